@@ -31,3 +31,264 @@ const (
 	// LastAppliedConfigurationTime is set as a timestamp infor of the last configuration update byt the CAPI Operator resource.
 	LastAppliedConfigurationTime = "LastAppliedConfigurationTime"
 )
+
+const (
+	// RancherAgentRegisteredCondition reports whether the Rancher agent applied to the downstream cluster
+	// registered back with Rancher.
+	RancherAgentRegisteredCondition clusterv1.ConditionType = "RancherAgentRegistered"
+
+	// WaitingForAgentRegistrationReason is used while the Rancher cluster has not become ready after the import manifest was applied.
+	WaitingForAgentRegistrationReason = "WaitingForAgentRegistration"
+
+	// AgentRegistrationFailedReason is used when the Rancher cluster did not become ready in time and the downstream agent is failing.
+	AgentRegistrationFailedReason = "AgentRegistrationFailed"
+)
+
+const (
+	// ImportWindowCondition reports whether the cluster can be imported according to the configured maintenance windows.
+	ImportWindowCondition clusterv1.ConditionType = "ImportWindowOpen"
+
+	// WaitingForImportWindowReason is used when an eligible cluster waits for the next maintenance window to be imported.
+	WaitingForImportWindowReason = "WaitingForImportWindow"
+)
+
+const (
+	// RancherNamespaceCondition reports whether the namespace the Rancher cluster is created in exists.
+	RancherNamespaceCondition clusterv1.ConditionType = "RancherNamespaceReady"
+
+	// RancherNamespaceMissingReason is used when the namespace of the Rancher cluster doesn't exist and can't be created.
+	RancherNamespaceMissingReason = "RancherNamespaceMissing"
+)
+
+const (
+	// ImportSkippedReason is used for the namespace events explaining its clusters are not imported as the namespace
+	// is not marked for import.
+	ImportSkippedReason = "ImportSkipped"
+)
+
+const (
+	// ImportDegradedCondition is set to true when the agent is deployed on the downstream cluster but the Rancher
+	// cluster stayed not ready for longer than the disconnected threshold.
+	ImportDegradedCondition clusterv1.ConditionType = "ImportDegraded"
+
+	// AgentDisconnectedReason is used when the running agent can't reach Rancher.
+	AgentDisconnectedReason = "AgentDisconnected"
+)
+
+const (
+	// ImportEligibleCondition summarizes whether the CAPI cluster passes all the checks required to be imported.
+	ImportEligibleCondition clusterv1.ConditionType = "ImportEligible"
+
+	// ImportNotEligibleReason is used when the CAPI cluster fails at least one of the import checks.
+	ImportNotEligibleReason = "ImportNotEligible"
+)
+
+const (
+	// ControlPlaneWaitCondition reports whether the control plane of the CAPI cluster became ready after the
+	// controller first observed it not ready. Its last transition time marks the start of the wait while it is false.
+	ControlPlaneWaitCondition clusterv1.ConditionType = "ControlPlaneWaitCompleted"
+
+	// WaitingForControlPlaneReason is used while the import waits for the control plane of the CAPI cluster to be ready.
+	WaitingForControlPlaneReason = "WaitingForControlPlane"
+)
+
+const (
+	// ImportPendingCondition is set to true while the import is held back, with the reason it waits for, and removed
+	// once the import proceeds.
+	ImportPendingCondition clusterv1.ConditionType = "ImportPending"
+)
+
+const (
+	// ManifestChangedReason is used for the events recording that the downloaded registration manifest differs from the
+	// previously applied one, e.g. after Rancher rotated the registration token, and is re-applied.
+	ManifestChangedReason = "ManifestChanged"
+)
+
+const (
+	// RancherClusterLinkedCondition reports whether the CAPI cluster is linked to an existing Rancher cluster. Its
+	// message references the Rancher cluster.
+	RancherClusterLinkedCondition clusterv1.ConditionType = "RancherClusterLinked"
+
+	// RancherAgentDeployedCondition mirrors whether Rancher deployed its agent on the downstream cluster.
+	RancherAgentDeployedCondition clusterv1.ConditionType = "RancherAgentDeployed"
+
+	// RancherClusterReadyCondition mirrors the readiness of the Rancher cluster.
+	RancherClusterReadyCondition clusterv1.ConditionType = "RancherClusterReady"
+
+	// RancherClusterDeletedReason is used when the linked Rancher cluster is being deleted.
+	RancherClusterDeletedReason = "RancherClusterDeleted"
+
+	// AgentNotDeployedReason is used while Rancher didn't deploy its agent on the downstream cluster.
+	AgentNotDeployedReason = "AgentNotDeployed"
+
+	// RancherClusterNotReadyReason is used while the Rancher cluster is not ready.
+	RancherClusterNotReadyReason = "RancherClusterNotReady"
+)
+
+const (
+	// ImportAttemptsCondition reports whether the cluster still has import attempts left before the controller backs
+	// off to the long retry interval. Its message shows the attempts consumed out of the maximum.
+	ImportAttemptsCondition clusterv1.ConditionType = "ImportAttemptsRemaining"
+
+	// ImportAttemptsExhaustedReason is used when the consecutive failed import attempts reached the maximum.
+	ImportAttemptsExhaustedReason = "ImportAttemptsExhausted"
+)
+
+const (
+	// KubernetesVersionSupportedCondition reports whether the Kubernetes version of the CAPI cluster is in the range
+	// supported by Rancher for imported clusters.
+	KubernetesVersionSupportedCondition clusterv1.ConditionType = "KubernetesVersionSupported"
+
+	// UnsupportedKubernetesVersionReason is used when the Kubernetes version of the CAPI cluster is out of the
+	// supported range.
+	UnsupportedKubernetesVersionReason = "UnsupportedKubernetesVersion"
+)
+
+const (
+	// RancherCapacityCondition reports whether Rancher has capacity left for the CAPI cluster to be imported.
+	RancherCapacityCondition clusterv1.ConditionType = "RancherCapacityAvailable"
+
+	// RancherAtCapacityReason is used while Rancher manages the configured maximum number of clusters.
+	RancherAtCapacityReason = "RancherAtCapacity"
+)
+
+const (
+	// RancherAgentActiveCondition reports whether the cattle-cluster-agent runs on the downstream cluster, or was
+	// scaled down because the import is suspended.
+	RancherAgentActiveCondition clusterv1.ConditionType = "RancherAgentActive"
+
+	// ImportSuspendedReason is used while the import of the CAPI cluster is suspended.
+	ImportSuspendedReason = "ImportSuspended"
+
+	// ImportResumedReason is used for the events recording that a suspended import was resumed.
+	ImportResumedReason = "ImportResumed"
+)
+
+const (
+	// ManifestVerifiedCondition reports whether the downloaded registration manifest matches the checksum expected by
+	// the CAPI cluster. It is only set when a checksum is expected.
+	ManifestVerifiedCondition clusterv1.ConditionType = "ManifestVerified"
+
+	// ManifestVerificationFailedReason is used when the registration manifest doesn't match the expected checksum,
+	// and is not applied.
+	ManifestVerificationFailedReason = "ManifestVerificationFailed"
+)
+
+const (
+	// RancherClusterNameCompliantCondition reports whether the name of the Rancher cluster complies with the configured
+	// naming policy.
+	RancherClusterNameCompliantCondition clusterv1.ConditionType = "RancherClusterNameCompliant"
+
+	// NamePolicyViolationReason is used when the name of the Rancher cluster violates the naming policy, and the CAPI
+	// cluster is not imported.
+	NamePolicyViolationReason = "NamePolicyViolation"
+)
+
+const (
+	// RancherClusterRelinkedReason is used for the events recording that the Rancher cluster of a CAPI cluster recreated
+	// with the same name was relinked to the new CAPI cluster. It is also the reason of the RancherAgentRegistered
+	// condition until the import manifest is applied to the new CAPI cluster.
+	RancherClusterRelinkedReason = "RancherClusterRelinked"
+)
+
+const (
+	// ManifestApplyPermittedCondition reports whether the remote cluster client was allowed to write all the objects
+	// of the import manifest.
+	ManifestApplyPermittedCondition clusterv1.ConditionType = "ManifestApplyPermitted"
+
+	// ManifestApplyForbiddenReason is used when the remote cluster client is forbidden to write some of the import
+	// manifest objects.
+	ManifestApplyForbiddenReason = "ManifestApplyForbidden"
+)
+
+const (
+	// ExclusiveManagementCondition reports whether the downstream cluster is free of a cattle-cluster-agent registered
+	// with another Rancher server.
+	ExclusiveManagementCondition clusterv1.ConditionType = "ExclusiveManagement"
+
+	// ManagedByOtherRancherReason is used when the downstream cluster is already managed by another Rancher server, and
+	// the import manifest is not applied.
+	ManagedByOtherRancherReason = "ManagedByOtherRancher"
+)
+
+const (
+	// EndpointsReachableCondition reports whether the management cluster can reach the control plane endpoint of the
+	// downstream cluster, and the agent the Rancher server-url of the import manifest. It is only set when the endpoint
+	// diagnostics are enabled.
+	EndpointsReachableCondition clusterv1.ConditionType = "EndpointsReachable"
+
+	// ControlPlaneEndpointUnreachableReason is used when the management cluster can't reach the control plane endpoint
+	// of the downstream cluster.
+	ControlPlaneEndpointUnreachableReason = "ControlPlaneEndpointUnreachable"
+
+	// RancherEndpointUnreachableReason is used when the agent can't, or likely can't, reach the Rancher server-url
+	// referenced by the import manifest.
+	RancherEndpointUnreachableReason = "RancherEndpointUnreachable"
+)
+
+const (
+	// RegistrationTokenRefreshedReason is used when the agent was rejected by Rancher with an authorization failure and
+	// the registration token of the cluster was replaced, while the registration manifest of the new token is applied.
+	RegistrationTokenRefreshedReason = "RegistrationTokenRefreshed"
+)
+
+const (
+	// RegistrationTokenReadyCondition reports whether the registration token of the Rancher cluster has its manifest
+	// URL set, so that the registration manifest can be downloaded.
+	RegistrationTokenReadyCondition clusterv1.ConditionType = "RegistrationTokenReady"
+
+	// WaitingForClusterNameReason is used while Rancher has not assigned a management cluster name to the Rancher
+	// cluster, which its registration token is created for.
+	WaitingForClusterNameReason = "WaitingForClusterName"
+
+	// WaitingForManifestURLReason is used while Rancher has not set the manifest URL of the registration token.
+	WaitingForManifestURLReason = "WaitingForManifestURL"
+
+	// ImportManifestAppliedCondition reports whether the registration manifest was applied to the downstream cluster.
+	ImportManifestAppliedCondition clusterv1.ConditionType = "ImportManifestApplied"
+
+	// WaitingForRegistrationTokenReason is used while the registration manifest can't be downloaded because the
+	// registration token is not ready.
+	WaitingForRegistrationTokenReason = "WaitingForRegistrationToken"
+
+	// ManifestApplyFailedReason is used when downloading or applying the registration manifest failed.
+	ManifestApplyFailedReason = "ManifestApplyFailed"
+
+	// WaitingForMachinePoolReason is used while none of the machine pools of the CAPI cluster is ready, when the
+	// import waits for machine pools.
+	WaitingForMachinePoolReason = "WaitingForMachinePool"
+)
+
+const (
+	// AgentAdoptedReason is used for the events recording that an existing healthy cattle-cluster-agent registered
+	// with the same Rancher server was adopted instead of applying the import manifest.
+	AgentAdoptedReason = "AgentAdopted"
+)
+
+const (
+	// ManifestDryRunReason is used when the registration manifest was applied with a server-side dry-run, which
+	// validates its objects without persisting them to the downstream cluster.
+	ManifestDryRunReason = "ManifestDryRun"
+)
+
+const (
+	// AgentHealthyCondition reports whether the cattle-cluster-agent deployment applied with the import manifest is
+	// available on the downstream cluster. It is only set when the agent health check is enabled.
+	AgentHealthyCondition clusterv1.ConditionType = "AgentHealthy"
+
+	// AgentUnavailableReason is used while the cattle-cluster-agent deployment is missing from the downstream cluster
+	// or not available.
+	AgentUnavailableReason = "AgentUnavailable"
+)
+
+const (
+	// AgentMissingReason is used for the events recording that the import manifest was re-applied because the
+	// cattle-cluster-agent deployment was deleted from the downstream cluster while Rancher reports it as deployed.
+	AgentMissingReason = "AgentMissing"
+)
+
+const (
+	// InvalidAccessLabelReason is used for the events recording that the value of an access label is not a valid label
+	// value, so that the label is not set on the Rancher cluster.
+	InvalidAccessLabelReason = "InvalidAccessLabel"
+)
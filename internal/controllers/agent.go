@@ -0,0 +1,225 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	cattleSystemNamespace  = "cattle-system"
+	cattleClusterAgentName = "cattle-cluster-agent"
+)
+
+// diagnoseAgent inspects the cattle-cluster-agent pods on the downstream cluster and returns a human readable
+// failure reason. An empty string is returned when all agent pods are running and ready.
+func diagnoseAgent(ctx context.Context, remoteClient client.Client) (string, error) {
+	pods := &corev1.PodList{}
+	if err := remoteClient.List(ctx, pods,
+		client.InNamespace(cattleSystemNamespace),
+		client.MatchingLabels{"app": cattleClusterAgentName},
+	); err != nil {
+		return "", fmt.Errorf("listing agent pods: %w", err)
+	}
+
+	if len(pods.Items) == 0 {
+		return fmt.Sprintf("no %s pods found in namespace %s", cattleClusterAgentName, cattleSystemNamespace), nil
+	}
+
+	events := &corev1.EventList{}
+	if err := remoteClient.List(ctx, events, client.InNamespace(cattleSystemNamespace)); err != nil {
+		return "", fmt.Errorf("listing agent events: %w", err)
+	}
+
+	reasons := []string{}
+
+	for i := range pods.Items {
+		if reason := diagnoseAgentPod(&pods.Items[i], events.Items); reason != "" {
+			reasons = append(reasons, reason)
+		}
+	}
+
+	return strings.Join(reasons, "; "), nil
+}
+
+// diagnoseAgentPod returns the failure reason for a single agent pod, or an empty string if the pod is healthy.
+func diagnoseAgentPod(pod *corev1.Pod, events []corev1.Event) string {
+	details := []string{}
+
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.Ready {
+			continue
+		}
+
+		detail := fmt.Sprintf("container %s is not ready", status.Name)
+		if status.State.Waiting != nil && status.State.Waiting.Reason != "" {
+			detail = fmt.Sprintf("container %s is in %s", status.Name, status.State.Waiting.Reason)
+		}
+
+		if status.RestartCount > 0 {
+			detail += fmt.Sprintf(" (restarted %d times)", status.RestartCount)
+		}
+
+		if terminated := status.LastTerminationState.Terminated; terminated != nil {
+			detail += fmt.Sprintf(", last terminated with %s (exit code %d)", terminated.Reason, terminated.ExitCode)
+
+			if message := strings.TrimSpace(terminated.Message); message != "" {
+				detail += ": " + message
+			}
+		}
+
+		details = append(details, detail)
+	}
+
+	if len(details) == 0 && pod.Status.Phase == corev1.PodRunning {
+		return ""
+	}
+
+	if len(pod.Status.ContainerStatuses) == 0 {
+		details = append(details, fmt.Sprintf("pod is %s", pod.Status.Phase))
+	}
+
+	if event := latestWarningEvent(pod, events); event != nil {
+		details = append(details, fmt.Sprintf("last warning event %s: %s", event.Reason, event.Message))
+	}
+
+	return fmt.Sprintf("pod %s: %s", pod.Name, strings.Join(details, ", "))
+}
+
+// latestWarningEvent returns the most recent warning event involving the pod.
+func latestWarningEvent(pod *corev1.Pod, events []corev1.Event) *corev1.Event {
+	warnings := []corev1.Event{}
+
+	for _, event := range events {
+		if event.Type == corev1.EventTypeWarning && event.InvolvedObject.Kind == "Pod" && event.InvolvedObject.Name == pod.Name {
+			warnings = append(warnings, event)
+		}
+	}
+
+	if len(warnings) == 0 {
+		return nil
+	}
+
+	sort.Slice(warnings, func(i, j int) bool {
+		return warnings[i].LastTimestamp.Before(&warnings[j].LastTimestamp)
+	})
+
+	return &warnings[len(warnings)-1]
+}
+
+// applyAgentScheduling merges the node selector and tolerations into the pod template of the cattle-cluster-agent
+// deployment of the manifest. Existing node selector keys are overridden, and tolerations are only added when no
+// equivalent toleration is already present.
+func applyAgentScheduling(objs []*unstructured.Unstructured, nodeSelector map[string]string, tolerations []corev1.Toleration) error {
+	if len(nodeSelector) == 0 && len(tolerations) == 0 {
+		return nil
+	}
+
+	for _, obj := range objs {
+		if obj.GroupVersionKind().GroupKind().String() != "Deployment.apps" ||
+			obj.GetName() != cattleClusterAgentName || obj.GetNamespace() != cattleSystemNamespace {
+			continue
+		}
+
+		podSpecPath := []string{"spec", "template", "spec"}
+
+		if len(nodeSelector) > 0 {
+			current, _, err := unstructured.NestedStringMap(obj.Object, append(podSpecPath, "nodeSelector")...)
+			if err != nil {
+				return fmt.Errorf("reading agent node selector: %w", err)
+			}
+
+			if current == nil {
+				current = map[string]string{}
+			}
+
+			for key, value := range nodeSelector {
+				current[key] = value
+			}
+
+			if err := unstructured.SetNestedStringMap(obj.Object, current, append(podSpecPath, "nodeSelector")...); err != nil {
+				return fmt.Errorf("setting agent node selector: %w", err)
+			}
+		}
+
+		if len(tolerations) > 0 {
+			if err := mergeTolerations(obj, append(podSpecPath, "tolerations"), tolerations); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func mergeTolerations(obj *unstructured.Unstructured, path []string, tolerations []corev1.Toleration) error {
+	current, _, err := unstructured.NestedSlice(obj.Object, path...)
+	if err != nil {
+		return fmt.Errorf("reading agent tolerations: %w", err)
+	}
+
+	existing := make([]corev1.Toleration, len(current))
+
+	for i := range current {
+		item, ok := current[i].(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("unexpected agent toleration type %T", current[i])
+		}
+
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(item, &existing[i]); err != nil {
+			return fmt.Errorf("converting agent toleration: %w", err)
+		}
+	}
+
+	for i := range tolerations {
+		toleration := tolerations[i]
+
+		found := false
+
+		for j := range existing {
+			if existing[j].MatchToleration(&toleration) {
+				found = true
+				break
+			}
+		}
+
+		if found {
+			continue
+		}
+
+		item, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&toleration)
+		if err != nil {
+			return fmt.Errorf("converting agent toleration: %w", err)
+		}
+
+		current = append(current, item)
+	}
+
+	if err := unstructured.SetNestedSlice(obj.Object, current, path...); err != nil {
+		return fmt.Errorf("setting agent tolerations: %w", err)
+	}
+
+	return nil
+}
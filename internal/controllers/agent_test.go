@@ -0,0 +1,430 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	clocktesting "k8s.io/utils/clock/testing"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	turtlesv1 "github.com/rancher/turtles/api/v1alpha1"
+	provisioningv1 "github.com/rancher/turtles/internal/rancher/provisioning/v1"
+	"github.com/rancher/turtles/testutil"
+)
+
+func agentPod(ready bool) *corev1.Pod {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "cattle-cluster-agent-abc",
+			Namespace: cattleSystemNamespace,
+			Labels:    map[string]string{"app": cattleClusterAgentName},
+		},
+		Status: corev1.PodStatus{
+			Phase: corev1.PodRunning,
+			ContainerStatuses: []corev1.ContainerStatus{{
+				Name:  "cluster-register",
+				Ready: true,
+			}},
+		},
+	}
+
+	if !ready {
+		pod.Status.ContainerStatuses[0] = corev1.ContainerStatus{
+			Name:         "cluster-register",
+			RestartCount: 5,
+			State: corev1.ContainerState{
+				Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"},
+			},
+			LastTerminationState: corev1.ContainerState{
+				Terminated: &corev1.ContainerStateTerminated{
+					Reason:   "Error",
+					ExitCode: 1,
+					Message:  "https://rancher.example.com/ping is not accessible",
+				},
+			},
+		}
+	}
+
+	return pod
+}
+
+var _ = Describe("verify agent registration", func() {
+	var (
+		r              *CAPIImportReconciler
+		remoteClient   client.Client
+		recorder       *record.FakeRecorder
+		fakeClock      *clocktesting.FakeClock
+		capiCluster    *clusterv1.Cluster
+		rancherCluster *provisioningv1.Cluster
+	)
+
+	BeforeEach(func() {
+		fakeClock = clocktesting.NewFakeClock(time.Now())
+		recorder = record.NewFakeRecorder(10)
+		remoteClient = fake.NewClientBuilder().WithScheme(scheme.Scheme).Build()
+
+		r = &CAPIImportReconciler{
+			recorder: recorder,
+			clock:    fakeClock,
+			remoteClientGetter: func(_ context.Context, _ string, _ client.Client, _ client.ObjectKey) (client.Client, error) {
+				return remoteClient, nil
+			},
+			TimingOptions: TimingOptions{
+				RegistrationCheckWindow: time.Minute,
+			},
+		}
+
+		capiCluster = &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", Namespace: "test-ns"}}
+		rancherCluster = &provisioningv1.Cluster{Status: provisioningv1.ClusterStatus{AgentDeployed: true}}
+	})
+
+	It("should mark the agent registered when the Rancher cluster is ready", func() {
+		rancherCluster.Status.Ready = true
+
+		res, err := r.verifyRegistration(ctx, capiCluster, rancherCluster)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res.RequeueAfter).To(BeZero())
+		Expect(conditions.IsTrue(capiCluster, turtlesv1.RancherAgentRegisteredCondition)).To(BeTrue())
+	})
+
+	It("should wait for the registration window before inspecting the agent", func() {
+		res, err := r.verifyRegistration(ctx, capiCluster, rancherCluster)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res.RequeueAfter).To(Equal(time.Minute))
+		Expect(conditions.GetReason(capiCluster, turtlesv1.RancherAgentRegisteredCondition)).To(Equal(turtlesv1.WaitingForAgentRegistrationReason))
+		Expect(recorder.Events).To(BeEmpty())
+	})
+
+	It("should report a healthy agent as still waiting after the window", func() {
+		Expect(remoteClient.Create(ctx, agentPod(true))).To(Succeed())
+		conditions.MarkFalse(capiCluster, turtlesv1.RancherAgentRegisteredCondition, turtlesv1.WaitingForAgentRegistrationReason,
+			clusterv1.ConditionSeverityInfo, "")
+		fakeClock.Step(2 * time.Minute)
+
+		_, err := r.verifyRegistration(ctx, capiCluster, rancherCluster)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(conditions.GetReason(capiCluster, turtlesv1.RancherAgentRegisteredCondition)).To(Equal(turtlesv1.WaitingForAgentRegistrationReason))
+		Expect(recorder.Events).To(BeEmpty())
+	})
+
+	It("should surface the failure reason of a crash-looping agent", func() {
+		Expect(remoteClient.Create(ctx, agentPod(false))).To(Succeed())
+		Expect(remoteClient.Create(ctx, &corev1.Event{
+			ObjectMeta:     metav1.ObjectMeta{Name: "agent-event", Namespace: cattleSystemNamespace},
+			InvolvedObject: corev1.ObjectReference{Kind: "Pod", Name: "cattle-cluster-agent-abc"},
+			Type:           corev1.EventTypeWarning,
+			Reason:         "BackOff",
+			Message:        "Back-off restarting failed container",
+		})).To(Succeed())
+		conditions.MarkFalse(capiCluster, turtlesv1.RancherAgentRegisteredCondition, turtlesv1.WaitingForAgentRegistrationReason,
+			clusterv1.ConditionSeverityInfo, "")
+		fakeClock.Step(2 * time.Minute)
+
+		res, err := r.verifyRegistration(ctx, capiCluster, rancherCluster)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res.RequeueAfter).To(Equal(time.Minute))
+		Expect(conditions.GetReason(capiCluster, turtlesv1.RancherAgentRegisteredCondition)).To(Equal(turtlesv1.AgentRegistrationFailedReason))
+
+		message := conditions.GetMessage(capiCluster, turtlesv1.RancherAgentRegisteredCondition)
+		Expect(message).To(ContainSubstring("CrashLoopBackOff"))
+		Expect(message).To(ContainSubstring("https://rancher.example.com/ping is not accessible"))
+		Expect(message).To(ContainSubstring("Back-off restarting failed container"))
+		Expect(recorder.Events).To(Receive(ContainSubstring(turtlesv1.AgentRegistrationFailedReason)))
+	})
+
+	It("should report missing agent pods", func() {
+		reason, err := diagnoseAgent(ctx, remoteClient)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(reason).To(ContainSubstring("no cattle-cluster-agent pods found"))
+	})
+})
+
+var _ = Describe("disconnected agent escalation", func() {
+	var (
+		r              *CAPIImportReconciler
+		remoteClient   client.Client
+		recorder       *record.FakeRecorder
+		fakeClock      *clocktesting.FakeClock
+		capiCluster    *clusterv1.Cluster
+		rancherCluster *provisioningv1.Cluster
+	)
+
+	BeforeEach(func() {
+		fakeClock = clocktesting.NewFakeClock(time.Now())
+		recorder = record.NewFakeRecorder(10)
+		remoteClient = fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(agentPod(true)).Build()
+
+		r = &CAPIImportReconciler{
+			recorder: recorder,
+			clock:    fakeClock,
+			remoteClientGetter: func(_ context.Context, _ string, _ client.Client, _ client.ObjectKey) (client.Client, error) {
+				return remoteClient, nil
+			},
+			TimingOptions: TimingOptions{
+				RegistrationCheckWindow: time.Minute,
+				DisconnectedThreshold:   30 * time.Minute,
+			},
+		}
+
+		capiCluster = &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", Namespace: "test-ns"}}
+		rancherCluster = &provisioningv1.Cluster{Status: provisioningv1.ClusterStatus{ClusterName: "c-m-test", AgentDeployed: true}}
+
+		// the agent was found running while the Rancher cluster is not ready
+		conditions.Set(capiCluster, &clusterv1.Condition{
+			Type:               turtlesv1.RancherAgentRegisteredCondition,
+			Status:             corev1.ConditionFalse,
+			Reason:             turtlesv1.WaitingForAgentRegistrationReason,
+			Severity:           clusterv1.ConditionSeverityWarning,
+			Message:            "Agent is running but the Rancher cluster is not ready yet",
+			LastTransitionTime: metav1.NewTime(fakeClock.Now()),
+		})
+	})
+
+	It("should not escalate before the threshold", func() {
+		fakeClock.Step(10 * time.Minute)
+
+		_, err := r.verifyRegistration(ctx, capiCluster, rancherCluster)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(conditions.Has(capiCluster, turtlesv1.ImportDegradedCondition)).To(BeFalse())
+		Expect(recorder.Events).To(BeEmpty())
+	})
+
+	It("should escalate once the disconnected state persists past the threshold", func() {
+		fakeClock.Step(31 * time.Minute)
+
+		res, err := r.verifyRegistration(ctx, capiCluster, rancherCluster)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res.RequeueAfter).To(Equal(time.Minute))
+
+		Expect(conditions.IsTrue(capiCluster, turtlesv1.ImportDegradedCondition)).To(BeTrue())
+		Expect(conditions.GetReason(capiCluster, turtlesv1.ImportDegradedCondition)).To(Equal(turtlesv1.AgentDisconnectedReason))
+		Expect(conditions.GetMessage(capiCluster, turtlesv1.ImportDegradedCondition)).To(ContainSubstring("network policies"))
+		Expect(conditions.GetMessage(capiCluster, turtlesv1.ImportDegradedCondition)).To(ContainSubstring("server-url"))
+		Expect(recorder.Events).To(Receive(ContainSubstring(turtlesv1.AgentDisconnectedReason)))
+
+		By("not escalating again while degraded")
+		fakeClock.Step(time.Minute)
+
+		_, err = r.verifyRegistration(ctx, capiCluster, rancherCluster)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(recorder.Events).To(BeEmpty())
+
+		By("clearing the condition once the cluster is ready")
+		rancherCluster.Status.Ready = true
+
+		_, err = r.verifyRegistration(ctx, capiCluster, rancherCluster)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(conditions.Has(capiCluster, turtlesv1.ImportDegradedCondition)).To(BeFalse())
+	})
+
+	It("should re-apply the import manifest when enabled", func() {
+		server := testutil.NewManifestServer(manifestWithServerFields)
+		defer server.Close()
+
+		r.ReapplyOnDisconnect = true
+		r.RancherClient = testutil.NewRancherClientBuilder().WithObjects(
+			testutil.RegistrationToken("c-m-test", "test-ns", server.URL),
+		).Build()
+
+		fakeClock.Step(31 * time.Minute)
+
+		_, err := r.verifyRegistration(ctx, capiCluster, rancherCluster)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(conditions.IsTrue(capiCluster, turtlesv1.ImportDegradedCondition)).To(BeTrue())
+		Expect(server.Requests()).To(Equal(1))
+		Expect(remoteClient.Get(ctx, client.ObjectKey{Name: "cattle-system"}, &corev1.Namespace{})).To(Succeed())
+	})
+
+	It("should throttle re-applications of a flapping agent to the minimum interval", func() {
+		server := testutil.NewManifestServer(manifestWithServerFields)
+		defer server.Close()
+
+		r.ReapplyOnDisconnect = true
+		r.DisconnectedThreshold = 2 * time.Minute
+		r.MinReapplyInterval = 10 * time.Minute
+		r.recorder = record.NewFakeRecorder(100)
+		r.RancherClient = testutil.NewRancherClientBuilder().WithObjects(
+			testutil.RegistrationToken("c-m-test", "test-ns", server.URL),
+		).Build()
+
+		capiCluster.Spec.InfrastructureRef = &corev1.ObjectReference{Kind: "ReapplyTestCluster"}
+		suppressed := reappliesSuppressed.WithLabelValues("ReapplyTestCluster")
+		before := promtestutil.ToFloat64(suppressed)
+
+		// the agent disconnects 3 minutes after every reconnection, 12 times over 36 minutes
+		for i := 0; i < 12; i++ {
+			fakeClock.Step(3 * time.Minute)
+
+			_, err := r.verifyRegistration(ctx, capiCluster, rancherCluster)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(conditions.IsTrue(capiCluster, turtlesv1.ImportDegradedCondition)).To(BeTrue())
+
+			By("reconnecting the agent")
+			rancherCluster.Status.Ready = true
+
+			_, err = r.verifyRegistration(ctx, capiCluster, rancherCluster)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(conditions.Has(capiCluster, turtlesv1.ImportDegradedCondition)).To(BeFalse())
+
+			By("disconnecting the agent again")
+			rancherCluster.Status.Ready = false
+			conditions.Delete(capiCluster, turtlesv1.RancherAgentRegisteredCondition)
+			conditions.Set(capiCluster, &clusterv1.Condition{
+				Type:               turtlesv1.RancherAgentRegisteredCondition,
+				Status:             corev1.ConditionFalse,
+				Reason:             turtlesv1.WaitingForAgentRegistrationReason,
+				Severity:           clusterv1.ConditionSeverityWarning,
+				LastTransitionTime: metav1.NewTime(fakeClock.Now()),
+			})
+		}
+
+		// re-applied at 3, 15 and 27 minutes
+		Expect(server.Requests()).To(Equal(3))
+		Expect(promtestutil.ToFloat64(suppressed) - before).To(Equal(float64(9)))
+
+		By("re-applying again once the interval elapsed")
+		fakeClock.Step(10 * time.Minute)
+
+		_, err := r.verifyRegistration(ctx, capiCluster, rancherCluster)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(server.Requests()).To(Equal(4))
+	})
+
+	It("should not throttle re-applications without a minimum interval", func() {
+		server := testutil.NewManifestServer(manifestWithServerFields)
+		defer server.Close()
+
+		r.ReapplyOnDisconnect = true
+		r.RancherClient = testutil.NewRancherClientBuilder().WithObjects(
+			testutil.RegistrationToken("c-m-test", "test-ns", server.URL),
+		).Build()
+
+		for i := 0; i < 3; i++ {
+			fakeClock.Step(31 * time.Minute)
+
+			_, err := r.verifyRegistration(ctx, capiCluster, rancherCluster)
+			Expect(err).ToNot(HaveOccurred())
+
+			conditions.Delete(capiCluster, turtlesv1.ImportDegradedCondition)
+		}
+
+		Expect(server.Requests()).To(Equal(3))
+	})
+})
+
+var _ = Describe("agent scheduling constraints", func() {
+	agentDeployment := func() *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "apps/v1",
+			"kind":       "Deployment",
+			"metadata": map[string]interface{}{
+				"name":      cattleClusterAgentName,
+				"namespace": cattleSystemNamespace,
+			},
+			"spec": map[string]interface{}{
+				"template": map[string]interface{}{
+					"spec": map[string]interface{}{
+						"nodeSelector": map[string]interface{}{
+							"kubernetes.io/os": "linux",
+							"tier":             "default",
+						},
+						"tolerations": []interface{}{
+							map[string]interface{}{
+								"key":      "node-role.kubernetes.io/control-plane",
+								"effect":   "NoSchedule",
+								"operator": "Exists",
+							},
+						},
+					},
+				},
+			},
+		}}
+	}
+
+	It("should leave the manifest untouched when nothing is configured", func() {
+		deployment := agentDeployment()
+		expected := deployment.DeepCopy()
+
+		Expect(applyAgentScheduling([]*unstructured.Unstructured{deployment}, nil, nil)).To(Succeed())
+		Expect(deployment).To(Equal(expected))
+	})
+
+	It("should merge the node selector and add missing tolerations", func() {
+		deployment := agentDeployment()
+		other := &unstructured.Unstructured{}
+		other.SetAPIVersion("apps/v1")
+		other.SetKind("Deployment")
+		other.SetName("other")
+		other.SetNamespace(cattleSystemNamespace)
+		otherCopy := other.DeepCopy()
+
+		Expect(applyAgentScheduling([]*unstructured.Unstructured{other, deployment},
+			map[string]string{"tier": "infra"},
+			[]corev1.Toleration{
+				{Key: "node-role.kubernetes.io/control-plane", Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoSchedule},
+				{Key: "dedicated", Operator: corev1.TolerationOpEqual, Value: "rancher", Effect: corev1.TaintEffectNoExecute},
+			},
+		)).To(Succeed())
+
+		Expect(other).To(Equal(otherCopy))
+
+		nodeSelector, _, err := unstructured.NestedStringMap(deployment.Object, "spec", "template", "spec", "nodeSelector")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(nodeSelector).To(Equal(map[string]string{"kubernetes.io/os": "linux", "tier": "infra"}))
+
+		tolerations, _, err := unstructured.NestedSlice(deployment.Object, "spec", "template", "spec", "tolerations")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(tolerations).To(HaveLen(2))
+		Expect(tolerations[1]).To(Equal(map[string]interface{}{
+			"key":      "dedicated",
+			"operator": "Equal",
+			"value":    "rancher",
+			"effect":   "NoExecute",
+		}))
+	})
+
+	It("should add constraints to an agent deployment without any", func() {
+		deployment := agentDeployment()
+		unstructured.RemoveNestedField(deployment.Object, "spec", "template", "spec")
+
+		Expect(applyAgentScheduling([]*unstructured.Unstructured{deployment},
+			map[string]string{"tier": "infra"},
+			[]corev1.Toleration{{Key: "dedicated", Operator: corev1.TolerationOpExists}},
+		)).To(Succeed())
+
+		nodeSelector, _, err := unstructured.NestedStringMap(deployment.Object, "spec", "template", "spec", "nodeSelector")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(nodeSelector).To(Equal(map[string]string{"tier": "infra"}))
+
+		tolerations, _, err := unstructured.NestedSlice(deployment.Object, "spec", "template", "spec", "tolerations")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(tolerations).To(HaveLen(1))
+	})
+})
@@ -0,0 +1,467 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// fieldManager is the field manager turtles applies the import manifest objects with when server-side apply is
+// enabled. It must stay constant across releases, as the API server tracks the fields owned by each manager and a
+// renamed manager would conflict with the fields owned by the previous one.
+const fieldManager = "rancher-turtles"
+
+// serverSideApplyObjects applies the manifest objects in the remote cluster with server-side apply, creating the
+// missing objects and updating the existing ones to match the manifest. When continueOnForbidden is set, the objects
+// the remote client is not allowed to apply are skipped and reported in the returned error.
+func serverSideApplyObjects(ctx context.Context, remoteClient client.Client, objs []*unstructured.Unstructured,
+	continueOnForbidden bool,
+) error {
+	return writeObjects(ctx, remoteClient, objs, continueOnForbidden, applyObject)
+}
+
+// dryRunObjects creates the manifest objects with a server-side dry-run, logging each object which would be applied.
+func dryRunObjects(ctx context.Context, remoteClient client.Client, objs []*unstructured.Unstructured,
+	continueOnForbidden bool,
+) error {
+	return writeObjects(ctx, remoteClient, objs, continueOnForbidden, dryRunObject)
+}
+
+// dryRunObject creates a single object with a server-side dry-run, passing client.DryRunAll to the create so the
+// object is validated without being persisted.
+func dryRunObject(ctx context.Context, c client.Client, obj client.Object) error {
+	if err := createObject(ctx, client.NewDryRunClient(c), obj); err != nil {
+		return err
+	}
+
+	log.FromContext(ctx).Info("Dry-run: object would be applied", "gvk", obj.GetObjectKind().GroupVersionKind(),
+		"name", obj.GetName(), "namespace", obj.GetNamespace())
+
+	return nil
+}
+
+// applyObject applies a single object with the turtles field manager, forcing ownership of the fields set in the
+// manifest. An object whose immutable fields changed in the manifest is left as is, as it can't be updated in place.
+func applyObject(ctx context.Context, c client.Client, obj client.Object) error {
+	log := log.FromContext(ctx)
+	gvk := obj.GetObjectKind().GroupVersionKind()
+
+	err := c.Patch(ctx, obj, client.Apply, client.FieldOwner(fieldManager), client.ForceOwnership)
+	if isImmutableFieldError(err) {
+		log.Info("object has changed immutable fields, keeping the existing object in remote cluster",
+			"gvk", gvk, "name", obj.GetName(), "namespace", obj.GetNamespace(), "error", err.Error())
+
+		return nil
+	}
+
+	if apierrors.IsForbidden(err) {
+		return newForbiddenObjectError("apply", obj, err)
+	}
+
+	if err != nil {
+		return fmt.Errorf("applying object in remote cluster: %w", err)
+	}
+
+	log.V(4).Info("object was applied", "gvk", gvk, "name", obj.GetName(), "namespace", obj.GetNamespace())
+
+	return nil
+}
+
+// isImmutableFieldError returns true if the write was rejected because it changes a field which is immutable, such
+// as the selector of a Deployment.
+func isImmutableFieldError(err error) bool {
+	if !apierrors.IsInvalid(err) {
+		return false
+	}
+
+	var status apierrors.APIStatus
+	if !errors.As(err, &status) {
+		return false
+	}
+
+	if details := status.Status().Details; details != nil {
+		for _, cause := range details.Causes {
+			if strings.Contains(cause.Message, "immutable") {
+				return true
+			}
+		}
+	}
+
+	return strings.Contains(status.Status().Message, "immutable")
+}
+
+// writeObjectsConcurrently writes the namespaces and custom resource definitions of the manifest in order, as the
+// other objects may depend on them, then the other objects with up to concurrency concurrent writes. No write is
+// started after an error, except forbidden objects skipped when continueOnForbidden is set, and the errors of the
+// writes in flight are joined in the returned error.
+func writeObjectsConcurrently(ctx context.Context, remoteClient client.Client, objs []*unstructured.Unstructured,
+	continueOnForbidden bool, concurrency int, write objectWriter,
+) error {
+	dependencies, rest := splitDependencies(objs)
+
+	err := writeObjects(ctx, remoteClient, dependencies, continueOnForbidden, write)
+	if _, other := splitForbidden(err); other != nil || (err != nil && !continueOnForbidden) {
+		return err
+	}
+
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		failed bool
+	)
+
+	errs := []error{err}
+	workers := make(chan struct{}, concurrency)
+
+	for _, obj := range rest {
+		workers <- struct{}{}
+
+		mu.Lock()
+		stop := failed
+		mu.Unlock()
+
+		if stop {
+			<-workers
+			break
+		}
+
+		if err := checkApplyAborted(ctx, obj); err != nil {
+			<-workers
+
+			mu.Lock()
+			errs = append(errs, err)
+			mu.Unlock()
+
+			break
+		}
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+			defer func() { <-workers }()
+
+			applyCtx, cancel := objectApplyContext(ctx)
+			defer cancel()
+
+			err := write(applyCtx, remoteClient, obj)
+			if err == nil {
+				return
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			errs = append(errs, err)
+			failed = failed || !continueOnForbidden || !isObjectForbidden(err)
+		}()
+	}
+
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// splitDependencies separates the namespaces and custom resource definitions of the manifest, which the other objects
+// may depend on, from the other objects. The manifest order is kept within both.
+func splitDependencies(objs []*unstructured.Unstructured) ([]*unstructured.Unstructured, []*unstructured.Unstructured) {
+	dependencies := []*unstructured.Unstructured{}
+	rest := []*unstructured.Unstructured{}
+
+	for _, obj := range objs {
+		switch obj.GroupVersionKind().GroupKind() {
+		case schema.GroupKind{Kind: "Namespace"}, crdGVK.GroupKind():
+			dependencies = append(dependencies, obj)
+		default:
+			rest = append(rest, obj)
+		}
+	}
+
+	return dependencies, rest
+}
+
+// orderObjects returns the manifest objects with the namespaces and custom resource definitions first, as the other
+// objects may depend on them. The manifest order is kept otherwise.
+func orderObjects(objs []*unstructured.Unstructured) []*unstructured.Unstructured {
+	dependencies, rest := splitDependencies(objs)
+
+	return append(dependencies, rest...)
+}
+
+// crdGVK is the kind of the custom resource definitions.
+var crdGVK = schema.GroupVersionKind{Group: "apiextensions.k8s.io", Version: "v1", Kind: "CustomResourceDefinition"}
+
+// splitCustomResources returns the CRDs of the manifest, and separates the custom resources of the kinds they define
+// from the other objects. The manifest order is kept.
+func splitCustomResources(objs []*unstructured.Unstructured) ([]*unstructured.Unstructured,
+	[]*unstructured.Unstructured, []*unstructured.Unstructured,
+) {
+	crds := []*unstructured.Unstructured{}
+	kinds := map[schema.GroupKind]bool{}
+
+	for _, obj := range objs {
+		if obj.GroupVersionKind().GroupKind() != crdGVK.GroupKind() {
+			continue
+		}
+
+		group, _, _ := unstructured.NestedString(obj.Object, "spec", "group")
+		kind, _, _ := unstructured.NestedString(obj.Object, "spec", "names", "kind")
+
+		crds = append(crds, obj)
+		kinds[schema.GroupKind{Group: group, Kind: kind}] = true
+	}
+
+	others := []*unstructured.Unstructured{}
+	resources := []*unstructured.Unstructured{}
+
+	for _, obj := range objs {
+		if kinds[obj.GroupVersionKind().GroupKind()] {
+			resources = append(resources, obj)
+		} else {
+			others = append(others, obj)
+		}
+	}
+
+	return crds, others, resources
+}
+
+// waitForCRDsEstablished polls the CRDs in the remote cluster until they are all established, for up to timeout.
+func waitForCRDsEstablished(ctx context.Context, remoteClient client.Client, crds []*unstructured.Unstructured,
+	interval, timeout time.Duration,
+) error {
+	for _, crd := range crds {
+		err := wait.PollUntilContextTimeout(ctx, interval, timeout, true, func(ctx context.Context) (bool, error) {
+			return crdEstablished(ctx, remoteClient, crd.GetName())
+		})
+		if err != nil {
+			return fmt.Errorf("waiting for CRD %s to be established: %w", crd.GetName(), err)
+		}
+	}
+
+	log.FromContext(ctx).V(4).Info("CRDs are established", "count", len(crds))
+
+	return nil
+}
+
+// crdEstablished returns true when the CRD exists in the remote cluster with the Established condition true.
+func crdEstablished(ctx context.Context, remoteClient client.Client, name string) (bool, error) {
+	crd := &unstructured.Unstructured{}
+	crd.SetGroupVersionKind(crdGVK)
+
+	if err := remoteClient.Get(ctx, client.ObjectKey{Name: name}, crd); err != nil {
+		return false, client.IgnoreNotFound(err)
+	}
+
+	crdConditions, _, _ := unstructured.NestedSlice(crd.Object, "status", "conditions")
+	for _, c := range crdConditions {
+		condition, ok := c.(map[string]interface{})
+		if ok && condition["type"] == "Established" && condition["status"] == "True" {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// applyObjectsIncrementally only creates the manifest objects missing in the remote cluster and patches the ones
+// which differ from the manifest, leaving unchanged objects untouched. It returns the number of objects written.
+// Objects are written in the order of writeObjects, with the same retry of the objects not found on the first pass.
+// When continueOnForbidden is set, the objects the remote client is not allowed to write are skipped and reported in
+// the returned error.
+func applyObjectsIncrementally(ctx context.Context, remoteClient client.Client, objs []*unstructured.Unstructured,
+	continueOnForbidden bool,
+) (int, error) {
+	applied := 0
+	forbidden := []error{}
+	pending := orderObjects(objs)
+
+	for pass := 1; pass <= manifestApplyPasses && len(pending) > 0; pass++ {
+		notFound := []*unstructured.Unstructured{}
+
+		for _, obj := range pending {
+			if err := checkApplyAborted(ctx, obj); err != nil {
+				return applied, errors.Join(append(forbidden, err)...)
+			}
+
+			written, err := applyObjectIncrementally(ctx, remoteClient, obj)
+			if pass < manifestApplyPasses && apierrors.IsNotFound(err) {
+				logNotFoundRetry(ctx, obj, err)
+				notFound = append(notFound, obj)
+
+				continue
+			}
+
+			if continueOnForbidden && isObjectForbidden(err) {
+				forbidden = append(forbidden, err)
+				continue
+			}
+
+			if err != nil {
+				return applied, errors.Join(append(forbidden, err)...)
+			}
+
+			if written {
+				applied++
+			}
+		}
+
+		pending = notFound
+	}
+
+	return applied, errors.Join(forbidden...)
+}
+
+// applyObjectIncrementally creates or patches a single object if it is missing or changed, and reports whether it
+// was written. The write is not interrupted by the cancellation of ctx.
+func applyObjectIncrementally(ctx context.Context, remoteClient client.Client, obj *unstructured.Unstructured) (bool, error) {
+	log := log.FromContext(ctx)
+
+	ctx, cancel := objectApplyContext(ctx)
+	defer cancel()
+
+	gvk := obj.GroupVersionKind()
+
+	existing := &unstructured.Unstructured{}
+	existing.SetGroupVersionKind(gvk)
+
+	err := remoteClient.Get(ctx, client.ObjectKeyFromObject(obj), existing)
+	if apierrors.IsNotFound(err) {
+		if err := createObject(ctx, remoteClient, obj); err != nil {
+			return false, err
+		}
+
+		return true, nil
+	}
+
+	if apierrors.IsForbidden(err) {
+		return false, newForbiddenObjectError("get", obj, err)
+	}
+
+	if err != nil {
+		return false, fmt.Errorf("getting object from remote cluster: %w", err)
+	}
+
+	if objectUpToDate(obj, existing) {
+		log.V(4).Info("object is up to date in remote cluster", "gvk", gvk, "name", obj.GetName(), "namespace", obj.GetNamespace())
+		return false, nil
+	}
+
+	err = remoteClient.Patch(ctx, obj, client.Merge)
+	if apierrors.IsForbidden(err) {
+		return false, newForbiddenObjectError("patch", obj, err)
+	}
+
+	if err != nil {
+		return false, fmt.Errorf("patching object in remote cluster: %w", err)
+	}
+
+	log.V(4).Info("object was updated", "gvk", gvk, "name", obj.GetName(), "namespace", obj.GetNamespace())
+
+	return true, nil
+}
+
+// objectUpToDate returns true when every field set in the desired object has the same value in the existing object.
+// Only labels and annotations are compared from the metadata, as the rest is owned by the server.
+func objectUpToDate(desired, existing *unstructured.Unstructured) bool {
+	for key, value := range desired.Object {
+		if key == "metadata" || key == "status" {
+			continue
+		}
+
+		if !isSubset(value, existing.Object[key]) {
+			return false
+		}
+	}
+
+	return isSubset(toInterfaceMap(desired.GetLabels()), toInterfaceMap(existing.GetLabels())) &&
+		isSubset(toInterfaceMap(desired.GetAnnotations()), toInterfaceMap(existing.GetAnnotations()))
+}
+
+// isSubset returns true if all the fields set in desired are set to the same value in actual. Fields defaulted by
+// the server are only present in actual, and are ignored.
+func isSubset(desired, actual interface{}) bool {
+	switch desired := desired.(type) {
+	case map[string]interface{}:
+		actual, ok := actual.(map[string]interface{})
+		if !ok {
+			return len(desired) == 0 && actual == nil
+		}
+
+		for key, value := range desired {
+			if !isSubset(value, actual[key]) {
+				return false
+			}
+		}
+
+		return true
+	case []interface{}:
+		actual, ok := actual.([]interface{})
+		if !ok || len(desired) != len(actual) {
+			return len(desired) == 0 && actual == nil
+		}
+
+		for i := range desired {
+			if !isSubset(desired[i], actual[i]) {
+				return false
+			}
+		}
+
+		return true
+	default:
+		if desiredNumber, ok := toFloat(desired); ok {
+			actualNumber, ok := toFloat(actual)
+			return ok && desiredNumber == actualNumber
+		}
+
+		return reflect.DeepEqual(desired, actual)
+	}
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch value := value.(type) {
+	case int64:
+		return float64(value), true
+	case int:
+		return float64(value), true
+	case float64:
+		return value, true
+	default:
+		return 0, false
+	}
+}
+
+func toInterfaceMap(in map[string]string) map[string]interface{} {
+	out := make(map[string]interface{}, len(in))
+	for k, v := range in {
+		out[k] = v
+	}
+
+	return out
+}
@@ -0,0 +1,708 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	turtlesv1 "github.com/rancher/turtles/api/v1alpha1"
+	provisioningv1 "github.com/rancher/turtles/internal/rancher/provisioning/v1"
+	"github.com/rancher/turtles/testutil"
+	turtlesannotations "github.com/rancher/turtles/util/annotations"
+)
+
+const incrementalManifest = `apiVersion: v1
+kind: Namespace
+metadata:
+  name: cattle-system
+---
+apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: cattle
+  namespace: cattle-system
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cattle-config
+  namespace: cattle-system
+  labels:
+    app: cattle
+data:
+  url: https://rancher.example.com
+`
+
+var _ = Describe("incremental apply of import manifest", func() {
+	var (
+		objs    []*unstructured.Unstructured
+		written []string
+	)
+
+	remoteClientWith := func(existing ...client.Object) client.Client {
+		return fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(existing...).WithInterceptorFuncs(interceptor.Funcs{
+			Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+				written = append(written, "create "+obj.GetName())
+				return c.Create(ctx, obj, opts...)
+			},
+			Patch: func(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+				written = append(written, "patch "+obj.GetName())
+				return c.Patch(ctx, obj, patch, opts...)
+			},
+		}).Build()
+	}
+
+	desired := func() []client.Object {
+		existing := []client.Object{}
+
+		decoded, err := decodeManifest(strings.NewReader(incrementalManifest))
+		Expect(err).ToNot(HaveOccurred())
+
+		for _, obj := range decoded {
+			existing = append(existing, obj)
+		}
+
+		return existing
+	}
+
+	BeforeEach(func() {
+		var err error
+
+		written = []string{}
+		objs, err = decodeManifest(strings.NewReader(incrementalManifest))
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("should not write anything when the remote cluster is up to date", func() {
+		remoteClient := remoteClientWith(desired()...)
+
+		applied, err := applyObjectsIncrementally(ctx, remoteClient, objs, false)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(applied).To(BeZero())
+		Expect(written).To(BeEmpty())
+	})
+
+	It("should only create the missing object", func() {
+		existing := desired()
+		remoteClient := remoteClientWith(existing[:2]...)
+
+		applied, err := applyObjectsIncrementally(ctx, remoteClient, objs, false)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(applied).To(Equal(1))
+		Expect(written).To(Equal([]string{"create cattle-config"}))
+	})
+
+	It("should only patch the changed object", func() {
+		existing := desired()
+		configMap := existing[2].(*unstructured.Unstructured)
+		Expect(unstructured.SetNestedField(configMap.Object, "https://old.example.com", "data", "url")).To(Succeed())
+
+		remoteClient := remoteClientWith(existing...)
+
+		applied, err := applyObjectsIncrementally(ctx, remoteClient, objs, false)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(applied).To(Equal(1))
+		Expect(written).To(Equal([]string{"patch cattle-config"}))
+
+		updated := &unstructured.Unstructured{}
+		updated.SetGroupVersionKind(configMap.GroupVersionKind())
+		Expect(remoteClient.Get(ctx, client.ObjectKeyFromObject(configMap), updated)).To(Succeed())
+		Expect(updated.Object["data"]).To(HaveKeyWithValue("url", "https://rancher.example.com"))
+	})
+
+	It("should patch an object with changed labels", func() {
+		existing := desired()
+		existing[2].SetLabels(map[string]string{"app": "other"})
+
+		remoteClient := remoteClientWith(existing...)
+
+		applied, err := applyObjectsIncrementally(ctx, remoteClient, objs, false)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(applied).To(Equal(1))
+		Expect(written).To(Equal([]string{"patch cattle-config"}))
+	})
+})
+
+const unorderedManifest = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: cattle-cluster-agent
+  namespace: cattle-system
+spec:
+  selector:
+    matchLabels:
+      app: cattle-cluster-agent
+  template:
+    metadata:
+      labels:
+        app: cattle-cluster-agent
+    spec:
+      serviceAccountName: cattle
+      containers:
+      - name: cluster-register
+        image: rancher/rancher-agent:v2.8.0
+---
+apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: cattle
+  namespace: cattle-system
+---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: cattle-system
+`
+
+var _ = Describe("ordered apply of import manifest", func() {
+	var (
+		written []string
+		missing map[string]string
+	)
+
+	// remoteClient fails the creation of namespaced objects whose namespace doesn't exist, like the API server, and of
+	// the objects listed in missing until the object they depend on exists.
+	remoteClient := func() client.Client {
+		return fake.NewClientBuilder().WithScheme(scheme.Scheme).WithInterceptorFuncs(interceptor.Funcs{
+			Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+				written = append(written, obj.GetName())
+
+				if ns := obj.GetNamespace(); ns != "" {
+					if err := c.Get(ctx, client.ObjectKey{Name: ns}, &corev1.Namespace{}); err != nil {
+						return err
+					}
+				}
+
+				if dependency, ok := missing[obj.GetName()]; ok {
+					key := client.ObjectKey{Namespace: obj.GetNamespace(), Name: dependency}
+					if err := c.Get(ctx, key, &corev1.ServiceAccount{}); err != nil {
+						return err
+					}
+				}
+
+				return c.Create(ctx, obj, opts...)
+			},
+		}).Build()
+	}
+
+	BeforeEach(func() {
+		written = []string{}
+		missing = map[string]string{}
+	})
+
+	It("should create the namespace before the objects it contains", func() {
+		cl := remoteClient()
+
+		Expect(createImportManifest(ctx, cl, strings.NewReader(unorderedManifest))).To(Succeed())
+		Expect(written).To(Equal([]string{"cattle-system", "cattle-cluster-agent", "cattle"}))
+
+		deployment := &unstructured.Unstructured{}
+		deployment.SetGroupVersionKind(schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"})
+		Expect(cl.Get(ctx, client.ObjectKey{Namespace: "cattle-system", Name: "cattle-cluster-agent"}, deployment)).To(Succeed())
+	})
+
+	It("should retry the objects not found once after the other objects", func() {
+		missing["cattle-cluster-agent"] = "cattle"
+
+		Expect(createImportManifest(ctx, remoteClient(), strings.NewReader(unorderedManifest))).To(Succeed())
+		Expect(written).To(Equal([]string{"cattle-system", "cattle-cluster-agent", "cattle", "cattle-cluster-agent"}))
+	})
+
+	It("should fail when the object is still not found on retry", func() {
+		missing["cattle-cluster-agent"] = "unknown"
+
+		err := createImportManifest(ctx, remoteClient(), strings.NewReader(unorderedManifest))
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+		Expect(written).To(Equal([]string{"cattle-system", "cattle-cluster-agent", "cattle", "cattle-cluster-agent"}))
+	})
+
+	It("should order and retry the objects of an incremental apply", func() {
+		missing["cattle-cluster-agent"] = "cattle"
+
+		objs, err := decodeManifest(strings.NewReader(unorderedManifest))
+		Expect(err).ToNot(HaveOccurred())
+
+		applied, err := applyObjectsIncrementally(ctx, remoteClient(), objs, false)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(applied).To(Equal(3))
+		Expect(written).To(Equal([]string{"cattle-system", "cattle-cluster-agent", "cattle", "cattle-cluster-agent"}))
+	})
+})
+
+var _ = Describe("server-side apply of import manifest", func() {
+	var (
+		objs      []*unstructured.Unstructured
+		configMap *unstructured.Unstructured
+		patches   []client.PatchOption
+	)
+
+	remoteClientWith := func(funcs interceptor.Funcs) client.Client {
+		if funcs.Patch == nil {
+			funcs.Patch = func(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+				Expect(patch.Type()).To(Equal(types.ApplyPatchType))
+				patches = append(patches, opts...)
+
+				return c.Patch(ctx, obj, patch, opts...)
+			}
+		}
+
+		return fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(configMap).WithInterceptorFuncs(funcs).Build()
+	}
+
+	remoteURL := func(remoteClient client.Client) interface{} {
+		existing := &unstructured.Unstructured{}
+		existing.SetGroupVersionKind(configMap.GroupVersionKind())
+		Expect(remoteClient.Get(ctx, client.ObjectKeyFromObject(configMap), existing)).To(Succeed())
+
+		return existing.Object["data"].(map[string]interface{})["url"]
+	}
+
+	BeforeEach(func() {
+		var err error
+
+		patches = nil
+		objs, err = decodeManifest(strings.NewReader(incrementalManifest))
+		Expect(err).ToNot(HaveOccurred())
+
+		configMap = objs[2].DeepCopy()
+		Expect(unstructured.SetNestedField(configMap.Object, "https://old.example.com", "data", "url")).To(Succeed())
+
+		objs = objs[2:]
+	})
+
+	It("should leave drifted objects untouched when only creating objects", func() {
+		remoteClient := remoteClientWith(interceptor.Funcs{})
+
+		Expect(createObjects(ctx, remoteClient, objs, false)).To(Succeed())
+		Expect(remoteURL(remoteClient)).To(Equal("https://old.example.com"))
+		Expect(patches).To(BeEmpty())
+	})
+
+	It("should update drifted objects with the turtles field manager", func() {
+		remoteClient := remoteClientWith(interceptor.Funcs{})
+
+		Expect(serverSideApplyObjects(ctx, remoteClient, objs, false)).To(Succeed())
+		Expect(remoteURL(remoteClient)).To(Equal("https://rancher.example.com"))
+		Expect(patches).To(ContainElements(client.FieldOwner(fieldManager), client.ForceOwnership))
+	})
+
+	It("should keep the existing object when an immutable field changed", func() {
+		remoteClient := remoteClientWith(interceptor.Funcs{
+			Patch: func(_ context.Context, _ client.WithWatch, obj client.Object, _ client.Patch, _ ...client.PatchOption) error {
+				return apierrors.NewInvalid(obj.GetObjectKind().GroupVersionKind().GroupKind(), obj.GetName(), field.ErrorList{
+					field.Invalid(field.NewPath("spec", "selector"), nil, "field is immutable"),
+				})
+			},
+		})
+
+		Expect(serverSideApplyObjects(ctx, remoteClient, objs, false)).To(Succeed())
+		Expect(remoteURL(remoteClient)).To(Equal("https://old.example.com"))
+	})
+
+	It("should fail on other invalid objects", func() {
+		remoteClient := remoteClientWith(interceptor.Funcs{
+			Patch: func(_ context.Context, _ client.WithWatch, obj client.Object, _ client.Patch, _ ...client.PatchOption) error {
+				return apierrors.NewInvalid(obj.GetObjectKind().GroupVersionKind().GroupKind(), obj.GetName(), field.ErrorList{
+					field.Required(field.NewPath("data"), "data is required"),
+				})
+			},
+		})
+
+		Expect(serverSideApplyObjects(ctx, remoteClient, objs, false)).To(MatchError(ContainSubstring("applying object")))
+	})
+
+	It("should report forbidden objects with the apply verb", func() {
+		remoteClient := remoteClientWith(interceptor.Funcs{
+			Patch: func(_ context.Context, _ client.WithWatch, obj client.Object, _ client.Patch, _ ...client.PatchOption) error {
+				return apierrors.NewForbidden(schema.GroupResource{Resource: "configmaps"}, obj.GetName(), errors.New("denied"))
+			},
+		})
+
+		forbidden, other := splitForbidden(serverSideApplyObjects(ctx, remoteClient, objs, true))
+		Expect(other).ToNot(HaveOccurred())
+		Expect(forbidden).To(HaveLen(1))
+		Expect(forbidden[0].Object()).To(Equal("apply v1 ConfigMap cattle-system/cattle-config"))
+	})
+})
+
+var _ = Describe("dry-run of import manifest", func() {
+	var (
+		r            *CAPIImportReconciler
+		remoteClient client.Client
+		createOpts   []client.CreateOption
+		capiCluster  *clusterv1.Cluster
+	)
+
+	persisted := func() []string {
+		names := []string{}
+
+		for _, list := range []client.ObjectList{&corev1.NamespaceList{}, &corev1.ServiceAccountList{}, &corev1.ConfigMapList{}} {
+			Expect(remoteClient.List(ctx, list)).To(Succeed())
+			Expect(meta.EachListItem(list, func(obj runtime.Object) error {
+				names = append(names, obj.(client.Object).GetName())
+				return nil
+			})).To(Succeed())
+		}
+
+		return names
+	}
+
+	BeforeEach(func() {
+		server := testutil.NewManifestServer(incrementalManifest)
+		DeferCleanup(server.Close)
+
+		createOpts = nil
+		remoteClient = fake.NewClientBuilder().WithScheme(scheme.Scheme).WithInterceptorFuncs(interceptor.Funcs{
+			Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+				createOpts = append(createOpts, opts...)
+				return c.Create(ctx, obj, opts...)
+			},
+		}).Build()
+
+		r = &CAPIImportReconciler{
+			RancherClient: testutil.NewRancherClientBuilder().WithObjects(
+				testutil.RegistrationToken("c-m-test", "test-ns", server.URL),
+			).Build(),
+			recorder: record.NewFakeRecorder(10),
+			remoteClientGetter: func(_ context.Context, _ string, _ client.Client, _ client.ObjectKey) (client.Client, error) {
+				return remoteClient, nil
+			},
+			ApplyOptions: ApplyOptions{
+				DryRun:             true,
+				AdditionalManifest: "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: extra\n  namespace: cattle-system\n",
+			},
+		}
+
+		capiCluster = &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", Namespace: "test-ns"}}
+	})
+
+	It("should create the objects with a dry-run without persisting them", func() {
+		objs, err := decodeManifest(strings.NewReader(incrementalManifest))
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(dryRunObjects(ctx, remoteClient, objs, false)).To(Succeed())
+		Expect(persisted()).To(BeEmpty())
+		Expect(createOpts).To(HaveLen(len(objs)))
+		Expect(createOpts).To(HaveEach(client.DryRunAll))
+	})
+
+	It("should report the import manifest as a dry-run", func() {
+		applied, err := r.applyImportManifest(ctx, capiCluster,
+			&provisioningv1.Cluster{Status: provisioningv1.ClusterStatus{ClusterName: "c-m-test"}})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(applied).To(BeTrue())
+
+		Expect(persisted()).To(BeEmpty())
+		Expect(createOpts).To(HaveLen(4))
+		Expect(conditions.GetReason(capiCluster, turtlesv1.ImportManifestAppliedCondition)).To(Equal(turtlesv1.ManifestDryRunReason))
+		Expect(capiCluster.GetAnnotations()).ToNot(HaveKey(turtlesannotations.ManifestHashAnnotation))
+	})
+
+	It("should take precedence over server-side apply", func() {
+		r.UseServerSideApply = true
+
+		_, err := r.applyImportManifest(ctx, capiCluster,
+			&provisioningv1.Cluster{Status: provisioningv1.ClusterStatus{ClusterName: "c-m-test"}})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(persisted()).To(BeEmpty())
+	})
+
+	It("should persist the objects once disabled", func() {
+		r.DryRun = false
+
+		_, err := r.applyImportManifest(ctx, capiCluster,
+			&provisioningv1.Cluster{Status: provisioningv1.ClusterStatus{ClusterName: "c-m-test"}})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(persisted()).To(ConsistOf("cattle-system", "cattle", "cattle-config", "extra"))
+		Expect(conditions.IsTrue(capiCluster, turtlesv1.ImportManifestAppliedCondition)).To(BeTrue())
+	})
+})
+
+var _ = Describe("concurrent apply of import manifest", func() {
+	const concurrency = 3
+
+	var (
+		mu       sync.Mutex
+		created  []string
+		inFlight atomic.Int32
+		release  chan struct{}
+	)
+
+	// manifest returns config maps with the given names, with a CRD and a namespace after the first one.
+	manifest := func(names ...string) []*unstructured.Unstructured {
+		objs := []*unstructured.Unstructured{}
+
+		for i, name := range names {
+			cm := &unstructured.Unstructured{}
+			cm.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("ConfigMap"))
+			cm.SetName(name)
+			cm.SetNamespace("cattle-system")
+			objs = append(objs, cm)
+
+			if i == 0 {
+				crd := &unstructured.Unstructured{}
+				crd.SetGroupVersionKind(schema.GroupVersionKind{
+					Group: "apiextensions.k8s.io", Version: "v1", Kind: "CustomResourceDefinition",
+				})
+				crd.SetName("clusters.management.cattle.io")
+
+				ns := &unstructured.Unstructured{}
+				ns.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("Namespace"))
+				ns.SetName("cattle-system")
+
+				objs = append(objs, crd, ns)
+			}
+		}
+
+		return objs
+	}
+
+	// remoteClient blocks the creation of config maps until released, and fails the ones named in failures.
+	remoteClient := func(failures map[string]error) client.Client {
+		return fake.NewClientBuilder().WithScheme(scheme.Scheme).WithInterceptorFuncs(interceptor.Funcs{
+			Create: func(_ context.Context, _ client.WithWatch, obj client.Object, _ ...client.CreateOption) error {
+				mu.Lock()
+				created = append(created, obj.GetName())
+				mu.Unlock()
+
+				if obj.GetObjectKind().GroupVersionKind().Kind != "ConfigMap" {
+					return nil
+				}
+
+				inFlight.Add(1)
+				defer inFlight.Add(-1)
+
+				<-release
+
+				return failures[obj.GetName()]
+			},
+		}).Build()
+	}
+
+	apply := func(c client.Client, objs []*unstructured.Unstructured, continueOnForbidden bool) chan error {
+		done := make(chan error, 1)
+
+		go func() {
+			done <- writeObjectsConcurrently(ctx, c, objs, continueOnForbidden, concurrency, createObject)
+		}()
+
+		return done
+	}
+
+	createdNames := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+
+		return append([]string{}, created...)
+	}
+
+	BeforeEach(func() {
+		created = nil
+		inFlight.Store(0)
+		release = make(chan struct{})
+	})
+
+	It("should write the namespaces and CRDs first, then bound the concurrent writes", func() {
+		done := apply(remoteClient(nil), manifest("a", "b", "c", "d", "e"), false)
+
+		Eventually(inFlight.Load).Should(BeEquivalentTo(concurrency))
+		Consistently(inFlight.Load, "50ms").Should(BeEquivalentTo(concurrency))
+		Expect(createdNames()[:2]).To(Equal([]string{"clusters.management.cattle.io", "cattle-system"}))
+
+		close(release)
+
+		Eventually(done).Should(Receive(BeNil()))
+		Expect(createdNames()).To(ConsistOf("clusters.management.cattle.io", "cattle-system", "a", "b", "c", "d", "e"))
+	})
+
+	It("should aggregate the errors of the writes in flight and stop writing", func() {
+		errA, errB, errC := errors.New("a failed"), errors.New("b failed"), errors.New("c failed")
+		done := apply(remoteClient(map[string]error{"a": errA, "b": errB, "c": errC}), manifest("a", "b", "c", "d"), false)
+
+		Eventually(inFlight.Load).Should(BeEquivalentTo(concurrency))
+		close(release)
+
+		var err error
+		Eventually(done).Should(Receive(&err))
+		Expect(err).To(MatchError(errA))
+		Expect(err).To(MatchError(errB))
+		Expect(err).To(MatchError(errC))
+		Expect(createdNames()).ToNot(ContainElement("d"))
+	})
+
+	It("should keep writing past forbidden objects when continuing on forbidden", func() {
+		forbidden := apierrors.NewForbidden(schema.GroupResource{Resource: "configmaps"}, "b", errors.New("denied"))
+		close(release)
+
+		var err error
+		Eventually(apply(remoteClient(map[string]error{"b": forbidden}), manifest("a", "b", "c", "d"), true)).Should(Receive(&err))
+
+		forbiddenErrs, other := splitForbidden(err)
+		Expect(other).ToNot(HaveOccurred())
+		Expect(forbiddenErrs).To(HaveLen(1))
+		Expect(createdNames()).To(ContainElements("a", "c", "d"))
+	})
+
+	It("should be used by the reconciler when the concurrency is set", func() {
+		c := fake.NewClientBuilder().WithScheme(scheme.Scheme).Build()
+		r := &CAPIImportReconciler{ApplyOptions: ApplyOptions{ApplyConcurrency: concurrency, IncrementalApply: true}}
+
+		objs, err := decodeManifest(strings.NewReader(incrementalManifest))
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(r.applyObjects(ctx, c, objs)).To(Succeed())
+		Expect(c.Get(ctx, client.ObjectKey{Namespace: "cattle-system", Name: "cattle-config"}, &corev1.ConfigMap{})).To(Succeed())
+		Expect(c.Get(ctx, client.ObjectKey{Namespace: "cattle-system", Name: "cattle"}, &corev1.ServiceAccount{})).To(Succeed())
+	})
+})
+
+var _ = Describe("CRD establishment before custom resources", func() {
+	const crdName = "widgets.example.cattle.io"
+
+	var (
+		r       *CAPIImportReconciler
+		objs    []*unstructured.Unstructured
+		written []string
+		crdGets int
+	)
+
+	// remoteClient reports the CRD established from the given number of gets on. Zero never establishes it.
+	remoteClient := func(establishedAfter int) client.Client {
+		return fake.NewClientBuilder().WithScheme(scheme.Scheme).WithInterceptorFuncs(interceptor.Funcs{
+			Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+				written = append(written, obj.GetName())
+
+				if obj.GetObjectKind().GroupVersionKind().Group != "" {
+					return nil
+				}
+
+				return c.Create(ctx, obj, opts...)
+			},
+			Get: func(ctx context.Context, c client.WithWatch, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+				if obj.GetObjectKind().GroupVersionKind() != crdGVK {
+					return c.Get(ctx, key, obj, opts...)
+				}
+
+				crdGets++
+				if crdGets == 1 {
+					return apierrors.NewNotFound(schema.GroupResource{Group: crdGVK.Group, Resource: "customresourcedefinitions"}, key.Name)
+				}
+
+				status := "False"
+				if establishedAfter > 0 && crdGets >= establishedAfter {
+					status = "True"
+					written = append(written, "established")
+				}
+
+				u := obj.(*unstructured.Unstructured)
+				u.SetName(key.Name)
+
+				return unstructured.SetNestedSlice(u.Object, []interface{}{
+					map[string]interface{}{"type": "Established", "status": status},
+				}, "status", "conditions")
+			},
+		}).Build()
+	}
+
+	BeforeEach(func() {
+		var err error
+
+		written = []string{}
+		crdGets = 0
+
+		objs, err = decodeManifest(strings.NewReader(`apiVersion: v1
+kind: Namespace
+metadata:
+  name: cattle-system
+---
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: widgets.example.cattle.io
+spec:
+  group: example.cattle.io
+  names:
+    kind: Widget
+---
+apiVersion: example.cattle.io/v1
+kind: Widget
+metadata:
+  name: widget
+  namespace: cattle-system
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cattle-config
+  namespace: cattle-system
+`))
+		Expect(err).ToNot(HaveOccurred())
+
+		r = &CAPIImportReconciler{crdPollInterval: time.Millisecond}
+	})
+
+	It("should apply the custom resources once their CRD is established", func() {
+		Expect(r.applyObjects(ctx, remoteClient(3), objs)).To(Succeed())
+		Expect(written).To(Equal([]string{"cattle-system", crdName, "cattle-config", "established", "widget"}))
+		Expect(crdGets).To(Equal(3))
+	})
+
+	It("should give up on a CRD which is not established in time", func() {
+		r.CRDEstablishedTimeout = 20 * time.Millisecond
+
+		err := r.applyObjects(ctx, remoteClient(0), objs)
+		Expect(err).To(MatchError(ContainSubstring("waiting for CRD " + crdName + " to be established")))
+		Expect(written).ToNot(ContainElement("widget"))
+	})
+
+	It("should not wait without custom resources of the CRDs", func() {
+		Expect(r.applyObjects(ctx, remoteClient(0), objs[:2])).To(Succeed())
+		Expect(crdGets).To(BeZero())
+	})
+
+	It("should not wait on a dry-run", func() {
+		r.DryRun = true
+
+		Expect(r.applyObjects(ctx, remoteClient(0), objs)).To(Succeed())
+		Expect(crdGets).To(BeZero())
+		Expect(written).To(ContainElement("widget"))
+	})
+})
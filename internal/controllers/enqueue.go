@@ -0,0 +1,90 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"k8s.io/client-go/util/workqueue"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+)
+
+// enqueueRequestsFromMapFuncStaggered behaves like handler.EnqueueRequestsFromMapFunc, but spreads the mapped requests
+// over the window instead of adding them all at once, so a single event mapping to many clusters doesn't cause a burst
+// of simultaneous imports. A zero window enqueues the requests immediately.
+func enqueueRequestsFromMapFuncStaggered(fn handler.MapFunc, window time.Duration) handler.EventHandler {
+	if window <= 0 {
+		return handler.EnqueueRequestsFromMapFunc(fn)
+	}
+
+	enqueue := func(ctx context.Context, q workqueue.RateLimitingInterface, objs ...client.Object) {
+		seen := map[ctrl.Request]struct{}{}
+		reqs := []ctrl.Request{}
+
+		for _, obj := range objs {
+			for _, req := range fn(ctx, obj) {
+				if _, ok := seen[req]; !ok {
+					seen[req] = struct{}{}
+					reqs = append(reqs, req)
+				}
+			}
+		}
+
+		for i, delay := range staggerDelays(len(reqs), window) {
+			q.AddAfter(reqs[i], delay)
+		}
+	}
+
+	return handler.Funcs{
+		CreateFunc: func(ctx context.Context, e event.CreateEvent, q workqueue.RateLimitingInterface) {
+			enqueue(ctx, q, e.Object)
+		},
+		UpdateFunc: func(ctx context.Context, e event.UpdateEvent, q workqueue.RateLimitingInterface) {
+			enqueue(ctx, q, e.ObjectOld, e.ObjectNew)
+		},
+		DeleteFunc: func(ctx context.Context, e event.DeleteEvent, q workqueue.RateLimitingInterface) {
+			enqueue(ctx, q, e.Object)
+		},
+		GenericFunc: func(ctx context.Context, e event.GenericEvent, q workqueue.RateLimitingInterface) {
+			enqueue(ctx, q, e.Object)
+		},
+	}
+}
+
+// staggerDelays splits the window in n equal slots and returns a random delay within each slot.
+func staggerDelays(n int, window time.Duration) []time.Duration {
+	delays := make([]time.Duration, n)
+	if n == 0 {
+		return delays
+	}
+
+	slot := window / time.Duration(n)
+
+	for i := range delays {
+		delays[i] = slot * time.Duration(i)
+		if slot > 0 {
+			delays[i] += time.Duration(rand.Int63n(int64(slot))) //nolint:gosec
+		}
+	}
+
+	return delays
+}
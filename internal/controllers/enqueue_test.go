@@ -0,0 +1,103 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/util/workqueue"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// delayRecordingQueue records the delay every item is added with.
+type delayRecordingQueue struct {
+	workqueue.RateLimitingInterface
+
+	delays map[interface{}]time.Duration
+}
+
+func (q *delayRecordingQueue) Add(item interface{}) {
+	q.delays[item] = 0
+}
+
+func (q *delayRecordingQueue) AddAfter(item interface{}, duration time.Duration) {
+	q.delays[item] = duration
+}
+
+var _ = Describe("staggered namespace enqueue", func() {
+	const clusters = 10
+
+	var (
+		cl    client.Client
+		ns    *corev1.Namespace
+		queue *delayRecordingQueue
+	)
+
+	BeforeEach(func() {
+		ns = &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+			Name:   "test-ns",
+			Labels: map[string]string{importLabelName: "true"},
+		}}
+
+		objs := []client.Object{ns}
+		for i := 0; i < clusters; i++ {
+			objs = append(objs, &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("cluster-%d", i), Namespace: ns.Name}})
+		}
+
+		cl = fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(objs...).Build()
+		queue = &delayRecordingQueue{delays: map[interface{}]time.Duration{}}
+	})
+
+	It("should spread the requests over the window", func() {
+		window := 10 * time.Second
+		eventHandler := enqueueRequestsFromMapFuncStaggered(namespaceToCapiClusters(ctx, predicate.Funcs{}, cl, importLabelName), window)
+
+		eventHandler.Update(ctx, event.UpdateEvent{ObjectOld: ns, ObjectNew: ns}, queue)
+		Expect(queue.delays).To(HaveLen(clusters))
+
+		slots := map[time.Duration]int{}
+		for _, delay := range queue.delays {
+			Expect(delay).To(BeNumerically(">=", 0))
+			Expect(delay).To(BeNumerically("<", window))
+			slots[delay/(window/clusters)]++
+		}
+
+		// every cluster lands in its own slot of the window
+		Expect(slots).To(HaveLen(clusters))
+	})
+
+	It("should enqueue the requests immediately without a window", func() {
+		eventHandler := enqueueRequestsFromMapFuncStaggered(namespaceToCapiClusters(ctx, predicate.Funcs{}, cl, importLabelName), 0)
+
+		eventHandler.Update(ctx, event.UpdateEvent{ObjectOld: ns, ObjectNew: ns}, queue)
+		Expect(queue.delays).To(HaveLen(clusters))
+
+		for _, delay := range queue.delays {
+			Expect(delay).To(BeZero())
+		}
+	})
+})
@@ -20,15 +20,25 @@ import (
 	"bufio"
 	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/wait"
 	yamlDecoder "k8s.io/apimachinery/pkg/util/yaml"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -42,22 +52,100 @@ import (
 
 	managementv3 "github.com/rancher/turtles/internal/rancher/management/v3"
 	"github.com/rancher/turtles/util"
+	turtlesannotations "github.com/rancher/turtles/util/annotations"
 )
 
 const (
-	importLabelName           = "cluster-api.cattle.io/rancher-auto-import"
-	ownedLabelName            = "cluster-api.cattle.io/owned"
+	// DefaultImportLabel is the label marking the CAPI clusters, or their namespaces, to import into Rancher.
+	DefaultImportLabel = "cluster-api.cattle.io/rancher-auto-import"
+	// DefaultOwnedLabel is the label marking the Rancher objects created by the controller.
+	DefaultOwnedLabel = "cluster-api.cattle.io/owned"
+)
+
+const (
+	importLabelName           = DefaultImportLabel
+	ownedLabelName            = DefaultOwnedLabel
 	capiClusterOwner          = "cluster-api.cattle.io/capi-cluster-owner"
 	capiClusterOwnerNamespace = "cluster-api.cattle.io/capi-cluster-owner-ns"
+	capiClusterOwnerUID       = "cluster-api.cattle.io/capi-cluster-owner-uid"
+	turtlesAppliedLabelName   = "cluster-api.cattle.io/turtles-applied"
+
+	deletionProtectionFinalizer = "cluster-api.cattle.io/deletion-protection"
 
 	defaultRequeueDuration = 1 * time.Minute
+	minRequeueDuration     = 5 * time.Second
+	objectApplyTimeout     = 30 * time.Second
+
+	defaultManifestDownloadTimeout = 30 * time.Second
+	manifestIdleConnTimeout        = 90 * time.Second
+
+	defaultCRDEstablishedTimeout = 1 * time.Minute
+	crdEstablishedPollInterval   = 1 * time.Second
+	manifestApplyPasses          = 2
+
+	shortHashLength = 12
+
+	// unstampedVersion is the version reported by k8s.io/component-base/version for builds without the version
+	// ldflags.
+	unstampedVersion = "v0.0.0-master+$Format:%H$"
 )
 
+// errManifestVerification is returned when the downloaded registration manifest doesn't match its expected checksum.
+var errManifestVerification = errors.New("registration manifest verification failed")
+
+// errManifestURLHostNotAllowed is returned when the manifest URL host annotation of a cluster names a host the
+// operator didn't allow.
+var errManifestURLHostNotAllowed = errors.New("manifest URL host not allowed")
+
+// getClusterRegistrationManifest downloads the registration manifest of the cluster. When expectedChecksum is set,
+// the manifest is verified against it and errManifestVerification is returned on a mismatch.
 func getClusterRegistrationManifest(ctx context.Context, clusterName, namespace string, cl client.Client,
-	insecureSkipVerify bool,
+	httpClient *http.Client, manifestHost, expectedChecksum string, retry manifestRetry, reissue tokenReissue,
 ) (string, error) {
-	log := log.FromContext(ctx)
+	manifestURL, err := getClusterRegistrationManifestURL(ctx, clusterName, namespace, cl, manifestHost, reissue)
+	if err != nil || manifestURL == "" {
+		return "", err
+	}
+
+	return fetchClusterRegistrationManifest(ctx, manifestURL, httpClient, expectedChecksum, retry)
+}
+
+// getClusterRegistrationManifestURL returns the registration manifest URL of the cluster, creating its registration
+// token when missing. The URL is empty until Rancher sets it on the token. An expired token, or a token Rancher didn't
+// set the URL of within the grace period, is re-issued and the URL is empty until Rancher sets it on the new token.
+func getClusterRegistrationManifestURL(ctx context.Context, clusterName, namespace string, cl client.Client,
+	manifestHost string, reissue tokenReissue,
+) (string, error) {
+	token, err := ensureRegistrationToken(ctx, cl, clusterName, namespace)
+	if err != nil {
+		return "", err
+	}
+
+	if reason := reissue.needed(token); reason != "" {
+		log.FromContext(ctx).Info("Re-issuing registration token", "token", client.ObjectKeyFromObject(token), "reason", reason)
+
+		if err := cl.Delete(ctx, token); client.IgnoreNotFound(err) != nil {
+			return "", fmt.Errorf("deleting registration token for cluster %s: %w", clusterName, err)
+		}
+
+		if _, err := ensureRegistrationToken(ctx, cl, clusterName, namespace); err != nil {
+			return "", err
+		}
+
+		return "", nil
+	}
 
+	if token.Status.ManifestURL == "" {
+		return "", nil
+	}
+
+	return rewriteManifestURL(token.Status.ManifestURL, manifestHost)
+}
+
+// ensureRegistrationToken returns the registration token of the cluster, creating it when missing. A token created
+// concurrently, e.g. by Rancher or by another reconcile, is adopted instead of failing the creation.
+func ensureRegistrationToken(ctx context.Context, cl client.Client, clusterName, namespace string,
+) (*managementv3.ClusterRegistrationToken, error) {
 	token := &managementv3.ClusterRegistrationToken{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      clusterName,
@@ -67,30 +155,55 @@ func getClusterRegistrationManifest(ctx context.Context, clusterName, namespace
 			ClusterName: clusterName,
 		},
 	}
+
 	err := cl.Get(ctx, client.ObjectKeyFromObject(token), token)
+	if err == nil {
+		return token, nil
+	}
 
-	if client.IgnoreNotFound(err) != nil {
-		return "", fmt.Errorf("error getting registration token for cluster %s: %w", clusterName, err)
-	} else if err != nil {
-		if err := cl.Create(ctx, token); err != nil {
-			return "", fmt.Errorf("failed to create cluster registration token for cluster %s: %w", clusterName, err)
-		}
+	if !apierrors.IsNotFound(err) {
+		return nil, fmt.Errorf("error getting registration token for cluster %s: %w", clusterName, err)
 	}
 
-	if token.Status.ManifestURL == "" {
-		return "", nil
+	err = cl.Create(ctx, token)
+	if err == nil {
+		log.FromContext(ctx).V(4).Info("created cluster registration token", "token", client.ObjectKeyFromObject(token))
+		return token, nil
+	}
+
+	if !apierrors.IsAlreadyExists(err) {
+		return nil, fmt.Errorf("failed to create cluster registration token for cluster %s: %w", clusterName, err)
+	}
+
+	if err := cl.Get(ctx, client.ObjectKeyFromObject(token), token); err != nil {
+		return nil, fmt.Errorf("error getting registration token created concurrently for cluster %s: %w", clusterName, err)
 	}
 
-	manifestData, err := downloadManifest(token.Status.ManifestURL, insecureSkipVerify)
+	return token, nil
+}
+
+// fetchClusterRegistrationManifest downloads the registration manifest from its URL, retrying transient failures,
+// and verifies it against the expected checksum, if any.
+func fetchClusterRegistrationManifest(ctx context.Context, manifestURL string, httpClient *http.Client,
+	expectedChecksum string, retry manifestRetry,
+) (string, error) {
+	log := log.FromContext(ctx)
+
+	manifestData, err := downloadManifest(ctx, httpClient, manifestURL, retry)
 	if err != nil {
-		log.Error(err, "failed downloading import manifest")
+		// the download errors carry the manifest URL, which embeds the registration token
+		log.Error(errors.New(redactSecrets(err.Error())), "failed downloading import manifest")
+		return "", err
+	}
+
+	if err := verifyManifestChecksum(manifestData, expectedChecksum); err != nil {
 		return "", err
 	}
 
 	return manifestData, nil
 }
 
-func namespaceToCapiClusters(ctx context.Context, clusterPredicate predicate.Funcs, cl client.Client) handler.MapFunc {
+func namespaceToCapiClusters(ctx context.Context, clusterPredicate predicate.Funcs, cl client.Client, label string) handler.MapFunc {
 	log := log.FromContext(ctx)
 
 	return func(_ context.Context, o client.Object) []ctrl.Request {
@@ -100,13 +213,13 @@ func namespaceToCapiClusters(ctx context.Context, clusterPredicate predicate.Fun
 			return nil
 		}
 
-		if _, autoImport := util.ShouldImport(ns, importLabelName); !autoImport {
+		if _, autoImport := util.ShouldImport(ns, label); !autoImport {
 			log.V(2).Info("Namespace doesn't have import annotation label with a true value, skipping")
 			return nil
 		}
 
 		capiClusters := &clusterv1.ClusterList{}
-		if err := cl.List(ctx, capiClusters, client.InNamespace(o.GetNamespace())); err != nil {
+		if err := cl.List(ctx, capiClusters, client.InNamespace(ns.Name)); err != nil {
 			log.Error(err, "getting capi cluster")
 			return nil
 		}
@@ -136,29 +249,307 @@ func namespaceToCapiClusters(ctx context.Context, clusterPredicate predicate.Fun
 	}
 }
 
-func downloadManifest(url string, insecureSkipVerify bool) (string, error) {
-	client := &http.Client{Transport: &http.Transport{
-		TLSClientConfig: &tls.Config{
-			InsecureSkipVerify: insecureSkipVerify, //nolint:gosec
+// verifyManifestChecksum verifies the manifest against the expected checksum, formatted as sha256:<hex> or as a bare
+// hex encoded sha256 hash. Nothing is verified when the expected checksum is empty.
+func verifyManifestChecksum(manifest, expected string) error {
+	if expected == "" {
+		return nil
+	}
+
+	algorithm, sum, found := strings.Cut(expected, ":")
+	if !found {
+		algorithm, sum = "sha256", expected
+	}
+
+	if algorithm != "sha256" {
+		return fmt.Errorf("%w: unsupported checksum algorithm %q", errManifestVerification, algorithm)
+	}
+
+	if actual := manifestHash(manifest); !strings.EqualFold(sum, actual) {
+		return fmt.Errorf("%w: expected checksum sha256:%s, got sha256:%s", errManifestVerification, sum, actual)
+	}
+
+	return nil
+}
+
+// manifestURLHost returns the host the registration manifest should be downloaded from for the cluster. The
+// per-cluster annotation takes precedence over the default host, but only when it names the default host or one of the
+// allowed hosts: the manifest URL carries the registration token and the manifest is applied as is, so whoever can
+// annotate a CAPI cluster must not be able to redirect the download anywhere.
+func manifestURLHost(capiCluster *clusterv1.Cluster, defaultHost string, allowedHosts []string) (string, error) {
+	host := capiCluster.GetAnnotations()[turtlesannotations.ManifestURLHostAnnotation]
+	if host == "" || host == defaultHost {
+		return defaultHost, nil
+	}
+
+	if !slices.Contains(allowedHosts, host) {
+		return "", fmt.Errorf("%w: %q is not in the allowed manifest URL hosts", errManifestURLHostNotAllowed, host)
+	}
+
+	return host, nil
+}
+
+// rewriteManifestURL replaces the host of the manifest URL, preserving the scheme, path and query.
+// The URL is returned unchanged when host is empty.
+func rewriteManifestURL(manifestURL, host string) (string, error) {
+	if host == "" {
+		return manifestURL, nil
+	}
+
+	u, err := url.Parse(manifestURL)
+	if err != nil {
+		return "", fmt.Errorf("parsing manifest URL: %w", err)
+	}
+
+	u.Host = host
+
+	return u.String(), nil
+}
+
+// manifestRetry configures the retries of the registration manifest download on transient failures.
+type manifestRetry struct {
+	// Attempts is the maximum number of download attempts. The manifest is downloaded once when it is not positive.
+	Attempts int
+	// Interval is the wait before the first retry, doubled for each following retry with some jitter.
+	Interval time.Duration
+}
+
+func (r manifestRetry) backoff() wait.Backoff {
+	return wait.Backoff{
+		Steps:    max(r.Attempts, 1),
+		Duration: r.Interval,
+		Factor:   2,
+		Jitter:   0.5,
+	}
+}
+
+// tokenReissue configures the re-issue of the registration tokens which expired, or which Rancher didn't set the
+// manifest URL of. The zero value never re-issues tokens.
+type tokenReissue struct {
+	// GracePeriod is the time Rancher has to set the manifest URL of a new token. Zero disables the re-issue of tokens
+	// without manifest URL.
+	GracePeriod time.Duration
+	// MinInterval is the minimum age of a token before it is re-issued, so that tokens are not re-issued in a loop.
+	MinInterval time.Duration
+	// Now is the current time.
+	Now time.Time
+}
+
+// needed returns why the token must be re-issued, or an empty string when it is still valid. Tokens younger than the
+// minimum interval are never re-issued.
+func (r tokenReissue) needed(token *managementv3.ClusterRegistrationToken) string {
+	if r.Now.IsZero() || token.CreationTimestamp.IsZero() {
+		return ""
+	}
+
+	age := r.Now.Sub(token.CreationTimestamp.Time)
+	if age < r.MinInterval {
+		return ""
+	}
+
+	if token.Status.ExpiresAt != nil && !r.Now.Before(token.Status.ExpiresAt.Time) {
+		return fmt.Sprintf("token expired at %s", token.Status.ExpiresAt.UTC().Format(time.RFC3339))
+	}
+
+	if r.GracePeriod > 0 && token.Status.ManifestURL == "" && age >= r.GracePeriod {
+		return fmt.Sprintf("manifest URL not set after %s", age.Round(time.Second))
+	}
+
+	return ""
+}
+
+// manifestTLS configures the verification of the certificate of the manifest server.
+type manifestTLS struct {
+	// InsecureSkipVerify disables the certificate verification. It is ignored when CABundle is set.
+	InsecureSkipVerify bool
+	// CABundle is the PEM encoded bundle of the CAs the certificate is verified against, instead of the system roots.
+	CABundle []byte
+}
+
+// config returns the TLS client configuration. A CA bundle takes precedence over InsecureSkipVerify.
+func (t manifestTLS) config() (*tls.Config, error) {
+	if len(t.CABundle) == 0 {
+		return &tls.Config{
+			InsecureSkipVerify: t.InsecureSkipVerify, //nolint:gosec
+		}, nil
+	}
+
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(t.CABundle) {
+		return nil, errors.New("no valid PEM certificate found in the CA bundle")
+	}
+
+	return &tls.Config{
+		RootCAs:    roots,
+		MinVersion: tls.VersionTLS12,
+	}, nil
+}
+
+// manifestHTTPClient is the HTTP client registration manifests are downloaded with. It is built once on first use, so
+// that the connections to the manifest server are pooled across reconciles.
+type manifestHTTPClient struct {
+	once   sync.Once
+	client *http.Client
+	err    error
+}
+
+// get returns the HTTP client, building it from the TLS settings and the timeout on the first call. A zero timeout
+// defaults to defaultManifestDownloadTimeout.
+func (c *manifestHTTPClient) get(tlsConfig manifestTLS, timeout time.Duration) (*http.Client, error) {
+	c.once.Do(func() {
+		c.client, c.err = newManifestHTTPClient(tlsConfig, timeout)
+	})
+
+	return c.client, c.err
+}
+
+// newManifestHTTPClient returns an HTTP client verifying the manifest server with the TLS settings, whose requests
+// fail after the timeout instead of blocking the reconcile on a hung server.
+func newManifestHTTPClient(tlsConfig manifestTLS, timeout time.Duration) (*http.Client, error) {
+	clientTLS, err := tlsConfig.config()
+	if err != nil {
+		return nil, fmt.Errorf("configuring manifest download TLS: %w", err)
+	}
+
+	if timeout <= 0 {
+		timeout = defaultManifestDownloadTimeout
+	}
+
+	return &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: clientTLS,
+			IdleConnTimeout: manifestIdleConnTimeout,
 		},
-	}}
+		Timeout: timeout,
+	}, nil
+}
+
+// manifestStatusError is returned when the manifest server answers with an unsuccessful status.
+type manifestStatusError struct {
+	StatusCode int
+}
+
+func (e *manifestStatusError) Error() string {
+	return fmt.Sprintf("downloading manifest: unexpected status %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// retryableDownloadError returns true for the manifest download failures which can be transient: server errors, rate
+// limiting, and network failures such as a refused or reset connection. Everything else, e.g. client errors, malformed
+// URLs or TLS verification failures, won't get better with a retry and is returned immediately.
+func retryableDownloadError(err error) bool {
+	var statusErr *manifestStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= http.StatusInternalServerError || statusErr.StatusCode == http.StatusTooManyRequests
+	}
+
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var (
+		verificationErr *tls.CertificateVerificationError
+		recordHeaderErr tls.RecordHeaderError
+		opErr           *net.OpError
+	)
+
+	// a TLS alert sent by the server, e.g. rejecting the client certificate, is a network error too
+	if errors.As(err, &verificationErr) || errors.As(err, &recordHeaderErr) ||
+		(errors.As(err, &opErr) && opErr.Op == "remote error") {
+		return false
+	}
+
+	// the HTTP client wraps every failure in a url.Error, which is a net.Error itself: only the error it wraps tells
+	// whether the request failed on the network or before being sent, e.g. with an unsupported scheme
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		err = urlErr.Err
+	}
+
+	if errors.Is(err, syscall.ECONNRESET) {
+		return true
+	}
+
+	var netErr net.Error
+
+	return errors.As(err, &netErr)
+}
+
+// downloadManifest downloads the manifest, retrying server errors and connection failures with exponential backoff.
+func downloadManifest(ctx context.Context, client *http.Client, url string, retry manifestRetry) (string, error) {
+	log := log.FromContext(ctx)
+
+	var (
+		manifest string
+		lastErr  error
+		attempt  int
+	)
+
+	err := wait.ExponentialBackoffWithContext(ctx, retry.backoff(), func(ctx context.Context) (bool, error) {
+		attempt++
+
+		manifest, lastErr = downloadManifestOnce(ctx, client, url)
+		if lastErr == nil {
+			return true, nil
+		}
+
+		if !retryableDownloadError(lastErr) {
+			return false, lastErr
+		}
 
-	resp, err := client.Get(url) //nolint:gosec,noctx
+		if attempt < retry.Attempts {
+			log.V(2).Info("Retrying manifest download", "attempt", attempt, "error", redactSecrets(lastErr.Error()))
+		}
+
+		return false, nil
+	})
+	if wait.Interrupted(err) && lastErr != nil {
+		return "", lastErr
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	return manifest, nil
+}
+
+func downloadManifestOnce(ctx context.Context, client *http.Client, url string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", fmt.Errorf("creating manifest request: %w", err)
+	}
+
+	resp, err := client.Do(req) //nolint:gosec
 	if err != nil {
 		return "", fmt.Errorf("downloading manifest: %w", err)
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return "", &manifestStatusError{StatusCode: resp.StatusCode}
+	}
+
 	data, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return "", fmt.Errorf("reading manifest: %w", err)
 	}
 
-	return string(data), err
+	return string(data), nil
 }
 
 func createImportManifest(ctx context.Context, remoteClient client.Client, in io.Reader) error {
+	objs, err := decodeManifest(in)
+	if err != nil {
+		return err
+	}
+
+	return createObjects(ctx, remoteClient, objs, false)
+}
+
+// decodeManifest decodes all the objects of a multi-document manifest, sanitizing them for creation.
+func decodeManifest(in io.Reader) ([]*unstructured.Unstructured, error) {
 	reader := yamlDecoder.NewYAMLReader(bufio.NewReaderSize(in, 4096))
+	objs := []*unstructured.Unstructured{}
 
 	for {
 		raw, err := reader.Read()
@@ -167,42 +558,152 @@ func createImportManifest(ctx context.Context, remoteClient client.Client, in io
 		}
 
 		if err != nil {
-			return err
+			return nil, err
 		}
 
-		if err := createRawManifest(ctx, remoteClient, raw); err != nil {
-			return err
+		items, err := utilyaml.ToUnstructured(raw)
+		if err != nil {
+			return nil, fmt.Errorf("error unmarshalling bytes or empty object passed: %w", err)
+		}
+
+		for _, obj := range items {
+			obj := obj.DeepCopy()
+			sanitizeObject(obj)
+			objs = append(objs, obj)
 		}
 	}
 
-	return nil
+	return objs, nil
 }
 
-func createRawManifest(ctx context.Context, remoteClient client.Client, bytes []byte) error {
-	items, err := utilyaml.ToUnstructured(bytes)
-	if err != nil {
-		return fmt.Errorf("error unmarshalling bytes or empty object passed: %w", err)
-	}
+// createObjects creates the decoded manifest objects in the remote cluster. When continueOnForbidden is set, the
+// objects the remote client is not allowed to create are skipped and reported in the returned error.
+func createObjects(ctx context.Context, remoteClient client.Client, objs []*unstructured.Unstructured,
+	continueOnForbidden bool,
+) error {
+	return writeObjects(ctx, remoteClient, objs, continueOnForbidden, createObject)
+}
 
-	for _, obj := range items {
-		if err := createObject(ctx, remoteClient, obj.DeepCopy()); err != nil {
-			return err
+// objectWriter writes a single manifest object to the remote cluster.
+type objectWriter func(ctx context.Context, c client.Client, obj client.Object) error
+
+// writeObjects writes the manifest objects with the given writer, each within its own apply context. The namespaces
+// and CRDs are written first, and the objects failing with a not found error are retried once after the first pass,
+// as they may depend on objects written later.
+func writeObjects(ctx context.Context, remoteClient client.Client, objs []*unstructured.Unstructured,
+	continueOnForbidden bool, write objectWriter,
+) error {
+	forbidden := []error{}
+	pending := orderObjects(objs)
+
+	for pass := 1; pass <= manifestApplyPasses && len(pending) > 0; pass++ {
+		notFound := []*unstructured.Unstructured{}
+
+		for _, obj := range pending {
+			if err := checkApplyAborted(ctx, obj); err != nil {
+				return errors.Join(append(forbidden, err)...)
+			}
+
+			applyCtx, cancel := objectApplyContext(ctx)
+			err := write(applyCtx, remoteClient, obj)
+
+			cancel()
+
+			if pass < manifestApplyPasses && apierrors.IsNotFound(err) {
+				logNotFoundRetry(ctx, obj, err)
+				notFound = append(notFound, obj)
+
+				continue
+			}
+
+			if continueOnForbidden && isObjectForbidden(err) {
+				forbidden = append(forbidden, err)
+				continue
+			}
+
+			if err != nil {
+				return errors.Join(append(forbidden, err)...)
+			}
 		}
+
+		pending = notFound
+	}
+
+	return errors.Join(forbidden...)
+}
+
+// logNotFoundRetry logs that the object is retried after the other manifest objects.
+func logNotFoundRetry(ctx context.Context, obj client.Object, err error) {
+	log.FromContext(ctx).V(2).Info("object depends on a missing object, retrying after the other objects",
+		"gvk", obj.GetObjectKind().GroupVersionKind(), "name", obj.GetName(), "namespace", obj.GetNamespace(),
+		"error", err.Error())
+}
+
+// checkApplyAborted returns an error if the context was cancelled, e.g. on controller shutdown or leader loss,
+// so that the manifest apply stops cleanly before writing the next object.
+func checkApplyAborted(ctx context.Context, obj client.Object) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("aborting manifest apply before %s %s: %w",
+			obj.GetObjectKind().GroupVersionKind().Kind, obj.GetName(), err)
 	}
 
 	return nil
 }
 
+// objectApplyTimeoutKey is the context key of the time an in-flight manifest object write is given to finish.
+type objectApplyTimeoutKey struct{}
+
+// withObjectApplyTimeout returns a context carrying the time objectApplyContext gives an in-flight write to finish.
+// The default objectApplyTimeout is kept when timeout is not positive.
+func withObjectApplyTimeout(ctx context.Context, timeout time.Duration) context.Context {
+	if timeout <= 0 {
+		return ctx
+	}
+
+	return context.WithValue(ctx, objectApplyTimeoutKey{}, timeout)
+}
+
+// objectApplyContext returns a context for writing a single manifest object which is not cancelled with the parent
+// context, giving an in-flight write up to the timeout set with withObjectApplyTimeout, or objectApplyTimeout, to
+// finish during shutdown.
+func objectApplyContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	timeout := objectApplyTimeout
+	if t, ok := ctx.Value(objectApplyTimeoutKey{}).(time.Duration); ok {
+		timeout = t
+	}
+
+	return context.WithTimeout(context.WithoutCancel(ctx), timeout)
+}
+
+// sanitizeObject clears server-populated metadata fields which some manifest versions carry and which would
+// otherwise cause create failures or apply conflicts on the downstream cluster.
+func sanitizeObject(obj *unstructured.Unstructured) {
+	obj.SetResourceVersion("")
+	obj.SetManagedFields(nil)
+	obj.SetUID("")
+	obj.SetGeneration(0)
+	obj.SetSelfLink("")
+	unstructured.RemoveNestedField(obj.Object, "metadata", "creationTimestamp")
+}
+
+// createObject creates the object in the remote cluster. Only the kind and the name of the object are logged, as the
+// manifest objects carry the registration token.
 func createObject(ctx context.Context, c client.Client, obj client.Object) error {
 	log := log.FromContext(ctx)
 	gvk := obj.GetObjectKind().GroupVersionKind()
 
+	log.V(5).Info("creating object in remote cluster", "gvk", gvk, "name", obj.GetName(), "namespace", obj.GetNamespace())
+
 	err := c.Create(ctx, obj)
 	if apierrors.IsAlreadyExists(err) {
 		log.V(4).Info("object already exists in remote cluster", "gvk", gvk, "name", obj.GetName(), "namespace", obj.GetNamespace())
 		return nil
 	}
 
+	if apierrors.IsForbidden(err) {
+		return newForbiddenObjectError("create", obj, err)
+	}
+
 	if err != nil {
 		return fmt.Errorf("creating object in remote cluster: %w", err)
 	}
@@ -211,3 +712,44 @@ func createObject(ctx context.Context, c client.Client, obj client.Object) error
 
 	return nil
 }
+
+// patchCluster patches the metadata and the status of the CAPI cluster against the original object. The main resource
+// is patched first with an optimistic lock, as it bumps the resource version the lock relies on, then the status is
+// patched with the resource version returned by the first patch. An unchanged cluster is not patched at all, so
+// projecting the Rancher cluster status on it does not trigger another reconcile through the cluster watch.
+func patchCluster(ctx context.Context, cl client.Client, capiCluster, original *clusterv1.Cluster) error {
+	if equality.Semantic.DeepEqual(capiCluster, original) {
+		return nil
+	}
+
+	status := capiCluster.Status.DeepCopy()
+
+	if err := cl.Patch(ctx, capiCluster, client.MergeFromWithOptions(original, client.MergeFromWithOptimisticLock{})); err != nil {
+		return fmt.Errorf("failed to patch cluster: %w", err)
+	}
+
+	// Removing the last finalizer of a deleting cluster deletes it, there is no status left to patch.
+	if !capiCluster.DeletionTimestamp.IsZero() && len(capiCluster.Finalizers) == 0 {
+		return nil
+	}
+
+	// The patch response carries the stored status, restore the reconciled one.
+	capiCluster.Status = *status
+
+	if err := cl.Status().Patch(ctx, capiCluster, client.MergeFrom(original)); err != nil {
+		return fmt.Errorf("failed to patch cluster status: %w", err)
+	}
+
+	return nil
+}
+
+// setAnnotation sets an annotation on the object, initializing the annotations if needed.
+func setAnnotation(obj metav1.Object, key, value string) {
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+
+	annotations[key] = value
+	obj.SetAnnotations(annotations)
+}
@@ -0,0 +1,715 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/ptr"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	managementv3 "github.com/rancher/turtles/internal/rancher/management/v3"
+	"github.com/rancher/turtles/testutil"
+	turtlesannotations "github.com/rancher/turtles/util/annotations"
+)
+
+const manifestWithServerFields = `apiVersion: v1
+kind: Namespace
+metadata:
+  name: cattle-system
+  resourceVersion: "12345"
+  uid: 2f6b6b4e-6f0b-4b5e-9d1c-0c6a2d4b8f11
+  generation: 3
+  creationTimestamp: "2024-01-01T00:00:00Z"
+  managedFields:
+  - manager: kubectl
+    operation: Apply
+    apiVersion: v1
+---
+apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: cattle
+  namespace: cattle-system
+  resourceVersion: "678"
+`
+
+var _ = Describe("apply import manifest", func() {
+	It("should strip server-owned metadata fields before create", func() {
+		created := []*unstructured.Unstructured{}
+
+		remoteClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithInterceptorFuncs(interceptor.Funcs{
+			Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+				created = append(created, obj.(*unstructured.Unstructured).DeepCopy())
+				return c.Create(ctx, obj, opts...)
+			},
+		}).Build()
+
+		Expect(createImportManifest(ctx, remoteClient, strings.NewReader(manifestWithServerFields))).To(Succeed())
+		Expect(created).To(HaveLen(2))
+
+		for _, obj := range created {
+			Expect(obj.GetResourceVersion()).To(BeEmpty())
+			Expect(obj.GetManagedFields()).To(BeEmpty())
+			Expect(string(obj.GetUID())).To(BeEmpty())
+			Expect(obj.GetGeneration()).To(BeZero())
+
+			_, found, err := unstructured.NestedFieldNoCopy(obj.Object, "metadata", "creationTimestamp")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(BeFalse())
+		}
+	})
+})
+
+var _ = Describe("manifest URL rewrite", func() {
+	DescribeTable("should rewrite the host and preserve the rest of the URL",
+		func(manifestURL, host, expected string) {
+			rewritten, err := rewriteManifestURL(manifestURL, host)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(rewritten).To(Equal(expected))
+		},
+		Entry("no host", "https://rancher.example.com/v3/import/abc.yaml", "", "https://rancher.example.com/v3/import/abc.yaml"),
+		Entry("host", "https://rancher.example.com/v3/import/abc.yaml?x=1", "mirror.local", "https://mirror.local/v3/import/abc.yaml?x=1"),
+		Entry("host with port", "https://rancher.example.com:8443/v3/import/abc.yaml", "mirror.local:9443", "https://mirror.local:9443/v3/import/abc.yaml"),
+	)
+
+	It("should prefer an allowed cluster annotation over the default host", func() {
+		capiCluster := &clusterv1.Cluster{}
+		Expect(manifestURLHost(capiCluster, "default.local", nil)).To(Equal("default.local"))
+
+		capiCluster.Annotations = map[string]string{turtlesannotations.ManifestURLHostAnnotation: "mirror.local"}
+		Expect(manifestURLHost(capiCluster, "default.local", []string{"other.local", "mirror.local"})).To(Equal("mirror.local"))
+
+		capiCluster.Annotations[turtlesannotations.ManifestURLHostAnnotation] = "default.local"
+		Expect(manifestURLHost(capiCluster, "default.local", nil)).To(Equal("default.local"))
+	})
+
+	It("should refuse a cluster annotation naming a host that is not allowed", func() {
+		capiCluster := &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{turtlesannotations.ManifestURLHostAnnotation: "attacker.example.com"},
+		}}
+
+		_, err := manifestURLHost(capiCluster, "default.local", nil)
+		Expect(err).To(MatchError(errManifestURLHostNotAllowed))
+
+		_, err = manifestURLHost(capiCluster, "", []string{"mirror.local"})
+		Expect(err).To(MatchError(ContainSubstring("attacker.example.com")))
+	})
+
+	It("should not download the manifest from a host that is not allowed", func() {
+		server := testutil.NewManifestServer(manifestWithServerFields)
+		defer server.Close()
+
+		attacker, err := url.Parse(server.URL)
+		Expect(err).ToNot(HaveOccurred())
+
+		r := &CAPIImportReconciler{
+			RancherClient: testutil.NewRancherClientBuilder().
+				WithCluster("test-cluster-capi", "test-ns", testutil.ClusterStateNameSet, "http://rancher.invalid/v3/import/abc.yaml").
+				Build(),
+			DownloadOptions: DownloadOptions{
+				ManifestURLHostAllowlist: []string{"mirror.local"},
+			},
+		}
+
+		capiCluster := &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{
+			Name:        "test-cluster",
+			Namespace:   "test-ns",
+			Annotations: map[string]string{turtlesannotations.ManifestURLHostAnnotation: attacker.Host},
+		}}
+
+		_, _, err = r.importManifest(ctx, capiCluster,
+			testutil.RancherCluster("test-cluster-capi", "test-ns", testutil.ClusterStateNameSet), false)
+		Expect(err).To(MatchError(errManifestURLHostNotAllowed))
+		Expect(server.Requests()).To(BeZero())
+	})
+
+	It("should download the manifest from the mirror", func() {
+		var requested *url.URL
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requested = r.URL
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(manifestWithServerFields))
+		}))
+		defer server.Close()
+
+		mirror, err := url.Parse(server.URL)
+		Expect(err).ToNot(HaveOccurred())
+
+		token := &managementv3.ClusterRegistrationToken{
+			ObjectMeta: metav1.ObjectMeta{Name: "c-m-mirror", Namespace: "test-ns"},
+			Status: managementv3.ClusterRegistrationTokenStatus{
+				ManifestURL: "http://rancher.invalid/v3/import/token_c-m-mirror.yaml?cluster=c-m-mirror",
+			},
+		}
+		rancherClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(token).Build()
+
+		manifest, err := getClusterRegistrationManifest(ctx, "c-m-mirror", "test-ns", rancherClient, http.DefaultClient, mirror.Host, "", manifestRetry{}, tokenReissue{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(manifest).To(Equal(manifestWithServerFields))
+		Expect(requested.Path).To(Equal("/v3/import/token_c-m-mirror.yaml"))
+		Expect(requested.RawQuery).To(Equal("cluster=c-m-mirror"))
+	})
+})
+
+var _ = Describe("registration token", func() {
+	key := client.ObjectKey{Namespace: "test-ns", Name: "c-m-token"}
+
+	It("should return the existing token", func() {
+		existing := testutil.RegistrationToken(key.Name, key.Namespace, "https://rancher.example.com/v3/import/abc.yaml")
+		creates := 0
+
+		rancherClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(existing).WithInterceptorFuncs(interceptor.Funcs{
+			Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+				creates++
+				return c.Create(ctx, obj, opts...)
+			},
+		}).Build()
+
+		token, err := ensureRegistrationToken(ctx, rancherClient, key.Name, key.Namespace)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(token.Status.ManifestURL).To(Equal("https://rancher.example.com/v3/import/abc.yaml"))
+		Expect(creates).To(BeZero())
+	})
+
+	It("should create a missing token", func() {
+		rancherClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).Build()
+
+		token, err := ensureRegistrationToken(ctx, rancherClient, key.Name, key.Namespace)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(token.Spec.ClusterName).To(Equal(key.Name))
+		Expect(token.Status.ManifestURL).To(BeEmpty())
+
+		stored := &managementv3.ClusterRegistrationToken{}
+		Expect(rancherClient.Get(ctx, key, stored)).To(Succeed())
+		Expect(stored.Spec.ClusterName).To(Equal(key.Name))
+	})
+
+	It("should adopt a token created concurrently", func() {
+		existing := testutil.RegistrationToken(key.Name, key.Namespace, "https://rancher.example.com/v3/import/abc.yaml")
+		gets := 0
+
+		// the first get races with the creation of the token by Rancher
+		rancherClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(existing).WithInterceptorFuncs(interceptor.Funcs{
+			Get: func(ctx context.Context, c client.WithWatch, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+				gets++
+				if gets == 1 {
+					return apierrors.NewNotFound(managementv3.GroupVersion.WithResource("clusterregistrationtokens").GroupResource(), key.Name)
+				}
+
+				return c.Get(ctx, key, obj, opts...)
+			},
+		}).Build()
+
+		token, err := ensureRegistrationToken(ctx, rancherClient, key.Name, key.Namespace)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(token.Status.ManifestURL).To(Equal("https://rancher.example.com/v3/import/abc.yaml"))
+		Expect(gets).To(Equal(2))
+	})
+
+	It("should fail on other errors", func() {
+		rancherClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithInterceptorFuncs(interceptor.Funcs{
+			Create: func(_ context.Context, _ client.WithWatch, _ client.Object, _ ...client.CreateOption) error {
+				return apierrors.NewServiceUnavailable("rancher is restarting")
+			},
+		}).Build()
+
+		_, err := ensureRegistrationToken(ctx, rancherClient, key.Name, key.Namespace)
+		Expect(err).To(MatchError(ContainSubstring("failed to create cluster registration token")))
+	})
+})
+
+var _ = Describe("registration token re-issue", func() {
+	key := client.ObjectKey{Namespace: "test-ns", Name: "c-m-token"}
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	var (
+		existing *managementv3.ClusterRegistrationToken
+		deletes  int
+	)
+
+	BeforeEach(func() {
+		existing = testutil.RegistrationToken(key.Name, key.Namespace, "")
+		existing.CreationTimestamp = metav1.NewTime(created)
+		deletes = 0
+	})
+
+	manifestURL := func(reissue tokenReissue) (string, *managementv3.ClusterRegistrationToken) {
+		rancherClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(existing).WithInterceptorFuncs(interceptor.Funcs{
+			Delete: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.DeleteOption) error {
+				deletes++
+				return c.Delete(ctx, obj, opts...)
+			},
+		}).Build()
+
+		url, err := getClusterRegistrationManifestURL(ctx, key.Name, key.Namespace, rancherClient, "", reissue)
+		Expect(err).ToNot(HaveOccurred())
+
+		token := &managementv3.ClusterRegistrationToken{}
+		Expect(rancherClient.Get(ctx, key, token)).To(Succeed())
+
+		return url, token
+	}
+
+	It("should keep a token without manifest URL during the grace period", func() {
+		url, token := manifestURL(tokenReissue{GracePeriod: 10 * time.Minute, Now: created.Add(9 * time.Minute)})
+		Expect(url).To(BeEmpty())
+		Expect(deletes).To(BeZero())
+		Expect(token.CreationTimestamp.Time).To(BeTemporally("==", created))
+	})
+
+	It("should re-issue a token without manifest URL after the grace period", func() {
+		url, token := manifestURL(tokenReissue{GracePeriod: 10 * time.Minute, Now: created.Add(10 * time.Minute)})
+		Expect(url).To(BeEmpty())
+		Expect(deletes).To(Equal(1))
+		Expect(token.CreationTimestamp.Time).ToNot(BeTemporally("==", created))
+		Expect(token.Spec.ClusterName).To(Equal(key.Name))
+	})
+
+	It("should not re-issue tokens without manifest URL when the grace period is disabled", func() {
+		url, _ := manifestURL(tokenReissue{Now: created.Add(24 * time.Hour)})
+		Expect(url).To(BeEmpty())
+		Expect(deletes).To(BeZero())
+	})
+
+	It("should re-issue an expired token", func() {
+		existing.Status.ManifestURL = "https://rancher.example.com/v3/import/abc.yaml"
+		existing.Status.ExpiresAt = ptr.To(metav1.NewTime(created.Add(time.Hour)))
+
+		url, token := manifestURL(tokenReissue{Now: created.Add(time.Hour)})
+		Expect(url).To(BeEmpty())
+		Expect(deletes).To(Equal(1))
+		Expect(token.Status.ManifestURL).To(BeEmpty())
+	})
+
+	It("should return the manifest URL of a token that has not expired", func() {
+		existing.Status.ManifestURL = "https://rancher.example.com/v3/import/abc.yaml"
+		existing.Status.ExpiresAt = ptr.To(metav1.NewTime(created.Add(time.Hour)))
+
+		url, _ := manifestURL(tokenReissue{GracePeriod: time.Minute, Now: created.Add(59 * time.Minute)})
+		Expect(url).To(Equal("https://rancher.example.com/v3/import/abc.yaml"))
+		Expect(deletes).To(BeZero())
+	})
+
+	It("should not re-issue a token younger than the minimum interval", func() {
+		existing.Status.ExpiresAt = ptr.To(metav1.NewTime(created))
+
+		_, _ = manifestURL(tokenReissue{GracePeriod: time.Minute, MinInterval: 5 * time.Minute, Now: created.Add(4 * time.Minute)})
+		Expect(deletes).To(BeZero())
+
+		_, _ = manifestURL(tokenReissue{GracePeriod: time.Minute, MinInterval: 5 * time.Minute, Now: created.Add(5 * time.Minute)})
+		Expect(deletes).To(Equal(1))
+	})
+})
+
+var _ = Describe("manifest download retries", func() {
+	var requests atomic.Int32
+
+	// flakyServer answers with the failure status to the first failures requests, then serves the manifest.
+	flakyServer := func(failures int32, status int) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			if requests.Add(1) <= failures {
+				w.WriteHeader(status)
+				return
+			}
+
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(manifestWithServerFields))
+		}))
+	}
+
+	retry := manifestRetry{Attempts: 4, Interval: time.Millisecond}
+
+	BeforeEach(func() {
+		requests.Store(0)
+	})
+
+	It("should retry server errors until the download succeeds", func() {
+		server := flakyServer(3, http.StatusServiceUnavailable)
+		defer server.Close()
+
+		manifest, err := downloadManifest(ctx, http.DefaultClient, server.URL, retry)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(manifest).To(Equal(manifestWithServerFields))
+		Expect(requests.Load()).To(BeEquivalentTo(4))
+	})
+
+	It("should give up after the maximum number of attempts", func() {
+		server := flakyServer(10, http.StatusBadGateway)
+		defer server.Close()
+
+		_, err := downloadManifest(ctx, http.DefaultClient, server.URL, retry)
+		Expect(err).To(MatchError(&manifestStatusError{StatusCode: http.StatusBadGateway}))
+		Expect(requests.Load()).To(BeEquivalentTo(4))
+	})
+
+	It("should not retry client errors", func() {
+		server := flakyServer(1, http.StatusNotFound)
+		defer server.Close()
+
+		_, err := downloadManifest(ctx, http.DefaultClient, server.URL, retry)
+		Expect(err).To(MatchError(ContainSubstring("unexpected status 404")))
+		Expect(requests.Load()).To(BeEquivalentTo(1))
+	})
+
+	It("should retry connection errors", func() {
+		server := flakyServer(0, http.StatusOK)
+		manifestURL := server.URL
+		server.Close()
+
+		_, err := downloadManifest(ctx, http.DefaultClient, manifestURL, retry)
+		Expect(err).To(MatchError(ContainSubstring("connection refused")))
+		Expect(retryableDownloadError(err)).To(BeTrue())
+	})
+
+	It("should download once without retries configured", func() {
+		server := flakyServer(1, http.StatusInternalServerError)
+		defer server.Close()
+
+		_, err := downloadManifest(ctx, http.DefaultClient, server.URL, manifestRetry{})
+		Expect(err).To(HaveOccurred())
+		Expect(requests.Load()).To(BeEquivalentTo(1))
+	})
+
+	It("should stop retrying when the context is cancelled", func() {
+		server := flakyServer(10, http.StatusServiceUnavailable)
+		defer server.Close()
+
+		cancelled, cancel := context.WithCancel(ctx)
+		cancel()
+
+		_, err := downloadManifest(cancelled, http.DefaultClient, server.URL, manifestRetry{Attempts: 4, Interval: time.Hour})
+		Expect(err).To(MatchError(context.Canceled))
+	})
+
+	It("should not retry a TLS verification failure", func() {
+		var handshakes atomic.Int32
+
+		server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {}))
+		server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+			if state == http.StateNew {
+				handshakes.Add(1)
+			}
+		}
+		server.Config.ErrorLog = log.New(io.Discard, "", 0)
+		server.StartTLS()
+		defer server.Close()
+
+		_, err := downloadManifest(ctx, http.DefaultClient, server.URL, retry)
+		Expect(err).To(MatchError(ContainSubstring("certificate")))
+		Expect(handshakes.Load()).To(BeEquivalentTo(1))
+	})
+
+	DescribeTable("should only retry transient failures",
+		func(downloadErr func() error, retryable bool) {
+			Expect(retryableDownloadError(downloadErr())).To(Equal(retryable))
+		},
+		Entry("a server error", func() error {
+			return &manifestStatusError{StatusCode: http.StatusServiceUnavailable}
+		}, true),
+		Entry("a rate limited request", func() error {
+			return fmt.Errorf("downloading manifest: %w", &manifestStatusError{StatusCode: http.StatusTooManyRequests})
+		}, true),
+		Entry("a client error", func() error {
+			return &manifestStatusError{StatusCode: http.StatusForbidden}
+		}, false),
+		Entry("a refused connection", func() error {
+			server := httptest.NewServer(http.NotFoundHandler())
+			server.Close()
+
+			_, err := downloadManifestOnce(ctx, http.DefaultClient, server.URL)
+
+			return err
+		}, true),
+		Entry("a reset connection", func() error {
+			return fmt.Errorf("downloading manifest: %w", &url.Error{Op: "Get", URL: "https://rancher.example.com", Err: &net.OpError{
+				Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET),
+			}})
+		}, true),
+		Entry("a TLS verification failure", func() error {
+			server := httptest.NewUnstartedServer(http.NotFoundHandler())
+			server.Config.ErrorLog = log.New(io.Discard, "", 0)
+			server.StartTLS()
+			defer server.Close()
+
+			_, err := downloadManifestOnce(ctx, http.DefaultClient, server.URL)
+
+			return err
+		}, false),
+		Entry("a TLS alert from the server", func() error {
+			return fmt.Errorf("downloading manifest: %w", &url.Error{Op: "Get", URL: "https://rancher.example.com", Err: &net.OpError{
+				Op: "remote error", Err: errors.New("tls: bad certificate"),
+			}})
+		}, false),
+		Entry("a malformed URL", func() error {
+			_, err := downloadManifestOnce(ctx, http.DefaultClient, "https://rancher.example.com:port/import.yaml")
+
+			return err
+		}, false),
+		Entry("an unsupported scheme", func() error {
+			_, err := downloadManifestOnce(ctx, http.DefaultClient, "ftp://rancher.example.com/import.yaml")
+
+			return err
+		}, false),
+		Entry("a manifest not matching its checksum", func() error {
+			return fmt.Errorf("%w: checksum mismatch", errManifestVerification)
+		}, false),
+		Entry("a manifest which can't be decoded", func() error {
+			_, err := decodeManifest(strings.NewReader("kind: [Namespace"))
+
+			return err
+		}, false),
+		Entry("a cancelled context", func() error {
+			return fmt.Errorf("downloading manifest: %w", &url.Error{Op: "Get", URL: "https://rancher.example.com", Err: context.Canceled})
+		}, false),
+	)
+})
+
+var _ = Describe("manifest download TLS", func() {
+	var server *httptest.Server
+
+	caBundle := func(s *httptest.Server) []byte {
+		return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: s.Certificate().Raw})
+	}
+
+	download := func(tlsConfig manifestTLS) (string, error) {
+		httpClient, err := newManifestHTTPClient(tlsConfig, 0)
+		if err != nil {
+			return "", err
+		}
+
+		return downloadManifest(ctx, httpClient, server.URL, manifestRetry{})
+	}
+
+	BeforeEach(func() {
+		server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.Write([]byte(manifestWithServerFields))
+		}))
+		DeferCleanup(server.Close)
+	})
+
+	It("should verify the server against the CA bundle", func() {
+		manifest, err := download(manifestTLS{CABundle: caBundle(server)})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(manifest).To(Equal(manifestWithServerFields))
+	})
+
+	It("should reject a server not signed by a trusted CA", func() {
+		_, err := download(manifestTLS{})
+		Expect(err).To(MatchError(ContainSubstring("certificate")))
+	})
+
+	It("should prefer the CA bundle over skipping verification", func() {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		Expect(err).ToNot(HaveOccurred())
+		template := &x509.Certificate{
+			SerialNumber:          big.NewInt(1),
+			Subject:               pkix.Name{CommonName: "untrusted"},
+			NotAfter:              time.Now().Add(time.Hour),
+			IsCA:                  true,
+			BasicConstraintsValid: true,
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+		Expect(err).ToNot(HaveOccurred())
+		untrusted := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+
+		_, err = download(manifestTLS{InsecureSkipVerify: true, CABundle: untrusted})
+		Expect(err).To(MatchError(ContainSubstring("certificate")))
+	})
+
+	It("should skip verification without a CA bundle", func() {
+		_, err := download(manifestTLS{InsecureSkipVerify: true})
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("should fail on a CA bundle without certificates", func() {
+		_, err := download(manifestTLS{CABundle: []byte("not a certificate")})
+		Expect(err).To(MatchError(ContainSubstring("no valid PEM certificate")))
+	})
+})
+
+var _ = Describe("manifest HTTP client", func() {
+	It("should be built once and reused", func() {
+		httpClient := manifestHTTPClient{}
+
+		first, err := httpClient.get(manifestTLS{}, 0)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(first.Timeout).To(Equal(defaultManifestDownloadTimeout))
+
+		second, err := httpClient.get(manifestTLS{InsecureSkipVerify: true}, time.Second)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(second).To(BeIdenticalTo(first))
+	})
+
+	It("should time out on a hung server instead of blocking", func() {
+		release := make(chan struct{})
+		server := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+			<-release
+		}))
+		defer server.Close()
+		defer close(release)
+
+		httpClient, err := newManifestHTTPClient(manifestTLS{}, 50*time.Millisecond)
+		Expect(err).ToNot(HaveOccurred())
+
+		done := make(chan error, 1)
+		go func() {
+			_, err := downloadManifest(ctx, httpClient, server.URL, manifestRetry{})
+			done <- err
+		}()
+
+		Eventually(done).WithTimeout(5 * time.Second).Should(Receive(MatchError(ContainSubstring("Client.Timeout exceeded"))))
+	})
+})
+
+var _ = Describe("manifest checksum", func() {
+	const manifest = "apiVersion: v1\nkind: Namespace\nmetadata:\n  name: cattle-system\n"
+
+	DescribeTable("verifyManifestChecksum",
+		func(expected string, matches bool) {
+			err := verifyManifestChecksum(manifest, expected)
+			if matches {
+				Expect(err).ToNot(HaveOccurred())
+			} else {
+				Expect(err).To(MatchError(errManifestVerification))
+			}
+		},
+		Entry("no expected checksum", "", true),
+		Entry("matching prefixed checksum", "sha256:"+manifestHash(manifest), true),
+		Entry("matching bare checksum", manifestHash(manifest), true),
+		Entry("matching upper case checksum", "sha256:"+strings.ToUpper(manifestHash(manifest)), true),
+		Entry("mismatching checksum", "sha256:"+manifestHash("tampered"), false),
+		Entry("unsupported algorithm", "md5:"+manifestHash(manifest), false),
+	)
+})
+
+var _ = Describe("manifest apply on shutdown", func() {
+	var (
+		objs    []*unstructured.Unstructured
+		created []string
+	)
+
+	// remoteClientCancelling cancels the reconcile context while the first object is being created,
+	// simulating a shutdown in the middle of the apply.
+	remoteClientCancelling := func(cancel context.CancelFunc) client.Client {
+		return fake.NewClientBuilder().WithScheme(scheme.Scheme).WithInterceptorFuncs(interceptor.Funcs{
+			Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+				cancel()
+
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+
+				created = append(created, obj.GetName())
+
+				return c.Create(ctx, obj, opts...)
+			},
+		}).Build()
+	}
+
+	BeforeEach(func() {
+		var err error
+
+		created = []string{}
+		objs, err = decodeManifest(strings.NewReader(manifestWithServerFields))
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("should finish the current object and abort before the next one", func() {
+		applyCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		err := createObjects(applyCtx, remoteClientCancelling(cancel), objs, false)
+		Expect(err).To(MatchError(context.Canceled))
+		Expect(created).To(Equal([]string{"cattle-system"}))
+	})
+
+	It("should finish the current object and abort before the next one when applying incrementally", func() {
+		applyCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		applied, err := applyObjectsIncrementally(applyCtx, remoteClientCancelling(cancel), objs, false)
+		Expect(err).To(MatchError(context.Canceled))
+		Expect(applied).To(Equal(1))
+		Expect(created).To(Equal([]string{"cattle-system"}))
+	})
+
+	It("should not start applying when already shut down", func() {
+		applyCtx, cancel := context.WithCancel(ctx)
+		cancel()
+
+		Expect(createObjects(applyCtx, remoteClientCancelling(cancel), objs, false)).To(MatchError(context.Canceled))
+		Expect(created).To(BeEmpty())
+	})
+
+	It("should give the in-flight write the configured time to finish", func() {
+		deadlines := []time.Duration{}
+
+		r := &CAPIImportReconciler{ApplyOptions: ApplyOptions{ObjectApplyTimeout: 2 * time.Minute}}
+		remoteClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithInterceptorFuncs(interceptor.Funcs{
+			Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+				deadline, ok := ctx.Deadline()
+				Expect(ok).To(BeTrue())
+				deadlines = append(deadlines, time.Until(deadline))
+
+				return c.Create(ctx, obj, opts...)
+			},
+		}).Build()
+
+		Expect(r.writeManifestObjects(ctx, remoteClient, objs)).To(Succeed())
+		Expect(deadlines).ToNot(BeEmpty())
+		Expect(deadlines[0]).To(BeNumerically(">", objectApplyTimeout))
+		Expect(deadlines[0]).To(BeNumerically("<=", 2*time.Minute))
+
+		applyCtx, cancel := objectApplyContext(ctx)
+		defer cancel()
+
+		deadline, ok := applyCtx.Deadline()
+		Expect(ok).To(BeTrue())
+		Expect(time.Until(deadline)).To(BeNumerically("<=", objectApplyTimeout))
+	})
+})
@@ -0,0 +1,179 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/clock"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/controllers/remote"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	turtlesv1 "github.com/rancher/turtles/api/v1alpha1"
+	provisioningv1 "github.com/rancher/turtles/internal/rancher/provisioning/v1"
+	turtlesannotations "github.com/rancher/turtles/util/annotations"
+	turtlesnaming "github.com/rancher/turtles/util/naming"
+)
+
+const defaultImportPollInterval = 5 * time.Second
+
+// ErrNotImportable is returned by ImportCluster when the CAPI cluster can't be imported, e.g. because it is not
+// marked for import.
+var ErrNotImportable = errors.New("cluster can't be imported")
+
+// ImportConfig configures a synchronous import with ImportCluster.
+type ImportConfig struct {
+	// Client is the client of the management cluster hosting the CAPI cluster.
+	Client client.Client
+	// RancherClient is the client of the cluster Rancher runs on.
+	RancherClient client.Client
+
+	// InsecureSkipVerify skips the TLS verification when downloading the registration manifest.
+	InsecureSkipVerify bool
+	// ManifestURLHost overrides the host the registration manifest is downloaded from.
+	ManifestURLHost string
+	// ManifestURLHostAllowlist lists the hosts the manifest URL host annotation of the CAPI cluster may select.
+	ManifestURLHostAllowlist []string
+	// NameTemplate, when set, overrides the default naming convention of the Rancher cluster.
+	NameTemplate *turtlesnaming.Template
+	// RancherClusterNamespace, when set, is the namespace the Rancher cluster is created in instead of the namespace
+	// of the CAPI cluster.
+	RancherClusterNamespace string
+
+	// PollInterval is the interval the import is retried at while it waits on the control plane or on Rancher.
+	// Defaults to 5 seconds.
+	PollInterval time.Duration
+	// Recorder records the events of the import. Events are dropped when nil.
+	Recorder record.EventRecorder
+	// KubeconfigSecret locates the kubeconfig secret of the CAPI cluster when it doesn't follow the CAPI convention.
+	KubeconfigSecret KubeconfigSecretRef
+	// RemoteClientGetter returns the client of the downstream cluster. Defaults to a client built from the kubeconfig
+	// secret.
+	RemoteClientGetter remote.ClusterClientGetter
+}
+
+// ImportCluster imports the CAPI cluster into Rancher synchronously, outside of the controller, using the same logic
+// as the reconciler: it creates the Rancher cluster, waits for its registration token, then downloads and applies the
+// registration manifest. It returns once the manifest was applied or the Rancher agent is already deployed, and
+// returns ErrNotImportable when the cluster is not eligible for import. The context bounds the wait.
+func ImportCluster(ctx context.Context, cfg ImportConfig, namespace, name string) error {
+	r := newImportReconciler(cfg)
+
+	pollInterval := cfg.PollInterval
+	if pollInterval == 0 {
+		pollInterval = defaultImportPollInterval
+	}
+
+	key := client.ObjectKey{Namespace: namespace, Name: name}
+
+	return wait.PollUntilContextCancel(ctx, pollInterval, true, func(ctx context.Context) (bool, error) {
+		return r.importOnce(ctx, key)
+	})
+}
+
+// newImportReconciler returns a reconciler running outside of the controller, configured from cfg.
+func newImportReconciler(cfg ImportConfig) *CAPIImportReconciler {
+	r := &CAPIImportReconciler{
+		Client:             cfg.Client,
+		RancherClient:      cfg.RancherClient,
+		KubeconfigSecret:   cfg.KubeconfigSecret,
+		recorder:           cfg.Recorder,
+		remoteClientGetter: cfg.RemoteClientGetter,
+		clock:              clock.RealClock{},
+		DownloadOptions: DownloadOptions{
+			InsecureSkipVerify:       cfg.InsecureSkipVerify,
+			ManifestURLHost:          cfg.ManifestURLHost,
+			ManifestURLHostAllowlist: cfg.ManifestURLHostAllowlist,
+		},
+		RancherClusterOptions: RancherClusterOptions{
+			NameTemplate:            cfg.NameTemplate,
+			RancherClusterNamespace: cfg.RancherClusterNamespace,
+		},
+	}
+
+	if r.recorder == nil {
+		r.recorder = &record.FakeRecorder{}
+	}
+
+	if r.remoteClientGetter == nil {
+		r.remoteClientGetter = r.KubeconfigSecret.clientGetter()
+	}
+
+	return r
+}
+
+// importOnce runs a single reconcile of the CAPI cluster and reports whether the import is done.
+func (r *CAPIImportReconciler) importOnce(ctx context.Context, key client.ObjectKey) (bool, error) {
+	capiCluster := &clusterv1.Cluster{}
+	if err := r.Client.Get(ctx, key, capiCluster); err != nil {
+		return false, fmt.Errorf("getting CAPI cluster %s: %w", key, err)
+	}
+
+	if turtlesannotations.HasClusterImportAnnotation(capiCluster) {
+		return false, fmt.Errorf("%w: %s was already imported and its Rancher cluster removed", ErrNotImportable, key)
+	}
+
+	result, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	if err != nil {
+		return false, err
+	}
+
+	if err := r.Client.Get(ctx, key, capiCluster); err != nil {
+		return false, fmt.Errorf("getting CAPI cluster %s: %w", key, err)
+	}
+
+	rancherClusterKey, err := r.rancherClusterKey(capiCluster)
+	if err != nil {
+		return false, err
+	}
+
+	rancherCluster := &provisioningv1.Cluster{ObjectMeta: metav1.ObjectMeta{
+		Namespace: rancherClusterKey.Namespace,
+		Name:      rancherClusterKey.Name,
+	}}
+
+	err = r.getRancherCluster(ctx, rancherCluster)
+	if client.IgnoreNotFound(err) != nil {
+		return false, fmt.Errorf("getting Rancher cluster: %w", err)
+	}
+
+	exists := !apierrors.IsNotFound(err) && rancherCluster.DeletionTimestamp.IsZero()
+
+	switch {
+	case exists && r.rancherClusterStatus(rancherCluster).AgentDeployed:
+		return true, nil
+	case result.IsZero() && exists:
+		// The manifest was applied, the reconciler only stops requeueing once it is done.
+		return true, nil
+	case result.IsZero():
+		return false, fmt.Errorf("%w: %s: %s", ErrNotImportable, key,
+			conditions.GetMessage(capiCluster, turtlesv1.ImportEligibleCondition))
+	default:
+		return false, nil
+	}
+}
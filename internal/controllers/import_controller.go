@@ -20,16 +20,22 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/go-logr/logr"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	errorutils "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/clock"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
@@ -39,28 +45,90 @@ import (
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	"sigs.k8s.io/cluster-api/controllers/external"
 	"sigs.k8s.io/cluster-api/controllers/remote"
+	capiannotations "sigs.k8s.io/cluster-api/util/annotations"
 	"sigs.k8s.io/cluster-api/util/conditions"
 	"sigs.k8s.io/cluster-api/util/predicates"
 
+	turtlesv1 "github.com/rancher/turtles/api/v1alpha1"
+	managementv3 "github.com/rancher/turtles/internal/rancher/management/v3"
 	provisioningv1 "github.com/rancher/turtles/internal/rancher/provisioning/v1"
-	"github.com/rancher/turtles/util"
 	turtlesannotations "github.com/rancher/turtles/util/annotations"
-	turtlesnaming "github.com/rancher/turtles/util/naming"
 	turtlespredicates "github.com/rancher/turtles/util/predicates"
+	"github.com/rancher/turtles/util/scheduling"
 )
 
 // CAPIImportReconciler represents a reconciler for importing CAPI clusters in Rancher.
 type CAPIImportReconciler struct {
-	Client             client.Client
-	RancherClient      client.Client
-	recorder           record.EventRecorder
-	WatchFilterValue   string
-	Scheme             *runtime.Scheme
-	InsecureSkipVerify bool
+	Client           client.Client
+	RancherClient    client.Client
+	recorder         record.EventRecorder
+	WatchFilterValue string
+	Scheme           *runtime.Scheme
+
+	// RemoteRancher reports that RancherClient points at another cluster than the CAPI clusters, e.g. when built with
+	// NewRemoteRancherClient. Owner references can't cross clusters, so the Rancher clusters are always linked to their
+	// CAPI cluster with labels.
+	RemoteRancher bool
+
+	// RancherClusterFactory creates and gets the Rancher clusters in the shape served by Rancher. Nil defaults to the
+	// provisioning v1 clusters.
+	RancherClusterFactory RancherClusterFactory
+
+	// CheckAgentHealth waits, once the import manifest is applied, for the cattle-cluster-agent deployment to be
+	// available on the downstream cluster and reports it with the AgentHealthy condition.
+	CheckAgentHealth bool
+
+	// ReconcileAgentHealth periodically verifies the cattle-cluster-agent deployment still exists on the downstream
+	// cluster once Rancher reports the agent as deployed, and re-applies the import manifest when it was deleted.
+	ReconcileAgentHealth bool
+
+	// EndpointDiagnostics reports with the EndpointsReachable condition a control plane endpoint the management cluster
+	// can't reach, and a Rancher server-url the agent can't, or likely can't, reach from the downstream cluster.
+	EndpointDiagnostics bool
+
+	// Version is the turtles version recorded on the imported clusters. Nothing is recorded when empty or when it is
+	// the placeholder version of builds without the version stamped in.
+	Version string
+
+	// CacheRemoteClients keeps the downstream cluster clients between reconciles instead of creating them on every
+	// use. Cached clients are evicted when the CAPI cluster or its Rancher cluster is deleted.
+	CacheRemoteClients bool
+
+	// KubeconfigSecret locates the kubeconfig secret of the CAPI clusters when it doesn't follow the CAPI convention.
+	KubeconfigSecret KubeconfigSecretRef
+
+	// Concurrency is the number of CAPI clusters imported in parallel. When positive, it overrides the
+	// MaxConcurrentReconciles of the controller options. The in-memory state shared by the reconciles, e.g. the caches
+	// and backoffs, is guarded by its own lock, and a cluster is never reconciled by two workers at once.
+	Concurrency int
+
+	// The import options, grouped by concern. Their fields are promoted to the reconciler.
+	DownloadOptions
+	ApplyOptions
+	SelectionOptions
+	TimingOptions
+	RancherClusterOptions
 
 	controller         controller.Controller
 	externalTracker    external.ObjectTracker
 	remoteClientGetter remote.ClusterClientGetter
+	remoteClients      remoteClientCache
+	reconciles         reconcileTracker
+	requeues           requeueBackoff
+	manifests          manifestCache
+	manifestHTTPClient manifestHTTPClient
+	managed            managedClusterSet
+	crdPollInterval    time.Duration
+	clock              clock.Clock
+
+	namespaceEventsLock sync.Mutex
+	namespaceEvents     map[string]time.Time
+
+	reappliesLock sync.Mutex
+	reapplies     map[client.ObjectKey]time.Time
+
+	selfCheckLock sync.RWMutex
+	selfCheck     *SelfCheckResult
 }
 
 // SetupWithManager sets up reconciler with manager.
@@ -68,19 +136,41 @@ func (r *CAPIImportReconciler) SetupWithManager(ctx context.Context, mgr ctrl.Ma
 	log := log.FromContext(ctx)
 
 	if r.remoteClientGetter == nil {
-		r.remoteClientGetter = remote.NewClusterClient
+		r.remoteClientGetter = r.KubeconfigSecret.clientGetter()
 	}
 
-	capiPredicates := predicates.All(log,
-		predicates.ResourceHasFilterLabel(log, r.WatchFilterValue),
-		turtlespredicates.ClusterWithoutImportedAnnotation(log),
-		turtlespredicates.ClusterWithReadyControlPlane(log),
-		turtlespredicates.ClusterOrNamespaceWithImportLabel(ctx, log, r.Client, importLabelName),
-	)
+	if r.clock == nil {
+		r.clock = clock.RealClock{}
+	}
+
+	if err := validateAnnotationSync(r.AnnotationsToRancher, r.AnnotationsFromRancher); err != nil {
+		return fmt.Errorf("validating annotation sync: %w", err)
+	}
+
+	for _, label := range []string{r.importLabel(), r.ownedLabel()} {
+		if errs := validation.IsQualifiedName(label); len(errs) > 0 {
+			return fmt.Errorf("validating label %q: %s", label, strings.Join(errs, ", "))
+		}
+	}
+
+	if err := scheduling.ValidateNodeSelector(r.AgentNodeSelector); err != nil {
+		return fmt.Errorf("validating agent node selector: %w", err)
+	}
+
+	if err := scheduling.ValidateTolerations(r.AgentTolerations); err != nil {
+		return fmt.Errorf("validating agent tolerations: %w", err)
+	}
+
+	clusterPredicates, err := r.clusterPredicates(ctx, log)
+	if err != nil {
+		return err
+	}
+
+	capiPredicates := predicates.All(log, clusterPredicates...)
 
 	c, err := ctrl.NewControllerManagedBy(mgr).
 		For(&clusterv1.Cluster{}).
-		WithOptions(options).
+		WithOptions(r.controllerOptions(options)).
 		WithEventFilter(capiPredicates).
 		Build(r)
 	if err != nil {
@@ -101,12 +191,22 @@ func (r *CAPIImportReconciler) SetupWithManager(ctx context.Context, mgr ctrl.Ma
 
 	err = c.Watch(
 		source.Kind(mgr.GetCache(), ns),
-		handler.EnqueueRequestsFromMapFunc(namespaceToCapiClusters(ctx, capiPredicates, r.Client)),
+		enqueueRequestsFromMapFuncStaggered(namespaceToCapiClusters(ctx, capiPredicates, r.Client, r.importLabel()), r.NamespaceEnqueueSpread),
 	)
 	if err != nil {
 		return fmt.Errorf("adding watch for namespaces: %w", err)
 	}
 
+	if r.TopologyVariableMapping.Name != "" {
+		err = c.Watch(
+			source.Kind(mgr.GetCache(), &corev1.ConfigMap{}),
+			handler.EnqueueRequestsFromMapFunc(r.variableMappingToCapiClusters(ctx, capiPredicates)),
+		)
+		if err != nil {
+			return fmt.Errorf("adding watch for topology variable mapping: %w", err)
+		}
+	}
+
 	r.recorder = mgr.GetEventRecorderFor("rancher-turtles")
 	r.controller = c
 	r.externalTracker = external.ObjectTracker{
@@ -116,49 +216,169 @@ func (r *CAPIImportReconciler) SetupWithManager(ctx context.Context, mgr ctrl.Ma
 	return nil
 }
 
+// controllerOptions returns the options of the controller, with the MaxConcurrentReconciles set by Concurrency.
+func (r *CAPIImportReconciler) controllerOptions(options controller.Options) controller.Options {
+	if r.Concurrency > 0 {
+		options.MaxConcurrentReconciles = r.Concurrency
+	}
+
+	return options
+}
+
+// clusterPredicates returns the predicates a CAPI cluster must pass to be reconciled. The watched namespaces and the
+// cluster selector are additive with the import label, clusters must pass all of them. A cluster being deleted which
+// still holds the finalizer bypasses the gating predicates, so its linked Rancher cluster is cleaned up and the
+// finalizer released even after the cluster stopped matching them.
+func (r *CAPIImportReconciler) clusterPredicates(ctx context.Context, log logr.Logger) ([]predicate.Funcs, error) {
+	gated := func(p predicate.Funcs) predicate.Funcs {
+		return turtlespredicates.AllowClusterDeletingWithFinalizer(log, managementv3.CapiClusterFinalizer, p)
+	}
+
+	clusterPredicates := []predicate.Funcs{
+		predicates.ResourceNotPaused(log),
+		predicates.ResourceHasFilterLabel(log, r.WatchFilterValue),
+		gated(turtlespredicates.ClusterWithoutImportedAnnotation(log)),
+		gated(turtlespredicates.ClusterOrNamespaceWithImportLabel(ctx, log, r.Client, r.importLabel())),
+	}
+
+	if len(r.WatchNamespaces) > 0 {
+		clusterPredicates = append(clusterPredicates, gated(turtlespredicates.ClusterInNamespaces(log, r.WatchNamespaces)))
+	}
+
+	if r.ClusterSelector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(r.ClusterSelector)
+		if err != nil {
+			return nil, fmt.Errorf("parsing cluster selector: %w", err)
+		}
+
+		clusterPredicates = append(clusterPredicates, gated(turtlespredicates.ClusterMatchingSelector(log, selector)))
+	}
+
+	// clusters are created eagerly in Rancher, before their control plane is ready
+	if !r.EagerCreate {
+		clusterPredicates = append(clusterPredicates,
+			gated(turtlespredicates.ClusterWithReadyControlPlane(log, r.ControlPlaneReadiness)))
+	}
+
+	return clusterPredicates, nil
+}
+
 // +kubebuilder:rbac:groups="",resources=secrets;events;configmaps,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=coordination.k8s.io,resources=leases,verbs=get;create;update
 // +kubebuilder:rbac:groups=cluster.x-k8s.io,resources=clusters;clusters/status,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups=cluster.x-k8s.io,resources=machinepools,verbs=get;list;watch
 // +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=*,verbs=get;list;watch;create;update;patch;delete
-// +kubebuilder:rbac:groups=provisioning.cattle.io,resources=clusters;clusters/status,verbs=get;list;watch;create;update;delete;patch
-// +kubebuilder:rbac:groups=management.cattle.io,resources=clusterregistrationtokens;clusterregistrationtokens/status,verbs=get;list;watch
+// +kubebuilder:rbac:groups=provisioning.cattle.io,resources=clusters;clusters/status,verbs=get;list;watch;create;update;delete;deletecollection;patch
+// +kubebuilder:rbac:groups=management.cattle.io,resources=clusterregistrationtokens;clusterregistrationtokens/status,verbs=get;list;watch;create;delete
+// +kubebuilder:rbac:groups=authentication.k8s.io,resources=tokenreviews,verbs=create
+// +kubebuilder:rbac:groups=authorization.k8s.io,resources=subjectaccessreviews,verbs=create
 
 // Reconcile reconciles a CAPI cluster, creating a Rancher cluster if needed and applying the import manifests.
 func (r *CAPIImportReconciler) Reconcile(ctx context.Context, req ctrl.Request) (res ctrl.Result, reterr error) {
 	log := log.FromContext(ctx)
 	log.Info("Reconciling CAPI cluster")
 
+	r.reconciles.start(req.NamespacedName, r.now())
+	defer r.reconciles.done(req.NamespacedName)
+
 	capiCluster := &clusterv1.Cluster{}
 	if err := r.Client.Get(ctx, req.NamespacedName, capiCluster); err != nil {
 		if apierrors.IsNotFound(err) {
-			return ctrl.Result{Requeue: true}, nil
+			r.remoteClients.evict(req.NamespacedName)
+			r.reconciles.forget(req.NamespacedName)
+			r.requeues.reset(req.NamespacedName)
+			r.managed.track(req.NamespacedName, false)
+			r.forgetReapply(req.NamespacedName)
+
+			if err := r.forgetManifest(ctx, req.NamespacedName); err != nil {
+				return ctrl.Result{}, err
+			}
+
+			return ctrl.Result{}, nil
 		}
 
-		return ctrl.Result{Requeue: true}, err
+		return ctrl.Result{}, err
 	}
 
-	patchBase := client.MergeFromWithOptions(capiCluster.DeepCopy(), client.MergeFromWithOptimisticLock{})
+	// The reconcile functions log with the context logger, carrying the same keys for every cluster.
+	log = log.WithValues("cluster", capiCluster.Name, "namespace", capiCluster.Namespace)
+	ctx = ctrl.LoggerInto(ctx, log)
 
-	log = log.WithValues("cluster", capiCluster.Name)
+	// A paused cluster is left untouched, e.g. while an operator debugs its import. This should never be true as the
+	// predicates do the filtering, unless the cluster was paused after the request was queued.
+	if capiannotations.HasPaused(capiCluster) {
+		log.Info("CAPI cluster is paused, skipping reconciliation")
+		return ctrl.Result{}, nil
+	}
+
+	original := capiCluster.DeepCopy()
+
+	// A cluster being deleted is never imported, even when it is first seen during its teardown, e.g. after a
+	// restart of the controller. Only the cleanup of its linked Rancher cluster runs.
+	if !capiCluster.DeletionTimestamp.IsZero() {
+		log.Info("CAPI cluster is being deleted, skipping import")
+
+		r.managed.track(req.NamespacedName, false)
+
+		var errs []error
+
+		if err := r.reconcileDeleting(ctx, capiCluster); err != nil {
+			errs = append(errs, fmt.Errorf("error cleaning up deleted cluster: %w", err))
+		}
+
+		if err := patchCluster(ctx, r.Client, capiCluster, original); err != nil {
+			errs = append(errs, err)
+		}
+
+		return ctrl.Result{}, errorutils.NewAggregate(errs)
+	}
 
 	// Wait for controlplane to be ready. This should never be false as the predicates
-	// do the filtering.
-	if !capiCluster.Status.ControlPlaneReady && !conditions.IsTrue(capiCluster, clusterv1.ControlPlaneReadyCondition) {
+	// do the filtering, unless the Rancher cluster is created eagerly.
+	if ready, _ := r.ControlPlaneReadiness.Ready(capiCluster); !ready {
 		log.Info("clusters control plane is not ready, requeue")
-		return ctrl.Result{RequeueAfter: defaultRequeueDuration}, nil
+
+		markImportPendingOnControlPlane(capiCluster)
+		r.trackControlPlaneWait(capiCluster, false)
+		r.evaluateEligibility(ctx, capiCluster)
+
+		var errs []error
+
+		if r.EagerCreate {
+			if err := r.reconcileEagerCreate(ctx, capiCluster); err != nil {
+				errs = append(errs, fmt.Errorf("error creating rancher cluster eagerly: %w", err))
+			}
+		}
+
+		if err := patchCluster(ctx, r.Client, capiCluster, original); err != nil {
+			errs = append(errs, err)
+		}
+
+		if len(errs) > 0 {
+			return ctrl.Result{}, errorutils.NewAggregate(errs)
+		}
+
+		return r.requeueWaiting(capiCluster, turtlesv1.WaitingForControlPlaneReason), nil
 	}
 
+	conditions.Delete(capiCluster, turtlesv1.ImportPendingCondition)
+	r.trackControlPlaneWait(capiCluster, true)
+
 	// Collect errors as an aggregate to return together after all patches have been performed.
 	var errs []error
 
-	result, err := r.reconcile(ctx, capiCluster)
+	result, reconcileErr := r.reconcile(ctx, capiCluster)
+
+	result, err := r.trackImportAttempts(ctx, capiCluster, result, reconcileErr)
+	r.reconciles.observe(capiCluster, reconcileErr, r.now(), r.ImportBackoffInterval)
+
 	if err != nil {
 		errs = append(errs, fmt.Errorf("error reconciling cluster: %w", err))
 	}
 
-	if err := r.Client.Patch(ctx, capiCluster, patchBase); err != nil {
-		errs = append(errs, fmt.Errorf("failed to patch cluster: %w", err))
+	if err := patchCluster(ctx, r.Client, capiCluster, original); err != nil {
+		errs = append(errs, err)
 	}
 
 	if len(errs) > 0 {
@@ -171,19 +391,38 @@ func (r *CAPIImportReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 func (r *CAPIImportReconciler) reconcile(ctx context.Context, capiCluster *clusterv1.Cluster) (ctrl.Result, error) {
 	log := log.FromContext(ctx)
 
+	rancherClusterKey, err := r.rancherClusterKey(capiCluster)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
 	// fetch the rancher cluster
 	rancherCluster := &provisioningv1.Cluster{ObjectMeta: metav1.ObjectMeta{
-		Namespace: capiCluster.Namespace,
-		Name:      turtlesnaming.Name(capiCluster.Name).ToRancherName(),
+		Namespace: rancherClusterKey.Namespace,
+		Name:      rancherClusterKey.Name,
 	}}
 
-	err := r.RancherClient.Get(ctx, client.ObjectKeyFromObject(rancherCluster), rancherCluster)
+	log = log.WithValues("rancherCluster", client.ObjectKeyFromObject(rancherCluster).String())
+	ctx = ctrl.LoggerInto(ctx, log)
+
+	err = r.getRancherCluster(ctx, rancherCluster)
 	if client.IgnoreNotFound(err) != nil {
-		log.Error(err, fmt.Sprintf("Unable to fetch rancher cluster %s", client.ObjectKeyFromObject(rancherCluster)))
-		return ctrl.Result{Requeue: true}, err
+		log.Error(err, "Unable to fetch rancher cluster")
+		return ctrl.Result{}, err
 	}
 
 	if !rancherCluster.ObjectMeta.DeletionTimestamp.IsZero() {
+		markRancherClusterUnlinked(capiCluster, rancherCluster)
+
+		if err := r.syncDeletionProtection(ctx, rancherCluster); err != nil {
+			return ctrl.Result{}, err
+		}
+
+		if controllerutil.ContainsFinalizer(rancherCluster, deletionProtectionFinalizer) {
+			log.Info("Rancher cluster deletion is blocked by the deletion protection")
+			return ctrl.Result{}, nil
+		}
+
 		return r.reconcileDelete(ctx, capiCluster)
 	}
 
@@ -195,93 +434,71 @@ func (r *CAPIImportReconciler) reconcileNormal(ctx context.Context, capiCluster
 ) (ctrl.Result, error) {
 	log := log.FromContext(ctx)
 
-	err := r.RancherClient.Get(ctx, client.ObjectKeyFromObject(rancherCluster), rancherCluster)
-	if apierrors.IsNotFound(err) {
-		shouldImport, err := util.ShouldAutoImport(ctx, log, r.Client, capiCluster, importLabelName)
-		if err != nil {
-			return ctrl.Result{}, err
-		}
-
-		if !shouldImport {
-			log.Info("not auto importing cluster as namespace or cluster isn't marked auto import")
-			return ctrl.Result{}, nil
-		}
-
-		if err := r.RancherClient.Create(ctx, &provisioningv1.Cluster{
-			ObjectMeta: metav1.ObjectMeta{
-				Name:      turtlesnaming.Name(capiCluster.Name).ToRancherName(),
-				Namespace: capiCluster.Namespace,
-				OwnerReferences: []metav1.OwnerReference{{
-					APIVersion: clusterv1.GroupVersion.String(),
-					Kind:       clusterv1.ClusterKind,
-					Name:       capiCluster.Name,
-					UID:        capiCluster.UID,
-				}},
-				Labels: map[string]string{
-					ownedLabelName: "",
-				},
-			},
-		}); err != nil {
-			return ctrl.Result{}, fmt.Errorf("error creating rancher cluster: %w", err)
-		}
+	r.evaluateEligibility(ctx, capiCluster)
 
-		return ctrl.Result{Requeue: true}, nil
+	err := r.getRancherCluster(ctx, rancherCluster)
+	if apierrors.IsNotFound(err) {
+		return r.createRancherCluster(ctx, capiCluster, rancherCluster)
 	}
 
 	if err != nil {
-		log.Error(err, fmt.Sprintf("Unable to fetch rancher cluster %s", client.ObjectKeyFromObject(rancherCluster)))
+		log.Error(err, "Unable to fetch rancher cluster")
 
 		return ctrl.Result{}, err
 	}
 
-	if rancherCluster.Status.ClusterName == "" {
-		log.Info("cluster name not set yet, requeue")
-		return ctrl.Result{Requeue: true}, nil
+	if err := r.checkRancherClusterOwner(ctx, capiCluster, rancherCluster); err != nil {
+		return ctrl.Result{}, err
 	}
 
-	log.Info("found cluster name", "name", rancherCluster.Status.ClusterName)
+	status := r.rancherClusterStatus(rancherCluster)
 
-	if rancherCluster.Status.AgentDeployed {
-		log.Info("agent already deployed, no action needed")
-		return ctrl.Result{}, nil
-	}
+	r.managed.track(client.ObjectKeyFromObject(capiCluster), true)
+	r.trackImportDuration(ctx, capiCluster, status.Ready)
+	syncRancherLinkage(capiCluster, rancherCluster, status, r.MirrorRancherReadiness)
 
-	// get the registration manifest
-	manifest, err := getClusterRegistrationManifest(ctx, rancherCluster.Status.ClusterName, capiCluster.Namespace, r.RancherClient, r.InsecureSkipVerify)
-	if err != nil {
+	if err := r.syncRancherCluster(ctx, capiCluster, rancherCluster); err != nil {
 		return ctrl.Result{}, err
 	}
 
-	if manifest == "" {
-		log.Info("Import manifest URL not set yet, requeue")
-		return ctrl.Result{Requeue: true}, nil
+	if suspended, err := r.reconcileSuspension(ctx, capiCluster, rancherCluster); err != nil || suspended {
+		return ctrl.Result{}, err
 	}
 
-	log.Info("Creating import manifest")
+	if status.ClusterName == "" {
+		log.Info("cluster name not set yet, requeue")
+		conditions.MarkFalse(capiCluster, turtlesv1.RegistrationTokenReadyCondition, turtlesv1.WaitingForClusterNameReason,
+			clusterv1.ConditionSeverityInfo, "Waiting for Rancher to assign a cluster name to %s", client.ObjectKeyFromObject(rancherCluster))
 
-	remoteClient, err := r.remoteClientGetter(ctx, capiCluster.Name, r.Client, client.ObjectKeyFromObject(capiCluster))
-	if err != nil {
-		return ctrl.Result{}, fmt.Errorf("getting remote cluster client: %w", err)
+		return r.requeueWaitingOnRancher(capiCluster, turtlesv1.WaitingForClusterNameReason), nil
 	}
 
-	if err := createImportManifest(ctx, remoteClient, strings.NewReader(manifest)); err != nil {
-		return ctrl.Result{}, fmt.Errorf("creating import manifest: %w", err)
-	}
+	log = log.WithValues("clusterName", status.ClusterName)
+	ctx = ctrl.LoggerInto(ctx, log)
 
-	log.Info("Successfully applied import manifest")
+	log.Info("found cluster name")
 
-	return ctrl.Result{}, nil
+	if status.AgentDeployed && !relinkedImportPending(capiCluster) {
+		log.Info("agent already deployed, verifying registration")
+
+		if res, reapplied, err := r.reconcileAgentPresence(ctx, capiCluster, rancherCluster); err != nil || reapplied {
+			return res, err
+		}
+
+		res, err := r.verifyRegistration(ctx, capiCluster, rancherCluster)
+
+		return r.requeueAgentHealth(res), err
+	}
+
+	return r.reconcileImport(ctx, capiCluster, rancherCluster)
 }
 
 func (r *CAPIImportReconciler) rancherClusterToCapiCluster(ctx context.Context, clusterPredicate predicate.Funcs) handler.MapFunc {
 	log := log.FromContext(ctx)
 
 	return func(_ context.Context, o client.Object) []ctrl.Request {
-		capiCluster := &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{
-			Name:      turtlesnaming.Name(o.GetName()).ToCapiName(),
-			Namespace: o.GetNamespace(),
-		}}
-		if err := r.Client.Get(ctx, client.ObjectKeyFromObject(capiCluster), capiCluster); err != nil {
+		capiCluster := &clusterv1.Cluster{}
+		if err := r.Client.Get(ctx, linkedCapiCluster(o), capiCluster); err != nil {
 			if !apierrors.IsNotFound(err) {
 				log.Error(err, "getting capi cluster")
 			}
@@ -297,14 +514,42 @@ func (r *CAPIImportReconciler) rancherClusterToCapiCluster(ctx context.Context,
 	}
 }
 
+// importLabel returns the label marking the CAPI clusters, or their namespaces, to import.
+func (r *CAPIImportReconciler) importLabel() string {
+	if r.ImportLabel != "" {
+		return r.ImportLabel
+	}
+
+	return importLabelName
+}
+
+// ownedLabel returns the label marking the Rancher clusters and objects created by the controller.
+func (r *CAPIImportReconciler) ownedLabel() string {
+	if r.OwnedLabel != "" {
+		return r.OwnedLabel
+	}
+
+	return ownedLabelName
+}
+
+// remoteClient returns the client of the downstream cluster, from the cache when remote clients are cached.
+func (r *CAPIImportReconciler) remoteClient(ctx context.Context, capiCluster *clusterv1.Cluster) (client.Client, error) {
+	if !r.CacheRemoteClients {
+		return r.remoteClientGetter(ctx, capiCluster.Name, r.Client, client.ObjectKeyFromObject(capiCluster))
+	}
+
+	return r.remoteClients.get(ctx, capiCluster, r.remoteClientGetter, r.Client)
+}
+
 func (r *CAPIImportReconciler) reconcileDelete(ctx context.Context, capiCluster *clusterv1.Cluster) (ctrl.Result, error) {
 	log := log.FromContext(ctx)
 	log.Info("Reconciling rancher cluster deletion")
 
+	r.remoteClients.evict(client.ObjectKeyFromObject(capiCluster))
+	r.managed.track(client.ObjectKeyFromObject(capiCluster), false)
+
 	// If the Rancher Cluster was already imported, then annotate the CAPI cluster so that we don't auto-import again.
-	log.Info(fmt.Sprintf("Rancher cluster is being removed, annotating CAPI cluster %s with %s",
-		capiCluster.Name,
-		turtlesannotations.ClusterImportedAnnotation))
+	log.Info("Rancher cluster is being removed, annotating CAPI cluster", "annotation", turtlesannotations.ClusterImportedAnnotation)
 
 	annotations := capiCluster.GetAnnotations()
 	if annotations == nil {
@@ -314,5 +559,8 @@ func (r *CAPIImportReconciler) reconcileDelete(ctx context.Context, capiCluster
 	annotations[turtlesannotations.ClusterImportedAnnotation] = "true"
 	capiCluster.SetAnnotations(annotations)
 
+	// There is no linked Rancher cluster left to delete along with the CAPI cluster.
+	controllerutil.RemoveFinalizer(capiCluster, managementv3.CapiClusterFinalizer)
+
 	return ctrl.Result{}, nil
 }
@@ -0,0 +1,121 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	turtlesv1 "github.com/rancher/turtles/api/v1alpha1"
+)
+
+// AgentPolicy selects how the import handles a cattle-cluster-agent already deployed on the downstream cluster, e.g.
+// by a prior manual import.
+type AgentPolicy string
+
+const (
+	// AgentPolicyReapply always applies the import manifest, replacing an existing agent.
+	AgentPolicyReapply AgentPolicy = "reapply"
+
+	// AgentPolicyAdopt adopts an existing healthy agent registered with the same Rancher server instead of applying
+	// the import manifest.
+	AgentPolicyAdopt AgentPolicy = "adopt"
+)
+
+// ParseAgentPolicy returns the agent policy with the given name. An empty name is the reapply policy.
+func ParseAgentPolicy(name string) (AgentPolicy, error) {
+	switch policy := AgentPolicy(name); policy {
+	case "":
+		return AgentPolicyReapply, nil
+	case AgentPolicyReapply, AgentPolicyAdopt:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unknown existing agent policy %q, must be %q or %q", name, AgentPolicyReapply, AgentPolicyAdopt)
+	}
+}
+
+// adoptExistingAgent adopts the cattle-cluster-agent of the downstream cluster instead of applying the import manifest
+// when the adopt policy is set and the agent is healthy and registered with the Rancher server of the manifest. The
+// adopted agent deployment is labeled as applied by turtles when LabelAdoptedAgent is set. It returns true if the
+// agent was adopted.
+func (r *CAPIImportReconciler) adoptExistingAgent(ctx context.Context, capiCluster *clusterv1.Cluster,
+	remoteClient client.Client, objs []*unstructured.Unstructured,
+) (bool, error) {
+	log := log.FromContext(ctx)
+
+	if r.ExistingAgentPolicy != AgentPolicyAdopt {
+		return false, nil
+	}
+
+	serverURL, err := manifestServerURL(objs)
+	if err != nil || serverURL == "" {
+		return false, err
+	}
+
+	agent := &appsv1.Deployment{}
+
+	err = remoteClient.Get(ctx, client.ObjectKey{Namespace: cattleSystemNamespace, Name: cattleClusterAgentName}, agent)
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, fmt.Errorf("getting cattle-cluster-agent deployment: %w", err)
+	}
+
+	if !sameServerURL(agentServerURL(&agent.Spec.Template.Spec), serverURL) {
+		return false, nil
+	}
+
+	reason, err := diagnoseAgent(ctx, remoteClient)
+	if err != nil {
+		return false, fmt.Errorf("diagnosing existing agent: %w", err)
+	}
+
+	if reason != "" {
+		log.Info("Existing agent is not healthy, applying import manifest", "reason", reason)
+		return false, nil
+	}
+
+	if r.LabelAdoptedAgent && agent.Labels[turtlesAppliedLabelName] != "true" {
+		patchBase := client.MergeFrom(agent.DeepCopy())
+
+		if agent.Labels == nil {
+			agent.Labels = map[string]string{}
+		}
+
+		agent.Labels[turtlesAppliedLabelName] = "true"
+
+		if err := remoteClient.Patch(ctx, agent, patchBase); err != nil {
+			return false, fmt.Errorf("labeling adopted cattle-cluster-agent deployment: %w", err)
+		}
+	}
+
+	log.Info("Adopted existing agent registered with the same Rancher, not applying import manifest", "server", serverURL)
+	r.recorder.Eventf(capiCluster, corev1.EventTypeNormal, turtlesv1.AgentAdoptedReason,
+		"Adopted the existing cattle-cluster-agent registered with %s", serverURL)
+
+	return true, nil
+}
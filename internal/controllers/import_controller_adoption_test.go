@@ -0,0 +1,168 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	turtlesv1 "github.com/rancher/turtles/api/v1alpha1"
+	provisioningv1 "github.com/rancher/turtles/internal/rancher/provisioning/v1"
+	"github.com/rancher/turtles/testutil"
+)
+
+var _ = Describe("existing agent adoption", func() {
+	var (
+		r              *CAPIImportReconciler
+		server         *testutil.ManifestServer
+		recorder       *record.FakeRecorder
+		remoteClient   client.Client
+		capiCluster    *clusterv1.Cluster
+		rancherCluster *provisioningv1.Cluster
+	)
+
+	existingAgent := func(serverURL string) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: cattleClusterAgentName, Namespace: cattleSystemNamespace},
+			Spec: appsv1.DeploymentSpec{
+				Template: corev1.PodTemplateSpec{
+					Spec: corev1.PodSpec{
+						Containers: []corev1.Container{{
+							Name: "cluster-register",
+							Env:  []corev1.EnvVar{{Name: cattleServerEnv, Value: serverURL}},
+						}},
+					},
+				},
+			},
+		}
+	}
+
+	configApplied := func() bool {
+		err := remoteClient.Get(ctx, client.ObjectKey{Namespace: cattleSystemNamespace, Name: "cattle-config"}, &corev1.ConfigMap{})
+		if apierrors.IsNotFound(err) {
+			return false
+		}
+
+		Expect(err).ToNot(HaveOccurred())
+
+		return true
+	}
+
+	storedAgent := func() *appsv1.Deployment {
+		agent := &appsv1.Deployment{}
+		Expect(remoteClient.Get(ctx, client.ObjectKey{Namespace: cattleSystemNamespace, Name: cattleClusterAgentName}, agent)).To(Succeed())
+
+		return agent
+	}
+
+	BeforeEach(func() {
+		server = testutil.NewManifestServer(agentManifest)
+		DeferCleanup(server.Close)
+
+		recorder = record.NewFakeRecorder(10)
+		remoteClient = fake.NewClientBuilder().WithScheme(scheme.Scheme).
+			WithObjects(existingAgent("https://rancher.example.com/"), agentPod(true)).Build()
+
+		r = &CAPIImportReconciler{
+			RancherClient: testutil.NewRancherClientBuilder().WithObjects(
+				testutil.RegistrationToken("c-m-test", "test-ns", server.URL),
+			).Build(),
+			recorder: recorder,
+			remoteClientGetter: func(_ context.Context, _ string, _ client.Client, _ client.ObjectKey) (client.Client, error) {
+				return remoteClient, nil
+			},
+			ApplyOptions: ApplyOptions{
+				ExistingAgentPolicy: AgentPolicyAdopt,
+			},
+		}
+
+		capiCluster = &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", Namespace: "test-ns"}}
+		rancherCluster = &provisioningv1.Cluster{Status: provisioningv1.ClusterStatus{ClusterName: "c-m-test"}}
+	})
+
+	It("should adopt a healthy agent registered with the same Rancher", func() {
+		applied, err := r.applyImportManifest(ctx, capiCluster, rancherCluster)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(applied).To(BeTrue())
+		Expect(configApplied()).To(BeFalse())
+
+		Expect(conditions.IsTrue(capiCluster, turtlesv1.ImportManifestAppliedCondition)).To(BeTrue())
+		Expect(recorder.Events).To(Receive(ContainSubstring(turtlesv1.AgentAdoptedReason)))
+		Expect(storedAgent().Labels).ToNot(HaveKey(turtlesAppliedLabelName))
+	})
+
+	It("should label the adopted agent when enabled", func() {
+		r.LabelAdoptedAgent = true
+
+		_, err := r.applyImportManifest(ctx, capiCluster, rancherCluster)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(configApplied()).To(BeFalse())
+		Expect(storedAgent().Labels).To(HaveKeyWithValue(turtlesAppliedLabelName, "true"))
+	})
+
+	It("should re-apply the import manifest in force mode", func() {
+		r.ExistingAgentPolicy = AgentPolicyReapply
+
+		applied, err := r.applyImportManifest(ctx, capiCluster, rancherCluster)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(applied).To(BeTrue())
+		Expect(configApplied()).To(BeTrue())
+		Expect(recorder.Events).ToNot(Receive(ContainSubstring(turtlesv1.AgentAdoptedReason)))
+	})
+
+	It("should apply the import manifest when the existing agent is not healthy", func() {
+		remoteClient = fake.NewClientBuilder().WithScheme(scheme.Scheme).
+			WithObjects(existingAgent("https://rancher.example.com"), agentPod(false)).Build()
+
+		_, err := r.applyImportManifest(ctx, capiCluster, rancherCluster)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(configApplied()).To(BeTrue())
+	})
+
+	It("should apply the import manifest when there is no existing agent", func() {
+		remoteClient = fake.NewClientBuilder().WithScheme(scheme.Scheme).Build()
+
+		_, err := r.applyImportManifest(ctx, capiCluster, rancherCluster)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(configApplied()).To(BeTrue())
+	})
+
+	It("should parse the existing agent policy", func() {
+		policy, err := ParseAgentPolicy("")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(policy).To(Equal(AgentPolicyReapply))
+
+		policy, err = ParseAgentPolicy("adopt")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(policy).To(Equal(AgentPolicyAdopt))
+
+		_, err = ParseAgentPolicy("replace")
+		Expect(err).To(MatchError(ContainSubstring("unknown existing agent policy")))
+	})
+})
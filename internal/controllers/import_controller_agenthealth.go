@@ -0,0 +1,183 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	turtlesv1 "github.com/rancher/turtles/api/v1alpha1"
+	provisioningv1 "github.com/rancher/turtles/internal/rancher/provisioning/v1"
+)
+
+// defaultAgentHealthInterval is the interval the presence of deployed agents is verified at when none is configured.
+const defaultAgentHealthInterval = 5 * time.Minute
+
+// agentAvailable returns whether the cattle-cluster-agent deployment is available on the downstream cluster, and the
+// reason it isn't otherwise.
+func (r *CAPIImportReconciler) agentAvailable(ctx context.Context, capiCluster *clusterv1.Cluster) (bool, string, error) {
+	remoteClient, err := r.remoteClient(ctx, capiCluster)
+	if err != nil {
+		return false, "", fmt.Errorf("getting remote cluster client: %w", err)
+	}
+
+	key := client.ObjectKey{Namespace: cattleSystemNamespace, Name: cattleClusterAgentName}
+	deployment := &appsv1.Deployment{}
+
+	err = remoteClient.Get(ctx, key, deployment)
+	if apierrors.IsNotFound(err) {
+		return false, fmt.Sprintf("deployment %s not found", key), nil
+	}
+
+	if err != nil {
+		return false, "", fmt.Errorf("getting agent deployment %s: %w", key, err)
+	}
+
+	for _, condition := range deployment.Status.Conditions {
+		if condition.Type != appsv1.DeploymentAvailable {
+			continue
+		}
+
+		if condition.Status == corev1.ConditionTrue {
+			return true, "", nil
+		}
+
+		return false, fmt.Sprintf("deployment %s is not available: %s", key, condition.Message), nil
+	}
+
+	return false, fmt.Sprintf("deployment %s has %d of %d replicas available", key,
+		deployment.Status.AvailableReplicas, deployment.Status.Replicas), nil
+}
+
+// checkAgentHealth reflects the availability of the cattle-cluster-agent deployment in the AgentHealthy condition,
+// once the import manifest was applied. It returns false with the requeue to wait for the agent while it is missing or
+// unavailable, the requeue interval growing with each consecutive wait.
+func (r *CAPIImportReconciler) checkAgentHealth(ctx context.Context, capiCluster *clusterv1.Cluster) (ctrl.Result, bool, error) {
+	if !r.CheckAgentHealth {
+		return ctrl.Result{}, true, nil
+	}
+
+	available, reason, err := r.agentAvailable(ctx, capiCluster)
+	if err != nil {
+		return ctrl.Result{}, false, err
+	}
+
+	if !available {
+		log.FromContext(ctx).Info("waiting for the agent to be available, requeue", "reason", reason)
+
+		conditions.MarkFalse(capiCluster, turtlesv1.AgentHealthyCondition, turtlesv1.AgentUnavailableReason,
+			clusterv1.ConditionSeverityWarning, "%s", reason)
+
+		return r.requeueWaiting(capiCluster, turtlesv1.AgentUnavailableReason), false, nil
+	}
+
+	conditions.MarkTrue(capiCluster, turtlesv1.AgentHealthyCondition)
+
+	return ctrl.Result{}, true, nil
+}
+
+// reconcileAgentPresence re-applies the import manifest when the cattle-cluster-agent deployment was deleted from the
+// downstream cluster while Rancher still reports the agent as deployed, which would otherwise leave the cluster
+// disconnected. It returns true with the requeue when the manifest was, or is being, re-applied.
+func (r *CAPIImportReconciler) reconcileAgentPresence(ctx context.Context, capiCluster *clusterv1.Cluster,
+	rancherCluster *provisioningv1.Cluster,
+) (ctrl.Result, bool, error) {
+	log := log.FromContext(ctx)
+
+	if !r.ReconcileAgentHealth {
+		return ctrl.Result{}, false, nil
+	}
+
+	remoteClient, err := r.remoteClient(ctx, capiCluster)
+	if err != nil {
+		return ctrl.Result{}, false, fmt.Errorf("getting remote cluster client: %w", err)
+	}
+
+	key := client.ObjectKey{Namespace: cattleSystemNamespace, Name: cattleClusterAgentName}
+
+	err = remoteClient.Get(ctx, key, &appsv1.Deployment{})
+	if err == nil {
+		return ctrl.Result{}, false, nil
+	}
+
+	if !apierrors.IsNotFound(err) {
+		return ctrl.Result{}, false, fmt.Errorf("getting agent deployment %s: %w", key, err)
+	}
+
+	if !r.allowReapply(client.ObjectKeyFromObject(capiCluster)) {
+		log.Info("Skipping import manifest re-application, the cluster was re-applied less than the minimum interval ago",
+			"interval", r.MinReapplyInterval)
+		reappliesSuppressed.WithLabelValues(clusterProvider(capiCluster)).Inc()
+
+		return ctrl.Result{RequeueAfter: r.agentHealthInterval()}, true, nil
+	}
+
+	message := fmt.Sprintf("Deployment %s was deleted from the downstream cluster, re-applying the import manifest", key)
+	log.Info("Agent is missing from the downstream cluster, re-applying import manifest")
+	r.recorder.Event(capiCluster, corev1.EventTypeWarning, turtlesv1.AgentMissingReason, message)
+
+	applied, err := r.applyImportManifest(ctx, capiCluster, rancherCluster)
+	if err != nil {
+		return ctrl.Result{}, false, fmt.Errorf("re-applying import manifest: %w", err)
+	}
+
+	if !applied {
+		log.Info("Import manifest URL not set yet, requeue")
+		return r.requeueWaitingOnRancher(capiCluster, turtlesv1.WaitingForManifestURLReason), true, nil
+	}
+
+	// Reset the condition so the registration window starts from this apply.
+	conditions.Delete(capiCluster, turtlesv1.RancherAgentRegisteredCondition)
+	conditions.MarkFalse(capiCluster, turtlesv1.RancherAgentRegisteredCondition, turtlesv1.WaitingForAgentRegistrationReason,
+		clusterv1.ConditionSeverityInfo, "Import manifest re-applied as the agent was missing, waiting for the agent to register with Rancher")
+
+	return ctrl.Result{RequeueAfter: r.agentHealthInterval()}, true, nil
+}
+
+// requeueAgentHealth makes sure a cluster with a deployed agent is reconciled again within the agent health interval,
+// for its agent presence to be verified.
+func (r *CAPIImportReconciler) requeueAgentHealth(res ctrl.Result) ctrl.Result {
+	if !r.ReconcileAgentHealth {
+		return res
+	}
+
+	if interval := r.agentHealthInterval(); res.RequeueAfter == 0 || res.RequeueAfter > interval {
+		res.RequeueAfter = interval
+	}
+
+	return res
+}
+
+// agentHealthInterval returns the interval the presence of deployed agents is verified at.
+func (r *CAPIImportReconciler) agentHealthInterval() time.Duration {
+	if r.AgentHealthInterval > 0 {
+		return r.AgentHealthInterval
+	}
+
+	return defaultAgentHealthInterval
+}
@@ -0,0 +1,245 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	clocktesting "k8s.io/utils/clock/testing"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	turtlesv1 "github.com/rancher/turtles/api/v1alpha1"
+	provisioningv1 "github.com/rancher/turtles/internal/rancher/provisioning/v1"
+	"github.com/rancher/turtles/testutil"
+)
+
+var _ = Describe("agent health", func() {
+	var (
+		r              *CAPIImportReconciler
+		remoteClient   client.Client
+		capiCluster    *clusterv1.Cluster
+		rancherCluster *provisioningv1.Cluster
+	)
+
+	agentDeployment := func(available bool) *appsv1.Deployment {
+		deployment := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{
+			Name:      cattleClusterAgentName,
+			Namespace: cattleSystemNamespace,
+		}}
+
+		deployment.Status.Replicas = 1
+		condition := appsv1.DeploymentCondition{
+			Type:    appsv1.DeploymentAvailable,
+			Status:  corev1.ConditionFalse,
+			Message: "Deployment does not have minimum availability.",
+		}
+
+		if available {
+			deployment.Status.AvailableReplicas = 1
+			condition.Status = corev1.ConditionTrue
+		}
+
+		deployment.Status.Conditions = []appsv1.DeploymentCondition{condition}
+
+		return deployment
+	}
+
+	BeforeEach(func() {
+		server := testutil.NewManifestServer(manifestWithServerFields)
+		DeferCleanup(server.Close)
+
+		capiCluster = &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-cluster",
+			Namespace: "test-ns",
+			Labels:    map[string]string{importLabelName: "true"},
+		}}
+		rancherCluster = &provisioningv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test-cluster-capi", Namespace: "test-ns"}}
+		remoteClient = fake.NewClientBuilder().WithScheme(scheme.Scheme).Build()
+
+		r = &CAPIImportReconciler{
+			Client: fake.NewClientBuilder().WithScheme(scheme.Scheme).Build(),
+			RancherClient: testutil.NewRancherClientBuilder().
+				WithCluster(rancherCluster.Name, rancherCluster.Namespace, testutil.ClusterStateNameSet, server.URL).
+				WithObjects(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test-ns"}}).
+				Build(),
+			CheckAgentHealth: true,
+			recorder:         record.NewFakeRecorder(10),
+			remoteClientGetter: func(_ context.Context, _ string, _ client.Client, _ client.ObjectKey) (client.Client, error) {
+				return remoteClient, nil
+			},
+		}
+	})
+
+	reconcile := func() ctrl.Result {
+		res, err := r.reconcileNormal(ctx, capiCluster, rancherCluster)
+		Expect(err).ToNot(HaveOccurred())
+
+		return res
+	}
+
+	It("should report a missing agent deployment and requeue with backoff", func() {
+		Expect(reconcile().RequeueAfter).To(Equal(minRequeueDuration))
+		Expect(conditions.IsTrue(capiCluster, turtlesv1.ImportManifestAppliedCondition)).To(BeTrue())
+
+		condition := conditions.Get(capiCluster, turtlesv1.AgentHealthyCondition)
+		Expect(condition).ToNot(BeNil())
+		Expect(condition.Status).To(Equal(corev1.ConditionFalse))
+		Expect(condition.Reason).To(Equal(turtlesv1.AgentUnavailableReason))
+		Expect(condition.Message).To(ContainSubstring("not found"))
+
+		Expect(reconcile().RequeueAfter).To(Equal(2 * minRequeueDuration))
+	})
+
+	It("should report an unavailable agent deployment", func() {
+		Expect(remoteClient.Create(ctx, agentDeployment(false))).To(Succeed())
+
+		Expect(reconcile().RequeueAfter).To(Equal(minRequeueDuration))
+		Expect(conditions.IsFalse(capiCluster, turtlesv1.AgentHealthyCondition)).To(BeTrue())
+		Expect(conditions.GetMessage(capiCluster, turtlesv1.AgentHealthyCondition)).To(ContainSubstring("minimum availability"))
+	})
+
+	It("should mark the agent healthy once its deployment is available", func() {
+		Expect(remoteClient.Create(ctx, agentDeployment(true))).To(Succeed())
+
+		reconcile()
+		Expect(conditions.IsTrue(capiCluster, turtlesv1.AgentHealthyCondition)).To(BeTrue())
+	})
+
+	It("should wait for the agent without applying the manifest again", func() {
+		reconcile()
+		Expect(conditions.IsFalse(capiCluster, turtlesv1.AgentHealthyCondition)).To(BeTrue())
+
+		Expect(remoteClient.Delete(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: cattleSystemNamespace}})).To(Succeed())
+		Expect(remoteClient.Create(ctx, agentDeployment(true))).To(Succeed())
+
+		reconcile()
+		Expect(conditions.IsTrue(capiCluster, turtlesv1.AgentHealthyCondition)).To(BeTrue())
+
+		err := remoteClient.Get(ctx, client.ObjectKey{Name: cattleSystemNamespace}, &corev1.Namespace{})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should not check the agent when disabled", func() {
+		r.CheckAgentHealth = false
+
+		reconcile()
+		Expect(conditions.Has(capiCluster, turtlesv1.AgentHealthyCondition)).To(BeFalse())
+	})
+})
+
+var _ = Describe("agent re-import", func() {
+	var (
+		r              *CAPIImportReconciler
+		recorder       *record.FakeRecorder
+		remoteClient   client.Client
+		capiCluster    *clusterv1.Cluster
+		rancherCluster *provisioningv1.Cluster
+	)
+
+	BeforeEach(func() {
+		server := testutil.NewManifestServer(manifestWithServerFields)
+		DeferCleanup(server.Close)
+
+		capiCluster = &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-cluster",
+			Namespace: "test-ns",
+			Labels:    map[string]string{importLabelName: "true"},
+		}}
+		rancherCluster = &provisioningv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test-cluster-capi", Namespace: "test-ns"}}
+		remoteClient = fake.NewClientBuilder().WithScheme(scheme.Scheme).Build()
+		recorder = record.NewFakeRecorder(10)
+
+		r = &CAPIImportReconciler{
+			Client: fake.NewClientBuilder().WithScheme(scheme.Scheme).Build(),
+			RancherClient: testutil.NewRancherClientBuilder().
+				WithCluster(rancherCluster.Name, rancherCluster.Namespace, testutil.ClusterStateReady, server.URL).
+				Build(),
+			ReconcileAgentHealth: true,
+			recorder:             recorder,
+			remoteClientGetter: func(_ context.Context, _ string, _ client.Client, _ client.ObjectKey) (client.Client, error) {
+				return remoteClient, nil
+			},
+			TimingOptions: TimingOptions{
+				AgentHealthInterval:     time.Minute,
+				RegistrationCheckWindow: time.Hour,
+			},
+		}
+	})
+
+	reconcile := func() ctrl.Result {
+		res, err := r.reconcileNormal(ctx, capiCluster, rancherCluster)
+		Expect(err).ToNot(HaveOccurred())
+
+		return res
+	}
+
+	manifestApplied := func() bool {
+		return remoteClient.Get(ctx, client.ObjectKey{Name: cattleSystemNamespace}, &corev1.Namespace{}) == nil
+	}
+
+	It("should not re-apply the manifest when the agent is present downstream", func() {
+		Expect(remoteClient.Create(ctx, &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{
+			Name:      cattleClusterAgentName,
+			Namespace: cattleSystemNamespace,
+		}})).To(Succeed())
+
+		Expect(reconcile().RequeueAfter).To(Equal(time.Minute))
+		Expect(manifestApplied()).To(BeFalse())
+		Expect(conditions.IsTrue(capiCluster, turtlesv1.RancherAgentRegisteredCondition)).To(BeTrue())
+		Expect(recorder.Events).To(BeEmpty())
+	})
+
+	It("should re-apply the manifest when the agent is missing downstream", func() {
+		Expect(reconcile().RequeueAfter).To(Equal(time.Minute))
+		Expect(manifestApplied()).To(BeTrue())
+
+		condition := conditions.Get(capiCluster, turtlesv1.RancherAgentRegisteredCondition)
+		Expect(condition).ToNot(BeNil())
+		Expect(condition.Reason).To(Equal(turtlesv1.WaitingForAgentRegistrationReason))
+		Expect(recorder.Events).To(Receive(ContainSubstring(turtlesv1.AgentMissingReason)))
+	})
+
+	It("should throttle the re-applications with the minimum re-apply interval", func() {
+		r.MinReapplyInterval = time.Hour
+		r.clock = clocktesting.NewFakeClock(time.Now())
+
+		reconcile()
+		Expect(remoteClient.Delete(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: cattleSystemNamespace}})).To(Succeed())
+
+		Expect(reconcile().RequeueAfter).To(Equal(time.Minute))
+		Expect(manifestApplied()).To(BeFalse())
+	})
+
+	It("should not check the agent when disabled", func() {
+		r.ReconcileAgentHealth = false
+
+		Expect(reconcile().RequeueAfter).To(BeZero())
+		Expect(manifestApplied()).To(BeFalse())
+	})
+})
@@ -0,0 +1,133 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+
+	provisioningv1 "github.com/rancher/turtles/internal/rancher/provisioning/v1"
+	turtlesannotations "github.com/rancher/turtles/util/annotations"
+)
+
+// validateAnnotationSync makes sure no annotation is mirrored in both directions, which would make the two
+// clusters fight over its value.
+func validateAnnotationSync(toRancher, fromRancher []string) error {
+	for _, key := range toRancher {
+		if metadataKeyAllowed(fromRancher, key) {
+			return fmt.Errorf("annotation %s can't be mirrored in both directions", key)
+		}
+	}
+
+	for _, key := range fromRancher {
+		if metadataKeyAllowed(toRancher, key) {
+			return fmt.Errorf("annotation %s can't be mirrored in both directions", key)
+		}
+	}
+
+	return nil
+}
+
+// metadataKeyAllowed returns whether the label or annotation key is in the list of keys. An entry ending with * allows
+// all the keys starting with its prefix.
+func metadataKeyAllowed(keys []string, key string) bool {
+	for _, allowed := range keys {
+		if prefix, ok := strings.CutSuffix(allowed, "*"); ok && strings.HasPrefix(key, prefix) || allowed == key {
+			return true
+		}
+	}
+
+	return false
+}
+
+// mirrorMetadata copies the allowed entries from source to destination, removing the allowed ones missing on the
+// source. The reserved keys are never touched. It returns the destination, allocated when nil, and true only if it was
+// changed.
+func mirrorMetadata(source, destination map[string]string, keys []string, reserved ...string) (map[string]string, bool) {
+	if destination == nil {
+		destination = map[string]string{}
+	}
+
+	changed := false
+
+	for key, value := range source {
+		if !metadataKeyAllowed(keys, key) || slices.Contains(reserved, key) {
+			continue
+		}
+
+		if current, exists := destination[key]; !exists || current != value {
+			destination[key] = value
+			changed = true
+		}
+	}
+
+	for key := range destination {
+		if _, ok := source[key]; ok || !metadataKeyAllowed(keys, key) || slices.Contains(reserved, key) {
+			continue
+		}
+
+		delete(destination, key)
+
+		changed = true
+	}
+
+	return destination, changed
+}
+
+// mirrorAnnotations copies the allowed annotations from source to destination, removing the ones missing on the source.
+// The reserved annotations are never touched. It returns true only if the destination annotations were changed.
+func mirrorAnnotations(source, destination client.Object, keys []string, reserved ...string) bool {
+	annotations, changed := mirrorMetadata(source.GetAnnotations(), destination.GetAnnotations(), keys, reserved...)
+	if changed {
+		destination.SetAnnotations(annotations)
+	}
+
+	return changed
+}
+
+// syncAnnotations mirrors the configured annotations between the CAPI cluster and its Rancher cluster. The CAPI cluster
+// is only changed in memory and persisted with the rest of the reconcile patch, while the Rancher cluster is patched
+// only when a mirrored value actually differs, so the watches on both clusters settle instead of looping.
+func (r *CAPIImportReconciler) syncAnnotations(ctx context.Context, capiCluster *clusterv1.Cluster,
+	rancherCluster *provisioningv1.Cluster,
+) error {
+	log := log.FromContext(ctx)
+
+	if mirrorAnnotations(rancherCluster, capiCluster, r.AnnotationsFromRancher, turtlesannotations.RancherClusterAnnotation) {
+		log.V(4).Info("mirrored annotations from Rancher cluster")
+	}
+
+	patchBase := client.MergeFrom(rancherCluster.DeepCopy())
+	if !mirrorAnnotations(capiCluster, rancherCluster, r.AnnotationsToRancher, turtlesannotations.ManagedAccessLabelsAnnotation) {
+		return nil
+	}
+
+	if err := r.RancherClient.Patch(ctx, rancherCluster, patchBase); err != nil {
+		return fmt.Errorf("patching Rancher cluster annotations: %w", err)
+	}
+
+	log.V(4).Info("mirrored annotations to Rancher cluster")
+
+	return nil
+}
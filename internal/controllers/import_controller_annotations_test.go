@@ -0,0 +1,233 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	provisioningv1 "github.com/rancher/turtles/internal/rancher/provisioning/v1"
+	turtlesannotations "github.com/rancher/turtles/util/annotations"
+	turtlesnaming "github.com/rancher/turtles/util/naming"
+)
+
+var _ = Describe("sync annotations", func() {
+	var (
+		r              *CAPIImportReconciler
+		patches        int
+		capiCluster    *clusterv1.Cluster
+		rancherCluster *provisioningv1.Cluster
+	)
+
+	BeforeEach(func() {
+		patches = 0
+
+		capiCluster = &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{
+			Name:        "test-cluster",
+			Namespace:   "test-ns",
+			Annotations: map[string]string{"example.com/team": "platform"},
+		}}
+
+		rancherCluster = &provisioningv1.Cluster{ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-cluster-capi",
+			Namespace: "test-ns",
+			Annotations: map[string]string{
+				"example.com/rancher-id":    "c-m-abcdef",
+				"example.com/dashboard-url": "https://rancher.example.com/dashboard/c/c-m-abcdef",
+				"example.com/unrelated":     "ignored",
+			},
+		}}
+
+		rancherClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(rancherCluster).
+			WithInterceptorFuncs(interceptor.Funcs{
+				Patch: func(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+					patches++
+					return c.Patch(ctx, obj, patch, opts...)
+				},
+			}).Build()
+
+		r = &CAPIImportReconciler{
+			RancherClient: rancherClient,
+			RancherClusterOptions: RancherClusterOptions{
+				AnnotationsToRancher:   []string{"example.com/team"},
+				AnnotationsFromRancher: []string{"example.com/rancher-id", "example.com/dashboard-url"},
+			},
+		}
+	})
+
+	It("should mirror allowed Rancher cluster annotations onto the CAPI cluster", func() {
+		Expect(r.syncAnnotations(ctx, capiCluster, rancherCluster)).To(Succeed())
+
+		Expect(capiCluster.Annotations).To(HaveKeyWithValue("example.com/rancher-id", "c-m-abcdef"))
+		Expect(capiCluster.Annotations).To(HaveKeyWithValue("example.com/dashboard-url", "https://rancher.example.com/dashboard/c/c-m-abcdef"))
+		Expect(capiCluster.Annotations).ToNot(HaveKey("example.com/unrelated"))
+
+		updated := &provisioningv1.Cluster{}
+		Expect(r.RancherClient.Get(ctx, client.ObjectKeyFromObject(rancherCluster), updated)).To(Succeed())
+		Expect(updated.Annotations).To(HaveKeyWithValue("example.com/team", "platform"))
+	})
+
+	It("should remove mirrored annotations deleted on the source", func() {
+		Expect(r.syncAnnotations(ctx, capiCluster, rancherCluster)).To(Succeed())
+
+		delete(rancherCluster.Annotations, "example.com/dashboard-url")
+		Expect(r.syncAnnotations(ctx, capiCluster, rancherCluster)).To(Succeed())
+		Expect(capiCluster.Annotations).ToNot(HaveKey("example.com/dashboard-url"))
+	})
+
+	It("should never mirror the saved Rancher cluster name", func() {
+		r.AnnotationsFromRancher = []string{"cluster-api.cattle.io/*"}
+		capiCluster.Annotations[turtlesannotations.RancherClusterAnnotation] = "test-ns/test-cluster-capi"
+
+		Expect(r.syncAnnotations(ctx, capiCluster, rancherCluster)).To(Succeed())
+		Expect(capiCluster.Annotations).To(HaveKeyWithValue(turtlesannotations.RancherClusterAnnotation, "test-ns/test-cluster-capi"))
+	})
+
+	It("should not write when the mirrored values already match", func() {
+		Expect(r.syncAnnotations(ctx, capiCluster, rancherCluster)).To(Succeed())
+		Expect(patches).To(Equal(1))
+
+		capiAnnotations := capiCluster.DeepCopy().Annotations
+		Expect(r.syncAnnotations(ctx, capiCluster, rancherCluster)).To(Succeed())
+		Expect(r.syncAnnotations(ctx, capiCluster, rancherCluster)).To(Succeed())
+		Expect(patches).To(Equal(1))
+		Expect(capiCluster.Annotations).To(Equal(capiAnnotations))
+	})
+
+	It("should reject annotations mirrored in both directions", func() {
+		Expect(validateAnnotationSync([]string{"a", "b"}, []string{"c"})).To(Succeed())
+		Expect(validateAnnotationSync([]string{"a", "b"}, []string{"b"})).ToNot(Succeed())
+		Expect(validateAnnotationSync([]string{"example.com/*"}, []string{"example.com/b"})).ToNot(Succeed())
+		Expect(validateAnnotationSync([]string{"example.com/a"}, []string{"example.com/*"})).ToNot(Succeed())
+	})
+})
+
+var _ = Describe("rancher cluster name template", func() {
+	It("should render the Rancher cluster name and map it back using the stored CAPI cluster name", func() {
+		tmpl, err := turtlesnaming.NewTemplate(`{{ index .Annotations "example.com/team" }}-{{ .Name }}`)
+		Expect(err).ToNot(HaveOccurred())
+
+		capiCluster := &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{
+			Name:        "test-cluster",
+			Namespace:   "test-ns",
+			Annotations: map[string]string{"example.com/team": "platform"},
+		}}
+
+		r := &CAPIImportReconciler{
+			Client: fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(capiCluster).Build(),
+			RancherClusterOptions: RancherClusterOptions{
+				NameTemplate: tmpl,
+			},
+		}
+
+		name, err := r.rancherClusterName(capiCluster)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(name).To(Equal("platform-test-cluster"))
+
+		rancherCluster := &provisioningv1.Cluster{ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   capiCluster.Namespace,
+			Annotations: map[string]string{turtlesannotations.CAPIClusterNameAnnotation: capiCluster.Name},
+		}}
+
+		reqs := r.rancherClusterToCapiCluster(ctx, predicate.Funcs{})(ctx, rancherCluster)
+		Expect(reqs).To(ConsistOf(ctrl.Request{NamespacedName: client.ObjectKeyFromObject(capiCluster)}))
+	})
+
+	It("should reuse the Rancher cluster name rendered first", func() {
+		tmpl, err := turtlesnaming.NewTemplate(`{{ index .Labels "example.com/team" }}-{{ .Name }}`)
+		Expect(err).ToNot(HaveOccurred())
+
+		capiCluster := &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-cluster",
+			Namespace: "test-ns",
+			Labels:    map[string]string{"example.com/team": "platform"},
+		}}
+
+		r := &CAPIImportReconciler{RancherClusterOptions: RancherClusterOptions{NameTemplate: tmpl}}
+
+		name, err := r.rancherClusterName(capiCluster)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(name).To(Equal("platform-test-cluster"))
+		Expect(capiCluster.Annotations).To(HaveKeyWithValue(turtlesannotations.RancherClusterAnnotation,
+			"test-ns/platform-test-cluster"))
+
+		capiCluster.Labels["example.com/team"] = "apps"
+
+		name, err = r.rancherClusterName(capiCluster)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(name).To(Equal("platform-test-cluster"))
+	})
+
+	It("should not save a Rancher cluster name violating the naming policy", func() {
+		tmpl, err := turtlesnaming.NewTemplate(`{{ index .Labels "example.com/team" }}-{{ .Name }}`)
+		Expect(err).ToNot(HaveOccurred())
+
+		policy, err := turtlesnaming.NewRegexPolicy(`platform-.*`)
+		Expect(err).ToNot(HaveOccurred())
+
+		capiCluster := &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-cluster",
+			Namespace: "test-ns",
+			Labels:    map[string]string{"example.com/team": "apps"},
+		}}
+
+		r := &CAPIImportReconciler{RancherClusterOptions: RancherClusterOptions{NameTemplate: tmpl, NamePolicy: policy}}
+
+		name, err := r.rancherClusterName(capiCluster)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(name).To(Equal("apps-test-cluster"))
+		Expect(capiCluster.Annotations).ToNot(HaveKey(turtlesannotations.RancherClusterAnnotation))
+
+		capiCluster.Labels["example.com/team"] = "platform"
+
+		name, err = r.rancherClusterName(capiCluster)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(name).To(Equal("platform-test-cluster"))
+	})
+
+	It("should fall back to the naming convention without the stored name", func() {
+		rancherCluster := &provisioningv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test-cluster-capi"}}
+		Expect(capiClusterName(rancherCluster)).To(Equal("test-cluster"))
+	})
+
+	It("should name Rancher clusters with the configured suffix", func() {
+		Expect(turtlesnaming.SetSuffix("-imported")).To(Succeed())
+		DeferCleanup(func() {
+			Expect(turtlesnaming.SetSuffix(turtlesnaming.DefaultSuffix)).To(Succeed())
+		})
+
+		capiCluster := &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test-capi-cluster", Namespace: "test-ns"}}
+
+		name, err := (&CAPIImportReconciler{}).rancherClusterName(capiCluster)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(name).To(Equal("test-capi-cluster-imported"))
+
+		rancherCluster := &provisioningv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: name}}
+		Expect(capiClusterName(rancherCluster)).To(Equal("test-capi-cluster"))
+	})
+})
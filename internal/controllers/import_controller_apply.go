@@ -0,0 +1,210 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// applyObjects writes the objects to the downstream cluster using the configured apply strategy. The custom resources
+// of the kinds defined by CRDs of the manifest are only written once these CRDs are established, as they can't be
+// created before. A dry-run doesn't wait, as the CRDs are never created.
+func (r *CAPIImportReconciler) applyObjects(ctx context.Context, remoteClient client.Client, objs []*unstructured.Unstructured) error {
+	crds, others, resources := splitCustomResources(objs)
+	if len(resources) == 0 || r.DryRun {
+		return r.writeManifestObjects(ctx, remoteClient, objs)
+	}
+
+	err := r.writeManifestObjects(ctx, remoteClient, others)
+	if _, other := splitForbidden(err); other != nil || (err != nil && !r.ContinueOnForbidden) {
+		return err
+	}
+
+	timeout := r.CRDEstablishedTimeout
+	if timeout <= 0 {
+		timeout = defaultCRDEstablishedTimeout
+	}
+
+	interval := r.crdPollInterval
+	if interval <= 0 {
+		interval = crdEstablishedPollInterval
+	}
+
+	if waitErr := waitForCRDsEstablished(ctx, remoteClient, crds, interval, timeout); waitErr != nil {
+		return errors.Join(err, waitErr)
+	}
+
+	return errors.Join(err, r.writeManifestObjects(ctx, remoteClient, resources))
+}
+
+// writeManifestObjects writes the objects to the downstream cluster using the configured apply strategy.
+func (r *CAPIImportReconciler) writeManifestObjects(ctx context.Context, remoteClient client.Client,
+	objs []*unstructured.Unstructured,
+) error {
+	ctx = withObjectApplyTimeout(ctx, r.ObjectApplyTimeout)
+
+	if r.ApplyConcurrency > 1 {
+		return r.applyObjectsConcurrently(ctx, remoteClient, objs)
+	}
+
+	if r.DryRun {
+		return dryRunObjects(ctx, remoteClient, objs, r.ContinueOnForbidden)
+	}
+
+	if r.UseServerSideApply {
+		return serverSideApplyObjects(ctx, remoteClient, objs, r.ContinueOnForbidden)
+	}
+
+	if !r.IncrementalApply {
+		return createObjects(ctx, remoteClient, objs, r.ContinueOnForbidden)
+	}
+
+	applied, err := applyObjectsIncrementally(ctx, remoteClient, objs, r.ContinueOnForbidden)
+	if _, other := splitForbidden(err); other != nil || (err != nil && !r.ContinueOnForbidden) {
+		return err
+	}
+
+	log.FromContext(ctx).Info("Applied changed manifest objects", "applied", applied, "total", len(objs))
+
+	return err
+}
+
+// applyObjectsConcurrently applies the manifest objects like writeManifestObjects, writing up to ApplyConcurrency objects
+// concurrently once the namespaces and custom resource definitions are written.
+func (r *CAPIImportReconciler) applyObjectsConcurrently(ctx context.Context, remoteClient client.Client,
+	objs []*unstructured.Unstructured,
+) error {
+	var applied atomic.Int32
+
+	incremental := r.IncrementalApply && !r.DryRun && !r.UseServerSideApply
+
+	var write objectWriter
+
+	switch {
+	case r.DryRun:
+		write = dryRunObject
+	case r.UseServerSideApply:
+		write = applyObject
+	case incremental:
+		write = func(ctx context.Context, c client.Client, obj client.Object) error {
+			written, err := applyObjectIncrementally(ctx, c, obj.(*unstructured.Unstructured))
+			if written {
+				applied.Add(1)
+			}
+
+			return err
+		}
+	default:
+		write = createObject
+	}
+
+	err := writeObjectsConcurrently(ctx, remoteClient, objs, r.ContinueOnForbidden, r.ApplyConcurrency, write)
+	if !incremental {
+		return err
+	}
+
+	if _, other := splitForbidden(err); other != nil || (err != nil && !r.ContinueOnForbidden) {
+		return err
+	}
+
+	log.FromContext(ctx).Info("Applied changed manifest objects", "applied", applied.Load(), "total", len(objs))
+
+	return err
+}
+
+// additionalObjects decodes the additional manifest applied to every imported cluster, from the inline manifest
+// and the data of the AdditionalManifestConfigMap in key order. The objects are labeled as applied by turtles.
+func (r *CAPIImportReconciler) additionalObjects(ctx context.Context) ([]*unstructured.Unstructured, error) {
+	manifests := []string{}
+
+	if r.AdditionalManifest != "" {
+		manifests = append(manifests, r.AdditionalManifest)
+	}
+
+	if r.AdditionalManifestConfigMap.Name != "" {
+		cm := &corev1.ConfigMap{}
+		if err := r.Client.Get(ctx, r.AdditionalManifestConfigMap, cm); err != nil {
+			return nil, fmt.Errorf("getting additional manifest config map %s: %w", r.AdditionalManifestConfigMap, err)
+		}
+
+		keys := make([]string, 0, len(cm.Data))
+		for key := range cm.Data {
+			keys = append(keys, key)
+		}
+
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			manifests = append(manifests, cm.Data[key])
+		}
+	}
+
+	objs := []*unstructured.Unstructured{}
+
+	for _, manifest := range manifests {
+		decoded, err := decodeManifest(strings.NewReader(manifest))
+		if err != nil {
+			return nil, fmt.Errorf("decoding additional manifest: %w", err)
+		}
+
+		objs = append(objs, decoded...)
+	}
+
+	for _, obj := range objs {
+		labels := obj.GetLabels()
+		if labels == nil {
+			labels = map[string]string{}
+		}
+
+		labels[turtlesAppliedLabelName] = "true"
+		obj.SetLabels(labels)
+	}
+
+	return objs, nil
+}
+
+// applyAdditionalManifest applies the additional manifest objects, if any, collecting the forbidden ones.
+func (r *CAPIImportReconciler) applyAdditionalManifest(ctx context.Context, remoteClient client.Client,
+	forbidden *[]*forbiddenObjectError,
+) error {
+	additionalObjs, err := r.additionalObjects(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(additionalObjs) == 0 {
+		return nil
+	}
+
+	if err := r.collectForbidden(r.applyObjects(ctx, remoteClient, additionalObjs), forbidden); err != nil {
+		return fmt.Errorf("applying additional manifest: %w", err)
+	}
+
+	log.FromContext(ctx).Info("Successfully applied additional manifest", "objects", len(additionalObjs))
+
+	return nil
+}
@@ -0,0 +1,85 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	turtlesv1 "github.com/rancher/turtles/api/v1alpha1"
+	turtlesannotations "github.com/rancher/turtles/util/annotations"
+)
+
+// trackImportAttempts counts the consecutive failed import attempts of the CAPI cluster and surfaces them in the
+// ImportAttemptsRemaining condition. A successful reconcile resets the counter. Once the maximum is reached, the
+// error is only logged and the import is retried at the backoff interval instead of the controller rate limit.
+func (r *CAPIImportReconciler) trackImportAttempts(ctx context.Context, capiCluster *clusterv1.Cluster,
+	result ctrl.Result, reconcileErr error,
+) (ctrl.Result, error) {
+	if r.MaxImportAttempts <= 0 {
+		return result, reconcileErr
+	}
+
+	if reconcileErr == nil {
+		annotations := capiCluster.GetAnnotations()
+		delete(annotations, turtlesannotations.ImportAttemptsAnnotation)
+		capiCluster.SetAnnotations(annotations)
+
+		setImportAttemptsCondition(capiCluster, 0, r.MaxImportAttempts)
+
+		return result, nil
+	}
+
+	attempts, err := strconv.Atoi(capiCluster.GetAnnotations()[turtlesannotations.ImportAttemptsAnnotation])
+	if err != nil {
+		attempts = 0
+	}
+
+	attempts++
+
+	setAnnotation(capiCluster, turtlesannotations.ImportAttemptsAnnotation, strconv.Itoa(attempts))
+
+	if attempts < r.MaxImportAttempts {
+		setImportAttemptsCondition(capiCluster, attempts, r.MaxImportAttempts)
+		return result, reconcileErr
+	}
+
+	conditions.MarkFalse(capiCluster, turtlesv1.ImportAttemptsCondition, turtlesv1.ImportAttemptsExhaustedReason,
+		clusterv1.ConditionSeverityWarning, "%d/%d import attempts consumed, retrying every %s: %s",
+		attempts, r.MaxImportAttempts, r.ImportBackoffInterval, reconcileErr)
+
+	log.FromContext(ctx).Error(reconcileErr, "import attempts exhausted, backing off",
+		"attempts", attempts, "retryAfter", r.ImportBackoffInterval)
+
+	return ctrl.Result{RequeueAfter: r.ImportBackoffInterval}, nil
+}
+
+func setImportAttemptsCondition(capiCluster *clusterv1.Cluster, attempts, maxAttempts int) {
+	conditions.Set(capiCluster, &clusterv1.Condition{
+		Type:    turtlesv1.ImportAttemptsCondition,
+		Status:  corev1.ConditionTrue,
+		Message: fmt.Sprintf("%d/%d import attempts consumed", attempts, maxAttempts),
+	})
+}
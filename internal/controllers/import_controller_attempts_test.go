@@ -0,0 +1,134 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	turtlesv1 "github.com/rancher/turtles/api/v1alpha1"
+	provisioningv1 "github.com/rancher/turtles/internal/rancher/provisioning/v1"
+	turtlesannotations "github.com/rancher/turtles/util/annotations"
+)
+
+var _ = Describe("import attempts", func() {
+	var (
+		r           *CAPIImportReconciler
+		capiCluster *clusterv1.Cluster
+		failing     bool
+	)
+
+	reconcileCluster := func() (reconcile.Result, error) {
+		res, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(capiCluster)})
+		Expect(r.Client.Get(ctx, client.ObjectKeyFromObject(capiCluster), capiCluster)).To(Succeed())
+
+		return res, err
+	}
+
+	BeforeEach(func() {
+		failing = true
+
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test-ns"}}
+		capiCluster = &clusterv1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-cluster",
+				Namespace: "test-ns",
+				Labels:    map[string]string{importLabelName: "true"},
+			},
+			Status: clusterv1.ClusterStatus{ControlPlaneReady: true},
+		}
+
+		r = &CAPIImportReconciler{
+			Client: fake.NewClientBuilder().WithScheme(scheme.Scheme).
+				WithObjects(ns, capiCluster.DeepCopy()).WithStatusSubresource(&clusterv1.Cluster{}).Build(),
+			RancherClient: fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(ns.DeepCopy()).
+				WithInterceptorFuncs(interceptor.Funcs{
+					Get: func(ctx context.Context, cl client.WithWatch, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+						if _, ok := obj.(*provisioningv1.Cluster); ok && failing {
+							return errors.New("rancher unavailable")
+						}
+
+						return cl.Get(ctx, key, obj, opts...)
+					},
+				}).Build(),
+			TimingOptions: TimingOptions{
+				MaxImportAttempts:     3,
+				ImportBackoffInterval: time.Hour,
+			},
+		}
+	})
+
+	It("should count the failed attempts and back off once exhausted", func() {
+		for attempt := 1; attempt < 3; attempt++ {
+			_, err := reconcileCluster()
+			Expect(err).To(HaveOccurred())
+
+			Expect(capiCluster.Annotations).To(HaveKeyWithValue(turtlesannotations.ImportAttemptsAnnotation, strconv.Itoa(attempt)))
+			Expect(conditions.IsTrue(capiCluster, turtlesv1.ImportAttemptsCondition)).To(BeTrue())
+			Expect(conditions.GetMessage(capiCluster, turtlesv1.ImportAttemptsCondition)).To(Equal(fmt.Sprintf("%d/3 import attempts consumed", attempt)))
+		}
+
+		res, err := reconcileCluster()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res.RequeueAfter).To(Equal(time.Hour))
+
+		Expect(capiCluster.Annotations).To(HaveKeyWithValue(turtlesannotations.ImportAttemptsAnnotation, "3"))
+		Expect(conditions.IsFalse(capiCluster, turtlesv1.ImportAttemptsCondition)).To(BeTrue())
+		Expect(conditions.GetReason(capiCluster, turtlesv1.ImportAttemptsCondition)).To(Equal(turtlesv1.ImportAttemptsExhaustedReason))
+		Expect(conditions.GetMessage(capiCluster, turtlesv1.ImportAttemptsCondition)).To(ContainSubstring("3/3 import attempts consumed"))
+	})
+
+	It("should reset the attempts on success", func() {
+		_, err := reconcileCluster()
+		Expect(err).To(HaveOccurred())
+		Expect(capiCluster.Annotations).To(HaveKeyWithValue(turtlesannotations.ImportAttemptsAnnotation, "1"))
+
+		failing = false
+
+		_, err = reconcileCluster()
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(capiCluster.Annotations).ToNot(HaveKey(turtlesannotations.ImportAttemptsAnnotation))
+		Expect(conditions.IsTrue(capiCluster, turtlesv1.ImportAttemptsCondition)).To(BeTrue())
+		Expect(conditions.GetMessage(capiCluster, turtlesv1.ImportAttemptsCondition)).To(Equal("0/3 import attempts consumed"))
+	})
+
+	It("should not track attempts when disabled", func() {
+		r.MaxImportAttempts = 0
+
+		_, err := reconcileCluster()
+		Expect(err).To(HaveOccurred())
+
+		Expect(capiCluster.Annotations).ToNot(HaveKey(turtlesannotations.ImportAttemptsAnnotation))
+		Expect(conditions.Has(capiCluster, turtlesv1.ImportAttemptsCondition)).To(BeFalse())
+	})
+})
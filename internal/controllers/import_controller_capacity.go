@@ -0,0 +1,73 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	turtlesv1 "github.com/rancher/turtles/api/v1alpha1"
+	provisioningv1 "github.com/rancher/turtles/internal/rancher/provisioning/v1"
+)
+
+// rancherClusterCount returns the number of Rancher clusters which are not being deleted, in all namespaces.
+func (r *CAPIImportReconciler) rancherClusterCount(ctx context.Context) (int, error) {
+	clusters := &provisioningv1.ClusterList{}
+	if err := r.RancherClient.List(ctx, clusters); err != nil {
+		return 0, fmt.Errorf("listing Rancher clusters: %w", err)
+	}
+
+	count := 0
+
+	for i := range clusters.Items {
+		if clusters.Items[i].DeletionTimestamp.IsZero() {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+// checkRancherCapacity reports whether Rancher can take one more cluster, according to MaxRancherClusters, in the
+// RancherCapacityAvailable condition. It returns false when Rancher is at capacity and the import must wait.
+func (r *CAPIImportReconciler) checkRancherCapacity(ctx context.Context, capiCluster *clusterv1.Cluster) (bool, error) {
+	if r.MaxRancherClusters <= 0 {
+		return true, nil
+	}
+
+	count, err := r.rancherClusterCount(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	if count < r.MaxRancherClusters {
+		conditions.MarkTrue(capiCluster, turtlesv1.RancherCapacityCondition)
+		return true, nil
+	}
+
+	log.FromContext(ctx).Info("Rancher is at capacity, holding off the import", "clusters", count, "max", r.MaxRancherClusters)
+
+	conditions.MarkFalse(capiCluster, turtlesv1.RancherCapacityCondition, turtlesv1.RancherAtCapacityReason,
+		clusterv1.ConditionSeverityWarning, "Rancher manages %d clusters, the maximum is %d", count, r.MaxRancherClusters)
+
+	return false, nil
+}
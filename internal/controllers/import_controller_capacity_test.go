@@ -0,0 +1,118 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	turtlesv1 "github.com/rancher/turtles/api/v1alpha1"
+	provisioningv1 "github.com/rancher/turtles/internal/rancher/provisioning/v1"
+	"github.com/rancher/turtles/testutil"
+	turtlesannotations "github.com/rancher/turtles/util/annotations"
+)
+
+var _ = Describe("Rancher capacity", func() {
+	var (
+		r           *CAPIImportReconciler
+		ns          *corev1.Namespace
+		capiCluster *clusterv1.Cluster
+		builder     *testutil.RancherClientBuilder
+	)
+
+	rancherClusterKey := client.ObjectKey{Namespace: "test-ns", Name: "test-cluster-capi"}
+
+	BeforeEach(func() {
+		ns = &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test-ns"}}
+		capiCluster = &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-cluster",
+			Namespace: "test-ns",
+			Labels:    map[string]string{importLabelName: "true"},
+		}}
+		builder = testutil.NewRancherClientBuilder().
+			WithObjects(ns.DeepCopy()).
+			WithCluster("local", "fleet-local", testutil.ClusterStateReady, "").
+			WithCluster("other-capi", "test-ns", testutil.ClusterStateReady, "")
+
+		r = &CAPIImportReconciler{
+			Client: fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(ns, capiCluster).Build(),
+		}
+	})
+
+	It("should hold off the import while Rancher is at capacity", func() {
+		r.RancherClient = builder.Build()
+		r.MaxRancherClusters = 2
+
+		res, err := r.reconcile(ctx, capiCluster)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res.RequeueAfter).To(Equal(defaultRequeueDuration))
+
+		Expect(apierrors.IsNotFound(r.RancherClient.Get(ctx, rancherClusterKey, &provisioningv1.Cluster{}))).To(BeTrue())
+		Expect(conditions.IsFalse(capiCluster, turtlesv1.RancherCapacityCondition)).To(BeTrue())
+		Expect(conditions.GetReason(capiCluster, turtlesv1.RancherCapacityCondition)).To(Equal(turtlesv1.RancherAtCapacityReason))
+		Expect(conditions.GetMessage(capiCluster, turtlesv1.RancherCapacityCondition)).To(Equal("Rancher manages 2 clusters, the maximum is 2"))
+	})
+
+	It("should import the cluster while Rancher is below capacity", func() {
+		r.RancherClient = builder.Build()
+		r.MaxRancherClusters = 3
+
+		_, err := r.reconcile(ctx, capiCluster)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(r.RancherClient.Get(ctx, rancherClusterKey, &provisioningv1.Cluster{})).To(Succeed())
+		Expect(conditions.IsTrue(capiCluster, turtlesv1.RancherCapacityCondition)).To(BeTrue())
+	})
+
+	It("should not count clusters being deleted", func() {
+		deleting := testutil.RancherCluster("deleted-capi", "test-ns", testutil.ClusterStateReady)
+		deleting.Finalizers = []string{"test"}
+		deleting.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+
+		r.RancherClient = builder.WithObjects(deleting).Build()
+		r.MaxRancherClusters = 3
+
+		_, err := r.reconcile(ctx, capiCluster)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(r.RancherClient.Get(ctx, rancherClusterKey, &provisioningv1.Cluster{})).To(Succeed())
+	})
+
+	It("should still proceed with deletions while Rancher is at capacity", func() {
+		deleting := testutil.RancherCluster(rancherClusterKey.Name, rancherClusterKey.Namespace, testutil.ClusterStateReady)
+		deleting.Finalizers = []string{"test"}
+		deleting.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+
+		r.RancherClient = builder.WithObjects(deleting).Build()
+		r.MaxRancherClusters = 1
+
+		_, err := r.reconcile(ctx, capiCluster)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(capiCluster.Annotations).To(HaveKeyWithValue(turtlesannotations.ClusterImportedAnnotation, "true"))
+	})
+})
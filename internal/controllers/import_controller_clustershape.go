@@ -0,0 +1,114 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	provisioningv1 "github.com/rancher/turtles/internal/rancher/provisioning/v1"
+)
+
+// RancherClusterShape is the API version, and shape, of the Rancher clusters created for the CAPI clusters.
+type RancherClusterShape string
+
+const (
+	// RancherClusterShapeV1 creates provisioning.cattle.io/v1 clusters.
+	RancherClusterShapeV1 RancherClusterShape = "v1"
+
+	// RancherClusterShapeV2 is reserved for the next shape of the Rancher clusters. It is not served by Rancher yet.
+	RancherClusterShapeV2 RancherClusterShape = "v2"
+)
+
+// RancherClusterStatus is the status of a Rancher cluster the import relies on.
+type RancherClusterStatus struct {
+	// ClusterName is the name of the management cluster of the Rancher cluster, empty until Rancher assigned it.
+	ClusterName string
+	// AgentDeployed is true once Rancher deployed its agent on the cluster.
+	AgentDeployed bool
+	// Ready is true once Rancher reports the cluster ready.
+	Ready bool
+}
+
+// RancherClusterFactory creates and gets the Rancher clusters in the shape served by Rancher, decoupling the reconciler
+// from a single API version. The reconciler works on provisioning v1 clusters, which the factory converts from and to
+// its shape.
+type RancherClusterFactory interface {
+	// Get reads the Rancher cluster with the key into cluster.
+	Get(ctx context.Context, c client.Client, key client.ObjectKey, cluster *provisioningv1.Cluster) error
+
+	// Create creates the Rancher cluster, updating cluster with the created object.
+	Create(ctx context.Context, c client.Client, cluster *provisioningv1.Cluster) error
+
+	// Status returns the status of the Rancher cluster.
+	Status(cluster *provisioningv1.Cluster) RancherClusterStatus
+}
+
+// NewRancherClusterFactory returns the factory of the Rancher clusters of the given shape. An empty shape is the v1
+// shape.
+func NewRancherClusterFactory(shape string) (RancherClusterFactory, error) {
+	switch RancherClusterShape(shape) {
+	case "", RancherClusterShapeV1:
+		return provisioningV1ClusterFactory{}, nil
+	case RancherClusterShapeV2:
+		return nil, fmt.Errorf("rancher cluster shape %q is not supported yet", shape)
+	default:
+		return nil, fmt.Errorf("unknown rancher cluster shape %q, must be %q", shape, RancherClusterShapeV1)
+	}
+}
+
+// provisioningV1ClusterFactory is the factory of the provisioning v1 Rancher clusters, used as is by the reconciler.
+type provisioningV1ClusterFactory struct{}
+
+func (provisioningV1ClusterFactory) Get(ctx context.Context, c client.Client, key client.ObjectKey,
+	cluster *provisioningv1.Cluster,
+) error {
+	return c.Get(ctx, key, cluster)
+}
+
+func (provisioningV1ClusterFactory) Create(ctx context.Context, c client.Client, cluster *provisioningv1.Cluster) error {
+	return c.Create(ctx, cluster)
+}
+
+func (provisioningV1ClusterFactory) Status(cluster *provisioningv1.Cluster) RancherClusterStatus {
+	return RancherClusterStatus{
+		ClusterName:   cluster.Status.ClusterName,
+		AgentDeployed: cluster.Status.IsAgentDeployed(),
+		Ready:         cluster.Status.IsReady(),
+	}
+}
+
+// rancherClusters returns the factory of the Rancher clusters, the provisioning v1 one when unset.
+func (r *CAPIImportReconciler) rancherClusters() RancherClusterFactory {
+	if r.RancherClusterFactory == nil {
+		return provisioningV1ClusterFactory{}
+	}
+
+	return r.RancherClusterFactory
+}
+
+// getRancherCluster reads the Rancher cluster with the key of rancherCluster into it.
+func (r *CAPIImportReconciler) getRancherCluster(ctx context.Context, rancherCluster *provisioningv1.Cluster) error {
+	return r.rancherClusters().Get(ctx, r.RancherClient, client.ObjectKeyFromObject(rancherCluster), rancherCluster)
+}
+
+// rancherClusterStatus returns the status of the Rancher cluster.
+func (r *CAPIImportReconciler) rancherClusterStatus(rancherCluster *provisioningv1.Cluster) RancherClusterStatus {
+	return r.rancherClusters().Status(rancherCluster)
+}
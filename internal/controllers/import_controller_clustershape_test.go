@@ -0,0 +1,167 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	turtlesv1 "github.com/rancher/turtles/api/v1alpha1"
+	provisioningv1 "github.com/rancher/turtles/internal/rancher/provisioning/v1"
+	"github.com/rancher/turtles/testutil"
+)
+
+// fakeClusterFactory is a Rancher cluster factory reporting a status of its own instead of the provisioning v1 one.
+type fakeClusterFactory struct {
+	status  RancherClusterStatus
+	gets    int
+	created []client.ObjectKey
+}
+
+func (f *fakeClusterFactory) Get(ctx context.Context, c client.Client, key client.ObjectKey,
+	cluster *provisioningv1.Cluster,
+) error {
+	f.gets++
+	return c.Get(ctx, key, cluster)
+}
+
+func (f *fakeClusterFactory) Create(ctx context.Context, c client.Client, cluster *provisioningv1.Cluster) error {
+	f.created = append(f.created, client.ObjectKeyFromObject(cluster))
+	return c.Create(ctx, cluster)
+}
+
+func (f *fakeClusterFactory) Status(_ *provisioningv1.Cluster) RancherClusterStatus {
+	return f.status
+}
+
+var _ = Describe("Rancher cluster factory", func() {
+	var (
+		r            *CAPIImportReconciler
+		factory      *fakeClusterFactory
+		server       *testutil.ManifestServer
+		remoteClient client.Client
+		capiCluster  *clusterv1.Cluster
+	)
+
+	BeforeEach(func() {
+		server = testutil.NewManifestServer(manifestWithServerFields)
+		DeferCleanup(server.Close)
+
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test-ns", Labels: map[string]string{importLabelName: "true"}}}
+		capiCluster = &clusterv1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", Namespace: "test-ns"},
+			Status:     clusterv1.ClusterStatus{ControlPlaneReady: true},
+		}
+
+		factory = &fakeClusterFactory{}
+		remoteClient = fake.NewClientBuilder().WithScheme(scheme.Scheme).Build()
+
+		r = &CAPIImportReconciler{
+			Client: fake.NewClientBuilder().WithScheme(scheme.Scheme).
+				WithObjects(ns, capiCluster.DeepCopy()).WithStatusSubresource(&clusterv1.Cluster{}).Build(),
+			// the registration token exists, but the provisioning v1 status of the cluster carries no name
+			RancherClient: testutil.NewRancherClientBuilder().WithObjects(ns.DeepCopy(),
+				testutil.RegistrationToken(testutil.ManagementClusterName("test-cluster-capi"), "test-ns", server.URL)).Build(),
+			RancherClusterFactory: factory,
+			recorder:              record.NewFakeRecorder(10),
+			remoteClientGetter: func(_ context.Context, _ string, _ client.Client, _ client.ObjectKey) (client.Client, error) {
+				return remoteClient, nil
+			},
+			RancherClusterOptions: RancherClusterOptions{
+				MirrorRancherReadiness: true,
+			},
+		}
+	})
+
+	It("should create the Rancher cluster with the factory", func() {
+		_, err := r.reconcile(ctx, capiCluster)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(factory.gets).ToNot(BeZero())
+		Expect(factory.created).To(ConsistOf(client.ObjectKey{Namespace: "test-ns", Name: "test-cluster-capi"}))
+		Expect(r.RancherClient.Get(ctx, factory.created[0], &provisioningv1.Cluster{})).To(Succeed())
+	})
+
+	It("should read the Rancher cluster status from the factory", func() {
+		_, err := r.reconcile(ctx, capiCluster)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(server.Requests()).To(BeZero())
+
+		factory.status = RancherClusterStatus{ClusterName: testutil.ManagementClusterName("test-cluster-capi")}
+
+		res, err := r.reconcile(ctx, capiCluster)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res).To(Equal(ctrl.Result{}))
+		Expect(server.Requests()).To(Equal(1))
+		Expect(conditions.IsTrue(capiCluster, turtlesv1.ImportManifestAppliedCondition)).To(BeTrue())
+		Expect(conditions.IsFalse(capiCluster, turtlesv1.RancherAgentDeployedCondition)).To(BeTrue())
+
+		factory.status.AgentDeployed = true
+		factory.status.Ready = true
+
+		_, err = r.reconcile(ctx, capiCluster)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(server.Requests()).To(Equal(1))
+		Expect(conditions.IsTrue(capiCluster, turtlesv1.RancherAgentDeployedCondition)).To(BeTrue())
+		Expect(conditions.IsTrue(capiCluster, turtlesv1.RancherClusterReadyCondition)).To(BeTrue())
+	})
+
+	DescribeTable("should select the factory of the shape",
+		func(shape string, succeed bool) {
+			factory, err := NewRancherClusterFactory(shape)
+			if !succeed {
+				Expect(err).To(HaveOccurred())
+				return
+			}
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(factory).To(Equal(provisioningV1ClusterFactory{}))
+		},
+		Entry("default", "", true),
+		Entry("v1", "v1", true),
+		Entry("future v2", "v2", false),
+		Entry("unknown", "v3", false),
+	)
+
+	It("should default to the provisioning v1 factory", func() {
+		r.RancherClusterFactory = nil
+
+		cluster := testutil.RancherCluster("test-cluster-capi", "test-ns", testutil.ClusterStateReady)
+		Expect(r.rancherClusterStatus(cluster)).To(Equal(RancherClusterStatus{
+			ClusterName:   testutil.ManagementClusterName("test-cluster-capi"),
+			AgentDeployed: true,
+			Ready:         true,
+		}))
+
+		cluster.Status.Conditions = []provisioningv1.Condition{
+			{Type: provisioningv1.ClusterReadyCondition, Status: corev1.ConditionFalse, Reason: "Waiting"},
+		}
+		Expect(r.rancherClusterStatus(cluster).Ready).To(BeFalse())
+		Expect(r.rancherClusterStatus(cluster).AgentDeployed).To(BeTrue())
+	})
+})
@@ -0,0 +1,176 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/clock"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	turtlesv1 "github.com/rancher/turtles/api/v1alpha1"
+	"github.com/rancher/turtles/testutil"
+)
+
+var _ = Describe("concurrent reconciles", func() {
+	const (
+		clusters = 20
+		rounds   = 3
+	)
+
+	var (
+		r      *CAPIImportReconciler
+		server *testutil.ManifestServer
+		keys   []client.ObjectKey
+	)
+
+	BeforeEach(func() {
+		server = testutil.NewManifestServer(manifestWithServerFields)
+		DeferCleanup(server.Close)
+
+		imported := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "imported", Labels: map[string]string{importLabelName: "true"}}}
+		skipped := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "skipped"}}
+
+		objs := []client.Object{imported, skipped}
+		rancher := testutil.NewRancherClientBuilder().WithObjects(imported.DeepCopy())
+		keys = nil
+
+		for i := 0; i < clusters; i++ {
+			// every other cluster is in a namespace not marked for import, emitting events on it
+			ns := imported.Name
+			if i%2 == 1 {
+				ns = skipped.Name
+			}
+
+			capiCluster := &clusterv1.Cluster{
+				ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("cluster-%d", i), Namespace: ns},
+				Status:     clusterv1.ClusterStatus{ControlPlaneReady: true},
+			}
+			objs = append(objs, capiCluster)
+			keys = append(keys, client.ObjectKeyFromObject(capiCluster))
+
+			if ns == imported.Name {
+				rancher = rancher.WithCluster(capiCluster.Name+"-capi", ns, testutil.ClusterStateNameSet, server.URL)
+			}
+		}
+
+		r = &CAPIImportReconciler{
+			Client: fake.NewClientBuilder().WithScheme(scheme.Scheme).
+				WithObjects(objs...).WithStatusSubresource(&clusterv1.Cluster{}).Build(),
+			RancherClient:      rancher.Build(),
+			CacheRemoteClients: true,
+			Concurrency:        clusters,
+			recorder:           record.NewFakeRecorder(clusters * rounds * 10),
+			clock:              clock.RealClock{},
+			remoteClientGetter: func(_ context.Context, _ string, _ client.Client, _ client.ObjectKey) (client.Client, error) {
+				return fake.NewClientBuilder().WithScheme(scheme.Scheme).Build(), nil
+			},
+			DownloadOptions: DownloadOptions{
+				ManifestCacheConfigMap: client.ObjectKey{Namespace: "rancher-turtles-system", Name: "manifest-cache"},
+			},
+			TimingOptions: TimingOptions{
+				NamespaceEventInterval:  time.Hour,
+				RegistrationCheckWindow: time.Minute,
+			},
+		}
+	})
+
+	It("should reconcile many clusters in parallel", func() {
+		var wg sync.WaitGroup
+
+		errs := make(chan error, clusters*rounds)
+
+		// the same cluster is never reconciled in parallel by the controller, only different ones
+		for _, key := range keys {
+			wg.Add(1)
+
+			go func(key client.ObjectKey) {
+				defer GinkgoRecover()
+				defer wg.Done()
+
+				for i := 0; i < rounds; i++ {
+					if _, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: key}); err != nil {
+						errs <- fmt.Errorf("reconciling %s: %w", key, err)
+					}
+				}
+			}(key)
+		}
+
+		// the debug and status endpoints read the reconciler state while reconciles run
+		wg.Add(1)
+
+		go func() {
+			defer GinkgoRecover()
+			defer wg.Done()
+
+			for i := 0; i < clusters; i++ {
+				_ = r.DebugState()
+				_ = r.Config()
+			}
+		}()
+
+		wg.Wait()
+		close(errs)
+
+		for err := range errs {
+			Expect(err).ToNot(HaveOccurred())
+		}
+
+		for _, key := range keys {
+			capiCluster := &clusterv1.Cluster{}
+			Expect(r.Client.Get(ctx, key, capiCluster)).To(Succeed())
+
+			if key.Namespace == "imported" {
+				Expect(conditions.IsTrue(capiCluster, turtlesv1.ImportManifestAppliedCondition)).To(BeTrue(), key.String())
+			} else {
+				Expect(conditions.Has(capiCluster, turtlesv1.ImportManifestAppliedCondition)).To(BeFalse(), key.String())
+			}
+		}
+
+		Expect(server.Requests()).To(Equal(clusters / 2))
+
+		// the state shared by the reconciles recorded every cluster
+		state := r.DebugState()
+		Expect(state.InFlight).To(BeEmpty())
+		Expect(state.Clusters).To(HaveLen(clusters))
+		Expect(state.RemoteClients).To(HaveLen(clusters / 2))
+		Expect(state.NamespaceEvents).To(HaveKey("skipped"))
+		Expect(state.NamespaceEvents).ToNot(HaveKey("imported"))
+		Expect(r.manifests.entries).To(HaveLen(clusters / 2))
+	})
+
+	It("should override the concurrency of the controller options", func() {
+		Expect(r.controllerOptions(controller.Options{MaxConcurrentReconciles: 1}).MaxConcurrentReconciles).To(Equal(clusters))
+
+		r.Concurrency = 0
+		Expect(r.controllerOptions(controller.Options{MaxConcurrentReconciles: 1}).MaxConcurrentReconciles).To(Equal(1))
+	})
+})
@@ -0,0 +1,261 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/rancher/turtles/feature"
+	turtlesnaming "github.com/rancher/turtles/util/naming"
+)
+
+// ReconcilerConfig is the effective configuration of the import reconciler, for verifying the flags took effect.
+// Durations are formatted as strings, and values which can carry credentials are redacted.
+type ReconcilerConfig struct {
+	ImportLabel                        string              `json:"importLabel"`
+	OwnedLabel                         string              `json:"ownedLabel"`
+	RemoteRancher                      bool                `json:"remoteRancher"`
+	Concurrency                        int                 `json:"concurrency,omitempty"`
+	WatchFilterValue                   string              `json:"watchFilterValue,omitempty"`
+	FeatureGates                       map[string]bool     `json:"featureGates"`
+	InsecureSkipVerify                 bool                `json:"insecureSkipVerify"`
+	CABundle                           bool                `json:"caBundle"`
+	ManifestURLHost                    string              `json:"manifestURLHost,omitempty"`
+	ManifestURLHostAllowlist           []string            `json:"manifestURLHostAllowlist,omitempty"`
+	ManifestDownloadAttempts           int                 `json:"manifestDownloadAttempts"`
+	ManifestDownloadInterval           string              `json:"manifestDownloadInterval"`
+	ManifestDownloadTimeout            string              `json:"manifestDownloadTimeout"`
+	RancherClusterPollInterval         string              `json:"rancherClusterPollInterval"`
+	RegistrationTokenGracePeriod       string              `json:"registrationTokenGracePeriod"`
+	RegistrationTokenReissueInterval   string              `json:"registrationTokenReissueInterval"`
+	RancherClusterNamespace            string              `json:"rancherClusterNamespace,omitempty"`
+	CreateRancherNamespace             bool                `json:"createRancherNamespace"`
+	NameTemplate                       string              `json:"nameTemplate,omitempty"`
+	NameSuffix                         string              `json:"nameSuffix"`
+	NamePolicy                         string              `json:"namePolicy,omitempty"`
+	ClusterSelector                    string              `json:"clusterSelector,omitempty"`
+	WatchNamespaces                    []string            `json:"watchNamespaces,omitempty"`
+	RegistrationCheckWindow            string              `json:"registrationCheckWindow"`
+	DisconnectedThreshold              string              `json:"disconnectedThreshold"`
+	ReapplyOnDisconnect                bool                `json:"reapplyOnDisconnect"`
+	MinReapplyInterval                 string              `json:"minReapplyInterval"`
+	SelfCheckInterval                  string              `json:"selfCheckInterval"`
+	RecordManifestStats                bool                `json:"recordManifestStats"`
+	MirrorRancherReadiness             bool                `json:"mirrorRancherReadiness"`
+	ImportWindows                      []string            `json:"importWindows,omitempty"`
+	ImportWindowsTimezone              string              `json:"importWindowsTimezone,omitempty"`
+	IncrementalApply                   bool                `json:"incrementalApply"`
+	UseServerSideApply                 bool                `json:"useServerSideApply"`
+	DryRun                             bool                `json:"dryRun"`
+	ApplyConcurrency                   int                 `json:"applyConcurrency"`
+	CRDEstablishedTimeout              string              `json:"crdEstablishedTimeout"`
+	ObjectApplyTimeout                 string              `json:"objectApplyTimeout"`
+	EagerCreate                        bool                `json:"eagerCreate"`
+	WaitForMachinePools                bool                `json:"waitForMachinePools"`
+	CheckAgentHealth                   bool                `json:"checkAgentHealth"`
+	ReconcileAgentHealth               bool                `json:"reconcileAgentHealth"`
+	AgentHealthInterval                string              `json:"agentHealthInterval"`
+	ControlPlaneReadyConditions        []string            `json:"controlPlaneReadyConditions,omitempty"`
+	ControlPlaneReadyOnProvisioned     bool                `json:"controlPlaneReadyOnProvisioned"`
+	ExistingAgentPolicy                string              `json:"existingAgentPolicy,omitempty"`
+	LabelAdoptedAgent                  bool                `json:"labelAdoptedAgent"`
+	ContinueOnForbidden                bool                `json:"continueOnForbidden"`
+	EndpointDiagnostics                bool                `json:"endpointDiagnostics"`
+	AgentNodeSelector                  map[string]string   `json:"agentNodeSelector,omitempty"`
+	AgentTolerations                   []corev1.Toleration `json:"agentTolerations,omitempty"`
+	AdditionalManifest                 string              `json:"additionalManifest,omitempty"`
+	AdditionalManifestConfigMap        string              `json:"additionalManifestConfigMap,omitempty"`
+	ManifestCacheConfigMap             string              `json:"manifestCacheConfigMap,omitempty"`
+	Version                            string              `json:"version,omitempty"`
+	CacheRemoteClients                 bool                `json:"cacheRemoteClients"`
+	KubeconfigSecretSuffix             string              `json:"kubeconfigSecretSuffix,omitempty"`
+	KubeconfigSecretKey                string              `json:"kubeconfigSecretKey,omitempty"`
+	SupportedKubernetesVersionsSet     bool                `json:"supportedKubernetesVersionsSet"`
+	AllowUnsupportedKubernetesVersions bool                `json:"allowUnsupportedKubernetesVersions"`
+	MaxRancherClusters                 int                 `json:"maxRancherClusters"`
+	MaxImportAttempts                  int                 `json:"maxImportAttempts"`
+	ImportBackoffInterval              string              `json:"importBackoffInterval"`
+	MaxTokenRefreshes                  int                 `json:"maxTokenRefreshes"`
+	ImportSLOThreshold                 string              `json:"importSLOThreshold"`
+	TimelineEntries                    int                 `json:"timelineEntries"`
+	TopologyLabels                     bool                `json:"topologyLabels"`
+	RegionFields                       map[string]string   `json:"regionFields,omitempty"`
+	TopologyVariableMapping            string              `json:"topologyVariableMapping,omitempty"`
+	NamespaceEnqueueSpread             string              `json:"namespaceEnqueueSpread"`
+	NamespaceEventInterval             string              `json:"namespaceEventInterval"`
+	AccessLabels                       []string            `json:"accessLabels,omitempty"`
+	DeletionProtection                 bool                `json:"deletionProtection"`
+	LabelsToRancher                    []string            `json:"labelsToRancher,omitempty"`
+	AnnotationsToRancher               []string            `json:"annotationsToRancher,omitempty"`
+	AnnotationsFromRancher             []string            `json:"annotationsFromRancher,omitempty"`
+}
+
+// Config returns the effective configuration of the reconciler. The inline additional manifest can hold secrets and
+// is never included, and credentials in the manifest URL host are redacted. The supported Kubernetes versions range
+// can't be formatted back, only whether one is set is reported.
+func (r *CAPIImportReconciler) Config() ReconcilerConfig {
+	config := ReconcilerConfig{
+		ImportLabel:                        r.importLabel(),
+		OwnedLabel:                         r.ownedLabel(),
+		RemoteRancher:                      r.RemoteRancher,
+		Concurrency:                        r.Concurrency,
+		WatchFilterValue:                   r.WatchFilterValue,
+		FeatureGates:                       map[string]bool{},
+		InsecureSkipVerify:                 r.InsecureSkipVerify,
+		CABundle:                           len(r.CABundle) > 0,
+		ManifestURLHost:                    redactHostCredentials(r.ManifestURLHost),
+		ManifestURLHostAllowlist:           redactHostsCredentials(r.ManifestURLHostAllowlist),
+		ManifestDownloadAttempts:           r.ManifestDownloadAttempts,
+		ManifestDownloadInterval:           r.ManifestDownloadInterval.String(),
+		ManifestDownloadTimeout:            r.ManifestDownloadTimeout.String(),
+		RancherClusterPollInterval:         r.RancherClusterPollInterval.String(),
+		RegistrationTokenGracePeriod:       r.RegistrationTokenGracePeriod.String(),
+		RegistrationTokenReissueInterval:   r.RegistrationTokenReissueInterval.String(),
+		RancherClusterNamespace:            r.RancherClusterNamespace,
+		CreateRancherNamespace:             r.CreateRancherNamespace,
+		NameSuffix:                         turtlesnaming.Suffix(),
+		RegistrationCheckWindow:            r.RegistrationCheckWindow.String(),
+		DisconnectedThreshold:              r.DisconnectedThreshold.String(),
+		ReapplyOnDisconnect:                r.ReapplyOnDisconnect,
+		MinReapplyInterval:                 r.MinReapplyInterval.String(),
+		SelfCheckInterval:                  r.SelfCheckInterval.String(),
+		RecordManifestStats:                r.RecordManifestStats,
+		MirrorRancherReadiness:             r.MirrorRancherReadiness,
+		IncrementalApply:                   r.IncrementalApply,
+		UseServerSideApply:                 r.UseServerSideApply,
+		DryRun:                             r.DryRun,
+		ApplyConcurrency:                   r.ApplyConcurrency,
+		CRDEstablishedTimeout:              r.CRDEstablishedTimeout.String(),
+		ObjectApplyTimeout:                 r.ObjectApplyTimeout.String(),
+		EagerCreate:                        r.EagerCreate,
+		WaitForMachinePools:                r.WaitForMachinePools,
+		CheckAgentHealth:                   r.CheckAgentHealth,
+		ReconcileAgentHealth:               r.ReconcileAgentHealth,
+		AgentHealthInterval:                r.agentHealthInterval().String(),
+		ControlPlaneReadyOnProvisioned:     r.ControlPlaneReadiness.ProvisionedPhase,
+		ExistingAgentPolicy:                string(r.ExistingAgentPolicy),
+		LabelAdoptedAgent:                  r.LabelAdoptedAgent,
+		ContinueOnForbidden:                r.ContinueOnForbidden,
+		EndpointDiagnostics:                r.EndpointDiagnostics,
+		AgentNodeSelector:                  r.AgentNodeSelector,
+		AgentTolerations:                   r.AgentTolerations,
+		AdditionalManifestConfigMap:        objectKeyString(r.AdditionalManifestConfigMap),
+		ManifestCacheConfigMap:             objectKeyString(r.ManifestCacheConfigMap),
+		Version:                            r.turtlesVersion(),
+		CacheRemoteClients:                 r.CacheRemoteClients,
+		KubeconfigSecretSuffix:             r.KubeconfigSecret.NameSuffix,
+		KubeconfigSecretKey:                r.KubeconfigSecret.Key,
+		SupportedKubernetesVersionsSet:     r.SupportedKubernetesVersions != nil,
+		AllowUnsupportedKubernetesVersions: r.AllowUnsupportedKubernetesVersions,
+		MaxRancherClusters:                 r.MaxRancherClusters,
+		MaxImportAttempts:                  r.MaxImportAttempts,
+		ImportBackoffInterval:              r.ImportBackoffInterval.String(),
+		MaxTokenRefreshes:                  r.MaxTokenRefreshes,
+		ImportSLOThreshold:                 r.ImportSLOThreshold.String(),
+		TimelineEntries:                    r.TimelineEntries,
+		TopologyLabels:                     r.TopologyLabels,
+		RegionFields:                       r.RegionFields,
+		TopologyVariableMapping:            objectKeyString(r.TopologyVariableMapping),
+		NamespaceEnqueueSpread:             r.NamespaceEnqueueSpread.String(),
+		NamespaceEventInterval:             r.NamespaceEventInterval.String(),
+		AccessLabels:                       r.AccessLabels,
+		WatchNamespaces:                    r.WatchNamespaces,
+		DeletionProtection:                 r.DeletionProtection,
+		LabelsToRancher:                    r.LabelsToRancher,
+		AnnotationsToRancher:               r.AnnotationsToRancher,
+		AnnotationsFromRancher:             r.AnnotationsFromRancher,
+	}
+
+	for gate := range feature.MutableGates.GetAll() {
+		config.FeatureGates[string(gate)] = feature.Gates.Enabled(gate)
+	}
+
+	for _, conditionType := range r.ControlPlaneReadiness.Conditions {
+		config.ControlPlaneReadyConditions = append(config.ControlPlaneReadyConditions, string(conditionType))
+	}
+
+	if r.NameTemplate != nil {
+		config.NameTemplate = r.NameTemplate.String()
+	}
+
+	if r.NamePolicy != nil {
+		config.NamePolicy = fmt.Sprint(r.NamePolicy)
+	}
+
+	if r.ClusterSelector != nil {
+		config.ClusterSelector = metav1.FormatLabelSelector(r.ClusterSelector)
+	}
+
+	if r.ImportSchedule != nil {
+		for _, window := range r.ImportSchedule.Windows {
+			config.ImportWindows = append(config.ImportWindows, window.String())
+		}
+
+		if r.ImportSchedule.Location != nil {
+			config.ImportWindowsTimezone = r.ImportSchedule.Location.String()
+		}
+	}
+
+	if r.AdditionalManifest != "" {
+		config.AdditionalManifest = fmt.Sprintf("%s (%d bytes)", redacted, len(r.AdditionalManifest))
+	}
+
+	return config
+}
+
+// ConfigHandler returns a handler serving the Config of the reconciler as JSON, authorized like DebugHandler.
+func (r *CAPIImportReconciler) ConfigHandler() http.Handler {
+	return r.debugJSONHandler(func() any { return r.Config() })
+}
+
+// redactHostCredentials redacts the user info of a host, e.g. user:password@mirror.example.com.
+func redactHostCredentials(host string) string {
+	if i := strings.LastIndex(host, "@"); i >= 0 {
+		return redacted + host[i:]
+	}
+
+	return host
+}
+
+// redactHostsCredentials redacts the user info of the hosts.
+func redactHostsCredentials(hosts []string) []string {
+	if len(hosts) == 0 {
+		return nil
+	}
+
+	redactedHosts := make([]string, 0, len(hosts))
+	for _, host := range hosts {
+		redactedHosts = append(redactedHosts, redactHostCredentials(host))
+	}
+
+	return redactedHosts
+}
+
+// objectKeyString formats an object key, returning an empty string for the empty key of a disabled option.
+func objectKeyString(key client.ObjectKey) string {
+	if key.Name == "" {
+		return ""
+	}
+
+	return key.String()
+}
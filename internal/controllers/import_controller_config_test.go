@@ -0,0 +1,173 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/blang/semver/v4"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	"github.com/rancher/turtles/feature"
+	turtlesnaming "github.com/rancher/turtles/util/naming"
+	"github.com/rancher/turtles/util/schedule"
+)
+
+var _ = Describe("reconciler configuration", func() {
+	const secretManifest = `apiVersion: v1
+kind: Secret
+metadata:
+  name: registry
+stringData:
+  password: s3cr3t-password`
+
+	var r *CAPIImportReconciler
+
+	BeforeEach(func() {
+		tmpl, err := turtlesnaming.NewTemplate(`{{ .Namespace }}-{{ .Name }}`)
+		Expect(err).ToNot(HaveOccurred())
+
+		policy, err := turtlesnaming.NewRegexPolicy(`bu1-.+`)
+		Expect(err).ToNot(HaveOccurred())
+
+		importSchedule, err := schedule.Parse([]string{"Sat,Sun 22:00-06:00"}, "Europe/Berlin")
+		Expect(err).ToNot(HaveOccurred())
+
+		r = &CAPIImportReconciler{
+			Client: fake.NewClientBuilder().WithScheme(scheme.Scheme).WithInterceptorFuncs(interceptor.Funcs{
+				Create: func(ctx context.Context, cl client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+					switch review := obj.(type) {
+					case *authenticationv1.TokenReview:
+						review.Status.Authenticated = review.Spec.Token == "admin-token"
+						review.Status.User = authenticationv1.UserInfo{Username: "admin"}
+
+						return nil
+					case *authorizationv1.SubjectAccessReview:
+						review.Status.Allowed = review.Spec.NonResourceAttributes.Path == "/debug/config"
+
+						return nil
+					}
+
+					return cl.Create(ctx, obj, opts...)
+				},
+			}).Build(),
+			WatchFilterValue: "team-a",
+			RemoteRancher:    true,
+			DownloadOptions: DownloadOptions{
+				InsecureSkipVerify:       true,
+				ManifestURLHost:          "mirror-user:s3cr3t-host@mirror.example.com:8443",
+				ManifestURLHostAllowlist: []string{"mirror-user:s3cr3t-host@other.example.com"},
+			},
+			ApplyOptions: ApplyOptions{
+				AgentNodeSelector:           map[string]string{"node-role": "infra"},
+				AdditionalManifest:          secretManifest,
+				AdditionalManifestConfigMap: client.ObjectKey{Namespace: "rancher-turtles-system", Name: "extra"},
+			},
+			SelectionOptions: SelectionOptions{
+				ImportSchedule:              importSchedule,
+				SupportedKubernetesVersions: semver.MustParseRange(">=1.27.0"),
+				ClusterSelector:             &metav1.LabelSelector{MatchLabels: map[string]string{"env": "dev"}},
+				WatchNamespaces:             []string{"team-a", "team-b"},
+			},
+			TimingOptions: TimingOptions{
+				RegistrationCheckWindow: 5 * time.Minute,
+				MaxImportAttempts:       5,
+				ImportBackoffInterval:   time.Hour,
+			},
+			RancherClusterOptions: RancherClusterOptions{
+				RancherClusterNamespace: "fleet-default",
+				NameTemplate:            tmpl,
+				NamePolicy:              policy,
+				AccessLabels:            []string{"example.com/team"},
+			},
+		}
+	})
+
+	It("should report the configured options", func() {
+		config := r.Config()
+
+		Expect(config.ImportLabel).To(Equal(importLabelName))
+		Expect(config.OwnedLabel).To(Equal(ownedLabelName))
+		Expect(config.RemoteRancher).To(BeTrue())
+		Expect(config.WatchFilterValue).To(Equal("team-a"))
+		Expect(config.InsecureSkipVerify).To(BeTrue())
+		Expect(config.RancherClusterNamespace).To(Equal("fleet-default"))
+		Expect(config.RegistrationCheckWindow).To(Equal("5m0s"))
+		Expect(config.DisconnectedThreshold).To(Equal("0s"))
+		Expect(config.ImportWindows).To(Equal([]string{"Sat,Sun 22:00-06:00"}))
+		Expect(config.ImportWindowsTimezone).To(Equal("Europe/Berlin"))
+		Expect(config.AgentNodeSelector).To(HaveKeyWithValue("node-role", "infra"))
+		Expect(config.AdditionalManifestConfigMap).To(Equal("rancher-turtles-system/extra"))
+		Expect(config.ManifestCacheConfigMap).To(BeEmpty())
+		Expect(config.SupportedKubernetesVersionsSet).To(BeTrue())
+		Expect(config.MaxImportAttempts).To(Equal(5))
+		Expect(config.ImportBackoffInterval).To(Equal("1h0m0s"))
+		Expect(config.NameTemplate).To(Equal(`{{ .Namespace }}-{{ .Name }}`))
+		Expect(config.NamePolicy).To(Equal(`bu1-.+`))
+		Expect(config.ClusterSelector).To(Equal("env=dev"))
+		Expect(config.WatchNamespaces).To(Equal([]string{"team-a", "team-b"}))
+		Expect(config.AccessLabels).To(Equal([]string{"example.com/team"}))
+		Expect(config.FeatureGates).To(HaveKeyWithValue(string(feature.RancherKubeSecretPatch),
+			feature.Gates.Enabled(feature.RancherKubeSecretPatch)))
+	})
+
+	It("should redact sensitive values", func() {
+		config := r.Config()
+
+		Expect(config.ManifestURLHost).To(Equal(redacted + "@mirror.example.com:8443"))
+		Expect(config.ManifestURLHostAllowlist).To(ConsistOf(redacted + "@other.example.com"))
+		Expect(config.AdditionalManifest).To(Equal(redacted + " (94 bytes)"))
+
+		data, err := json.Marshal(config)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(data)).ToNot(ContainSubstring("s3cr3t"))
+	})
+
+	It("should serve the configuration to authorized users only", func() {
+		get := func(token string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(http.MethodGet, "/debug/config", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+
+			rec := httptest.NewRecorder()
+			r.ConfigHandler().ServeHTTP(rec, req)
+
+			return rec
+		}
+
+		Expect(get("unknown-token").Code).To(Equal(http.StatusUnauthorized))
+
+		rec := get("admin-token")
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		Expect(rec.Body.String()).ToNot(ContainSubstring("s3cr3t"))
+
+		served := ReconcilerConfig{}
+		Expect(json.Unmarshal(rec.Body.Bytes(), &served)).To(Succeed())
+		Expect(served).To(Equal(r.Config()))
+	})
+})
@@ -0,0 +1,145 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	turtlesv1 "github.com/rancher/turtles/api/v1alpha1"
+	provisioningv1 "github.com/rancher/turtles/internal/rancher/provisioning/v1"
+	"github.com/rancher/turtles/util"
+	turtlesannotations "github.com/rancher/turtles/util/annotations"
+)
+
+// createRancherCluster creates the missing Rancher cluster of a CAPI cluster marked for import, once the CAPI cluster
+// passes the checks required before writing to Rancher.
+func (r *CAPIImportReconciler) createRancherCluster(ctx context.Context, capiCluster *clusterv1.Cluster,
+	rancherCluster *provisioningv1.Cluster,
+) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	importSource, err := util.AutoImportSource(ctx, log, r.Client, capiCluster, r.importLabel())
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if importSource == util.ImportSourceNone {
+		log.Info("not auto importing cluster as namespace or cluster isn't marked auto import")
+
+		recordImportSkipped(capiCluster, turtlesv1.ImportSkippedReason)
+
+		if err := r.recordNamespaceSkip(ctx, capiCluster); err != nil {
+			return ctrl.Result{}, err
+		}
+
+		return ctrl.Result{}, nil
+	}
+
+	// The name only depends on the CAPI cluster metadata, whose changes trigger a new reconcile.
+	if !r.checkNamePolicy(capiCluster, rancherCluster.Name) {
+		log.Info("rancher cluster name violates the naming policy, skipping import")
+		recordImportSkipped(capiCluster, turtlesv1.NamePolicyViolationReason)
+
+		return ctrl.Result{}, nil
+	}
+
+	if requeueAfter, open := r.importWindowOpen(capiCluster); !open {
+		log.Info("outside of the import maintenance window, requeue", "after", requeueAfter)
+		recordImportSkipped(capiCluster, turtlesv1.WaitingForImportWindowReason)
+
+		return ctrl.Result{RequeueAfter: requeueAfter}, nil
+	}
+
+	supported, err := r.checkKubernetesVersion(ctx, capiCluster)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if !supported {
+		recordImportSkipped(capiCluster, turtlesv1.UnsupportedKubernetesVersionReason)
+		return ctrl.Result{RequeueAfter: defaultRequeueDuration}, nil
+	}
+
+	available, err := r.checkRancherCapacity(ctx, capiCluster)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if !available {
+		recordImportSkipped(capiCluster, turtlesv1.RancherAtCapacityReason)
+		return ctrl.Result{RequeueAfter: defaultRequeueDuration}, nil
+	}
+
+	exists, err := r.ensureRancherNamespace(ctx, capiCluster, rancherCluster.Namespace)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if !exists {
+		recordImportSkipped(capiCluster, turtlesv1.RancherNamespaceMissingReason)
+		return ctrl.Result{RequeueAfter: defaultRequeueDuration}, nil
+	}
+
+	newCluster := &provisioningv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      rancherCluster.Name,
+			Namespace: rancherCluster.Namespace,
+			Labels: map[string]string{
+				r.ownedLabel(): "",
+			},
+			Annotations: r.importedByVersion(map[string]string{
+				turtlesannotations.CAPIClusterNameAnnotation: capiCluster.Name,
+			}),
+		},
+	}
+	mirrorLabels(capiCluster, newCluster, r.LabelsToRancher, r.reservedLabels()...)
+	mirrorAnnotations(capiCluster, newCluster, r.AnnotationsToRancher)
+	linkRancherCluster(capiCluster, newCluster, r.ownerReferenceLink(capiCluster, newCluster))
+
+	if err := r.rancherClusters().Create(ctx, r.RancherClient, newCluster); err != nil {
+		recordImportFailure(capiCluster, rancherClusterCreateFailed)
+		return ctrl.Result{}, fmt.Errorf("error creating rancher cluster: %w", err)
+	}
+
+	r.managed.track(client.ObjectKeyFromObject(capiCluster), true)
+
+	if err := r.recordTimeline(ctx, newCluster, timelinePhaseCreated,
+		fmt.Sprintf("Created for CAPI cluster %s", client.ObjectKeyFromObject(capiCluster))); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	log.Info("created rancher cluster", "importSource", importSource)
+
+	if r.MirrorRancherReadiness {
+		conditions.MarkFalse(capiCluster, turtlesv1.RancherClusterReadyCondition, turtlesv1.RancherClusterNotReadyReason,
+			clusterv1.ConditionSeverityInfo, "Rancher cluster %s created", client.ObjectKeyFromObject(newCluster))
+	}
+	setAnnotation(capiCluster, turtlesannotations.ImportSourceAnnotation, string(importSource))
+	capiCluster.SetAnnotations(r.importedByVersion(capiCluster.GetAnnotations()))
+
+	r.requeues.reset(client.ObjectKeyFromObject(capiCluster))
+
+	return r.requeueWaitingOnRancher(capiCluster, turtlesv1.WaitingForClusterNameReason), nil
+}
@@ -0,0 +1,311 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/clock"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	turtlesv1 "github.com/rancher/turtles/api/v1alpha1"
+)
+
+const redacted = "REDACTED"
+
+// secretPatterns match the credentials which can leak into error messages: the registration manifest URLs embed the
+// cluster registration token, and kubeconfigs carry tokens, passwords and client keys.
+var secretPatterns = []struct {
+	pattern     *regexp.Regexp
+	replacement string
+}{
+	{regexp.MustCompile(`(https?://[^/\s"']+)[^\s"']*`), "${1}/" + redacted},
+	{regexp.MustCompile(`(?i)(bearer\s+)\S+`), "${1}" + redacted},
+	{regexp.MustCompile(`(?i)((?:token|password|client-key-data|client-certificate-data)["']?\s*[:=]\s*["']?)[^\s"',]+`),
+		"${1}" + redacted},
+}
+
+// ReconcilerState is a snapshot of the in-memory state of the import reconciler, for diagnosing stuck reconciles.
+type ReconcilerState struct {
+	// RemoteClients are the CAPI clusters whose downstream client is cached.
+	RemoteClients []CachedRemoteClientState `json:"remoteClients"`
+	// InFlight are the CAPI clusters being reconciled.
+	InFlight []InFlightImportState `json:"inFlight"`
+	// Clusters are the outcomes of the last reconcile of each CAPI cluster.
+	Clusters []ClusterReconcileState `json:"clusters"`
+	// NamespaceEvents are the times the last event was emitted on the namespaces not marked for import.
+	NamespaceEvents map[string]time.Time `json:"namespaceEvents"`
+}
+
+// CachedRemoteClientState describes a cached downstream cluster client.
+type CachedRemoteClientState struct {
+	Cluster string    `json:"cluster"`
+	UID     types.UID `json:"uid"`
+}
+
+// InFlightImportState describes a reconcile in progress.
+type InFlightImportState struct {
+	Cluster string    `json:"cluster"`
+	Started time.Time `json:"started"`
+}
+
+// ClusterReconcileState describes the outcome of the last reconcile of a CAPI cluster. BackingOff reports the import
+// attempts were exhausted and the import is only retried at RetryAt.
+type ClusterReconcileState struct {
+	Cluster       string     `json:"cluster"`
+	LastReconcile time.Time  `json:"lastReconcile"`
+	LastError     string     `json:"lastError,omitempty"`
+	LastErrorTime *time.Time `json:"lastErrorTime,omitempty"`
+	BackingOff    bool       `json:"backingOff"`
+	RetryAt       *time.Time `json:"retryAt,omitempty"`
+}
+
+// reconcileTracker records the reconciles in progress and the outcome of the last reconcile of each CAPI cluster.
+type reconcileTracker struct {
+	lock     sync.Mutex
+	inFlight map[client.ObjectKey]time.Time
+	clusters map[client.ObjectKey]ClusterReconcileState
+}
+
+// start records the reconcile of the CAPI cluster as in progress.
+func (t *reconcileTracker) start(key client.ObjectKey, now time.Time) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.inFlight == nil {
+		t.inFlight = map[client.ObjectKey]time.Time{}
+	}
+
+	t.inFlight[key] = now
+}
+
+// done records the reconcile of the CAPI cluster as finished.
+func (t *reconcileTracker) done(key client.ObjectKey) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	delete(t.inFlight, key)
+}
+
+// observe records the outcome of the reconcile of the CAPI cluster. The last error is kept until a reconcile succeeds.
+func (t *reconcileTracker) observe(capiCluster *clusterv1.Cluster, reconcileErr error, now time.Time,
+	backoffInterval time.Duration,
+) {
+	key := client.ObjectKeyFromObject(capiCluster)
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.clusters == nil {
+		t.clusters = map[client.ObjectKey]ClusterReconcileState{}
+	}
+
+	state := t.clusters[key]
+	state.Cluster = key.String()
+	state.LastReconcile = now
+	state.BackingOff = false
+	state.RetryAt = nil
+
+	if reconcileErr == nil {
+		state.LastError = ""
+		state.LastErrorTime = nil
+	} else {
+		errorTime := now
+		state.LastError = redactSecrets(reconcileErr.Error())
+		state.LastErrorTime = &errorTime
+	}
+
+	if conditions.GetReason(capiCluster, turtlesv1.ImportAttemptsCondition) == turtlesv1.ImportAttemptsExhaustedReason {
+		retryAt := now.Add(backoffInterval)
+		state.BackingOff = true
+		state.RetryAt = &retryAt
+	}
+
+	t.clusters[key] = state
+}
+
+// forget drops the state of a CAPI cluster which no longer exists.
+func (t *reconcileTracker) forget(key client.ObjectKey) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	delete(t.clusters, key)
+}
+
+// DebugState returns a snapshot of the in-memory state of the reconciler. It is safe to call concurrently with
+// reconciles, and never includes the cached clients themselves nor any credential.
+func (r *CAPIImportReconciler) DebugState() ReconcilerState {
+	state := ReconcilerState{
+		RemoteClients:   []CachedRemoteClientState{},
+		InFlight:        []InFlightImportState{},
+		Clusters:        []ClusterReconcileState{},
+		NamespaceEvents: map[string]time.Time{},
+	}
+
+	r.remoteClients.lock.Lock()
+	for key, cached := range r.remoteClients.clients {
+		state.RemoteClients = append(state.RemoteClients, CachedRemoteClientState{Cluster: key.String(), UID: cached.uid})
+	}
+	r.remoteClients.lock.Unlock()
+
+	r.reconciles.lock.Lock()
+	for key, started := range r.reconciles.inFlight {
+		state.InFlight = append(state.InFlight, InFlightImportState{Cluster: key.String(), Started: started})
+	}
+
+	for _, cluster := range r.reconciles.clusters {
+		state.Clusters = append(state.Clusters, cluster)
+	}
+	r.reconciles.lock.Unlock()
+
+	r.namespaceEventsLock.Lock()
+	for namespace, last := range r.namespaceEvents {
+		state.NamespaceEvents[namespace] = last
+	}
+	r.namespaceEventsLock.Unlock()
+
+	sort.Slice(state.RemoteClients, func(i, j int) bool {
+		return state.RemoteClients[i].Cluster < state.RemoteClients[j].Cluster
+	})
+	sort.Slice(state.InFlight, func(i, j int) bool { return state.InFlight[i].Cluster < state.InFlight[j].Cluster })
+	sort.Slice(state.Clusters, func(i, j int) bool { return state.Clusters[i].Cluster < state.Clusters[j].Cluster })
+
+	return state
+}
+
+// DebugHandler returns a handler serving the DebugState of the reconciler as JSON. Requests must carry the bearer
+// token of a user allowed to get the requested path in the management cluster.
+func (r *CAPIImportReconciler) DebugHandler() http.Handler {
+	return r.debugJSONHandler(func() any { return r.DebugState() })
+}
+
+// debugJSONHandler returns a handler serving the value returned by dump as JSON to authorized GET requests.
+func (r *CAPIImportReconciler) debugJSONHandler(dump func() any) http.Handler {
+	return r.authorizedJSONHandler(func(*http.Request) (any, int) { return dump(), http.StatusOK })
+}
+
+// authorizedJSONHandler returns a handler serving the value returned by serve as JSON to authorized GET requests, or
+// only the text of the status it returns when it is not OK.
+func (r *CAPIImportReconciler) authorizedJSONHandler(serve func(req *http.Request) (any, int)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+
+			return
+		}
+
+		if status := r.authorizeDebugRequest(req); status != http.StatusOK {
+			http.Error(w, http.StatusText(status), status)
+			return
+		}
+
+		value, status := serve(req)
+		if status != http.StatusOK {
+			http.Error(w, http.StatusText(status), status)
+			return
+		}
+
+		data, err := json.MarshalIndent(value, "", "  ")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(data)
+	})
+}
+
+// authorizeDebugRequest reviews the bearer token of the request and the access of its user to the requested path,
+// and returns the HTTP status the request is answered with when it is not allowed.
+func (r *CAPIImportReconciler) authorizeDebugRequest(req *http.Request) int {
+	log := log.FromContext(req.Context())
+
+	token, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return http.StatusUnauthorized
+	}
+
+	review := &authenticationv1.TokenReview{Spec: authenticationv1.TokenReviewSpec{Token: token}}
+	if err := r.Client.Create(req.Context(), review); err != nil {
+		log.Error(err, "reviewing debug request token")
+		return http.StatusInternalServerError
+	}
+
+	if !review.Status.Authenticated {
+		return http.StatusUnauthorized
+	}
+
+	user := review.Status.User
+
+	extra := map[string]authorizationv1.ExtraValue{}
+	for key, values := range user.Extra {
+		extra[key] = authorizationv1.ExtraValue(values)
+	}
+
+	access := &authorizationv1.SubjectAccessReview{Spec: authorizationv1.SubjectAccessReviewSpec{
+		User:   user.Username,
+		UID:    user.UID,
+		Groups: user.Groups,
+		Extra:  extra,
+		NonResourceAttributes: &authorizationv1.NonResourceAttributes{
+			Path: req.URL.Path,
+			Verb: strings.ToLower(req.Method),
+		},
+	}}
+	if err := r.Client.Create(req.Context(), access); err != nil {
+		log.Error(err, "reviewing debug request access")
+		return http.StatusInternalServerError
+	}
+
+	if !access.Status.Allowed {
+		return http.StatusForbidden
+	}
+
+	return http.StatusOK
+}
+
+// redactSecrets masks the credentials found in the message.
+func redactSecrets(message string) string {
+	for _, secret := range secretPatterns {
+		message = secret.pattern.ReplaceAllString(message, secret.replacement)
+	}
+
+	return message
+}
+
+func (r *CAPIImportReconciler) now() time.Time {
+	if r.clock == nil {
+		return clock.RealClock{}.Now()
+	}
+
+	return r.clock.Now()
+}
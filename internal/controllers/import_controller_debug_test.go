@@ -0,0 +1,265 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	clocktesting "k8s.io/utils/clock/testing"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	provisioningv1 "github.com/rancher/turtles/internal/rancher/provisioning/v1"
+)
+
+var _ = Describe("reconciler debug state", func() {
+	const (
+		registrationToken = "t0k3nsecretvalue"
+		kubeconfigToken   = "kubeconfig-bearer-secret"
+	)
+
+	var (
+		r           *CAPIImportReconciler
+		capiCluster *clusterv1.Cluster
+		fakeClock   *clocktesting.FakeClock
+		rancherErr  error
+		reviewed    []*authorizationv1.SubjectAccessReview
+		users       map[string]bool
+	)
+
+	clusterKey := client.ObjectKey{Namespace: "test-ns", Name: "test-cluster"}
+
+	get := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/debug/import", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		rec := httptest.NewRecorder()
+		r.DebugHandler().ServeHTTP(rec, req)
+
+		return rec
+	}
+
+	BeforeEach(func() {
+		fakeClock = clocktesting.NewFakeClock(time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC))
+		rancherErr = errors.New(`Get "https://rancher.example.com/v3/import/` + registrationToken +
+			`_c-m-abc.yaml": token: ` + kubeconfigToken)
+		reviewed = nil
+		users = map[string]bool{"admin-token": true, "viewer-token": false}
+
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test-ns"}}
+		capiCluster = &clusterv1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      clusterKey.Name,
+				Namespace: clusterKey.Namespace,
+				UID:       "capi-uid",
+				Labels:    map[string]string{importLabelName: "true"},
+			},
+			Status: clusterv1.ClusterStatus{ControlPlaneReady: true},
+		}
+
+		r = &CAPIImportReconciler{
+			Client: fake.NewClientBuilder().WithScheme(scheme.Scheme).
+				WithObjects(ns, capiCluster.DeepCopy()).WithStatusSubresource(&clusterv1.Cluster{}).
+				WithInterceptorFuncs(interceptor.Funcs{
+					Create: func(ctx context.Context, cl client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+						switch review := obj.(type) {
+						case *authenticationv1.TokenReview:
+							allowed, ok := users[review.Spec.Token]
+							review.Status.Authenticated = ok
+							review.Status.User = authenticationv1.UserInfo{Username: review.Spec.Token, Extra: map[string]authenticationv1.ExtraValue{
+								"allowed": {map[bool]string{true: "yes", false: "no"}[allowed]},
+							}}
+
+							return nil
+						case *authorizationv1.SubjectAccessReview:
+							reviewed = append(reviewed, review.DeepCopy())
+							review.Status.Allowed = users[review.Spec.User]
+
+							return nil
+						}
+
+						return cl.Create(ctx, obj, opts...)
+					},
+				}).Build(),
+			RancherClient: fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(ns.DeepCopy()).
+				WithInterceptorFuncs(interceptor.Funcs{
+					Get: func(ctx context.Context, cl client.WithWatch, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+						if _, ok := obj.(*provisioningv1.Cluster); ok && rancherErr != nil {
+							return rancherErr
+						}
+
+						return cl.Get(ctx, key, obj, opts...)
+					},
+				}).Build(),
+			recorder: record.NewFakeRecorder(100),
+			clock:    fakeClock,
+		}
+	})
+
+	It("should reflect the seeded state", func() {
+		r.remoteClients.clients = map[client.ObjectKey]cachedRemoteClient{
+			clusterKey: {uid: "capi-uid", client: r.Client},
+		}
+		r.namespaceEvents = map[string]time.Time{"other-ns": fakeClock.Now()}
+		r.reconciles.start(client.ObjectKey{Namespace: "test-ns", Name: "in-flight"}, fakeClock.Now())
+		r.reconciles.observe(capiCluster, errors.New("rancher unavailable"), fakeClock.Now(), time.Hour)
+
+		state := r.DebugState()
+		Expect(state.RemoteClients).To(Equal([]CachedRemoteClientState{{Cluster: "test-ns/test-cluster", UID: "capi-uid"}}))
+		Expect(state.InFlight).To(Equal([]InFlightImportState{{Cluster: "test-ns/in-flight", Started: fakeClock.Now()}}))
+		Expect(state.NamespaceEvents).To(HaveKeyWithValue("other-ns", fakeClock.Now()))
+		Expect(state.Clusters).To(HaveLen(1))
+		Expect(state.Clusters[0].Cluster).To(Equal("test-ns/test-cluster"))
+		Expect(state.Clusters[0].LastError).To(Equal("rancher unavailable"))
+		Expect(state.Clusters[0].BackingOff).To(BeFalse())
+	})
+
+	It("should record the last error of a reconcile with its secrets redacted", func() {
+		_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: clusterKey})
+		Expect(err).To(HaveOccurred())
+
+		state := r.DebugState()
+		Expect(state.InFlight).To(BeEmpty())
+		Expect(state.Clusters).To(HaveLen(1))
+		Expect(state.Clusters[0].LastError).To(ContainSubstring("https://rancher.example.com/REDACTED"))
+		Expect(state.Clusters[0].LastError).To(ContainSubstring("token: REDACTED"))
+		Expect(state.Clusters[0].LastError).ToNot(ContainSubstring(registrationToken))
+		Expect(state.Clusters[0].LastError).ToNot(ContainSubstring(kubeconfigToken))
+		Expect(*state.Clusters[0].LastErrorTime).To(Equal(fakeClock.Now()))
+
+		rancherErr = nil
+
+		_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: clusterKey})
+		Expect(err).ToNot(HaveOccurred())
+
+		state = r.DebugState()
+		Expect(state.Clusters[0].LastError).To(BeEmpty())
+		Expect(state.Clusters[0].LastErrorTime).To(BeNil())
+	})
+
+	It("should report the clusters backing off after exhausting their import attempts", func() {
+		r.MaxImportAttempts = 1
+		r.ImportBackoffInterval = time.Hour
+
+		_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: clusterKey})
+		Expect(err).ToNot(HaveOccurred())
+
+		state := r.DebugState()
+		Expect(state.Clusters).To(HaveLen(1))
+		Expect(state.Clusters[0].BackingOff).To(BeTrue())
+		Expect(*state.Clusters[0].RetryAt).To(Equal(fakeClock.Now().Add(time.Hour)))
+	})
+
+	It("should forget the clusters which no longer exist", func() {
+		r.reconciles.observe(capiCluster, errors.New("rancher unavailable"), fakeClock.Now(), time.Hour)
+		Expect(r.Client.Delete(ctx, capiCluster)).To(Succeed())
+
+		_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: clusterKey})
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(r.DebugState().Clusters).To(BeEmpty())
+	})
+
+	It("should serve the state as JSON to authorized users only", func() {
+		_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: clusterKey})
+		Expect(err).To(HaveOccurred())
+
+		Expect(get("").Code).To(Equal(http.StatusUnauthorized))
+		Expect(get("unknown-token").Code).To(Equal(http.StatusUnauthorized))
+		Expect(get("viewer-token").Code).To(Equal(http.StatusForbidden))
+
+		rec := get("admin-token")
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		Expect(rec.Header().Get("Content-Type")).To(Equal("application/json"))
+		Expect(rec.Body.String()).ToNot(ContainSubstring(registrationToken))
+		Expect(rec.Body.String()).ToNot(ContainSubstring(kubeconfigToken))
+
+		state := ReconcilerState{}
+		Expect(json.Unmarshal(rec.Body.Bytes(), &state)).To(Succeed())
+		Expect(state.Clusters).To(HaveLen(1))
+		Expect(state.Clusters[0].Cluster).To(Equal("test-ns/test-cluster"))
+
+		last := reviewed[len(reviewed)-1]
+		Expect(last.Spec.User).To(Equal("admin-token"))
+		Expect(last.Spec.Extra).To(HaveKeyWithValue("allowed", authorizationv1.ExtraValue{"yes"}))
+		Expect(last.Spec.NonResourceAttributes).To(Equal(&authorizationv1.NonResourceAttributes{
+			Path: "/debug/import",
+			Verb: "get",
+		}))
+	})
+
+	It("should be safe to dump concurrently with reconciles", func() {
+		var wg sync.WaitGroup
+
+		for i := 0; i < 10; i++ {
+			wg.Add(2)
+
+			go func() {
+				defer GinkgoRecover()
+				defer wg.Done()
+
+				r.reconciles.start(clusterKey, fakeClock.Now())
+				r.reconciles.observe(capiCluster, errors.New("rancher unavailable"), fakeClock.Now(), time.Hour)
+				r.reconciles.done(clusterKey)
+			}()
+
+			go func() {
+				defer GinkgoRecover()
+				defer wg.Done()
+
+				_, err := json.Marshal(r.DebugState())
+				Expect(err).ToNot(HaveOccurred())
+			}()
+		}
+
+		wg.Wait()
+
+		Expect(r.DebugState().InFlight).To(BeEmpty())
+	})
+})
+
+var _ = Describe("redactSecrets", func() {
+	DescribeTable("should mask credentials",
+		func(message, expected string) {
+			Expect(redactSecrets(message)).To(Equal(expected))
+		},
+		Entry("manifest URL", `downloading manifest: Get "https://rancher/v3/import/abc_c-m-1.yaml": EOF`,
+			`downloading manifest: Get "https://rancher/REDACTED": EOF`),
+		Entry("bearer token", "Authorization: Bearer abc.def", "Authorization: Bearer REDACTED"),
+		Entry("kubeconfig fields", "token: abc, client-key-data: ZGF0YQ==", "token: REDACTED, client-key-data: REDACTED"),
+		Entry("plain message", "rancher unavailable", "rancher unavailable"),
+	)
+})
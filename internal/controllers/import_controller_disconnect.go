@@ -0,0 +1,110 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	turtlesv1 "github.com/rancher/turtles/api/v1alpha1"
+	provisioningv1 "github.com/rancher/turtles/internal/rancher/provisioning/v1"
+)
+
+// escalateDisconnected reports a running agent which couldn't reach Rancher for longer than the disconnected threshold
+// with the ImportDegraded condition and a warning event, re-applying the import manifest when ReapplyOnDisconnect is set.
+// The escalation only happens once until the Rancher cluster becomes ready again.
+func (r *CAPIImportReconciler) escalateDisconnected(ctx context.Context, capiCluster *clusterv1.Cluster,
+	rancherCluster *provisioningv1.Cluster, elapsed time.Duration,
+) error {
+	log := log.FromContext(ctx)
+
+	if conditions.IsTrue(capiCluster, turtlesv1.ImportDegradedCondition) {
+		return nil
+	}
+
+	message := fmt.Sprintf("Agent is deployed but the Rancher cluster has not been ready for %s. "+
+		"Check the network policies of the downstream cluster allow the agent to reach the Rancher server-url",
+		elapsed.Round(time.Second))
+
+	log.Info("Downstream agent is disconnected from Rancher", "duration", elapsed)
+	conditions.Set(capiCluster, &clusterv1.Condition{
+		Type:    turtlesv1.ImportDegradedCondition,
+		Status:  corev1.ConditionTrue,
+		Reason:  turtlesv1.AgentDisconnectedReason,
+		Message: message,
+	})
+	r.recorder.Event(capiCluster, corev1.EventTypeWarning, turtlesv1.AgentDisconnectedReason, message)
+
+	if !r.ReapplyOnDisconnect {
+		return nil
+	}
+
+	if !r.allowReapply(client.ObjectKeyFromObject(capiCluster)) {
+		log.Info("Skipping import manifest re-application, the cluster was re-applied less than the minimum interval ago",
+			"interval", r.MinReapplyInterval)
+		reappliesSuppressed.WithLabelValues(clusterProvider(capiCluster)).Inc()
+
+		return nil
+	}
+
+	log.Info("Re-applying import manifest to the disconnected cluster")
+
+	if _, err := r.applyImportManifest(ctx, capiCluster, rancherCluster); err != nil {
+		return fmt.Errorf("re-applying import manifest: %w", err)
+	}
+
+	return nil
+}
+
+// allowReapply reports whether the import manifest can be re-applied to the cluster, recording the re-application
+// when it can. Re-applications are allowed at most once per MinReapplyInterval for each cluster.
+func (r *CAPIImportReconciler) allowReapply(key client.ObjectKey) bool {
+	if r.MinReapplyInterval == 0 {
+		return true
+	}
+
+	r.reappliesLock.Lock()
+	defer r.reappliesLock.Unlock()
+
+	now := r.clock.Now()
+	if last, ok := r.reapplies[key]; ok && now.Sub(last) < r.MinReapplyInterval {
+		return false
+	}
+
+	if r.reapplies == nil {
+		r.reapplies = map[client.ObjectKey]time.Time{}
+	}
+
+	r.reapplies[key] = now
+
+	return true
+}
+
+// forgetReapply drops the last re-application time of a deleted cluster.
+func (r *CAPIImportReconciler) forgetReapply(key client.ObjectKey) {
+	r.reappliesLock.Lock()
+	defer r.reappliesLock.Unlock()
+
+	delete(r.reapplies, key)
+}
@@ -0,0 +1,55 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+
+	provisioningv1 "github.com/rancher/turtles/internal/rancher/provisioning/v1"
+)
+
+// reconcileEagerCreate creates the Rancher cluster of a CAPI cluster whose control plane is not ready yet, so that
+// Rancher sets it up while the control plane comes up. Nothing else is done until the control plane is ready, which
+// is when the downstream cluster is reachable and the registration manifest is applied.
+func (r *CAPIImportReconciler) reconcileEagerCreate(ctx context.Context, capiCluster *clusterv1.Cluster) error {
+	rancherClusterKey, err := r.rancherClusterKey(capiCluster)
+	if err != nil {
+		return err
+	}
+
+	rancherCluster := &provisioningv1.Cluster{ObjectMeta: metav1.ObjectMeta{
+		Namespace: rancherClusterKey.Namespace,
+		Name:      rancherClusterKey.Name,
+	}}
+
+	err = r.getRancherCluster(ctx, rancherCluster)
+	if !apierrors.IsNotFound(err) {
+		return err
+	}
+
+	log.FromContext(ctx).Info("Creating rancher cluster before the control plane is ready")
+
+	_, err = r.createRancherCluster(ctx, capiCluster, rancherCluster)
+
+	return err
+}
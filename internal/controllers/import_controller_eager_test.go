@@ -0,0 +1,162 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	turtlesv1 "github.com/rancher/turtles/api/v1alpha1"
+	provisioningv1 "github.com/rancher/turtles/internal/rancher/provisioning/v1"
+	"github.com/rancher/turtles/testutil"
+)
+
+var _ = Describe("eager Rancher cluster creation", func() {
+	var (
+		r              *CAPIImportReconciler
+		server         *testutil.ManifestServer
+		remoteClient   client.Client
+		remoteRequests int
+		capiCluster    *clusterv1.Cluster
+		rancherKey     client.ObjectKey
+	)
+
+	reconcileCluster := func() ctrl.Result {
+		res, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(capiCluster)})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(r.Client.Get(ctx, client.ObjectKeyFromObject(capiCluster), capiCluster)).To(Succeed())
+
+		return res
+	}
+
+	rancherCluster := func() *provisioningv1.Cluster {
+		cluster := &provisioningv1.Cluster{}
+
+		err := r.RancherClient.Get(ctx, rancherKey, cluster)
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+
+		Expect(err).ToNot(HaveOccurred())
+
+		return cluster
+	}
+
+	manifestApplied := func() bool {
+		err := remoteClient.Get(ctx, client.ObjectKey{Namespace: "cattle-system", Name: "cattle-config"}, &corev1.ConfigMap{})
+		if apierrors.IsNotFound(err) {
+			return false
+		}
+
+		Expect(err).ToNot(HaveOccurred())
+
+		return true
+	}
+
+	BeforeEach(func() {
+		server = testutil.NewManifestServer(incrementalManifest)
+		DeferCleanup(server.Close)
+
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test-ns"}}
+		capiCluster = &clusterv1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-cluster",
+				Namespace: "test-ns",
+				Labels:    map[string]string{importLabelName: "true"},
+			},
+		}
+		rancherKey = client.ObjectKey{Namespace: "test-ns", Name: "test-cluster-capi"}
+
+		remoteRequests = 0
+		remoteClient = fake.NewClientBuilder().WithScheme(scheme.Scheme).Build()
+
+		r = &CAPIImportReconciler{
+			Client: fake.NewClientBuilder().WithScheme(scheme.Scheme).
+				WithObjects(ns, capiCluster.DeepCopy()).WithStatusSubresource(&clusterv1.Cluster{}).Build(),
+			RancherClient: testutil.NewRancherClientBuilder().WithObjects(ns.DeepCopy()).Build(),
+			recorder:      record.NewFakeRecorder(10),
+			remoteClientGetter: func(_ context.Context, _ string, _ client.Client, _ client.ObjectKey) (client.Client, error) {
+				remoteRequests++
+				return remoteClient, nil
+			},
+			SelectionOptions: SelectionOptions{
+				EagerCreate: true,
+			},
+			RancherClusterOptions: RancherClusterOptions{
+				MirrorRancherReadiness: true,
+			},
+		}
+	})
+
+	It("should create the Rancher cluster before the control plane is ready and defer the apply", func() {
+		res := reconcileCluster()
+		Expect(res.RequeueAfter).To(Equal(minRequeueDuration))
+
+		Expect(rancherCluster()).ToNot(BeNil())
+		Expect(conditions.GetMessage(capiCluster, turtlesv1.RancherClusterReadyCondition)).To(ContainSubstring("created"))
+		Expect(conditions.IsFalse(capiCluster, turtlesv1.ControlPlaneWaitCondition)).To(BeTrue())
+
+		created := rancherCluster()
+		created.Status.ClusterName = testutil.ManagementClusterName(created.Name)
+		Expect(r.RancherClient.Status().Update(ctx, created)).To(Succeed())
+		Expect(r.RancherClient.Create(ctx, testutil.RegistrationToken(created.Status.ClusterName, "test-ns", server.URL))).To(Succeed())
+
+		reconcileCluster()
+		Expect(server.Requests()).To(BeZero())
+		Expect(remoteRequests).To(BeZero())
+		Expect(manifestApplied()).To(BeFalse())
+
+		capiCluster.Status.ControlPlaneReady = true
+		Expect(r.Client.Status().Update(ctx, capiCluster)).To(Succeed())
+
+		reconcileCluster()
+		Expect(server.Requests()).To(Equal(1))
+		Expect(manifestApplied()).To(BeTrue())
+		Expect(conditions.IsTrue(capiCluster, turtlesv1.ImportManifestAppliedCondition)).To(BeTrue())
+	})
+
+	It("should not create the Rancher cluster of a cluster not marked for import", func() {
+		Expect(r.Client.Get(ctx, client.ObjectKeyFromObject(capiCluster), capiCluster)).To(Succeed())
+		capiCluster.Labels = nil
+		Expect(r.Client.Update(ctx, capiCluster)).To(Succeed())
+
+		reconcileCluster()
+		Expect(rancherCluster()).To(BeNil())
+	})
+
+	It("should wait for the control plane to create the Rancher cluster when disabled", func() {
+		r.EagerCreate = false
+
+		res := reconcileCluster()
+		Expect(res.RequeueAfter).To(Equal(minRequeueDuration))
+		Expect(rancherCluster()).To(BeNil())
+		Expect(conditions.IsFalse(capiCluster, turtlesv1.ControlPlaneWaitCondition)).To(BeTrue())
+	})
+})
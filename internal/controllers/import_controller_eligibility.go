@@ -0,0 +1,241 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/clock"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	turtlesv1 "github.com/rancher/turtles/api/v1alpha1"
+	"github.com/rancher/turtles/util"
+	turtlesannotations "github.com/rancher/turtles/util/annotations"
+)
+
+// importGate is a check the CAPI cluster must pass to be imported. It returns the reason the cluster fails the
+// check, or an empty string when it passes.
+type importGate struct {
+	name  string
+	check func(ctx context.Context, capiCluster *clusterv1.Cluster) (string, error)
+}
+
+// importGates returns the checks the CAPI cluster must pass to be imported, in evaluation order.
+func (r *CAPIImportReconciler) importGates() []importGate {
+	return []importGate{
+		{name: "ControlPlaneReady", check: r.controlPlaneReadyGate},
+		{name: "ImportLabel", check: r.importLabelGate},
+		{name: "ImportWindow", check: r.importWindowGate},
+		{name: "NamePolicy", check: r.namePolicyGate},
+		{name: "RancherNamespace", check: r.rancherNamespaceGate},
+		{name: "KubernetesVersion", check: r.kubernetesVersionGate},
+		{name: "MachinePoolReady", check: r.machinePoolGate},
+	}
+}
+
+// evaluateEligibility runs all the import gates without writing to Rancher and summarizes them in the ImportEligible
+// condition, listing every failed gate. Errors evaluating a gate are reported as a failure of that gate. The failures
+// are mirrored in the import skipped reason annotation, so that they show up with the cluster metadata.
+func (r *CAPIImportReconciler) evaluateEligibility(ctx context.Context, capiCluster *clusterv1.Cluster) {
+	log := log.FromContext(ctx)
+	failures := []string{}
+
+	for _, gate := range r.importGates() {
+		reason, err := gate.check(ctx, capiCluster)
+		if err != nil {
+			log.Error(err, "unable to evaluate import gate", "gate", gate.name)
+			reason = fmt.Sprintf("unable to evaluate: %s", err)
+		}
+
+		if reason != "" {
+			failures = append(failures, fmt.Sprintf("%s: %s", gate.name, reason))
+		}
+	}
+
+	if len(failures) == 0 {
+		conditions.MarkTrue(capiCluster, turtlesv1.ImportEligibleCondition)
+
+		annotations := capiCluster.GetAnnotations()
+		delete(annotations, turtlesannotations.ImportSkippedReasonAnnotation)
+		capiCluster.SetAnnotations(annotations)
+
+		return
+	}
+
+	reason := strings.Join(failures, "; ")
+
+	conditions.MarkFalse(capiCluster, turtlesv1.ImportEligibleCondition, turtlesv1.ImportNotEligibleReason,
+		clusterv1.ConditionSeverityInfo, "%s", reason)
+	setAnnotation(capiCluster, turtlesannotations.ImportSkippedReasonAnnotation, reason)
+}
+
+func (r *CAPIImportReconciler) controlPlaneReadyGate(_ context.Context, capiCluster *clusterv1.Cluster) (string, error) {
+	if ready, _ := r.ControlPlaneReadiness.Ready(capiCluster); ready {
+		return "", nil
+	}
+
+	return "control plane is not ready", nil
+}
+
+// trackControlPlaneWait records the time the cluster waits for its control plane to be ready. The first not ready
+// observation marks the ControlPlaneWaitCompleted condition false, and once the control plane is ready the wait since
+// that transition is observed in the metric and the condition is marked true. Clusters which were never observed
+// not ready are left untouched. It returns true if the condition changed.
+func (r *CAPIImportReconciler) trackControlPlaneWait(capiCluster *clusterv1.Cluster, ready bool) bool {
+	condition := conditions.Get(capiCluster, turtlesv1.ControlPlaneWaitCondition)
+
+	clk := r.clock
+	if clk == nil {
+		clk = clock.RealClock{}
+	}
+
+	now := metav1.NewTime(clk.Now().UTC().Truncate(time.Second))
+
+	if !ready {
+		if condition != nil && condition.Status == corev1.ConditionFalse {
+			return false
+		}
+
+		setConditionAt(capiCluster, &clusterv1.Condition{
+			Type:     turtlesv1.ControlPlaneWaitCondition,
+			Status:   corev1.ConditionFalse,
+			Reason:   turtlesv1.WaitingForControlPlaneReason,
+			Severity: clusterv1.ConditionSeverityInfo,
+			Message:  "control plane is not ready",
+		}, now)
+
+		return true
+	}
+
+	if condition == nil || condition.Status != corev1.ConditionFalse {
+		return false
+	}
+
+	waited := now.Sub(condition.LastTransitionTime.Time)
+	controlPlaneWaitSeconds.WithLabelValues(clusterProvider(capiCluster)).Observe(waited.Seconds())
+
+	setConditionAt(capiCluster, &clusterv1.Condition{
+		Type:    turtlesv1.ControlPlaneWaitCondition,
+		Status:  corev1.ConditionTrue,
+		Message: fmt.Sprintf("waited %s for the control plane to be ready", waited),
+	}, now)
+
+	return true
+}
+
+// trackImportDuration records the import duration of a cluster whose Rancher cluster just became ready, measured
+// from the last transition of the cluster to eligible for import. It must be called before the readiness of the
+// Rancher cluster is mirrored in the RancherClusterReady condition, so that each import is only recorded once. Without
+// the mirror, a new import can't be told apart from a cluster already imported and nothing is recorded.
+func (r *CAPIImportReconciler) trackImportDuration(ctx context.Context, capiCluster *clusterv1.Cluster, ready bool) {
+	if !r.MirrorRancherReadiness || !ready || conditions.IsTrue(capiCluster, turtlesv1.RancherClusterReadyCondition) {
+		return
+	}
+
+	eligible := conditions.Get(capiCluster, turtlesv1.ImportEligibleCondition)
+	if eligible == nil || eligible.Status != corev1.ConditionTrue {
+		return
+	}
+
+	duration := r.now().Sub(eligible.LastTransitionTime.Time)
+	if duration < 0 {
+		duration = 0
+	}
+
+	log.FromContext(ctx).Info("Cluster imported", "duration", duration)
+
+	recordImportDuration(capiCluster, duration, r.ImportSLOThreshold)
+}
+
+// setConditionAt sets the condition with the given transition time, as conditions.Set always uses the current time
+// when the status changes.
+func setConditionAt(capiCluster *clusterv1.Cluster, condition *clusterv1.Condition, at metav1.Time) {
+	condition.LastTransitionTime = at
+
+	conditions.Delete(capiCluster, condition.Type)
+	conditions.Set(capiCluster, condition)
+}
+
+func (r *CAPIImportReconciler) importLabelGate(ctx context.Context, capiCluster *clusterv1.Cluster) (string, error) {
+	source, err := util.AutoImportSource(ctx, log.FromContext(ctx), r.Client, capiCluster, r.importLabel())
+	if err != nil {
+		return "", err
+	}
+
+	if source == util.ImportSourceNone {
+		return fmt.Sprintf("neither the cluster nor its namespace are labeled with %s=true", r.importLabel()), nil
+	}
+
+	return "", nil
+}
+
+func (r *CAPIImportReconciler) importWindowGate(_ context.Context, _ *clusterv1.Cluster) (string, error) {
+	if r.ImportSchedule == nil {
+		return "", nil
+	}
+
+	now := r.clock.Now()
+	if r.ImportSchedule.Contains(now) {
+		return "", nil
+	}
+
+	return fmt.Sprintf("outside of the import windows, next window opens at %s", r.ImportSchedule.Next(now).Format(time.RFC3339)), nil
+}
+
+func (r *CAPIImportReconciler) rancherNamespaceGate(ctx context.Context, capiCluster *clusterv1.Cluster) (string, error) {
+	namespace := r.rancherClusterNamespace(capiCluster)
+
+	err := r.RancherClient.Get(ctx, client.ObjectKey{Name: namespace}, &corev1.Namespace{})
+	if apierrors.IsNotFound(err) {
+		if r.CreateRancherNamespace {
+			return "", nil
+		}
+
+		return fmt.Sprintf("Rancher cluster namespace %s does not exist", namespace), nil
+	}
+
+	return "", err
+}
+
+// importWindowOpen returns true when the import is allowed by the maintenance windows, otherwise it returns the time
+// to wait for the next window and marks the cluster as waiting for it.
+func (r *CAPIImportReconciler) importWindowOpen(capiCluster *clusterv1.Cluster) (time.Duration, bool) {
+	if r.ImportSchedule == nil {
+		return 0, true
+	}
+
+	now := r.clock.Now()
+	if r.ImportSchedule.Contains(now) {
+		conditions.MarkTrue(capiCluster, turtlesv1.ImportWindowCondition)
+		return 0, true
+	}
+
+	next := r.ImportSchedule.Next(now)
+	conditions.MarkFalse(capiCluster, turtlesv1.ImportWindowCondition, turtlesv1.WaitingForImportWindowReason,
+		clusterv1.ConditionSeverityInfo, "Waiting for the next import window at %s", next.Format(time.RFC3339))
+
+	return next.Sub(now), false
+}
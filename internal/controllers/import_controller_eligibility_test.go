@@ -0,0 +1,300 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	clocktesting "k8s.io/utils/clock/testing"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	turtlesv1 "github.com/rancher/turtles/api/v1alpha1"
+	provisioningv1 "github.com/rancher/turtles/internal/rancher/provisioning/v1"
+	"github.com/rancher/turtles/util"
+	turtlesannotations "github.com/rancher/turtles/util/annotations"
+	"github.com/rancher/turtles/util/schedule"
+)
+
+var _ = Describe("import eligibility", func() {
+	var (
+		r           *CAPIImportReconciler
+		ns          *corev1.Namespace
+		capiCluster *clusterv1.Cluster
+	)
+
+	BeforeEach(func() {
+		ns = &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test-ns"}}
+		capiCluster = &clusterv1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-cluster",
+				Namespace: ns.Name,
+				Labels:    map[string]string{importLabelName: "true"},
+			},
+			Status: clusterv1.ClusterStatus{ControlPlaneReady: true},
+		}
+
+		r = &CAPIImportReconciler{
+			Client:        fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(ns).Build(),
+			RancherClient: fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(ns.DeepCopy()).Build(),
+			clock:         clocktesting.NewFakeClock(time.Date(2024, 1, 3, 12, 0, 0, 0, time.UTC)),
+		}
+	})
+
+	It("should mark an eligible cluster", func() {
+		r.evaluateEligibility(ctx, capiCluster)
+		Expect(conditions.IsTrue(capiCluster, turtlesv1.ImportEligibleCondition)).To(BeTrue())
+	})
+
+	It("should list a single failed gate", func() {
+		capiCluster.Labels = nil
+
+		r.evaluateEligibility(ctx, capiCluster)
+		Expect(conditions.IsFalse(capiCluster, turtlesv1.ImportEligibleCondition)).To(BeTrue())
+		Expect(conditions.GetReason(capiCluster, turtlesv1.ImportEligibleCondition)).To(Equal(turtlesv1.ImportNotEligibleReason))
+		Expect(conditions.GetMessage(capiCluster, turtlesv1.ImportEligibleCondition)).To(Equal(
+			"ImportLabel: neither the cluster nor its namespace are labeled with cluster-api.cattle.io/rancher-auto-import=true"))
+	})
+
+	It("should list every failed gate", func() {
+		importSchedule, err := schedule.Parse([]string{"22:00-06:00"}, "UTC")
+		Expect(err).ToNot(HaveOccurred())
+
+		r.ImportSchedule = importSchedule
+		r.RancherClient = fake.NewClientBuilder().WithScheme(scheme.Scheme).Build()
+		capiCluster.Status.ControlPlaneReady = false
+
+		r.evaluateEligibility(ctx, capiCluster)
+		Expect(conditions.IsFalse(capiCluster, turtlesv1.ImportEligibleCondition)).To(BeTrue())
+
+		message := conditions.GetMessage(capiCluster, turtlesv1.ImportEligibleCondition)
+		Expect(message).To(ContainSubstring("ControlPlaneReady: control plane is not ready"))
+		Expect(message).To(ContainSubstring("ImportWindow: outside of the import windows, next window opens at 2024-01-03T22:00:00Z"))
+		Expect(message).To(ContainSubstring("RancherNamespace: Rancher cluster namespace test-ns does not exist"))
+		Expect(message).ToNot(ContainSubstring("ImportLabel"))
+	})
+
+	It("should not fail the namespace gate when the namespace can be created", func() {
+		r.RancherClient = fake.NewClientBuilder().WithScheme(scheme.Scheme).Build()
+		r.CreateRancherNamespace = true
+
+		r.evaluateEligibility(ctx, capiCluster)
+		Expect(conditions.IsTrue(capiCluster, turtlesv1.ImportEligibleCondition)).To(BeTrue())
+	})
+
+	It("should set the condition even when the import does not proceed", func() {
+		capiCluster.Labels = map[string]string{importLabelName: "false"}
+		rancherCluster := &provisioningv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test-cluster-capi", Namespace: ns.Name}}
+
+		_, err := r.reconcileNormal(ctx, capiCluster, rancherCluster)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(conditions.IsFalse(capiCluster, turtlesv1.ImportEligibleCondition)).To(BeTrue())
+		Expect(r.RancherClient.Get(ctx, client.ObjectKeyFromObject(rancherCluster), rancherCluster)).ToNot(Succeed())
+	})
+
+	DescribeTable("should annotate the reason the cluster is skipped",
+		func(setup func(), reason string) {
+			setup()
+
+			r.evaluateEligibility(ctx, capiCluster)
+			Expect(capiCluster.GetAnnotations()).To(HaveKeyWithValue(turtlesannotations.ImportSkippedReasonAnnotation, reason))
+		},
+		Entry("control plane not ready", func() { capiCluster.Status.ControlPlaneReady = false },
+			"ControlPlaneReady: control plane is not ready"),
+		Entry("import label missing", func() { capiCluster.Labels = nil },
+			"ImportLabel: neither the cluster nor its namespace are labeled with cluster-api.cattle.io/rancher-auto-import=true"),
+		Entry("import disabled", func() { capiCluster.Labels = map[string]string{importLabelName: "disabled"} },
+			"ImportLabel: neither the cluster nor its namespace are labeled with cluster-api.cattle.io/rancher-auto-import=true"),
+		Entry("Rancher namespace missing", func() { r.RancherClient = fake.NewClientBuilder().WithScheme(scheme.Scheme).Build() },
+			"RancherNamespace: Rancher cluster namespace test-ns does not exist"),
+		Entry("several gates failing", func() {
+			capiCluster.Status.ControlPlaneReady = false
+			capiCluster.Labels = nil
+		}, "ControlPlaneReady: control plane is not ready; "+
+			"ImportLabel: neither the cluster nor its namespace are labeled with cluster-api.cattle.io/rancher-auto-import=true"),
+	)
+
+	Context("when the control plane readiness is reported by a provider-specific condition", func() {
+		const providerReady clusterv1.ConditionType = "EKSControlPlaneReady"
+
+		BeforeEach(func() {
+			capiCluster.Status.ControlPlaneReady = false
+			conditions.MarkTrue(capiCluster, providerReady)
+		})
+
+		It("should not be eligible when the condition is not configured", func() {
+			r.evaluateEligibility(ctx, capiCluster)
+			Expect(conditions.GetMessage(capiCluster, turtlesv1.ImportEligibleCondition)).To(
+				Equal("ControlPlaneReady: control plane is not ready"))
+		})
+
+		It("should be eligible when the condition is configured", func() {
+			r.ControlPlaneReadiness = util.ControlPlaneReadiness{Conditions: []clusterv1.ConditionType{providerReady}}
+
+			r.evaluateEligibility(ctx, capiCluster)
+			Expect(conditions.IsTrue(capiCluster, turtlesv1.ImportEligibleCondition)).To(BeTrue())
+		})
+	})
+
+	It("should be eligible once the cluster is provisioned when the phase is accepted", func() {
+		capiCluster.Status.ControlPlaneReady = false
+		capiCluster.Status.SetTypedPhase(clusterv1.ClusterPhaseProvisioned)
+		r.ControlPlaneReadiness = util.ControlPlaneReadiness{ProvisionedPhase: true}
+
+		r.evaluateEligibility(ctx, capiCluster)
+		Expect(conditions.IsTrue(capiCluster, turtlesv1.ImportEligibleCondition)).To(BeTrue())
+	})
+
+	It("should clear the skipped reason once the cluster is eligible", func() {
+		capiCluster.Status.ControlPlaneReady = false
+		capiCluster.Annotations = map[string]string{"other": "value"}
+
+		r.evaluateEligibility(ctx, capiCluster)
+		Expect(capiCluster.GetAnnotations()).To(HaveKey(turtlesannotations.ImportSkippedReasonAnnotation))
+
+		capiCluster.Status.ControlPlaneReady = true
+
+		r.evaluateEligibility(ctx, capiCluster)
+		Expect(capiCluster.GetAnnotations()).ToNot(HaveKey(turtlesannotations.ImportSkippedReasonAnnotation))
+		Expect(capiCluster.GetAnnotations()).To(HaveKeyWithValue("other", "value"))
+	})
+})
+
+var _ = Describe("control plane wait", func() {
+	var (
+		r           *CAPIImportReconciler
+		fakeClock   *clocktesting.FakeClock
+		capiCluster *clusterv1.Cluster
+	)
+
+	const provider = "ControlPlaneWaitTestCluster"
+
+	waitSamples := func() (uint64, float64) {
+		metric := &dto.Metric{}
+		Expect(controlPlaneWaitSeconds.WithLabelValues(provider).(prometheus.Histogram).Write(metric)).To(Succeed())
+
+		return metric.GetHistogram().GetSampleCount(), metric.GetHistogram().GetSampleSum()
+	}
+
+	reconcileCluster := func() {
+		res, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(capiCluster)})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res.RequeueAfter).To(BeNumerically(">=", minRequeueDuration))
+		Expect(r.Client.Get(ctx, client.ObjectKeyFromObject(capiCluster), capiCluster)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		capiCluster = &clusterv1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", Namespace: "test-ns"},
+			Spec: clusterv1.ClusterSpec{
+				InfrastructureRef: &corev1.ObjectReference{Kind: provider},
+			},
+		}
+
+		fakeClock = clocktesting.NewFakeClock(time.Date(2024, 1, 3, 12, 0, 0, 0, time.UTC))
+
+		r = &CAPIImportReconciler{
+			Client: fake.NewClientBuilder().WithScheme(scheme.Scheme).
+				WithObjects(capiCluster.DeepCopy()).WithStatusSubresource(&clusterv1.Cluster{}).Build(),
+			RancherClient: fake.NewClientBuilder().WithScheme(scheme.Scheme).Build(),
+			clock:         fakeClock,
+		}
+	})
+
+	It("should measure the wait from the first not ready reconcile", func() {
+		count, _ := waitSamples()
+
+		reconcileCluster()
+
+		condition := conditions.Get(capiCluster, turtlesv1.ControlPlaneWaitCondition)
+		Expect(condition).ToNot(BeNil())
+		Expect(condition.Status).To(Equal(corev1.ConditionFalse))
+		Expect(condition.Reason).To(Equal(turtlesv1.WaitingForControlPlaneReason))
+		Expect(condition.LastTransitionTime.Time).To(BeTemporally("==", fakeClock.Now()))
+		Expect(capiCluster.GetAnnotations()).To(HaveKeyWithValue(turtlesannotations.ImportSkippedReasonAnnotation, ContainSubstring("control plane")))
+
+		start := fakeClock.Now()
+
+		fakeClock.Step(5 * time.Minute)
+		reconcileCluster()
+
+		condition = conditions.Get(capiCluster, turtlesv1.ControlPlaneWaitCondition)
+		Expect(condition.Status).To(Equal(corev1.ConditionFalse))
+		Expect(condition.LastTransitionTime.Time).To(BeTemporally("==", start))
+
+		fakeClock.Step(5 * time.Minute)
+		capiCluster.Status.ControlPlaneReady = true
+		Expect(r.trackControlPlaneWait(capiCluster, true)).To(BeTrue())
+
+		condition = conditions.Get(capiCluster, turtlesv1.ControlPlaneWaitCondition)
+		Expect(condition.Status).To(Equal(corev1.ConditionTrue))
+		Expect(condition.Message).To(ContainSubstring("10m0s"))
+		Expect(condition.LastTransitionTime.Time).To(BeTemporally("==", fakeClock.Now()))
+
+		newCount, sum := waitSamples()
+		Expect(newCount).To(Equal(count + 1))
+		Expect(sum).To(BeNumerically(">=", (10 * time.Minute).Seconds()))
+
+		fakeClock.Step(time.Minute)
+		Expect(r.trackControlPlaneWait(capiCluster, true)).To(BeFalse())
+
+		newCount, _ = waitSamples()
+		Expect(newCount).To(Equal(count + 1))
+	})
+
+	It("should not wait for a control plane ready on a configured provider-specific condition", func() {
+		const providerReady clusterv1.ConditionType = "EKSControlPlaneReady"
+
+		Expect(r.Client.Get(ctx, client.ObjectKeyFromObject(capiCluster), capiCluster)).To(Succeed())
+		conditions.MarkTrue(capiCluster, providerReady)
+		Expect(r.Client.Status().Update(ctx, capiCluster)).To(Succeed())
+
+		reconcileCluster()
+		Expect(conditions.IsFalse(capiCluster, turtlesv1.ControlPlaneWaitCondition)).To(BeTrue())
+
+		r.ControlPlaneReadiness = util.ControlPlaneReadiness{Conditions: []clusterv1.ConditionType{providerReady}}
+		Expect(r.Client.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test-ns"}})).To(Succeed())
+
+		_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(capiCluster)})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(r.Client.Get(ctx, client.ObjectKeyFromObject(capiCluster), capiCluster)).To(Succeed())
+		Expect(conditions.IsTrue(capiCluster, turtlesv1.ControlPlaneWaitCondition)).To(BeTrue())
+		Expect(capiCluster.GetAnnotations()).ToNot(HaveKeyWithValue(turtlesannotations.ImportSkippedReasonAnnotation,
+			ContainSubstring("control plane")))
+	})
+
+	It("should not record a wait for clusters which were ready when first observed", func() {
+		count, _ := waitSamples()
+
+		capiCluster.Status.ControlPlaneReady = true
+		Expect(r.trackControlPlaneWait(capiCluster, true)).To(BeFalse())
+		Expect(conditions.Has(capiCluster, turtlesv1.ControlPlaneWaitCondition)).To(BeFalse())
+
+		newCount, _ := waitSamples()
+		Expect(newCount).To(Equal(count))
+	})
+})
@@ -0,0 +1,239 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	turtlesv1 "github.com/rancher/turtles/api/v1alpha1"
+)
+
+// connectivityFailures are the messages of the network failures reported by the agent, or wrapped as strings by the
+// clients, which denote an unreachable endpoint rather than a failing server.
+var connectivityFailures = []string{
+	"connection refused",
+	"no such host",
+	"i/o timeout",
+	"no route to host",
+	"network is unreachable",
+	"connection timed out",
+	"dial tcp",
+}
+
+// endpointClass describes the network an endpoint host belongs to, to explain why it can be reachable from one side
+// only, e.g. a private address reachable from the management cluster but not from a downstream cluster in another
+// network.
+func endpointClass(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	ip := net.ParseIP(strings.Trim(host, "[]"))
+
+	switch {
+	case strings.EqualFold(host, "localhost"), ip != nil && ip.IsLoopback():
+		return "loopback address"
+	case ip != nil && ip.IsPrivate():
+		return "private address"
+	case ip != nil && (ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast()):
+		return "link-local address"
+	case ip != nil:
+		return "public address"
+	case !strings.Contains(host, "."):
+		return "unqualified hostname"
+	default:
+		return "hostname"
+	}
+}
+
+// isConnectivityError returns true if err reports an endpoint which can't be reached, as opposed to a server error.
+func isConnectivityError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var (
+		opErr  *net.OpError
+		dnsErr *net.DNSError
+	)
+
+	if errors.As(err, &opErr) || errors.As(err, &dnsErr) {
+		return true
+	}
+
+	return isConnectivityFailure(err.Error())
+}
+
+// isConnectivityFailure returns true if the message reports a network failure.
+func isConnectivityFailure(message string) bool {
+	message = strings.ToLower(message)
+
+	for _, failure := range connectivityFailures {
+		if strings.Contains(message, failure) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// controlPlaneEndpoint formats the control plane endpoint of the CAPI cluster, or returns an empty string when it is
+// not set yet.
+func controlPlaneEndpoint(capiCluster *clusterv1.Cluster) string {
+	endpoint := capiCluster.Spec.ControlPlaneEndpoint
+	if endpoint.Host == "" {
+		return ""
+	}
+
+	return net.JoinHostPort(endpoint.Host, strconv.Itoa(int(endpoint.Port)))
+}
+
+// describeEndpoint formats an endpoint along with its network class. Only the scheme and the host of URLs are kept.
+func describeEndpoint(endpoint string) string {
+	host := endpoint
+	if u, err := url.Parse(endpoint); err == nil && u.Host != "" {
+		host = u.Host
+		endpoint = u.Scheme + "://" + u.Host
+	}
+
+	return fmt.Sprintf("%s (%s)", endpoint, endpointClass(host))
+}
+
+// diagnoseControlPlaneUnreachable reports the management cluster can't reach the control plane endpoint when err is a
+// connectivity failure of the remote cluster client. err is returned unchanged.
+func (r *CAPIImportReconciler) diagnoseControlPlaneUnreachable(ctx context.Context, capiCluster *clusterv1.Cluster,
+	err error,
+) error {
+	if !r.EndpointDiagnostics || !isConnectivityError(err) {
+		return err
+	}
+
+	endpoint := controlPlaneEndpoint(capiCluster)
+	if endpoint == "" {
+		return err
+	}
+
+	message := fmt.Sprintf("Management cluster can't reach the control plane endpoint %s: %s",
+		describeEndpoint(endpoint), redactSecrets(err.Error()))
+
+	r.reportUnreachableEndpoint(ctx, capiCluster, turtlesv1.ControlPlaneEndpointUnreachableReason, message)
+
+	return err
+}
+
+// checkRancherEndpoint warns before the import manifest is applied when the Rancher server-url the agent registers
+// with is likely unreachable from the downstream cluster: a loopback address, or a private address while the control
+// plane endpoint is public, and so in another network. The manifest is still applied.
+func (r *CAPIImportReconciler) checkRancherEndpoint(ctx context.Context, capiCluster *clusterv1.Cluster,
+	objs []*unstructured.Unstructured,
+) error {
+	if !r.EndpointDiagnostics {
+		return nil
+	}
+
+	serverURL, err := manifestServerURL(objs)
+	if err != nil || serverURL == "" {
+		return err
+	}
+
+	u, err := url.Parse(serverURL)
+	if err != nil {
+		return fmt.Errorf("parsing Rancher server-url: %w", err)
+	}
+
+	endpoint := controlPlaneEndpoint(capiCluster)
+	rancherClass := endpointClass(u.Host)
+
+	unreachable := rancherClass == "loopback address" ||
+		(endpoint != "" && rancherClass == "private address" && endpointClass(endpoint) == "public address")
+	if !unreachable {
+		r.markEndpointsReachable(capiCluster)
+		return nil
+	}
+
+	message := fmt.Sprintf("The agent registers with Rancher at %s, which is likely unreachable from the downstream cluster",
+		describeEndpoint(serverURL))
+	if endpoint != "" {
+		message += fmt.Sprintf(" with the control plane endpoint %s", describeEndpoint(endpoint))
+	}
+
+	r.reportUnreachableEndpoint(ctx, capiCluster, turtlesv1.RancherEndpointUnreachableReason, message)
+
+	return nil
+}
+
+// diagnoseRancherUnreachable reports the agent can't reach Rancher when the management cluster could inspect the
+// downstream cluster but the agent failure reason is a network failure.
+func (r *CAPIImportReconciler) diagnoseRancherUnreachable(ctx context.Context, capiCluster *clusterv1.Cluster,
+	remoteClient client.Client, reason string,
+) error {
+	if !r.EndpointDiagnostics || !isConnectivityFailure(reason) {
+		return nil
+	}
+
+	agent := &appsv1.Deployment{}
+
+	err := remoteClient.Get(ctx, client.ObjectKey{Namespace: cattleSystemNamespace, Name: cattleClusterAgentName}, agent)
+	if client.IgnoreNotFound(err) != nil {
+		return fmt.Errorf("getting cattle-cluster-agent deployment: %w", err)
+	}
+
+	rancher := "the Rancher server-url"
+	if serverURL := agentServerURL(&agent.Spec.Template.Spec); serverURL != "" {
+		rancher = "Rancher at " + describeEndpoint(serverURL)
+	}
+
+	cluster := "the downstream cluster"
+	if endpoint := controlPlaneEndpoint(capiCluster); endpoint != "" {
+		cluster = "the control plane endpoint " + describeEndpoint(endpoint)
+	}
+
+	message := fmt.Sprintf("Management cluster reaches %s but the agent can't reach %s: %s", cluster, rancher, reason)
+
+	r.reportUnreachableEndpoint(ctx, capiCluster, turtlesv1.RancherEndpointUnreachableReason, message)
+
+	return nil
+}
+
+func (r *CAPIImportReconciler) reportUnreachableEndpoint(ctx context.Context, capiCluster *clusterv1.Cluster,
+	reason, message string,
+) {
+	log.FromContext(ctx).Info("Endpoint is unreachable", "reason", reason, "message", message)
+	conditions.MarkFalse(capiCluster, turtlesv1.EndpointsReachableCondition, reason, clusterv1.ConditionSeverityWarning, "%s", message)
+	r.recorder.Event(capiCluster, corev1.EventTypeWarning, reason, message)
+}
+
+// markEndpointsReachable clears a previously reported unreachable endpoint.
+func (r *CAPIImportReconciler) markEndpointsReachable(capiCluster *clusterv1.Cluster) {
+	if r.EndpointDiagnostics && conditions.Has(capiCluster, turtlesv1.EndpointsReachableCondition) {
+		conditions.MarkTrue(capiCluster, turtlesv1.EndpointsReachableCondition)
+	}
+}
@@ -0,0 +1,201 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	clocktesting "k8s.io/utils/clock/testing"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	turtlesv1 "github.com/rancher/turtles/api/v1alpha1"
+	provisioningv1 "github.com/rancher/turtles/internal/rancher/provisioning/v1"
+	"github.com/rancher/turtles/testutil"
+)
+
+var _ = DescribeTable("endpointClass",
+	func(host, class string) {
+		Expect(endpointClass(host)).To(Equal(class))
+	},
+	Entry("private IPv4 with port", "10.0.0.5:6443", "private address"),
+	Entry("private IPv4", "192.168.1.10", "private address"),
+	Entry("public IPv4", "34.120.1.2:6443", "public address"),
+	Entry("loopback", "127.0.0.1", "loopback address"),
+	Entry("localhost", "localhost:8443", "loopback address"),
+	Entry("link-local", "169.254.10.1", "link-local address"),
+	Entry("private IPv6", "[fd00::1]:6443", "private address"),
+	Entry("hostname", "rancher.example.com", "hostname"),
+	Entry("unqualified hostname", "rancher:443", "unqualified hostname"),
+)
+
+var _ = Describe("endpoint diagnostics", func() {
+	var (
+		r              *CAPIImportReconciler
+		server         *testutil.ManifestServer
+		recorder       *record.FakeRecorder
+		remoteClient   client.Client
+		remoteErr      error
+		capiCluster    *clusterv1.Cluster
+		rancherCluster *provisioningv1.Cluster
+	)
+
+	setup := func(manifest string) {
+		server = testutil.NewManifestServer(manifest)
+		DeferCleanup(server.Close)
+
+		r.RancherClient = testutil.NewRancherClientBuilder().WithObjects(
+			testutil.RegistrationToken("c-m-test", "test-ns", server.URL),
+		).Build()
+	}
+
+	BeforeEach(func() {
+		recorder = record.NewFakeRecorder(10)
+		remoteErr = nil
+		remoteClient = fake.NewClientBuilder().WithScheme(scheme.Scheme).Build()
+
+		r = &CAPIImportReconciler{
+			EndpointDiagnostics: true,
+			recorder:            recorder,
+			remoteClientGetter: func(_ context.Context, _ string, _ client.Client, _ client.ObjectKey) (client.Client, error) {
+				return remoteClient, remoteErr
+			},
+		}
+
+		capiCluster = &clusterv1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", Namespace: "test-ns"},
+			Spec: clusterv1.ClusterSpec{
+				ControlPlaneEndpoint: clusterv1.APIEndpoint{Host: "10.0.0.5", Port: 6443},
+			},
+		}
+		rancherCluster = &provisioningv1.Cluster{Status: provisioningv1.ClusterStatus{ClusterName: "c-m-test"}}
+	})
+
+	It("should report a control plane endpoint the management cluster can't reach", func() {
+		setup(agentManifest)
+		remoteErr = fmt.Errorf("creating client: %w", &net.OpError{
+			Op: "dial", Net: "tcp", Err: errors.New("connect: connection refused"),
+		})
+
+		_, err := r.applyImportManifest(ctx, capiCluster, rancherCluster)
+		Expect(err).To(MatchError(ContainSubstring("connection refused")))
+
+		Expect(conditions.IsFalse(capiCluster, turtlesv1.EndpointsReachableCondition)).To(BeTrue())
+		Expect(conditions.GetReason(capiCluster, turtlesv1.EndpointsReachableCondition)).To(Equal(turtlesv1.ControlPlaneEndpointUnreachableReason))
+		Expect(conditions.GetMessage(capiCluster, turtlesv1.EndpointsReachableCondition)).To(
+			HavePrefix("Management cluster can't reach the control plane endpoint 10.0.0.5:6443 (private address)"))
+		Expect(recorder.Events).To(Receive(ContainSubstring(turtlesv1.ControlPlaneEndpointUnreachableReason)))
+	})
+
+	It("should not diagnose other failures", func() {
+		setup(agentManifest)
+		remoteErr = errors.New("kubeconfig secret not found")
+
+		_, err := r.applyImportManifest(ctx, capiCluster, rancherCluster)
+		Expect(err).To(HaveOccurred())
+		Expect(conditions.Has(capiCluster, turtlesv1.EndpointsReachableCondition)).To(BeFalse())
+	})
+
+	It("should not diagnose when disabled", func() {
+		setup(agentManifest)
+		r.EndpointDiagnostics = false
+		remoteErr = &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connect: connection refused")}
+
+		_, err := r.applyImportManifest(ctx, capiCluster, rancherCluster)
+		Expect(err).To(HaveOccurred())
+		Expect(conditions.Has(capiCluster, turtlesv1.EndpointsReachableCondition)).To(BeFalse())
+	})
+
+	It("should warn about a private Rancher endpoint for a public cluster and still apply", func() {
+		setup(strings.ReplaceAll(agentManifest, "https://rancher.example.com", "https://10.1.2.3"))
+		capiCluster.Spec.ControlPlaneEndpoint.Host = "34.120.1.2"
+
+		applied, err := r.applyImportManifest(ctx, capiCluster, rancherCluster)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(applied).To(BeTrue())
+		Expect(remoteClient.Get(ctx, client.ObjectKey{Namespace: cattleSystemNamespace, Name: "cattle-config"}, &corev1.ConfigMap{})).To(Succeed())
+
+		Expect(conditions.GetReason(capiCluster, turtlesv1.EndpointsReachableCondition)).To(Equal(turtlesv1.RancherEndpointUnreachableReason))
+		Expect(conditions.GetMessage(capiCluster, turtlesv1.EndpointsReachableCondition)).To(Equal(
+			"The agent registers with Rancher at https://10.1.2.3 (private address), which is likely unreachable from the " +
+				"downstream cluster with the control plane endpoint 34.120.1.2:6443 (public address)"))
+	})
+
+	It("should not warn about a Rancher hostname", func() {
+		setup(agentManifest)
+
+		_, err := r.applyImportManifest(ctx, capiCluster, rancherCluster)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(conditions.Has(capiCluster, turtlesv1.EndpointsReachableCondition)).To(BeFalse())
+		Expect(recorder.Events).To(BeEmpty())
+	})
+
+	It("should report an agent which can't reach Rancher while the cluster is reachable", func() {
+		fakeClock := clocktesting.NewFakeClock(time.Now())
+		r.clock = fakeClock
+		r.RegistrationCheckWindow = time.Minute
+
+		pod := agentPod(false)
+		pod.Status.ContainerStatuses[0].LastTerminationState.Terminated.Message =
+			"dial tcp: lookup rancher.example.com on 10.96.0.10:53: no such host"
+
+		agent := &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: cattleClusterAgentName, Namespace: cattleSystemNamespace},
+			Spec: appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{
+					Name: "cluster-register",
+					Env:  []corev1.EnvVar{{Name: cattleServerEnv, Value: "https://rancher.example.com"}},
+				}},
+			}}},
+		}
+		remoteClient = fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(pod, agent).Build()
+
+		conditions.MarkFalse(capiCluster, turtlesv1.RancherAgentRegisteredCondition, turtlesv1.WaitingForAgentRegistrationReason,
+			clusterv1.ConditionSeverityInfo, "")
+		fakeClock.Step(2 * time.Minute)
+
+		_, err := r.verifyRegistration(ctx, capiCluster, rancherCluster)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(conditions.GetReason(capiCluster, turtlesv1.EndpointsReachableCondition)).To(Equal(turtlesv1.RancherEndpointUnreachableReason))
+		Expect(conditions.GetMessage(capiCluster, turtlesv1.EndpointsReachableCondition)).To(HavePrefix(
+			"Management cluster reaches the control plane endpoint 10.0.0.5:6443 (private address) but the agent can't " +
+				"reach Rancher at https://rancher.example.com (hostname): pod cattle-cluster-agent-abc"))
+		Expect(conditions.GetMessage(capiCluster, turtlesv1.EndpointsReachableCondition)).To(ContainSubstring("no such host"))
+
+		By("clearing the diagnostic once the cluster is ready")
+		rancherCluster.Status.Ready = true
+
+		_, err = r.verifyRegistration(ctx, capiCluster, rancherCluster)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(conditions.IsTrue(capiCluster, turtlesv1.EndpointsReachableCondition)).To(BeTrue())
+	})
+})
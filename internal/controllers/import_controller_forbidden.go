@@ -0,0 +1,147 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	turtlesv1 "github.com/rancher/turtles/api/v1alpha1"
+)
+
+// forbiddenObjectError is returned when the remote client is not allowed to write a manifest object, usually because
+// the downstream cluster restricts the permissions of the kubeconfig used by turtles.
+type forbiddenObjectError struct {
+	GVK       schema.GroupVersionKind
+	Verb      string
+	Namespace string
+	Name      string
+
+	err error
+}
+
+func newForbiddenObjectError(verb string, obj client.Object, err error) *forbiddenObjectError {
+	return &forbiddenObjectError{
+		GVK:       obj.GetObjectKind().GroupVersionKind(),
+		Verb:      verb,
+		Namespace: obj.GetNamespace(),
+		Name:      obj.GetName(),
+		err:       err,
+	}
+}
+
+// Object describes the forbidden operation, e.g. "create apps/v1 Deployment cattle-system/cattle-cluster-agent".
+func (e *forbiddenObjectError) Object() string {
+	name := e.Name
+	if e.Namespace != "" {
+		name = e.Namespace + "/" + e.Name
+	}
+
+	return fmt.Sprintf("%s %s %s %s", e.Verb, e.GVK.GroupVersion(), e.GVK.Kind, name)
+}
+
+func (e *forbiddenObjectError) Error() string {
+	return fmt.Sprintf("forbidden to %s in remote cluster: %v", e.Object(), e.err)
+}
+
+func (e *forbiddenObjectError) Unwrap() error {
+	return e.err
+}
+
+// isObjectForbidden returns true if err reports the remote client is not allowed to write a manifest object.
+func isObjectForbidden(err error) bool {
+	var forbidden *forbiddenObjectError
+	return errors.As(err, &forbidden)
+}
+
+// splitForbidden separates the forbidden object errors from the other errors joined in err.
+func splitForbidden(err error) ([]*forbiddenObjectError, error) {
+	if err == nil {
+		return nil, nil
+	}
+
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		forbidden := []*forbiddenObjectError{}
+		others := []error{}
+
+		for _, err := range joined.Unwrap() {
+			f, other := splitForbidden(err)
+			forbidden = append(forbidden, f...)
+
+			if other != nil {
+				others = append(others, other)
+			}
+		}
+
+		return forbidden, errors.Join(others...)
+	}
+
+	var forbidden *forbiddenObjectError
+	if errors.As(err, &forbidden) {
+		return []*forbiddenObjectError{forbidden}, nil
+	}
+
+	return nil, err
+}
+
+// collectForbidden adds the forbidden objects reported by an apply error to forbidden. It returns the apply error
+// without the forbidden objects when ContinueOnForbidden is set, as the rest of the manifest was still applied.
+func (r *CAPIImportReconciler) collectForbidden(err error, forbidden *[]*forbiddenObjectError) error {
+	objs, other := splitForbidden(err)
+	*forbidden = append(*forbidden, objs...)
+
+	if r.ContinueOnForbidden {
+		return other
+	}
+
+	return err
+}
+
+// reportForbidden surfaces the manifest objects the remote client was not allowed to write with the
+// ManifestApplyPermitted condition and a warning event, so operators can grant exactly the missing permissions.
+func (r *CAPIImportReconciler) reportForbidden(ctx context.Context, capiCluster *clusterv1.Cluster,
+	forbidden []*forbiddenObjectError,
+) {
+	if len(forbidden) == 0 {
+		if conditions.Has(capiCluster, turtlesv1.ManifestApplyPermittedCondition) {
+			conditions.MarkTrue(capiCluster, turtlesv1.ManifestApplyPermittedCondition)
+		}
+
+		return
+	}
+
+	objects := make([]string, 0, len(forbidden))
+	for _, f := range forbidden {
+		objects = append(objects, f.Object())
+	}
+
+	message := "Remote cluster client is not allowed to " + strings.Join(objects, ", ")
+
+	log.FromContext(ctx).Info("Import manifest objects are forbidden in the remote cluster", "objects", objects)
+	conditions.MarkFalse(capiCluster, turtlesv1.ManifestApplyPermittedCondition, turtlesv1.ManifestApplyForbiddenReason,
+		clusterv1.ConditionSeverityWarning, "%s", message)
+	r.recorder.Event(capiCluster, corev1.EventTypeWarning, turtlesv1.ManifestApplyForbiddenReason, message)
+}
@@ -0,0 +1,205 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	turtlesv1 "github.com/rancher/turtles/api/v1alpha1"
+	provisioningv1 "github.com/rancher/turtles/internal/rancher/provisioning/v1"
+	"github.com/rancher/turtles/testutil"
+)
+
+var _ = Describe("forbidden import manifest objects", func() {
+	var (
+		objs         []*unstructured.Unstructured
+		remoteClient client.Client
+		forbid       bool
+	)
+
+	forbidden := func(obj client.Object) error {
+		if forbid && obj.GetObjectKind().GroupVersionKind().Kind == "ServiceAccount" {
+			return apierrors.NewForbidden(schema.GroupResource{Resource: "serviceaccounts"}, obj.GetName(),
+				errors.New(`User "turtles" cannot create resource "serviceaccounts"`))
+		}
+
+		return nil
+	}
+
+	BeforeEach(func() {
+		var err error
+
+		forbid = true
+		objs, err = decodeManifest(strings.NewReader(incrementalManifest))
+		Expect(err).ToNot(HaveOccurred())
+
+		remoteClient = fake.NewClientBuilder().WithScheme(scheme.Scheme).WithInterceptorFuncs(interceptor.Funcs{
+			Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+				if err := forbidden(obj); err != nil {
+					return err
+				}
+
+				return c.Create(ctx, obj, opts...)
+			},
+		}).Build()
+	})
+
+	It("should diagnose the forbidden object and stop the apply", func() {
+		err := createObjects(ctx, remoteClient, objs, false)
+		Expect(err).To(HaveOccurred())
+		Expect(apierrors.IsForbidden(err)).To(BeTrue())
+
+		f, other := splitForbidden(err)
+		Expect(other).ToNot(HaveOccurred())
+		Expect(f).To(HaveLen(1))
+		Expect(f[0].Object()).To(Equal("create v1 ServiceAccount cattle-system/cattle"))
+
+		Expect(remoteClient.Get(ctx, client.ObjectKey{Name: "cattle-system"}, &corev1.Namespace{})).To(Succeed())
+		Expect(apierrors.IsNotFound(remoteClient.Get(ctx,
+			client.ObjectKey{Namespace: "cattle-system", Name: "cattle-config"}, &corev1.ConfigMap{}))).To(BeTrue())
+	})
+
+	It("should apply the rest of the manifest when continuing on forbidden objects", func() {
+		err := createObjects(ctx, remoteClient, objs, true)
+		Expect(isObjectForbidden(err)).To(BeTrue())
+
+		f, other := splitForbidden(err)
+		Expect(other).ToNot(HaveOccurred())
+		Expect(f).To(HaveLen(1))
+
+		Expect(remoteClient.Get(ctx, client.ObjectKey{Namespace: "cattle-system", Name: "cattle-config"}, &corev1.ConfigMap{})).To(Succeed())
+	})
+
+	It("should report the verb of the forbidden incremental write", func() {
+		remoteClient = fake.NewClientBuilder().WithScheme(scheme.Scheme).WithInterceptorFuncs(interceptor.Funcs{
+			Get: func(ctx context.Context, c client.WithWatch, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+				if key.Name == "cattle-config" {
+					return apierrors.NewForbidden(schema.GroupResource{Resource: "configmaps"}, key.Name, errors.New("denied"))
+				}
+
+				return c.Get(ctx, key, obj, opts...)
+			},
+		}).Build()
+
+		applied, err := applyObjectsIncrementally(ctx, remoteClient, objs, true)
+		Expect(applied).To(Equal(2))
+
+		f, other := splitForbidden(err)
+		Expect(other).ToNot(HaveOccurred())
+		Expect(f).To(HaveLen(1))
+		Expect(f[0].Object()).To(Equal("get v1 ConfigMap cattle-system/cattle-config"))
+	})
+
+	It("should keep other failures when continuing on forbidden objects", func() {
+		remoteClient = fake.NewClientBuilder().WithScheme(scheme.Scheme).WithInterceptorFuncs(interceptor.Funcs{
+			Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+				if err := forbidden(obj); err != nil {
+					return err
+				}
+
+				if obj.GetName() == "cattle-config" {
+					return errors.New("connection refused")
+				}
+
+				return c.Create(ctx, obj, opts...)
+			},
+		}).Build()
+
+		f, other := splitForbidden(createObjects(ctx, remoteClient, objs, true))
+		Expect(f).To(HaveLen(1))
+		Expect(other).To(MatchError(ContainSubstring("connection refused")))
+	})
+
+	Context("through the import", func() {
+		var (
+			r              *CAPIImportReconciler
+			server         *testutil.ManifestServer
+			recorder       *record.FakeRecorder
+			capiCluster    *clusterv1.Cluster
+			rancherCluster *provisioningv1.Cluster
+		)
+
+		BeforeEach(func() {
+			server = testutil.NewManifestServer(incrementalManifest)
+			recorder = record.NewFakeRecorder(10)
+
+			r = &CAPIImportReconciler{
+				RancherClient: testutil.NewRancherClientBuilder().WithObjects(
+					testutil.RegistrationToken("c-m-test", "test-ns", server.URL),
+				).Build(),
+				recorder: recorder,
+				remoteClientGetter: func(_ context.Context, _ string, _ client.Client, _ client.ObjectKey) (client.Client, error) {
+					return remoteClient, nil
+				},
+			}
+
+			capiCluster = &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", Namespace: "test-ns"}}
+			rancherCluster = &provisioningv1.Cluster{Status: provisioningv1.ClusterStatus{ClusterName: "c-m-test"}}
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		It("should fail the import and surface the forbidden object", func() {
+			_, err := r.applyImportManifest(ctx, capiCluster, rancherCluster)
+			Expect(err).To(MatchError(ContainSubstring("forbidden to create v1 ServiceAccount cattle-system/cattle")))
+
+			Expect(conditions.IsFalse(capiCluster, turtlesv1.ManifestApplyPermittedCondition)).To(BeTrue())
+			Expect(conditions.GetReason(capiCluster, turtlesv1.ManifestApplyPermittedCondition)).To(Equal(turtlesv1.ManifestApplyForbiddenReason))
+			Expect(recorder.Events).To(Receive(ContainSubstring("create v1 ServiceAccount cattle-system/cattle")))
+		})
+
+		It("should import the rest of the manifest and clear the condition once permitted", func() {
+			r.ContinueOnForbidden = true
+
+			applied, err := r.applyImportManifest(ctx, capiCluster, rancherCluster)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(applied).To(BeTrue())
+
+			Expect(conditions.GetMessage(capiCluster, turtlesv1.ManifestApplyPermittedCondition)).To(
+				Equal("Remote cluster client is not allowed to create v1 ServiceAccount cattle-system/cattle"))
+			Expect(recorder.Events).To(Receive(ContainSubstring(turtlesv1.ManifestApplyForbiddenReason)))
+			Expect(remoteClient.Get(ctx, client.ObjectKey{Namespace: "cattle-system", Name: "cattle-config"}, &corev1.ConfigMap{})).To(Succeed())
+
+			By("granting the missing permission")
+			forbid = false
+
+			_, err = r.applyImportManifest(ctx, capiCluster, rancherCluster)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(conditions.IsTrue(capiCluster, turtlesv1.ManifestApplyPermittedCondition)).To(BeTrue())
+			Expect(remoteClient.Get(ctx, client.ObjectKey{Namespace: "cattle-system", Name: "cattle"}, &corev1.ServiceAccount{})).To(Succeed())
+		})
+	})
+})
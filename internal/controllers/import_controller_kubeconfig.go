@@ -0,0 +1,102 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/cluster-api/controllers/remote"
+	"sigs.k8s.io/cluster-api/util/secret"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// remoteClientTimeout is the timeout of the requests to the downstream clusters, as set by remote.NewClusterClient.
+const remoteClientTimeout = 10 * time.Second
+
+// KubeconfigSecretRef locates the kubeconfig secret of the CAPI clusters, for providers or setups not following the
+// CAPI convention of a <cluster>-kubeconfig secret holding the kubeconfig in its value key. The secret is always in
+// the namespace of the CAPI cluster.
+type KubeconfigSecretRef struct {
+	// NameSuffix is appended to the CAPI cluster name to form the secret name. Defaults to -kubeconfig.
+	NameSuffix string
+	// Key is the data key of the secret holding the kubeconfig. Defaults to value.
+	Key string
+}
+
+// IsZero returns whether the reference follows the CAPI convention.
+func (k KubeconfigSecretRef) IsZero() bool {
+	return k.NameSuffix == "" && k.Key == ""
+}
+
+// secretName returns the name of the kubeconfig secret of the CAPI cluster.
+func (k KubeconfigSecretRef) secretName(clusterName string) string {
+	if k.NameSuffix == "" {
+		return secret.Name(clusterName, secret.Kubeconfig)
+	}
+
+	return clusterName + k.NameSuffix
+}
+
+// key returns the data key of the secret holding the kubeconfig.
+func (k KubeconfigSecretRef) key() string {
+	if k.Key == "" {
+		return secret.KubeconfigDataName
+	}
+
+	return k.Key
+}
+
+// clientGetter returns the getter of the downstream cluster clients reading the referenced kubeconfig secret, the
+// CAPI one when the reference follows the CAPI convention.
+func (k KubeconfigSecretRef) clientGetter() remote.ClusterClientGetter {
+	if k.IsZero() {
+		return remote.NewClusterClient
+	}
+
+	return func(ctx context.Context, sourceName string, c client.Client, cluster client.ObjectKey) (client.Client, error) {
+		secretKey := client.ObjectKey{Namespace: cluster.Namespace, Name: k.secretName(cluster.Name)}
+
+		kubeconfigSecret := &corev1.Secret{}
+		if err := c.Get(ctx, secretKey, kubeconfigSecret); err != nil {
+			return nil, fmt.Errorf("getting kubeconfig secret %s of cluster %s: %w", secretKey, cluster, err)
+		}
+
+		kubeconfig, ok := kubeconfigSecret.Data[k.key()]
+		if !ok {
+			return nil, fmt.Errorf("kubeconfig secret %s of cluster %s has no %s key", secretKey, cluster, k.key())
+		}
+
+		restConfig, err := clientcmd.RESTConfigFromKubeConfig(kubeconfig)
+		if err != nil {
+			return nil, fmt.Errorf("loading kubeconfig from secret %s: %w", secretKey, err)
+		}
+
+		restConfig.UserAgent = remote.DefaultClusterAPIUserAgent(sourceName)
+		restConfig.Timeout = remoteClientTimeout
+
+		remoteClient, err := client.New(restConfig, client.Options{Scheme: c.Scheme()})
+		if err != nil {
+			return nil, fmt.Errorf("creating client for cluster %s: %w", cluster, err)
+		}
+
+		return remoteClient, nil
+	}
+}
@@ -0,0 +1,88 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/clientcmd/api"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("kubeconfig secret", func() {
+	var (
+		cl         client.Client
+		clusterKey client.ObjectKey
+	)
+
+	kubeconfigSecret := func(name, key string) *corev1.Secret {
+		kubeconfig, err := clientcmd.Write(api.Config{
+			Clusters:       map[string]*api.Cluster{"test": {Server: "https://127.0.0.1:6443"}},
+			Contexts:       map[string]*api.Context{"test": {Cluster: "test", AuthInfo: "test"}},
+			AuthInfos:      map[string]*api.AuthInfo{"test": {Token: "test"}},
+			CurrentContext: "test",
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "test-ns"},
+			Data:       map[string][]byte{key: kubeconfig},
+		}
+	}
+
+	BeforeEach(func() {
+		clusterKey = client.ObjectKey{Namespace: "test-ns", Name: "test-cluster"}
+		cl = fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(
+			kubeconfigSecret("test-cluster-admin-kubeconfig", "kubeconfig"),
+		).Build()
+	})
+
+	It("should follow the CAPI convention by default", func() {
+		ref := KubeconfigSecretRef{}
+		Expect(ref.IsZero()).To(BeTrue())
+		Expect(ref.secretName("test-cluster")).To(Equal("test-cluster-kubeconfig"))
+		Expect(ref.key()).To(Equal("value"))
+	})
+
+	It("should build the client from a secret with a custom name and key", func() {
+		ref := KubeconfigSecretRef{NameSuffix: "-admin-kubeconfig", Key: "kubeconfig"}
+		Expect(ref.IsZero()).To(BeFalse())
+
+		remoteClient, err := ref.clientGetter()(ctx, "test", cl, clusterKey)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(remoteClient).ToNot(BeNil())
+	})
+
+	It("should fail when the custom secret is missing", func() {
+		ref := KubeconfigSecretRef{NameSuffix: "-other-kubeconfig"}
+
+		_, err := ref.clientGetter()(ctx, "test", cl, clusterKey)
+		Expect(err).To(MatchError(ContainSubstring("test-ns/test-cluster-other-kubeconfig")))
+	})
+
+	It("should fail when the custom secret has no kubeconfig key", func() {
+		ref := KubeconfigSecretRef{NameSuffix: "-admin-kubeconfig"}
+
+		_, err := ref.clientGetter()(ctx, "test", cl, clusterKey)
+		Expect(err).To(MatchError(ContainSubstring("has no value key")))
+	})
+})
@@ -0,0 +1,163 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/cluster-api/util/predicates"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	turtlesv1 "github.com/rancher/turtles/api/v1alpha1"
+	provisioningv1 "github.com/rancher/turtles/internal/rancher/provisioning/v1"
+	"github.com/rancher/turtles/testutil"
+)
+
+var _ = Describe("custom label keys", func() {
+	const (
+		customImportLabel = "example.com/auto-import"
+		customOwnedLabel  = "example.com/owned"
+	)
+
+	var (
+		r              *CAPIImportReconciler
+		ns             *corev1.Namespace
+		capiCluster    *clusterv1.Cluster
+		rancherCluster *provisioningv1.Cluster
+		remoteClient   client.Client
+	)
+
+	BeforeEach(func() {
+		server := testutil.NewManifestServer(manifestWithServerFields)
+		DeferCleanup(server.Close)
+
+		ns = &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test-ns"}}
+		capiCluster = &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-cluster",
+			Namespace: ns.Name,
+			UID:       "capi-uid",
+			Labels:    map[string]string{customImportLabel: "true"},
+		}}
+		// linked with labels, as owner references can't cross namespaces
+		rancherCluster = &provisioningv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test-cluster-capi", Namespace: "rancher-ns"}}
+		remoteClient = fake.NewClientBuilder().WithScheme(scheme.Scheme).Build()
+
+		r = &CAPIImportReconciler{
+			Client: fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(ns, capiCluster).Build(),
+			RancherClient: testutil.NewRancherClientBuilder().
+				WithObjects(
+					&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: rancherCluster.Namespace}},
+					testutil.RegistrationToken(testutil.ManagementClusterName(rancherCluster.Name), rancherCluster.Namespace, server.URL),
+				).
+				Build(),
+			recorder: record.NewFakeRecorder(10),
+			remoteClientGetter: func(_ context.Context, _ string, _ client.Client, _ client.ObjectKey) (client.Client, error) {
+				return remoteClient, nil
+			},
+			SelectionOptions: SelectionOptions{
+				ImportLabel: customImportLabel,
+				EagerCreate: true,
+			},
+			RancherClusterOptions: RancherClusterOptions{
+				OwnedLabel:              customOwnedLabel,
+				RancherClusterNamespace: rancherCluster.Namespace,
+				TimelineEntries:         10,
+			},
+		}
+	})
+
+	capiPredicates := func() bool {
+		clusterPredicates, err := r.clusterPredicates(ctx, logr.Discard())
+		Expect(err).ToNot(HaveOccurred())
+
+		return predicates.All(logr.Discard(), clusterPredicates...).Create(event.CreateEvent{Object: capiCluster})
+	}
+
+	It("should only watch clusters with the custom import label", func() {
+		Expect(capiPredicates()).To(BeTrue())
+
+		capiCluster.Labels = map[string]string{importLabelName: "true"}
+		Expect(capiPredicates()).To(BeFalse())
+	})
+
+	It("should enqueue the clusters of namespaces with the custom import label", func() {
+		capiCluster.Labels = nil
+		Expect(r.Client.Update(ctx, capiCluster)).To(Succeed())
+
+		ns.Labels = map[string]string{importLabelName: "true"}
+		Expect(namespaceToCapiClusters(ctx, predicates.All(logr.Discard()), r.Client, r.importLabel())(ctx, ns)).To(BeEmpty())
+
+		ns.Labels = map[string]string{customImportLabel: "true"}
+		Expect(namespaceToCapiClusters(ctx, predicates.All(logr.Discard()), r.Client, r.importLabel())(ctx, ns)).To(ConsistOf(
+			ctrl.Request{NamespacedName: client.ObjectKeyFromObject(capiCluster)},
+		))
+	})
+
+	It("should import the cluster with the custom label keys", func() {
+		_, err := r.reconcileNormal(ctx, capiCluster, rancherCluster)
+		Expect(err).ToNot(HaveOccurred())
+
+		created := &provisioningv1.Cluster{}
+		Expect(r.RancherClient.Get(ctx, client.ObjectKeyFromObject(rancherCluster), created)).To(Succeed())
+		Expect(created.Labels).To(HaveKey(customOwnedLabel))
+		Expect(created.Labels).ToNot(HaveKey(ownedLabelName))
+
+		timeline := &corev1.ConfigMap{}
+		Expect(r.RancherClient.Get(ctx, client.ObjectKey{Namespace: created.Namespace, Name: timelineConfigMapName(created.Name)}, timeline)).To(Succeed())
+		Expect(timeline.Labels).To(HaveKey(customOwnedLabel))
+		Expect(timeline.Labels).ToNot(HaveKey(ownedLabelName))
+
+		created.Status.ClusterName = testutil.ManagementClusterName(created.Name)
+		Expect(r.RancherClient.Status().Update(ctx, created)).To(Succeed())
+
+		_, err = r.reconcileNormal(ctx, capiCluster, rancherCluster)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(conditions.IsTrue(capiCluster, turtlesv1.ImportManifestAppliedCondition)).To(BeTrue())
+		Expect(remoteClient.Get(ctx, client.ObjectKey{Name: cattleSystemNamespace}, &corev1.Namespace{})).To(Succeed())
+
+		Expect(r.deleteLinkedRancherCluster(ctx, capiCluster, nil)).To(Succeed())
+		Expect(r.RancherClient.Get(ctx, client.ObjectKeyFromObject(rancherCluster), &provisioningv1.Cluster{})).ToNot(Succeed())
+	})
+
+	It("should report the custom import label when the cluster isn't labeled", func() {
+		capiCluster.Labels = map[string]string{importLabelName: "true"}
+
+		reason, err := r.importLabelGate(ctx, capiCluster)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(reason).To(ContainSubstring(customImportLabel))
+	})
+
+	It("should reject invalid label keys", func() {
+		r.OwnedLabel = "not a label"
+
+		err := r.SetupWithManager(ctx, nil, controller.Options{})
+		Expect(err).To(MatchError(ContainSubstring("validating label")))
+	})
+})
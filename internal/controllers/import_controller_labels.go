@@ -0,0 +1,226 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+
+	turtlesv1 "github.com/rancher/turtles/api/v1alpha1"
+	provisioningv1 "github.com/rancher/turtles/internal/rancher/provisioning/v1"
+	turtlesannotations "github.com/rancher/turtles/util/annotations"
+)
+
+// mergeLabels sets the non empty labels on the object. It returns true only if the object labels were changed.
+func mergeLabels(obj client.Object, labels map[string]string) bool {
+	current := obj.GetLabels()
+	changed := false
+
+	for key, value := range labels {
+		if value == "" || current[key] == value {
+			continue
+		}
+
+		if current == nil {
+			current = map[string]string{}
+		}
+
+		current[key] = value
+		changed = true
+	}
+
+	if changed {
+		obj.SetLabels(current)
+	}
+
+	return changed
+}
+
+// mirrorLabels copies the allowed labels from source to destination, removing the ones missing on the source. The
+// reserved labels are never touched. It returns true only if the destination labels were changed.
+func mirrorLabels(source, destination client.Object, keys []string, reserved ...string) bool {
+	labels, changed := mirrorMetadata(source.GetLabels(), destination.GetLabels(), keys, reserved...)
+	if changed {
+		destination.SetLabels(labels)
+	}
+
+	return changed
+}
+
+// reservedLabels returns the labels of the Rancher cluster set by turtles itself, which are never mirrored from the
+// CAPI cluster.
+func (r *CAPIImportReconciler) reservedLabels() []string {
+	return append([]string{r.ownedLabel(), capiClusterOwner, capiClusterOwnerNamespace, capiClusterOwnerUID}, r.AccessLabels...)
+}
+
+// syncLabels mirrors the allowed CAPI cluster labels onto the Rancher cluster, removing the ones deleted from the
+// CAPI cluster. The Rancher cluster is only patched when a mirrored label differs.
+func (r *CAPIImportReconciler) syncLabels(ctx context.Context, capiCluster *clusterv1.Cluster,
+	rancherCluster *provisioningv1.Cluster,
+) error {
+	if len(r.LabelsToRancher) == 0 {
+		return nil
+	}
+
+	patchBase := client.MergeFrom(rancherCluster.DeepCopy())
+	if !mirrorLabels(capiCluster, rancherCluster, r.LabelsToRancher, r.reservedLabels()...) {
+		return nil
+	}
+
+	if err := r.RancherClient.Patch(ctx, rancherCluster, patchBase); err != nil {
+		return fmt.Errorf("patching Rancher cluster labels: %w", err)
+	}
+
+	log.FromContext(ctx).V(4).Info("mirrored labels to Rancher cluster")
+
+	return nil
+}
+
+// accessLabels returns the values of the configured access labels for the CAPI cluster. A value is taken from the
+// CAPI cluster annotation with the same key, falling back to the label of the CAPI cluster namespace. Values which are
+// not valid label values are skipped and reported with a warning event.
+func (r *CAPIImportReconciler) accessLabels(ctx context.Context, capiCluster *clusterv1.Cluster) (map[string]string, error) {
+	if len(r.AccessLabels) == 0 {
+		return map[string]string{}, nil
+	}
+
+	ns := &corev1.Namespace{}
+	if err := r.Client.Get(ctx, client.ObjectKey{Name: capiCluster.Namespace}, ns); err != nil {
+		return nil, fmt.Errorf("getting CAPI cluster namespace: %w", err)
+	}
+
+	labels := map[string]string{}
+
+	for _, key := range r.AccessLabels {
+		value, ok := capiCluster.GetAnnotations()[key]
+		if !ok {
+			value = ns.GetLabels()[key]
+		}
+
+		if value == "" {
+			log.FromContext(ctx).V(4).Info("no value found for access label", "label", key)
+			continue
+		}
+
+		if errs := validation.IsValidLabelValue(value); len(errs) > 0 {
+			message := fmt.Sprintf("Access label %s has an invalid value %q: %s", key, value, strings.Join(errs, ", "))
+			log.FromContext(ctx).Info(message)
+			r.recorder.Event(capiCluster, corev1.EventTypeWarning, turtlesv1.InvalidAccessLabelReason, message)
+
+			continue
+		}
+
+		labels[key] = value
+	}
+
+	return labels, nil
+}
+
+// syncAccessLabels makes sure the access labels used by the Rancher RBAC are present on the Rancher cluster,
+// re-asserting them when they were removed or changed. The keys of the labels set are recorded in the
+// ManagedAccessLabelsAnnotation of the Rancher cluster, and the labels whose source is gone, or which are no longer
+// configured, are removed so that they stop granting access.
+func (r *CAPIImportReconciler) syncAccessLabels(ctx context.Context, capiCluster *clusterv1.Cluster,
+	rancherCluster *provisioningv1.Cluster,
+) error {
+	managed := managedAccessLabels(rancherCluster)
+	if len(r.AccessLabels) == 0 && len(managed) == 0 {
+		return nil
+	}
+
+	labels, err := r.accessLabels(ctx, capiCluster)
+	if err != nil {
+		return err
+	}
+
+	patchBase := client.MergeFrom(rancherCluster.DeepCopy())
+
+	changed := false
+
+	for _, key := range managed {
+		if _, ok := labels[key]; ok {
+			continue
+		}
+
+		if _, ok := rancherCluster.GetLabels()[key]; ok {
+			delete(rancherCluster.Labels, key)
+
+			changed = true
+		}
+	}
+
+	changed = mergeLabels(rancherCluster, labels) || changed
+	changed = setManagedAccessLabels(rancherCluster, labels) || changed
+
+	if !changed {
+		return nil
+	}
+
+	if err := r.RancherClient.Patch(ctx, rancherCluster, patchBase); err != nil {
+		return fmt.Errorf("patching Rancher cluster access labels: %w", err)
+	}
+
+	log.FromContext(ctx).V(4).Info("set access labels on Rancher cluster", "labels", labels)
+
+	return nil
+}
+
+// managedAccessLabels returns the keys of the access labels set by turtles on the Rancher cluster.
+func managedAccessLabels(rancherCluster *provisioningv1.Cluster) []string {
+	keys := rancherCluster.GetAnnotations()[turtlesannotations.ManagedAccessLabelsAnnotation]
+	if keys == "" {
+		return nil
+	}
+
+	return strings.Split(keys, ",")
+}
+
+// setManagedAccessLabels records the keys of the access labels on the Rancher cluster. It returns true only if the
+// recorded keys were changed.
+func setManagedAccessLabels(rancherCluster *provisioningv1.Cluster, labels map[string]string) bool {
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+
+	slices.Sort(keys)
+
+	value := strings.Join(keys, ",")
+	current, ok := rancherCluster.GetAnnotations()[turtlesannotations.ManagedAccessLabelsAnnotation]
+
+	switch {
+	case value == "" && !ok:
+		return false
+	case value == "":
+		delete(rancherCluster.Annotations, turtlesannotations.ManagedAccessLabelsAnnotation)
+	case value == current:
+		return false
+	default:
+		setAnnotation(rancherCluster, turtlesannotations.ManagedAccessLabelsAnnotation, value)
+	}
+
+	return true
+}
@@ -0,0 +1,240 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	turtlesv1 "github.com/rancher/turtles/api/v1alpha1"
+	provisioningv1 "github.com/rancher/turtles/internal/rancher/provisioning/v1"
+	"github.com/rancher/turtles/testutil"
+	turtlesannotations "github.com/rancher/turtles/util/annotations"
+)
+
+var _ = Describe("access labels", func() {
+	var (
+		r              *CAPIImportReconciler
+		capiCluster    *clusterv1.Cluster
+		rancherCluster *provisioningv1.Cluster
+	)
+
+	BeforeEach(func() {
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+			Name:   "test-ns",
+			Labels: map[string]string{"example.com/team": "platform", "example.com/env": "dev"},
+		}}
+		capiCluster = &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{
+			Name:        "test-cluster",
+			Namespace:   ns.Name,
+			Annotations: map[string]string{"example.com/env": "prod"},
+		}}
+		rancherCluster = &provisioningv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test-cluster-capi", Namespace: ns.Name}}
+
+		r = &CAPIImportReconciler{
+			Client:        fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(ns).Build(),
+			RancherClient: fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(rancherCluster.DeepCopy()).Build(),
+			RancherClusterOptions: RancherClusterOptions{
+				AccessLabels: []string{"example.com/team", "example.com/env", "example.com/missing"},
+			},
+		}
+	})
+
+	rancherLabels := func() map[string]string {
+		cluster := &provisioningv1.Cluster{}
+		Expect(r.RancherClient.Get(ctx, client.ObjectKeyFromObject(rancherCluster), cluster)).To(Succeed())
+
+		return cluster.Labels
+	}
+
+	It("should set the access labels from the cluster annotations and namespace labels", func() {
+		Expect(r.syncAccessLabels(ctx, capiCluster, rancherCluster)).To(Succeed())
+		Expect(rancherLabels()).To(Equal(map[string]string{
+			"example.com/team": "platform",
+			"example.com/env":  "prod",
+		}))
+	})
+
+	It("should re-assert the access labels when removed", func() {
+		Expect(r.syncAccessLabels(ctx, capiCluster, rancherCluster)).To(Succeed())
+
+		cluster := &provisioningv1.Cluster{}
+		Expect(r.RancherClient.Get(ctx, client.ObjectKeyFromObject(rancherCluster), cluster)).To(Succeed())
+		cluster.Labels = map[string]string{"example.com/env": "changed"}
+		Expect(r.RancherClient.Update(ctx, cluster)).To(Succeed())
+
+		Expect(r.syncAccessLabels(ctx, capiCluster, cluster)).To(Succeed())
+		Expect(rancherLabels()).To(Equal(map[string]string{
+			"example.com/team": "platform",
+			"example.com/env":  "prod",
+		}))
+	})
+
+	It("should not change the Rancher cluster without access labels", func() {
+		r.AccessLabels = nil
+
+		Expect(r.syncAccessLabels(ctx, capiCluster, rancherCluster)).To(Succeed())
+		Expect(rancherLabels()).To(BeEmpty())
+	})
+
+	It("should record the managed access labels", func() {
+		Expect(r.syncAccessLabels(ctx, capiCluster, rancherCluster)).To(Succeed())
+
+		cluster := &provisioningv1.Cluster{}
+		Expect(r.RancherClient.Get(ctx, client.ObjectKeyFromObject(rancherCluster), cluster)).To(Succeed())
+		Expect(cluster.Annotations).To(HaveKeyWithValue(turtlesannotations.ManagedAccessLabelsAnnotation,
+			"example.com/env,example.com/team"))
+	})
+
+	It("should remove the access labels whose source is gone", func() {
+		Expect(r.syncAccessLabels(ctx, capiCluster, rancherCluster)).To(Succeed())
+
+		ns := &corev1.Namespace{}
+		Expect(r.Client.Get(ctx, client.ObjectKey{Name: capiCluster.Namespace}, ns)).To(Succeed())
+		delete(ns.Labels, "example.com/team")
+		Expect(r.Client.Update(ctx, ns)).To(Succeed())
+
+		Expect(r.syncAccessLabels(ctx, capiCluster, rancherCluster)).To(Succeed())
+		Expect(rancherLabels()).To(Equal(map[string]string{"example.com/env": "prod"}))
+	})
+
+	It("should remove the access labels no longer configured", func() {
+		Expect(r.RancherClient.Get(ctx, client.ObjectKeyFromObject(rancherCluster), rancherCluster)).To(Succeed())
+		rancherCluster.Labels = map[string]string{"example.com/other": "kept"}
+		Expect(r.RancherClient.Update(ctx, rancherCluster)).To(Succeed())
+
+		Expect(r.syncAccessLabels(ctx, capiCluster, rancherCluster)).To(Succeed())
+
+		r.AccessLabels = nil
+
+		Expect(r.syncAccessLabels(ctx, capiCluster, rancherCluster)).To(Succeed())
+		Expect(rancherLabels()).To(Equal(map[string]string{"example.com/other": "kept"}))
+		Expect(rancherCluster.Annotations).ToNot(HaveKey(turtlesannotations.ManagedAccessLabelsAnnotation))
+	})
+
+	It("should skip and report invalid access label values", func() {
+		recorder := record.NewFakeRecorder(10)
+		r.recorder = recorder
+		capiCluster.Annotations["example.com/env"] = "not a valid/label value"
+
+		Expect(r.syncAccessLabels(ctx, capiCluster, rancherCluster)).To(Succeed())
+		Expect(rancherLabels()).To(Equal(map[string]string{"example.com/team": "platform"}))
+		Expect(recorder.Events).To(Receive(ContainSubstring(turtlesv1.InvalidAccessLabelReason)))
+	})
+})
+
+var _ = Describe("mirrored labels", func() {
+	var (
+		r              *CAPIImportReconciler
+		capiCluster    *clusterv1.Cluster
+		rancherCluster *provisioningv1.Cluster
+	)
+
+	BeforeEach(func() {
+		capiCluster = &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-cluster",
+			Namespace: "test-ns",
+			Labels: map[string]string{
+				"team":                    "platform",
+				"env.example.com/stage":   "prod",
+				"env.example.com/region":  "eu",
+				"other":                   "ignored",
+				"cluster-api.cattle.io/x": "ignored",
+			},
+		}}
+		rancherCluster = &provisioningv1.Cluster{ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-cluster-capi",
+			Namespace: "test-ns",
+			Labels:    map[string]string{ownedLabelName: "", "rancher": "kept"},
+		}}
+
+		r = &CAPIImportReconciler{
+			RancherClient: fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(rancherCluster.DeepCopy()).Build(),
+			RancherClusterOptions: RancherClusterOptions{
+				LabelsToRancher: []string{"team", "env.example.com/*", ownedLabelName},
+			},
+		}
+	})
+
+	rancherLabels := func() map[string]string {
+		cluster := &provisioningv1.Cluster{}
+		Expect(r.RancherClient.Get(ctx, client.ObjectKeyFromObject(rancherCluster), cluster)).To(Succeed())
+
+		return cluster.Labels
+	}
+
+	It("should only mirror the allowed labels", func() {
+		Expect(r.syncLabels(ctx, capiCluster, rancherCluster)).To(Succeed())
+		Expect(rancherLabels()).To(Equal(map[string]string{
+			ownedLabelName:           "",
+			"rancher":                "kept",
+			"team":                   "platform",
+			"env.example.com/stage":  "prod",
+			"env.example.com/region": "eu",
+		}))
+	})
+
+	It("should remove the labels deleted from the CAPI cluster", func() {
+		Expect(r.syncLabels(ctx, capiCluster, rancherCluster)).To(Succeed())
+
+		delete(capiCluster.Labels, "team")
+		delete(capiCluster.Labels, "env.example.com/region")
+		capiCluster.Labels["env.example.com/stage"] = "dev"
+
+		Expect(r.syncLabels(ctx, capiCluster, rancherCluster)).To(Succeed())
+		Expect(rancherLabels()).To(Equal(map[string]string{
+			ownedLabelName:          "",
+			"rancher":               "kept",
+			"env.example.com/stage": "dev",
+		}))
+	})
+
+	It("should mirror the allowed labels and annotations when creating the Rancher cluster", func() {
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test-ns", Labels: map[string]string{importLabelName: "true"}}}
+		capiCluster.Annotations = map[string]string{"example.com/owner": "alice", "example.com/other": "ignored"}
+
+		r.Client = fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(ns).Build()
+		r.RancherClient = testutil.NewRancherClientBuilder().WithObjects(ns.DeepCopy()).Build()
+		r.AnnotationsToRancher = []string{"example.com/owner"}
+		r.recorder = record.NewFakeRecorder(10)
+
+		_, err := r.reconcileNormal(ctx, capiCluster, rancherCluster)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(rancherLabels()).To(HaveKeyWithValue("team", "platform"))
+		Expect(rancherLabels()).To(HaveKeyWithValue("env.example.com/stage", "prod"))
+		Expect(rancherLabels()).ToNot(HaveKey("other"))
+
+		created := &provisioningv1.Cluster{}
+		Expect(r.RancherClient.Get(ctx, client.ObjectKeyFromObject(rancherCluster), created)).To(Succeed())
+		Expect(created.Annotations).To(HaveKeyWithValue("example.com/owner", "alice"))
+		Expect(created.Annotations).ToNot(HaveKey("example.com/other"))
+	})
+
+	It("should not change the Rancher cluster without mirrored labels", func() {
+		r.LabelsToRancher = nil
+
+		Expect(r.syncLabels(ctx, capiCluster, rancherCluster)).To(Succeed())
+		Expect(rancherLabels()).To(Equal(map[string]string{ownedLabelName: "", "rancher": "kept"}))
+	})
+})
@@ -0,0 +1,81 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	turtlesv1 "github.com/rancher/turtles/api/v1alpha1"
+	provisioningv1 "github.com/rancher/turtles/internal/rancher/provisioning/v1"
+	turtlesannotations "github.com/rancher/turtles/util/annotations"
+)
+
+// syncRancherLinkage reflects the Rancher cluster on the CAPI cluster, for tooling only watching CAPI clusters. The
+// reference is recorded in an annotation and in the RancherClusterLinked condition. When mirrorReadiness is set, the
+// agent deployment and readiness of the Rancher cluster, read from its status, are mirrored in conditions, otherwise
+// the conditions left by a previous mirror are removed.
+func syncRancherLinkage(capiCluster *clusterv1.Cluster, rancherCluster *provisioningv1.Cluster, status RancherClusterStatus,
+	mirrorReadiness bool,
+) {
+	ref := client.ObjectKeyFromObject(rancherCluster).String()
+
+	setAnnotation(capiCluster, turtlesannotations.RancherClusterAnnotation, ref)
+
+	message := fmt.Sprintf("Rancher cluster %s", ref)
+	if status.ClusterName != "" {
+		message += fmt.Sprintf(" (management cluster %s)", status.ClusterName)
+	}
+
+	conditions.Set(capiCluster, &clusterv1.Condition{
+		Type:    turtlesv1.RancherClusterLinkedCondition,
+		Status:  corev1.ConditionTrue,
+		Message: message,
+	})
+
+	if !mirrorReadiness {
+		conditions.Delete(capiCluster, turtlesv1.RancherAgentDeployedCondition)
+		conditions.Delete(capiCluster, turtlesv1.RancherClusterReadyCondition)
+
+		return
+	}
+
+	if status.AgentDeployed {
+		conditions.MarkTrue(capiCluster, turtlesv1.RancherAgentDeployedCondition)
+	} else {
+		conditions.MarkFalse(capiCluster, turtlesv1.RancherAgentDeployedCondition, turtlesv1.AgentNotDeployedReason,
+			clusterv1.ConditionSeverityInfo, "Rancher did not deploy its agent on the cluster yet")
+	}
+
+	if status.Ready {
+		conditions.MarkTrue(capiCluster, turtlesv1.RancherClusterReadyCondition)
+	} else {
+		conditions.MarkFalse(capiCluster, turtlesv1.RancherClusterReadyCondition, turtlesv1.RancherClusterNotReadyReason,
+			clusterv1.ConditionSeverityInfo, "Rancher cluster %s is not ready", ref)
+	}
+}
+
+// markRancherClusterUnlinked records on the CAPI cluster that its Rancher cluster is being deleted.
+func markRancherClusterUnlinked(capiCluster *clusterv1.Cluster, rancherCluster *provisioningv1.Cluster) {
+	conditions.MarkFalse(capiCluster, turtlesv1.RancherClusterLinkedCondition, turtlesv1.RancherClusterDeletedReason,
+		clusterv1.ConditionSeverityInfo, "Rancher cluster %s is being deleted", client.ObjectKeyFromObject(rancherCluster))
+}
@@ -0,0 +1,194 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	turtlesv1 "github.com/rancher/turtles/api/v1alpha1"
+	provisioningv1 "github.com/rancher/turtles/internal/rancher/provisioning/v1"
+	"github.com/rancher/turtles/testutil"
+	turtlesannotations "github.com/rancher/turtles/util/annotations"
+)
+
+var _ = Describe("Rancher cluster linkage", func() {
+	var (
+		r              *CAPIImportReconciler
+		capiCluster    *clusterv1.Cluster
+		rancherCluster *provisioningv1.Cluster
+	)
+
+	BeforeEach(func() {
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test-ns"}}
+		capiCluster = &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-cluster",
+			Namespace: "test-ns",
+			Labels:    map[string]string{importLabelName: "true"},
+		}}
+		rancherCluster = testutil.RancherCluster("test-cluster-capi", "test-ns", testutil.ClusterStateNoName)
+
+		r = &CAPIImportReconciler{
+			Client:        fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(ns, capiCluster).Build(),
+			RancherClient: testutil.NewRancherClientBuilder().WithObjects(rancherCluster).Build(),
+			RancherClusterOptions: RancherClusterOptions{
+				MirrorRancherReadiness: true,
+			},
+		}
+	})
+
+	It("should link the CAPI cluster to the Rancher cluster", func() {
+		_, err := r.reconcile(ctx, capiCluster)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(capiCluster.Annotations).To(HaveKeyWithValue(turtlesannotations.RancherClusterAnnotation, "test-ns/test-cluster-capi"))
+		Expect(conditions.IsTrue(capiCluster, turtlesv1.RancherClusterLinkedCondition)).To(BeTrue())
+		Expect(conditions.GetMessage(capiCluster, turtlesv1.RancherClusterLinkedCondition)).To(ContainSubstring("test-ns/test-cluster-capi"))
+		Expect(conditions.GetReason(capiCluster, turtlesv1.RancherAgentDeployedCondition)).To(Equal(turtlesv1.AgentNotDeployedReason))
+		Expect(conditions.GetReason(capiCluster, turtlesv1.RancherClusterReadyCondition)).To(Equal(turtlesv1.RancherClusterNotReadyReason))
+	})
+
+	It("should keep the linkage current with the Rancher cluster status", func() {
+		syncRancherLinkage(capiCluster, rancherCluster, r.rancherClusterStatus(rancherCluster), true)
+		Expect(conditions.IsFalse(capiCluster, turtlesv1.RancherAgentDeployedCondition)).To(BeTrue())
+
+		rancherCluster = testutil.RancherCluster("test-cluster-capi", "test-ns", testutil.ClusterStateAgentDeployed)
+		syncRancherLinkage(capiCluster, rancherCluster, r.rancherClusterStatus(rancherCluster), true)
+		Expect(conditions.IsTrue(capiCluster, turtlesv1.RancherAgentDeployedCondition)).To(BeTrue())
+		Expect(conditions.IsFalse(capiCluster, turtlesv1.RancherClusterReadyCondition)).To(BeTrue())
+		Expect(conditions.GetMessage(capiCluster, turtlesv1.RancherClusterLinkedCondition)).To(
+			ContainSubstring(rancherCluster.Status.ClusterName))
+
+		rancherCluster = testutil.RancherCluster("test-cluster-capi", "test-ns", testutil.ClusterStateReady)
+		syncRancherLinkage(capiCluster, rancherCluster, r.rancherClusterStatus(rancherCluster), true)
+		Expect(conditions.IsTrue(capiCluster, turtlesv1.RancherAgentDeployedCondition)).To(BeTrue())
+		Expect(conditions.IsTrue(capiCluster, turtlesv1.RancherClusterReadyCondition)).To(BeTrue())
+	})
+
+	It("should only link the CAPI cluster when the readiness mirror is disabled", func() {
+		r.MirrorRancherReadiness = false
+		conditions.MarkTrue(capiCluster, turtlesv1.RancherClusterReadyCondition)
+
+		_, err := r.reconcile(ctx, capiCluster)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(capiCluster.Annotations).To(HaveKeyWithValue(turtlesannotations.RancherClusterAnnotation, "test-ns/test-cluster-capi"))
+		Expect(conditions.IsTrue(capiCluster, turtlesv1.RancherClusterLinkedCondition)).To(BeTrue())
+		Expect(conditions.Has(capiCluster, turtlesv1.RancherAgentDeployedCondition)).To(BeFalse())
+		Expect(conditions.Has(capiCluster, turtlesv1.RancherClusterReadyCondition)).To(BeFalse())
+	})
+
+	It("should unlink the CAPI cluster when the Rancher cluster is deleted", func() {
+		_, err := r.reconcile(ctx, capiCluster)
+		Expect(err).ToNot(HaveOccurred())
+
+		rancherCluster.Finalizers = []string{"test"}
+		Expect(r.RancherClient.Update(ctx, rancherCluster)).To(Succeed())
+		Expect(r.RancherClient.Delete(ctx, rancherCluster)).To(Succeed())
+
+		_, err = r.reconcile(ctx, capiCluster)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(conditions.IsFalse(capiCluster, turtlesv1.RancherClusterLinkedCondition)).To(BeTrue())
+		Expect(conditions.GetReason(capiCluster, turtlesv1.RancherClusterLinkedCondition)).To(Equal(turtlesv1.RancherClusterDeletedReason))
+	})
+
+	Context("through the reconciler", func() {
+		var patches int
+
+		capiClusterKey := client.ObjectKey{Namespace: "test-ns", Name: "test-cluster"}
+
+		setRancherStatus := func(state testutil.ClusterState) {
+			Expect(r.RancherClient.Get(ctx, client.ObjectKeyFromObject(rancherCluster), rancherCluster)).To(Succeed())
+			rancherCluster.Status = testutil.RancherCluster("test-cluster-capi", "test-ns", state).Status
+			Expect(r.RancherClient.Status().Update(ctx, rancherCluster)).To(Succeed())
+		}
+
+		reconcileCluster := func() *clusterv1.Cluster {
+			_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: capiClusterKey})
+			Expect(err).ToNot(HaveOccurred())
+
+			stored := &clusterv1.Cluster{}
+			Expect(r.Client.Get(ctx, capiClusterKey, stored)).To(Succeed())
+
+			return stored
+		}
+
+		BeforeEach(func() {
+			patches = 0
+			capiCluster.Status.ControlPlaneReady = true
+			rancherCluster = testutil.RancherCluster("test-cluster-capi", "test-ns", testutil.ClusterStateAgentDeployed)
+
+			r.Client = fake.NewClientBuilder().WithScheme(scheme.Scheme).
+				WithObjects(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test-ns"}}, capiCluster).
+				WithStatusSubresource(&clusterv1.Cluster{}).
+				WithInterceptorFuncs(interceptor.Funcs{
+					Patch: func(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+						patches++
+						return c.Patch(ctx, obj, patch, opts...)
+					},
+					SubResourcePatch: func(ctx context.Context, c client.Client, subResource string, obj client.Object, patch client.Patch,
+						opts ...client.SubResourcePatchOption,
+					) error {
+						patches++
+						return c.SubResource(subResource).Patch(ctx, obj, patch, opts...)
+					},
+				}).Build()
+			r.RancherClient = testutil.NewRancherClientBuilder().WithObjects(rancherCluster).Build()
+		})
+
+		It("should track the Rancher status transitions on the stored CAPI cluster", func() {
+			stored := reconcileCluster()
+			Expect(conditions.IsTrue(stored, turtlesv1.RancherAgentDeployedCondition)).To(BeTrue())
+			Expect(conditions.IsFalse(stored, turtlesv1.RancherClusterReadyCondition)).To(BeTrue())
+
+			setRancherStatus(testutil.ClusterStateReady)
+			stored = reconcileCluster()
+			Expect(conditions.IsTrue(stored, turtlesv1.RancherClusterReadyCondition)).To(BeTrue())
+
+			setRancherStatus(testutil.ClusterStateAgentDeployed)
+			stored = reconcileCluster()
+			Expect(conditions.IsTrue(stored, turtlesv1.RancherAgentDeployedCondition)).To(BeTrue())
+			Expect(conditions.IsFalse(stored, turtlesv1.RancherClusterReadyCondition)).To(BeTrue())
+			Expect(conditions.GetReason(stored, turtlesv1.RancherClusterReadyCondition)).To(Equal(turtlesv1.RancherClusterNotReadyReason))
+		})
+
+		It("should not patch the CAPI cluster while the Rancher status is unchanged", func() {
+			reconcileCluster()
+			Expect(patches).ToNot(BeZero())
+
+			patches = 0
+			reconcileCluster()
+			Expect(patches).To(BeZero())
+
+			setRancherStatus(testutil.ClusterStateReady)
+			reconcileCluster()
+			Expect(patches).ToNot(BeZero())
+		})
+	})
+})
@@ -0,0 +1,146 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/go-logr/logr/funcr"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	turtlesv1 "github.com/rancher/turtles/api/v1alpha1"
+	"github.com/rancher/turtles/testutil"
+)
+
+const (
+	loggedRegistrationToken = "s3cr3t-registration-token"
+
+	manifestWithToken = `apiVersion: v1
+kind: Namespace
+metadata:
+  name: cattle-system
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: cattle-credentials
+  namespace: cattle-system
+stringData:
+  token: ` + loggedRegistrationToken + `
+`
+)
+
+var _ = Describe("reconcile logging", func() {
+	var (
+		r           *CAPIImportReconciler
+		server      *testutil.ManifestServer
+		manifestURL string
+		capiCluster *clusterv1.Cluster
+		logs        *strings.Builder
+		logCtx      context.Context
+	)
+
+	BeforeEach(func() {
+		server = testutil.NewManifestServer(manifestWithToken)
+		DeferCleanup(server.Close)
+
+		manifestURL = server.URL + "/v3/import/" + loggedRegistrationToken + "_c-m-test.yaml"
+
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test-ns"}}
+		capiCluster = &clusterv1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-cluster",
+				Namespace: ns.Name,
+				Labels:    map[string]string{importLabelName: "true"},
+			},
+			Status: clusterv1.ClusterStatus{ControlPlaneReady: true},
+		}
+
+		r = &CAPIImportReconciler{
+			Client: fake.NewClientBuilder().WithScheme(scheme.Scheme).
+				WithObjects(ns, capiCluster).WithStatusSubresource(&clusterv1.Cluster{}).Build(),
+			RancherClient: testutil.NewRancherClientBuilder().
+				WithCluster("test-cluster-capi", ns.Name, testutil.ClusterStateNameSet, manifestURL).
+				Build(),
+			recorder: record.NewFakeRecorder(10),
+			remoteClientGetter: func(_ context.Context, _ string, _ client.Client, _ client.ObjectKey) (client.Client, error) {
+				return fake.NewClientBuilder().WithScheme(scheme.Scheme).Build(), nil
+			},
+		}
+
+		// capture every log line at the highest verbosity, the objects may be applied concurrently
+		var mu sync.Mutex
+
+		logs = &strings.Builder{}
+		logCtx = ctrl.LoggerInto(ctx, funcr.New(func(prefix, args string) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			logs.WriteString(prefix + " " + args + "\n")
+		}, funcr.Options{Verbosity: 10}))
+	})
+
+	reconcileCluster := func() error {
+		_, err := r.Reconcile(logCtx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(capiCluster)})
+		return err
+	}
+
+	It("should log the cluster fields and the applied objects", func() {
+		Expect(reconcileCluster()).To(Succeed())
+
+		Expect(r.Client.Get(ctx, client.ObjectKeyFromObject(capiCluster), capiCluster)).To(Succeed())
+		Expect(conditions.IsTrue(capiCluster, turtlesv1.ImportManifestAppliedCondition)).To(BeTrue())
+
+		Expect(logs.String()).To(ContainSubstring(`"cluster"="test-cluster"`))
+		Expect(logs.String()).To(ContainSubstring(`"namespace"="test-ns"`))
+		Expect(logs.String()).To(ContainSubstring(`"rancherCluster"="test-ns/test-cluster-capi"`))
+		Expect(logs.String()).To(ContainSubstring(`"clusterName"="c-m-test-cluster-capi"`))
+		Expect(logs.String()).To(ContainSubstring(`"manifestURL"="` + server.URL + `/REDACTED"`))
+		Expect(logs.String()).To(ContainSubstring(`"msg"="creating object in remote cluster"`))
+		Expect(logs.String()).To(ContainSubstring(`"name"="cattle-credentials"`))
+	})
+
+	It("should never log the registration token or the manifest", func() {
+		Expect(reconcileCluster()).To(Succeed())
+
+		Expect(logs.String()).ToNot(ContainSubstring(loggedRegistrationToken))
+		Expect(logs.String()).ToNot(ContainSubstring("stringData"))
+		Expect(logs.String()).ToNot(ContainSubstring(manifestWithToken))
+	})
+
+	It("should not log the registration token of a failed download", func() {
+		server.Close()
+
+		Expect(reconcileCluster()).ToNot(Succeed())
+
+		Expect(logs.String()).To(ContainSubstring("failed downloading import manifest"))
+		Expect(logs.String()).ToNot(ContainSubstring(loggedRegistrationToken))
+	})
+})
@@ -0,0 +1,75 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	expv1 "sigs.k8s.io/cluster-api/exp/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+)
+
+// machinePoolsReady returns whether the import manifest can be applied to the CAPI cluster as far as its machine pools
+// are concerned, and the reason it can't otherwise. When waiting for machine pools is enabled, at least one of the
+// machine pools of the cluster must be ready, so that the agent is not scheduled on nodes which are still scaling up.
+// Clusters without machine pools, e.g. using machine deployments, are not gated.
+func (r *CAPIImportReconciler) machinePoolsReady(ctx context.Context, capiCluster *clusterv1.Cluster) (bool, string, error) {
+	if !r.WaitForMachinePools {
+		return true, "", nil
+	}
+
+	machinePools := &expv1.MachinePoolList{}
+
+	err := r.Client.List(ctx, machinePools, client.InNamespace(capiCluster.Namespace),
+		client.MatchingLabels{clusterv1.ClusterNameLabel: capiCluster.Name})
+	if meta.IsNoMatchError(err) {
+		// the machine pool feature is not enabled in the management cluster
+		return true, "", nil
+	}
+
+	if err != nil {
+		return false, "", fmt.Errorf("listing machine pools of cluster %s: %w", client.ObjectKeyFromObject(capiCluster), err)
+	}
+
+	if len(machinePools.Items) == 0 {
+		return true, "", nil
+	}
+
+	for i := range machinePools.Items {
+		if machinePoolReady(&machinePools.Items[i]) {
+			return true, "", nil
+		}
+	}
+
+	return false, fmt.Sprintf("none of the %d machine pools is ready", len(machinePools.Items)), nil
+}
+
+// machinePoolReady returns true if the machine pool is ready and has ready replicas.
+func machinePoolReady(machinePool *expv1.MachinePool) bool {
+	return conditions.IsTrue(machinePool, clusterv1.ReadyCondition) && machinePool.Status.ReadyReplicas > 0
+}
+
+func (r *CAPIImportReconciler) machinePoolGate(ctx context.Context, capiCluster *clusterv1.Cluster) (string, error) {
+	_, reason, err := r.machinePoolsReady(ctx, capiCluster)
+
+	return reason, err
+}
@@ -0,0 +1,137 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	expv1 "sigs.k8s.io/cluster-api/exp/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	turtlesv1 "github.com/rancher/turtles/api/v1alpha1"
+	provisioningv1 "github.com/rancher/turtles/internal/rancher/provisioning/v1"
+	"github.com/rancher/turtles/testutil"
+)
+
+var _ = Describe("machine pool readiness", func() {
+	var (
+		r              *CAPIImportReconciler
+		capiCluster    *clusterv1.Cluster
+		rancherCluster *provisioningv1.Cluster
+		machinePools   []client.Object
+	)
+
+	machinePool := func(name, clusterName string, ready bool) *expv1.MachinePool {
+		machinePool := &expv1.MachinePool{ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "test-ns",
+			Labels:    map[string]string{clusterv1.ClusterNameLabel: clusterName},
+		}}
+
+		if ready {
+			machinePool.Status.ReadyReplicas = 1
+			conditions.MarkTrue(machinePool, clusterv1.ReadyCondition)
+		} else {
+			conditions.MarkFalse(machinePool, clusterv1.ReadyCondition, expv1.WaitingForReplicasReadyReason,
+				clusterv1.ConditionSeverityInfo, "scaling up")
+		}
+
+		return machinePool
+	}
+
+	BeforeEach(func() {
+		server := testutil.NewManifestServer(manifestWithServerFields)
+		DeferCleanup(server.Close)
+
+		capiCluster = &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-cluster",
+			Namespace: "test-ns",
+			Labels:    map[string]string{importLabelName: "true"},
+		}}
+		rancherCluster = &provisioningv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test-cluster-capi", Namespace: "test-ns"}}
+		machinePools = []client.Object{}
+
+		r = &CAPIImportReconciler{
+			RancherClient: testutil.NewRancherClientBuilder().
+				WithCluster(rancherCluster.Name, rancherCluster.Namespace, testutil.ClusterStateNameSet, server.URL).
+				WithObjects(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test-ns"}}).
+				Build(),
+			recorder: record.NewFakeRecorder(10),
+			remoteClientGetter: func(_ context.Context, _ string, _ client.Client, _ client.ObjectKey) (client.Client, error) {
+				return fake.NewClientBuilder().WithScheme(scheme.Scheme).Build(), nil
+			},
+			ApplyOptions: ApplyOptions{
+				WaitForMachinePools: true,
+			},
+		}
+	})
+
+	reconcile := func() ctrl.Result {
+		r.Client = fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(machinePools...).Build()
+
+		res, err := r.reconcileNormal(ctx, capiCluster, rancherCluster)
+		Expect(err).ToNot(HaveOccurred())
+
+		return res
+	}
+
+	It("should wait for a machine pool of the cluster to be ready", func() {
+		machinePools = append(machinePools, machinePool("pool-0", capiCluster.Name, false))
+
+		Expect(reconcile().RequeueAfter).To(Equal(minRequeueDuration))
+
+		condition := conditions.Get(capiCluster, turtlesv1.ImportManifestAppliedCondition)
+		Expect(condition).ToNot(BeNil())
+		Expect(condition.Status).To(Equal(corev1.ConditionFalse))
+		Expect(condition.Reason).To(Equal(turtlesv1.WaitingForMachinePoolReason))
+		Expect(conditions.GetMessage(capiCluster, turtlesv1.ImportEligibleCondition)).To(ContainSubstring("MachinePoolReady"))
+	})
+
+	It("should apply the manifest once one of the machine pools is ready", func() {
+		machinePools = append(machinePools,
+			machinePool("pool-0", capiCluster.Name, false),
+			machinePool("pool-1", capiCluster.Name, true))
+
+		reconcile()
+		Expect(conditions.IsTrue(capiCluster, turtlesv1.ImportManifestAppliedCondition)).To(BeTrue())
+	})
+
+	It("should not gate clusters without machine pools", func() {
+		machinePools = append(machinePools, machinePool("pool-0", "other-cluster", false))
+
+		reconcile()
+		Expect(conditions.IsTrue(capiCluster, turtlesv1.ImportManifestAppliedCondition)).To(BeTrue())
+	})
+
+	It("should not wait for machine pools when disabled", func() {
+		r.WaitForMachinePools = false
+		machinePools = append(machinePools, machinePool("pool-0", capiCluster.Name, false))
+
+		reconcile()
+		Expect(conditions.IsTrue(capiCluster, turtlesv1.ImportManifestAppliedCondition)).To(BeTrue())
+	})
+})
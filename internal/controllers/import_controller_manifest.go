@@ -0,0 +1,309 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	turtlesv1 "github.com/rancher/turtles/api/v1alpha1"
+	provisioningv1 "github.com/rancher/turtles/internal/rancher/provisioning/v1"
+	turtlesannotations "github.com/rancher/turtles/util/annotations"
+)
+
+// reconcileImport applies the registration manifest of the Rancher cluster to the downstream cluster, once the import
+// window is open and the machine pools are ready, and waits for the agent to become healthy and register.
+func (r *CAPIImportReconciler) reconcileImport(ctx context.Context, capiCluster *clusterv1.Cluster,
+	rancherCluster *provisioningv1.Cluster,
+) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	if requeueAfter, open := r.importWindowOpen(capiCluster); !open {
+		log.Info("outside of the import maintenance window, requeue", "after", requeueAfter)
+		recordImportSkipped(capiCluster, turtlesv1.WaitingForImportWindowReason)
+
+		return ctrl.Result{RequeueAfter: requeueAfter}, nil
+	}
+
+	// the manifest was applied already, wait for the agent without applying it again
+	if conditions.IsFalse(capiCluster, turtlesv1.AgentHealthyCondition) {
+		if res, healthy, err := r.checkAgentHealth(ctx, capiCluster); err != nil || !healthy {
+			return res, err
+		}
+
+		return ctrl.Result{RequeueAfter: r.RegistrationCheckWindow}, nil
+	}
+
+	machinePoolsReady, reason, err := r.machinePoolsReady(ctx, capiCluster)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if !machinePoolsReady {
+		log.Info("waiting for a machine pool to be ready, requeue", "reason", reason)
+		conditions.MarkFalse(capiCluster, turtlesv1.ImportManifestAppliedCondition, turtlesv1.WaitingForMachinePoolReason,
+			clusterv1.ConditionSeverityInfo, "Waiting for a machine pool to be ready: %s", reason)
+
+		return r.requeueWaiting(capiCluster, turtlesv1.WaitingForMachinePoolReason), nil
+	}
+
+	cached, err := r.manifestCached(ctx, capiCluster, rancherCluster)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if cached && !relinkedImportPending(capiCluster) {
+		log.Info("Import manifest unchanged since it was applied, skipping download")
+		return ctrl.Result{RequeueAfter: r.RegistrationCheckWindow}, nil
+	}
+
+	firstApply := !conditions.IsTrue(capiCluster, turtlesv1.ImportManifestAppliedCondition)
+
+	applied, unchanged, err := r.importManifest(ctx, capiCluster, rancherCluster, !manifestReapplyNeeded(capiCluster))
+	if err != nil {
+		recordImportFailure(capiCluster, manifestApplyFailureReason(err))
+		return ctrl.Result{}, err
+	}
+
+	if !applied {
+		log.Info("Import manifest URL not set yet, requeue")
+		return r.requeueWaitingOnRancher(capiCluster, turtlesv1.WaitingForManifestURLReason), nil
+	}
+
+	if unchanged {
+		r.requeues.reset(client.ObjectKeyFromObject(capiCluster))
+		return ctrl.Result{RequeueAfter: r.RegistrationCheckWindow}, nil
+	}
+
+	r.requeues.reset(client.ObjectKeyFromObject(capiCluster))
+
+	if r.DryRun {
+		return ctrl.Result{RequeueAfter: r.RegistrationCheckWindow}, nil
+	}
+
+	if firstApply {
+		recordImportSucceeded(capiCluster, r.now())
+	}
+
+	if err := r.recordTimeline(ctx, rancherCluster, timelinePhaseManifestApplied, fmt.Sprintf("Applied registration manifest %s",
+		shortHash(capiCluster.GetAnnotations()[turtlesannotations.ManifestHashAnnotation]))); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if relinkedImportPending(capiCluster) {
+		conditions.Delete(capiCluster, turtlesv1.RancherAgentRegisteredCondition)
+	}
+
+	if res, healthy, err := r.checkAgentHealth(ctx, capiCluster); err != nil || !healthy {
+		return res, err
+	}
+
+	if r.RegistrationCheckWindow == 0 {
+		return ctrl.Result{}, nil
+	}
+
+	// Reset the condition so the registration window starts from this apply.
+	conditions.Delete(capiCluster, turtlesv1.RancherAgentRegisteredCondition)
+	conditions.MarkFalse(capiCluster, turtlesv1.RancherAgentRegisteredCondition, turtlesv1.WaitingForAgentRegistrationReason,
+		clusterv1.ConditionSeverityInfo, "Import manifest applied, waiting for the agent to register with Rancher")
+
+	return ctrl.Result{RequeueAfter: r.RegistrationCheckWindow}, nil
+}
+
+// applyImportManifest downloads the registration manifest of the Rancher cluster and applies it to the downstream
+// cluster. It returns false when the manifest URL is not available yet. The outcome is reported with the
+// ImportManifestApplied condition.
+func (r *CAPIImportReconciler) applyImportManifest(ctx context.Context, capiCluster *clusterv1.Cluster,
+	rancherCluster *provisioningv1.Cluster,
+) (bool, error) {
+	applied, _, err := r.importManifest(ctx, capiCluster, rancherCluster, false)
+
+	return applied, err
+}
+
+// importManifest downloads the registration manifest of the Rancher cluster and applies it to the downstream cluster,
+// like applyImportManifest. With skipUnchanged, a manifest with the same hash as the last one successfully applied is
+// not applied again, and reported as both applied and unchanged.
+func (r *CAPIImportReconciler) importManifest(ctx context.Context, capiCluster *clusterv1.Cluster,
+	rancherCluster *provisioningv1.Cluster, skipUnchanged bool,
+) (applied, unchanged bool, reterr error) {
+	log := log.FromContext(ctx)
+
+	defer func() { markImportManifestApplied(capiCluster, applied, r.DryRun, reterr) }()
+
+	// get the registration manifest
+	expectedChecksum := capiCluster.GetAnnotations()[turtlesannotations.ManifestChecksumAnnotation]
+
+	host, err := manifestURLHost(capiCluster, r.ManifestURLHost, r.ManifestURLHostAllowlist)
+	if err != nil {
+		return false, false, err
+	}
+
+	manifestURL, err := getClusterRegistrationManifestURL(ctx, r.rancherClusterStatus(rancherCluster).ClusterName,
+		r.rancherClusterNamespace(capiCluster), r.RancherClient, host, r.tokenReissue())
+	if err != nil {
+		return false, false, err
+	}
+
+	markRegistrationTokenReady(capiCluster, manifestURL)
+
+	if manifestURL == "" {
+		return false, false, nil
+	}
+
+	// the manifest URL embeds the registration token, only its host is logged
+	log = log.WithValues("manifestURL", redactSecrets(manifestURL))
+	ctx = ctrl.LoggerInto(ctx, log)
+
+	httpClient, err := r.manifestHTTPClient.get(manifestTLS{InsecureSkipVerify: r.InsecureSkipVerify, CABundle: r.CABundle},
+		r.ManifestDownloadTimeout)
+	if err != nil {
+		return false, false, err
+	}
+
+	manifest, err := fetchClusterRegistrationManifest(ctx, manifestURL, httpClient, expectedChecksum,
+		manifestRetry{Attempts: r.ManifestDownloadAttempts, Interval: r.ManifestDownloadInterval})
+	if errors.Is(err, errManifestVerification) {
+		conditions.MarkFalse(capiCluster, turtlesv1.ManifestVerifiedCondition, turtlesv1.ManifestVerificationFailedReason,
+			clusterv1.ConditionSeverityError, "%s", err)
+		r.recorder.Event(capiCluster, corev1.EventTypeWarning, turtlesv1.ManifestVerificationFailedReason, err.Error())
+	}
+
+	if err != nil {
+		return false, false, err
+	}
+
+	if manifest == "" {
+		return false, false, nil
+	}
+
+	hash := manifestHash(manifest)
+	previousHash := capiCluster.GetAnnotations()[turtlesannotations.ManifestHashAnnotation]
+
+	if skipUnchanged && hash == previousHash && conditions.IsTrue(capiCluster, turtlesv1.ImportManifestAppliedCondition) {
+		log.Info("Import manifest unchanged since it was applied, skipping apply", "hash", shortHash(hash))
+		return true, true, nil
+	}
+
+	if expectedChecksum != "" {
+		conditions.MarkTrue(capiCluster, turtlesv1.ManifestVerifiedCondition)
+	} else {
+		conditions.Delete(capiCluster, turtlesv1.ManifestVerifiedCondition)
+	}
+
+	log.Info("Creating import manifest")
+
+	remoteClient, err := r.remoteClient(ctx, capiCluster)
+	if err != nil {
+		return false, false, fmt.Errorf("getting remote cluster client: %w", r.diagnoseControlPlaneUnreachable(ctx, capiCluster, err))
+	}
+
+	objs, err := decodeManifest(strings.NewReader(manifest))
+	if err != nil {
+		return false, false, fmt.Errorf("decoding import manifest: %w", err)
+	}
+
+	if err := r.checkRancherTakeover(ctx, capiCluster, remoteClient, objs); err != nil {
+		return false, false, err
+	}
+
+	if err := r.checkRancherEndpoint(ctx, capiCluster, objs); err != nil {
+		return false, false, err
+	}
+
+	if adopted, err := r.adoptExistingAgent(ctx, capiCluster, remoteClient, objs); err != nil || adopted {
+		return adopted, false, err
+	}
+
+	if err := applyAgentScheduling(objs, r.AgentNodeSelector, r.AgentTolerations); err != nil {
+		return false, false, fmt.Errorf("setting agent scheduling constraints: %w", err)
+	}
+
+	recordManifestStats(capiCluster, len(manifest), len(objs))
+
+	if r.RecordManifestStats {
+		setAnnotation(capiCluster, turtlesannotations.ManifestSizeAnnotation, strconv.Itoa(len(manifest)))
+		setAnnotation(capiCluster, turtlesannotations.ManifestObjectsAnnotation, strconv.Itoa(len(objs)))
+	}
+
+	forbidden := []*forbiddenObjectError{}
+	defer func() { r.reportForbidden(ctx, capiCluster, forbidden) }()
+
+	if err := r.collectForbidden(r.applyObjects(ctx, remoteClient, objs), &forbidden); err != nil {
+		return false, false, fmt.Errorf("applying import manifest: %w", r.diagnoseControlPlaneUnreachable(ctx, capiCluster, err))
+	}
+
+	// nothing was persisted by a dry-run, so it is neither recorded nor cached and the manifest is applied for real
+	// once the dry-run is disabled
+	if r.DryRun {
+		log.Info("Dry-run of import manifest succeeded, no object was persisted")
+		return true, false, r.applyAdditionalManifest(ctx, remoteClient, &forbidden)
+	}
+
+	log.Info("Successfully applied import manifest")
+
+	if previousHash != "" && previousHash != hash {
+		r.recorder.Eventf(capiCluster, corev1.EventTypeNormal, turtlesv1.ManifestChangedReason,
+			"Registration manifest changed from %s to %s and was re-applied", shortHash(previousHash), shortHash(hash))
+	}
+
+	setAnnotation(capiCluster, turtlesannotations.ManifestHashAnnotation, hash)
+
+	// a partially applied manifest is not cached, so the forbidden objects are retried once permissions are granted
+	if len(forbidden) == 0 {
+		if err := r.cacheManifest(ctx, capiCluster, manifestURL, hash); err != nil {
+			return false, false, err
+		}
+	}
+
+	if err := r.updateImportedByVersion(ctx, capiCluster, rancherCluster); err != nil {
+		return false, false, err
+	}
+
+	if err := r.applyAdditionalManifest(ctx, remoteClient, &forbidden); err != nil {
+		return false, false, err
+	}
+
+	return true, false, nil
+}
+
+// manifestHash returns the hex encoded sha256 hash of the registration manifest.
+func manifestHash(manifest string) string {
+	sum := sha256.Sum256([]byte(manifest))
+	return hex.EncodeToString(sum[:])
+}
+
+// shortHash abbreviates a manifest hash for messages.
+func shortHash(hash string) string {
+	if len(hash) > shortHashLength {
+		return hash[:shortHashLength]
+	}
+
+	return hash
+}
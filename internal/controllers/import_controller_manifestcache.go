@@ -0,0 +1,204 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	provisioningv1 "github.com/rancher/turtles/internal/rancher/provisioning/v1"
+	turtlesannotations "github.com/rancher/turtles/util/annotations"
+)
+
+const manifestCacheDataKey = "clusters"
+
+// manifestCacheEntry is the registration manifest applied to a cluster. The manifest URL embeds the registration
+// token, only its hash is kept.
+type manifestCacheEntry struct {
+	URLHash      string      `json:"urlHash"`
+	ManifestHash string      `json:"manifestHash"`
+	Applied      metav1.Time `json:"applied"`
+}
+
+// manifestCache holds the registration manifest applied to each cluster, keyed by the namespaced name of the CAPI
+// cluster. It is loaded from its config map on first use, which only happens once the controller leads, and written
+// back on every change so the next leader starts from it.
+type manifestCache struct {
+	lock    sync.Mutex
+	loaded  bool
+	entries map[string]manifestCacheEntry
+}
+
+// manifestCached returns whether the registration manifest last applied to the cluster is still current, that is the
+// cluster is at the applied manifest hash and the registration URL of its Rancher cluster didn't change.
+func (r *CAPIImportReconciler) manifestCached(ctx context.Context, capiCluster *clusterv1.Cluster,
+	rancherCluster *provisioningv1.Cluster,
+) (bool, error) {
+	if r.ManifestCacheConfigMap.Name == "" {
+		return false, nil
+	}
+
+	r.manifests.lock.Lock()
+	defer r.manifests.lock.Unlock()
+
+	if err := r.loadManifestCache(ctx); err != nil {
+		return false, err
+	}
+
+	entry, ok := r.manifests.entries[client.ObjectKeyFromObject(capiCluster).String()]
+	if !ok || entry.ManifestHash != capiCluster.GetAnnotations()[turtlesannotations.ManifestHashAnnotation] {
+		return false, nil
+	}
+
+	host, err := manifestURLHost(capiCluster, r.ManifestURLHost, r.ManifestURLHostAllowlist)
+	if err != nil {
+		return false, err
+	}
+
+	manifestURL, err := getClusterRegistrationManifestURL(ctx, r.rancherClusterStatus(rancherCluster).ClusterName,
+		r.rancherClusterNamespace(capiCluster), r.RancherClient, host, r.tokenReissue())
+	if err != nil {
+		return false, err
+	}
+
+	return manifestURL != "" && entry.URLHash == manifestHash(manifestURL), nil
+}
+
+// cacheManifest records the registration manifest applied to the cluster.
+func (r *CAPIImportReconciler) cacheManifest(ctx context.Context, capiCluster *clusterv1.Cluster, manifestURL, hash string) error {
+	if r.ManifestCacheConfigMap.Name == "" {
+		return nil
+	}
+
+	r.manifests.lock.Lock()
+	defer r.manifests.lock.Unlock()
+
+	if err := r.loadManifestCache(ctx); err != nil {
+		return err
+	}
+
+	r.manifests.entries[client.ObjectKeyFromObject(capiCluster).String()] = manifestCacheEntry{
+		URLHash:      manifestHash(manifestURL),
+		ManifestHash: hash,
+		Applied:      metav1.NewTime(r.now().UTC()),
+	}
+
+	return r.storeManifestCache(ctx)
+}
+
+// forgetManifest drops the registration manifest recorded for a deleted cluster.
+func (r *CAPIImportReconciler) forgetManifest(ctx context.Context, key client.ObjectKey) error {
+	if r.ManifestCacheConfigMap.Name == "" {
+		return nil
+	}
+
+	r.manifests.lock.Lock()
+	defer r.manifests.lock.Unlock()
+
+	if err := r.loadManifestCache(ctx); err != nil {
+		return err
+	}
+
+	if _, ok := r.manifests.entries[key.String()]; !ok {
+		return nil
+	}
+
+	delete(r.manifests.entries, key.String())
+
+	return r.storeManifestCache(ctx)
+}
+
+// loadManifestCache reads the persisted cache once. The caller must hold the cache lock.
+func (r *CAPIImportReconciler) loadManifestCache(ctx context.Context) error {
+	if r.manifests.loaded {
+		return nil
+	}
+
+	cm := &corev1.ConfigMap{}
+
+	err := r.Client.Get(ctx, r.ManifestCacheConfigMap, cm)
+	if client.IgnoreNotFound(err) != nil {
+		return fmt.Errorf("getting manifest cache: %w", err)
+	}
+
+	entries := map[string]manifestCacheEntry{}
+
+	if data := cm.Data[manifestCacheDataKey]; data != "" {
+		if err := json.Unmarshal([]byte(data), &entries); err != nil {
+			log.FromContext(ctx).Error(err, "discarding unreadable manifest cache", "configMap", r.ManifestCacheConfigMap)
+
+			entries = map[string]manifestCacheEntry{}
+		}
+	}
+
+	r.manifests.entries = entries
+	r.manifests.loaded = true
+
+	return nil
+}
+
+// storeManifestCache writes the cache to its config map, creating it when missing. The caller must hold the cache lock.
+func (r *CAPIImportReconciler) storeManifestCache(ctx context.Context) error {
+	data, err := json.Marshal(r.manifests.entries)
+	if err != nil {
+		return fmt.Errorf("encoding manifest cache: %w", err)
+	}
+
+	cm := &corev1.ConfigMap{}
+
+	err = r.Client.Get(ctx, r.ManifestCacheConfigMap, cm)
+	if client.IgnoreNotFound(err) != nil {
+		return fmt.Errorf("getting manifest cache: %w", err)
+	}
+
+	if apierrors.IsNotFound(err) {
+		cm = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: r.ManifestCacheConfigMap.Namespace,
+				Name:      r.ManifestCacheConfigMap.Name,
+			},
+			Data: map[string]string{manifestCacheDataKey: string(data)},
+		}
+
+		if err := r.Client.Create(ctx, cm); err != nil {
+			return fmt.Errorf("creating manifest cache: %w", err)
+		}
+
+		return nil
+	}
+
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+
+	cm.Data[manifestCacheDataKey] = string(data)
+
+	if err := r.Client.Update(ctx, cm); err != nil {
+		return fmt.Errorf("updating manifest cache: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,273 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	turtlesv1 "github.com/rancher/turtles/api/v1alpha1"
+	managementv3 "github.com/rancher/turtles/internal/rancher/management/v3"
+	provisioningv1 "github.com/rancher/turtles/internal/rancher/provisioning/v1"
+	"github.com/rancher/turtles/testutil"
+	turtlesannotations "github.com/rancher/turtles/util/annotations"
+)
+
+var _ = Describe("manifest cache", func() {
+	var (
+		server         *testutil.ManifestServer
+		managementCl   client.Client
+		rancherCl      client.Client
+		capiCluster    *clusterv1.Cluster
+		rancherCluster *provisioningv1.Cluster
+	)
+
+	cacheKey := client.ObjectKey{Namespace: "rancher-turtles-system", Name: "manifest-cache"}
+
+	// newLeader returns a reconciler starting with an empty in-memory state, as after a failover.
+	newLeader := func() *CAPIImportReconciler {
+		return &CAPIImportReconciler{
+			Client:        managementCl,
+			RancherClient: rancherCl,
+			recorder:      record.NewFakeRecorder(10),
+			remoteClientGetter: func(_ context.Context, _ string, _ client.Client, _ client.ObjectKey) (client.Client, error) {
+				return fake.NewClientBuilder().WithScheme(scheme.Scheme).Build(), nil
+			},
+			DownloadOptions: DownloadOptions{
+				ManifestCacheConfigMap: cacheKey,
+			},
+		}
+	}
+
+	BeforeEach(func() {
+		server = testutil.NewManifestServer(manifestWithServerFields)
+		DeferCleanup(server.Close)
+
+		capiCluster = &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-cluster",
+			Namespace: "test-ns",
+			Labels:    map[string]string{importLabelName: "true"},
+		}}
+		rancherCluster = testutil.RancherCluster("test-cluster-capi", "test-ns", testutil.ClusterStateNameSet)
+
+		managementCl = fake.NewClientBuilder().WithScheme(scheme.Scheme).Build()
+		rancherCl = testutil.NewRancherClientBuilder().
+			WithCluster(rancherCluster.Name, rancherCluster.Namespace, testutil.ClusterStateNameSet, server.URL).
+			Build()
+	})
+
+	It("should persist the applied manifest", func() {
+		_, err := newLeader().reconcileNormal(ctx, capiCluster, rancherCluster)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(server.Requests()).To(Equal(1))
+
+		cm := &corev1.ConfigMap{}
+		Expect(managementCl.Get(ctx, cacheKey, cm)).To(Succeed())
+		Expect(cm.Data[manifestCacheDataKey]).To(ContainSubstring(manifestHash(manifestWithServerFields)))
+		Expect(cm.Data[manifestCacheDataKey]).To(ContainSubstring("test-ns/test-cluster"))
+		Expect(cm.Data[manifestCacheDataKey]).ToNot(ContainSubstring(server.URL))
+	})
+
+	It("should not download an unchanged manifest again after a failover", func() {
+		_, err := newLeader().reconcileNormal(ctx, capiCluster, rancherCluster)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(server.Requests()).To(Equal(1))
+
+		_, err = newLeader().reconcileNormal(ctx, capiCluster, rancherCluster)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(server.Requests()).To(Equal(1))
+	})
+
+	It("should download the manifest of a cluster not at the cached hash", func() {
+		_, err := newLeader().reconcileNormal(ctx, capiCluster, rancherCluster)
+		Expect(err).ToNot(HaveOccurred())
+
+		capiCluster.Annotations[turtlesannotations.ManifestHashAnnotation] = manifestHash("previous manifest")
+
+		_, err = newLeader().reconcileNormal(ctx, capiCluster, rancherCluster)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(server.Requests()).To(Equal(2))
+	})
+
+	It("should download the manifest again when the registration URL changes", func() {
+		_, err := newLeader().reconcileNormal(ctx, capiCluster, rancherCluster)
+		Expect(err).ToNot(HaveOccurred())
+
+		token := &managementv3.ClusterRegistrationToken{}
+		Expect(rancherCl.Get(ctx, client.ObjectKey{Namespace: "test-ns", Name: rancherCluster.Status.ClusterName}, token)).To(Succeed())
+		token.Status.ManifestURL = server.URL + "/rotated"
+		Expect(rancherCl.Status().Update(ctx, token)).To(Succeed())
+
+		_, err = newLeader().reconcileNormal(ctx, capiCluster, rancherCluster)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(server.Requests()).To(Equal(2))
+	})
+
+	It("should discard an unreadable cache", func() {
+		Expect(managementCl.Create(ctx, &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: cacheKey.Namespace, Name: cacheKey.Name},
+			Data:       map[string]string{manifestCacheDataKey: "not json"},
+		})).To(Succeed())
+
+		_, err := newLeader().reconcileNormal(ctx, capiCluster, rancherCluster)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(server.Requests()).To(Equal(1))
+
+		cm := &corev1.ConfigMap{}
+		Expect(managementCl.Get(ctx, cacheKey, cm)).To(Succeed())
+		Expect(cm.Data[manifestCacheDataKey]).To(ContainSubstring(manifestHash(manifestWithServerFields)))
+	})
+
+	It("should forget deleted clusters", func() {
+		r := newLeader()
+		_, err := r.reconcileNormal(ctx, capiCluster, rancherCluster)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(r.forgetManifest(ctx, client.ObjectKeyFromObject(capiCluster))).To(Succeed())
+
+		cm := &corev1.ConfigMap{}
+		Expect(managementCl.Get(ctx, cacheKey, cm)).To(Succeed())
+		Expect(cm.Data[manifestCacheDataKey]).To(Equal("{}"))
+
+		_, err = newLeader().reconcileNormal(ctx, capiCluster, rancherCluster)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(server.Requests()).To(Equal(2))
+	})
+
+	It("should always download the manifest when the cache is disabled", func() {
+		r := newLeader()
+		r.ManifestCacheConfigMap = client.ObjectKey{}
+
+		_, err := r.reconcileNormal(ctx, capiCluster, rancherCluster)
+		Expect(err).ToNot(HaveOccurred())
+
+		_, err = r.reconcileNormal(ctx, capiCluster, rancherCluster)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(server.Requests()).To(Equal(2))
+		Expect(managementCl.Get(ctx, cacheKey, &corev1.ConfigMap{})).ToNot(Succeed())
+	})
+})
+
+var _ = Describe("unchanged import manifest", func() {
+	var (
+		r            *CAPIImportReconciler
+		server       *testutil.ManifestServer
+		remoteWrites int
+		capiCluster  *clusterv1.Cluster
+		rancherKey   client.ObjectKey
+	)
+
+	BeforeEach(func() {
+		server = testutil.NewManifestServer(manifestWithServerFields)
+		DeferCleanup(server.Close)
+
+		capiCluster = &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-cluster",
+			Namespace: "test-ns",
+			Labels:    map[string]string{importLabelName: "true"},
+		}}
+		rancherKey = client.ObjectKey{Namespace: "test-ns", Name: "test-cluster-capi"}
+
+		remoteWrites = 0
+		remoteClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithInterceptorFuncs(interceptor.Funcs{
+			Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+				remoteWrites++
+				return c.Create(ctx, obj, opts...)
+			},
+			Patch: func(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+				remoteWrites++
+				return c.Patch(ctx, obj, patch, opts...)
+			},
+		}).Build()
+
+		r = &CAPIImportReconciler{
+			Client: fake.NewClientBuilder().WithScheme(scheme.Scheme).Build(),
+			RancherClient: testutil.NewRancherClientBuilder().
+				WithCluster(rancherKey.Name, rancherKey.Namespace, testutil.ClusterStateNameSet, server.URL).
+				Build(),
+			recorder: record.NewFakeRecorder(10),
+			remoteClientGetter: func(_ context.Context, _ string, _ client.Client, _ client.ObjectKey) (client.Client, error) {
+				return remoteClient, nil
+			},
+		}
+	})
+
+	reconcile := func() {
+		_, err := r.reconcileNormal(ctx, capiCluster, &provisioningv1.Cluster{ObjectMeta: metav1.ObjectMeta{
+			Name:      rancherKey.Name,
+			Namespace: rancherKey.Namespace,
+		}})
+		Expect(err).ToNot(HaveOccurred())
+	}
+
+	It("should not write to the downstream cluster when the manifest didn't change", func() {
+		reconcile()
+		Expect(remoteWrites).ToNot(BeZero())
+		Expect(capiCluster.GetAnnotations()).To(HaveKeyWithValue(turtlesannotations.ManifestHashAnnotation, manifestHash(manifestWithServerFields)))
+
+		remoteWrites = 0
+
+		reconcile()
+		Expect(server.Requests()).To(Equal(2))
+		Expect(remoteWrites).To(BeZero())
+		Expect(conditions.IsTrue(capiCluster, turtlesv1.ImportManifestAppliedCondition)).To(BeTrue())
+	})
+
+	It("should re-apply the manifest when it changed", func() {
+		reconcile()
+
+		setAnnotation(capiCluster, turtlesannotations.ManifestHashAnnotation, "previous")
+		remoteWrites = 0
+
+		reconcile()
+		Expect(remoteWrites).ToNot(BeZero())
+	})
+
+	It("should re-apply an unchanged manifest when the agent failed to register", func() {
+		reconcile()
+
+		conditions.MarkFalse(capiCluster, turtlesv1.RancherAgentRegisteredCondition, turtlesv1.AgentRegistrationFailedReason,
+			clusterv1.ConditionSeverityWarning, "agent pod is crash looping")
+		remoteWrites = 0
+
+		reconcile()
+		Expect(remoteWrites).ToNot(BeZero())
+	})
+
+	It("should re-apply an unchanged manifest when the previous apply failed", func() {
+		reconcile()
+
+		conditions.MarkFalse(capiCluster, turtlesv1.ImportManifestAppliedCondition, turtlesv1.ManifestApplyFailedReason,
+			clusterv1.ConditionSeverityWarning, "connection refused")
+		remoteWrites = 0
+
+		reconcile()
+		Expect(remoteWrites).ToNot(BeZero())
+	})
+})
@@ -0,0 +1,67 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	turtlesv1 "github.com/rancher/turtles/api/v1alpha1"
+)
+
+// namePolicyGate returns the reason the Rancher cluster name of the CAPI cluster violates the naming policy, or an
+// empty string when it complies or no policy is configured.
+func (r *CAPIImportReconciler) namePolicyGate(_ context.Context, capiCluster *clusterv1.Cluster) (string, error) {
+	if r.NamePolicy == nil {
+		return "", nil
+	}
+
+	name, err := r.rancherClusterName(capiCluster)
+	if err != nil {
+		return "", err
+	}
+
+	if err := r.NamePolicy.Validate(name); err != nil {
+		return err.Error(), nil
+	}
+
+	return "", nil
+}
+
+// checkNamePolicy reports whether the name of the Rancher cluster complies with the naming policy in the
+// RancherClusterNameCompliant condition, emitting a warning event when it doesn't. It returns false when the import
+// must be skipped.
+func (r *CAPIImportReconciler) checkNamePolicy(capiCluster *clusterv1.Cluster, name string) bool {
+	if r.NamePolicy == nil {
+		return true
+	}
+
+	if err := r.NamePolicy.Validate(name); err != nil {
+		conditions.MarkFalse(capiCluster, turtlesv1.RancherClusterNameCompliantCondition, turtlesv1.NamePolicyViolationReason,
+			clusterv1.ConditionSeverityError, "%s", err)
+		r.recorder.Event(capiCluster, corev1.EventTypeWarning, turtlesv1.NamePolicyViolationReason, err.Error()+", skipping import")
+
+		return false
+	}
+
+	conditions.MarkTrue(capiCluster, turtlesv1.RancherClusterNameCompliantCondition)
+
+	return true
+}
@@ -0,0 +1,104 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	turtlesv1 "github.com/rancher/turtles/api/v1alpha1"
+	provisioningv1 "github.com/rancher/turtles/internal/rancher/provisioning/v1"
+	"github.com/rancher/turtles/testutil"
+	turtlesnaming "github.com/rancher/turtles/util/naming"
+)
+
+var _ = Describe("Rancher cluster name policy", func() {
+	var (
+		r           *CAPIImportReconciler
+		recorder    *record.FakeRecorder
+		capiCluster *clusterv1.Cluster
+	)
+
+	BeforeEach(func() {
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test-ns"}}
+		capiCluster = &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-cluster",
+			Namespace: "test-ns",
+			Labels:    map[string]string{importLabelName: "true"},
+		}}
+		recorder = record.NewFakeRecorder(10)
+
+		policy, err := turtlesnaming.NewRegexPolicy(`bu1-[a-z0-9-]+`)
+		Expect(err).ToNot(HaveOccurred())
+
+		r = &CAPIImportReconciler{
+			Client:        fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(ns, capiCluster).Build(),
+			RancherClient: testutil.NewRancherClientBuilder().WithObjects(ns.DeepCopy()).Build(),
+			recorder:      recorder,
+			RancherClusterOptions: RancherClusterOptions{
+				NamePolicy: policy,
+			},
+		}
+	})
+
+	It("should not import a cluster whose Rancher cluster name violates the policy", func() {
+		res, err := r.reconcile(ctx, capiCluster)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res).To(BeZero())
+
+		Expect(apierrors.IsNotFound(r.RancherClient.Get(ctx, client.ObjectKey{Namespace: "test-ns", Name: "test-cluster-capi"},
+			&provisioningv1.Cluster{}))).To(BeTrue())
+		Expect(conditions.IsFalse(capiCluster, turtlesv1.RancherClusterNameCompliantCondition)).To(BeTrue())
+		Expect(conditions.GetReason(capiCluster, turtlesv1.RancherClusterNameCompliantCondition)).To(Equal(turtlesv1.NamePolicyViolationReason))
+		Expect(conditions.GetMessage(capiCluster, turtlesv1.RancherClusterNameCompliantCondition)).To(ContainSubstring(`"test-cluster-capi"`))
+		Expect(conditions.IsFalse(capiCluster, turtlesv1.ImportEligibleCondition)).To(BeTrue())
+		Expect(conditions.GetMessage(capiCluster, turtlesv1.ImportEligibleCondition)).To(ContainSubstring("NamePolicy"))
+		Expect(recorder.Events).To(Receive(ContainSubstring(turtlesv1.NamePolicyViolationReason)))
+	})
+
+	It("should import a cluster whose templated Rancher cluster name complies with the policy", func() {
+		tmpl, err := turtlesnaming.NewTemplate(`bu1-{{ .Name }}`)
+		Expect(err).ToNot(HaveOccurred())
+		r.NameTemplate = tmpl
+
+		_, err = r.reconcile(ctx, capiCluster)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(r.RancherClient.Get(ctx, client.ObjectKey{Namespace: "test-ns", Name: "bu1-test-cluster"}, &provisioningv1.Cluster{})).To(Succeed())
+		Expect(conditions.IsTrue(capiCluster, turtlesv1.RancherClusterNameCompliantCondition)).To(BeTrue())
+		Expect(recorder.Events).ToNot(Receive())
+	})
+
+	It("should not check names without a policy", func() {
+		r.NamePolicy = nil
+
+		_, err := r.reconcile(ctx, capiCluster)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(r.RancherClient.Get(ctx, client.ObjectKey{Namespace: "test-ns", Name: "test-cluster-capi"}, &provisioningv1.Cluster{})).To(Succeed())
+		Expect(conditions.Get(capiCluster, turtlesv1.RancherClusterNameCompliantCondition)).To(BeNil())
+	})
+})
@@ -0,0 +1,108 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	turtlesv1 "github.com/rancher/turtles/api/v1alpha1"
+	"github.com/rancher/turtles/util"
+)
+
+// ensureRancherNamespace checks the namespace the Rancher cluster is created in exists, creating it when
+// CreateRancherNamespace is set. A missing namespace is reported as a condition and an event on the CAPI cluster.
+func (r *CAPIImportReconciler) ensureRancherNamespace(ctx context.Context, capiCluster *clusterv1.Cluster, namespace string) (bool, error) {
+	log := log.FromContext(ctx)
+
+	err := r.RancherClient.Get(ctx, client.ObjectKey{Name: namespace}, &corev1.Namespace{})
+	if apierrors.IsNotFound(err) && r.CreateRancherNamespace {
+		log.Info("creating missing Rancher cluster namespace", "namespace", namespace)
+
+		err = r.RancherClient.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}})
+		if apierrors.IsAlreadyExists(err) {
+			err = nil
+		}
+	}
+
+	if apierrors.IsNotFound(err) {
+		message := fmt.Sprintf("Rancher cluster namespace %s does not exist", namespace)
+
+		log.Info(message)
+		conditions.MarkFalse(capiCluster, turtlesv1.RancherNamespaceCondition, turtlesv1.RancherNamespaceMissingReason,
+			clusterv1.ConditionSeverityError, message)
+		r.recorder.Event(capiCluster, corev1.EventTypeWarning, turtlesv1.RancherNamespaceMissingReason, message)
+
+		return false, nil
+	}
+
+	if err != nil {
+		return false, fmt.Errorf("checking Rancher cluster namespace %s: %w", namespace, err)
+	}
+
+	if conditions.Has(capiCluster, turtlesv1.RancherNamespaceCondition) {
+		conditions.MarkTrue(capiCluster, turtlesv1.RancherNamespaceCondition)
+	}
+
+	return true, nil
+}
+
+// recordNamespaceSkip emits an event on the namespace of a cluster which isn't imported because neither the cluster nor
+// the namespace are labeled for import. Events are emitted at most once per NamespaceEventInterval for each namespace.
+func (r *CAPIImportReconciler) recordNamespaceSkip(ctx context.Context, capiCluster *clusterv1.Cluster) error {
+	if r.NamespaceEventInterval == 0 {
+		return nil
+	}
+
+	// the cluster opted out of the import itself, so the namespace isn't the reason it is skipped
+	if hasLabel, _ := util.ShouldImport(capiCluster, r.importLabel()); hasLabel {
+		return nil
+	}
+
+	r.namespaceEventsLock.Lock()
+	defer r.namespaceEventsLock.Unlock()
+
+	now := r.clock.Now()
+	if last, ok := r.namespaceEvents[capiCluster.Namespace]; ok && now.Sub(last) < r.NamespaceEventInterval {
+		return nil
+	}
+
+	ns := &corev1.Namespace{}
+	if err := r.Client.Get(ctx, client.ObjectKey{Name: capiCluster.Namespace}, ns); err != nil {
+		return fmt.Errorf("getting namespace: %w", err)
+	}
+
+	r.recorder.Eventf(ns, corev1.EventTypeNormal, turtlesv1.ImportSkippedReason,
+		"Cluster %s is not imported into Rancher as the namespace is not labeled with %s=true", capiCluster.Name, r.importLabel())
+
+	if r.namespaceEvents == nil {
+		r.namespaceEvents = map[string]time.Time{}
+	}
+
+	r.namespaceEvents[capiCluster.Namespace] = now
+
+	return nil
+}
@@ -0,0 +1,94 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"strings"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	turtlesannotations "github.com/rancher/turtles/util/annotations"
+	turtlesnaming "github.com/rancher/turtles/util/naming"
+)
+
+// rancherClusterKey returns the key of the Rancher cluster for the CAPI cluster, in the Rancher cluster namespace.
+func (r *CAPIImportReconciler) rancherClusterKey(capiCluster *clusterv1.Cluster) (client.ObjectKey, error) {
+	key := turtlesnaming.RancherClusterKey(client.ObjectKeyFromObject(capiCluster))
+	key.Namespace = r.rancherClusterNamespace(capiCluster)
+
+	if r.NameTemplate == nil {
+		return key, nil
+	}
+
+	name, err := r.rancherClusterName(capiCluster)
+	if err != nil {
+		return client.ObjectKey{}, err
+	}
+
+	key.Name = name
+
+	return key, nil
+}
+
+// rancherClusterName returns the name of the Rancher cluster for the CAPI cluster, rendered from the name template
+// when one is configured. The rendered name is saved in the RancherCluster annotation of the CAPI cluster the first time
+// and reused afterwards, so that changing the labels or annotations the template reads never renames the Rancher
+// cluster. Names violating the naming policy are not saved, so that they are rendered again once fixed.
+func (r *CAPIImportReconciler) rancherClusterName(capiCluster *clusterv1.Cluster) (string, error) {
+	if r.NameTemplate == nil {
+		return turtlesnaming.Name(capiCluster.Name).ToRancherName(), nil
+	}
+
+	if name := savedRancherClusterName(capiCluster); name != "" {
+		return name, nil
+	}
+
+	name, err := r.NameTemplate.Render(capiCluster)
+	if err != nil {
+		return "", fmt.Errorf("getting rancher cluster name: %w", err)
+	}
+
+	if r.NamePolicy == nil || r.NamePolicy.Validate(name) == nil {
+		setAnnotation(capiCluster, turtlesannotations.RancherClusterAnnotation,
+			client.ObjectKey{Namespace: r.rancherClusterNamespace(capiCluster), Name: name}.String())
+	}
+
+	return name, nil
+}
+
+// savedRancherClusterName returns the name of the Rancher cluster saved in the RancherCluster annotation of the CAPI
+// cluster, or an empty string when none is saved.
+func savedRancherClusterName(capiCluster *clusterv1.Cluster) string {
+	ref := capiCluster.GetAnnotations()[turtlesannotations.RancherClusterAnnotation]
+	if _, name, found := strings.Cut(ref, "/"); found {
+		return name
+	}
+
+	return ref
+}
+
+// capiClusterName returns the name of the CAPI cluster owning the Rancher cluster. The name stored on the Rancher cluster
+// takes precedence, as templated or truncated names can't be converted back.
+func capiClusterName(rancherCluster client.Object) string {
+	if name := rancherCluster.GetAnnotations()[turtlesannotations.CAPIClusterNameAnnotation]; name != "" {
+		return name
+	}
+
+	return turtlesnaming.Name(rancherCluster.GetName()).ToCapiName()
+}
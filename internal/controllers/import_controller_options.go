@@ -0,0 +1,285 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"time"
+
+	"github.com/blang/semver/v4"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/rancher/turtles/util"
+	turtlesnaming "github.com/rancher/turtles/util/naming"
+	"github.com/rancher/turtles/util/schedule"
+)
+
+// DownloadOptions configures how the registration manifest of the imported clusters is downloaded from Rancher.
+type DownloadOptions struct {
+	InsecureSkipVerify bool
+
+	// CABundle is the PEM encoded CA bundle the certificate of the Rancher server is verified against when downloading
+	// the registration manifest. It takes precedence over InsecureSkipVerify.
+	CABundle []byte
+
+	// ManifestURLHost, when set, replaces the host of the registration manifest URL, e.g. with a mirror reachable
+	// from air-gapped clusters. The ManifestURLHostAnnotation on the CAPI cluster takes precedence when it names one of
+	// the ManifestURLHostAllowlist hosts.
+	ManifestURLHost string
+
+	// ManifestURLHostAllowlist lists the hosts the ManifestURLHostAnnotation of the CAPI clusters may select. Clusters
+	// annotated with any other host are not imported.
+	ManifestURLHostAllowlist []string
+
+	// ManifestDownloadAttempts is the maximum number of attempts to download the registration manifest. Server errors
+	// and connection failures are retried, client errors are not. The manifest is downloaded once when not positive.
+	ManifestDownloadAttempts int
+
+	// ManifestDownloadInterval is the wait before the first retry of the registration manifest download, doubled with
+	// jitter for each following retry.
+	ManifestDownloadInterval time.Duration
+
+	// ManifestDownloadTimeout bounds each registration manifest download attempt. Defaults to 30 seconds.
+	ManifestDownloadTimeout time.Duration
+
+	// ManifestCacheConfigMap, when set, is the config map the registration manifest applied to each cluster is
+	// persisted to. The manifest of a cluster still at the applied hash is not downloaded again while its registration
+	// URL is unchanged, including by a new leader after a failover.
+	ManifestCacheConfigMap client.ObjectKey
+}
+
+// ApplyOptions configures how the registration manifest is applied to the downstream clusters.
+type ApplyOptions struct {
+	// IncrementalApply only writes the manifest objects which are missing or differ in the downstream cluster.
+	IncrementalApply bool
+
+	// UseServerSideApply applies the manifest objects with server-side apply using the turtles field manager, so that
+	// changes to the downloaded manifest are propagated to the existing objects. It takes precedence over IncrementalApply.
+	UseServerSideApply bool
+
+	// DryRun applies the manifest objects with a server-side dry-run: they are validated by the downstream cluster and
+	// logged, but not persisted. The import progresses up to the manifest apply, which is reported as a dry-run.
+	DryRun bool
+
+	// ApplyConcurrency is the maximum number of manifest objects written to the downstream cluster concurrently. The
+	// namespaces and custom resource definitions are always written first, in order. Objects are written one at a time
+	// when lower than 2.
+	ApplyConcurrency int
+
+	// CRDEstablishedTimeout bounds the wait for the CRDs of the manifest to be established before the custom resources
+	// of their kinds are applied. Defaults to 1 minute.
+	CRDEstablishedTimeout time.Duration
+
+	// ObjectApplyTimeout is the time an in-flight write of a manifest object is given to finish once the reconcile is
+	// cancelled, e.g. when the controller stops. It should match the graceful shutdown timeout of the manager. Defaults
+	// to 30 seconds.
+	ObjectApplyTimeout time.Duration
+
+	// ContinueOnForbidden keeps applying the rest of the import manifest when the remote cluster client is forbidden
+	// to write some of its objects. The forbidden objects are reported with the ManifestApplyPermitted condition.
+	ContinueOnForbidden bool
+
+	// WaitForMachinePools delays the apply of the import manifest until at least one of the machine pools of the CAPI
+	// cluster is ready, so that the agent is not scheduled on a node pool still scaling up. Clusters without machine
+	// pools are not affected.
+	WaitForMachinePools bool
+
+	// ExistingAgentPolicy selects whether a healthy cattle-cluster-agent already registered with the same Rancher on
+	// the downstream cluster is adopted, or replaced by applying the import manifest.
+	ExistingAgentPolicy AgentPolicy
+
+	// LabelAdoptedAgent labels the adopted cattle-cluster-agent deployment as applied by turtles.
+	LabelAdoptedAgent bool
+
+	// AgentNodeSelector is merged into the node selector of the cattle-cluster-agent deployment before it is applied.
+	AgentNodeSelector map[string]string
+
+	// AgentTolerations are added to the tolerations of the cattle-cluster-agent deployment before it is applied.
+	AgentTolerations []corev1.Toleration
+
+	// AdditionalManifest is an inline manifest applied to the downstream cluster after the registration manifest.
+	AdditionalManifest string
+
+	// AdditionalManifestConfigMap references a config map whose data is applied to the downstream cluster after the
+	// registration manifest.
+	AdditionalManifestConfigMap client.ObjectKey
+
+	// ReapplyOnDisconnect re-applies the import manifest when the cluster is reported as degraded.
+	ReapplyOnDisconnect bool
+
+	// RecordManifestStats enables recording the registration manifest size and object count on the CAPI cluster.
+	RecordManifestStats bool
+}
+
+// SelectionOptions configures which CAPI clusters are imported, and when they become eligible for the import.
+type SelectionOptions struct {
+	// ClusterSelector, when set, restricts the import to the CAPI clusters whose labels match it, in addition to the
+	// import label of the cluster or its namespace. Clusters not matching the selector are not reconciled at all.
+	ClusterSelector *metav1.LabelSelector
+
+	// WatchNamespaces, when set, restricts the import to the CAPI clusters in these namespaces. Clusters in other
+	// namespaces are not reconciled, whatever the import label of the cluster or its namespace.
+	WatchNamespaces []string
+
+	// ImportLabel is the label marking the CAPI clusters, or their namespaces, to import. Defaults to
+	// DefaultImportLabel.
+	ImportLabel string
+
+	// EagerCreate creates the Rancher cluster as soon as the CAPI cluster is marked for import, before its control
+	// plane is ready, so that Rancher sets it up while the control plane comes up. The registration manifest is still
+	// only applied once the control plane is ready.
+	EagerCreate bool
+
+	// ControlPlaneReadiness configures the additional signals, e.g. provider-specific conditions, the control plane of
+	// the CAPI cluster is considered ready on. The standard ControlPlaneReady status and condition are always accepted.
+	ControlPlaneReadiness util.ControlPlaneReadiness
+
+	// SupportedKubernetesVersions is the range of Kubernetes versions Rancher supports for imported clusters. Clusters
+	// out of the range are not imported, unless AllowUnsupportedKubernetesVersions is set. Nil disables the check.
+	SupportedKubernetesVersions semver.Range
+
+	// AllowUnsupportedKubernetesVersions imports clusters out of the supported range with a warning.
+	AllowUnsupportedKubernetesVersions bool
+
+	// MaxRancherClusters is the number of clusters Rancher can manage. Clusters are not imported while Rancher
+	// manages that many clusters. Zero disables the limit.
+	MaxRancherClusters int
+
+	// ImportSchedule restricts imports to its maintenance windows. Imports are always allowed when unset.
+	ImportSchedule *schedule.Schedule
+}
+
+// TimingOptions configures the intervals, timeouts and retry limits of the import.
+type TimingOptions struct {
+	// RancherClusterPollInterval is the first requeue interval of a CAPI cluster waiting for Rancher to set the name
+	// of its Rancher cluster or the manifest URL of its registration token, doubled on each consecutive wait.
+	// Defaults to 5 seconds.
+	RancherClusterPollInterval time.Duration
+
+	// RegistrationTokenGracePeriod is the time Rancher has to set the manifest URL of a registration token before the
+	// token is re-issued. Zero disables the re-issue of tokens without manifest URL, expired tokens are always re-issued.
+	RegistrationTokenGracePeriod time.Duration
+
+	// RegistrationTokenReissueInterval is the minimum age of a registration token before it is re-issued.
+	RegistrationTokenReissueInterval time.Duration
+
+	// MaxTokenRefreshes is the number of times the registration token of a cluster whose agent is rejected by Rancher
+	// is replaced and its manifest re-applied, until the cluster registers. Zero disables the token refresh.
+	MaxTokenRefreshes int
+
+	// RegistrationCheckWindow is the time the Rancher cluster has to become ready after the import manifest
+	// was applied, before the downstream agent is inspected for failures. Zero disables the verification.
+	RegistrationCheckWindow time.Duration
+
+	// DisconnectedThreshold is the time the Rancher cluster can stay not ready while the downstream agent is running,
+	// before the cluster is reported as degraded. Zero disables the escalation.
+	DisconnectedThreshold time.Duration
+
+	// MinReapplyInterval is the minimum time between two re-applications of the import manifest to the same cluster,
+	// so an agent flapping between ready and disconnected doesn't cause apply storms. Zero disables the throttling.
+	MinReapplyInterval time.Duration
+
+	// AgentHealthInterval is the interval the agent presence is verified at. Defaults to 5 minutes.
+	AgentHealthInterval time.Duration
+
+	// SelfCheckInterval is the interval the import pipeline self-check served by SelfCheckHandler is run at.
+	SelfCheckInterval time.Duration
+
+	// MaxImportAttempts is the number of consecutive failed import attempts after which the controller stops retrying
+	// at its rate limit and backs off to ImportBackoffInterval. Zero disables the limit.
+	MaxImportAttempts int
+
+	// ImportBackoffInterval is the interval failed imports are retried at once MaxImportAttempts is reached.
+	ImportBackoffInterval time.Duration
+
+	// ImportSLOThreshold is the import duration above which an import is counted as an SLO breach. Zero disables
+	// the SLO breach counter.
+	ImportSLOThreshold time.Duration
+
+	// NamespaceEnqueueSpread is the window the clusters of a changed namespace are enqueued over, to avoid a burst of
+	// simultaneous imports. Zero enqueues them immediately.
+	NamespaceEnqueueSpread time.Duration
+
+	// NamespaceEventInterval is the minimum interval between the events emitted on a namespace not marked for import,
+	// explaining why its clusters are not imported. Zero disables the events.
+	NamespaceEventInterval time.Duration
+}
+
+// RancherClusterOptions configures the Rancher clusters created for the imported CAPI clusters and the metadata
+// kept in sync with them.
+type RancherClusterOptions struct {
+	// RancherClusterNamespace, when set, is the namespace the Rancher clusters are created in instead of the namespace
+	// of their CAPI cluster. Rancher clusters in another namespace than their CAPI cluster are linked to it with labels
+	// instead of an owner reference, and deleted by the controller along with it. The namespace can be overridden per
+	// cluster with the rancher-cluster-namespace annotation of the CAPI cluster, which must be set before the import.
+	RancherClusterNamespace string
+
+	// CreateRancherNamespace enables creating the namespace of the Rancher cluster when it is missing.
+	CreateRancherNamespace bool
+
+	// OwnedLabel is the label marking the Rancher clusters and objects created by the controller. Defaults to
+	// DefaultOwnedLabel.
+	OwnedLabel string
+
+	// MirrorRancherReadiness mirrors the agent deployment and the readiness of the Rancher cluster in the
+	// RancherAgentDeployed and RancherClusterReady conditions of the CAPI cluster, kept current as the Rancher cluster
+	// status changes. The import duration metrics and the imported phase of the import status are derived from the
+	// RancherClusterReady condition, so they are only available with the mirror enabled.
+	MirrorRancherReadiness bool
+
+	// NameTemplate, when set, overrides the default naming convention of the Rancher cluster.
+	NameTemplate *turtlesnaming.Template
+
+	// NamePolicy, when set, validates the Rancher cluster names before they are created. CAPI clusters whose Rancher
+	// cluster name violates the policy are not imported.
+	NamePolicy turtlesnaming.NamePolicy
+
+	// TopologyLabels enables stamping the region and zone labels of the CAPI cluster on the Rancher cluster.
+	TopologyLabels bool
+
+	// RegionFields maps infrastructure cluster kinds to the dot separated path of the field holding their region.
+	RegionFields map[string]string
+
+	// TopologyVariableMapping, when set, is the config map mapping the topology variables of the CAPI clusters to the
+	// labels they are projected to on the Rancher cluster. The mapping is watched, so it can change at runtime.
+	TopologyVariableMapping client.ObjectKey
+
+	// AccessLabels is the list of labels used by the Rancher RBAC which must always be present on the Rancher
+	// cluster. Their values are taken from the CAPI cluster annotations or its namespace labels.
+	AccessLabels []string
+
+	// DeletionProtection protects the Rancher cluster against accidental deletion with a finalizer, re-asserted
+	// until the CAPI cluster is deleted or the protection is lifted on the Rancher cluster.
+	DeletionProtection bool
+
+	// LabelsToRancher is the list of CAPI cluster labels mirrored onto the Rancher cluster, when it is created and
+	// on every reconcile, removing the ones deleted from the CAPI cluster. An entry ending with * mirrors all the
+	// labels with its prefix. The labels set by turtles on the Rancher cluster are never mirrored.
+	LabelsToRancher []string
+
+	// AnnotationsToRancher is the list of CAPI cluster annotations mirrored onto the Rancher cluster. An entry ending
+	// with * mirrors all the annotations with its prefix.
+	AnnotationsToRancher []string
+
+	// AnnotationsFromRancher is the list of Rancher cluster annotations mirrored back onto the CAPI cluster.
+	AnnotationsFromRancher []string
+
+	// TimelineEntries is the number of import lifecycle entries kept in the timeline config map of each Rancher
+	// cluster, the oldest being dropped first. Zero disables the timeline.
+	TimelineEntries int
+}
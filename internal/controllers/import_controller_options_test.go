@@ -0,0 +1,797 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-logr/logr"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	clocktesting "k8s.io/utils/clock/testing"
+	"k8s.io/utils/ptr"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/cluster-api/util/predicates"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	turtlesv1 "github.com/rancher/turtles/api/v1alpha1"
+	provisioningv1 "github.com/rancher/turtles/internal/rancher/provisioning/v1"
+	"github.com/rancher/turtles/testutil"
+	"github.com/rancher/turtles/util"
+	turtlesannotations "github.com/rancher/turtles/util/annotations"
+	"github.com/rancher/turtles/util/schedule"
+)
+
+var _ = Describe("import maintenance windows", func() {
+	var (
+		r              *CAPIImportReconciler
+		fakeClock      *clocktesting.FakeClock
+		capiCluster    *clusterv1.Cluster
+		rancherCluster *provisioningv1.Cluster
+	)
+
+	BeforeEach(func() {
+		importSchedule, err := schedule.Parse([]string{"22:00-06:00"}, "UTC")
+		Expect(err).ToNot(HaveOccurred())
+
+		capiCluster = &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-cluster",
+			Namespace: "test-ns",
+			Labels:    map[string]string{importLabelName: "true"},
+		}}
+		rancherCluster = &provisioningv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test-cluster-capi", Namespace: "test-ns"}}
+
+		fakeClock = clocktesting.NewFakeClock(time.Date(2024, 1, 3, 12, 0, 0, 0, time.UTC))
+
+		r = &CAPIImportReconciler{
+			Client: fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(capiCluster).Build(),
+			RancherClient: fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(
+				&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test-ns"}},
+			).Build(),
+			clock: fakeClock,
+			SelectionOptions: SelectionOptions{
+				ImportSchedule: importSchedule,
+			},
+		}
+	})
+
+	It("should defer the import outside of the window", func() {
+		res, err := r.reconcileNormal(ctx, capiCluster, rancherCluster)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res.RequeueAfter).To(Equal(10 * time.Hour))
+		Expect(conditions.GetReason(capiCluster, turtlesv1.ImportWindowCondition)).To(Equal(turtlesv1.WaitingForImportWindowReason))
+
+		err = r.RancherClient.Get(ctx, client.ObjectKeyFromObject(rancherCluster), &provisioningv1.Cluster{})
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("should import inside of the window", func() {
+		fakeClock.Step(11 * time.Hour)
+
+		res, err := r.reconcileNormal(ctx, capiCluster, rancherCluster)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res.RequeueAfter).To(Equal(minRequeueDuration))
+		Expect(conditions.IsTrue(capiCluster, turtlesv1.ImportWindowCondition)).To(BeTrue())
+		Expect(r.RancherClient.Get(ctx, client.ObjectKeyFromObject(rancherCluster), &provisioningv1.Cluster{})).To(Succeed())
+	})
+})
+
+var _ = Describe("import source", func() {
+	var (
+		ns             *corev1.Namespace
+		capiCluster    *clusterv1.Cluster
+		rancherCluster *provisioningv1.Cluster
+	)
+
+	BeforeEach(func() {
+		ns = &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test-ns"}}
+		capiCluster = &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", Namespace: ns.Name}}
+		rancherCluster = &provisioningv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test-cluster-capi", Namespace: ns.Name}}
+	})
+
+	reconcile := func() {
+		r := &CAPIImportReconciler{
+			Client:        fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(ns, capiCluster).Build(),
+			RancherClient: fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(ns.DeepCopy()).Build(),
+		}
+
+		_, err := r.reconcileNormal(ctx, capiCluster, rancherCluster)
+		Expect(err).ToNot(HaveOccurred())
+	}
+
+	It("should record a cluster label as the import source", func() {
+		capiCluster.Labels = map[string]string{importLabelName: "true"}
+		reconcile()
+		Expect(capiCluster.Annotations).To(HaveKeyWithValue(turtlesannotations.ImportSourceAnnotation, string(util.ImportSourceClusterLabel)))
+	})
+
+	It("should record a namespace label as the import source", func() {
+		ns.Labels = map[string]string{importLabelName: "true"}
+		reconcile()
+		Expect(capiCluster.Annotations).To(HaveKeyWithValue(turtlesannotations.ImportSourceAnnotation, string(util.ImportSourceNamespaceLabel)))
+	})
+
+	It("should not record a source when the cluster is not imported", func() {
+		reconcile()
+		Expect(capiCluster.Annotations).ToNot(HaveKey(turtlesannotations.ImportSourceAnnotation))
+	})
+})
+
+var _ = Describe("cluster selector", func() {
+	var (
+		ns          *corev1.Namespace
+		capiCluster *clusterv1.Cluster
+	)
+
+	BeforeEach(func() {
+		ns = &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test-ns"}}
+		capiCluster = &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-cluster",
+			Namespace: ns.Name,
+			Labels:    map[string]string{importLabelName: "true", "env": "prod"},
+		}}
+	})
+
+	reconciled := func(selector *metav1.LabelSelector) bool {
+		r := &CAPIImportReconciler{
+			Client: fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(ns).Build(),
+			SelectionOptions: SelectionOptions{
+				ClusterSelector: selector,
+				EagerCreate:     true,
+			},
+		}
+
+		clusterPredicates, err := r.clusterPredicates(ctx, logr.Discard())
+		Expect(err).ToNot(HaveOccurred())
+
+		return predicates.All(logr.Discard(), clusterPredicates...).Create(event.CreateEvent{Object: capiCluster})
+	}
+
+	It("should reconcile labeled clusters when no selector is set", func() {
+		Expect(reconciled(nil)).To(BeTrue())
+	})
+
+	It("should reconcile labeled clusters matching the selector", func() {
+		Expect(reconciled(&metav1.LabelSelector{MatchLabels: map[string]string{"env": "prod"}})).To(BeTrue())
+	})
+
+	It("should skip labeled clusters not matching the selector", func() {
+		Expect(reconciled(&metav1.LabelSelector{MatchLabels: map[string]string{"env": "dev"}})).To(BeFalse())
+	})
+
+	It("should skip clusters matching the selector without the import label", func() {
+		delete(capiCluster.Labels, importLabelName)
+		Expect(reconciled(&metav1.LabelSelector{MatchLabels: map[string]string{"env": "prod"}})).To(BeFalse())
+	})
+
+	It("should reject an invalid selector", func() {
+		r := &CAPIImportReconciler{
+			SelectionOptions: SelectionOptions{
+				ClusterSelector: &metav1.LabelSelector{
+					MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "env", Operator: "Unknown"}},
+				},
+			},
+		}
+
+		_, err := r.clusterPredicates(ctx, logr.Discard())
+		Expect(err).To(MatchError(ContainSubstring("parsing cluster selector")))
+	})
+})
+
+var _ = Describe("watched namespaces", func() {
+	var (
+		watchedNs   *corev1.Namespace
+		otherNs     *corev1.Namespace
+		capiCluster *clusterv1.Cluster
+		cl          client.Client
+		r           *CAPIImportReconciler
+	)
+
+	BeforeEach(func() {
+		watchedNs = &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "watched-ns"}}
+		otherNs = &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+			Name:   "other-ns",
+			Labels: map[string]string{importLabelName: "true"},
+		}}
+		capiCluster = &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-cluster",
+			Namespace: otherNs.Name,
+			Labels:    map[string]string{importLabelName: "true"},
+		}}
+
+		cl = fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(watchedNs, otherNs, capiCluster).Build()
+		r = &CAPIImportReconciler{
+			Client: cl,
+			SelectionOptions: SelectionOptions{
+				WatchNamespaces: []string{watchedNs.Name},
+				EagerCreate:     true,
+			},
+		}
+	})
+
+	capiPredicates := func() predicate.Funcs {
+		clusterPredicates, err := r.clusterPredicates(ctx, logr.Discard())
+		Expect(err).ToNot(HaveOccurred())
+
+		return predicates.All(logr.Discard(), clusterPredicates...)
+	}
+
+	It("should ignore labeled clusters in an unwatched namespace", func() {
+		Expect(capiPredicates().Create(event.CreateEvent{Object: capiCluster})).To(BeFalse())
+	})
+
+	It("should not enqueue the clusters of an unwatched namespace with the import label", func() {
+		Expect(namespaceToCapiClusters(ctx, capiPredicates(), cl, importLabelName)(ctx, otherNs)).To(BeEmpty())
+	})
+
+	It("should reconcile labeled clusters in a watched namespace", func() {
+		capiCluster.Namespace = watchedNs.Name
+		Expect(capiPredicates().Create(event.CreateEvent{Object: capiCluster})).To(BeTrue())
+	})
+
+	It("should reconcile labeled clusters in every namespace when none is set", func() {
+		r.WatchNamespaces = nil
+		Expect(capiPredicates().Create(event.CreateEvent{Object: capiCluster})).To(BeTrue())
+		Expect(namespaceToCapiClusters(ctx, capiPredicates(), cl, importLabelName)(ctx, otherNs)).To(ConsistOf(
+			ctrl.Request{NamespacedName: client.ObjectKeyFromObject(capiCluster)},
+		))
+	})
+})
+
+var _ = Describe("rancher cluster namespace", func() {
+	var (
+		r              *CAPIImportReconciler
+		recorder       *record.FakeRecorder
+		capiCluster    *clusterv1.Cluster
+		rancherCluster *provisioningv1.Cluster
+	)
+
+	BeforeEach(func() {
+		capiCluster = &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-cluster",
+			Namespace: "test-ns",
+			Labels:    map[string]string{importLabelName: "true"},
+		}}
+		rancherCluster = &provisioningv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test-cluster-capi", Namespace: "test-ns"}}
+		recorder = record.NewFakeRecorder(10)
+
+		r = &CAPIImportReconciler{
+			Client:        fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(capiCluster).Build(),
+			RancherClient: fake.NewClientBuilder().WithScheme(scheme.Scheme).Build(),
+			recorder:      recorder,
+		}
+	})
+
+	It("should report a missing namespace", func() {
+		res, err := r.reconcileNormal(ctx, capiCluster, rancherCluster)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res.RequeueAfter).To(Equal(defaultRequeueDuration))
+
+		Expect(conditions.IsFalse(capiCluster, turtlesv1.RancherNamespaceCondition)).To(BeTrue())
+		Expect(conditions.GetReason(capiCluster, turtlesv1.RancherNamespaceCondition)).To(Equal(turtlesv1.RancherNamespaceMissingReason))
+		Expect(recorder.Events).To(Receive(ContainSubstring("Rancher cluster namespace test-ns does not exist")))
+
+		err = r.RancherClient.Get(ctx, client.ObjectKeyFromObject(rancherCluster), &provisioningv1.Cluster{})
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("should create the missing namespace when allowed", func() {
+		r.CreateRancherNamespace = true
+
+		res, err := r.reconcileNormal(ctx, capiCluster, rancherCluster)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res.RequeueAfter).To(Equal(minRequeueDuration))
+
+		Expect(r.RancherClient.Get(ctx, client.ObjectKey{Name: "test-ns"}, &corev1.Namespace{})).To(Succeed())
+		Expect(r.RancherClient.Get(ctx, client.ObjectKeyFromObject(rancherCluster), &provisioningv1.Cluster{})).To(Succeed())
+		Expect(conditions.Has(capiCluster, turtlesv1.RancherNamespaceCondition)).To(BeFalse())
+		Expect(recorder.Events).To(BeEmpty())
+	})
+
+	It("should clear the condition once the namespace exists", func() {
+		_, err := r.reconcileNormal(ctx, capiCluster, rancherCluster)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(conditions.IsFalse(capiCluster, turtlesv1.RancherNamespaceCondition)).To(BeTrue())
+
+		Expect(r.RancherClient.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test-ns"}})).To(Succeed())
+
+		_, err = r.reconcileNormal(ctx, capiCluster, rancherCluster)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(conditions.IsTrue(capiCluster, turtlesv1.RancherNamespaceCondition)).To(BeTrue())
+	})
+})
+
+var _ = Describe("namespace import skip events", func() {
+	var (
+		r         *CAPIImportReconciler
+		recorder  *record.FakeRecorder
+		fakeClock *clocktesting.FakeClock
+		ns        *corev1.Namespace
+	)
+
+	BeforeEach(func() {
+		ns = &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test-ns"}}
+		recorder = record.NewFakeRecorder(10)
+		fakeClock = clocktesting.NewFakeClock(time.Date(2024, 1, 3, 12, 0, 0, 0, time.UTC))
+
+		r = &CAPIImportReconciler{
+			Client:        fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(ns).Build(),
+			RancherClient: fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(ns.DeepCopy()).Build(),
+			recorder:      recorder,
+			clock:         fakeClock,
+			TimingOptions: TimingOptions{
+				NamespaceEventInterval: 10 * time.Minute,
+			},
+		}
+	})
+
+	reconcile := func(name string, labels map[string]string) {
+		capiCluster := &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ns.Name, Labels: labels}}
+		rancherCluster := &provisioningv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: name + "-capi", Namespace: ns.Name}}
+
+		_, err := r.reconcileNormal(ctx, capiCluster, rancherCluster)
+		Expect(err).ToNot(HaveOccurred())
+	}
+
+	It("should emit a rate limited event on an unlabeled namespace", func() {
+		reconcile("cluster-a", nil)
+		Expect(recorder.Events).To(Receive(And(
+			ContainSubstring(turtlesv1.ImportSkippedReason),
+			ContainSubstring("Cluster cluster-a is not imported"),
+		)))
+
+		reconcile("cluster-b", nil)
+		reconcile("cluster-a", nil)
+		Expect(recorder.Events).To(BeEmpty())
+
+		fakeClock.Step(10 * time.Minute)
+
+		reconcile("cluster-b", nil)
+		Expect(recorder.Events).To(Receive(ContainSubstring("Cluster cluster-b is not imported")))
+	})
+
+	It("should not emit an event when the cluster opted out of the import", func() {
+		reconcile("cluster-a", map[string]string{importLabelName: "false"})
+		Expect(recorder.Events).To(BeEmpty())
+	})
+
+	It("should not emit an event when disabled", func() {
+		r.NamespaceEventInterval = 0
+
+		reconcile("cluster-a", nil)
+		Expect(recorder.Events).To(BeEmpty())
+	})
+})
+
+var _ = Describe("additional manifest", func() {
+	const (
+		additionalNetworkPolicy = `apiVersion: networking.k8s.io/v1
+kind: NetworkPolicy
+metadata:
+  name: default-deny
+  namespace: default
+spec:
+  podSelector: {}
+`
+		additionalConfigMap = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: monitoring-agent
+  namespace: default
+data:
+  endpoint: https://metrics.example.com
+`
+	)
+
+	var (
+		r              *CAPIImportReconciler
+		server         *testutil.ManifestServer
+		created        []string
+		capiCluster    *clusterv1.Cluster
+		rancherCluster *provisioningv1.Cluster
+		remoteClient   client.Client
+	)
+
+	BeforeEach(func() {
+		created = []string{}
+		server = testutil.NewManifestServer(manifestWithServerFields)
+
+		capiCluster = &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", Namespace: "test-ns"}}
+		rancherCluster = &provisioningv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test-cluster-capi", Namespace: "test-ns"}}
+
+		remoteClient = fake.NewClientBuilder().WithScheme(scheme.Scheme).WithInterceptorFuncs(interceptor.Funcs{
+			Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+				created = append(created, obj.GetObjectKind().GroupVersionKind().Kind+"/"+obj.GetName())
+				return c.Create(ctx, obj, opts...)
+			},
+		}).Build()
+
+		r = &CAPIImportReconciler{
+			Client: fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(&corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "additional-manifest", Namespace: "rancher-turtles-system"},
+				Data:       map[string]string{"monitoring.yaml": additionalConfigMap},
+			}).Build(),
+			RancherClient: testutil.NewRancherClientBuilder().
+				WithCluster(rancherCluster.Name, rancherCluster.Namespace, testutil.ClusterStateNameSet, server.URL).Build(),
+			remoteClientGetter: func(_ context.Context, _ string, _ client.Client, _ client.ObjectKey) (client.Client, error) {
+				return remoteClient, nil
+			},
+			ApplyOptions: ApplyOptions{
+				AdditionalManifest:          additionalNetworkPolicy,
+				AdditionalManifestConfigMap: client.ObjectKey{Name: "additional-manifest", Namespace: "rancher-turtles-system"},
+			},
+		}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("should apply the additional objects after the registration manifest", func() {
+		_, err := r.reconcileNormal(ctx, capiCluster, rancherCluster)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(created).To(Equal([]string{
+			"Namespace/cattle-system",
+			"ServiceAccount/cattle",
+			"NetworkPolicy/default-deny",
+			"ConfigMap/monitoring-agent",
+		}))
+
+		cm := &corev1.ConfigMap{}
+		Expect(remoteClient.Get(ctx, client.ObjectKey{Name: "monitoring-agent", Namespace: "default"}, cm)).To(Succeed())
+		Expect(cm.Labels).To(HaveKeyWithValue(turtlesAppliedLabelName, "true"))
+
+		ns := &corev1.Namespace{}
+		Expect(remoteClient.Get(ctx, client.ObjectKey{Name: "cattle-system"}, ns)).To(Succeed())
+		Expect(ns.Labels).ToNot(HaveKey(turtlesAppliedLabelName))
+	})
+
+	It("should fail when the additional manifest config map is missing", func() {
+		r.AdditionalManifestConfigMap.Name = "missing"
+
+		_, err := r.reconcileNormal(ctx, capiCluster, rancherCluster)
+		Expect(err).To(MatchError(ContainSubstring("getting additional manifest config map")))
+	})
+})
+
+var _ = Describe("imported by version", func() {
+	var (
+		capiCluster    *clusterv1.Cluster
+		rancherCluster *provisioningv1.Cluster
+	)
+
+	BeforeEach(func() {
+		capiCluster = &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-cluster",
+			Namespace: "test-ns",
+			Labels:    map[string]string{importLabelName: "true"},
+		}}
+		rancherCluster = &provisioningv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test-cluster-capi", Namespace: "test-ns"}}
+	})
+
+	It("should set the version annotation when creating the Rancher cluster", func() {
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test-ns"}}
+		r := &CAPIImportReconciler{
+			Client:        fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(ns, capiCluster).Build(),
+			RancherClient: fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(ns.DeepCopy()).Build(),
+			Version:       "v0.6.0",
+		}
+
+		_, err := r.reconcileNormal(ctx, capiCluster, rancherCluster)
+		Expect(err).ToNot(HaveOccurred())
+
+		created := &provisioningv1.Cluster{}
+		Expect(r.RancherClient.Get(ctx, client.ObjectKeyFromObject(rancherCluster), created)).To(Succeed())
+		Expect(created.Annotations).To(HaveKeyWithValue(turtlesannotations.ImportedByVersionAnnotation, "v0.6.0"))
+		Expect(capiCluster.Annotations).To(HaveKeyWithValue(turtlesannotations.ImportedByVersionAnnotation, "v0.6.0"))
+	})
+
+	It("should update the version annotation when a newer version re-imports the cluster", func() {
+		server := testutil.NewManifestServer(manifestWithServerFields)
+		defer server.Close()
+
+		existing := testutil.RancherCluster(rancherCluster.Name, rancherCluster.Namespace, testutil.ClusterStateNameSet)
+		existing.Annotations = map[string]string{turtlesannotations.ImportedByVersionAnnotation: "v0.5.0"}
+
+		r := &CAPIImportReconciler{
+			RancherClient: testutil.NewRancherClientBuilder().WithObjects(
+				existing,
+				testutil.RegistrationToken(existing.Status.ClusterName, existing.Namespace, server.URL),
+			).Build(),
+			Version: "v0.6.0",
+			remoteClientGetter: func(_ context.Context, _ string, _ client.Client, _ client.ObjectKey) (client.Client, error) {
+				return fake.NewClientBuilder().WithScheme(scheme.Scheme).Build(), nil
+			},
+		}
+
+		_, err := r.reconcileNormal(ctx, capiCluster, rancherCluster)
+		Expect(err).ToNot(HaveOccurred())
+
+		updated := &provisioningv1.Cluster{}
+		Expect(r.RancherClient.Get(ctx, client.ObjectKeyFromObject(rancherCluster), updated)).To(Succeed())
+		Expect(updated.Annotations).To(HaveKeyWithValue(turtlesannotations.ImportedByVersionAnnotation, "v0.6.0"))
+		Expect(capiCluster.Annotations).To(HaveKeyWithValue(turtlesannotations.ImportedByVersionAnnotation, "v0.6.0"))
+	})
+
+	DescribeTable("should only replace a missing or older recorded version",
+		func(current, recorded, expected string) {
+			r := &CAPIImportReconciler{Version: current}
+
+			annotations := map[string]string{}
+			if recorded != "" {
+				annotations[turtlesannotations.ImportedByVersionAnnotation] = recorded
+			}
+
+			annotations = r.importedByVersion(annotations)
+			if expected == "" {
+				Expect(annotations).ToNot(HaveKey(turtlesannotations.ImportedByVersionAnnotation))
+				return
+			}
+
+			Expect(annotations).To(HaveKeyWithValue(turtlesannotations.ImportedByVersionAnnotation, expected))
+		},
+		Entry("missing", "v0.6.0", "", "v0.6.0"),
+		Entry("older", "v0.6.0", "v0.5.2", "v0.6.0"),
+		Entry("same", "v0.6.0", "v0.6.0", "v0.6.0"),
+		Entry("newer", "v0.5.0", "v0.6.0", "v0.6.0"),
+		Entry("recorded placeholder", "v0.6.0", unstampedVersion, "v0.6.0"),
+		Entry("recorded invalid", "v0.6.0", "dev", "v0.6.0"),
+		Entry("current invalid", "dev", "v0.6.0", "v0.6.0"),
+		Entry("current placeholder", unstampedVersion, "", ""),
+		Entry("current placeholder with recorded", unstampedVersion, "v0.5.0", "v0.5.0"),
+		Entry("unknown", "", "", ""),
+	)
+
+	It("should not downgrade the version recorded on the Rancher cluster", func() {
+		existing := testutil.RancherCluster(rancherCluster.Name, rancherCluster.Namespace, testutil.ClusterStateNameSet)
+		existing.Annotations = map[string]string{turtlesannotations.ImportedByVersionAnnotation: "v0.7.0"}
+
+		r := &CAPIImportReconciler{
+			RancherClient: testutil.NewRancherClientBuilder().WithObjects(existing).Build(),
+			Version:       unstampedVersion,
+		}
+
+		Expect(r.updateImportedByVersion(ctx, capiCluster, existing)).To(Succeed())
+		Expect(existing.Annotations).To(HaveKeyWithValue(turtlesannotations.ImportedByVersionAnnotation, "v0.7.0"))
+		Expect(capiCluster.Annotations).ToNot(HaveKey(turtlesannotations.ImportedByVersionAnnotation))
+
+		r.Version = "v0.6.0"
+
+		Expect(r.updateImportedByVersion(ctx, capiCluster, existing)).To(Succeed())
+		Expect(existing.Annotations).To(HaveKeyWithValue(turtlesannotations.ImportedByVersionAnnotation, "v0.7.0"))
+		Expect(capiCluster.Annotations).To(HaveKeyWithValue(turtlesannotations.ImportedByVersionAnnotation, "v0.6.0"))
+	})
+})
+
+var _ = Describe("provisioning API version", func() {
+	var (
+		r           *CAPIImportReconciler
+		capiCluster *clusterv1.Cluster
+		ns          *corev1.Namespace
+		gvk         schema.GroupVersionKind
+		rancherCl   *fake.ClientBuilder
+	)
+
+	BeforeEach(func() {
+		gvk = schema.GroupVersionKind{Group: "provisioning.example.io", Version: "v2", Kind: "Cluster"}
+
+		customScheme := runtime.NewScheme()
+		Expect(scheme.AddToScheme(customScheme)).To(Succeed())
+		Expect(provisioningv1.AddToSchemeWithGroupVersion(gvk.GroupVersion())(customScheme)).To(Succeed())
+
+		capiCluster = &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-cluster",
+			Namespace: "test-ns",
+			Labels:    map[string]string{importLabelName: "true"},
+		}}
+		ns = &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test-ns"}}
+		rancherCl = fake.NewClientBuilder().WithScheme(customScheme).WithObjects(ns.DeepCopy())
+
+		r = &CAPIImportReconciler{
+			Client: fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(capiCluster, ns).Build(),
+		}
+	})
+
+	It("should create the Rancher cluster with the configured group version", func() {
+		r.RancherClient = rancherCl.Build()
+
+		res, err := r.reconcile(ctx, capiCluster)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res.RequeueAfter).To(Equal(minRequeueDuration))
+
+		rancherCluster := &unstructured.Unstructured{}
+		rancherCluster.SetGroupVersionKind(gvk)
+		Expect(r.RancherClient.Get(ctx, client.ObjectKey{Namespace: "test-ns", Name: "test-cluster-capi"}, rancherCluster)).To(Succeed())
+	})
+
+	It("should fetch the Rancher cluster with the configured group version", func() {
+		fetched := []schema.GroupVersionKind{}
+
+		r.RancherClient = rancherCl.WithInterceptorFuncs(interceptor.Funcs{
+			Get: func(ctx context.Context, cl client.WithWatch, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+				if _, ok := obj.(*provisioningv1.Cluster); ok {
+					objGVK, err := apiutil.GVKForObject(obj, cl.Scheme())
+					Expect(err).ToNot(HaveOccurred())
+
+					fetched = append(fetched, objGVK)
+				}
+
+				return cl.Get(ctx, key, obj, opts...)
+			},
+		}).Build()
+
+		_, err := r.reconcile(ctx, capiCluster)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(fetched).ToNot(BeEmpty())
+		Expect(fetched).To(HaveEach(gvk))
+	})
+})
+
+var _ = Describe("manifest change events", func() {
+	var (
+		r              *CAPIImportReconciler
+		recorder       *record.FakeRecorder
+		server         *testutil.ManifestServer
+		capiCluster    *clusterv1.Cluster
+		rancherCluster *provisioningv1.Cluster
+	)
+
+	BeforeEach(func() {
+		server = testutil.NewManifestServer(manifestWithServerFields)
+		DeferCleanup(server.Close)
+
+		capiCluster = &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-cluster",
+			Namespace: "test-ns",
+			Labels:    map[string]string{importLabelName: "true"},
+		}}
+		rancherCluster = &provisioningv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test-cluster-capi", Namespace: "test-ns"}}
+		recorder = record.NewFakeRecorder(10)
+
+		r = &CAPIImportReconciler{
+			RancherClient: testutil.NewRancherClientBuilder().
+				WithCluster(rancherCluster.Name, rancherCluster.Namespace, testutil.ClusterStateNameSet, server.URL).
+				Build(),
+			recorder: recorder,
+			remoteClientGetter: func(_ context.Context, _ string, _ client.Client, _ client.ObjectKey) (client.Client, error) {
+				return fake.NewClientBuilder().WithScheme(scheme.Scheme).Build(), nil
+			},
+		}
+	})
+
+	It("should record the manifest hash without an event on the first apply", func() {
+		_, err := r.reconcileNormal(ctx, capiCluster, rancherCluster)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(capiCluster.Annotations).To(HaveKeyWithValue(turtlesannotations.ManifestHashAnnotation, manifestHash(manifestWithServerFields)))
+		Expect(recorder.Events).To(BeEmpty())
+	})
+
+	It("should not emit an event when the manifest is unchanged", func() {
+		capiCluster.Annotations = map[string]string{turtlesannotations.ManifestHashAnnotation: manifestHash(manifestWithServerFields)}
+
+		_, err := r.reconcileNormal(ctx, capiCluster, rancherCluster)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(recorder.Events).To(BeEmpty())
+	})
+
+	It("should emit an event with the old and new hashes when the manifest changed", func() {
+		previous := manifestHash("previous manifest")
+		current := manifestHash(manifestWithServerFields)
+		capiCluster.Annotations = map[string]string{turtlesannotations.ManifestHashAnnotation: previous}
+
+		_, err := r.reconcileNormal(ctx, capiCluster, rancherCluster)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(capiCluster.Annotations).To(HaveKeyWithValue(turtlesannotations.ManifestHashAnnotation, current))
+		Expect(recorder.Events).To(Receive(SatisfyAll(
+			ContainSubstring(turtlesv1.ManifestChangedReason),
+			ContainSubstring(previous[:shortHashLength]),
+			ContainSubstring(current[:shortHashLength]),
+		)))
+		Expect(recorder.Events).To(BeEmpty())
+	})
+})
+
+var _ = Describe("manifest checksum verification", func() {
+	var (
+		r              *CAPIImportReconciler
+		recorder       *record.FakeRecorder
+		remoteClient   client.Client
+		capiCluster    *clusterv1.Cluster
+		rancherCluster *provisioningv1.Cluster
+	)
+
+	BeforeEach(func() {
+		server := testutil.NewManifestServer(manifestWithServerFields)
+		DeferCleanup(server.Close)
+
+		capiCluster = &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-cluster",
+			Namespace: "test-ns",
+			Labels:    map[string]string{importLabelName: "true"},
+		}}
+		rancherCluster = &provisioningv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test-cluster-capi", Namespace: "test-ns"}}
+		recorder = record.NewFakeRecorder(10)
+		remoteClient = fake.NewClientBuilder().WithScheme(scheme.Scheme).Build()
+
+		r = &CAPIImportReconciler{
+			RancherClient: testutil.NewRancherClientBuilder().
+				WithCluster(rancherCluster.Name, rancherCluster.Namespace, testutil.ClusterStateNameSet, server.URL).
+				Build(),
+			recorder: recorder,
+			remoteClientGetter: func(_ context.Context, _ string, _ client.Client, _ client.ObjectKey) (client.Client, error) {
+				return remoteClient, nil
+			},
+		}
+	})
+
+	It("should apply a manifest matching the expected checksum", func() {
+		capiCluster.Annotations = map[string]string{
+			turtlesannotations.ManifestChecksumAnnotation: "sha256:" + manifestHash(manifestWithServerFields),
+		}
+
+		_, err := r.reconcileNormal(ctx, capiCluster, rancherCluster)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(conditions.IsTrue(capiCluster, turtlesv1.ManifestVerifiedCondition)).To(BeTrue())
+		Expect(remoteClient.Get(ctx, client.ObjectKey{Name: "cattle-system"}, &corev1.Namespace{})).To(Succeed())
+	})
+
+	It("should not apply a manifest which doesn't match the expected checksum", func() {
+		capiCluster.Annotations = map[string]string{
+			turtlesannotations.ManifestChecksumAnnotation: "sha256:" + manifestHash("expected manifest"),
+		}
+
+		_, err := r.reconcileNormal(ctx, capiCluster, rancherCluster)
+		Expect(err).To(MatchError(errManifestVerification))
+
+		Expect(conditions.IsFalse(capiCluster, turtlesv1.ManifestVerifiedCondition)).To(BeTrue())
+		Expect(conditions.GetReason(capiCluster, turtlesv1.ManifestVerifiedCondition)).To(Equal(turtlesv1.ManifestVerificationFailedReason))
+		Expect(conditions.GetSeverity(capiCluster, turtlesv1.ManifestVerifiedCondition)).To(Equal(ptr.To(clusterv1.ConditionSeverityError)))
+		Expect(conditions.GetMessage(capiCluster, turtlesv1.ManifestVerifiedCondition)).To(
+			ContainSubstring("got sha256:" + manifestHash(manifestWithServerFields)))
+		Expect(recorder.Events).To(Receive(ContainSubstring(turtlesv1.ManifestVerificationFailedReason)))
+		Expect(apierrors.IsNotFound(remoteClient.Get(ctx, client.ObjectKey{Name: "cattle-system"}, &corev1.Namespace{}))).To(BeTrue())
+	})
+
+	It("should not verify manifests without an expected checksum", func() {
+		_, err := r.reconcileNormal(ctx, capiCluster, rancherCluster)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(conditions.Get(capiCluster, turtlesv1.ManifestVerifiedCondition)).To(BeNil())
+	})
+})
@@ -0,0 +1,295 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	turtlesv1 "github.com/rancher/turtles/api/v1alpha1"
+	managementv3 "github.com/rancher/turtles/internal/rancher/management/v3"
+	provisioningv1 "github.com/rancher/turtles/internal/rancher/provisioning/v1"
+	turtlesannotations "github.com/rancher/turtles/util/annotations"
+)
+
+// rancherClusterNamespace returns the namespace of the Rancher cluster of the CAPI cluster. The namespace set on the
+// CAPI cluster with the rancher-cluster-namespace annotation takes precedence over the RancherClusterNamespace option.
+func (r *CAPIImportReconciler) rancherClusterNamespace(capiCluster *clusterv1.Cluster) string {
+	if namespace := capiCluster.GetAnnotations()[turtlesannotations.RancherClusterNamespaceAnnotation]; namespace != "" {
+		return namespace
+	}
+
+	if r.RancherClusterNamespace != "" {
+		return r.RancherClusterNamespace
+	}
+
+	return capiCluster.Namespace
+}
+
+// linkRancherCluster makes the CAPI cluster the owner of the new Rancher cluster. Without ownerReference, e.g. as
+// owner references can't cross namespaces, the Rancher cluster is linked with the name, namespace and UID labels of
+// the CAPI cluster instead. The CAPI cluster gets a finalizer to delete the Rancher cluster and its registration token.
+func linkRancherCluster(capiCluster *clusterv1.Cluster, rancherCluster *provisioningv1.Cluster, ownerReference bool) {
+	controllerutil.AddFinalizer(capiCluster, managementv3.CapiClusterFinalizer)
+
+	if ownerReference {
+		rancherCluster.OwnerReferences = []metav1.OwnerReference{{
+			APIVersion: clusterv1.GroupVersion.String(),
+			Kind:       clusterv1.ClusterKind,
+			Name:       capiCluster.Name,
+			UID:        capiCluster.UID,
+		}}
+
+		return
+	}
+
+	labels := rancherCluster.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+
+	labels[capiClusterOwner] = capiCluster.Name
+	labels[capiClusterOwnerNamespace] = capiCluster.Namespace
+	labels[capiClusterOwnerUID] = string(capiCluster.UID)
+	rancherCluster.SetLabels(labels)
+}
+
+// ownerReferenceLink returns true when the Rancher cluster is linked to the CAPI cluster with an owner reference, which
+// requires both in the same namespace of the same cluster.
+func (r *CAPIImportReconciler) ownerReferenceLink(capiCluster *clusterv1.Cluster, rancherCluster *provisioningv1.Cluster) bool {
+	return !r.RemoteRancher && rancherCluster.Namespace == capiCluster.Namespace
+}
+
+// checkRancherClusterOwner verifies that the Rancher cluster is linked to the CAPI cluster. When the CAPI cluster was
+// recreated with the same name, the Rancher cluster is still linked to the UID of the previous one, and is relinked
+// to the new UID before the garbage collector, or the controller for label links, deletes it. A Rancher cluster linked
+// to another CAPI cluster in another namespace is an error. The CAPI cluster gets the finalizer deleting its Rancher
+// cluster.
+func (r *CAPIImportReconciler) checkRancherClusterOwner(ctx context.Context, capiCluster *clusterv1.Cluster,
+	rancherCluster *provisioningv1.Cluster,
+) error {
+	if r.ownerReferenceLink(capiCluster, rancherCluster) {
+		if err := r.relinkOwnerReference(ctx, capiCluster, rancherCluster); err != nil {
+			return err
+		}
+
+		controllerutil.AddFinalizer(capiCluster, managementv3.CapiClusterFinalizer)
+
+		return nil
+	}
+
+	labels := rancherCluster.GetLabels()
+	if labels[capiClusterOwner] != capiCluster.Name || labels[capiClusterOwnerNamespace] != capiCluster.Namespace {
+		return fmt.Errorf("rancher cluster %s is not linked to CAPI cluster %s",
+			client.ObjectKeyFromObject(rancherCluster), client.ObjectKeyFromObject(capiCluster))
+	}
+
+	if previousUID := labels[capiClusterOwnerUID]; previousUID != string(capiCluster.UID) {
+		patchBase := client.MergeFrom(rancherCluster.DeepCopy())
+
+		labels[capiClusterOwnerUID] = string(capiCluster.UID)
+		rancherCluster.SetLabels(labels)
+
+		if err := r.RancherClient.Patch(ctx, rancherCluster, patchBase); err != nil {
+			return fmt.Errorf("relinking rancher cluster: %w", err)
+		}
+
+		r.recordRelink(ctx, capiCluster, rancherCluster, previousUID)
+	}
+
+	controllerutil.AddFinalizer(capiCluster, managementv3.CapiClusterFinalizer)
+
+	return nil
+}
+
+// relinkOwnerReference points the owner reference of the Rancher cluster on a previous CAPI cluster with the same
+// name to the CAPI cluster. Only the Rancher clusters created by the controller for a CAPI cluster with that name are
+// relinked.
+func (r *CAPIImportReconciler) relinkOwnerReference(ctx context.Context, capiCluster *clusterv1.Cluster,
+	rancherCluster *provisioningv1.Cluster,
+) error {
+	if _, owned := rancherCluster.GetLabels()[r.ownedLabel()]; !owned || capiClusterName(rancherCluster) != capiCluster.Name {
+		return nil
+	}
+
+	for i, ref := range rancherCluster.OwnerReferences {
+		gv, err := schema.ParseGroupVersion(ref.APIVersion)
+		if err != nil || gv.Group != clusterv1.GroupVersion.Group || ref.Kind != clusterv1.ClusterKind ||
+			ref.Name != capiCluster.Name || ref.UID == capiCluster.UID {
+			continue
+		}
+
+		patchBase := client.MergeFrom(rancherCluster.DeepCopy())
+		rancherCluster.OwnerReferences[i].UID = capiCluster.UID
+
+		if err := r.RancherClient.Patch(ctx, rancherCluster, patchBase); err != nil {
+			return fmt.Errorf("relinking rancher cluster: %w", err)
+		}
+
+		r.recordRelink(ctx, capiCluster, rancherCluster, string(ref.UID))
+	}
+
+	return nil
+}
+
+// recordRelink logs and records an event for a Rancher cluster relinked from a previous CAPI cluster. The registration
+// reported by the Rancher cluster is the one of the previous CAPI cluster, so the agent of the new one is reported as
+// not registered until the import manifest is applied to it.
+func (r *CAPIImportReconciler) recordRelink(ctx context.Context, capiCluster *clusterv1.Cluster,
+	rancherCluster *provisioningv1.Cluster, previousUID string,
+) {
+	conditions.MarkFalse(capiCluster, turtlesv1.RancherAgentRegisteredCondition, turtlesv1.RancherClusterRelinkedReason,
+		clusterv1.ConditionSeverityInfo, "Rancher cluster %s was linked to a previous cluster with the same name, "+
+			"waiting for the import manifest to be applied", client.ObjectKeyFromObject(rancherCluster))
+
+	log.FromContext(ctx).Info("relinked Rancher cluster of a recreated CAPI cluster",
+		"rancherCluster", client.ObjectKeyFromObject(rancherCluster), "previousUID", previousUID, "uid", capiCluster.UID)
+	r.recorder.Eventf(capiCluster, corev1.EventTypeNormal, turtlesv1.RancherClusterRelinkedReason,
+		"Rancher cluster %s was linked to a previous cluster with the same name (uid %s) and is now linked to this one",
+		client.ObjectKeyFromObject(rancherCluster), previousUID)
+}
+
+// deleteLinkedRancherCluster deletes the Rancher cluster linked to the CAPI cluster being deleted, along with its
+// registration token, and releases the CAPI cluster by removing its finalizer. The Rancher cluster is nil when it
+// doesn't exist or couldn't be resolved, in which case only the Rancher clusters linked with labels are deleted.
+func (r *CAPIImportReconciler) deleteLinkedRancherCluster(ctx context.Context, capiCluster *clusterv1.Cluster,
+	rancherCluster *provisioningv1.Cluster,
+) error {
+	if !controllerutil.ContainsFinalizer(capiCluster, managementv3.CapiClusterFinalizer) {
+		return nil
+	}
+
+	log.FromContext(ctx).Info("capi cluster is being deleted, deleting linked rancher cluster")
+
+	if rancherCluster != nil && rancherClusterLinked(capiCluster, rancherCluster) {
+		if err := r.RancherClient.Delete(ctx, rancherCluster); client.IgnoreNotFound(err) != nil {
+			return fmt.Errorf("error deleting rancher cluster: %w", err)
+		}
+
+		if err := r.deleteRegistrationToken(ctx, rancherCluster); err != nil {
+			return err
+		}
+	}
+
+	if err := r.RancherClient.DeleteAllOf(ctx, &provisioningv1.Cluster{},
+		client.InNamespace(r.rancherClusterNamespace(capiCluster)),
+		client.MatchingLabels{
+			capiClusterOwner:          capiCluster.Name,
+			capiClusterOwnerNamespace: capiCluster.Namespace,
+			capiClusterOwnerUID:       string(capiCluster.UID),
+			r.ownedLabel():            "",
+		},
+	); err != nil {
+		return fmt.Errorf("error deleting linked rancher cluster: %w", err)
+	}
+
+	r.remoteClients.evict(client.ObjectKeyFromObject(capiCluster))
+	controllerutil.RemoveFinalizer(capiCluster, managementv3.CapiClusterFinalizer)
+
+	return nil
+}
+
+// linkedCapiCluster returns the key of the CAPI cluster the Rancher cluster belongs to, from its owner labels when it
+// is linked across namespaces.
+func linkedCapiCluster(rancherCluster client.Object) client.ObjectKey {
+	labels := rancherCluster.GetLabels()
+	if name, namespace := labels[capiClusterOwner], labels[capiClusterOwnerNamespace]; name != "" && namespace != "" {
+		return client.ObjectKey{Namespace: namespace, Name: name}
+	}
+
+	return client.ObjectKey{Namespace: rancherCluster.GetNamespace(), Name: capiClusterName(rancherCluster)}
+}
+
+// rancherClusterLinked returns true if the Rancher cluster is linked to the CAPI cluster, either with an owner
+// reference or with the owner labels.
+func rancherClusterLinked(capiCluster *clusterv1.Cluster, rancherCluster *provisioningv1.Cluster) bool {
+	for _, ref := range rancherCluster.OwnerReferences {
+		if ref.Kind == clusterv1.ClusterKind && ref.UID == capiCluster.UID {
+			return true
+		}
+	}
+
+	labels := rancherCluster.GetLabels()
+
+	return labels[capiClusterOwner] == capiCluster.Name && labels[capiClusterOwnerNamespace] == capiCluster.Namespace &&
+		labels[capiClusterOwnerUID] == string(capiCluster.UID)
+}
+
+// deleteRegistrationToken deletes the registration token of the Rancher cluster. A missing token is not an error.
+func (r *CAPIImportReconciler) deleteRegistrationToken(ctx context.Context, rancherCluster *provisioningv1.Cluster) error {
+	if r.rancherClusterStatus(rancherCluster).ClusterName == "" {
+		return nil
+	}
+
+	token := &managementv3.ClusterRegistrationToken{ObjectMeta: metav1.ObjectMeta{
+		Name:      r.rancherClusterStatus(rancherCluster).ClusterName,
+		Namespace: rancherCluster.Namespace,
+	}}
+
+	if err := r.RancherClient.Delete(ctx, token); client.IgnoreNotFound(err) != nil {
+		return fmt.Errorf("error deleting registration token %s: %w", client.ObjectKeyFromObject(token), err)
+	}
+
+	return nil
+}
+
+// reconcileDeleting cleans up after a CAPI cluster being deleted: the deletion protection of its Rancher cluster is
+// released and the linked Rancher cluster is deleted along with its registration token. A missing Rancher cluster is
+// not an error. Rancher clusters linked with labels are also deleted by their owner labels, so the cleanup proceeds
+// even when the name of the Rancher cluster can't be resolved anymore.
+func (r *CAPIImportReconciler) reconcileDeleting(ctx context.Context, capiCluster *clusterv1.Cluster) error {
+	log := log.FromContext(ctx)
+
+	rancherClusterKey, err := r.rancherClusterKey(capiCluster)
+	if err != nil {
+		log.Error(err, "unable to resolve the rancher cluster name, skipping the deletion protection release")
+		return r.deleteLinkedRancherCluster(ctx, capiCluster, nil)
+	}
+
+	rancherCluster := &provisioningv1.Cluster{ObjectMeta: metav1.ObjectMeta{
+		Namespace: rancherClusterKey.Namespace,
+		Name:      rancherClusterKey.Name,
+	}}
+
+	ctx = ctrl.LoggerInto(ctx, log.WithValues("rancherCluster", client.ObjectKeyFromObject(rancherCluster).String()))
+
+	err = r.getRancherCluster(ctx, rancherCluster)
+	if client.IgnoreNotFound(err) != nil {
+		return fmt.Errorf("getting rancher cluster %s: %w", client.ObjectKeyFromObject(rancherCluster), err)
+	}
+
+	if apierrors.IsNotFound(err) {
+		return r.deleteLinkedRancherCluster(ctx, capiCluster, nil)
+	}
+
+	if err := r.releaseDeletionProtection(ctx, rancherCluster); err != nil {
+		return err
+	}
+
+	return r.deleteLinkedRancherCluster(ctx, capiCluster, rancherCluster)
+}
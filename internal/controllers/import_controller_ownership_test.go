@@ -0,0 +1,601 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/go-logr/logr"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/cluster-api/util/predicates"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	turtlesv1 "github.com/rancher/turtles/api/v1alpha1"
+	managementv3 "github.com/rancher/turtles/internal/rancher/management/v3"
+	provisioningv1 "github.com/rancher/turtles/internal/rancher/provisioning/v1"
+	"github.com/rancher/turtles/testutil"
+	turtlesannotations "github.com/rancher/turtles/util/annotations"
+)
+
+var _ = Describe("Rancher cluster ownership", func() {
+	var (
+		r           *CAPIImportReconciler
+		recorder    *record.FakeRecorder
+		capiCluster *clusterv1.Cluster
+		builder     *testutil.RancherClientBuilder
+	)
+
+	capiClusterKey := client.ObjectKey{Namespace: "test-ns", Name: "test-cluster"}
+
+	reconcileCluster := func() (reconcile.Result, error) {
+		return r.Reconcile(ctx, reconcile.Request{NamespacedName: capiClusterKey})
+	}
+
+	deleteCapiCluster := func() {
+		Expect(r.Client.Get(ctx, capiClusterKey, capiCluster)).To(Succeed())
+		Expect(r.Client.Delete(ctx, capiCluster)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test-ns"}}
+		capiCluster = &clusterv1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      capiClusterKey.Name,
+				Namespace: capiClusterKey.Namespace,
+				UID:       "capi-uid",
+				Labels:    map[string]string{importLabelName: "true"},
+			},
+			Status: clusterv1.ClusterStatus{ControlPlaneReady: true},
+		}
+		builder = testutil.NewRancherClientBuilder().WithObjects(
+			ns.DeepCopy(),
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "fleet-default"}},
+		)
+
+		recorder = record.NewFakeRecorder(10)
+
+		r = &CAPIImportReconciler{
+			Client: fake.NewClientBuilder().WithScheme(scheme.Scheme).
+				WithObjects(ns, capiCluster.DeepCopy()).WithStatusSubresource(&clusterv1.Cluster{}).Build(),
+			recorder: recorder,
+		}
+	})
+
+	Context("in the namespace of the CAPI cluster", func() {
+		rancherClusterKey := client.ObjectKey{Namespace: "test-ns", Name: "test-cluster-capi"}
+
+		It("should own the Rancher cluster with an owner reference and delete it along with the CAPI cluster", func() {
+			r.RancherClient = builder.Build()
+
+			_, err := reconcileCluster()
+			Expect(err).ToNot(HaveOccurred())
+
+			rancherCluster := &provisioningv1.Cluster{}
+			Expect(r.RancherClient.Get(ctx, rancherClusterKey, rancherCluster)).To(Succeed())
+			Expect(rancherCluster.OwnerReferences).To(ConsistOf(HaveField("UID", capiCluster.UID)))
+			Expect(rancherCluster.Labels).ToNot(HaveKey(capiClusterOwner))
+
+			Expect(r.Client.Get(ctx, capiClusterKey, capiCluster)).To(Succeed())
+			Expect(capiCluster.Finalizers).To(ConsistOf(managementv3.CapiClusterFinalizer))
+
+			By("registering the Rancher cluster")
+			rancherCluster.Status.ClusterName = "c-m-lifecycle"
+			Expect(r.RancherClient.Status().Update(ctx, rancherCluster)).To(Succeed())
+			token := testutil.RegistrationToken("c-m-lifecycle", rancherClusterKey.Namespace, "https://rancher.example.com/v3/import/abc.yaml")
+			Expect(r.RancherClient.Create(ctx, token)).To(Succeed())
+
+			deleteCapiCluster()
+
+			_, err = reconcileCluster()
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(apierrors.IsNotFound(r.RancherClient.Get(ctx, rancherClusterKey, &provisioningv1.Cluster{}))).To(BeTrue())
+			Expect(apierrors.IsNotFound(r.RancherClient.Get(ctx, client.ObjectKeyFromObject(token),
+				&managementv3.ClusterRegistrationToken{}))).To(BeTrue())
+			Expect(apierrors.IsNotFound(r.Client.Get(ctx, capiClusterKey, &clusterv1.Cluster{}))).To(BeTrue())
+
+			By("reconciling the deleted cluster again")
+			_, err = reconcileCluster()
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("should delete the Rancher cluster when the import label is removed before the CAPI cluster is deleted", func() {
+			r.RancherClient = builder.Build()
+
+			_, err := reconcileCluster()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(r.RancherClient.Get(ctx, rancherClusterKey, &provisioningv1.Cluster{})).To(Succeed())
+
+			clusterPredicates, err := r.clusterPredicates(ctx, logr.Discard())
+			Expect(err).ToNot(HaveOccurred())
+			capiPredicates := predicates.All(logr.Discard(), clusterPredicates...)
+
+			By("removing the import label")
+			Expect(r.Client.Get(ctx, capiClusterKey, capiCluster)).To(Succeed())
+			unlabeled := capiCluster.DeepCopy()
+			delete(unlabeled.Labels, importLabelName)
+			Expect(r.Client.Update(ctx, unlabeled)).To(Succeed())
+			Expect(capiPredicates.Update(event.UpdateEvent{ObjectOld: capiCluster, ObjectNew: unlabeled})).To(BeFalse())
+
+			deleteCapiCluster()
+
+			deleting := &clusterv1.Cluster{}
+			Expect(r.Client.Get(ctx, capiClusterKey, deleting)).To(Succeed())
+			Expect(deleting.DeletionTimestamp.IsZero()).To(BeFalse())
+			Expect(capiPredicates.Update(event.UpdateEvent{ObjectOld: unlabeled, ObjectNew: deleting})).To(BeTrue())
+			Expect(capiPredicates.Delete(event.DeleteEvent{Object: deleting})).To(BeTrue())
+
+			_, err = reconcileCluster()
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(apierrors.IsNotFound(r.RancherClient.Get(ctx, rancherClusterKey, &provisioningv1.Cluster{}))).To(BeTrue())
+			Expect(apierrors.IsNotFound(r.Client.Get(ctx, capiClusterKey, &clusterv1.Cluster{}))).To(BeTrue())
+		})
+
+		It("should release the CAPI cluster when its Rancher cluster is already deleted", func() {
+			r.RancherClient = builder.Build()
+
+			_, err := reconcileCluster()
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(r.RancherClient.Delete(ctx, &provisioningv1.Cluster{ObjectMeta: metav1.ObjectMeta{
+				Name: rancherClusterKey.Name, Namespace: rancherClusterKey.Namespace,
+			}})).To(Succeed())
+
+			deleteCapiCluster()
+			Expect(capiCluster.Finalizers).To(ContainElement(managementv3.CapiClusterFinalizer))
+
+			_, err = reconcileCluster()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(apierrors.IsNotFound(r.Client.Get(ctx, capiClusterKey, &clusterv1.Cluster{}))).To(BeTrue())
+		})
+
+		It("should not delete a Rancher cluster which is not linked to the CAPI cluster", func() {
+			r.RancherClient = builder.WithCluster(rancherClusterKey.Name, rancherClusterKey.Namespace,
+				testutil.ClusterStateReady, "").Build()
+
+			Expect(r.Client.Get(ctx, capiClusterKey, capiCluster)).To(Succeed())
+			capiCluster.Finalizers = []string{managementv3.CapiClusterFinalizer}
+			Expect(r.Client.Update(ctx, capiCluster)).To(Succeed())
+			deleteCapiCluster()
+
+			_, err := reconcileCluster()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(r.RancherClient.Get(ctx, rancherClusterKey, &provisioningv1.Cluster{})).To(Succeed())
+			Expect(apierrors.IsNotFound(r.Client.Get(ctx, capiClusterKey, &clusterv1.Cluster{}))).To(BeTrue())
+		})
+
+		It("should relink the Rancher cluster when the CAPI cluster is recreated with the same name", func() {
+			r.RancherClient = builder.Build()
+
+			_, err := reconcileCluster()
+			Expect(err).ToNot(HaveOccurred())
+
+			rancherCluster := &provisioningv1.Cluster{}
+			Expect(r.RancherClient.Get(ctx, rancherClusterKey, rancherCluster)).To(Succeed())
+			rancherUID := rancherCluster.UID
+
+			// Recreate the CAPI cluster with the same name, e.g. restored from a backup after it was removed without
+			// running its finalizer. The fake client keeps the UID it is created with.
+			Expect(r.Client.Get(ctx, capiClusterKey, capiCluster)).To(Succeed())
+			capiCluster.Finalizers = nil
+			Expect(r.Client.Update(ctx, capiCluster)).To(Succeed())
+			Expect(r.Client.Delete(ctx, capiCluster)).To(Succeed())
+
+			recreated := &clusterv1.Cluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      capiClusterKey.Name,
+					Namespace: capiClusterKey.Namespace,
+					UID:       "recreated-uid",
+					Labels:    map[string]string{importLabelName: "true"},
+				},
+			}
+			Expect(r.Client.Create(ctx, recreated)).To(Succeed())
+			recreated.Status.ControlPlaneReady = true
+			Expect(r.Client.Status().Update(ctx, recreated)).To(Succeed())
+
+			_, err = reconcileCluster()
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(r.RancherClient.Get(ctx, rancherClusterKey, rancherCluster)).To(Succeed())
+			Expect(rancherCluster.UID).To(Equal(rancherUID))
+			Expect(rancherCluster.OwnerReferences).To(ConsistOf(And(
+				HaveField("Name", "test-cluster"),
+				HaveField("UID", BeEquivalentTo("recreated-uid")),
+			)))
+			Expect(recorder.Events).To(Receive(ContainSubstring(turtlesv1.RancherClusterRelinkedReason)))
+		})
+
+		It("should not relink a Rancher cluster which wasn't created for the CAPI cluster", func() {
+			foreign := testutil.RancherCluster(rancherClusterKey.Name, rancherClusterKey.Namespace, testutil.ClusterStateReady)
+			foreign.OwnerReferences = []metav1.OwnerReference{{
+				APIVersion: clusterv1.GroupVersion.String(),
+				Kind:       clusterv1.ClusterKind,
+				Name:       "test-cluster",
+				UID:        "previous-uid",
+			}}
+			r.RancherClient = builder.WithObjects(foreign).Build()
+
+			_, err := reconcileCluster()
+			Expect(err).ToNot(HaveOccurred())
+
+			rancherCluster := &provisioningv1.Cluster{}
+			Expect(r.RancherClient.Get(ctx, rancherClusterKey, rancherCluster)).To(Succeed())
+			Expect(rancherCluster.OwnerReferences).To(ConsistOf(HaveField("UID", BeEquivalentTo("previous-uid"))))
+			Expect(recorder.Events).ToNot(Receive())
+		})
+
+		Context("with the agent of the previous CAPI cluster deployed", func() {
+			var (
+				server       *testutil.ManifestServer
+				remoteErr    error
+				remoteClient client.Client
+			)
+
+			BeforeEach(func() {
+				server = testutil.NewManifestServer(manifestWithServerFields)
+				DeferCleanup(server.Close)
+
+				stale := testutil.RancherCluster(rancherClusterKey.Name, rancherClusterKey.Namespace, testutil.ClusterStateReady)
+				stale.Labels = map[string]string{ownedLabelName: ""}
+				stale.Annotations = map[string]string{turtlesannotations.CAPIClusterNameAnnotation: "test-cluster"}
+				stale.OwnerReferences = []metav1.OwnerReference{{
+					APIVersion: clusterv1.GroupVersion.String(),
+					Kind:       clusterv1.ClusterKind,
+					Name:       "test-cluster",
+					UID:        "previous-uid",
+				}}
+
+				remoteErr = nil
+				remoteClient = fake.NewClientBuilder().WithScheme(scheme.Scheme).Build()
+
+				r.RancherClient = builder.WithObjects(stale,
+					testutil.RegistrationToken(stale.Status.ClusterName, rancherClusterKey.Namespace, server.URL),
+				).Build()
+				r.remoteClientGetter = func(_ context.Context, _ string, _ client.Client, _ client.ObjectKey) (client.Client, error) {
+					return remoteClient, remoteErr
+				}
+			})
+
+			It("should apply the import manifest to the recreated CAPI cluster", func() {
+				_, err := reconcileCluster()
+				Expect(err).ToNot(HaveOccurred())
+
+				rancherCluster := &provisioningv1.Cluster{}
+				Expect(r.RancherClient.Get(ctx, rancherClusterKey, rancherCluster)).To(Succeed())
+				Expect(rancherCluster.OwnerReferences).To(ConsistOf(HaveField("UID", capiCluster.UID)))
+				Expect(recorder.Events).To(Receive(ContainSubstring(turtlesv1.RancherClusterRelinkedReason)))
+
+				Expect(server.Requests()).To(Equal(1))
+				Expect(remoteClient.Get(ctx, client.ObjectKey{Name: cattleSystemNamespace}, &corev1.Namespace{})).To(Succeed())
+
+				Expect(r.Client.Get(ctx, capiClusterKey, capiCluster)).To(Succeed())
+				Expect(conditions.IsTrue(capiCluster, turtlesv1.ImportManifestAppliedCondition)).To(BeTrue())
+				Expect(conditions.GetReason(capiCluster, turtlesv1.RancherAgentRegisteredCondition)).
+					ToNot(Equal(turtlesv1.RancherClusterRelinkedReason))
+
+				By("verifying the registration of the deployed agent afterwards")
+				_, err = reconcileCluster()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(server.Requests()).To(Equal(1))
+			})
+
+			It("should retry the import manifest until it is applied to the recreated CAPI cluster", func() {
+				remoteErr = errors.New("downstream cluster unreachable")
+
+				_, err := reconcileCluster()
+				Expect(err).To(HaveOccurred())
+
+				Expect(r.Client.Get(ctx, capiClusterKey, capiCluster)).To(Succeed())
+				Expect(conditions.GetReason(capiCluster, turtlesv1.RancherAgentRegisteredCondition)).
+					To(Equal(turtlesv1.RancherClusterRelinkedReason))
+
+				remoteErr = nil
+
+				_, err = reconcileCluster()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(server.Requests()).To(Equal(2))
+
+				Expect(r.Client.Get(ctx, capiClusterKey, capiCluster)).To(Succeed())
+				Expect(conditions.IsTrue(capiCluster, turtlesv1.ImportManifestAppliedCondition)).To(BeTrue())
+			})
+		})
+	})
+
+	Context("in another namespace", func() {
+		rancherClusterKey := client.ObjectKey{Namespace: "fleet-default", Name: "test-cluster-capi"}
+
+		BeforeEach(func() {
+			r.RancherClusterNamespace = "fleet-default"
+		})
+
+		It("should link the Rancher cluster with labels and delete it along with the CAPI cluster", func() {
+			r.RancherClient = builder.Build()
+
+			_, err := reconcileCluster()
+			Expect(err).ToNot(HaveOccurred())
+
+			rancherCluster := &provisioningv1.Cluster{}
+			Expect(r.RancherClient.Get(ctx, rancherClusterKey, rancherCluster)).To(Succeed())
+			Expect(rancherCluster.OwnerReferences).To(BeEmpty())
+			Expect(rancherCluster.Labels).To(HaveKeyWithValue(capiClusterOwner, "test-cluster"))
+			Expect(rancherCluster.Labels).To(HaveKeyWithValue(capiClusterOwnerNamespace, "test-ns"))
+			Expect(rancherCluster.Labels).To(HaveKeyWithValue(capiClusterOwnerUID, "capi-uid"))
+			Expect(linkedCapiCluster(rancherCluster)).To(Equal(capiClusterKey))
+
+			Expect(r.Client.Get(ctx, capiClusterKey, capiCluster)).To(Succeed())
+			Expect(capiCluster.Finalizers).To(ContainElement(managementv3.CapiClusterFinalizer))
+
+			deleteCapiCluster()
+
+			_, err = reconcileCluster()
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(apierrors.IsNotFound(r.RancherClient.Get(ctx, rancherClusterKey, &provisioningv1.Cluster{}))).To(BeTrue())
+			Expect(apierrors.IsNotFound(r.Client.Get(ctx, capiClusterKey, &clusterv1.Cluster{}))).To(BeTrue())
+		})
+
+		It("should relink a Rancher cluster linked to a previous CAPI cluster with the same name", func() {
+			stale := testutil.RancherCluster(rancherClusterKey.Name, rancherClusterKey.Namespace, testutil.ClusterStateReady)
+			stale.UID = "rancher-uid"
+			stale.Labels = map[string]string{
+				ownedLabelName:            "",
+				capiClusterOwner:          "test-cluster",
+				capiClusterOwnerNamespace: "test-ns",
+				capiClusterOwnerUID:       "previous-uid",
+			}
+			r.RancherClient = builder.WithObjects(stale).Build()
+
+			_, err := reconcileCluster()
+			Expect(err).ToNot(HaveOccurred())
+
+			rancherCluster := &provisioningv1.Cluster{}
+			Expect(r.RancherClient.Get(ctx, rancherClusterKey, rancherCluster)).To(Succeed())
+			Expect(rancherCluster.UID).To(BeEquivalentTo("rancher-uid"))
+			Expect(rancherCluster.Labels).To(HaveKeyWithValue(capiClusterOwnerUID, "capi-uid"))
+			Expect(recorder.Events).To(Receive(ContainSubstring(turtlesv1.RancherClusterRelinkedReason)))
+
+			Expect(r.Client.Get(ctx, capiClusterKey, capiCluster)).To(Succeed())
+			Expect(capiCluster.Finalizers).To(ContainElement(managementv3.CapiClusterFinalizer))
+		})
+
+		It("should not take over a Rancher cluster linked to another CAPI cluster", func() {
+			other := testutil.RancherCluster(rancherClusterKey.Name, rancherClusterKey.Namespace, testutil.ClusterStateReady)
+			other.Labels = map[string]string{
+				ownedLabelName:            "",
+				capiClusterOwner:          "test-cluster",
+				capiClusterOwnerNamespace: "other-ns",
+				capiClusterOwnerUID:       "other-uid",
+			}
+			r.RancherClient = builder.WithObjects(other).Build()
+
+			_, err := reconcileCluster()
+			Expect(err).To(MatchError(ContainSubstring("is not linked to CAPI cluster test-ns/test-cluster")))
+			Expect(r.RancherClient.Get(ctx, rancherClusterKey, &provisioningv1.Cluster{})).To(Succeed())
+		})
+	})
+
+	Context("in a namespace mapped with the annotation of the CAPI cluster", func() {
+		rancherClusterKey := client.ObjectKey{Namespace: "team-a", Name: "test-cluster-capi"}
+
+		BeforeEach(func() {
+			r.RancherClusterNamespace = "fleet-default"
+			builder = builder.WithObjects(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a"}})
+
+			Expect(r.Client.Get(ctx, capiClusterKey, capiCluster)).To(Succeed())
+			capiCluster.Annotations = map[string]string{turtlesannotations.RancherClusterNamespaceAnnotation: "team-a"}
+			Expect(r.Client.Update(ctx, capiCluster)).To(Succeed())
+		})
+
+		It("should create the Rancher cluster in the mapped namespace and map it back to the CAPI cluster", func() {
+			r.RancherClient = builder.Build()
+
+			_, err := reconcileCluster()
+			Expect(err).ToNot(HaveOccurred())
+
+			rancherCluster := &provisioningv1.Cluster{}
+			Expect(r.RancherClient.Get(ctx, rancherClusterKey, rancherCluster)).To(Succeed())
+			Expect(rancherCluster.OwnerReferences).To(BeEmpty())
+			Expect(rancherCluster.Labels).To(HaveKeyWithValue(capiClusterOwnerNamespace, "test-ns"))
+			Expect(apierrors.IsNotFound(r.RancherClient.Get(ctx,
+				client.ObjectKey{Namespace: "fleet-default", Name: rancherClusterKey.Name}, &provisioningv1.Cluster{}))).To(BeTrue())
+
+			requests := r.rancherClusterToCapiCluster(ctx, predicate.Funcs{})(ctx, rancherCluster)
+			Expect(requests).To(ConsistOf(reconcile.Request{NamespacedName: capiClusterKey}))
+		})
+
+		It("should read the registration token from the mapped namespace", func() {
+			server := testutil.NewManifestServer(manifestWithServerFields)
+			DeferCleanup(server.Close)
+
+			linked := testutil.RancherCluster(rancherClusterKey.Name, rancherClusterKey.Namespace, testutil.ClusterStateNameSet)
+			linked.Labels = map[string]string{
+				ownedLabelName:            "",
+				capiClusterOwner:          "test-cluster",
+				capiClusterOwnerNamespace: "test-ns",
+				capiClusterOwnerUID:       "capi-uid",
+			}
+			r.RancherClient = builder.WithObjects(linked,
+				testutil.RegistrationToken(linked.Status.ClusterName, rancherClusterKey.Namespace, server.URL),
+			).Build()
+			r.remoteClientGetter = func(_ context.Context, _ string, _ client.Client, _ client.ObjectKey) (client.Client, error) {
+				return fake.NewClientBuilder().WithScheme(scheme.Scheme).Build(), nil
+			}
+
+			_, err := reconcileCluster()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(server.Requests()).To(Equal(1))
+
+			Expect(r.Client.Get(ctx, capiClusterKey, capiCluster)).To(Succeed())
+			Expect(conditions.IsTrue(capiCluster, turtlesv1.ImportManifestAppliedCondition)).To(BeTrue())
+		})
+	})
+
+	Context("in a remote Rancher cluster", func() {
+		rancherClusterKey := client.ObjectKey{Namespace: "test-ns", Name: "test-cluster-capi"}
+
+		BeforeEach(func() {
+			r.RemoteRancher = true
+		})
+
+		It("should link the Rancher cluster in the same namespace with labels and delete it along with the CAPI cluster", func() {
+			r.RancherClient = builder.Build()
+
+			_, err := reconcileCluster()
+			Expect(err).ToNot(HaveOccurred())
+
+			rancherCluster := &provisioningv1.Cluster{}
+			Expect(r.RancherClient.Get(ctx, rancherClusterKey, rancherCluster)).To(Succeed())
+			Expect(rancherCluster.OwnerReferences).To(BeEmpty())
+			Expect(rancherCluster.Labels).To(HaveKeyWithValue(capiClusterOwner, "test-cluster"))
+			Expect(rancherCluster.Labels).To(HaveKeyWithValue(capiClusterOwnerNamespace, "test-ns"))
+			Expect(rancherCluster.Labels).To(HaveKeyWithValue(capiClusterOwnerUID, "capi-uid"))
+			Expect(apierrors.IsNotFound(r.Client.Get(ctx, rancherClusterKey, &provisioningv1.Cluster{}))).To(BeTrue())
+
+			requests := r.rancherClusterToCapiCluster(ctx, predicate.Funcs{})(ctx, rancherCluster)
+			Expect(requests).To(ConsistOf(reconcile.Request{NamespacedName: capiClusterKey}))
+
+			By("reconciling the linked Rancher cluster")
+			_, err = reconcileCluster()
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(r.Client.Get(ctx, capiClusterKey, capiCluster)).To(Succeed())
+			Expect(capiCluster.Finalizers).To(ContainElement(managementv3.CapiClusterFinalizer))
+
+			deleteCapiCluster()
+
+			_, err = reconcileCluster()
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(apierrors.IsNotFound(r.RancherClient.Get(ctx, rancherClusterKey, &provisioningv1.Cluster{}))).To(BeTrue())
+			Expect(apierrors.IsNotFound(r.Client.Get(ctx, capiClusterKey, &clusterv1.Cluster{}))).To(BeTrue())
+		})
+
+		It("should not take over a Rancher cluster in the same namespace linked with an owner reference", func() {
+			r.RancherClient = builder.WithObjects(&provisioningv1.Cluster{ObjectMeta: metav1.ObjectMeta{
+				Name:      rancherClusterKey.Name,
+				Namespace: rancherClusterKey.Namespace,
+				Labels:    map[string]string{ownedLabelName: ""},
+				OwnerReferences: []metav1.OwnerReference{{
+					APIVersion: clusterv1.GroupVersion.String(),
+					Kind:       clusterv1.ClusterKind,
+					Name:       "test-cluster",
+					UID:        "capi-uid",
+				}},
+			}}).Build()
+
+			_, err := reconcileCluster()
+			Expect(err).To(MatchError(ContainSubstring("is not linked to CAPI cluster")))
+		})
+	})
+})
+
+var _ = Describe("CAPI cluster first seen while being deleted", func() {
+	var (
+		r           *CAPIImportReconciler
+		capiCluster *clusterv1.Cluster
+		builder     *testutil.RancherClientBuilder
+	)
+
+	capiClusterKey := client.ObjectKey{Namespace: "test-ns", Name: "test-cluster"}
+	rancherClusterKey := client.ObjectKey{Namespace: "fleet-default", Name: "test-cluster-capi"}
+
+	reconcileCluster := func() error {
+		r.Client = fake.NewClientBuilder().WithScheme(scheme.Scheme).
+			WithObjects(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+				Name:   "test-ns",
+				Labels: map[string]string{importLabelName: "true"},
+			}}, capiCluster).WithStatusSubresource(&clusterv1.Cluster{}).Build()
+		r.RancherClient = builder.Build()
+
+		_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: capiClusterKey})
+
+		return err
+	}
+
+	BeforeEach(func() {
+		capiCluster = &clusterv1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              capiClusterKey.Name,
+				Namespace:         capiClusterKey.Namespace,
+				UID:               "capi-uid",
+				Finalizers:        []string{"test"},
+				DeletionTimestamp: &metav1.Time{Time: time.Now()},
+			},
+		}
+		builder = testutil.NewRancherClientBuilder().WithObjects(
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "fleet-default"}},
+		)
+
+		r = &CAPIImportReconciler{
+			recorder: record.NewFakeRecorder(10),
+			RancherClusterOptions: RancherClusterOptions{
+				RancherClusterNamespace: "fleet-default",
+			},
+		}
+	})
+
+	DescribeTable("should never import the cluster",
+		func(controlPlaneReady bool) {
+			capiCluster.Status.ControlPlaneReady = controlPlaneReady
+
+			Expect(reconcileCluster()).To(Succeed())
+
+			Expect(apierrors.IsNotFound(r.RancherClient.Get(ctx, rancherClusterKey, &provisioningv1.Cluster{}))).To(BeTrue())
+
+			stored := &clusterv1.Cluster{}
+			Expect(r.Client.Get(ctx, capiClusterKey, stored)).To(Succeed())
+			Expect(stored.Status.Conditions).To(BeEmpty())
+			Expect(stored.Annotations).To(BeEmpty())
+		},
+		Entry("with a ready control plane", true),
+		Entry("with a control plane not ready", false),
+	)
+
+	It("should run the cleanup of the linked Rancher cluster", func() {
+		capiCluster.Finalizers = []string{managementv3.CapiClusterFinalizer}
+
+		linked := testutil.RancherCluster(rancherClusterKey.Name, rancherClusterKey.Namespace, testutil.ClusterStateReady)
+		linked.Labels = map[string]string{
+			ownedLabelName:            "",
+			capiClusterOwner:          capiClusterKey.Name,
+			capiClusterOwnerNamespace: capiClusterKey.Namespace,
+			capiClusterOwnerUID:       "capi-uid",
+		}
+		linked.Finalizers = []string{deletionProtectionFinalizer}
+		builder.WithObjects(linked)
+
+		Expect(reconcileCluster()).To(Succeed())
+
+		Expect(apierrors.IsNotFound(r.RancherClient.Get(ctx, rancherClusterKey, &provisioningv1.Cluster{}))).To(BeTrue())
+		Expect(apierrors.IsNotFound(r.Client.Get(ctx, capiClusterKey, &clusterv1.Cluster{}))).To(BeTrue())
+	})
+})
@@ -0,0 +1,153 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/predicates"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	managementv3 "github.com/rancher/turtles/internal/rancher/management/v3"
+	provisioningv1 "github.com/rancher/turtles/internal/rancher/provisioning/v1"
+	"github.com/rancher/turtles/testutil"
+	turtlesannotations "github.com/rancher/turtles/util/annotations"
+)
+
+var _ = Describe("paused clusters", func() {
+	var (
+		r            *CAPIImportReconciler
+		server       *testutil.ManifestServer
+		remoteClient client.Client
+		ns           *corev1.Namespace
+		capiCluster  *clusterv1.Cluster
+		rancherKey   client.ObjectKey
+	)
+
+	BeforeEach(func() {
+		server = testutil.NewManifestServer(manifestWithServerFields)
+		DeferCleanup(server.Close)
+
+		ns = &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test-ns"}}
+		capiCluster = &clusterv1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "test-cluster",
+				Namespace:   ns.Name,
+				Labels:      map[string]string{importLabelName: "true"},
+				Annotations: map[string]string{clusterv1.PausedAnnotation: ""},
+			},
+			Status: clusterv1.ClusterStatus{ControlPlaneReady: true},
+		}
+		rancherKey = client.ObjectKey{Namespace: ns.Name, Name: "test-cluster-capi"}
+		remoteClient = fake.NewClientBuilder().WithScheme(scheme.Scheme).Build()
+
+		r = &CAPIImportReconciler{
+			RancherClient: testutil.NewRancherClientBuilder().WithObjects(ns.DeepCopy()).Build(),
+			recorder:      record.NewFakeRecorder(10),
+			remoteClientGetter: func(_ context.Context, _ string, _ client.Client, _ client.ObjectKey) (client.Client, error) {
+				return remoteClient, nil
+			},
+		}
+	})
+
+	reconcileCluster := func() {
+		r.Client = fake.NewClientBuilder().WithScheme(scheme.Scheme).
+			WithObjects(ns, capiCluster).WithStatusSubresource(&clusterv1.Cluster{}).Build()
+
+		_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(capiCluster)})
+		Expect(err).ToNot(HaveOccurred())
+	}
+
+	capiPredicates := func() predicate.Funcs {
+		r.Client = fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(ns).Build()
+
+		clusterPredicates, err := r.clusterPredicates(ctx, logr.Discard())
+		Expect(err).ToNot(HaveOccurred())
+
+		return predicates.All(logr.Discard(), clusterPredicates...)
+	}
+
+	It("should not create the Rancher cluster of a paused cluster", func() {
+		reconcileCluster()
+
+		err := r.RancherClient.Get(ctx, rancherKey, &provisioningv1.Cluster{})
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+
+		updated := &clusterv1.Cluster{}
+		Expect(r.Client.Get(ctx, client.ObjectKeyFromObject(capiCluster), updated)).To(Succeed())
+		Expect(updated.Finalizers).To(BeEmpty())
+		Expect(updated.Status.Conditions).To(BeEmpty())
+	})
+
+	It("should not apply the manifest of a paused cluster", func() {
+		r.RancherClient = testutil.NewRancherClientBuilder().
+			WithCluster(rancherKey.Name, rancherKey.Namespace, testutil.ClusterStateNameSet, server.URL).
+			Build()
+
+		reconcileCluster()
+
+		Expect(server.Requests()).To(BeZero())
+		Expect(remoteClient.Get(ctx, client.ObjectKey{Name: cattleSystemNamespace}, &corev1.Namespace{})).ToNot(Succeed())
+	})
+
+	It("should import the cluster once unpaused", func() {
+		delete(capiCluster.Annotations, clusterv1.PausedAnnotation)
+
+		reconcileCluster()
+
+		Expect(r.RancherClient.Get(ctx, rancherKey, &provisioningv1.Cluster{})).To(Succeed())
+	})
+
+	It("should filter paused clusters out of the watches", func() {
+		Expect(capiPredicates().Create(event.CreateEvent{Object: capiCluster})).To(BeFalse())
+
+		delete(capiCluster.Annotations, clusterv1.PausedAnnotation)
+		Expect(capiPredicates().Create(event.CreateEvent{Object: capiCluster})).To(BeTrue())
+
+		capiCluster.Annotations[turtlesannotations.ClusterImportedAnnotation] = "true"
+		Expect(capiPredicates().Create(event.CreateEvent{Object: capiCluster})).To(BeFalse())
+	})
+
+	It("should not create the management cluster of a paused cluster", func() {
+		cl := fake.NewClientBuilder().WithScheme(testutil.NewScheme()).WithObjects(ns, capiCluster).Build()
+		v3Reconciler := &CAPIImportManagementV3Reconciler{Client: cl, RancherClient: cl}
+
+		_, err := v3Reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(capiCluster)})
+		Expect(err).ToNot(HaveOccurred())
+
+		managementClusters := &managementv3.ClusterList{}
+		Expect(cl.List(ctx, managementClusters)).To(Succeed())
+		Expect(managementClusters.Items).To(BeEmpty())
+
+		updated := &clusterv1.Cluster{}
+		Expect(cl.Get(ctx, client.ObjectKeyFromObject(capiCluster), updated)).To(Succeed())
+		Expect(updated.Finalizers).To(BeEmpty())
+	})
+})
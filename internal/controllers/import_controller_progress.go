@@ -0,0 +1,102 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	turtlesv1 "github.com/rancher/turtles/api/v1alpha1"
+)
+
+// markRegistrationTokenReady reports the registration token as ready once Rancher set its manifest URL.
+func markRegistrationTokenReady(capiCluster *clusterv1.Cluster, manifestURL string) {
+	if manifestURL == "" {
+		conditions.MarkFalse(capiCluster, turtlesv1.RegistrationTokenReadyCondition, turtlesv1.WaitingForManifestURLReason,
+			clusterv1.ConditionSeverityInfo, "Waiting for Rancher to set the manifest URL of the registration token")
+
+		return
+	}
+
+	conditions.MarkTrue(capiCluster, turtlesv1.RegistrationTokenReadyCondition)
+}
+
+// markImportPendingOnControlPlane reports the import as pending on the control plane of the CAPI cluster, with the last
+// observed ControlPlaneReady condition in the message.
+func markImportPendingOnControlPlane(capiCluster *clusterv1.Cluster) {
+	message := "Waiting for the control plane to be ready"
+
+	if controlPlane := conditions.Get(capiCluster, clusterv1.ControlPlaneReadyCondition); controlPlane != nil {
+		message += fmt.Sprintf(", %s is %s", controlPlane.Type, controlPlane.Status)
+
+		if controlPlane.Reason != "" {
+			message += fmt.Sprintf(" (%s)", controlPlane.Reason)
+		}
+
+		if controlPlane.Message != "" {
+			message += ": " + controlPlane.Message
+		}
+	} else {
+		message += fmt.Sprintf(", no %s condition reported yet", clusterv1.ControlPlaneReadyCondition)
+	}
+
+	conditions.Set(capiCluster, &clusterv1.Condition{
+		Type:    turtlesv1.ImportPendingCondition,
+		Status:  corev1.ConditionTrue,
+		Reason:  turtlesv1.WaitingForControlPlaneReason,
+		Message: message,
+	})
+}
+
+// markImportManifestApplied reports the outcome of an attempt to apply the registration manifest. A manifest which
+// was not applied without an error is waiting for the registration token, and a manifest applied with a dry-run is not
+// reported as applied.
+func markImportManifestApplied(capiCluster *clusterv1.Cluster, applied, dryRun bool, err error) {
+	switch {
+	case err != nil:
+		conditions.MarkFalse(capiCluster, turtlesv1.ImportManifestAppliedCondition, turtlesv1.ManifestApplyFailedReason,
+			clusterv1.ConditionSeverityWarning, "%s", err)
+	case !applied:
+		conditions.MarkFalse(capiCluster, turtlesv1.ImportManifestAppliedCondition, turtlesv1.WaitingForRegistrationTokenReason,
+			clusterv1.ConditionSeverityInfo, "Waiting for the registration manifest to be available")
+	case dryRun:
+		conditions.MarkFalse(capiCluster, turtlesv1.ImportManifestAppliedCondition, turtlesv1.ManifestDryRunReason,
+			clusterv1.ConditionSeverityInfo, "Registration manifest validated with a dry-run, no object was persisted")
+	default:
+		conditions.MarkTrue(capiCluster, turtlesv1.ImportManifestAppliedCondition)
+	}
+}
+
+// manifestReapplyNeeded returns whether the conditions of the CAPI cluster report a problem the registration manifest
+// must be applied again for, even when it didn't change since it was last applied: the cluster is degraded, the agent
+// failed to register, some of the manifest objects were forbidden, or the Rancher cluster was relinked.
+func manifestReapplyNeeded(capiCluster *clusterv1.Cluster) bool {
+	return conditions.IsTrue(capiCluster, turtlesv1.ImportDegradedCondition) ||
+		conditions.IsFalse(capiCluster, turtlesv1.ManifestApplyPermittedCondition) ||
+		conditions.GetReason(capiCluster, turtlesv1.RancherAgentRegisteredCondition) == turtlesv1.AgentRegistrationFailedReason ||
+		relinkedImportPending(capiCluster)
+}
+
+// relinkedImportPending returns whether the Rancher cluster of the CAPI cluster was relinked from a previous CAPI
+// cluster with the same name, and the import manifest was not applied to the new one yet. The agent deployment
+// reported by the Rancher cluster belongs to the previous CAPI cluster until then.
+func relinkedImportPending(capiCluster *clusterv1.Cluster) bool {
+	return conditions.GetReason(capiCluster, turtlesv1.RancherAgentRegisteredCondition) == turtlesv1.RancherClusterRelinkedReason
+}
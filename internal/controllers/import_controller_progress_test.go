@@ -0,0 +1,189 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	turtlesv1 "github.com/rancher/turtles/api/v1alpha1"
+	managementv3 "github.com/rancher/turtles/internal/rancher/management/v3"
+	provisioningv1 "github.com/rancher/turtles/internal/rancher/provisioning/v1"
+	"github.com/rancher/turtles/testutil"
+)
+
+var _ = Describe("import progress conditions", func() {
+	var (
+		r              *CAPIImportReconciler
+		server         *testutil.ManifestServer
+		capiCluster    *clusterv1.Cluster
+		rancherCluster *provisioningv1.Cluster
+	)
+
+	newReconciler := func(state testutil.ClusterState, manifestURL string) *CAPIImportReconciler {
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test-ns", Labels: map[string]string{importLabelName: "true"}}}
+
+		rancherClient := testutil.NewRancherClientBuilder().WithObjects(ns.DeepCopy())
+		if state != testutil.ClusterStateNoName || manifestURL != "" {
+			rancherClient = rancherClient.WithCluster(rancherCluster.Name, rancherCluster.Namespace, state, manifestURL)
+		}
+
+		return &CAPIImportReconciler{
+			Client:        fake.NewClientBuilder().WithScheme(testutil.NewScheme()).WithObjects(ns).Build(),
+			RancherClient: rancherClient.Build(),
+			recorder:      record.NewFakeRecorder(10),
+			remoteClientGetter: func(_ context.Context, _ string, _ client.Client, _ client.ObjectKey) (client.Client, error) {
+				return fake.NewClientBuilder().WithScheme(scheme.Scheme).Build(), nil
+			},
+			RancherClusterOptions: RancherClusterOptions{
+				MirrorRancherReadiness: true,
+			},
+		}
+	}
+
+	expectCondition := func(conditionType clusterv1.ConditionType, status corev1.ConditionStatus, reason string) {
+		condition := conditions.Get(capiCluster, conditionType)
+		Expect(condition).ToNot(BeNil(), "condition %s", conditionType)
+		Expect(condition.Status).To(Equal(status), "condition %s", conditionType)
+		Expect(condition.Reason).To(Equal(reason), "condition %s", conditionType)
+	}
+
+	BeforeEach(func() {
+		server = testutil.NewManifestServer(manifestWithServerFields)
+		DeferCleanup(server.Close)
+
+		capiCluster = &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", Namespace: "test-ns"}}
+		rancherCluster = &provisioningv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test-cluster-capi", Namespace: "test-ns"}}
+	})
+
+	It("should report the Rancher cluster as not ready once created", func() {
+		r = newReconciler(testutil.ClusterStateNoName, "")
+
+		res, err := r.reconcileNormal(ctx, capiCluster, rancherCluster)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res.RequeueAfter).To(Equal(minRequeueDuration))
+		expectCondition(turtlesv1.RancherClusterReadyCondition, corev1.ConditionFalse, turtlesv1.RancherClusterNotReadyReason)
+		Expect(conditions.GetMessage(capiCluster, turtlesv1.RancherClusterReadyCondition)).To(ContainSubstring("created"))
+	})
+
+	It("should wait for the cluster name before the registration token", func() {
+		r = newReconciler(testutil.ClusterStateNoName, server.URL)
+
+		res, err := r.reconcileNormal(ctx, capiCluster, rancherCluster)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res.RequeueAfter).To(Equal(minRequeueDuration))
+		expectCondition(turtlesv1.RegistrationTokenReadyCondition, corev1.ConditionFalse, turtlesv1.WaitingForClusterNameReason)
+		Expect(conditions.Has(capiCluster, turtlesv1.ImportManifestAppliedCondition)).To(BeFalse())
+	})
+
+	It("should wait for the manifest URL of the registration token", func() {
+		r = newReconciler(testutil.ClusterStateNameSet, "")
+
+		res, err := r.reconcileNormal(ctx, capiCluster, rancherCluster)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res.RequeueAfter).To(Equal(minRequeueDuration))
+		expectCondition(turtlesv1.RegistrationTokenReadyCondition, corev1.ConditionFalse, turtlesv1.WaitingForManifestURLReason)
+		expectCondition(turtlesv1.ImportManifestAppliedCondition, corev1.ConditionFalse, turtlesv1.WaitingForRegistrationTokenReason)
+		expectCondition(turtlesv1.RancherClusterReadyCondition, corev1.ConditionFalse, turtlesv1.RancherClusterNotReadyReason)
+
+		By("applying the manifest once the manifest URL is set")
+		token := &managementv3.ClusterRegistrationToken{}
+		Expect(r.RancherClient.Get(ctx, client.ObjectKey{
+			Name:      testutil.ManagementClusterName(rancherCluster.Name),
+			Namespace: rancherCluster.Namespace,
+		}, token)).To(Succeed())
+		token.Status.ManifestURL = server.URL
+		Expect(r.RancherClient.Status().Update(ctx, token)).To(Succeed())
+
+		_, err = r.reconcileNormal(ctx, capiCluster, rancherCluster)
+		Expect(err).ToNot(HaveOccurred())
+		expectCondition(turtlesv1.RegistrationTokenReadyCondition, corev1.ConditionTrue, "")
+		expectCondition(turtlesv1.ImportManifestAppliedCondition, corev1.ConditionTrue, "")
+	})
+
+	It("should report a failed manifest apply", func() {
+		failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		DeferCleanup(failing.Close)
+
+		r = newReconciler(testutil.ClusterStateNameSet, failing.URL)
+
+		_, err := r.reconcileNormal(ctx, capiCluster, rancherCluster)
+		Expect(err).To(HaveOccurred())
+		expectCondition(turtlesv1.RegistrationTokenReadyCondition, corev1.ConditionTrue, "")
+		expectCondition(turtlesv1.ImportManifestAppliedCondition, corev1.ConditionFalse, turtlesv1.ManifestApplyFailedReason)
+		Expect(conditions.GetSeverity(capiCluster, turtlesv1.ImportManifestAppliedCondition)).To(
+			HaveValue(Equal(clusterv1.ConditionSeverityWarning)))
+	})
+
+	It("should report the Rancher cluster as ready once the agent registered", func() {
+		r = newReconciler(testutil.ClusterStateReady, server.URL)
+
+		_, err := r.reconcileNormal(ctx, capiCluster, rancherCluster)
+		Expect(err).ToNot(HaveOccurred())
+		expectCondition(turtlesv1.RancherClusterReadyCondition, corev1.ConditionTrue, "")
+	})
+
+	It("should report the import as pending until the control plane is ready", func() {
+		r = newReconciler(testutil.ClusterStateNoName, "")
+		r.Client = fake.NewClientBuilder().WithScheme(testutil.NewScheme()).
+			WithObjects(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test-ns"}}, capiCluster.DeepCopy()).
+			WithStatusSubresource(&clusterv1.Cluster{}).Build()
+
+		reconcileCluster := func() {
+			_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(capiCluster)})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(r.Client.Get(ctx, client.ObjectKeyFromObject(capiCluster), capiCluster)).To(Succeed())
+		}
+
+		reconcileCluster()
+		expectCondition(turtlesv1.ImportPendingCondition, corev1.ConditionTrue, turtlesv1.WaitingForControlPlaneReason)
+		Expect(conditions.GetMessage(capiCluster, turtlesv1.ImportPendingCondition)).To(
+			ContainSubstring("no ControlPlaneReady condition reported yet"))
+
+		By("including the last observed control plane condition")
+		conditions.MarkFalse(capiCluster, clusterv1.ControlPlaneReadyCondition, "ScalingUp", clusterv1.ConditionSeverityInfo,
+			"1 of 3 replicas ready")
+		Expect(r.Client.Status().Update(ctx, capiCluster)).To(Succeed())
+
+		reconcileCluster()
+		expectCondition(turtlesv1.ImportPendingCondition, corev1.ConditionTrue, turtlesv1.WaitingForControlPlaneReason)
+		Expect(conditions.GetMessage(capiCluster, turtlesv1.ImportPendingCondition)).To(
+			ContainSubstring("ControlPlaneReady is False (ScalingUp): 1 of 3 replicas ready"))
+
+		By("clearing the condition once the control plane is ready")
+		capiCluster.Status.ControlPlaneReady = true
+		Expect(r.Client.Status().Update(ctx, capiCluster)).To(Succeed())
+
+		reconcileCluster()
+		Expect(conditions.Has(capiCluster, turtlesv1.ImportPendingCondition)).To(BeFalse())
+	})
+})
@@ -0,0 +1,75 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	provisioningv1 "github.com/rancher/turtles/internal/rancher/provisioning/v1"
+	turtlesannotations "github.com/rancher/turtles/util/annotations"
+)
+
+// syncDeletionProtection makes sure the Rancher cluster carries the deletion protection annotation and finalizer,
+// re-asserting them when they were removed. Setting the annotation to "false", or disabling the protection, removes
+// the finalizer so the Rancher cluster can be deleted.
+func (r *CAPIImportReconciler) syncDeletionProtection(ctx context.Context, rancherCluster *provisioningv1.Cluster) error {
+	if !r.DeletionProtection || rancherCluster.GetAnnotations()[turtlesannotations.DeletionProtectionAnnotation] == "false" {
+		return r.releaseDeletionProtection(ctx, rancherCluster)
+	}
+
+	patchBase := client.MergeFrom(rancherCluster.DeepCopy())
+
+	changed := controllerutil.AddFinalizer(rancherCluster, deletionProtectionFinalizer)
+	if rancherCluster.GetAnnotations()[turtlesannotations.DeletionProtectionAnnotation] != "true" {
+		setAnnotation(rancherCluster, turtlesannotations.DeletionProtectionAnnotation, "true")
+
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+
+	if err := r.RancherClient.Patch(ctx, rancherCluster, patchBase); err != nil {
+		return fmt.Errorf("protecting Rancher cluster against deletion: %w", err)
+	}
+
+	log.FromContext(ctx).V(4).Info("protected Rancher cluster against deletion")
+
+	return nil
+}
+
+// releaseDeletionProtection removes the deletion protection finalizer from the Rancher cluster, if present.
+func (r *CAPIImportReconciler) releaseDeletionProtection(ctx context.Context, rancherCluster *provisioningv1.Cluster) error {
+	patchBase := client.MergeFrom(rancherCluster.DeepCopy())
+	if !controllerutil.RemoveFinalizer(rancherCluster, deletionProtectionFinalizer) {
+		return nil
+	}
+
+	if err := r.RancherClient.Patch(ctx, rancherCluster, patchBase); err != nil {
+		return fmt.Errorf("releasing Rancher cluster deletion protection: %w", err)
+	}
+
+	log.FromContext(ctx).Info("released Rancher cluster deletion protection")
+
+	return nil
+}
@@ -0,0 +1,138 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	provisioningv1 "github.com/rancher/turtles/internal/rancher/provisioning/v1"
+	"github.com/rancher/turtles/testutil"
+	turtlesannotations "github.com/rancher/turtles/util/annotations"
+)
+
+var _ = Describe("Rancher cluster deletion protection", func() {
+	var (
+		r              *CAPIImportReconciler
+		capiCluster    *clusterv1.Cluster
+		rancherCluster *provisioningv1.Cluster
+	)
+
+	stored := func() *provisioningv1.Cluster {
+		cluster := &provisioningv1.Cluster{}
+		Expect(r.RancherClient.Get(ctx, client.ObjectKeyFromObject(rancherCluster), cluster)).To(Succeed())
+
+		return cluster
+	}
+
+	BeforeEach(func() {
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test-ns"}}
+		capiCluster = &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-cluster",
+			Namespace: "test-ns",
+			Labels:    map[string]string{importLabelName: "true"},
+		}}
+		rancherCluster = &provisioningv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test-cluster-capi", Namespace: "test-ns"}}
+
+		r = &CAPIImportReconciler{
+			Client: fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(ns, capiCluster).Build(),
+			RancherClient: testutil.NewRancherClientBuilder().
+				WithCluster(rancherCluster.Name, rancherCluster.Namespace, testutil.ClusterStateNoName, "").
+				Build(),
+			RancherClusterOptions: RancherClusterOptions{
+				DeletionProtection: true,
+			},
+		}
+	})
+
+	It("should protect the Rancher cluster and re-assert the protection when removed", func() {
+		_, err := r.reconcile(ctx, capiCluster)
+		Expect(err).ToNot(HaveOccurred())
+
+		protected := stored()
+		Expect(protected.Annotations).To(HaveKeyWithValue(turtlesannotations.DeletionProtectionAnnotation, "true"))
+		Expect(protected.Finalizers).To(ContainElement(deletionProtectionFinalizer))
+
+		controllerutil.RemoveFinalizer(protected, deletionProtectionFinalizer)
+		delete(protected.Annotations, turtlesannotations.DeletionProtectionAnnotation)
+		Expect(r.RancherClient.Update(ctx, protected)).To(Succeed())
+
+		_, err = r.reconcile(ctx, capiCluster)
+		Expect(err).ToNot(HaveOccurred())
+
+		protected = stored()
+		Expect(protected.Annotations).To(HaveKeyWithValue(turtlesannotations.DeletionProtectionAnnotation, "true"))
+		Expect(protected.Finalizers).To(ContainElement(deletionProtectionFinalizer))
+	})
+
+	It("should not protect the Rancher cluster when disabled", func() {
+		r.DeletionProtection = false
+
+		_, err := r.reconcile(ctx, capiCluster)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(stored().Finalizers).ToNot(ContainElement(deletionProtectionFinalizer))
+	})
+
+	It("should lift the protection when the annotation is set to false", func() {
+		_, err := r.reconcile(ctx, capiCluster)
+		Expect(err).ToNot(HaveOccurred())
+
+		protected := stored()
+		protected.Annotations[turtlesannotations.DeletionProtectionAnnotation] = "false"
+		Expect(r.RancherClient.Update(ctx, protected)).To(Succeed())
+
+		_, err = r.reconcile(ctx, capiCluster)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(stored().Finalizers).ToNot(ContainElement(deletionProtectionFinalizer))
+	})
+
+	It("should block the Rancher cluster deletion while protected", func() {
+		_, err := r.reconcile(ctx, capiCluster)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(r.RancherClient.Delete(ctx, stored())).To(Succeed())
+
+		_, err = r.reconcile(ctx, capiCluster)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(stored().DeletionTimestamp.IsZero()).To(BeFalse())
+		Expect(capiCluster.Annotations).ToNot(HaveKey(turtlesannotations.ClusterImportedAnnotation))
+	})
+
+	It("should remove the protection when the CAPI cluster is deleted", func() {
+		_, err := r.reconcile(ctx, capiCluster)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stored().Finalizers).To(ContainElement(deletionProtectionFinalizer))
+
+		capiCluster.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+
+		Expect(r.reconcileDeleting(ctx, capiCluster)).To(Succeed())
+
+		Expect(stored().Finalizers).ToNot(ContainElement(deletionProtectionFinalizer))
+	})
+})
@@ -0,0 +1,123 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	turtlesv1 "github.com/rancher/turtles/api/v1alpha1"
+	provisioningv1 "github.com/rancher/turtles/internal/rancher/provisioning/v1"
+	turtlesannotations "github.com/rancher/turtles/util/annotations"
+)
+
+// verifyRegistration checks the Rancher cluster became ready within the registration window after the agent was deployed.
+// When it did not, the downstream agent is inspected and its failure reason is surfaced as a condition and an event.
+func (r *CAPIImportReconciler) verifyRegistration(ctx context.Context, capiCluster *clusterv1.Cluster,
+	rancherCluster *provisioningv1.Cluster,
+) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	if r.RegistrationCheckWindow == 0 {
+		return ctrl.Result{}, nil
+	}
+
+	if r.rancherClusterStatus(rancherCluster).Ready {
+		conditions.MarkTrue(capiCluster, turtlesv1.RancherAgentRegisteredCondition)
+		conditions.Delete(capiCluster, turtlesv1.ImportDegradedCondition)
+		r.markEndpointsReachable(capiCluster)
+
+		annotations := capiCluster.GetAnnotations()
+		delete(annotations, turtlesannotations.TokenRefreshesAnnotation)
+		capiCluster.SetAnnotations(annotations)
+
+		return ctrl.Result{}, nil
+	}
+
+	condition := conditions.Get(capiCluster, turtlesv1.RancherAgentRegisteredCondition)
+	if condition == nil || condition.Status == corev1.ConditionTrue {
+		conditions.MarkFalse(capiCluster, turtlesv1.RancherAgentRegisteredCondition, turtlesv1.WaitingForAgentRegistrationReason,
+			clusterv1.ConditionSeverityInfo, "Waiting for the agent to register with Rancher")
+
+		return ctrl.Result{RequeueAfter: r.RegistrationCheckWindow}, nil
+	}
+
+	if condition.Reason == turtlesv1.RegistrationTokenRefreshedReason {
+		return r.applyRefreshedManifest(ctx, capiCluster, rancherCluster)
+	}
+
+	elapsed := r.clock.Since(condition.LastTransitionTime.Time)
+	if elapsed < r.RegistrationCheckWindow {
+		return ctrl.Result{RequeueAfter: r.RegistrationCheckWindow - elapsed}, nil
+	}
+
+	log.Info("Rancher cluster is not ready after the registration window, inspecting downstream agent")
+
+	remoteClient, err := r.remoteClient(ctx, capiCluster)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("getting remote cluster client: %w", r.diagnoseControlPlaneUnreachable(ctx, capiCluster, err))
+	}
+
+	reason, err := diagnoseAgent(ctx, remoteClient)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("diagnosing downstream agent: %w", r.diagnoseControlPlaneUnreachable(ctx, capiCluster, err))
+	}
+
+	if reason == "" {
+		// The condition is already in the disconnected state, its last transition is when the agent was first
+		// found running while the Rancher cluster is not ready.
+		disconnected := condition.Reason == turtlesv1.WaitingForAgentRegistrationReason &&
+			condition.Severity == clusterv1.ConditionSeverityWarning
+
+		if disconnected && r.DisconnectedThreshold > 0 && elapsed >= r.DisconnectedThreshold {
+			if err := r.escalateDisconnected(ctx, capiCluster, rancherCluster, elapsed); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+
+		conditions.MarkFalse(capiCluster, turtlesv1.RancherAgentRegisteredCondition, turtlesv1.WaitingForAgentRegistrationReason,
+			clusterv1.ConditionSeverityWarning, "Agent is running but the Rancher cluster is not ready yet")
+
+		return ctrl.Result{RequeueAfter: r.RegistrationCheckWindow}, nil
+	}
+
+	log.Info("Downstream agent is failing", "reason", reason)
+
+	if refreshed, err := r.refreshRegistrationToken(ctx, capiCluster, rancherCluster, reason); err != nil || refreshed {
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+
+		return r.applyRefreshedManifest(ctx, capiCluster, rancherCluster)
+	}
+
+	if err := r.diagnoseRancherUnreachable(ctx, capiCluster, remoteClient, reason); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	conditions.MarkFalse(capiCluster, turtlesv1.RancherAgentRegisteredCondition, turtlesv1.AgentRegistrationFailedReason,
+		clusterv1.ConditionSeverityWarning, "%s", reason)
+	r.recorder.Event(capiCluster, corev1.EventTypeWarning, turtlesv1.AgentRegistrationFailedReason, reason)
+
+	return ctrl.Result{RequeueAfter: r.RegistrationCheckWindow}, nil
+}
@@ -0,0 +1,93 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"sync"
+	"time"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+)
+
+// requeueBackoff tracks the consecutive reconciles each CAPI cluster waited for the same reason, e.g. on its control
+// plane or on Rancher, so that waiting clusters are requeued quickly at first and settle to defaultRequeueDuration.
+type requeueBackoff struct {
+	lock  sync.Mutex
+	waits map[client.ObjectKey]requeueWait
+}
+
+// requeueWait is the reason a CAPI cluster waits for and the number of consecutive reconciles it waited for it.
+type requeueWait struct {
+	reason string
+	count  int
+}
+
+// next records a wait of the CAPI cluster for the reason and returns the interval to requeue it after. The interval
+// starts at initial, or minRequeueDuration when not positive, and doubles on each consecutive wait for the same
+// reason, up to defaultRequeueDuration. Waiting for a different reason starts over.
+func (b *requeueBackoff) next(key client.ObjectKey, reason string, initial time.Duration) time.Duration {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.waits == nil {
+		b.waits = map[client.ObjectKey]requeueWait{}
+	}
+
+	wait := b.waits[key]
+	if wait.reason != reason {
+		wait = requeueWait{reason: reason}
+	}
+
+	interval := initial
+	if interval <= 0 {
+		interval = minRequeueDuration
+	}
+
+	for i := 0; i < wait.count && interval < defaultRequeueDuration; i++ {
+		interval *= 2
+	}
+
+	wait.count++
+	b.waits[key] = wait
+
+	return min(interval, defaultRequeueDuration)
+}
+
+// reset restarts the backoff of the CAPI cluster, once it made progress or no longer exists.
+func (b *requeueBackoff) reset(key client.ObjectKey) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	delete(b.waits, key)
+}
+
+// requeueWaiting returns the result of a reconcile of the CAPI cluster waiting for the reason, requeued with the
+// backoff of the cluster starting at minRequeueDuration. Errors are not waits: they are returned to be requeued by the
+// controller rate limiter.
+func (r *CAPIImportReconciler) requeueWaiting(capiCluster *clusterv1.Cluster, reason string) ctrl.Result {
+	return ctrl.Result{RequeueAfter: r.requeues.next(client.ObjectKeyFromObject(capiCluster), reason, minRequeueDuration)}
+}
+
+// requeueWaitingOnRancher returns the result of a reconcile of the CAPI cluster waiting for Rancher to fill a field,
+// e.g. the cluster name or the manifest URL, requeued with the backoff of the cluster starting at the Rancher
+// cluster poll interval.
+func (r *CAPIImportReconciler) requeueWaitingOnRancher(capiCluster *clusterv1.Cluster, reason string) ctrl.Result {
+	return ctrl.Result{RequeueAfter: r.requeues.next(client.ObjectKeyFromObject(capiCluster), reason, r.RancherClusterPollInterval)}
+}
@@ -0,0 +1,262 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	clocktesting "k8s.io/utils/clock/testing"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	turtlesv1 "github.com/rancher/turtles/api/v1alpha1"
+	provisioningv1 "github.com/rancher/turtles/internal/rancher/provisioning/v1"
+	"github.com/rancher/turtles/testutil"
+)
+
+var _ = Describe("requeue backoff", func() {
+	key := client.ObjectKey{Namespace: "test-ns", Name: "test-cluster"}
+
+	It("should double the interval of consecutive waits up to the default requeue duration", func() {
+		backoff := &requeueBackoff{}
+
+		intervals := []time.Duration{}
+		for range 7 {
+			intervals = append(intervals, backoff.next(key, turtlesv1.WaitingForControlPlaneReason, minRequeueDuration))
+		}
+
+		Expect(intervals).To(Equal([]time.Duration{
+			5 * time.Second, 10 * time.Second, 20 * time.Second, 40 * time.Second,
+			defaultRequeueDuration, defaultRequeueDuration, defaultRequeueDuration,
+		}))
+	})
+
+	It("should start over when the cluster waits for another reason or is reset", func() {
+		backoff := &requeueBackoff{}
+
+		backoff.next(key, turtlesv1.WaitingForControlPlaneReason, minRequeueDuration)
+		Expect(backoff.next(key, turtlesv1.WaitingForControlPlaneReason, minRequeueDuration)).To(Equal(10 * time.Second))
+		Expect(backoff.next(key, turtlesv1.WaitingForClusterNameReason, minRequeueDuration)).To(Equal(minRequeueDuration))
+		Expect(backoff.next(key, turtlesv1.WaitingForClusterNameReason, minRequeueDuration)).To(Equal(10 * time.Second))
+
+		backoff.reset(key)
+		Expect(backoff.next(key, turtlesv1.WaitingForClusterNameReason, minRequeueDuration)).To(Equal(minRequeueDuration))
+	})
+
+	It("should track each cluster separately", func() {
+		backoff := &requeueBackoff{}
+		other := client.ObjectKey{Namespace: "test-ns", Name: "other-cluster"}
+
+		backoff.next(key, turtlesv1.WaitingForControlPlaneReason, minRequeueDuration)
+		Expect(backoff.next(key, turtlesv1.WaitingForControlPlaneReason, minRequeueDuration)).To(Equal(10 * time.Second))
+		Expect(backoff.next(other, turtlesv1.WaitingForControlPlaneReason, minRequeueDuration)).To(Equal(minRequeueDuration))
+	})
+})
+
+var _ = Describe("requeue of waiting reconciles", func() {
+	var (
+		r           *CAPIImportReconciler
+		capiCluster *clusterv1.Cluster
+	)
+
+	BeforeEach(func() {
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test-ns"}}
+		capiCluster = &clusterv1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-cluster",
+				Namespace: "test-ns",
+				Labels:    map[string]string{importLabelName: "true"},
+			},
+		}
+
+		r = &CAPIImportReconciler{
+			Client: fake.NewClientBuilder().WithScheme(scheme.Scheme).
+				WithObjects(ns, capiCluster.DeepCopy()).WithStatusSubresource(&clusterv1.Cluster{}).Build(),
+			RancherClient: testutil.NewRancherClientBuilder().WithObjects(ns.DeepCopy()).Build(),
+			clock:         clocktesting.NewFakeClock(time.Date(2024, 1, 3, 12, 0, 0, 0, time.UTC)),
+		}
+	})
+
+	reconcileCluster := func() time.Duration {
+		res, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(capiCluster)})
+		Expect(err).ToNot(HaveOccurred())
+
+		return res.RequeueAfter
+	}
+
+	It("should back off while the control plane is not ready", func() {
+		Expect(reconcileCluster()).To(Equal(5 * time.Second))
+		Expect(reconcileCluster()).To(Equal(10 * time.Second))
+		Expect(reconcileCluster()).To(Equal(20 * time.Second))
+	})
+
+	It("should restart the backoff once the Rancher cluster is created", func() {
+		reconcileCluster()
+		reconcileCluster()
+
+		Expect(r.Client.Get(ctx, client.ObjectKeyFromObject(capiCluster), capiCluster)).To(Succeed())
+		capiCluster.Status.ControlPlaneReady = true
+		Expect(r.Client.Status().Update(ctx, capiCluster)).To(Succeed())
+
+		Expect(reconcileCluster()).To(Equal(minRequeueDuration))
+		Expect(r.RancherClient.Get(ctx, client.ObjectKey{Namespace: "test-ns", Name: "test-cluster-capi"},
+			&provisioningv1.Cluster{})).To(Succeed())
+
+		By("waiting for Rancher to assign a cluster name")
+		Expect(reconcileCluster()).To(Equal(10 * time.Second))
+		Expect(reconcileCluster()).To(Equal(20 * time.Second))
+	})
+})
+
+var _ = Describe("requeue of waiting management v3 reconciles", func() {
+	var (
+		r           *CAPIImportManagementV3Reconciler
+		capiCluster *clusterv1.Cluster
+	)
+
+	BeforeEach(func() {
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test-ns"}}
+		capiCluster = &clusterv1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-cluster",
+				Namespace: "test-ns",
+				Labels:    map[string]string{importLabelName: "true"},
+			},
+		}
+
+		r = &CAPIImportManagementV3Reconciler{
+			Client:        fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(ns, capiCluster.DeepCopy()).Build(),
+			RancherClient: testutil.NewRancherClientBuilder().WithObjects(ns.DeepCopy()).Build(),
+		}
+	})
+
+	reconcileCluster := func() (reconcile.Result, error) {
+		return r.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(capiCluster)})
+	}
+
+	It("should back off while the control plane is not ready", func() {
+		for _, interval := range []time.Duration{5 * time.Second, 10 * time.Second, 20 * time.Second} {
+			res, err := reconcileCluster()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(res).To(Equal(reconcile.Result{RequeueAfter: interval}))
+		}
+	})
+
+	It("should back off from the poll interval once the Rancher cluster is created", func() {
+		r.RancherClusterPollInterval = 2 * time.Second
+
+		reconcileCluster()
+
+		Expect(r.Client.Get(ctx, client.ObjectKeyFromObject(capiCluster), capiCluster)).To(Succeed())
+		capiCluster.Status.ControlPlaneReady = true
+		Expect(r.Client.Update(ctx, capiCluster)).To(Succeed())
+
+		res, err := reconcileCluster()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res).To(Equal(reconcile.Result{RequeueAfter: 2 * time.Second}))
+	})
+
+	It("should not requeue a deleted cluster", func() {
+		Expect(r.Client.Delete(ctx, capiCluster)).To(Succeed())
+
+		res, err := reconcileCluster()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res).To(BeZero())
+	})
+
+	It("should leave the requeue of errors to the rate limiter", func() {
+		r.Client = fake.NewClientBuilder().WithScheme(scheme.Scheme).WithInterceptorFuncs(interceptor.Funcs{
+			Get: func(_ context.Context, _ client.WithWatch, _ client.ObjectKey, _ client.Object, _ ...client.GetOption) error {
+				return errors.New("connection refused")
+			},
+		}).Build()
+
+		res, err := reconcileCluster()
+		Expect(err).To(MatchError("connection refused"))
+		Expect(res).To(BeZero())
+	})
+})
+
+var _ = Describe("Rancher cluster poll interval", func() {
+	var (
+		capiCluster    *clusterv1.Cluster
+		rancherCluster *provisioningv1.Cluster
+	)
+
+	BeforeEach(func() {
+		capiCluster = &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-cluster",
+			Namespace: "test-ns",
+			Labels:    map[string]string{importLabelName: "true"},
+		}}
+		rancherCluster = &provisioningv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test-cluster-capi", Namespace: "test-ns"}}
+	})
+
+	reconcilerWith := func(state testutil.ClusterState, pollInterval time.Duration) *CAPIImportReconciler {
+		return &CAPIImportReconciler{
+			RancherClient: testutil.NewRancherClientBuilder().
+				WithCluster(rancherCluster.Name, rancherCluster.Namespace, state, "").
+				WithObjects(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test-ns"}}).
+				Build(),
+			TimingOptions: TimingOptions{
+				RancherClusterPollInterval: pollInterval,
+			},
+		}
+	}
+
+	It("should requeue after the poll interval while the cluster name is not set", func() {
+		r := reconcilerWith(testutil.ClusterStateNoName, 2*time.Second)
+
+		res, err := r.reconcileNormal(ctx, capiCluster, rancherCluster)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res.Requeue).To(BeFalse())
+		Expect(res.RequeueAfter).To(Equal(2 * time.Second))
+
+		res, err = r.reconcileNormal(ctx, capiCluster, rancherCluster)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res.Requeue).To(BeFalse())
+		Expect(res.RequeueAfter).To(Equal(4 * time.Second))
+	})
+
+	It("should requeue after the poll interval while the manifest URL is not set", func() {
+		r := reconcilerWith(testutil.ClusterStateNameSet, 2*time.Second)
+
+		res, err := r.reconcileNormal(ctx, capiCluster, rancherCluster)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res.Requeue).To(BeFalse())
+		Expect(res.RequeueAfter).To(Equal(2 * time.Second))
+	})
+
+	It("should default the poll interval", func() {
+		r := reconcilerWith(testutil.ClusterStateNoName, 0)
+
+		res, err := r.reconcileNormal(ctx, capiCluster, rancherCluster)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res.Requeue).To(BeFalse())
+		Expect(res.RequeueAfter).To(Equal(minRequeueDuration))
+	})
+})
@@ -0,0 +1,176 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	provisioningv1 "github.com/rancher/turtles/internal/rancher/provisioning/v1"
+)
+
+// selfCheckTimeout bounds each step of the self-check, so an unresponsive Rancher is reported as unhealthy instead of
+// leaving the last result stale.
+const selfCheckTimeout = 10 * time.Second
+
+// SelfCheckResult is the outcome of the last synthetic check of the import pipeline.
+type SelfCheckResult struct {
+	Healthy   bool            `json:"healthy"`
+	CheckedAt time.Time       `json:"checkedAt,omitempty"`
+	Duration  string          `json:"duration,omitempty"`
+	Message   string          `json:"message,omitempty"`
+	Checks    []SelfCheckStep `json:"checks"`
+}
+
+// SelfCheckStep is the outcome of a single step of the self-check.
+type SelfCheckStep struct {
+	Name    string `json:"name"`
+	Healthy bool   `json:"healthy"`
+	Error   string `json:"error,omitempty"`
+}
+
+// selfCheckRunner runs the self-check periodically on every replica, not only on the leader, so that each replica
+// can be probed.
+type selfCheckRunner struct {
+	r *CAPIImportReconciler
+}
+
+// SelfCheckRunnable returns a runnable performing the self-check every SelfCheckInterval.
+func (r *CAPIImportReconciler) SelfCheckRunnable() manager.Runnable {
+	return selfCheckRunner{r: r}
+}
+
+func (s selfCheckRunner) Start(ctx context.Context) error {
+	wait.UntilWithContext(ctx, func(ctx context.Context) { s.r.runSelfCheck(ctx) }, s.r.SelfCheckInterval)
+	return nil
+}
+
+func (s selfCheckRunner) NeedLeaderElection() bool {
+	return false
+}
+
+// runSelfCheck checks Rancher can be reached and serves the provisioning API the clusters are imported with, and
+// records the result served by the SelfCheckHandler.
+func (r *CAPIImportReconciler) runSelfCheck(ctx context.Context) SelfCheckResult {
+	start := r.now()
+
+	steps := []struct {
+		name  string
+		check func(ctx context.Context) error
+	}{
+		{"provisioningAPI", r.checkProvisioningAPI},
+		{"rancherReachable", r.checkRancherReachable},
+	}
+
+	result := SelfCheckResult{Healthy: true, CheckedAt: start, Checks: []SelfCheckStep{}}
+
+	for _, step := range steps {
+		stepCtx, cancel := context.WithTimeout(ctx, selfCheckTimeout)
+		err := step.check(stepCtx)
+
+		cancel()
+
+		checked := SelfCheckStep{Name: step.name, Healthy: err == nil}
+		if err != nil {
+			checked.Error = redactSecrets(err.Error())
+			result.Healthy = false
+		}
+
+		result.Checks = append(result.Checks, checked)
+	}
+
+	result.Duration = r.now().Sub(start).String()
+
+	if !result.Healthy {
+		log.FromContext(ctx).Info("Import pipeline self-check failed", "checks", result.Checks)
+	}
+
+	r.selfCheckLock.Lock()
+	r.selfCheck = &result
+	r.selfCheckLock.Unlock()
+
+	return result
+}
+
+// checkProvisioningAPI checks the Rancher provisioning cluster resource is served.
+func (r *CAPIImportReconciler) checkProvisioningAPI(_ context.Context) error {
+	gvk, err := apiutil.GVKForObject(&provisioningv1.Cluster{}, r.RancherClient.Scheme())
+	if err != nil {
+		return fmt.Errorf("getting provisioning cluster kind: %w", err)
+	}
+
+	if _, err := r.RancherClient.RESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version); err != nil {
+		return fmt.Errorf("provisioning API %s is not available: %w", gvk.GroupVersion(), err)
+	}
+
+	return nil
+}
+
+// checkRancherReachable checks the Rancher clusters can be listed.
+func (r *CAPIImportReconciler) checkRancherReachable(ctx context.Context) error {
+	if err := r.RancherClient.List(ctx, &provisioningv1.ClusterList{}, client.Limit(1)); err != nil {
+		return fmt.Errorf("listing Rancher clusters: %w", err)
+	}
+
+	return nil
+}
+
+// SelfCheckHandler returns a handler serving the result of the last self-check as JSON, with a 200 status when it
+// was healthy and a 503 status when it failed or did not complete yet. It is meant for external monitors and is not
+// authenticated, the result doesn't carry secrets.
+func (r *CAPIImportReconciler) SelfCheckHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+
+			return
+		}
+
+		r.selfCheckLock.RLock()
+		result := SelfCheckResult{Message: "self-check did not complete yet", Checks: []SelfCheckStep{}}
+
+		if r.selfCheck != nil {
+			result = *r.selfCheck
+		}
+		r.selfCheckLock.RUnlock()
+
+		data, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		status := http.StatusOK
+		if !result.Healthy {
+			status = http.StatusServiceUnavailable
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_, _ = w.Write(data)
+	})
+}
@@ -0,0 +1,150 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/client-go/kubernetes/scheme"
+	clocktesting "k8s.io/utils/clock/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	provisioningv1 "github.com/rancher/turtles/internal/rancher/provisioning/v1"
+)
+
+var _ = Describe("import pipeline self-check", func() {
+	var (
+		r           *CAPIImportReconciler
+		fakeClock   *clocktesting.FakeClock
+		listErr     error
+		rancherList int
+	)
+
+	probe := func() (int, SelfCheckResult) {
+		rec := httptest.NewRecorder()
+		r.SelfCheckHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/selfcheck", nil))
+
+		result := SelfCheckResult{}
+		Expect(json.Unmarshal(rec.Body.Bytes(), &result)).To(Succeed())
+		Expect(rec.Header().Get("Content-Type")).To(Equal("application/json"))
+
+		return rec.Code, result
+	}
+
+	rancherClient := func(mapper meta.RESTMapper) client.Client {
+		return fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRESTMapper(mapper).WithInterceptorFuncs(interceptor.Funcs{
+			List: func(ctx context.Context, c client.WithWatch, list client.ObjectList, opts ...client.ListOption) error {
+				rancherList++
+
+				if listErr != nil {
+					return listErr
+				}
+
+				return c.List(ctx, list, opts...)
+			},
+		}).Build()
+	}
+
+	BeforeEach(func() {
+		listErr = nil
+		rancherList = 0
+		fakeClock = clocktesting.NewFakeClock(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+
+		mapper := meta.NewDefaultRESTMapper(nil)
+		mapper.Add(provisioningv1.GroupVersion.WithKind("Cluster"), meta.RESTScopeNamespace)
+
+		r = &CAPIImportReconciler{
+			RancherClient: rancherClient(mapper),
+			clock:         fakeClock,
+		}
+	})
+
+	It("should be unavailable before the first check", func() {
+		status, result := probe()
+		Expect(status).To(Equal(http.StatusServiceUnavailable))
+		Expect(result.Healthy).To(BeFalse())
+		Expect(result.Message).To(ContainSubstring("did not complete"))
+	})
+
+	It("should report a healthy pipeline", func() {
+		result := r.runSelfCheck(ctx)
+		Expect(result.Healthy).To(BeTrue())
+		Expect(rancherList).To(Equal(1))
+
+		status, served := probe()
+		Expect(status).To(Equal(http.StatusOK))
+		Expect(served.Healthy).To(BeTrue())
+		Expect(served.CheckedAt).To(BeTemporally("==", fakeClock.Now()))
+		Expect(served.Checks).To(ConsistOf(
+			SelfCheckStep{Name: "provisioningAPI", Healthy: true},
+			SelfCheckStep{Name: "rancherReachable", Healthy: true},
+		))
+	})
+
+	It("should report an unreachable Rancher and recover", func() {
+		listErr = errors.New(`Get "https://rancher.example.com/v1/provisioning.cattle.io.clusters?token=secret": connection refused`)
+
+		r.runSelfCheck(ctx)
+
+		status, result := probe()
+		Expect(status).To(Equal(http.StatusServiceUnavailable))
+		Expect(result.Healthy).To(BeFalse())
+		Expect(result.Checks).To(HaveLen(2))
+		Expect(result.Checks[0].Healthy).To(BeTrue())
+		Expect(result.Checks[1].Healthy).To(BeFalse())
+		Expect(result.Checks[1].Error).To(ContainSubstring("connection refused"))
+		Expect(result.Checks[1].Error).ToNot(ContainSubstring("secret"))
+
+		By("reporting healthy once Rancher is reachable again")
+		listErr = nil
+		fakeClock.Step(time.Minute)
+
+		r.runSelfCheck(ctx)
+
+		status, result = probe()
+		Expect(status).To(Equal(http.StatusOK))
+		Expect(result.CheckedAt).To(BeTemporally("==", fakeClock.Now()))
+	})
+
+	It("should report a missing provisioning API", func() {
+		r.RancherClient = rancherClient(meta.NewDefaultRESTMapper(nil))
+
+		r.runSelfCheck(ctx)
+
+		status, result := probe()
+		Expect(status).To(Equal(http.StatusServiceUnavailable))
+		Expect(result.Checks[0].Name).To(Equal("provisioningAPI"))
+		Expect(result.Checks[0].Healthy).To(BeFalse())
+		Expect(result.Checks[0].Error).To(ContainSubstring("provisioning API"))
+	})
+
+	It("should only answer GET requests", func() {
+		rec := httptest.NewRecorder()
+		r.SelfCheckHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/selfcheck", nil))
+		Expect(rec.Code).To(Equal(http.StatusMethodNotAllowed))
+	})
+})
@@ -0,0 +1,216 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	turtlesv1 "github.com/rancher/turtles/api/v1alpha1"
+)
+
+// ImportPhase summarizes where a CAPI cluster stands in its import.
+type ImportPhase string
+
+const (
+	// ImportPhasePending is used when the CAPI cluster is not eligible for import yet.
+	ImportPhasePending ImportPhase = "Pending"
+	// ImportPhaseImporting is used while the Rancher cluster and its registration manifest are set up.
+	ImportPhaseImporting ImportPhase = "Importing"
+	// ImportPhaseRegistering is used once the registration manifest is applied, until the Rancher cluster is ready.
+	ImportPhaseRegistering ImportPhase = "Registering"
+	// ImportPhaseImported is used once the Rancher cluster is ready.
+	ImportPhaseImported ImportPhase = "Imported"
+	// ImportPhaseDegraded is used when the Rancher cluster stayed not ready after the agent was deployed.
+	ImportPhaseDegraded ImportPhase = "Degraded"
+	// ImportPhaseBackingOff is used when the import attempts were exhausted.
+	ImportPhaseBackingOff ImportPhase = "BackingOff"
+	// ImportPhaseDeleting is used when the CAPI cluster is being deleted.
+	ImportPhaseDeleting ImportPhase = "Deleting"
+)
+
+// ImportStatus is the import status of a CAPI cluster, as served to external dashboards. It combines the conditions
+// of the cached CAPI cluster with the outcome of its last reconcile.
+type ImportStatus struct {
+	Cluster    string               `json:"cluster"`
+	Phase      ImportPhase          `json:"phase"`
+	Conditions clusterv1.Conditions `json:"conditions"`
+	// EligibleSince is when the CAPI cluster became eligible for import, which import durations are measured from.
+	EligibleSince *time.Time `json:"eligibleSince,omitempty"`
+	// ImportedAt is when the Rancher cluster became ready.
+	ImportedAt *time.Time `json:"importedAt,omitempty"`
+	// ImportDurationSeconds is the time the import took, or has been taking so far when it is not complete.
+	ImportDurationSeconds *float64 `json:"importDurationSeconds,omitempty"`
+	// InFlightSince is when the reconcile in progress started.
+	InFlightSince *time.Time `json:"inFlightSince,omitempty"`
+	ClusterReconcileState
+}
+
+// ImportStatusList is the import status of all the CAPI clusters reconciled by the import controller.
+type ImportStatusList struct {
+	Items []ImportStatus `json:"items"`
+}
+
+// ImportStatuses returns the import status of the CAPI clusters tracked by the reconciler, read from the cache of the
+// manager so that serving them does not load the API server.
+func (r *CAPIImportReconciler) ImportStatuses(ctx context.Context) (ImportStatusList, error) {
+	r.reconciles.lock.Lock()
+	keys := make([]client.ObjectKey, 0, len(r.reconciles.clusters))
+	for key := range r.reconciles.clusters {
+		keys = append(keys, key)
+	}
+	r.reconciles.lock.Unlock()
+
+	sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+
+	list := ImportStatusList{Items: []ImportStatus{}}
+
+	for _, key := range keys {
+		status, err := r.ImportStatus(ctx, key)
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+
+		if err != nil {
+			return ImportStatusList{}, err
+		}
+
+		list.Items = append(list.Items, status)
+	}
+
+	return list, nil
+}
+
+// ImportStatus returns the import status of a CAPI cluster. A not found error is returned for CAPI clusters which are
+// gone or were never reconciled.
+func (r *CAPIImportReconciler) ImportStatus(ctx context.Context, key client.ObjectKey) (ImportStatus, error) {
+	r.reconciles.lock.Lock()
+	state, tracked := r.reconciles.clusters[key]
+	started, inFlight := r.reconciles.inFlight[key]
+	r.reconciles.lock.Unlock()
+
+	if !tracked {
+		return ImportStatus{}, apierrors.NewNotFound(clusterv1.GroupVersion.WithResource("clusters").GroupResource(), key.Name)
+	}
+
+	capiCluster := &clusterv1.Cluster{}
+	if err := r.Client.Get(ctx, key, capiCluster); err != nil {
+		return ImportStatus{}, err
+	}
+
+	status := ImportStatus{
+		Cluster:               key.String(),
+		Phase:                 importPhase(capiCluster, state),
+		Conditions:            capiCluster.GetConditions(),
+		ClusterReconcileState: state,
+	}
+
+	if status.Conditions == nil {
+		status.Conditions = clusterv1.Conditions{}
+	}
+
+	if inFlight {
+		status.InFlightSince = &started
+	}
+
+	if eligible := conditions.Get(capiCluster, turtlesv1.ImportEligibleCondition); eligible != nil &&
+		eligible.Status == corev1.ConditionTrue {
+		eligibleSince := eligible.LastTransitionTime.Time
+		status.EligibleSince = &eligibleSince
+
+		end := r.now()
+
+		if ready := conditions.Get(capiCluster, turtlesv1.RancherClusterReadyCondition); ready != nil &&
+			ready.Status == corev1.ConditionTrue {
+			importedAt := ready.LastTransitionTime.Time
+			status.ImportedAt = &importedAt
+			end = importedAt
+		}
+
+		duration := max(end.Sub(eligibleSince), 0).Seconds()
+		status.ImportDurationSeconds = &duration
+	}
+
+	return status, nil
+}
+
+// importPhase summarizes the conditions of the CAPI cluster and the outcome of its last reconcile in a phase.
+func importPhase(capiCluster *clusterv1.Cluster, state ClusterReconcileState) ImportPhase {
+	switch {
+	case !capiCluster.DeletionTimestamp.IsZero():
+		return ImportPhaseDeleting
+	case state.BackingOff:
+		return ImportPhaseBackingOff
+	case conditions.IsTrue(capiCluster, turtlesv1.ImportDegradedCondition):
+		return ImportPhaseDegraded
+	case conditions.IsTrue(capiCluster, turtlesv1.RancherClusterReadyCondition):
+		return ImportPhaseImported
+	case !conditions.IsTrue(capiCluster, turtlesv1.ImportEligibleCondition):
+		return ImportPhasePending
+	case conditions.IsTrue(capiCluster, turtlesv1.ImportManifestAppliedCondition):
+		return ImportPhaseRegistering
+	default:
+		return ImportPhaseImporting
+	}
+}
+
+// StatusHandler returns a read-only handler serving the import status of all the CAPI clusters at prefix, and of a
+// single CAPI cluster at prefix/<namespace>/<name>, as JSON. Requests are authorized like DebugHandler.
+func (r *CAPIImportReconciler) StatusHandler(prefix string) http.Handler {
+	return r.authorizedJSONHandler(func(req *http.Request) (any, int) {
+		log := log.FromContext(req.Context())
+
+		path := strings.Trim(strings.TrimPrefix(req.URL.Path, prefix), "/")
+		if path == "" {
+			list, err := r.ImportStatuses(req.Context())
+			if err != nil {
+				log.Error(err, "listing import statuses")
+				return nil, http.StatusInternalServerError
+			}
+
+			return list, http.StatusOK
+		}
+
+		namespace, name, ok := strings.Cut(path, "/")
+		if !ok || namespace == "" || name == "" || strings.Contains(name, "/") {
+			return nil, http.StatusNotFound
+		}
+
+		status, err := r.ImportStatus(req.Context(), client.ObjectKey{Namespace: namespace, Name: name})
+		if apierrors.IsNotFound(err) {
+			return nil, http.StatusNotFound
+		}
+
+		if err != nil {
+			log.Error(err, "getting import status", "cluster", path)
+			return nil, http.StatusInternalServerError
+		}
+
+		return status, http.StatusOK
+	})
+}
@@ -0,0 +1,199 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	clocktesting "k8s.io/utils/clock/testing"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	turtlesv1 "github.com/rancher/turtles/api/v1alpha1"
+)
+
+var _ = Describe("import status API", func() {
+	const prefix = "/api/v1/imports"
+
+	var (
+		r         *CAPIImportReconciler
+		fakeClock *clocktesting.FakeClock
+		eligible  time.Time
+		imported  *clusterv1.Cluster
+		importing *clusterv1.Cluster
+	)
+
+	get := func(path, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		rec := httptest.NewRecorder()
+		r.StatusHandler(prefix).ServeHTTP(rec, req)
+
+		return rec
+	}
+
+	capiCluster := func(name string, conds ...clusterv1.Condition) *clusterv1.Cluster {
+		return &clusterv1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "test-ns"},
+			Status:     clusterv1.ClusterStatus{Conditions: conds},
+		}
+	}
+
+	BeforeEach(func() {
+		fakeClock = clocktesting.NewFakeClock(time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC))
+		eligible = fakeClock.Now().Add(-10 * time.Minute)
+
+		imported = capiCluster("imported",
+			clusterv1.Condition{Type: turtlesv1.ImportEligibleCondition, Status: corev1.ConditionTrue,
+				LastTransitionTime: metav1.NewTime(eligible)},
+			clusterv1.Condition{Type: turtlesv1.RancherClusterReadyCondition, Status: corev1.ConditionTrue,
+				LastTransitionTime: metav1.NewTime(eligible.Add(3 * time.Minute))},
+		)
+		importing = capiCluster("importing",
+			clusterv1.Condition{Type: turtlesv1.ImportEligibleCondition, Status: corev1.ConditionTrue,
+				LastTransitionTime: metav1.NewTime(eligible)},
+			clusterv1.Condition{Type: turtlesv1.ImportManifestAppliedCondition, Status: corev1.ConditionTrue},
+		)
+
+		r = &CAPIImportReconciler{
+			Client: fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(imported, importing).
+				WithInterceptorFuncs(interceptor.Funcs{
+					Create: func(ctx context.Context, cl client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+						switch review := obj.(type) {
+						case *authenticationv1.TokenReview:
+							review.Status.Authenticated = review.Spec.Token != "unknown-token"
+							review.Status.User = authenticationv1.UserInfo{Username: review.Spec.Token}
+
+							return nil
+						case *authorizationv1.SubjectAccessReview:
+							review.Status.Allowed = review.Spec.User == "dashboard-token"
+
+							return nil
+						}
+
+						return cl.Create(ctx, obj, opts...)
+					},
+				}).Build(),
+			clock: fakeClock,
+		}
+
+		r.reconciles.observe(imported, nil, fakeClock.Now(), time.Hour)
+		r.reconciles.observe(importing, errors.New("rancher unavailable"), fakeClock.Now(), time.Hour)
+		r.reconciles.start(client.ObjectKeyFromObject(importing), fakeClock.Now())
+	})
+
+	It("should list the import status of the reconciled clusters", func() {
+		rec := get(prefix, "dashboard-token")
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		Expect(rec.Header().Get("Content-Type")).To(Equal("application/json"))
+
+		list := ImportStatusList{}
+		Expect(json.Unmarshal(rec.Body.Bytes(), &list)).To(Succeed())
+		Expect(list.Items).To(HaveLen(2))
+
+		Expect(list.Items[0].Cluster).To(Equal("test-ns/imported"))
+		Expect(list.Items[0].Phase).To(Equal(ImportPhaseImported))
+		Expect(list.Items[0].ImportDurationSeconds).To(HaveValue(BeEquivalentTo(180)))
+		Expect(list.Items[0].ImportedAt).To(HaveValue(BeTemporally("==", eligible.Add(3*time.Minute))))
+		Expect(list.Items[0].InFlightSince).To(BeNil())
+		Expect(list.Items[0].Conditions).To(HaveLen(2))
+
+		Expect(list.Items[1].Cluster).To(Equal("test-ns/importing"))
+		Expect(list.Items[1].Phase).To(Equal(ImportPhaseRegistering))
+		Expect(list.Items[1].EligibleSince).To(HaveValue(BeTemporally("==", eligible)))
+		Expect(list.Items[1].ImportDurationSeconds).To(HaveValue(BeEquivalentTo(600)))
+		Expect(list.Items[1].ImportedAt).To(BeNil())
+		Expect(list.Items[1].InFlightSince).To(HaveValue(BeTemporally("==", fakeClock.Now())))
+		Expect(list.Items[1].LastError).To(Equal("rancher unavailable"))
+	})
+
+	It("should serve the import status of a single cluster", func() {
+		rec := get(prefix+"/test-ns/importing", "dashboard-token")
+		Expect(rec.Code).To(Equal(http.StatusOK))
+
+		status := ImportStatus{}
+		Expect(json.Unmarshal(rec.Body.Bytes(), &status)).To(Succeed())
+		Expect(status.Cluster).To(Equal("test-ns/importing"))
+		Expect(status.Phase).To(Equal(ImportPhaseRegistering))
+	})
+
+	It("should not find clusters which were not reconciled", func() {
+		Expect(get(prefix+"/test-ns/unknown", "dashboard-token").Code).To(Equal(http.StatusNotFound))
+		Expect(get(prefix+"/test-ns", "dashboard-token").Code).To(Equal(http.StatusNotFound))
+		Expect(get(prefix+"/test-ns/importing/extra", "dashboard-token").Code).To(Equal(http.StatusNotFound))
+	})
+
+	It("should skip the clusters gone from the cache", func() {
+		Expect(r.Client.Delete(ctx, importing)).To(Succeed())
+
+		list, err := r.ImportStatuses(ctx)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(list.Items).To(HaveLen(1))
+		Expect(list.Items[0].Cluster).To(Equal("test-ns/imported"))
+
+		Expect(get(prefix+"/test-ns/importing", "dashboard-token").Code).To(Equal(http.StatusNotFound))
+	})
+
+	It("should reject unauthenticated and unauthorized requests", func() {
+		Expect(get(prefix, "").Code).To(Equal(http.StatusUnauthorized))
+		Expect(get(prefix, "unknown-token").Code).To(Equal(http.StatusUnauthorized))
+		Expect(get(prefix, "viewer-token").Code).To(Equal(http.StatusForbidden))
+	})
+
+	It("should be read-only", func() {
+		req := httptest.NewRequest(http.MethodDelete, prefix+"/test-ns/imported", nil)
+		req.Header.Set("Authorization", "Bearer dashboard-token")
+
+		rec := httptest.NewRecorder()
+		r.StatusHandler(prefix).ServeHTTP(rec, req)
+		Expect(rec.Code).To(Equal(http.StatusMethodNotAllowed))
+		Expect(rec.Header().Get("Allow")).To(Equal(http.MethodGet))
+	})
+
+	DescribeTable("importPhase",
+		func(state ClusterReconcileState, expected ImportPhase, conds ...clusterv1.Condition) {
+			Expect(importPhase(capiCluster("test-cluster", conds...), state)).To(Equal(expected))
+		},
+		Entry("not eligible", ClusterReconcileState{}, ImportPhasePending),
+		Entry("eligible", ClusterReconcileState{}, ImportPhaseImporting,
+			clusterv1.Condition{Type: turtlesv1.ImportEligibleCondition, Status: corev1.ConditionTrue}),
+		Entry("backing off", ClusterReconcileState{BackingOff: true}, ImportPhaseBackingOff,
+			clusterv1.Condition{Type: turtlesv1.ImportEligibleCondition, Status: corev1.ConditionTrue}),
+		Entry("degraded", ClusterReconcileState{}, ImportPhaseDegraded,
+			clusterv1.Condition{Type: turtlesv1.ImportEligibleCondition, Status: corev1.ConditionTrue},
+			clusterv1.Condition{Type: turtlesv1.ImportDegradedCondition, Status: corev1.ConditionTrue}),
+		Entry("imported", ClusterReconcileState{}, ImportPhaseImported,
+			clusterv1.Condition{Type: turtlesv1.RancherClusterReadyCondition, Status: corev1.ConditionTrue}),
+	)
+})
@@ -0,0 +1,150 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	turtlesv1 "github.com/rancher/turtles/api/v1alpha1"
+	provisioningv1 "github.com/rancher/turtles/internal/rancher/provisioning/v1"
+	turtlesannotations "github.com/rancher/turtles/util/annotations"
+)
+
+// reconcileSuspension scales the cattle-cluster-agent down on the downstream cluster while the CAPI cluster carries
+// the ImportSuspendedAnnotation, and restores its replicas once the annotation is removed. The Rancher cluster is
+// left in place. It returns true while the import is suspended.
+func (r *CAPIImportReconciler) reconcileSuspension(ctx context.Context, capiCluster *clusterv1.Cluster,
+	rancherCluster *provisioningv1.Cluster,
+) (bool, error) {
+	suspended := turtlesannotations.HasAnnotation(capiCluster, turtlesannotations.ImportSuspendedAnnotation)
+	wasSuspended := conditions.GetReason(capiCluster, turtlesv1.RancherAgentActiveCondition) == turtlesv1.ImportSuspendedReason
+
+	if !suspended && !wasSuspended {
+		return false, nil
+	}
+
+	remoteClient, err := r.remoteClient(ctx, capiCluster)
+	if err != nil {
+		return suspended, fmt.Errorf("getting remote cluster client: %w", err)
+	}
+
+	agent := &appsv1.Deployment{}
+
+	err = remoteClient.Get(ctx, client.ObjectKey{Namespace: cattleSystemNamespace, Name: cattleClusterAgentName}, agent)
+	if client.IgnoreNotFound(err) != nil {
+		return suspended, fmt.Errorf("getting cattle-cluster-agent deployment: %w", err)
+	}
+
+	agentFound := !apierrors.IsNotFound(err)
+
+	if suspended {
+		if agentFound {
+			if err := suspendAgent(ctx, remoteClient, agent); err != nil {
+				return true, err
+			}
+		}
+
+		if !wasSuspended {
+			r.recorder.Event(capiCluster, corev1.EventTypeNormal, turtlesv1.ImportSuspendedReason,
+				"Import suspended, cattle-cluster-agent scaled down")
+
+			if err := r.recordTimeline(ctx, rancherCluster, timelinePhaseSuspended, "cattle-cluster-agent scaled down"); err != nil {
+				return true, err
+			}
+		}
+
+		conditions.MarkFalse(capiCluster, turtlesv1.RancherAgentActiveCondition, turtlesv1.ImportSuspendedReason,
+			clusterv1.ConditionSeverityInfo, "Import suspended by the %s annotation", turtlesannotations.ImportSuspendedAnnotation)
+
+		return true, nil
+	}
+
+	if agentFound {
+		if err := resumeAgent(ctx, remoteClient, agent); err != nil {
+			return false, err
+		}
+	}
+
+	if err := r.recordTimeline(ctx, rancherCluster, timelinePhaseResumed, "cattle-cluster-agent restored"); err != nil {
+		return false, err
+	}
+
+	r.recorder.Event(capiCluster, corev1.EventTypeNormal, turtlesv1.ImportResumedReason, "Import resumed, cattle-cluster-agent restored")
+	conditions.MarkTrue(capiCluster, turtlesv1.RancherAgentActiveCondition)
+
+	return false, nil
+}
+
+// suspendAgent scales the agent deployment down to zero, recording its replicas to restore them on resume.
+func suspendAgent(ctx context.Context, remoteClient client.Client, agent *appsv1.Deployment) error {
+	if ptr.Deref(agent.Spec.Replicas, 1) == 0 {
+		return nil
+	}
+
+	patchBase := client.MergeFrom(agent.DeepCopy())
+
+	setAnnotation(agent, turtlesannotations.SuspendedReplicasAnnotation, strconv.Itoa(int(ptr.Deref(agent.Spec.Replicas, 1))))
+	agent.Spec.Replicas = ptr.To[int32](0)
+
+	if err := remoteClient.Patch(ctx, agent, patchBase); err != nil {
+		return fmt.Errorf("scaling down cattle-cluster-agent: %w", err)
+	}
+
+	log.FromContext(ctx).Info("scaled down cattle-cluster-agent, import suspended")
+
+	return nil
+}
+
+// resumeAgent restores the replicas of the agent deployment recorded when it was suspended.
+func resumeAgent(ctx context.Context, remoteClient client.Client, agent *appsv1.Deployment) error {
+	recorded, ok := agent.GetAnnotations()[turtlesannotations.SuspendedReplicasAnnotation]
+	if !ok {
+		return nil
+	}
+
+	replicas, err := strconv.ParseInt(recorded, 10, 32)
+	if err != nil || replicas < 1 {
+		replicas = 1
+	}
+
+	patchBase := client.MergeFrom(agent.DeepCopy())
+
+	annotations := agent.GetAnnotations()
+	delete(annotations, turtlesannotations.SuspendedReplicasAnnotation)
+	agent.SetAnnotations(annotations)
+	agent.Spec.Replicas = ptr.To(int32(replicas))
+
+	if err := remoteClient.Patch(ctx, agent, patchBase); err != nil {
+		return fmt.Errorf("restoring cattle-cluster-agent: %w", err)
+	}
+
+	log.FromContext(ctx).Info("restored cattle-cluster-agent, import resumed", "replicas", replicas)
+
+	return nil
+}
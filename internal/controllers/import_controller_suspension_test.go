@@ -0,0 +1,137 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	turtlesv1 "github.com/rancher/turtles/api/v1alpha1"
+	provisioningv1 "github.com/rancher/turtles/internal/rancher/provisioning/v1"
+	"github.com/rancher/turtles/testutil"
+	turtlesannotations "github.com/rancher/turtles/util/annotations"
+)
+
+var _ = Describe("import suspension", func() {
+	var (
+		r            *CAPIImportReconciler
+		capiCluster  *clusterv1.Cluster
+		remoteClient client.Client
+		recorder     *record.FakeRecorder
+	)
+
+	agentKey := client.ObjectKey{Namespace: cattleSystemNamespace, Name: cattleClusterAgentName}
+	rancherClusterKey := client.ObjectKey{Namespace: "test-ns", Name: "test-cluster-capi"}
+
+	agent := func() *appsv1.Deployment {
+		deployment := &appsv1.Deployment{}
+		Expect(remoteClient.Get(ctx, agentKey, deployment)).To(Succeed())
+
+		return deployment
+	}
+
+	BeforeEach(func() {
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test-ns"}}
+		capiCluster = &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{
+			Name:        "test-cluster",
+			Namespace:   "test-ns",
+			Labels:      map[string]string{importLabelName: "true"},
+			Annotations: map[string]string{turtlesannotations.ImportSuspendedAnnotation: ""},
+		}}
+		remoteClient = fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Namespace: agentKey.Namespace, Name: agentKey.Name},
+			Spec:       appsv1.DeploymentSpec{Replicas: ptr.To[int32](2)},
+		}).Build()
+		recorder = record.NewFakeRecorder(10)
+
+		r = &CAPIImportReconciler{
+			Client: fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(ns, capiCluster).Build(),
+			RancherClient: testutil.NewRancherClientBuilder().
+				WithCluster(rancherClusterKey.Name, rancherClusterKey.Namespace, testutil.ClusterStateReady, "").Build(),
+			recorder: recorder,
+			remoteClientGetter: func(_ context.Context, _ string, _ client.Client, _ client.ObjectKey) (client.Client, error) {
+				return remoteClient, nil
+			},
+		}
+	})
+
+	It("should scale down the agent while the import is suspended", func() {
+		res, err := r.reconcile(ctx, capiCluster)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res.IsZero()).To(BeTrue())
+
+		Expect(agent().Spec.Replicas).To(Equal(ptr.To[int32](0)))
+		Expect(agent().Annotations).To(HaveKeyWithValue(turtlesannotations.SuspendedReplicasAnnotation, "2"))
+		Expect(conditions.IsFalse(capiCluster, turtlesv1.RancherAgentActiveCondition)).To(BeTrue())
+		Expect(conditions.GetReason(capiCluster, turtlesv1.RancherAgentActiveCondition)).To(Equal(turtlesv1.ImportSuspendedReason))
+		Expect(recorder.Events).To(Receive(ContainSubstring(turtlesv1.ImportSuspendedReason)))
+
+		Expect(r.RancherClient.Get(ctx, rancherClusterKey, &provisioningv1.Cluster{})).To(Succeed())
+
+		_, err = r.reconcile(ctx, capiCluster)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(agent().Annotations).To(HaveKeyWithValue(turtlesannotations.SuspendedReplicasAnnotation, "2"))
+		Expect(recorder.Events).To(BeEmpty())
+	})
+
+	It("should restore the agent when the import is resumed", func() {
+		_, err := r.reconcile(ctx, capiCluster)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(agent().Spec.Replicas).To(Equal(ptr.To[int32](0)))
+		Expect(recorder.Events).To(Receive(ContainSubstring(turtlesv1.ImportSuspendedReason)))
+
+		delete(capiCluster.Annotations, turtlesannotations.ImportSuspendedAnnotation)
+
+		_, err = r.reconcile(ctx, capiCluster)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(agent().Spec.Replicas).To(Equal(ptr.To[int32](2)))
+		Expect(agent().Annotations).ToNot(HaveKey(turtlesannotations.SuspendedReplicasAnnotation))
+		Expect(conditions.IsTrue(capiCluster, turtlesv1.RancherAgentActiveCondition)).To(BeTrue())
+		Expect(recorder.Events).To(Receive(ContainSubstring(turtlesv1.ImportResumedReason)))
+	})
+
+	It("should suspend clusters whose agent is not deployed yet", func() {
+		Expect(remoteClient.Delete(ctx, agent())).To(Succeed())
+
+		_, err := r.reconcile(ctx, capiCluster)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(conditions.GetReason(capiCluster, turtlesv1.RancherAgentActiveCondition)).To(Equal(turtlesv1.ImportSuspendedReason))
+	})
+
+	It("should not touch the downstream cluster when the import was never suspended", func() {
+		delete(capiCluster.Annotations, turtlesannotations.ImportSuspendedAnnotation)
+		r.remoteClientGetter = nil
+
+		suspended, err := r.reconcileSuspension(ctx, capiCluster, &provisioningv1.Cluster{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(suspended).To(BeFalse())
+		Expect(conditions.Get(capiCluster, turtlesv1.RancherAgentActiveCondition)).To(BeNil())
+	})
+})
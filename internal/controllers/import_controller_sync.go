@@ -0,0 +1,50 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+
+	provisioningv1 "github.com/rancher/turtles/internal/rancher/provisioning/v1"
+)
+
+// syncRancherCluster keeps the metadata and the deletion protection of the Rancher cluster in sync with the CAPI
+// cluster, stopping at the first hook failing.
+func (r *CAPIImportReconciler) syncRancherCluster(ctx context.Context, capiCluster *clusterv1.Cluster,
+	rancherCluster *provisioningv1.Cluster,
+) error {
+	hooks := []func(context.Context, *clusterv1.Cluster, *provisioningv1.Cluster) error{
+		r.syncAnnotations,
+		r.syncLabels,
+		r.syncTopologyLabels,
+		r.syncVariableLabels,
+		r.syncAccessLabels,
+		func(ctx context.Context, _ *clusterv1.Cluster, rancherCluster *provisioningv1.Cluster) error {
+			return r.syncDeletionProtection(ctx, rancherCluster)
+		},
+	}
+
+	for _, hook := range hooks {
+		if err := hook(ctx, capiCluster, rancherCluster); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,129 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	turtlesv1 "github.com/rancher/turtles/api/v1alpha1"
+	turtlesannotations "github.com/rancher/turtles/util/annotations"
+)
+
+// cattleServerEnv is the environment variable of the cattle-cluster-agent holding the Rancher server-url.
+const cattleServerEnv = "CATTLE_SERVER"
+
+// errManagedByOtherRancher is returned when the downstream cluster runs a cattle-cluster-agent registered with
+// another Rancher server.
+var errManagedByOtherRancher = errors.New("downstream cluster is managed by another Rancher")
+
+// checkRancherTakeover refuses to apply the import manifest objects to a downstream cluster whose cattle-cluster-agent
+// is registered with another Rancher server, unless the CAPI cluster has the AllowRancherTakeoverAnnotation. Applying
+// the agent of a second Rancher would take the cluster over from the first one.
+func (r *CAPIImportReconciler) checkRancherTakeover(ctx context.Context, capiCluster *clusterv1.Cluster,
+	remoteClient client.Client, objs []*unstructured.Unstructured,
+) error {
+	log := log.FromContext(ctx)
+
+	serverURL, err := manifestServerURL(objs)
+	if err != nil || serverURL == "" {
+		return err
+	}
+
+	agent := &appsv1.Deployment{}
+
+	err = remoteClient.Get(ctx, client.ObjectKey{Namespace: cattleSystemNamespace, Name: cattleClusterAgentName}, agent)
+	if client.IgnoreNotFound(err) != nil {
+		return fmt.Errorf("getting cattle-cluster-agent deployment: %w", err)
+	}
+
+	// a missing deployment has no server-url
+	currentURL := agentServerURL(&agent.Spec.Template.Spec)
+	if currentURL == "" || sameServerURL(currentURL, serverURL) {
+		if conditions.Has(capiCluster, turtlesv1.ExclusiveManagementCondition) {
+			conditions.MarkTrue(capiCluster, turtlesv1.ExclusiveManagementCondition)
+		}
+
+		return nil
+	}
+
+	if turtlesannotations.HasAnnotation(capiCluster, turtlesannotations.AllowRancherTakeoverAnnotation) {
+		log.Info("Taking over downstream cluster managed by another Rancher", "server", currentURL)
+		conditions.MarkTrue(capiCluster, turtlesv1.ExclusiveManagementCondition)
+
+		return nil
+	}
+
+	message := fmt.Sprintf("Downstream cluster is managed by the Rancher server %s. Set the %s annotation to import it anyway",
+		currentURL, turtlesannotations.AllowRancherTakeoverAnnotation)
+
+	log.Info("Downstream cluster is managed by another Rancher, not applying import manifest", "server", currentURL)
+	conditions.MarkFalse(capiCluster, turtlesv1.ExclusiveManagementCondition, turtlesv1.ManagedByOtherRancherReason,
+		clusterv1.ConditionSeverityError, "%s", message)
+	r.recorder.Event(capiCluster, corev1.EventTypeWarning, turtlesv1.ManagedByOtherRancherReason, message)
+
+	return fmt.Errorf("%w: %s", errManagedByOtherRancher, currentURL)
+}
+
+// manifestServerURL returns the Rancher server-url the cattle-cluster-agent deployment of the manifest registers with,
+// or an empty string when the manifest has no agent deployment.
+func manifestServerURL(objs []*unstructured.Unstructured) (string, error) {
+	for _, obj := range objs {
+		if obj.GroupVersionKind().GroupKind().String() != "Deployment.apps" ||
+			obj.GetName() != cattleClusterAgentName || obj.GetNamespace() != cattleSystemNamespace {
+			continue
+		}
+
+		agent := &appsv1.Deployment{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, agent); err != nil {
+			return "", fmt.Errorf("converting cattle-cluster-agent deployment: %w", err)
+		}
+
+		return agentServerURL(&agent.Spec.Template.Spec), nil
+	}
+
+	return "", nil
+}
+
+// agentServerURL returns the CATTLE_SERVER environment variable of the agent pod spec.
+func agentServerURL(podSpec *corev1.PodSpec) string {
+	for _, container := range podSpec.Containers {
+		for _, env := range container.Env {
+			if env.Name == cattleServerEnv {
+				return env.Value
+			}
+		}
+	}
+
+	return ""
+}
+
+// sameServerURL compares two Rancher server-urls, ignoring a trailing slash and case.
+func sameServerURL(a, b string) bool {
+	return strings.EqualFold(strings.TrimSuffix(a, "/"), strings.TrimSuffix(b, "/"))
+}
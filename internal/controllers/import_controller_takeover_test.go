@@ -0,0 +1,178 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	turtlesv1 "github.com/rancher/turtles/api/v1alpha1"
+	provisioningv1 "github.com/rancher/turtles/internal/rancher/provisioning/v1"
+	"github.com/rancher/turtles/testutil"
+	turtlesannotations "github.com/rancher/turtles/util/annotations"
+)
+
+const agentManifest = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cattle-config
+  namespace: cattle-system
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: cattle-cluster-agent
+  namespace: cattle-system
+spec:
+  selector:
+    matchLabels:
+      app: cattle-cluster-agent
+  template:
+    metadata:
+      labels:
+        app: cattle-cluster-agent
+    spec:
+      containers:
+      - name: cluster-register
+        image: rancher/rancher-agent:v2.8.0
+        env:
+        - name: CATTLE_SERVER
+          value: https://rancher.example.com
+`
+
+var _ = Describe("downstream cluster managed by another Rancher", func() {
+	var (
+		r              *CAPIImportReconciler
+		server         *testutil.ManifestServer
+		recorder       *record.FakeRecorder
+		remoteClient   client.Client
+		capiCluster    *clusterv1.Cluster
+		rancherCluster *provisioningv1.Cluster
+	)
+
+	existingAgent := func(serverURL string) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: cattleClusterAgentName, Namespace: cattleSystemNamespace},
+			Spec: appsv1.DeploymentSpec{
+				Template: corev1.PodTemplateSpec{
+					Spec: corev1.PodSpec{
+						Containers: []corev1.Container{{
+							Name: "cluster-register",
+							Env:  []corev1.EnvVar{{Name: cattleServerEnv, Value: serverURL}},
+						}},
+					},
+				},
+			},
+		}
+	}
+
+	configApplied := func() bool {
+		err := remoteClient.Get(ctx, client.ObjectKey{Namespace: cattleSystemNamespace, Name: "cattle-config"}, &corev1.ConfigMap{})
+		if apierrors.IsNotFound(err) {
+			return false
+		}
+
+		Expect(err).ToNot(HaveOccurred())
+
+		return true
+	}
+
+	BeforeEach(func() {
+		server = testutil.NewManifestServer(agentManifest)
+		recorder = record.NewFakeRecorder(10)
+
+		r = &CAPIImportReconciler{
+			RancherClient: testutil.NewRancherClientBuilder().WithObjects(
+				testutil.RegistrationToken("c-m-test", "test-ns", server.URL),
+			).Build(),
+			recorder: recorder,
+			remoteClientGetter: func(_ context.Context, _ string, _ client.Client, _ client.ObjectKey) (client.Client, error) {
+				return remoteClient, nil
+			},
+		}
+
+		capiCluster = &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", Namespace: "test-ns"}}
+		rancherCluster = &provisioningv1.Cluster{Status: provisioningv1.ClusterStatus{ClusterName: "c-m-test"}}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("should refuse to apply the import manifest", func() {
+		remoteClient = fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(existingAgent("https://other-rancher.example.com")).Build()
+
+		_, err := r.applyImportManifest(ctx, capiCluster, rancherCluster)
+		Expect(err).To(MatchError(errManagedByOtherRancher))
+		Expect(configApplied()).To(BeFalse())
+
+		Expect(conditions.IsFalse(capiCluster, turtlesv1.ExclusiveManagementCondition)).To(BeTrue())
+		Expect(conditions.GetReason(capiCluster, turtlesv1.ExclusiveManagementCondition)).To(Equal(turtlesv1.ManagedByOtherRancherReason))
+		Expect(conditions.GetMessage(capiCluster, turtlesv1.ExclusiveManagementCondition)).To(ContainSubstring("https://other-rancher.example.com"))
+		Expect(recorder.Events).To(Receive(ContainSubstring(turtlesv1.ManagedByOtherRancherReason)))
+
+		agent := &appsv1.Deployment{}
+		Expect(remoteClient.Get(ctx, client.ObjectKey{Namespace: cattleSystemNamespace, Name: cattleClusterAgentName}, agent)).To(Succeed())
+		Expect(agentServerURL(&agent.Spec.Template.Spec)).To(Equal("https://other-rancher.example.com"))
+	})
+
+	It("should take the cluster over with the override annotation", func() {
+		remoteClient = fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(existingAgent("https://other-rancher.example.com")).Build()
+
+		_, err := r.applyImportManifest(ctx, capiCluster, rancherCluster)
+		Expect(err).To(HaveOccurred())
+
+		capiCluster.Annotations = map[string]string{turtlesannotations.AllowRancherTakeoverAnnotation: "true"}
+
+		applied, err := r.applyImportManifest(ctx, capiCluster, rancherCluster)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(applied).To(BeTrue())
+		Expect(configApplied()).To(BeTrue())
+		Expect(conditions.IsTrue(capiCluster, turtlesv1.ExclusiveManagementCondition)).To(BeTrue())
+	})
+
+	It("should re-apply to a cluster registered with the same Rancher", func() {
+		remoteClient = fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(existingAgent("https://Rancher.example.com/")).Build()
+
+		applied, err := r.applyImportManifest(ctx, capiCluster, rancherCluster)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(applied).To(BeTrue())
+		Expect(configApplied()).To(BeTrue())
+		Expect(conditions.Has(capiCluster, turtlesv1.ExclusiveManagementCondition)).To(BeFalse())
+	})
+
+	It("should apply to a cluster without an agent", func() {
+		remoteClient = fake.NewClientBuilder().WithScheme(scheme.Scheme).Build()
+
+		applied, err := r.applyImportManifest(ctx, capiCluster, rancherCluster)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(applied).To(BeTrue())
+		Expect(configApplied()).To(BeTrue())
+	})
+})
@@ -137,7 +137,7 @@ var _ = Describe("reconcile CAPI Cluster", func() {
 				},
 			})
 			g.Expect(err).ToNot(HaveOccurred())
-			g.Expect(res.RequeueAfter).To(Equal(defaultRequeueDuration))
+			g.Expect(res.RequeueAfter).To(BeNumerically(">=", minRequeueDuration))
 		})
 	})
 
@@ -157,7 +157,7 @@ var _ = Describe("reconcile CAPI Cluster", func() {
 				},
 			})
 			g.Expect(err).ToNot(HaveOccurred())
-			g.Expect(res.Requeue).To(BeTrue())
+			g.Expect(res.RequeueAfter).To(BeNumerically(">=", minRequeueDuration))
 		}).Should(Succeed())
 
 		Eventually(testEnv.GetAs(rancherCluster, &provisioningv1.Cluster{})).ShouldNot(BeNil())
@@ -176,7 +176,7 @@ var _ = Describe("reconcile CAPI Cluster", func() {
 				},
 			})
 			g.Expect(err).ToNot(HaveOccurred())
-			g.Expect(res.Requeue).To(BeTrue())
+			g.Expect(res.RequeueAfter).To(BeNumerically(">=", minRequeueDuration))
 		}).Should(Succeed())
 
 		Eventually(testEnv.GetAs(rancherCluster, &provisioningv1.Cluster{})).ShouldNot(BeNil())
@@ -247,7 +247,7 @@ var _ = Describe("reconcile CAPI Cluster", func() {
 				},
 			})
 			g.Expect(err).ToNot(HaveOccurred())
-			g.Expect(res.Requeue).To(BeTrue())
+			g.Expect(res.RequeueAfter).To(BeNumerically(">=", minRequeueDuration))
 		}).Should(Succeed())
 	})
 
@@ -301,7 +301,7 @@ var _ = Describe("reconcile CAPI Cluster", func() {
 				},
 			})
 			g.Expect(err).ToNot(HaveOccurred())
-			g.Expect(res.Requeue).To(BeTrue())
+			g.Expect(res.RequeueAfter).To(BeNumerically(">=", minRequeueDuration))
 		}).Should(Succeed())
 	})
 
@@ -331,7 +331,7 @@ var _ = Describe("reconcile CAPI Cluster", func() {
 				},
 			})
 			g.Expect(err).ToNot(HaveOccurred())
-			g.Expect(res.Requeue).To(BeTrue())
+			g.Expect(res.RequeueAfter).To(BeNumerically(">=", minRequeueDuration))
 			g.Expect(cl.Get(ctx, client.ObjectKeyFromObject(clusterRegistrationToken), clusterRegistrationToken)).ToNot(HaveOccurred())
 		}).Should(Succeed())
 	})
@@ -358,7 +358,7 @@ var _ = Describe("reconcile CAPI Cluster", func() {
 				},
 			})
 			g.Expect(err).ToNot(HaveOccurred())
-			g.Expect(res.Requeue).To(BeTrue())
+			g.Expect(res.RequeueAfter).To(BeNumerically(">=", minRequeueDuration))
 		}).Should(Succeed())
 	})
 })
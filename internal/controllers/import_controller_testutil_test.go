@@ -0,0 +1,98 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/rancher/turtles/internal/controllers/testdata"
+	provisioningv1 "github.com/rancher/turtles/internal/rancher/provisioning/v1"
+	"github.com/rancher/turtles/testutil"
+)
+
+var _ = Describe("reconcile with fake rancher client", func() {
+	var (
+		capiCluster  *clusterv1.Cluster
+		server       *testutil.ManifestServer
+		remoteClient client.Client
+	)
+
+	BeforeEach(func() {
+		capiCluster = &clusterv1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", Namespace: "test-ns"},
+		}
+
+		server = testutil.NewManifestServer(setTemplateParams(testdata.ImportManifest, map[string]string{"${TEST_CASE_NAME}": "testutil"}))
+		remoteClient = fake.NewClientBuilder().WithScheme(testutil.NewScheme()).Build()
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	reconcile := func(state testutil.ClusterState) ctrl.Result {
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test-ns"}}
+
+		r := &CAPIImportReconciler{
+			Client: fake.NewClientBuilder().WithScheme(testutil.NewScheme()).WithObjects(ns).Build(),
+			RancherClient: testutil.NewRancherClientBuilder().WithCluster("test-cluster-capi", "test-ns", state, server.URL).
+				WithObjects(ns.DeepCopy()).Build(),
+			remoteClientGetter: func(_ context.Context, _ string, _ client.Client, _ client.ObjectKey) (client.Client, error) {
+				return remoteClient, nil
+			},
+		}
+
+		rancherCluster := &provisioningv1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-cluster-capi", Namespace: "test-ns"},
+		}
+
+		res, err := r.reconcileNormal(ctx, capiCluster, rancherCluster)
+		Expect(err).ToNot(HaveOccurred())
+
+		return res
+	}
+
+	It("should requeue when the cluster name is not set", func() {
+		Expect(reconcile(testutil.ClusterStateNoName).RequeueAfter).To(Equal(minRequeueDuration))
+		Expect(server.Requests()).To(BeZero())
+	})
+
+	It("should apply the manifest when the cluster name is set", func() {
+		Expect(reconcile(testutil.ClusterStateNameSet)).To(Equal(ctrl.Result{}))
+		Expect(server.Requests()).To(Equal(1))
+
+		Expect(remoteClient.Get(ctx, client.ObjectKey{Name: "cattle-system-testutil"}, &corev1.Namespace{})).To(Succeed())
+	})
+
+	DescribeTable("should not download the manifest once the agent is deployed",
+		func(state testutil.ClusterState) {
+			Expect(reconcile(state)).To(Equal(ctrl.Result{}))
+			Expect(server.Requests()).To(BeZero())
+		},
+		Entry("agent deployed", testutil.ClusterStateAgentDeployed),
+		Entry("ready", testutil.ClusterStateReady),
+	)
+})
@@ -0,0 +1,129 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	provisioningv1 "github.com/rancher/turtles/internal/rancher/provisioning/v1"
+)
+
+const (
+	timelineConfigMapSuffix = "-import-timeline"
+	timelineDataKey         = "timeline"
+
+	timelinePhaseCreated         = "RancherClusterCreated"
+	timelinePhaseManifestApplied = "ManifestApplied"
+	timelinePhaseSuspended       = "ImportSuspended"
+	timelinePhaseResumed         = "ImportResumed"
+)
+
+// timelineEntry is an import lifecycle entry of the timeline of a Rancher cluster.
+type timelineEntry struct {
+	Time    metav1.Time `json:"time"`
+	Phase   string      `json:"phase"`
+	Message string      `json:"message"`
+}
+
+// timelineConfigMapName returns the name of the config map holding the timeline of the Rancher cluster.
+func timelineConfigMapName(rancherClusterName string) string {
+	return rancherClusterName + timelineConfigMapSuffix
+}
+
+// recordTimeline appends an entry to the timeline config map of the Rancher cluster, creating it owned by the Rancher
+// cluster when missing. Only the last TimelineEntries entries are kept.
+func (r *CAPIImportReconciler) recordTimeline(ctx context.Context, rancherCluster *provisioningv1.Cluster,
+	phase, message string,
+) error {
+	if r.TimelineEntries <= 0 {
+		return nil
+	}
+
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+		Namespace: rancherCluster.Namespace,
+		Name:      timelineConfigMapName(rancherCluster.Name),
+	}}
+
+	err := r.RancherClient.Get(ctx, client.ObjectKeyFromObject(cm), cm)
+	if client.IgnoreNotFound(err) != nil {
+		return fmt.Errorf("getting import timeline: %w", err)
+	}
+
+	exists := !apierrors.IsNotFound(err)
+
+	entries := []timelineEntry{}
+
+	if data := cm.Data[timelineDataKey]; data != "" {
+		if err := json.Unmarshal([]byte(data), &entries); err != nil {
+			log.FromContext(ctx).Error(err, "discarding unreadable import timeline", "configMap", client.ObjectKeyFromObject(cm))
+
+			entries = []timelineEntry{}
+		}
+	}
+
+	entries = append(entries, timelineEntry{
+		Time:    metav1.NewTime(r.now().UTC()),
+		Phase:   phase,
+		Message: message,
+	})
+
+	if len(entries) > r.TimelineEntries {
+		entries = entries[len(entries)-r.TimelineEntries:]
+	}
+
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return fmt.Errorf("encoding import timeline: %w", err)
+	}
+
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+
+	cm.Data[timelineDataKey] = string(data)
+
+	if exists {
+		if err := r.RancherClient.Update(ctx, cm); err != nil {
+			return fmt.Errorf("updating import timeline: %w", err)
+		}
+
+		return nil
+	}
+
+	gvk, err := apiutil.GVKForObject(rancherCluster, r.RancherClient.Scheme())
+	if err != nil {
+		return fmt.Errorf("getting Rancher cluster kind: %w", err)
+	}
+
+	cm.Labels = map[string]string{r.ownedLabel(): ""}
+	cm.OwnerReferences = []metav1.OwnerReference{*metav1.NewControllerRef(rancherCluster, gvk)}
+
+	if err := r.RancherClient.Create(ctx, cm); err != nil {
+		return fmt.Errorf("creating import timeline: %w", err)
+	}
+
+	return nil
+}
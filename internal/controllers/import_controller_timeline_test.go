@@ -0,0 +1,136 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	clocktesting "k8s.io/utils/clock/testing"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	provisioningv1 "github.com/rancher/turtles/internal/rancher/provisioning/v1"
+	"github.com/rancher/turtles/testutil"
+)
+
+var _ = Describe("import timeline", func() {
+	var (
+		r           *CAPIImportReconciler
+		capiCluster *clusterv1.Cluster
+		fakeClock   *clocktesting.FakeClock
+	)
+
+	rancherClusterKey := client.ObjectKey{Namespace: "test-ns", Name: "test-cluster-capi"}
+	timelineKey := client.ObjectKey{Namespace: "test-ns", Name: "test-cluster-capi-import-timeline"}
+
+	timeline := func() []timelineEntry {
+		cm := &corev1.ConfigMap{}
+		Expect(r.RancherClient.Get(ctx, timelineKey, cm)).To(Succeed())
+
+		entries := []timelineEntry{}
+		Expect(json.Unmarshal([]byte(cm.Data[timelineDataKey]), &entries)).To(Succeed())
+
+		return entries
+	}
+
+	BeforeEach(func() {
+		fakeClock = clocktesting.NewFakeClock(time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC))
+
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test-ns"}}
+		capiCluster = &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-cluster",
+			Namespace: "test-ns",
+			Labels:    map[string]string{importLabelName: "true"},
+		}}
+
+		r = &CAPIImportReconciler{
+			Client:        fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(ns, capiCluster).Build(),
+			RancherClient: testutil.NewRancherClientBuilder().WithObjects(ns.DeepCopy()).Build(),
+			clock:         fakeClock,
+			RancherClusterOptions: RancherClusterOptions{
+				TimelineEntries: 3,
+			},
+		}
+	})
+
+	It("should record the creation of the Rancher cluster in a config map it owns", func() {
+		_, err := r.reconcile(ctx, capiCluster)
+		Expect(err).ToNot(HaveOccurred())
+
+		rancherCluster := &provisioningv1.Cluster{}
+		Expect(r.RancherClient.Get(ctx, rancherClusterKey, rancherCluster)).To(Succeed())
+
+		cm := &corev1.ConfigMap{}
+		Expect(r.RancherClient.Get(ctx, timelineKey, cm)).To(Succeed())
+		Expect(cm.OwnerReferences).To(ConsistOf(And(
+			HaveField("APIVersion", "provisioning.cattle.io/v1"),
+			HaveField("Kind", "Cluster"),
+			HaveField("Name", rancherClusterKey.Name),
+			HaveField("UID", rancherCluster.UID),
+		)))
+
+		entries := timeline()
+		Expect(entries).To(HaveLen(1))
+		Expect(entries[0].Phase).To(Equal(timelinePhaseCreated))
+		Expect(entries[0].Message).To(Equal("Created for CAPI cluster test-ns/test-cluster"))
+		Expect(entries[0].Time.Time).To(BeTemporally("==", fakeClock.Now()))
+	})
+
+	It("should only keep the most recent entries", func() {
+		rancherCluster := testutil.RancherCluster(rancherClusterKey.Name, rancherClusterKey.Namespace, testutil.ClusterStateReady)
+
+		for i := 1; i <= 5; i++ {
+			fakeClock.Step(time.Minute)
+			Expect(r.recordTimeline(ctx, rancherCluster, timelinePhaseManifestApplied, fmt.Sprintf("entry %d", i))).To(Succeed())
+		}
+
+		entries := timeline()
+		Expect(entries).To(HaveLen(3))
+		Expect(entries).To(HaveEach(HaveField("Phase", timelinePhaseManifestApplied)))
+		Expect([]string{entries[0].Message, entries[1].Message, entries[2].Message}).To(Equal([]string{"entry 3", "entry 4", "entry 5"}))
+		Expect(entries[2].Time.Time).To(BeTemporally("==", fakeClock.Now()))
+	})
+
+	It("should start over from an unreadable timeline", func() {
+		rancherCluster := testutil.RancherCluster(rancherClusterKey.Name, rancherClusterKey.Namespace, testutil.ClusterStateReady)
+		Expect(r.RancherClient.Create(ctx, &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: timelineKey.Namespace, Name: timelineKey.Name},
+			Data:       map[string]string{timelineDataKey: "not json"},
+		})).To(Succeed())
+
+		Expect(r.recordTimeline(ctx, rancherCluster, timelinePhaseSuspended, "suspended")).To(Succeed())
+		Expect(timeline()).To(ConsistOf(HaveField("Phase", timelinePhaseSuspended)))
+	})
+
+	It("should not record a timeline when disabled", func() {
+		r.TimelineEntries = 0
+
+		_, err := r.reconcile(ctx, capiCluster)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(apierrors.IsNotFound(r.RancherClient.Get(ctx, timelineKey, &corev1.ConfigMap{}))).To(BeTrue())
+	})
+})
@@ -0,0 +1,147 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	turtlesv1 "github.com/rancher/turtles/api/v1alpha1"
+	managementv3 "github.com/rancher/turtles/internal/rancher/management/v3"
+	provisioningv1 "github.com/rancher/turtles/internal/rancher/provisioning/v1"
+	turtlesannotations "github.com/rancher/turtles/util/annotations"
+)
+
+// authFailures are the messages of the agent failures which denote Rancher rejecting the registration token, e.g.
+// because it was revoked between the download and the apply of the registration manifest.
+var authFailures = []string{
+	"unauthorized",
+	"forbidden",
+	"invalid token",
+}
+
+// isAuthFailure returns true if the failure reason of the agent denotes a rejected registration token.
+func isAuthFailure(reason string) bool {
+	reason = strings.ToLower(reason)
+
+	for _, failure := range authFailures {
+		if strings.Contains(reason, failure) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// refreshRegistrationToken replaces the registration token of a cluster whose agent is rejected by Rancher, so that
+// a fresh registration manifest is applied once Rancher set its URL. It returns false without refreshing when the
+// MaxTokenRefreshes budget is disabled or consumed since the cluster last registered.
+func (r *CAPIImportReconciler) refreshRegistrationToken(ctx context.Context, capiCluster *clusterv1.Cluster,
+	rancherCluster *provisioningv1.Cluster, reason string,
+) (bool, error) {
+	log := log.FromContext(ctx)
+
+	if r.MaxTokenRefreshes <= 0 || !isAuthFailure(reason) {
+		return false, nil
+	}
+
+	refreshes, err := strconv.Atoi(capiCluster.GetAnnotations()[turtlesannotations.TokenRefreshesAnnotation])
+	if err != nil {
+		refreshes = 0
+	}
+
+	if refreshes >= r.MaxTokenRefreshes {
+		log.Info("Registration token refresh budget consumed, not refreshing", "refreshes", refreshes)
+		return false, nil
+	}
+
+	token := &managementv3.ClusterRegistrationToken{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      r.rancherClusterStatus(rancherCluster).ClusterName,
+			Namespace: r.rancherClusterNamespace(capiCluster),
+		},
+	}
+
+	if err := r.RancherClient.Delete(ctx, token); err != nil && !apierrors.IsNotFound(err) {
+		return false, fmt.Errorf("deleting rejected registration token for cluster %s: %w", r.rancherClusterStatus(rancherCluster).ClusterName, err)
+	}
+
+	if _, err := ensureRegistrationToken(ctx, r.RancherClient, r.rancherClusterStatus(rancherCluster).ClusterName,
+		r.rancherClusterNamespace(capiCluster)); err != nil {
+		return false, err
+	}
+
+	refreshes++
+
+	setAnnotation(capiCluster, turtlesannotations.TokenRefreshesAnnotation, strconv.Itoa(refreshes))
+
+	message := fmt.Sprintf("Agent registration was rejected by Rancher, refreshed the registration token (%d/%d): %s",
+		refreshes, r.MaxTokenRefreshes, reason)
+
+	log.Info("Refreshed rejected registration token", "refreshes", refreshes, "reason", reason)
+	conditions.MarkFalse(capiCluster, turtlesv1.RancherAgentRegisteredCondition, turtlesv1.RegistrationTokenRefreshedReason,
+		clusterv1.ConditionSeverityWarning, "%s", message)
+	r.recorder.Event(capiCluster, corev1.EventTypeWarning, turtlesv1.RegistrationTokenRefreshedReason, message)
+
+	return true, nil
+}
+
+// applyRefreshedManifest applies the registration manifest of the refreshed registration token, and restarts the
+// registration window once it was applied. The apply is retried until Rancher set the manifest URL of the new token.
+func (r *CAPIImportReconciler) applyRefreshedManifest(ctx context.Context, capiCluster *clusterv1.Cluster,
+	rancherCluster *provisioningv1.Cluster,
+) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	applied, err := r.applyImportManifest(ctx, capiCluster, rancherCluster)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("applying refreshed registration manifest: %w", err)
+	}
+
+	if !applied {
+		log.Info("Refreshed registration token manifest URL not set yet, requeue")
+		return r.requeueWaitingOnRancher(capiCluster, turtlesv1.WaitingForManifestURLReason), nil
+	}
+
+	log.Info("Applied registration manifest with the refreshed token")
+
+	// Reset the condition so the registration window starts from this apply.
+	conditions.Delete(capiCluster, turtlesv1.RancherAgentRegisteredCondition)
+	conditions.MarkFalse(capiCluster, turtlesv1.RancherAgentRegisteredCondition, turtlesv1.WaitingForAgentRegistrationReason,
+		clusterv1.ConditionSeverityInfo, "Import manifest applied with a refreshed registration token, waiting for the agent to register with Rancher")
+
+	return ctrl.Result{RequeueAfter: r.RegistrationCheckWindow}, nil
+}
+
+// tokenReissue returns the re-issue configuration of the registration tokens, as of now.
+func (r *CAPIImportReconciler) tokenReissue() tokenReissue {
+	return tokenReissue{
+		GracePeriod: r.RegistrationTokenGracePeriod,
+		MinInterval: r.RegistrationTokenReissueInterval,
+		Now:         r.now(),
+	}
+}
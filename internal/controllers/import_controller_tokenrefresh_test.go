@@ -0,0 +1,176 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	clocktesting "k8s.io/utils/clock/testing"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	turtlesv1 "github.com/rancher/turtles/api/v1alpha1"
+	managementv3 "github.com/rancher/turtles/internal/rancher/management/v3"
+	provisioningv1 "github.com/rancher/turtles/internal/rancher/provisioning/v1"
+	"github.com/rancher/turtles/testutil"
+	turtlesannotations "github.com/rancher/turtles/util/annotations"
+)
+
+var _ = Describe("registration token refresh", func() {
+	var (
+		r              *CAPIImportReconciler
+		remoteClient   client.Client
+		recorder       *record.FakeRecorder
+		fakeClock      *clocktesting.FakeClock
+		capiCluster    *clusterv1.Cluster
+		rancherCluster *provisioningv1.Cluster
+		staleServer    *testutil.ManifestServer
+		freshServer    *testutil.ManifestServer
+	)
+
+	rejectedAgentPod := func() *corev1.Pod {
+		pod := agentPod(false)
+		pod.Status.ContainerStatuses[0].LastTerminationState.Terminated.Message = "failed to register: 401 Unauthorized"
+
+		return pod
+	}
+
+	registrationToken := func() *managementv3.ClusterRegistrationToken {
+		token := &managementv3.ClusterRegistrationToken{}
+		Expect(r.RancherClient.Get(ctx, client.ObjectKey{Name: "c-m-test", Namespace: "test-ns"}, token)).To(Succeed())
+
+		return token
+	}
+
+	registrationFailing := func() {
+		conditions.Delete(capiCluster, turtlesv1.RancherAgentRegisteredCondition)
+		conditions.Set(capiCluster, &clusterv1.Condition{
+			Type:               turtlesv1.RancherAgentRegisteredCondition,
+			Status:             corev1.ConditionFalse,
+			Reason:             turtlesv1.WaitingForAgentRegistrationReason,
+			Severity:           clusterv1.ConditionSeverityInfo,
+			LastTransitionTime: metav1.NewTime(fakeClock.Now()),
+		})
+		fakeClock.Step(2 * time.Minute)
+	}
+
+	BeforeEach(func() {
+		staleServer = testutil.NewManifestServer(manifestWithServerFields)
+		freshServer = testutil.NewManifestServer(manifestWithServerFields)
+
+		fakeClock = clocktesting.NewFakeClock(time.Now())
+		recorder = record.NewFakeRecorder(10)
+		remoteClient = fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(rejectedAgentPod()).Build()
+
+		r = &CAPIImportReconciler{
+			RancherClient: testutil.NewRancherClientBuilder().WithObjects(
+				testutil.RegistrationToken("c-m-test", "test-ns", staleServer.URL),
+			).Build(),
+			recorder: recorder,
+			clock:    fakeClock,
+			remoteClientGetter: func(_ context.Context, _ string, _ client.Client, _ client.ObjectKey) (client.Client, error) {
+				return remoteClient, nil
+			},
+			TimingOptions: TimingOptions{
+				RegistrationCheckWindow: time.Minute,
+				MaxTokenRefreshes:       2,
+			},
+		}
+
+		capiCluster = &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", Namespace: "test-ns"}}
+		rancherCluster = &provisioningv1.Cluster{Status: provisioningv1.ClusterStatus{ClusterName: "c-m-test", AgentDeployed: true}}
+
+		registrationFailing()
+	})
+
+	AfterEach(func() {
+		staleServer.Close()
+		freshServer.Close()
+	})
+
+	It("should mint a fresh token and apply its manifest when the agent is rejected", func() {
+		res, err := r.verifyRegistration(ctx, capiCluster, rancherCluster)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res.RequeueAfter).To(Equal(minRequeueDuration))
+
+		Expect(registrationToken().Status.ManifestURL).To(BeEmpty())
+		Expect(capiCluster.GetAnnotations()).To(HaveKeyWithValue(turtlesannotations.TokenRefreshesAnnotation, "1"))
+		Expect(conditions.GetReason(capiCluster, turtlesv1.RancherAgentRegisteredCondition)).To(
+			Equal(turtlesv1.RegistrationTokenRefreshedReason))
+		Expect(recorder.Events).To(Receive(ContainSubstring(turtlesv1.RegistrationTokenRefreshedReason)))
+		Expect(staleServer.Requests()).To(BeZero())
+
+		By("applying the manifest of the fresh token once Rancher set its URL")
+		token := registrationToken()
+		token.Status.ManifestURL = freshServer.URL
+		Expect(r.RancherClient.Status().Update(ctx, token)).To(Succeed())
+
+		res, err = r.verifyRegistration(ctx, capiCluster, rancherCluster)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res.RequeueAfter).To(Equal(time.Minute))
+		Expect(freshServer.Requests()).To(Equal(1))
+		Expect(staleServer.Requests()).To(BeZero())
+		Expect(remoteClient.Get(ctx, client.ObjectKey{Name: "cattle-system"}, &corev1.Namespace{})).To(Succeed())
+		Expect(conditions.GetReason(capiCluster, turtlesv1.RancherAgentRegisteredCondition)).To(
+			Equal(turtlesv1.WaitingForAgentRegistrationReason))
+
+		By("resetting the budget once the cluster registered")
+		rancherCluster.Status.Ready = true
+
+		_, err = r.verifyRegistration(ctx, capiCluster, rancherCluster)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(capiCluster.GetAnnotations()).ToNot(HaveKey(turtlesannotations.TokenRefreshesAnnotation))
+	})
+
+	It("should stop refreshing the token once the budget is consumed", func() {
+		setAnnotation(capiCluster, turtlesannotations.TokenRefreshesAnnotation, "2")
+
+		_, err := r.verifyRegistration(ctx, capiCluster, rancherCluster)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(registrationToken().Status.ManifestURL).To(Equal(staleServer.URL))
+		Expect(conditions.GetReason(capiCluster, turtlesv1.RancherAgentRegisteredCondition)).To(
+			Equal(turtlesv1.AgentRegistrationFailedReason))
+	})
+
+	It("should not refresh the token when disabled", func() {
+		r.MaxTokenRefreshes = 0
+
+		_, err := r.verifyRegistration(ctx, capiCluster, rancherCluster)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(registrationToken().Status.ManifestURL).To(Equal(staleServer.URL))
+		Expect(capiCluster.GetAnnotations()).ToNot(HaveKey(turtlesannotations.TokenRefreshesAnnotation))
+	})
+
+	It("should not refresh the token for other agent failures", func() {
+		remoteClient = fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(agentPod(false)).Build()
+
+		_, err := r.verifyRegistration(ctx, capiCluster, rancherCluster)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(registrationToken().Status.ManifestURL).To(Equal(staleServer.URL))
+		Expect(conditions.GetReason(capiCluster, turtlesv1.RancherAgentRegisteredCondition)).To(
+			Equal(turtlesv1.AgentRegistrationFailedReason))
+	})
+})
@@ -0,0 +1,146 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+
+	provisioningv1 "github.com/rancher/turtles/internal/rancher/provisioning/v1"
+)
+
+// failureDomainRegionAttribute is the failure domain attribute holding the region of the failure domain.
+const failureDomainRegionAttribute = "region"
+
+// clusterTopology returns the region and zone of the CAPI cluster. The region is read from the infrastructure cluster
+// field configured for its kind, falling back to the region attribute shared by all failure domains. The zone is only
+// known when the cluster has a single failure domain. Empty values are returned when they can't be determined.
+func (r *CAPIImportReconciler) clusterTopology(ctx context.Context, capiCluster *clusterv1.Cluster) (string, string, error) {
+	region, err := r.infrastructureRegion(ctx, capiCluster)
+	if err != nil {
+		return "", "", err
+	}
+
+	if region == "" {
+		region = failureDomainsRegion(capiCluster.Status.FailureDomains)
+	}
+
+	zone := ""
+
+	if len(capiCluster.Status.FailureDomains) == 1 {
+		for name := range capiCluster.Status.FailureDomains {
+			zone = name
+		}
+	}
+
+	return region, zone, nil
+}
+
+// infrastructureRegion reads the region from the field configured in RegionFields for the kind of the
+// infrastructure cluster.
+func (r *CAPIImportReconciler) infrastructureRegion(ctx context.Context, capiCluster *clusterv1.Cluster) (string, error) {
+	ref := capiCluster.Spec.InfrastructureRef
+	if ref == nil {
+		return "", nil
+	}
+
+	path, ok := r.RegionFields[ref.Kind]
+	if !ok || path == "" {
+		return "", nil
+	}
+
+	namespace := ref.Namespace
+	if namespace == "" {
+		namespace = capiCluster.Namespace
+	}
+
+	infraCluster := &unstructured.Unstructured{}
+	infraCluster.SetAPIVersion(ref.APIVersion)
+	infraCluster.SetKind(ref.Kind)
+
+	if err := r.Client.Get(ctx, client.ObjectKey{Name: ref.Name, Namespace: namespace}, infraCluster); err != nil {
+		if apierrors.IsNotFound(err) {
+			return "", nil
+		}
+
+		return "", fmt.Errorf("getting infrastructure cluster: %w", err)
+	}
+
+	region, _, err := unstructured.NestedString(infraCluster.Object, strings.Split(path, ".")...)
+	if err != nil {
+		log.FromContext(ctx).V(4).Info("unable to read region from infrastructure cluster", "field", path, "error", err.Error())
+		return "", nil
+	}
+
+	return region, nil
+}
+
+// failureDomainsRegion returns the region attribute of the failure domains if they all agree on it.
+func failureDomainsRegion(failureDomains clusterv1.FailureDomains) string {
+	region := ""
+
+	for _, failureDomain := range failureDomains {
+		value := failureDomain.Attributes[failureDomainRegionAttribute]
+		if value == "" || (region != "" && region != value) {
+			return ""
+		}
+
+		region = value
+	}
+
+	return region
+}
+
+// syncTopologyLabels stamps the region and zone labels on the Rancher cluster. Labels which can't be determined
+// are left untouched, and the Rancher cluster is only patched when a value changed.
+func (r *CAPIImportReconciler) syncTopologyLabels(ctx context.Context, capiCluster *clusterv1.Cluster,
+	rancherCluster *provisioningv1.Cluster,
+) error {
+	if !r.TopologyLabels {
+		return nil
+	}
+
+	region, zone, err := r.clusterTopology(ctx, capiCluster)
+	if err != nil {
+		return err
+	}
+
+	patchBase := client.MergeFrom(rancherCluster.DeepCopy())
+	if !mergeLabels(rancherCluster, map[string]string{
+		corev1.LabelTopologyRegion: region,
+		corev1.LabelTopologyZone:   zone,
+	}) {
+		return nil
+	}
+
+	if err := r.RancherClient.Patch(ctx, rancherCluster, patchBase); err != nil {
+		return fmt.Errorf("patching Rancher cluster topology labels: %w", err)
+	}
+
+	log.FromContext(ctx).V(4).Info("set topology labels on Rancher cluster", "region", region, "zone", zone)
+
+	return nil
+}
@@ -0,0 +1,115 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/kubernetes/scheme"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	provisioningv1 "github.com/rancher/turtles/internal/rancher/provisioning/v1"
+)
+
+var _ = Describe("topology labels", func() {
+	var (
+		r              *CAPIImportReconciler
+		capiCluster    *clusterv1.Cluster
+		rancherCluster *provisioningv1.Cluster
+	)
+
+	BeforeEach(func() {
+		capiCluster = &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", Namespace: "test-ns"}}
+		rancherCluster = &provisioningv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test-cluster-capi", Namespace: "test-ns"}}
+
+		r = &CAPIImportReconciler{
+			Client:        fake.NewClientBuilder().WithScheme(scheme.Scheme).Build(),
+			RancherClient: fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(rancherCluster.DeepCopy()).Build(),
+			RancherClusterOptions: RancherClusterOptions{
+				TopologyLabels: true,
+			},
+		}
+	})
+
+	rancherLabels := func() map[string]string {
+		cluster := &provisioningv1.Cluster{}
+		Expect(r.RancherClient.Get(ctx, client.ObjectKeyFromObject(rancherCluster), cluster)).To(Succeed())
+
+		return cluster.Labels
+	}
+
+	It("should derive the region from the failure domains", func() {
+		capiCluster.Status.FailureDomains = clusterv1.FailureDomains{
+			"eu-west-1a": {Attributes: map[string]string{"region": "eu-west-1"}},
+			"eu-west-1b": {Attributes: map[string]string{"region": "eu-west-1"}},
+		}
+
+		Expect(r.syncTopologyLabels(ctx, capiCluster, rancherCluster)).To(Succeed())
+		Expect(rancherLabels()).To(HaveKeyWithValue(corev1.LabelTopologyRegion, "eu-west-1"))
+		Expect(rancherLabels()).ToNot(HaveKey(corev1.LabelTopologyZone))
+	})
+
+	It("should set the zone of a single failure domain", func() {
+		capiCluster.Status.FailureDomains = clusterv1.FailureDomains{
+			"eu-west-1a": {Attributes: map[string]string{"region": "eu-west-1"}},
+		}
+
+		Expect(r.syncTopologyLabels(ctx, capiCluster, rancherCluster)).To(Succeed())
+		Expect(rancherLabels()).To(HaveKeyWithValue(corev1.LabelTopologyRegion, "eu-west-1"))
+		Expect(rancherLabels()).To(HaveKeyWithValue(corev1.LabelTopologyZone, "eu-west-1a"))
+	})
+
+	It("should read the region from the configured infrastructure field", func() {
+		infraCluster := &unstructured.Unstructured{}
+		infraCluster.SetAPIVersion("infrastructure.cluster.x-k8s.io/v1beta2")
+		infraCluster.SetKind("AWSCluster")
+		infraCluster.SetName("test-cluster")
+		infraCluster.SetNamespace("test-ns")
+		Expect(unstructured.SetNestedField(infraCluster.Object, "us-east-2", "spec", "region")).To(Succeed())
+
+		r.Client = fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(infraCluster).Build()
+		r.RegionFields = map[string]string{"AWSCluster": "spec.region"}
+
+		capiCluster.Spec.InfrastructureRef = &corev1.ObjectReference{
+			APIVersion: "infrastructure.cluster.x-k8s.io/v1beta2",
+			Kind:       "AWSCluster",
+			Name:       "test-cluster",
+		}
+		capiCluster.Status.FailureDomains = clusterv1.FailureDomains{
+			"us-east-2a": {},
+			"us-east-2b": {},
+		}
+
+		Expect(r.syncTopologyLabels(ctx, capiCluster, rancherCluster)).To(Succeed())
+		Expect(rancherLabels()).To(HaveKeyWithValue(corev1.LabelTopologyRegion, "us-east-2"))
+	})
+
+	It("should skip the labels when the region can't be determined", func() {
+		capiCluster.Status.FailureDomains = clusterv1.FailureDomains{
+			"zone-a": {Attributes: map[string]string{"region": "one"}},
+			"zone-b": {Attributes: map[string]string{"region": "two"}},
+		}
+
+		Expect(r.syncTopologyLabels(ctx, capiCluster, rancherCluster)).To(Succeed())
+		Expect(rancherLabels()).To(BeEmpty())
+	})
+})
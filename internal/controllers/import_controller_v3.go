@@ -20,6 +20,7 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
@@ -41,9 +42,11 @@ import (
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	"sigs.k8s.io/cluster-api/controllers/external"
 	"sigs.k8s.io/cluster-api/controllers/remote"
+	capiannotations "sigs.k8s.io/cluster-api/util/annotations"
 	"sigs.k8s.io/cluster-api/util/conditions"
 	"sigs.k8s.io/cluster-api/util/predicates"
 
+	turtlesv1 "github.com/rancher/turtles/api/v1alpha1"
 	managementv3 "github.com/rancher/turtles/internal/rancher/management/v3"
 	"github.com/rancher/turtles/util"
 	turtlesannotations "github.com/rancher/turtles/util/annotations"
@@ -58,10 +61,34 @@ type CAPIImportManagementV3Reconciler struct {
 	WatchFilterValue   string
 	Scheme             *runtime.Scheme
 	InsecureSkipVerify bool
+	// CABundle is the PEM encoded CA bundle the certificate of the Rancher server is verified against when downloading
+	// the registration manifest. It takes precedence over InsecureSkipVerify.
+	CABundle []byte
+	// ManifestURLHost, when set, replaces the host of the registration manifest URL.
+	ManifestURLHost string
+
+	// ManifestURLHostAllowlist lists the hosts the ManifestURLHostAnnotation of the CAPI clusters may select.
+	ManifestURLHostAllowlist []string
+	// ManifestDownloadAttempts is the maximum number of attempts to download the registration manifest.
+	ManifestDownloadAttempts int
+	// ManifestDownloadInterval is the initial wait between two registration manifest download attempts.
+	ManifestDownloadInterval time.Duration
+	// ManifestDownloadTimeout bounds each registration manifest download attempt. Defaults to 30 seconds.
+	ManifestDownloadTimeout time.Duration
+	// RancherClusterPollInterval is the first requeue interval of a CAPI cluster waiting for Rancher to set the
+	// manifest URL of its registration token, doubled on each consecutive wait. Defaults to 5 seconds.
+	RancherClusterPollInterval time.Duration
+	// NamespaceEnqueueSpread is the window the clusters of a changed namespace are enqueued over.
+	NamespaceEnqueueSpread time.Duration
+	// ControlPlaneReadiness configures the additional signals the control plane of the CAPI cluster is considered
+	// ready on.
+	ControlPlaneReadiness util.ControlPlaneReadiness
 
 	controller         controller.Controller
 	externalTracker    external.ObjectTracker
 	remoteClientGetter remote.ClusterClientGetter
+	manifestHTTPClient manifestHTTPClient
+	requeues           requeueBackoff
 }
 
 // SetupWithManager sets up reconciler with manager.
@@ -73,9 +100,10 @@ func (r *CAPIImportManagementV3Reconciler) SetupWithManager(ctx context.Context,
 	}
 
 	capiPredicates := predicates.All(log,
+		predicates.ResourceNotPaused(log),
 		predicates.ResourceHasFilterLabel(log, r.WatchFilterValue),
 		turtlespredicates.ClusterWithoutImportedAnnotation(log),
-		turtlespredicates.ClusterWithReadyControlPlane(log),
+		turtlespredicates.ClusterWithReadyControlPlane(log, r.ControlPlaneReadiness),
 		turtlespredicates.ClusterOrNamespaceWithImportLabel(ctx, log, r.Client, importLabelName),
 	)
 
@@ -99,7 +127,7 @@ func (r *CAPIImportManagementV3Reconciler) SetupWithManager(ctx context.Context,
 	ns := &corev1.Namespace{}
 	if err = c.Watch(
 		source.Kind(mgr.GetCache(), ns),
-		handler.EnqueueRequestsFromMapFunc(namespaceToCapiClusters(ctx, capiPredicates, r.Client)),
+		enqueueRequestsFromMapFuncStaggered(namespaceToCapiClusters(ctx, capiPredicates, r.Client, importLabelName), r.NamespaceEnqueueSpread),
 	); err != nil {
 		return fmt.Errorf("adding watch for namespaces: %w", err)
 	}
@@ -129,10 +157,18 @@ func (r *CAPIImportManagementV3Reconciler) Reconcile(ctx context.Context, req ct
 	capiCluster := &clusterv1.Cluster{}
 	if err := r.Client.Get(ctx, req.NamespacedName, capiCluster); err != nil {
 		if apierrors.IsNotFound(err) {
-			return ctrl.Result{Requeue: true}, nil
+			r.requeues.reset(req.NamespacedName)
+
+			return ctrl.Result{}, nil
 		}
 
-		return ctrl.Result{Requeue: true}, err
+		return ctrl.Result{}, err
+	}
+
+	// A paused cluster is left untouched, e.g. while an operator debugs its import.
+	if capiannotations.HasPaused(capiCluster) {
+		log.Info("CAPI cluster is paused, skipping reconciliation")
+		return ctrl.Result{}, nil
 	}
 
 	if capiCluster.ObjectMeta.DeletionTimestamp.IsZero() && !turtlesannotations.HasClusterImportAnnotation(capiCluster) &&
@@ -149,9 +185,9 @@ func (r *CAPIImportManagementV3Reconciler) Reconcile(ctx context.Context, req ct
 
 	// Wait for controlplane to be ready. This should never be false as the predicates
 	// do the filtering.
-	if !capiCluster.Status.ControlPlaneReady && !conditions.IsTrue(capiCluster, clusterv1.ControlPlaneReadyCondition) {
+	if ready, _ := r.ControlPlaneReadiness.Ready(capiCluster); !ready {
 		log.Info("clusters control plane is not ready, requeue")
-		return ctrl.Result{RequeueAfter: defaultRequeueDuration}, nil
+		return r.requeueWaiting(capiCluster, turtlesv1.WaitingForControlPlaneReason, minRequeueDuration), nil
 	}
 
 	// Collect errors as an aggregate to return together after all patches have been performed.
@@ -206,7 +242,7 @@ func (r *CAPIImportManagementV3Reconciler) reconcile(ctx context.Context, capiCl
 
 	if client.IgnoreNotFound(err) != nil {
 		log.Error(err, fmt.Sprintf("Unable to fetch rancher cluster %s", client.ObjectKeyFromObject(rancherCluster)))
-		return ctrl.Result{Requeue: true}, err
+		return ctrl.Result{}, err
 	}
 
 	if len(rancherClusterList.Items) != 0 {
@@ -265,7 +301,7 @@ func (r *CAPIImportManagementV3Reconciler) reconcileNormal(ctx context.Context,
 			return ctrl.Result{}, fmt.Errorf("error creating rancher cluster: %w", err)
 		}
 
-		return ctrl.Result{Requeue: true}, nil
+		return r.requeueWaiting(capiCluster, turtlesv1.WaitingForManifestURLReason, r.RancherClusterPollInterval), nil
 	}
 
 	if err != nil {
@@ -276,18 +312,33 @@ func (r *CAPIImportManagementV3Reconciler) reconcileNormal(ctx context.Context,
 
 	if conditions.IsTrue(rancherCluster, managementv3.ClusterConditionAgentDeployed) {
 		log.Info("agent already deployed, no action needed")
+		r.requeues.reset(client.ObjectKeyFromObject(capiCluster))
+
 		return ctrl.Result{}, nil
 	}
 
+	httpClient, err := r.manifestHTTPClient.get(manifestTLS{InsecureSkipVerify: r.InsecureSkipVerify, CABundle: r.CABundle},
+		r.ManifestDownloadTimeout)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	host, err := manifestURLHost(capiCluster, r.ManifestURLHost, r.ManifestURLHostAllowlist)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
 	// get the registration manifest
-	manifest, err := getClusterRegistrationManifest(ctx, rancherCluster.Name, rancherCluster.Name, r.RancherClient, r.InsecureSkipVerify)
+	manifest, err := getClusterRegistrationManifest(ctx, rancherCluster.Name, rancherCluster.Name, r.RancherClient, httpClient,
+		host, capiCluster.GetAnnotations()[turtlesannotations.ManifestChecksumAnnotation],
+		manifestRetry{Attempts: r.ManifestDownloadAttempts, Interval: r.ManifestDownloadInterval}, tokenReissue{})
 	if err != nil {
 		return ctrl.Result{}, err
 	}
 
 	if manifest == "" {
 		log.Info("Import manifest URL not set yet, requeue")
-		return ctrl.Result{Requeue: true}, nil
+		return r.requeueWaiting(capiCluster, turtlesv1.WaitingForManifestURLReason, r.RancherClusterPollInterval), nil
 	}
 
 	log.Info("Creating import manifest")
@@ -303,6 +354,8 @@ func (r *CAPIImportManagementV3Reconciler) reconcileNormal(ctx context.Context,
 
 	log.Info("Successfully applied import manifest")
 
+	r.requeues.reset(client.ObjectKeyFromObject(capiCluster))
+
 	return ctrl.Result{}, nil
 }
 
@@ -384,3 +437,12 @@ func (r *CAPIImportManagementV3Reconciler) deleteDependentRancherCluster(ctx con
 
 	return r.RancherClient.DeleteAllOf(ctx, &managementv3.Cluster{}, selectors...)
 }
+
+// requeueWaiting returns the result of a reconcile of the CAPI cluster waiting for the reason, requeued with the
+// backoff of the cluster starting at initial. Errors are not waits: they are returned to be requeued by the controller
+// rate limiter.
+func (r *CAPIImportManagementV3Reconciler) requeueWaiting(capiCluster *clusterv1.Cluster, reason string,
+	initial time.Duration,
+) ctrl.Result {
+	return ctrl.Result{RequeueAfter: r.requeues.next(client.ObjectKeyFromObject(capiCluster), reason, initial)}
+}
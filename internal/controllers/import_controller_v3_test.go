@@ -155,7 +155,7 @@ var _ = Describe("reconcile CAPI Cluster", func() {
 				},
 			})
 			g.Expect(err).ToNot(HaveOccurred())
-			g.Expect(res.RequeueAfter).To(Equal(defaultRequeueDuration))
+			g.Expect(res.RequeueAfter).To(Equal(minRequeueDuration))
 		}).Should(Succeed())
 	})
 
@@ -177,7 +177,7 @@ var _ = Describe("reconcile CAPI Cluster", func() {
 				},
 			})
 			g.Expect(err).ToNot(HaveOccurred())
-			g.Expect(res.Requeue).To(BeTrue())
+			g.Expect(res.RequeueAfter).To(Equal(minRequeueDuration))
 		}).Should(Succeed())
 
 		Eventually(ctx, func(g Gomega) {
@@ -200,7 +200,7 @@ var _ = Describe("reconcile CAPI Cluster", func() {
 				},
 			})
 			g.Expect(err).ToNot(HaveOccurred())
-			g.Expect(res.Requeue).To(BeTrue())
+			g.Expect(res.RequeueAfter).To(Equal(minRequeueDuration))
 		}).Should(Succeed())
 
 		Eventually(ctx, func(g Gomega) {
@@ -293,7 +293,7 @@ var _ = Describe("reconcile CAPI Cluster", func() {
 				},
 			})
 			g.Expect(err).ToNot(HaveOccurred())
-			g.Expect(res.Requeue).To(BeTrue())
+			g.Expect(res.RequeueAfter).To(Equal(minRequeueDuration))
 		}).Should(Succeed())
 	})
 
@@ -363,7 +363,7 @@ var _ = Describe("reconcile CAPI Cluster", func() {
 				},
 			})
 			g.Expect(err).ToNot(HaveOccurred())
-			g.Expect(res.Requeue).To(BeTrue())
+			g.Expect(res.RequeueAfter).To(Equal(minRequeueDuration))
 		}).Should(Succeed())
 	})
 
@@ -402,7 +402,7 @@ var _ = Describe("reconcile CAPI Cluster", func() {
 				},
 			})
 			g.Expect(err).ToNot(HaveOccurred())
-			g.Expect(res.Requeue).To(BeTrue())
+			g.Expect(res.RequeueAfter).To(Equal(minRequeueDuration))
 			g.Expect(cl.Get(ctx, client.ObjectKeyFromObject(clusterRegistrationToken), clusterRegistrationToken)).ToNot(HaveOccurred())
 		}).Should(Succeed())
 	})
@@ -437,7 +437,7 @@ var _ = Describe("reconcile CAPI Cluster", func() {
 				},
 			})
 			g.Expect(err).ToNot(HaveOccurred())
-			g.Expect(res.Requeue).To(BeTrue())
+			g.Expect(res.RequeueAfter).To(Equal(minRequeueDuration))
 		}).Should(Succeed())
 	})
 })
@@ -0,0 +1,210 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/validation"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+
+	provisioningv1 "github.com/rancher/turtles/internal/rancher/provisioning/v1"
+)
+
+// variableTransformSeparator separates the label key from the optional value transform in the variable mapping.
+const variableTransformSeparator = "|"
+
+// variableTransforms are the transforms which can be applied to the topology variable values.
+var variableTransforms = map[string]func(string) string{
+	"":      func(value string) string { return value },
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+}
+
+// variableLabel is the Rancher cluster label a topology variable is projected to.
+type variableLabel struct {
+	key       string
+	transform func(string) string
+}
+
+// variableMapping reads the topology variable mapping from its config map. Each entry maps a variable name to a label
+// key, optionally followed by |lower or |upper to transform the value. Invalid entries are skipped, and a missing
+// config map projects nothing.
+func (r *CAPIImportReconciler) variableMapping(ctx context.Context) (map[string]variableLabel, error) {
+	log := log.FromContext(ctx)
+
+	cm := &corev1.ConfigMap{}
+	if err := r.Client.Get(ctx, r.TopologyVariableMapping, cm); err != nil {
+		if apierrors.IsNotFound(err) {
+			log.V(4).Info("topology variable mapping not found", "configMap", r.TopologyVariableMapping)
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("getting topology variable mapping %s: %w", r.TopologyVariableMapping, err)
+	}
+
+	mapping := map[string]variableLabel{}
+
+	for variable, target := range cm.Data {
+		key, transformName, _ := strings.Cut(strings.TrimSpace(target), variableTransformSeparator)
+
+		transform, ok := variableTransforms[strings.TrimSpace(transformName)]
+		if !ok {
+			log.Info("skipping topology variable with an unknown transform", "variable", variable, "transform", transformName)
+			continue
+		}
+
+		key = strings.TrimSpace(key)
+		if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+			log.Info("skipping topology variable with an invalid label key", "variable", variable, "key", key,
+				"errors", strings.Join(errs, ", "))
+
+			continue
+		}
+
+		mapping[variable] = variableLabel{key: key, transform: transform}
+	}
+
+	return mapping, nil
+}
+
+// variableValue returns the value of a topology variable as a string. Only scalar values can be projected, false is
+// returned for objects and arrays.
+func variableValue(variable clusterv1.ClusterVariable) (string, bool) {
+	var value interface{}
+	if err := json.Unmarshal(variable.Value.Raw, &value); err != nil {
+		return "", false
+	}
+
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case bool:
+		return strconv.FormatBool(v), true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	default:
+		return "", false
+	}
+}
+
+// variableLabels returns the labels projected from the topology variables of the CAPI cluster. Variables whose
+// transformed value isn't a valid label value are skipped.
+func variableLabels(ctx context.Context, capiCluster *clusterv1.Cluster, mapping map[string]variableLabel) map[string]string {
+	labels := map[string]string{}
+
+	if capiCluster.Spec.Topology == nil {
+		return labels
+	}
+
+	for _, variable := range capiCluster.Spec.Topology.Variables {
+		label, ok := mapping[variable.Name]
+		if !ok {
+			continue
+		}
+
+		value, ok := variableValue(variable)
+		if !ok {
+			log.FromContext(ctx).V(4).Info("skipping non scalar topology variable", "variable", variable.Name)
+			continue
+		}
+
+		value = label.transform(value)
+		if errs := validation.IsValidLabelValue(value); len(errs) > 0 {
+			log.FromContext(ctx).Info("skipping topology variable with an invalid label value", "variable", variable.Name,
+				"value", value, "errors", strings.Join(errs, ", "))
+
+			continue
+		}
+
+		labels[label.key] = value
+	}
+
+	return labels
+}
+
+// syncVariableLabels projects the mapped topology variables of the CAPI cluster onto the Rancher cluster labels. The
+// mapping is read on every reconcile, so changes apply from the next reconcile of each cluster. Labels of variables
+// which are no longer mapped are left untouched, and the Rancher cluster is only patched when a value changed.
+func (r *CAPIImportReconciler) syncVariableLabels(ctx context.Context, capiCluster *clusterv1.Cluster,
+	rancherCluster *provisioningv1.Cluster,
+) error {
+	if r.TopologyVariableMapping.Name == "" || capiCluster.Spec.Topology == nil {
+		return nil
+	}
+
+	mapping, err := r.variableMapping(ctx)
+	if err != nil {
+		return err
+	}
+
+	patchBase := client.MergeFrom(rancherCluster.DeepCopy())
+	if !mergeLabels(rancherCluster, variableLabels(ctx, capiCluster, mapping)) {
+		return nil
+	}
+
+	if err := r.RancherClient.Patch(ctx, rancherCluster, patchBase); err != nil {
+		return fmt.Errorf("patching Rancher cluster topology variable labels: %w", err)
+	}
+
+	log.FromContext(ctx).V(4).Info("set topology variable labels on Rancher cluster")
+
+	return nil
+}
+
+// variableMappingToCapiClusters enqueues the topology managed CAPI clusters when the variable mapping changes.
+func (r *CAPIImportReconciler) variableMappingToCapiClusters(ctx context.Context, clusterPredicate predicate.Funcs) handler.MapFunc {
+	log := log.FromContext(ctx)
+
+	return func(_ context.Context, o client.Object) []ctrl.Request {
+		if client.ObjectKeyFromObject(o) != r.TopologyVariableMapping {
+			return nil
+		}
+
+		capiClusters := &clusterv1.ClusterList{}
+		if err := r.Client.List(ctx, capiClusters); err != nil {
+			log.Error(err, "listing capi clusters")
+			return nil
+		}
+
+		reqs := []ctrl.Request{}
+
+		for i := range capiClusters.Items {
+			cluster := &capiClusters.Items[i]
+			if cluster.Spec.Topology == nil || !clusterPredicate.Generic(event.GenericEvent{Object: cluster}) {
+				continue
+			}
+
+			reqs = append(reqs, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(cluster)})
+		}
+
+		return reqs
+	}
+}
@@ -0,0 +1,189 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	"github.com/rancher/turtles/testutil"
+)
+
+var _ = Describe("topology variable labels", func() {
+	var (
+		r       *CAPIImportReconciler
+		mapping *corev1.ConfigMap
+	)
+
+	mappingKey := client.ObjectKey{Namespace: "rancher-turtles-system", Name: "variable-mapping"}
+
+	topologyCluster := func(name string, variables map[string]string) *clusterv1.Cluster {
+		cluster := &clusterv1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "test-ns",
+				Labels:    map[string]string{importLabelName: "true"},
+			},
+			Spec: clusterv1.ClusterSpec{Topology: &clusterv1.Topology{Class: "test-class", Version: "v1.29.0"}},
+		}
+
+		for variable, value := range variables {
+			cluster.Spec.Topology.Variables = append(cluster.Spec.Topology.Variables, clusterv1.ClusterVariable{
+				Name:  variable,
+				Value: apiextensionsv1.JSON{Raw: []byte(value)},
+			})
+		}
+
+		return cluster
+	}
+
+	// reconcileLabels imports the cluster and returns the labels of its Rancher cluster.
+	reconcileLabels := func(capiCluster *clusterv1.Cluster) map[string]string {
+		Expect(r.Client.Create(ctx, capiCluster)).To(Succeed())
+
+		rancherCluster := testutil.RancherCluster(capiCluster.Name+"-capi", capiCluster.Namespace, testutil.ClusterStateNoName)
+		Expect(r.RancherClient.Create(ctx, rancherCluster)).To(Succeed())
+
+		_, err := r.reconcile(ctx, capiCluster)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(r.RancherClient.Get(ctx, client.ObjectKeyFromObject(rancherCluster), rancherCluster)).To(Succeed())
+
+		return rancherCluster.Labels
+	}
+
+	BeforeEach(func() {
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test-ns"}}
+		mapping = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: mappingKey.Namespace, Name: mappingKey.Name},
+			Data: map[string]string{
+				"region": "example.com/region",
+				"env":    "example.com/env | upper",
+			},
+		}
+
+		r = &CAPIImportReconciler{
+			Client:        fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(ns, mapping).Build(),
+			RancherClient: testutil.NewRancherClientBuilder().WithObjects(ns.DeepCopy()).Build(),
+			RancherClusterOptions: RancherClusterOptions{
+				TopologyVariableMapping: mappingKey,
+			},
+		}
+	})
+
+	It("should project the mapped variables onto the Rancher cluster", func() {
+		labels := reconcileLabels(topologyCluster("test-cluster", map[string]string{
+			"region":   `"eu-west-1"`,
+			"env":      `"prod"`,
+			"unmapped": `"value"`,
+		}))
+
+		Expect(labels).To(HaveKeyWithValue("example.com/region", "eu-west-1"))
+		Expect(labels).To(HaveKeyWithValue("example.com/env", "PROD"))
+		Expect(labels).ToNot(HaveKey("unmapped"))
+	})
+
+	It("should project the updated mapping onto subsequently reconciled clusters", func() {
+		variables := map[string]string{"region": `"eu-west-1"`, "env": `"prod"`, "tier": `"Gold"`}
+
+		labels := reconcileLabels(topologyCluster("first-cluster", variables))
+		Expect(labels).To(HaveKeyWithValue("example.com/env", "PROD"))
+		Expect(labels).ToNot(HaveKey("example.com/tier"))
+
+		mapping.Data = map[string]string{
+			"region": "example.com/location",
+			"tier":   "example.com/tier|lower",
+		}
+		Expect(r.Client.Update(ctx, mapping)).To(Succeed())
+
+		labels = reconcileLabels(topologyCluster("second-cluster", variables))
+		Expect(labels).To(HaveKeyWithValue("example.com/location", "eu-west-1"))
+		Expect(labels).To(HaveKeyWithValue("example.com/tier", "gold"))
+		Expect(labels).ToNot(HaveKey("example.com/region"))
+		Expect(labels).ToNot(HaveKey("example.com/env"))
+	})
+
+	It("should project scalar values and skip the others", func() {
+		mapping.Data = map[string]string{
+			"replicas": "example.com/replicas",
+			"ha":       "example.com/ha",
+			"network":  "example.com/network",
+			"long":     "example.com/long",
+		}
+		Expect(r.Client.Update(ctx, mapping)).To(Succeed())
+
+		labels := reconcileLabels(topologyCluster("test-cluster", map[string]string{
+			"replicas": `3`,
+			"ha":       `true`,
+			"network":  `{"cidr": "10.0.0.0/16"}`,
+			"long":     `"` + strings.Repeat("a", 64) + `"`,
+		}))
+
+		Expect(labels).To(HaveKeyWithValue("example.com/replicas", "3"))
+		Expect(labels).To(HaveKeyWithValue("example.com/ha", "true"))
+		Expect(labels).ToNot(HaveKey("example.com/network"))
+		Expect(labels).ToNot(HaveKey("example.com/long"))
+	})
+
+	It("should skip invalid mapping entries", func() {
+		mapping.Data = map[string]string{
+			"region": "example.com/region|reverse",
+			"env":    "not a label",
+			"tier":   "example.com/tier",
+		}
+		Expect(r.Client.Update(ctx, mapping)).To(Succeed())
+
+		labels := reconcileLabels(topologyCluster("test-cluster", map[string]string{
+			"region": `"eu-west-1"`,
+			"env":    `"prod"`,
+			"tier":   `"gold"`,
+		}))
+
+		Expect(labels).To(HaveKeyWithValue("example.com/tier", "gold"))
+		Expect(labels).ToNot(HaveKey("example.com/region"))
+	})
+
+	It("should not project anything without the mapping config map", func() {
+		Expect(r.Client.Delete(ctx, mapping)).To(Succeed())
+
+		labels := reconcileLabels(topologyCluster("test-cluster", map[string]string{"region": `"eu-west-1"`}))
+		Expect(labels).ToNot(HaveKey("example.com/region"))
+	})
+
+	It("should enqueue the topology managed clusters when the mapping changes", func() {
+		Expect(r.Client.Create(ctx, topologyCluster("test-cluster", nil))).To(Succeed())
+		Expect(r.Client.Create(ctx, &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "classless", Namespace: "test-ns"}})).To(Succeed())
+
+		mapFunc := r.variableMappingToCapiClusters(ctx, predicate.Funcs{})
+
+		reqs := mapFunc(ctx, mapping)
+		Expect(reqs).To(HaveLen(1))
+		Expect(reqs[0].NamespacedName).To(Equal(client.ObjectKey{Namespace: "test-ns", Name: "test-cluster"}))
+
+		Expect(mapFunc(ctx, &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns", Name: "other"}})).To(BeEmpty())
+	})
+})
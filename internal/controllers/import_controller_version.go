@@ -0,0 +1,217 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/blang/semver/v4"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	turtlesv1 "github.com/rancher/turtles/api/v1alpha1"
+	provisioningv1 "github.com/rancher/turtles/internal/rancher/provisioning/v1"
+	turtlesannotations "github.com/rancher/turtles/util/annotations"
+)
+
+// clusterKubernetesVersion returns the Kubernetes version of the CAPI cluster, read from its topology or from the
+// version of its control plane. An empty string is returned when it can't be determined.
+func (r *CAPIImportReconciler) clusterKubernetesVersion(ctx context.Context, capiCluster *clusterv1.Cluster) (string, error) {
+	if capiCluster.Spec.Topology != nil && capiCluster.Spec.Topology.Version != "" {
+		return capiCluster.Spec.Topology.Version, nil
+	}
+
+	ref := capiCluster.Spec.ControlPlaneRef
+	if ref == nil {
+		return "", nil
+	}
+
+	namespace := ref.Namespace
+	if namespace == "" {
+		namespace = capiCluster.Namespace
+	}
+
+	controlPlane := &unstructured.Unstructured{}
+	controlPlane.SetAPIVersion(ref.APIVersion)
+	controlPlane.SetKind(ref.Kind)
+
+	if err := r.Client.Get(ctx, client.ObjectKey{Name: ref.Name, Namespace: namespace}, controlPlane); err != nil {
+		if apierrors.IsNotFound(err) {
+			return "", nil
+		}
+
+		return "", fmt.Errorf("getting control plane: %w", err)
+	}
+
+	version, _, err := unstructured.NestedString(controlPlane.Object, "spec", "version")
+	if err != nil {
+		log.FromContext(ctx).V(4).Info("unable to read version from control plane", "error", err.Error())
+		return "", nil
+	}
+
+	return version, nil
+}
+
+// unsupportedKubernetesVersion returns the reason the Kubernetes version of the CAPI cluster is not supported, or an
+// empty string when it is in the supported range, when the range is not configured or when the version is unknown.
+func (r *CAPIImportReconciler) unsupportedKubernetesVersion(ctx context.Context, capiCluster *clusterv1.Cluster) (string, error) {
+	if r.SupportedKubernetesVersions == nil {
+		return "", nil
+	}
+
+	version, err := r.clusterKubernetesVersion(ctx, capiCluster)
+	if err != nil || version == "" {
+		return "", err
+	}
+
+	parsed, err := semver.ParseTolerant(version)
+	if err != nil {
+		return fmt.Sprintf("Kubernetes version %s can't be parsed", version), nil
+	}
+
+	// Pre-release and build metadata, e.g. the +rke2r1 suffix, don't affect the support range.
+	parsed.Pre, parsed.Build = nil, nil
+
+	if r.SupportedKubernetesVersions(parsed) {
+		return "", nil
+	}
+
+	return fmt.Sprintf("Kubernetes version %s is not supported by Rancher", version), nil
+}
+
+// checkKubernetesVersion reports whether the Kubernetes version of the CAPI cluster is supported in the
+// KubernetesVersionSupported condition, emitting a warning event when it isn't. It returns false when the import must
+// be skipped, which only happens for unsupported versions unless they are allowed.
+func (r *CAPIImportReconciler) checkKubernetesVersion(ctx context.Context, capiCluster *clusterv1.Cluster) (bool, error) {
+	if r.SupportedKubernetesVersions == nil {
+		return true, nil
+	}
+
+	reason, err := r.unsupportedKubernetesVersion(ctx, capiCluster)
+	if err != nil {
+		return false, err
+	}
+
+	if reason == "" {
+		conditions.MarkTrue(capiCluster, turtlesv1.KubernetesVersionSupportedCondition)
+		return true, nil
+	}
+
+	severity := clusterv1.ConditionSeverityError
+	if r.AllowUnsupportedKubernetesVersions {
+		severity = clusterv1.ConditionSeverityWarning
+	}
+
+	conditions.MarkFalse(capiCluster, turtlesv1.KubernetesVersionSupportedCondition, turtlesv1.UnsupportedKubernetesVersionReason,
+		severity, "%s", reason)
+
+	if r.AllowUnsupportedKubernetesVersions {
+		r.recorder.Event(capiCluster, corev1.EventTypeWarning, turtlesv1.UnsupportedKubernetesVersionReason, reason+", importing anyway")
+		return true, nil
+	}
+
+	r.recorder.Event(capiCluster, corev1.EventTypeWarning, turtlesv1.UnsupportedKubernetesVersionReason, reason+", skipping import")
+
+	return false, nil
+}
+
+func (r *CAPIImportReconciler) kubernetesVersionGate(ctx context.Context, capiCluster *clusterv1.Cluster) (string, error) {
+	if r.AllowUnsupportedKubernetesVersions {
+		return "", nil
+	}
+
+	return r.unsupportedKubernetesVersion(ctx, capiCluster)
+}
+
+// turtlesVersion returns the version of turtles recorded on the imported clusters, empty when it is unknown, e.g. for
+// builds without the version stamped in.
+func (r *CAPIImportReconciler) turtlesVersion() string {
+	if r.Version == unstampedVersion {
+		return ""
+	}
+
+	return r.Version
+}
+
+// stampsVersion returns true when the version of turtles must replace the version recorded in the annotations: when
+// none is recorded, or when the recorded one is older. A version that can't be compared is only replaced by a valid
+// one, so that a downgrade or a custom build never overwrites the version recorded by a newer turtles.
+func (r *CAPIImportReconciler) stampsVersion(annotations map[string]string) bool {
+	current := r.turtlesVersion()
+	if current == "" {
+		return false
+	}
+
+	recorded := annotations[turtlesannotations.ImportedByVersionAnnotation]
+	if recorded == "" || recorded == unstampedVersion {
+		return true
+	}
+
+	currentVersion, err := semver.ParseTolerant(current)
+	if err != nil {
+		return false
+	}
+
+	recordedVersion, err := semver.ParseTolerant(recorded)
+	if err != nil {
+		return true
+	}
+
+	return recordedVersion.LT(currentVersion)
+}
+
+// importedByVersion adds the version of turtles to the annotations, when it is known and newer than the recorded one.
+func (r *CAPIImportReconciler) importedByVersion(annotations map[string]string) map[string]string {
+	if !r.stampsVersion(annotations) {
+		return annotations
+	}
+
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+
+	annotations[turtlesannotations.ImportedByVersionAnnotation] = r.turtlesVersion()
+
+	return annotations
+}
+
+// updateImportedByVersion records the version of turtles re-importing the cluster on both clusters. The Rancher
+// cluster is only patched when its recorded version is missing or older.
+func (r *CAPIImportReconciler) updateImportedByVersion(ctx context.Context, capiCluster *clusterv1.Cluster,
+	rancherCluster *provisioningv1.Cluster,
+) error {
+	capiCluster.SetAnnotations(r.importedByVersion(capiCluster.GetAnnotations()))
+
+	if !r.stampsVersion(rancherCluster.GetAnnotations()) {
+		return nil
+	}
+
+	patchBase := client.MergeFrom(rancherCluster.DeepCopy())
+	rancherCluster.SetAnnotations(r.importedByVersion(rancherCluster.GetAnnotations()))
+
+	if err := r.RancherClient.Patch(ctx, rancherCluster, patchBase); err != nil {
+		return fmt.Errorf("patching Rancher cluster version annotation: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,154 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"github.com/blang/semver/v4"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	turtlesv1 "github.com/rancher/turtles/api/v1alpha1"
+	provisioningv1 "github.com/rancher/turtles/internal/rancher/provisioning/v1"
+)
+
+var _ = Describe("supported Kubernetes versions", func() {
+	var (
+		r              *CAPIImportReconciler
+		recorder       *record.FakeRecorder
+		ns             *corev1.Namespace
+		capiCluster    *clusterv1.Cluster
+		rancherCluster *provisioningv1.Cluster
+	)
+
+	rancherClusterCreated := func() bool {
+		err := r.RancherClient.Get(ctx, client.ObjectKeyFromObject(rancherCluster), &provisioningv1.Cluster{})
+		if apierrors.IsNotFound(err) {
+			return false
+		}
+
+		Expect(err).ToNot(HaveOccurred())
+
+		return true
+	}
+
+	withVersion := func(version string) {
+		capiCluster.Spec.Topology = &clusterv1.Topology{Class: "test", Version: version}
+		r.Client = fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(ns, capiCluster).Build()
+	}
+
+	BeforeEach(func() {
+		ns = &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test-ns"}}
+		capiCluster = &clusterv1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-cluster",
+				Namespace: "test-ns",
+				Labels:    map[string]string{importLabelName: "true"},
+			},
+			Status: clusterv1.ClusterStatus{ControlPlaneReady: true},
+		}
+		rancherCluster = &provisioningv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test-cluster-capi", Namespace: "test-ns"}}
+		recorder = record.NewFakeRecorder(10)
+
+		r = &CAPIImportReconciler{
+			RancherClient: fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(ns.DeepCopy()).Build(),
+			recorder:      recorder,
+			SelectionOptions: SelectionOptions{
+				SupportedKubernetesVersions: semver.MustParseRange(">=1.26.0 <1.30.0"),
+			},
+		}
+	})
+
+	It("should import clusters in the supported range", func() {
+		withVersion("v1.28.3+rke2r1")
+
+		_, err := r.reconcileNormal(ctx, capiCluster, rancherCluster)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(rancherClusterCreated()).To(BeTrue())
+		Expect(conditions.IsTrue(capiCluster, turtlesv1.KubernetesVersionSupportedCondition)).To(BeTrue())
+		Expect(conditions.IsTrue(capiCluster, turtlesv1.ImportEligibleCondition)).To(BeTrue())
+		Expect(recorder.Events).To(BeEmpty())
+	})
+
+	It("should not import clusters out of the supported range", func() {
+		withVersion("v1.31.0")
+
+		res, err := r.reconcileNormal(ctx, capiCluster, rancherCluster)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res.RequeueAfter).To(Equal(defaultRequeueDuration))
+
+		Expect(rancherClusterCreated()).To(BeFalse())
+		Expect(conditions.IsFalse(capiCluster, turtlesv1.KubernetesVersionSupportedCondition)).To(BeTrue())
+		Expect(conditions.GetReason(capiCluster, turtlesv1.KubernetesVersionSupportedCondition)).To(Equal(turtlesv1.UnsupportedKubernetesVersionReason))
+		Expect(conditions.GetSeverity(capiCluster, turtlesv1.KubernetesVersionSupportedCondition)).To(HaveValue(Equal(clusterv1.ConditionSeverityError)))
+		Expect(conditions.GetMessage(capiCluster, turtlesv1.ImportEligibleCondition)).To(ContainSubstring("KubernetesVersion: Kubernetes version v1.31.0 is not supported"))
+		Expect(recorder.Events).To(Receive(ContainSubstring("skipping import")))
+	})
+
+	It("should import clusters out of the supported range with a warning when allowed", func() {
+		withVersion("v1.25.9")
+		r.AllowUnsupportedKubernetesVersions = true
+
+		_, err := r.reconcileNormal(ctx, capiCluster, rancherCluster)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(rancherClusterCreated()).To(BeTrue())
+		Expect(conditions.GetSeverity(capiCluster, turtlesv1.KubernetesVersionSupportedCondition)).To(HaveValue(Equal(clusterv1.ConditionSeverityWarning)))
+		Expect(recorder.Events).To(Receive(ContainSubstring("importing anyway")))
+	})
+
+	It("should read the version from the control plane", func() {
+		controlPlane := &unstructured.Unstructured{}
+		controlPlane.SetAPIVersion("controlplane.cluster.x-k8s.io/v1beta1")
+		controlPlane.SetKind("KubeadmControlPlane")
+		controlPlane.SetName("test-control-plane")
+		controlPlane.SetNamespace("test-ns")
+		Expect(unstructured.SetNestedField(controlPlane.Object, "v1.31.2", "spec", "version")).To(Succeed())
+
+		capiCluster.Spec.ControlPlaneRef = &corev1.ObjectReference{
+			APIVersion: "controlplane.cluster.x-k8s.io/v1beta1",
+			Kind:       "KubeadmControlPlane",
+			Name:       "test-control-plane",
+		}
+		r.Client = fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(ns, capiCluster, controlPlane).Build()
+
+		_, err := r.reconcileNormal(ctx, capiCluster, rancherCluster)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(rancherClusterCreated()).To(BeFalse())
+		Expect(conditions.GetMessage(capiCluster, turtlesv1.KubernetesVersionSupportedCondition)).To(ContainSubstring("v1.31.2"))
+	})
+
+	It("should import clusters with an unknown version", func() {
+		r.Client = fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(ns, capiCluster).Build()
+
+		_, err := r.reconcileNormal(ctx, capiCluster, rancherCluster)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(rancherClusterCreated()).To(BeTrue())
+	})
+})
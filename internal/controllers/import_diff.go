@@ -0,0 +1,314 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+
+	managementv3 "github.com/rancher/turtles/internal/rancher/management/v3"
+	provisioningv1 "github.com/rancher/turtles/internal/rancher/provisioning/v1"
+	turtlesannotations "github.com/rancher/turtles/util/annotations"
+)
+
+// ErrManifestNotAvailable is returned by DiffCluster when the registration manifest of the cluster can't be
+// downloaded yet, e.g. because Rancher didn't register the cluster or issue its registration token.
+var ErrManifestNotAvailable = errors.New("registration manifest not available")
+
+// ObjectDiffStatus is the state of a manifest object in the downstream cluster.
+type ObjectDiffStatus string
+
+const (
+	// ObjectMissing is an object of the manifest which doesn't exist in the downstream cluster.
+	ObjectMissing ObjectDiffStatus = "Missing"
+	// ObjectUnchanged is an object of the manifest which is up to date in the downstream cluster.
+	ObjectUnchanged ObjectDiffStatus = "Unchanged"
+	// ObjectChanged is an object of the manifest with fields differing in the downstream cluster.
+	ObjectChanged ObjectDiffStatus = "Changed"
+)
+
+// FieldChangeType is the change applying the manifest makes to a field of a downstream object.
+type FieldChangeType string
+
+const (
+	// FieldAdded is a field set in the manifest but missing in the downstream object.
+	FieldAdded FieldChangeType = "Added"
+	// FieldRemoved is a field removed by the manifest but set in the downstream object.
+	FieldRemoved FieldChangeType = "Removed"
+	// FieldChanged is a field set to a different value in the manifest and in the downstream object.
+	FieldChanged FieldChangeType = "Changed"
+)
+
+// FieldChange is a field of a downstream object which differs from the manifest.
+type FieldChange struct {
+	// Path is the dot separated path of the field, e.g. spec.template.spec.containers.
+	Path string `json:"path"`
+	// Type is the change applying the manifest makes to the field.
+	Type FieldChangeType `json:"type"`
+	// Desired is the value of the field in the manifest.
+	Desired interface{} `json:"desired,omitempty"`
+	// Actual is the value of the field in the downstream cluster.
+	Actual interface{} `json:"actual,omitempty"`
+}
+
+// ObjectDiff is the difference between an object of the manifest and its downstream counterpart.
+type ObjectDiff struct {
+	APIVersion string           `json:"apiVersion"`
+	Kind       string           `json:"kind"`
+	Namespace  string           `json:"namespace,omitempty"`
+	Name       string           `json:"name"`
+	Status     ObjectDiffStatus `json:"status"`
+	// Changes lists the differing fields of a changed object.
+	Changes []FieldChange `json:"changes,omitempty"`
+}
+
+// ManifestDiff is the difference between the registration manifest of a cluster and its downstream state.
+type ManifestDiff struct {
+	// Cluster is the namespaced name of the CAPI cluster.
+	Cluster string `json:"cluster"`
+	// Objects lists the objects of the manifest, in manifest order.
+	Objects []ObjectDiff `json:"objects"`
+}
+
+// DiffCluster compares the registration manifest of the CAPI cluster with the state of the downstream cluster,
+// without changing either. The manifest is downloaded and decoded the same way as by the reconciler, and each of its
+// objects is fetched from the downstream cluster and reported as missing, unchanged or changed. Fields are compared
+// the same way as by the incremental apply: fields only set downstream, e.g. defaulted by the server, are ignored.
+// ErrManifestNotAvailable is returned until Rancher issued the registration token of the cluster.
+func DiffCluster(ctx context.Context, cfg ImportConfig, namespace, name string) (*ManifestDiff, error) {
+	r := newImportReconciler(cfg)
+
+	key := client.ObjectKey{Namespace: namespace, Name: name}
+
+	capiCluster := &clusterv1.Cluster{}
+	if err := r.Client.Get(ctx, key, capiCluster); err != nil {
+		return nil, fmt.Errorf("getting CAPI cluster %s: %w", key, err)
+	}
+
+	return r.diffManifest(ctx, capiCluster)
+}
+
+// diffManifest compares the registration manifest of the CAPI cluster with the state of the downstream cluster.
+// Unlike the import, it doesn't create the registration token of the cluster.
+func (r *CAPIImportReconciler) diffManifest(ctx context.Context, capiCluster *clusterv1.Cluster) (*ManifestDiff, error) {
+	manifest, err := r.fetchManifest(ctx, capiCluster)
+	if err != nil {
+		return nil, err
+	}
+
+	objs, err := decodeManifest(strings.NewReader(manifest))
+	if err != nil {
+		return nil, fmt.Errorf("decoding import manifest: %w", err)
+	}
+
+	if err := applyAgentScheduling(objs, r.AgentNodeSelector, r.AgentTolerations); err != nil {
+		return nil, fmt.Errorf("setting agent scheduling constraints: %w", err)
+	}
+
+	remoteClient, err := r.remoteClient(ctx, capiCluster)
+	if err != nil {
+		return nil, fmt.Errorf("getting remote cluster client: %w", err)
+	}
+
+	diff := &ManifestDiff{
+		Cluster: client.ObjectKeyFromObject(capiCluster).String(),
+		Objects: make([]ObjectDiff, 0, len(objs)),
+	}
+
+	for _, obj := range objs {
+		objDiff, err := diffObject(ctx, remoteClient, obj)
+		if err != nil {
+			return nil, err
+		}
+
+		diff.Objects = append(diff.Objects, objDiff)
+	}
+
+	return diff, nil
+}
+
+// fetchManifest downloads the registration manifest of the CAPI cluster, looking up its registration token
+// without creating it.
+func (r *CAPIImportReconciler) fetchManifest(ctx context.Context, capiCluster *clusterv1.Cluster) (string, error) {
+	rancherClusterKey, err := r.rancherClusterKey(capiCluster)
+	if err != nil {
+		return "", err
+	}
+
+	rancherCluster := &provisioningv1.Cluster{}
+
+	err = r.rancherClusters().Get(ctx, r.RancherClient, rancherClusterKey, rancherCluster)
+	if apierrors.IsNotFound(err) {
+		return "", fmt.Errorf("%w: Rancher cluster %s not found", ErrManifestNotAvailable, rancherClusterKey)
+	}
+
+	if err != nil {
+		return "", fmt.Errorf("getting Rancher cluster: %w", err)
+	}
+
+	if r.rancherClusterStatus(rancherCluster).ClusterName == "" {
+		return "", fmt.Errorf("%w: Rancher cluster %s is not registered yet", ErrManifestNotAvailable, rancherClusterKey)
+	}
+
+	token := &managementv3.ClusterRegistrationToken{}
+	tokenKey := client.ObjectKey{Namespace: r.rancherClusterNamespace(capiCluster), Name: r.rancherClusterStatus(rancherCluster).ClusterName}
+
+	err = r.RancherClient.Get(ctx, tokenKey, token)
+	if apierrors.IsNotFound(err) || (err == nil && token.Status.ManifestURL == "") {
+		return "", fmt.Errorf("%w: registration token %s is not ready", ErrManifestNotAvailable, tokenKey)
+	}
+
+	if err != nil {
+		return "", fmt.Errorf("getting registration token: %w", err)
+	}
+
+	host, err := manifestURLHost(capiCluster, r.ManifestURLHost, r.ManifestURLHostAllowlist)
+	if err != nil {
+		return "", err
+	}
+
+	manifestURL, err := rewriteManifestURL(token.Status.ManifestURL, host)
+	if err != nil {
+		return "", err
+	}
+
+	httpClient, err := r.manifestHTTPClient.get(manifestTLS{InsecureSkipVerify: r.InsecureSkipVerify, CABundle: r.CABundle},
+		r.ManifestDownloadTimeout)
+	if err != nil {
+		return "", err
+	}
+
+	manifest, err := fetchClusterRegistrationManifest(ctx, manifestURL, httpClient,
+		capiCluster.GetAnnotations()[turtlesannotations.ManifestChecksumAnnotation],
+		manifestRetry{Attempts: r.ManifestDownloadAttempts, Interval: r.ManifestDownloadInterval})
+	if err != nil {
+		return "", err
+	}
+
+	if manifest == "" {
+		return "", fmt.Errorf("%w: registration manifest of %s is empty", ErrManifestNotAvailable, rancherClusterKey)
+	}
+
+	return manifest, nil
+}
+
+// diffObject fetches the downstream counterpart of the manifest object and compares them.
+func diffObject(ctx context.Context, remoteClient client.Client, desired *unstructured.Unstructured) (ObjectDiff, error) {
+	objDiff := ObjectDiff{
+		APIVersion: desired.GetAPIVersion(),
+		Kind:       desired.GetKind(),
+		Namespace:  desired.GetNamespace(),
+		Name:       desired.GetName(),
+	}
+
+	existing := &unstructured.Unstructured{}
+	existing.SetGroupVersionKind(desired.GroupVersionKind())
+
+	err := remoteClient.Get(ctx, client.ObjectKeyFromObject(desired), existing)
+	if apierrors.IsNotFound(err) {
+		objDiff.Status = ObjectMissing
+		return objDiff, nil
+	}
+
+	if err != nil {
+		return objDiff, fmt.Errorf("getting %s %s: %w", desired.GetKind(), client.ObjectKeyFromObject(desired), err)
+	}
+
+	objDiff.Changes = diffFields(desired, existing)
+	if len(objDiff.Changes) == 0 {
+		objDiff.Status = ObjectUnchanged
+	} else {
+		objDiff.Status = ObjectChanged
+	}
+
+	return objDiff, nil
+}
+
+// diffFields returns the fields of the existing object which differ from the desired object, mirroring
+// objectUpToDate: only labels and annotations are compared from the metadata, and the status is ignored.
+func diffFields(desired, existing *unstructured.Unstructured) []FieldChange {
+	var changes []FieldChange
+
+	for _, key := range sortedKeys(desired.Object) {
+		if key == "metadata" || key == "status" {
+			continue
+		}
+
+		changes = appendFieldChanges(changes, key, desired.Object[key], existing.Object[key])
+	}
+
+	changes = appendFieldChanges(changes, "metadata.labels",
+		toInterfaceMap(desired.GetLabels()), toInterfaceMap(existing.GetLabels()))
+	changes = appendFieldChanges(changes, "metadata.annotations",
+		toInterfaceMap(desired.GetAnnotations()), toInterfaceMap(existing.GetAnnotations()))
+
+	return changes
+}
+
+// appendFieldChanges appends the changes of the field at path to changes. Maps are compared field by field, while
+// lists and scalar values are compared as a whole.
+func appendFieldChanges(changes []FieldChange, path string, desired, actual interface{}) []FieldChange {
+	if desired == nil {
+		if actual != nil {
+			changes = append(changes, FieldChange{Path: path, Type: FieldRemoved, Actual: actual})
+		}
+
+		return changes
+	}
+
+	desiredMap, isMap := desired.(map[string]interface{})
+	actualMap, actualIsMap := actual.(map[string]interface{})
+
+	if !isMap || !actualIsMap {
+		switch {
+		case isSubset(desired, actual):
+		case actual == nil:
+			changes = append(changes, FieldChange{Path: path, Type: FieldAdded, Desired: desired})
+		default:
+			changes = append(changes, FieldChange{Path: path, Type: FieldChanged, Desired: desired, Actual: actual})
+		}
+
+		return changes
+	}
+
+	for _, key := range sortedKeys(desiredMap) {
+		changes = appendFieldChanges(changes, path+"."+key, desiredMap[key], actualMap[key])
+	}
+
+	return changes
+}
+
+// sortedKeys returns the keys of the map in a stable order.
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,138 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/kubernetes/scheme"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/rancher/turtles/testutil"
+)
+
+var _ = Describe("diff of import manifest", func() {
+	var (
+		cfg          ImportConfig
+		server       *testutil.ManifestServer
+		capiCluster  *clusterv1.Cluster
+		remoteClient client.Client
+	)
+
+	BeforeEach(func() {
+		server = testutil.NewManifestServer(incrementalManifest)
+		DeferCleanup(server.Close)
+
+		capiCluster = &clusterv1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-cluster",
+				Namespace: "test-ns",
+			},
+		}
+
+		// The namespace is up to date, the service account is missing and the config map has drifted.
+		remoteClient = fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "cattle-system"}},
+			&corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "cattle-config",
+					Namespace: "cattle-system",
+					Labels:    map[string]string{"extra": "label"},
+				},
+				Data: map[string]string{"url": "https://old.example.com", "extra": "value"},
+			},
+		).Build()
+
+		cfg = ImportConfig{
+			Client: fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(capiCluster).Build(),
+			RancherClient: testutil.NewRancherClientBuilder().
+				WithCluster("test-cluster-capi", "test-ns", testutil.ClusterStateNameSet, server.URL).Build(),
+			RemoteClientGetter: func(_ context.Context, _ string, _ client.Client, _ client.ObjectKey) (client.Client, error) {
+				return remoteClient, nil
+			},
+		}
+	})
+
+	It("should report missing, unchanged and changed objects", func() {
+		diff, err := DiffCluster(ctx, cfg, "test-ns", "test-cluster")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(diff.Cluster).To(Equal("test-ns/test-cluster"))
+		Expect(diff.Objects).To(HaveLen(3))
+
+		Expect(diff.Objects[0]).To(Equal(ObjectDiff{
+			APIVersion: "v1", Kind: "Namespace", Name: "cattle-system", Status: ObjectUnchanged,
+		}))
+		Expect(diff.Objects[1]).To(Equal(ObjectDiff{
+			APIVersion: "v1", Kind: "ServiceAccount", Namespace: "cattle-system", Name: "cattle", Status: ObjectMissing,
+		}))
+
+		configMap := diff.Objects[2]
+		Expect(configMap.Kind).To(Equal("ConfigMap"))
+		Expect(configMap.Status).To(Equal(ObjectChanged))
+		Expect(configMap.Changes).To(ConsistOf(
+			FieldChange{
+				Path: "data.url", Type: FieldChanged,
+				Desired: "https://rancher.example.com", Actual: "https://old.example.com",
+			},
+			FieldChange{Path: "metadata.labels.app", Type: FieldAdded, Desired: "cattle"},
+		))
+	})
+
+	It("should not change the downstream cluster nor create the registration token", func() {
+		_, err := DiffCluster(ctx, cfg, "test-ns", "test-cluster")
+		Expect(err).ToNot(HaveOccurred())
+
+		serviceAccount := &corev1.ServiceAccount{}
+		err = remoteClient.Get(ctx, client.ObjectKey{Namespace: "cattle-system", Name: "cattle"}, serviceAccount)
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+
+		configMap := &corev1.ConfigMap{}
+		Expect(remoteClient.Get(ctx, client.ObjectKey{Namespace: "cattle-system", Name: "cattle-config"}, configMap)).To(Succeed())
+		Expect(configMap.Data).To(HaveKeyWithValue("url", "https://old.example.com"))
+	})
+
+	It("should fail when the registration token is not ready", func() {
+		cfg.RancherClient = testutil.NewRancherClientBuilder().
+			WithObjects(testutil.RancherCluster("test-cluster-capi", "test-ns", testutil.ClusterStateNameSet)).Build()
+
+		_, err := DiffCluster(ctx, cfg, "test-ns", "test-cluster")
+		Expect(errors.Is(err, ErrManifestNotAvailable)).To(BeTrue())
+		Expect(server.Requests()).To(BeZero())
+	})
+
+	It("should report fields removed by the manifest", func() {
+		desired := &unstructured.Unstructured{Object: map[string]interface{}{
+			"spec": map[string]interface{}{"replicas": int64(1), "paused": nil},
+		}}
+		existing := &unstructured.Unstructured{Object: map[string]interface{}{
+			"spec": map[string]interface{}{"replicas": int64(1), "paused": true},
+		}}
+
+		Expect(diffFields(desired, existing)).To(ConsistOf(
+			FieldChange{Path: "spec.paused", Type: FieldRemoved, Actual: true},
+		))
+	})
+})
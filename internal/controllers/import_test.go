@@ -0,0 +1,136 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	managementv3 "github.com/rancher/turtles/internal/rancher/management/v3"
+	provisioningv1 "github.com/rancher/turtles/internal/rancher/provisioning/v1"
+	"github.com/rancher/turtles/testutil"
+)
+
+var _ = Describe("synchronous import", func() {
+	var (
+		cfg          ImportConfig
+		server       *testutil.ManifestServer
+		ns           *corev1.Namespace
+		capiCluster  *clusterv1.Cluster
+		remoteClient client.Client
+	)
+
+	BeforeEach(func() {
+		server = testutil.NewManifestServer(manifestWithServerFields)
+		DeferCleanup(server.Close)
+
+		ns = &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test-ns"}}
+		capiCluster = &clusterv1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-cluster",
+				Namespace: "test-ns",
+				Labels:    map[string]string{importLabelName: "true"},
+			},
+			Status: clusterv1.ClusterStatus{ControlPlaneReady: true},
+		}
+		remoteClient = fake.NewClientBuilder().WithScheme(scheme.Scheme).Build()
+
+		// The Rancher client assigns a management cluster and a registration token to created clusters, as Rancher
+		// would.
+		rancherClient := fake.NewClientBuilder().WithScheme(testutil.NewScheme()).WithObjects(ns.DeepCopy()).
+			WithStatusSubresource(&provisioningv1.Cluster{}, &managementv3.ClusterRegistrationToken{}).
+			WithInterceptorFuncs(interceptor.Funcs{
+				Create: func(ctx context.Context, cl client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+					if err := cl.Create(ctx, obj, opts...); err != nil {
+						return err
+					}
+
+					rancherCluster, ok := obj.(*provisioningv1.Cluster)
+					if !ok {
+						return nil
+					}
+
+					rancherCluster.Status.ClusterName = testutil.ManagementClusterName(capiCluster.Name)
+					if err := cl.Status().Update(ctx, rancherCluster); err != nil {
+						return err
+					}
+
+					return cl.Create(ctx, testutil.RegistrationToken(rancherCluster.Status.ClusterName, rancherCluster.Namespace, server.URL))
+				},
+			}).Build()
+
+		cfg = ImportConfig{
+			Client: fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(ns, capiCluster).
+				WithStatusSubresource(&clusterv1.Cluster{}).Build(),
+			RancherClient: rancherClient,
+			PollInterval:  10 * time.Millisecond,
+			RemoteClientGetter: func(_ context.Context, _ string, _ client.Client, _ client.ObjectKey) (client.Client, error) {
+				return remoteClient, nil
+			},
+		}
+	})
+
+	It("should import the cluster and apply the registration manifest", func() {
+		Expect(ImportCluster(ctx, cfg, "test-ns", "test-cluster")).To(Succeed())
+
+		rancherCluster := &provisioningv1.Cluster{}
+		Expect(cfg.RancherClient.Get(ctx, client.ObjectKey{Namespace: "test-ns", Name: "test-cluster-capi"}, rancherCluster)).To(Succeed())
+
+		Expect(server.Requests()).To(Equal(1))
+		Expect(remoteClient.Get(ctx, client.ObjectKey{Namespace: "cattle-system", Name: "cattle"}, &corev1.ServiceAccount{})).To(Succeed())
+	})
+
+	It("should return once the agent is deployed", func() {
+		cfg.RancherClient = testutil.NewRancherClientBuilder().
+			WithObjects(ns.DeepCopy()).
+			WithCluster("test-cluster-capi", "test-ns", testutil.ClusterStateAgentDeployed, server.URL).
+			Build()
+
+		Expect(ImportCluster(ctx, cfg, "test-ns", "test-cluster")).To(Succeed())
+		Expect(server.Requests()).To(BeZero())
+	})
+
+	It("should fail for clusters not marked for import", func() {
+		capiCluster.Labels = nil
+		Expect(cfg.Client.Update(ctx, capiCluster)).To(Succeed())
+
+		err := ImportCluster(ctx, cfg, "test-ns", "test-cluster")
+		Expect(err).To(MatchError(ErrNotImportable))
+		Expect(err.Error()).To(ContainSubstring("ImportLabel"))
+	})
+
+	It("should wait for the control plane until the context is done", func() {
+		capiCluster.Status.ControlPlaneReady = false
+		Expect(cfg.Client.Status().Update(ctx, capiCluster)).To(Succeed())
+
+		timeoutCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+		defer cancel()
+
+		Expect(ImportCluster(timeoutCtx, cfg, "test-ns", "test-cluster")).ToNot(Succeed())
+		Expect(server.Requests()).To(BeZero())
+	})
+})
@@ -0,0 +1,238 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	turtlesv1 "github.com/rancher/turtles/api/v1alpha1"
+)
+
+const (
+	metricsNamespace = "turtles"
+	metricsSubsystem = "import"
+
+	unknownProvider = "unknown"
+
+	// rancherClusterCreateFailed is the import failure reason of a Rancher cluster which couldn't be created.
+	rancherClusterCreateFailed = "RancherClusterCreateFailed"
+)
+
+// importDurationBuckets cover imports completing in seconds up to an hour, with boundaries at the durations import
+// SLOs are commonly defined for, e.g. 5 or 10 minutes, so that recording rules can use them directly.
+var importDurationBuckets = []float64{5, 15, 30, 60, 120, 180, 300, 600, 900, 1200, 1800, 2700, 3600}
+
+var (
+	manifestSizeBytes = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "manifest_size_bytes",
+		Help:      "Size in bytes of the downloaded registration manifests.",
+		Buckets:   prometheus.ExponentialBuckets(1024, 2, 10),
+	}, []string{"provider"})
+
+	manifestObjects = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "manifest_objects",
+		Help:      "Number of objects in the downloaded registration manifests.",
+		Buckets:   prometheus.LinearBuckets(5, 5, 10),
+	}, []string{"provider"})
+
+	controlPlaneWaitSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "control_plane_wait_seconds",
+		Help:      "Time clusters waited for their control plane to be ready, from the first reconcile observing it not ready.",
+		Buckets:   prometheus.ExponentialBuckets(30, 2, 10),
+	}, []string{"provider"})
+
+	reappliesSuppressed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "reapplies_suppressed_total",
+		Help:      "Number of import manifest re-applications skipped because the cluster was re-applied too recently.",
+	}, []string{"provider"})
+
+	importDurationSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "duration_seconds",
+		Help:      "Time clusters took to be imported, from becoming eligible for import to their Rancher cluster being ready.",
+		Buckets:   importDurationBuckets,
+	}, []string{"provider"})
+
+	importSLOBreaches = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "slo_breaches_total",
+		Help:      "Number of imports which took longer than the import SLO threshold.",
+	}, []string{"provider"})
+
+	managedClusters = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "managed_clusters",
+		Help:      "Number of CAPI clusters with a Rancher cluster managed by the controller.",
+	})
+
+	importsSucceeded = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "succeeded_total",
+		Help:      "Number of clusters whose registration manifest was applied for the first time.",
+	}, []string{"provider"})
+
+	importsSkipped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "skipped_total",
+		Help:      "Number of reconciles which skipped the import of a cluster, by reason.",
+	}, []string{"provider", "reason"})
+
+	importFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "failures_total",
+		Help:      "Number of reconciles which failed to create the Rancher cluster or apply the registration manifest, by reason.",
+	}, []string{"provider", "reason"})
+
+	readyToManifestAppliedSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "ready_to_manifest_applied_seconds",
+		Help:      "Time from the control plane of clusters being ready to their registration manifest being applied.",
+		Buckets:   importDurationBuckets,
+	}, []string{"provider"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		manifestSizeBytes,
+		manifestObjects,
+		controlPlaneWaitSeconds,
+		reappliesSuppressed,
+		importDurationSeconds,
+		importSLOBreaches,
+		managedClusters,
+		importsSucceeded,
+		importsSkipped,
+		importFailures,
+		readyToManifestAppliedSeconds,
+	)
+}
+
+// clusterProvider returns the infrastructure provider of the CAPI cluster used to label metrics.
+func clusterProvider(capiCluster *clusterv1.Cluster) string {
+	if capiCluster.Spec.InfrastructureRef == nil || capiCluster.Spec.InfrastructureRef.Kind == "" {
+		return unknownProvider
+	}
+
+	return capiCluster.Spec.InfrastructureRef.Kind
+}
+
+// recordManifestStats records the size and the object count of a registration manifest.
+func recordManifestStats(capiCluster *clusterv1.Cluster, size, objects int) {
+	provider := clusterProvider(capiCluster)
+
+	manifestSizeBytes.WithLabelValues(provider).Observe(float64(size))
+	manifestObjects.WithLabelValues(provider).Observe(float64(objects))
+}
+
+// recordImportDuration records the duration of a completed import, counting it as an SLO breach when it exceeded
+// the threshold. A zero threshold disables the breach counter.
+func recordImportDuration(capiCluster *clusterv1.Cluster, duration, sloThreshold time.Duration) {
+	provider := clusterProvider(capiCluster)
+
+	importDurationSeconds.WithLabelValues(provider).Observe(duration.Seconds())
+
+	if sloThreshold > 0 && duration > sloThreshold {
+		importSLOBreaches.WithLabelValues(provider).Inc()
+	}
+}
+
+// recordImportSkipped counts a reconcile skipping the import of the CAPI cluster for the reason.
+func recordImportSkipped(capiCluster *clusterv1.Cluster, reason string) {
+	importsSkipped.WithLabelValues(clusterProvider(capiCluster), reason).Inc()
+}
+
+// recordImportFailure counts a reconcile failing to import the CAPI cluster for the reason.
+func recordImportFailure(capiCluster *clusterv1.Cluster, reason string) {
+	importFailures.WithLabelValues(clusterProvider(capiCluster), reason).Inc()
+}
+
+// manifestApplyFailureReason returns the import failure reason of a registration manifest which couldn't be applied.
+func manifestApplyFailureReason(err error) string {
+	switch {
+	case errors.Is(err, errManifestVerification):
+		return turtlesv1.ManifestVerificationFailedReason
+	case isObjectForbidden(err):
+		return turtlesv1.ManifestApplyForbiddenReason
+	default:
+		return turtlesv1.ManifestApplyFailedReason
+	}
+}
+
+// recordImportSucceeded counts the first application of the registration manifest of the CAPI cluster, and records
+// the time since its control plane became ready when it is known.
+func recordImportSucceeded(capiCluster *clusterv1.Cluster, now time.Time) {
+	provider := clusterProvider(capiCluster)
+
+	importsSucceeded.WithLabelValues(provider).Inc()
+
+	ready := conditions.Get(capiCluster, clusterv1.ControlPlaneReadyCondition)
+	if ready == nil || ready.Status != corev1.ConditionTrue || ready.LastTransitionTime.IsZero() {
+		return
+	}
+
+	readyToManifestAppliedSeconds.WithLabelValues(provider).Observe(now.Sub(ready.LastTransitionTime.Time).Seconds())
+}
+
+// managedClusterSet tracks the CAPI clusters with a Rancher cluster managed by the controller, and reports their
+// number with the managed clusters gauge.
+type managedClusterSet struct {
+	lock     sync.Mutex
+	clusters map[client.ObjectKey]struct{}
+}
+
+// track records whether the CAPI cluster has a managed Rancher cluster.
+func (s *managedClusterSet) track(key client.ObjectKey, managed bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.clusters == nil {
+		s.clusters = map[client.ObjectKey]struct{}{}
+	}
+
+	if managed {
+		s.clusters[key] = struct{}{}
+	} else {
+		delete(s.clusters, key)
+	}
+
+	managedClusters.Set(float64(len(s.clusters)))
+}
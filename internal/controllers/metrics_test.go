@@ -0,0 +1,346 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	clocktesting "k8s.io/utils/clock/testing"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	turtlesv1 "github.com/rancher/turtles/api/v1alpha1"
+	"github.com/rancher/turtles/internal/controllers/testdata"
+	managementv3 "github.com/rancher/turtles/internal/rancher/management/v3"
+	provisioningv1 "github.com/rancher/turtles/internal/rancher/provisioning/v1"
+	turtlestestutil "github.com/rancher/turtles/testutil"
+	turtlesannotations "github.com/rancher/turtles/util/annotations"
+)
+
+var _ = Describe("import manifest metrics", func() {
+	It("should record the manifest size and object count", func() {
+		manifest := setTemplateParams(testdata.ImportManifest, map[string]string{"${TEST_CASE_NAME}": "metrics"})
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(manifest))
+		}))
+		defer server.Close()
+
+		capiCluster := &clusterv1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", Namespace: "test-ns"},
+			Spec: clusterv1.ClusterSpec{
+				InfrastructureRef: &corev1.ObjectReference{Kind: "MetricsTestCluster"},
+			},
+		}
+
+		rancherCluster := &provisioningv1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-cluster-capi", Namespace: "test-ns"},
+			Status:     provisioningv1.ClusterStatus{ClusterName: "c-m-metrics"},
+		}
+
+		token := &managementv3.ClusterRegistrationToken{
+			ObjectMeta: metav1.ObjectMeta{Name: "c-m-metrics", Namespace: "test-ns"},
+			Status:     managementv3.ClusterRegistrationTokenStatus{ManifestURL: server.URL},
+		}
+
+		remoteClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).Build()
+
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test-ns"}}
+
+		r := &CAPIImportReconciler{
+			Client:        fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(ns).Build(),
+			RancherClient: fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(rancherCluster, token, ns.DeepCopy()).Build(),
+			remoteClientGetter: func(_ context.Context, _ string, _ client.Client, _ client.ObjectKey) (client.Client, error) {
+				return remoteClient, nil
+			},
+			ApplyOptions: ApplyOptions{
+				RecordManifestStats: true,
+			},
+		}
+
+		_, err := r.reconcileNormal(ctx, capiCluster, rancherCluster)
+		Expect(err).ToNot(HaveOccurred())
+
+		size := &dto.Metric{}
+		Expect(manifestSizeBytes.WithLabelValues("MetricsTestCluster").(prometheus.Histogram).Write(size)).To(Succeed())
+		Expect(size.GetHistogram().GetSampleCount()).To(BeEquivalentTo(1))
+		Expect(size.GetHistogram().GetSampleSum()).To(BeEquivalentTo(len(manifest)))
+
+		objects := &dto.Metric{}
+		Expect(manifestObjects.WithLabelValues("MetricsTestCluster").(prometheus.Histogram).Write(objects)).To(Succeed())
+		Expect(objects.GetHistogram().GetSampleCount()).To(BeEquivalentTo(1))
+		Expect(objects.GetHistogram().GetSampleSum()).To(BeEquivalentTo(9))
+
+		Expect(capiCluster.Annotations).To(HaveKeyWithValue(turtlesannotations.ManifestSizeAnnotation, strconv.Itoa(len(manifest))))
+		Expect(capiCluster.Annotations).To(HaveKeyWithValue(turtlesannotations.ManifestObjectsAnnotation, "9"))
+	})
+})
+
+var _ = Describe("import duration metrics", func() {
+	var (
+		r           *CAPIImportReconciler
+		fakeClock   *clocktesting.FakeClock
+		capiCluster *clusterv1.Cluster
+		breaches    float64
+		imports     uint64
+	)
+
+	const provider = "ImportDurationTestCluster"
+
+	observedImports := func() uint64 {
+		metric := &dto.Metric{}
+		Expect(importDurationSeconds.WithLabelValues(provider).(prometheus.Histogram).Write(metric)).To(Succeed())
+
+		return metric.GetHistogram().GetSampleCount()
+	}
+
+	BeforeEach(func() {
+		fakeClock = clocktesting.NewFakeClock(time.Now())
+
+		r = &CAPIImportReconciler{
+			clock: fakeClock,
+			TimingOptions: TimingOptions{
+				ImportSLOThreshold: 10 * time.Minute,
+			},
+			RancherClusterOptions: RancherClusterOptions{
+				MirrorRancherReadiness: true,
+			},
+		}
+
+		capiCluster = &clusterv1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", Namespace: "test-ns"},
+			Spec: clusterv1.ClusterSpec{
+				InfrastructureRef: &corev1.ObjectReference{Kind: provider},
+			},
+		}
+
+		setConditionAt(capiCluster, &clusterv1.Condition{
+			Type:   turtlesv1.ImportEligibleCondition,
+			Status: corev1.ConditionTrue,
+		}, metav1.NewTime(fakeClock.Now()))
+
+		breaches = testutil.ToFloat64(importSLOBreaches.WithLabelValues(provider))
+		imports = observedImports()
+	})
+
+	It("should record the import duration without an SLO breach within the threshold", func() {
+		fakeClock.Step(4 * time.Minute)
+
+		r.trackImportDuration(ctx, capiCluster, true)
+
+		Expect(observedImports()).To(Equal(imports + 1))
+		Expect(testutil.ToFloat64(importSLOBreaches.WithLabelValues(provider))).To(Equal(breaches))
+	})
+
+	It("should count an import exceeding the SLO threshold as a breach", func() {
+		fakeClock.Step(12 * time.Minute)
+
+		r.trackImportDuration(ctx, capiCluster, true)
+
+		Expect(testutil.ToFloat64(importSLOBreaches.WithLabelValues(provider))).To(Equal(breaches + 1))
+
+		By("recording the import only once")
+		conditions.MarkTrue(capiCluster, turtlesv1.RancherClusterReadyCondition)
+		fakeClock.Step(time.Minute)
+
+		r.trackImportDuration(ctx, capiCluster, true)
+
+		Expect(testutil.ToFloat64(importSLOBreaches.WithLabelValues(provider))).To(Equal(breaches + 1))
+		Expect(observedImports()).To(Equal(imports + 1))
+	})
+
+	It("should not count breaches without a threshold", func() {
+		r.ImportSLOThreshold = 0
+		fakeClock.Step(time.Hour)
+
+		r.trackImportDuration(ctx, capiCluster, true)
+
+		Expect(testutil.ToFloat64(importSLOBreaches.WithLabelValues(provider))).To(Equal(breaches))
+	})
+
+	It("should not record clusters whose Rancher cluster is not ready", func() {
+		fakeClock.Step(12 * time.Minute)
+
+		r.trackImportDuration(ctx, capiCluster, false)
+
+		Expect(testutil.ToFloat64(importSLOBreaches.WithLabelValues(provider))).To(Equal(breaches))
+		Expect(observedImports()).To(Equal(imports))
+	})
+
+	It("should have buckets from seconds to tens of minutes including the SLO boundaries", func() {
+		Expect(importDurationBuckets[0]).To(BeNumerically("<=", 5))
+		Expect(importDurationBuckets[len(importDurationBuckets)-1]).To(BeNumerically(">=", 30*60))
+		Expect(importDurationBuckets).To(ContainElements(float64(5*60), float64(10*60)))
+	})
+})
+
+var _ = Describe("import outcome metrics", func() {
+	var (
+		r           *CAPIImportReconciler
+		fakeClock   *clocktesting.FakeClock
+		capiCluster *clusterv1.Cluster
+		builder     *turtlestestutil.RancherClientBuilder
+	)
+
+	const provider = "ImportOutcomeTestCluster"
+
+	capiClusterKey := client.ObjectKey{Namespace: "test-ns", Name: "test-cluster"}
+	rancherClusterKey := client.ObjectKey{Namespace: "test-ns", Name: "test-cluster-capi"}
+
+	reconcileCluster := func() error {
+		_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: capiClusterKey})
+		return err
+	}
+
+	appliedSamples := func() (uint64, float64) {
+		metric := &dto.Metric{}
+		Expect(readyToManifestAppliedSeconds.WithLabelValues(provider).(prometheus.Histogram).Write(metric)).To(Succeed())
+
+		return metric.GetHistogram().GetSampleCount(), metric.GetHistogram().GetSampleSum()
+	}
+
+	BeforeEach(func() {
+		fakeClock = clocktesting.NewFakeClock(time.Date(2024, 1, 3, 12, 0, 0, 0, time.UTC))
+
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test-ns"}}
+		capiCluster = &clusterv1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      capiClusterKey.Name,
+				Namespace: capiClusterKey.Namespace,
+				Labels:    map[string]string{importLabelName: "true"},
+			},
+			Spec: clusterv1.ClusterSpec{
+				InfrastructureRef: &corev1.ObjectReference{Kind: provider},
+			},
+			Status: clusterv1.ClusterStatus{ControlPlaneReady: true},
+		}
+		setConditionAt(capiCluster, &clusterv1.Condition{
+			Type:   clusterv1.ControlPlaneReadyCondition,
+			Status: corev1.ConditionTrue,
+		}, metav1.NewTime(fakeClock.Now().Add(-2*time.Minute)))
+
+		builder = turtlestestutil.NewRancherClientBuilder().WithObjects(ns.DeepCopy())
+
+		r = &CAPIImportReconciler{
+			Client: fake.NewClientBuilder().WithScheme(scheme.Scheme).
+				WithObjects(ns, capiCluster).WithStatusSubresource(&clusterv1.Cluster{}).Build(),
+			recorder: record.NewFakeRecorder(10),
+			clock:    fakeClock,
+		}
+	})
+
+	It("should count a cluster imported through to the manifest apply", func() {
+		server := turtlestestutil.NewManifestServer(incrementalManifest)
+		DeferCleanup(server.Close)
+
+		remoteClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).Build()
+		r.RancherClient = builder.Build()
+		r.remoteClientGetter = func(_ context.Context, _ string, _ client.Client, _ client.ObjectKey) (client.Client, error) {
+			return remoteClient, nil
+		}
+
+		succeeded := testutil.ToFloat64(importsSucceeded.WithLabelValues(provider))
+		count, sum := appliedSamples()
+
+		Expect(reconcileCluster()).To(Succeed())
+		Expect(testutil.ToFloat64(managedClusters)).To(BeEquivalentTo(1))
+
+		By("assigning a cluster name and a registration token to the Rancher cluster")
+		rancherCluster := &provisioningv1.Cluster{}
+		Expect(r.RancherClient.Get(ctx, rancherClusterKey, rancherCluster)).To(Succeed())
+		rancherCluster.Status.ClusterName = "c-m-outcome"
+		Expect(r.RancherClient.Status().Update(ctx, rancherCluster)).To(Succeed())
+		Expect(r.RancherClient.Create(ctx, turtlestestutil.RegistrationToken("c-m-outcome", "test-ns", server.URL))).To(Succeed())
+
+		Expect(reconcileCluster()).To(Succeed())
+		Expect(server.Requests()).To(Equal(1))
+		Expect(testutil.ToFloat64(importsSucceeded.WithLabelValues(provider))).To(Equal(succeeded + 1))
+
+		newCount, newSum := appliedSamples()
+		Expect(newCount).To(Equal(count + 1))
+		Expect(newSum - sum).To(BeNumerically("~", (2 * time.Minute).Seconds()))
+
+		By("counting the import only once")
+		Expect(reconcileCluster()).To(Succeed())
+		Expect(testutil.ToFloat64(importsSucceeded.WithLabelValues(provider))).To(Equal(succeeded + 1))
+
+		By("forgetting the cluster once deleted")
+		Expect(r.Client.Delete(ctx, capiCluster)).To(Succeed())
+		Expect(reconcileCluster()).To(Succeed())
+		Expect(testutil.ToFloat64(managedClusters)).To(BeZero())
+	})
+
+	It("should count a cluster not marked for import as skipped", func() {
+		r.RancherClient = builder.Build()
+
+		capiCluster.Labels = nil
+		Expect(r.Client.Update(ctx, capiCluster)).To(Succeed())
+
+		skipped := testutil.ToFloat64(importsSkipped.WithLabelValues(provider, turtlesv1.ImportSkippedReason))
+
+		Expect(reconcileCluster()).To(Succeed())
+		Expect(testutil.ToFloat64(importsSkipped.WithLabelValues(provider, turtlesv1.ImportSkippedReason))).To(Equal(skipped + 1))
+	})
+
+	It("should count a Rancher cluster which can't be created as a failure", func() {
+		r.RancherClient = interceptor.NewClient(builder.Build().(client.WithWatch), interceptor.Funcs{
+			Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+				if _, ok := obj.(*provisioningv1.Cluster); ok {
+					return errors.New("rancher is unavailable")
+				}
+
+				return c.Create(ctx, obj, opts...)
+			},
+		})
+
+		failures := testutil.ToFloat64(importFailures.WithLabelValues(provider, rancherClusterCreateFailed))
+
+		Expect(reconcileCluster()).ToNot(Succeed())
+		Expect(testutil.ToFloat64(importFailures.WithLabelValues(provider, rancherClusterCreateFailed))).To(Equal(failures + 1))
+	})
+
+	DescribeTable("manifestApplyFailureReason",
+		func(err error, reason string) {
+			Expect(manifestApplyFailureReason(err)).To(Equal(reason))
+		},
+		Entry("verification", fmt.Errorf("checking manifest: %w", errManifestVerification), turtlesv1.ManifestVerificationFailedReason),
+		Entry("forbidden", newForbiddenObjectError("create", &corev1.ConfigMap{},
+			apierrors.NewForbidden(corev1.Resource("configmaps"), "cattle", errors.New("denied"))), turtlesv1.ManifestApplyForbiddenReason),
+		Entry("other", errors.New("connection refused"), turtlesv1.ManifestApplyFailedReason),
+	)
+})
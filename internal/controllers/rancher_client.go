@@ -0,0 +1,57 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/cluster-api/util/secret"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// NewRemoteRancherClient builds a client for a Rancher server running in another cluster than the CAPI clusters. The
+// kubeconfig of the Rancher cluster is read from the value key of the secret, like the CAPI cluster kubeconfigs. The
+// reconcilers using the client must set RemoteRancher.
+func NewRemoteRancherClient(ctx context.Context, reader client.Reader, secretKey client.ObjectKey,
+	scheme *runtime.Scheme,
+) (client.Client, error) {
+	kubeconfigSecret := &corev1.Secret{}
+	if err := reader.Get(ctx, secretKey, kubeconfigSecret); err != nil {
+		return nil, fmt.Errorf("getting Rancher kubeconfig secret %s: %w", secretKey, err)
+	}
+
+	kubeconfig, ok := kubeconfigSecret.Data[secret.KubeconfigDataName]
+	if !ok {
+		return nil, fmt.Errorf("rancher kubeconfig secret %s has no %s key", secretKey, secret.KubeconfigDataName)
+	}
+
+	restConfig, err := clientcmd.RESTConfigFromKubeConfig(kubeconfig)
+	if err != nil {
+		return nil, fmt.Errorf("loading Rancher kubeconfig from secret %s: %w", secretKey, err)
+	}
+
+	rancherClient, err := client.New(restConfig, client.Options{Scheme: scheme})
+	if err != nil {
+		return nil, fmt.Errorf("creating Rancher client: %w", err)
+	}
+
+	return rancherClient, nil
+}
@@ -0,0 +1,88 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const remoteRancherKubeconfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: rancher
+  cluster:
+    server: https://rancher.example.com:6443
+contexts:
+- name: rancher
+  context:
+    cluster: rancher
+    user: turtles
+current-context: rancher
+users:
+- name: turtles
+  user:
+    token: abc
+`
+
+var _ = Describe("remote Rancher client", func() {
+	secretKey := client.ObjectKey{Namespace: "rancher-turtles-system", Name: "rancher-kubeconfig"}
+
+	newSecret := func(data map[string][]byte) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: secretKey.Namespace, Name: secretKey.Name},
+			Data:       data,
+		}
+	}
+
+	It("should build a client from the kubeconfig secret", func() {
+		reader := fake.NewClientBuilder().WithScheme(scheme.Scheme).
+			WithObjects(newSecret(map[string][]byte{"value": []byte(remoteRancherKubeconfig)})).Build()
+
+		rancherClient, err := NewRemoteRancherClient(ctx, reader, secretKey, scheme.Scheme)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(rancherClient).ToNot(BeNil())
+	})
+
+	It("should fail without the kubeconfig secret", func() {
+		reader := fake.NewClientBuilder().WithScheme(scheme.Scheme).Build()
+
+		_, err := NewRemoteRancherClient(ctx, reader, secretKey, scheme.Scheme)
+		Expect(err).To(MatchError(ContainSubstring("getting Rancher kubeconfig secret rancher-turtles-system/rancher-kubeconfig")))
+	})
+
+	It("should fail without the kubeconfig key", func() {
+		reader := fake.NewClientBuilder().WithScheme(scheme.Scheme).
+			WithObjects(newSecret(map[string][]byte{"kubeconfig": []byte(remoteRancherKubeconfig)})).Build()
+
+		_, err := NewRemoteRancherClient(ctx, reader, secretKey, scheme.Scheme)
+		Expect(err).To(MatchError(ContainSubstring("has no value key")))
+	})
+
+	It("should fail with an invalid kubeconfig", func() {
+		reader := fake.NewClientBuilder().WithScheme(scheme.Scheme).
+			WithObjects(newSecret(map[string][]byte{"value": []byte("not a kubeconfig")})).Build()
+
+		_, err := NewRemoteRancherClient(ctx, reader, secretKey, scheme.Scheme)
+		Expect(err).To(MatchError(ContainSubstring("loading Rancher kubeconfig")))
+	})
+})
@@ -0,0 +1,117 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	provisioningv1 "github.com/rancher/turtles/internal/rancher/provisioning/v1"
+	turtlesannotations "github.com/rancher/turtles/util/annotations"
+	turtlesnaming "github.com/rancher/turtles/util/naming"
+)
+
+// +kubebuilder:webhook:path=/validate-provisioning-cattle-io-v1-cluster,mutating=false,failurePolicy=fail,sideEffects=None,groups=provisioning.cattle.io,resources=clusters,verbs=create;update,versions=v1,name=vcluster.turtles.cattle.io,admissionReviewVersions=v1
+
+// RancherClusterValidator validates the Rancher clusters created by turtles for CAPI clusters. Rancher clusters
+// created by anyone else are admitted as is.
+type RancherClusterValidator struct {
+	// OwnedLabel is the label marking the Rancher clusters created by the controller. Defaults to DefaultOwnedLabel.
+	OwnedLabel string
+}
+
+var _ webhook.CustomValidator = &RancherClusterValidator{}
+
+// SetupWebhookWithManager registers the validating webhook for Rancher clusters with the manager.
+func (v *RancherClusterValidator) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&provisioningv1.Cluster{}).
+		WithValidator(v).
+		Complete()
+}
+
+// ValidateCreate validates a Rancher cluster being created.
+func (v *RancherClusterValidator) ValidateCreate(_ context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return nil, v.validate(obj)
+}
+
+// ValidateUpdate validates a Rancher cluster being updated.
+func (v *RancherClusterValidator) ValidateUpdate(_ context.Context, _, newObj runtime.Object) (admission.Warnings, error) {
+	return nil, v.validate(newObj)
+}
+
+// ValidateDelete admits every Rancher cluster deletion.
+func (v *RancherClusterValidator) ValidateDelete(_ context.Context, _ runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+func (v *RancherClusterValidator) validate(obj runtime.Object) error {
+	rancherCluster, ok := obj.(*provisioningv1.Cluster)
+	if !ok {
+		return apierrors.NewBadRequest(fmt.Sprintf("expected a Rancher cluster but got %T", obj))
+	}
+
+	if !createdByTurtles(rancherCluster) {
+		return nil
+	}
+
+	var allErrs field.ErrorList
+
+	ownedLabel := v.OwnedLabel
+	if ownedLabel == "" {
+		ownedLabel = ownedLabelName
+	}
+
+	if _, owned := rancherCluster.GetLabels()[ownedLabel]; !owned {
+		allErrs = append(allErrs, field.Required(field.NewPath("metadata", "labels").Key(ownedLabel),
+			"Rancher clusters created for CAPI clusters must be labeled as owned"))
+	}
+
+	if name := rancherCluster.GetName(); len(name) > validation.DNS1123LabelMaxLength {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("metadata", "name"), name,
+			fmt.Sprintf("must be no more than %d characters once the %q suffix is appended to CAPI cluster %q",
+				validation.DNS1123LabelMaxLength, turtlesnaming.Suffix(), capiClusterName(rancherCluster))))
+	} else if errs := validation.IsDNS1123Label(name); len(errs) > 0 {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("metadata", "name"), name, strings.Join(errs, ", ")))
+	}
+
+	if len(allErrs) == 0 {
+		return nil
+	}
+
+	return apierrors.NewInvalid(provisioningv1.GroupVersion.WithKind("Cluster").GroupKind(), rancherCluster.GetName(), allErrs)
+}
+
+// createdByTurtles tells whether the Rancher cluster was created by turtles for a CAPI cluster.
+func createdByTurtles(rancherCluster *provisioningv1.Cluster) bool {
+	if _, ok := rancherCluster.GetAnnotations()[turtlesannotations.CAPIClusterNameAnnotation]; ok {
+		return true
+	}
+
+	_, ok := rancherCluster.GetLabels()[capiClusterOwner]
+
+	return ok
+}
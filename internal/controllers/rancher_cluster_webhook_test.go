@@ -0,0 +1,143 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+
+	provisioningv1 "github.com/rancher/turtles/internal/rancher/provisioning/v1"
+	turtlesannotations "github.com/rancher/turtles/util/annotations"
+	turtlesnaming "github.com/rancher/turtles/util/naming"
+)
+
+var _ = Describe("Rancher cluster validating webhook", func() {
+	var (
+		validator *RancherClusterValidator
+		ctx       context.Context
+	)
+
+	BeforeEach(func() {
+		validator = &RancherClusterValidator{}
+		ctx = context.Background()
+	})
+
+	turtlesCluster := func(capiName string) *provisioningv1.Cluster {
+		capiCluster := &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: capiName, Namespace: "default", UID: "uid"}}
+		rancherCluster := &provisioningv1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      turtlesnaming.Name(capiName).ToRancherName(),
+				Namespace: "default",
+				Labels:    map[string]string{ownedLabelName: ""},
+				Annotations: map[string]string{
+					turtlesannotations.CAPIClusterNameAnnotation: capiName,
+				},
+			},
+		}
+		linkRancherCluster(capiCluster, rancherCluster, true)
+
+		return rancherCluster
+	}
+
+	It("should accept a Rancher cluster created by turtles", func() {
+		warnings, err := validator.ValidateCreate(ctx, turtlesCluster("cluster1"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(warnings).To(BeEmpty())
+	})
+
+	It("should accept a Rancher cluster not created by turtles", func() {
+		rancherCluster := &provisioningv1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{Name: strings.Repeat("a", 70), Namespace: "default"},
+		}
+
+		_, err := validator.ValidateCreate(ctx, rancherCluster)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should reject a Rancher cluster created by turtles without the owned label", func() {
+		rancherCluster := turtlesCluster("cluster1")
+		delete(rancherCluster.Labels, ownedLabelName)
+
+		_, err := validator.ValidateCreate(ctx, rancherCluster)
+		Expect(apierrors.IsInvalid(err)).To(BeTrue())
+		Expect(err).To(MatchError(ContainSubstring(ownedLabelName)))
+	})
+
+	It("should check the configured owned label", func() {
+		validator.OwnedLabel = "example.com/owned"
+
+		_, err := validator.ValidateCreate(ctx, turtlesCluster("cluster1"))
+		Expect(err).To(MatchError(ContainSubstring("example.com/owned")))
+
+		rancherCluster := turtlesCluster("cluster1")
+		rancherCluster.Labels["example.com/owned"] = ""
+
+		_, err = validator.ValidateCreate(ctx, rancherCluster)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should reject a Rancher cluster whose name exceeds 63 characters with the suffix", func() {
+		capiName := strings.Repeat("a", 63-len(turtlesnaming.Suffix())+1)
+		rancherCluster := turtlesCluster(capiName)
+		rancherCluster.Name = capiName + turtlesnaming.Suffix()
+
+		_, err := validator.ValidateCreate(ctx, rancherCluster)
+		Expect(apierrors.IsInvalid(err)).To(BeTrue())
+		Expect(err).To(MatchError(ContainSubstring("must be no more than 63 characters")))
+		Expect(err).To(MatchError(ContainSubstring(capiName)))
+	})
+
+	It("should accept a Rancher cluster whose name is exactly 63 characters with the suffix", func() {
+		capiName := strings.Repeat("a", 63-len(turtlesnaming.Suffix()))
+
+		_, err := validator.ValidateCreate(ctx, turtlesCluster(capiName))
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should accept a Rancher cluster whose name was truncated", func() {
+		_, err := validator.ValidateCreate(ctx, turtlesCluster(strings.Repeat("a", 100)))
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should reject an update removing the owned label", func() {
+		oldCluster := turtlesCluster("cluster1")
+		newCluster := oldCluster.DeepCopy()
+		delete(newCluster.Labels, ownedLabelName)
+
+		_, err := validator.ValidateUpdate(ctx, oldCluster, newCluster)
+		Expect(apierrors.IsInvalid(err)).To(BeTrue())
+	})
+
+	It("should accept deletions", func() {
+		rancherCluster := turtlesCluster("cluster1")
+		delete(rancherCluster.Labels, ownedLabelName)
+
+		_, err := validator.ValidateDelete(ctx, rancherCluster)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should reject objects which are not Rancher clusters", func() {
+		_, err := validator.ValidateCreate(ctx, &clusterv1.Cluster{})
+		Expect(apierrors.IsBadRequest(err)).To(BeTrue())
+	})
+})
@@ -0,0 +1,75 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/types"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/controllers/remote"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// remoteClientCache keeps the clients of the downstream clusters between reconciles. Entries are bound to the UID of
+// the CAPI cluster, so a cluster recreated with the same name never reuses the client of its predecessor.
+type remoteClientCache struct {
+	lock    sync.Mutex
+	clients map[client.ObjectKey]cachedRemoteClient
+}
+
+type cachedRemoteClient struct {
+	uid    types.UID
+	client client.Client
+}
+
+// get returns the cached client of the CAPI cluster, creating it with the getter when missing or when the cached one
+// belongs to a previous cluster with the same name.
+func (c *remoteClientCache) get(ctx context.Context, capiCluster *clusterv1.Cluster, getter remote.ClusterClientGetter,
+	cl client.Client,
+) (client.Client, error) {
+	key := client.ObjectKeyFromObject(capiCluster)
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if cached, ok := c.clients[key]; ok && cached.uid == capiCluster.UID {
+		return cached.client, nil
+	}
+
+	remoteClient, err := getter(ctx, capiCluster.Name, cl, key)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.clients == nil {
+		c.clients = map[client.ObjectKey]cachedRemoteClient{}
+	}
+
+	c.clients[key] = cachedRemoteClient{uid: capiCluster.UID, client: remoteClient}
+
+	return remoteClient, nil
+}
+
+// evict drops the cached client of the CAPI cluster.
+func (c *remoteClientCache) evict(key client.ObjectKey) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	delete(c.clients, key)
+}
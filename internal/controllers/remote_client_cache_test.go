@@ -0,0 +1,124 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var _ = Describe("remote client cache", func() {
+	var (
+		r           *CAPIImportReconciler
+		capiCluster *clusterv1.Cluster
+		created     []client.Client
+	)
+
+	BeforeEach(func() {
+		created = nil
+		capiCluster = &clusterv1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", Namespace: "test-ns", UID: "first"},
+		}
+
+		r = &CAPIImportReconciler{
+			Client:             fake.NewClientBuilder().WithScheme(scheme.Scheme).Build(),
+			CacheRemoteClients: true,
+			remoteClientGetter: func(_ context.Context, _ string, _ client.Client, _ client.ObjectKey) (client.Client, error) {
+				remoteClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).Build()
+				created = append(created, remoteClient)
+
+				return remoteClient, nil
+			},
+		}
+	})
+
+	It("should reuse the client of the same cluster", func() {
+		first, err := r.remoteClient(ctx, capiCluster)
+		Expect(err).ToNot(HaveOccurred())
+
+		second, err := r.remoteClient(ctx, capiCluster)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(created).To(HaveLen(1))
+		Expect(second).To(BeIdenticalTo(first))
+	})
+
+	It("should evict the client when the CAPI cluster is deleted", func() {
+		first, err := r.remoteClient(ctx, capiCluster)
+		Expect(err).ToNot(HaveOccurred())
+
+		res, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(capiCluster)})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res).To(Equal(reconcile.Result{}))
+		Expect(r.remoteClients.clients).ToNot(HaveKey(client.ObjectKeyFromObject(capiCluster)))
+
+		recreated := capiCluster.DeepCopy()
+		recreated.UID = "second"
+
+		second, err := r.remoteClient(ctx, recreated)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(created).To(HaveLen(2))
+		Expect(second).ToNot(BeIdenticalTo(first))
+	})
+
+	It("should evict the client when the Rancher cluster is deleted", func() {
+		_, err := r.remoteClient(ctx, capiCluster)
+		Expect(err).ToNot(HaveOccurred())
+
+		_, err = r.reconcileDelete(ctx, capiCluster)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(r.remoteClients.clients).ToNot(HaveKey(client.ObjectKeyFromObject(capiCluster)))
+
+		_, err = r.remoteClient(ctx, capiCluster)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(created).To(HaveLen(2))
+	})
+
+	It("should not reuse the client of a previous cluster with the same name", func() {
+		first, err := r.remoteClient(ctx, capiCluster)
+		Expect(err).ToNot(HaveOccurred())
+
+		recreated := capiCluster.DeepCopy()
+		recreated.UID = "second"
+
+		second, err := r.remoteClient(ctx, recreated)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(created).To(HaveLen(2))
+		Expect(second).ToNot(BeIdenticalTo(first))
+	})
+
+	It("should not cache clients when disabled", func() {
+		r.CacheRemoteClients = false
+
+		for range 2 {
+			_, err := r.remoteClient(ctx, capiCluster)
+			Expect(err).ToNot(HaveOccurred())
+		}
+
+		Expect(created).To(HaveLen(2))
+	})
+})
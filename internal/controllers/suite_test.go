@@ -32,6 +32,7 @@ import (
 	provisioningv1 "github.com/rancher/turtles/internal/rancher/provisioning/v1"
 	operatorv1 "sigs.k8s.io/cluster-api-operator/api/v1alpha2"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	expv1 "sigs.k8s.io/cluster-api/exp/api/v1beta1"
 
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/client-go/rest"
@@ -52,6 +53,7 @@ var (
 
 func setup() {
 	utilruntime.Must(clusterv1.AddToScheme(scheme.Scheme))
+	utilruntime.Must(expv1.AddToScheme(scheme.Scheme))
 	utilruntime.Must(operatorv1.AddToScheme(scheme.Scheme))
 	utilruntime.Must(turtlesv1.AddToScheme(scheme.Scheme))
 	utilruntime.Must(provisioningv1.AddToScheme(scheme.Scheme))
@@ -40,6 +40,10 @@ type ClusterRegistrationTokenSpec struct {
 // ClusterRegistrationTokenStatus is the struct representing the status of a Rancher ClusterRegistrationToken.
 type ClusterRegistrationTokenStatus struct {
 	ManifestURL string `json:"manifestUrl"`
+	// Token is the secret the agent registers with.
+	Token string `json:"token,omitempty"`
+	// ExpiresAt is the time the token expires at. Tokens without expiry don't set it.
+	ExpiresAt *metav1.Time `json:"expiresAt,omitempty"`
 }
 
 // ClusterRegistrationTokenList contains a list of ClusterRegistrationTokens.
@@ -17,6 +17,7 @@ limitations under the License.
 package v1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -38,9 +39,60 @@ type ClusterSpec struct {
 
 // ClusterStatus is the struct representing the status of a Rancher Cluster.
 type ClusterStatus struct {
-	ClusterName   string `json:"clusterName,omitempty"`
-	AgentDeployed bool   `json:"agentDeployed,omitempty"`
-	Ready         bool   `json:"ready,omitempty"`
+	ClusterName   string      `json:"clusterName,omitempty"`
+	AgentDeployed bool        `json:"agentDeployed,omitempty"`
+	Ready         bool        `json:"ready,omitempty"`
+	Conditions    []Condition `json:"conditions,omitempty"`
+}
+
+const (
+	// ClusterProvisionedCondition is true once Rancher provisioned the cluster.
+	ClusterProvisionedCondition = "Provisioned"
+	// ClusterAgentDeployedCondition is true once Rancher deployed its agent on the cluster.
+	ClusterAgentDeployedCondition = "AgentDeployed"
+	// ClusterReadyCondition is true once the cluster is ready.
+	ClusterReadyCondition = "Ready"
+)
+
+// Condition is a condition of a Rancher Cluster, in the generic condition format of Rancher.
+type Condition struct {
+	Type               string                 `json:"type"`
+	Status             corev1.ConditionStatus `json:"status"`
+	LastUpdateTime     string                 `json:"lastUpdateTime,omitempty"`
+	LastTransitionTime string                 `json:"lastTransitionTime,omitempty"`
+	Reason             string                 `json:"reason,omitempty"`
+	Message            string                 `json:"message,omitempty"`
+}
+
+// GetCondition returns the condition of the given type, nil when missing.
+func (s *ClusterStatus) GetCondition(conditionType string) *Condition {
+	for i := range s.Conditions {
+		if s.Conditions[i].Type == conditionType {
+			return &s.Conditions[i]
+		}
+	}
+
+	return nil
+}
+
+// IsAgentDeployed returns whether Rancher deployed its agent on the cluster. The AgentDeployed condition takes
+// precedence over the agentDeployed field, which older Rancher releases only set.
+func (s *ClusterStatus) IsAgentDeployed() bool {
+	if condition := s.GetCondition(ClusterAgentDeployedCondition); condition != nil {
+		return condition.Status == corev1.ConditionTrue
+	}
+
+	return s.AgentDeployed
+}
+
+// IsReady returns whether the cluster is ready. The Ready condition takes precedence over the ready field, which
+// older Rancher releases only set.
+func (s *ClusterStatus) IsReady() bool {
+	if condition := s.GetCondition(ClusterReadyCondition); condition != nil {
+		return condition.Status == corev1.ConditionTrue
+	}
+
+	return s.Ready
 }
 
 // ClusterList contains a list of ClusterList.
@@ -0,0 +1,101 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"encoding/json"
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+)
+
+var _ = Describe("Cluster status", func() {
+	It("should unmarshal the conditions set by Rancher", func() {
+		status := ClusterStatus{}
+		Expect(json.Unmarshal([]byte(`{
+			"clusterName": "c-m-test",
+			"agentDeployed": true,
+			"ready": true,
+			"conditions": [
+				{"type": "Provisioned", "status": "True", "lastUpdateTime": "2024-01-01T00:00:00Z"},
+				{"type": "AgentDeployed", "status": "True", "lastUpdateTime": "2024-01-01T00:00:00Z"},
+				{"type": "Ready", "status": "False", "reason": "Waiting", "message": "waiting for agent to check in",
+				 "lastTransitionTime": ""}
+			]
+		}`), &status)).To(Succeed())
+
+		Expect(status.ClusterName).To(Equal("c-m-test"))
+		Expect(status.Conditions).To(HaveLen(3))
+		Expect(status.GetCondition(ClusterReadyCondition)).To(Equal(&Condition{
+			Type:    ClusterReadyCondition,
+			Status:  corev1.ConditionFalse,
+			Reason:  "Waiting",
+			Message: "waiting for agent to check in",
+		}))
+		Expect(status.GetCondition("Updated")).To(BeNil())
+	})
+
+	It("should round-trip the status", func() {
+		status := ClusterStatus{
+			ClusterName:   "c-m-test",
+			AgentDeployed: true,
+			Conditions: []Condition{
+				{Type: ClusterAgentDeployedCondition, Status: corev1.ConditionTrue, LastUpdateTime: "2024-01-01T00:00:00Z"},
+			},
+		}
+
+		data, err := json.Marshal(status)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(data)).ToNot(ContainSubstring("ready"))
+
+		decoded := ClusterStatus{}
+		Expect(json.Unmarshal(data, &decoded)).To(Succeed())
+		Expect(decoded).To(Equal(status))
+	})
+
+	It("should prefer the conditions over the fields", func() {
+		status := ClusterStatus{AgentDeployed: true, Ready: true}
+		Expect(status.IsAgentDeployed()).To(BeTrue())
+		Expect(status.IsReady()).To(BeTrue())
+
+		status.Conditions = []Condition{
+			{Type: ClusterAgentDeployedCondition, Status: corev1.ConditionTrue},
+			{Type: ClusterReadyCondition, Status: corev1.ConditionFalse},
+		}
+		Expect(status.IsAgentDeployed()).To(BeTrue())
+		Expect(status.IsReady()).To(BeFalse())
+
+		status = ClusterStatus{Conditions: []Condition{{Type: ClusterReadyCondition, Status: corev1.ConditionTrue}}}
+		Expect(status.IsAgentDeployed()).To(BeFalse())
+		Expect(status.IsReady()).To(BeTrue())
+	})
+
+	It("should deep copy the conditions", func() {
+		cluster := &Cluster{Status: ClusterStatus{Conditions: []Condition{{Type: ClusterReadyCondition, Status: corev1.ConditionTrue}}}}
+
+		copied := cluster.DeepCopy()
+		copied.Status.Conditions[0].Status = corev1.ConditionFalse
+		Expect(cluster.Status.IsReady()).To(BeTrue())
+	})
+})
+
+func TestProvisioningV1(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Rancher provisioning v1 types")
+}
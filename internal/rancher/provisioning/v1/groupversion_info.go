@@ -20,6 +20,7 @@ limitations under the License.
 package v1
 
 import (
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"sigs.k8s.io/controller-runtime/pkg/scheme"
 )
@@ -34,3 +35,9 @@ var (
 	// AddToScheme adds the types in this group-version to the given scheme.
 	AddToScheme = SchemeBuilder.AddToScheme
 )
+
+// AddToSchemeWithGroupVersion returns a function registering the Cluster types under the given group version instead
+// of the default one, for Rancher releases or forks serving the provisioning API elsewhere.
+func AddToSchemeWithGroupVersion(gv schema.GroupVersion) func(*runtime.Scheme) error {
+	return (&scheme.Builder{GroupVersion: gv}).Register(&Cluster{}, &ClusterList{}).AddToScheme
+}
@@ -18,14 +18,21 @@ package main
 
 import (
 	"context"
+	"crypto/x509"
+	"errors"
 	"flag"
 	"fmt"
+	"net/http"
 	"os"
+	"strings"
 	"time"
 
+	"github.com/blang/semver/v4"
 	"github.com/spf13/pflag"
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
@@ -44,15 +51,29 @@ import (
 
 	operatorv1 "sigs.k8s.io/cluster-api-operator/api/v1alpha2"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	expv1 "sigs.k8s.io/cluster-api/exp/api/v1beta1"
 
 	turtlesv1 "github.com/rancher/turtles/api/v1alpha1"
 	"github.com/rancher/turtles/feature"
 	"github.com/rancher/turtles/internal/controllers"
 	managementv3 "github.com/rancher/turtles/internal/rancher/management/v3"
 	provisioningv1 "github.com/rancher/turtles/internal/rancher/provisioning/v1"
+	"github.com/rancher/turtles/util"
+	turtlesannotations "github.com/rancher/turtles/util/annotations"
+	turtlesnaming "github.com/rancher/turtles/util/naming"
+	"github.com/rancher/turtles/util/schedule"
+	"github.com/rancher/turtles/util/scheduling"
 )
 
-const maxDuration time.Duration = 1<<63 - 1
+const (
+	maxDuration time.Duration = 1<<63 - 1
+
+	debugPath         = "/debug/import"
+	configPath        = "/debug/config"
+	selfCheckPath     = "/selfcheck"
+	statusPath        = "/api/v1/imports"
+	httpServerTimeout = 10 * time.Second
+)
 
 var (
 	scheme   = runtime.NewScheme()
@@ -70,7 +91,85 @@ var (
 	healthAddr                  string
 	concurrencyNumber           int
 	rancherKubeconfig           string
+	rancherKubeconfigSecret     string
 	insecureSkipVerify          bool
+	rancherCACertPath           string
+	registrationCheckWindow     time.Duration
+	labelsToRancher             []string
+	annotationsToRancher        []string
+	annotationsFromRancher      []string
+	nameTemplate                string
+	nameSuffix                  string
+	namePolicy                  string
+	clusterSelector             string
+	watchNamespaces             []string
+	importLabel                 string
+	ownedLabel                  string
+	recordManifestStats         bool
+	mirrorRancherReadiness      bool
+	importWindows               []string
+	importWindowsTimezone       string
+	incrementalApply            bool
+	serverSideApply             bool
+	dryRun                      bool
+	applyConcurrency            int
+	importConcurrency           int
+	crdEstablishedTimeout       time.Duration
+	eagerCreate                 bool
+	waitForMachinePools         bool
+	checkAgentHealth            bool
+	reconcileAgentHealth        bool
+	agentHealthInterval         time.Duration
+	rancherClusterWebhook       bool
+	controlPlaneReadyConditions []string
+	controlPlaneReadyPhase      bool
+	existingAgentPolicy         string
+	rancherClusterShape         string
+	labelAdoptedAgent           bool
+	continueOnForbidden         bool
+	endpointDiagnostics         bool
+	manifestURLHost             string
+	manifestURLHostAllowlist    []string
+	manifestDownloadAttempts    int
+	manifestDownloadInterval    time.Duration
+	manifestDownloadTimeout     time.Duration
+	tokenGracePeriod            time.Duration
+	rancherClusterPollInterval  time.Duration
+	tokenReissueInterval        time.Duration
+	gracefulShutdownTimeout     time.Duration
+	topologyLabels              bool
+	regionFields                map[string]string
+	createRancherNamespace      bool
+	accessLabels                []string
+	namespaceEventInterval      time.Duration
+	disconnectedThreshold       time.Duration
+	reapplyOnDisconnect         bool
+	minReapplyInterval          time.Duration
+	additionalManifestFile      string
+	additionalManifestCM        string
+	namespaceEnqueueSpread      time.Duration
+	agentNodeSelector           map[string]string
+	agentTolerations            []string
+	cacheRemoteClients          bool
+	kubeconfigSecretSuffix      string
+	kubeconfigSecretKey         string
+	provisioningAPIVersion      string
+	rancherDeletionProtection   bool
+	maxImportAttempts           int
+	importBackoffInterval       time.Duration
+	maxTokenRefreshes           int
+	importSLOThreshold          time.Duration
+	supportedK8sVersions        string
+	allowUnsupportedK8sVersions bool
+	maxRancherClusters          int
+	debugAddress                string
+	selfCheckAddress            string
+	statusAddress               string
+	selfCheckInterval           time.Duration
+	rancherClusterNamespace     string
+	importTimelineEntries       int
+	manifestCacheCM             string
+	variableMappingCM           string
 )
 
 func init() {
@@ -79,13 +178,23 @@ func init() {
 	//+kubebuilder:scaffold:scheme
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
 	utilruntime.Must(clusterv1.AddToScheme(scheme))
-	utilruntime.Must(provisioningv1.AddToScheme(scheme))
+	utilruntime.Must(expv1.AddToScheme(scheme))
 	utilruntime.Must(managementv3.AddToScheme(scheme))
 	utilruntime.Must(operatorv1.AddToScheme(scheme))
 	utilruntime.Must(turtlesv1.AddToScheme(scheme))
 	turtlesv1.AddKnownTypes(scheme)
 }
 
+// managerCacheOptions returns the cache options of the manager. The informers of all the watched types resync at the
+// given period, or at the controller-runtime default when it is zero.
+func managerCacheOptions(syncPeriod time.Duration) cache.Options {
+	if syncPeriod == 0 {
+		return cache.Options{}
+	}
+
+	return cache.Options{SyncPeriod: &syncPeriod}
+}
+
 // initFlags initializes the flags.
 func initFlags(fs *pflag.FlagSet) {
 	fs.StringVar(&metricsBindAddr, "metrics-bind-addr", ":8080",
@@ -108,8 +217,8 @@ func initFlags(fs *pflag.FlagSet) {
 	fs.StringVar(&profilerAddress, "profiler-address", "",
 		"Bind address to expose the pprof profiler (e.g. localhost:6060)")
 
-	fs.DurationVar(&syncPeriod, "sync-period", 2*time.Minute,
-		"The minimum interval at which watched resources are reconciled (e.g. 15m)")
+	fs.DurationVar(&syncPeriod, "sync-period", 0,
+		"The minimum interval at which watched resources are reconciled (e.g. 15m). Uses the controller-runtime default if unset.")
 
 	fs.StringVar(&healthAddr, "health-addr", ":9440",
 		"The address the health endpoint binds to.")
@@ -120,9 +229,250 @@ func initFlags(fs *pflag.FlagSet) {
 	fs.StringVar(&rancherKubeconfig, "rancher-kubeconfig", "",
 		"Path to the Rancher kubeconfig file. Only required if running out-of-cluster.")
 
+	fs.StringVar(&rancherKubeconfigSecret, "rancher-kubeconfig-secret", "",
+		"Secret, in the namespace/name format, whose value key holds the kubeconfig of a Rancher running in another cluster than the CAPI clusters. The Rancher clusters are then linked to their CAPI cluster with labels.") //nolint:lll
+
 	fs.BoolVar(&insecureSkipVerify, "insecure-skip-verify", false,
 		"Skip TLS certificate verification when connecting to Rancher. Only used for development and testing purposes. Use at your own risk.")
 
+	fs.StringVar(&rancherCACertPath, "rancher-ca-cert-path", "",
+		"Path to a PEM encoded CA bundle the Rancher server certificate is verified against when downloading registration manifests. Takes precedence over --insecure-skip-verify.") //nolint:lll
+
+	fs.DurationVar(&registrationCheckWindow, "registration-check-window", 5*time.Minute,
+		"Time an imported Rancher cluster has to become ready before the downstream agent is inspected for failures. Set to 0 to disable.")
+
+	fs.StringSliceVar(&labelsToRancher, "labels-to-rancher", []string{},
+		"List of CAPI cluster labels to mirror onto the imported Rancher cluster. An entry ending with * mirrors all the labels with its prefix.") //nolint:lll
+
+	fs.StringSliceVar(&annotationsToRancher, "annotations-to-rancher", []string{},
+		"List of CAPI cluster annotations to mirror onto the imported Rancher cluster. An entry ending with * mirrors all the annotations with its prefix.") //nolint:lll
+
+	fs.StringSliceVar(&annotationsFromRancher, "annotations-from-rancher", []string{},
+		"List of Rancher cluster annotations to mirror back onto the CAPI cluster. Must not overlap with --annotations-to-rancher.")
+
+	fs.StringVar(&nameTemplate, "rancher-cluster-name-template", "",
+		"Go template used to render imported Rancher cluster names from the CAPI cluster metadata (.Name, .Namespace, .Labels, .Annotations). Defaults to <name><suffix>.") //nolint:lll
+
+	fs.StringVar(&nameSuffix, "rancher-cluster-name-suffix", turtlesnaming.DefaultSuffix,
+		"Suffix added to CAPI cluster names to name their Rancher cluster, when no name template is set. Changing it on an existing installation re-imports the clusters under new names.") //nolint:lll
+
+	fs.StringVar(&namePolicy, "rancher-cluster-name-policy", "",
+		"Regular expression the imported Rancher cluster names must entirely match (e.g. (bu1|bu2)-.+). CAPI clusters whose Rancher cluster name doesn't match are not imported. Disabled when empty.") //nolint:lll
+
+	fs.StringVar(&clusterSelector, "cluster-selector", "",
+		"Label selector the CAPI clusters must match to be imported, in addition to the import label (e.g. env=dev,tier in (web)). Disabled when empty.") //nolint:lll
+
+	fs.StringSliceVar(&watchNamespaces, "watch-namespaces", []string{},
+		"Namespaces of the CAPI clusters to import. Clusters in other namespaces are ignored, even with the import label. All namespaces when empty.") //nolint:lll
+
+	fs.StringVar(&importLabel, "import-label", controllers.DefaultImportLabel,
+		"Label marking the CAPI clusters, or their namespaces, to import into Rancher.")
+
+	fs.StringVar(&ownedLabel, "owned-label", controllers.DefaultOwnedLabel,
+		"Label marking the Rancher clusters and objects created by the controller.")
+
+	fs.BoolVar(&recordManifestStats, "record-manifest-stats", false,
+		"Record the registration manifest size and object count as annotations on the CAPI cluster.")
+
+	fs.BoolVar(&mirrorRancherReadiness, "mirror-rancher-readiness", false,
+		"Mirror the agent deployment and readiness of the Rancher cluster in the RancherAgentDeployed and RancherClusterReady conditions of the CAPI cluster. Required by the import duration metrics.") //nolint:lll
+
+	fs.StringSliceVar(&importWindows, "import-windows", []string{},
+		"Maintenance windows during which clusters can be imported, in the `[Mon-Fri|Sat,Sun] HH:MM-HH:MM` format. Imports are always allowed if unset.") //nolint:lll
+
+	fs.StringVar(&importWindowsTimezone, "import-windows-timezone", "UTC",
+		"IANA time zone the import maintenance windows are evaluated in.")
+
+	fs.BoolVar(&incrementalApply, "incremental-apply", false,
+		"Only create or update the import manifest objects which are missing or changed in the downstream cluster.")
+
+	fs.BoolVar(&serverSideApply, "server-side-apply", false,
+		"Apply the import manifest objects with server-side apply, updating existing objects when the manifest changes.")
+
+	fs.BoolVar(&dryRun, "dry-run", false,
+		"Apply the import manifest objects with a server-side dry-run, logging the objects which would be applied without persisting them.") //nolint:lll
+
+	fs.IntVar(&applyConcurrency, "apply-concurrency", 1,
+		"Maximum number of import manifest objects written to the downstream cluster concurrently, after its namespaces and CRDs.") //nolint:lll
+
+	fs.IntVar(&importConcurrency, "import-concurrency", 0,
+		"Number of CAPI clusters imported in parallel. Zero uses the value of --concurrency.")
+
+	fs.DurationVar(&crdEstablishedTimeout, "crd-established-timeout", time.Minute,
+		"Maximum wait for the CRDs of the import manifest to be established before applying the custom resources of their kinds.") //nolint:lll
+
+	fs.BoolVar(&eagerCreate, "eager-create", false,
+		"Create the Rancher cluster before the control plane of the CAPI cluster is ready. The import manifest is applied once it is ready.") //nolint:lll
+
+	fs.StringSliceVar(&controlPlaneReadyConditions, "control-plane-ready-conditions", []string{},
+		"Condition types of the CAPI cluster which mark its control plane ready when true, in addition to ControlPlaneReady, e.g. provider-specific conditions of managed control planes.") //nolint:lll
+
+	fs.BoolVar(&controlPlaneReadyPhase, "control-plane-ready-on-provisioned", false,
+		"Consider the control plane of the CAPI cluster ready once the cluster is in the Provisioned phase.")
+
+	fs.BoolVar(&waitForMachinePools, "wait-for-machine-pools", false,
+		"Apply the import manifest of CAPI clusters with machine pools once at least one of them is ready. Requires the MachinePool feature.") //nolint:lll
+
+	fs.BoolVar(&checkAgentHealth, "check-agent-health", false,
+		"Wait for the cattle-cluster-agent deployment to be available on the downstream cluster once the import manifest is applied, and report it with the AgentHealthy condition.") //nolint:lll
+
+	fs.BoolVar(&reconcileAgentHealth, "reconcile-agent-health", false,
+		"Periodically verify the cattle-cluster-agent deployment still exists on the downstream cluster once Rancher reports it as deployed, and re-apply the import manifest when it was deleted.") //nolint:lll
+
+	fs.DurationVar(&agentHealthInterval, "agent-health-interval", 5*time.Minute,
+		"Interval the presence of the cattle-cluster-agent deployment is verified at when reconciling the agent health.")
+
+	fs.BoolVar(&rancherClusterWebhook, "rancher-cluster-webhook", false,
+		"Serve the webhook validating the owned label and name length of the Rancher clusters created for CAPI clusters. Requires webhook serving certificates.") //nolint:lll
+
+	fs.StringVar(&existingAgentPolicy, "existing-agent-policy", string(controllers.AgentPolicyReapply),
+		"How to handle a healthy cattle-cluster-agent already registered with the same Rancher on the downstream cluster: \"reapply\" the import manifest or \"adopt\" the agent.") //nolint:lll
+
+	fs.StringVar(&rancherClusterShape, "rancher-cluster-shape", string(controllers.RancherClusterShapeV1),
+		"API version of the Rancher clusters created for the CAPI clusters. Only \"v1\", the provisioning.cattle.io/v1 clusters, is supported for now.") //nolint:lll
+
+	fs.BoolVar(&labelAdoptedAgent, "label-adopted-agent", false,
+		"Label the adopted cattle-cluster-agent deployment as applied by turtles.")
+
+	fs.BoolVar(&continueOnForbidden, "continue-on-forbidden", false,
+		"Keep applying the rest of the import manifest when the downstream cluster forbids creating some of its objects.")
+
+	fs.BoolVar(&endpointDiagnostics, "endpoint-diagnostics", false,
+		"Report control plane endpoints the management cluster can't reach and Rancher server-urls the agent can't reach from the downstream cluster.") //nolint:lll
+
+	fs.StringVar(&manifestURLHost, "manifest-url-host", "",
+		"Host (and optional port) replacing the host of the registration manifest URL, e.g. a mirror reachable from air-gapped clusters.")
+
+	fs.StringSliceVar(&manifestURLHostAllowlist, "manifest-url-host-allowlist", []string{},
+		"Hosts the cluster-api.cattle.io/manifest-url-host annotation of a CAPI cluster may select. Clusters annotated with other hosts are not imported.") //nolint:lll
+
+	fs.IntVar(&manifestDownloadAttempts, "manifest-download-attempts", 3,
+		"Maximum number of attempts to download a registration manifest when Rancher answers with a server error or can't be reached.")
+
+	fs.DurationVar(&manifestDownloadInterval, "manifest-download-interval", time.Second,
+		"Wait before the first retry of a registration manifest download, doubled with jitter for each following retry.")
+
+	fs.DurationVar(&manifestDownloadTimeout, "manifest-download-timeout", 30*time.Second,
+		"Timeout of each registration manifest download attempt, so that a hung Rancher endpoint doesn't block the reconcile.")
+
+	fs.DurationVar(&rancherClusterPollInterval, "rancher-cluster-poll-interval", 5*time.Second,
+		"First requeue interval of clusters waiting for Rancher to set their cluster name or manifest URL, doubled on each consecutive wait.") //nolint:lll
+
+	fs.DurationVar(&tokenGracePeriod, "registration-token-grace-period", 0,
+		"Time Rancher has to set the manifest URL of a registration token before the token is re-issued. Zero disables it.")
+
+	fs.DurationVar(&tokenReissueInterval, "registration-token-reissue-interval", 5*time.Minute,
+		"Minimum age of a registration token before it is re-issued, either because it expired or its manifest URL is missing.")
+
+	fs.DurationVar(&gracefulShutdownTimeout, "graceful-shutdown-timeout", 30*time.Second,
+		"The time in-flight reconciles are given to finish their current manifest apply when the controller stops.")
+
+	fs.BoolVar(&topologyLabels, "topology-labels", false,
+		"Label imported Rancher clusters with the region and zone of the CAPI cluster, where they can be determined.")
+
+	fs.StringToStringVar(&regionFields, "topology-region-fields",
+		map[string]string{"AWSCluster": "spec.region", "AzureCluster": "spec.location", "GCPCluster": "spec.region"},
+		"Infrastructure cluster kinds and the field holding their region, used by --topology-labels.")
+
+	fs.StringVar(&rancherClusterNamespace, "rancher-cluster-namespace", "",
+		"Namespace the Rancher clusters are created in (e.g. fleet-default). Defaults to the namespace of their CAPI cluster.")
+
+	fs.StringVar(&variableMappingCM, "topology-variable-mapping-configmap", "",
+		"Config map, in the namespace/name format, mapping CAPI cluster topology variables to the Rancher cluster labels they are projected to. Each entry maps a variable name to a label key, optionally followed by |lower or |upper to transform the value.") //nolint:lll
+
+	fs.BoolVar(&createRancherNamespace, "create-rancher-namespace", false,
+		"Create the namespace of the imported Rancher cluster when it does not exist.")
+
+	fs.StringSliceVar(&accessLabels, "rancher-access-labels", []string{},
+		"List of labels granting access to imported Rancher clusters, kept in sync from the CAPI cluster annotations or its namespace labels.")
+
+	fs.DurationVar(&namespaceEventInterval, "namespace-event-interval", 10*time.Minute,
+		"Minimum interval between the events explaining a namespace's clusters are not imported as it isn't labeled for import. Set to 0 to disable.") //nolint:lll
+
+	fs.DurationVar(&disconnectedThreshold, "disconnected-threshold", 30*time.Minute,
+		"Time a Rancher cluster can stay not ready while its agent is running before it is reported as degraded. Set to 0 to disable.")
+
+	fs.BoolVar(&reapplyOnDisconnect, "reapply-on-disconnect", false,
+		"Re-apply the import manifest when a cluster is reported as degraded because its agent can't reach Rancher.")
+
+	fs.DurationVar(&minReapplyInterval, "min-reapply-interval", 10*time.Minute,
+		"Minimum time between two re-applications of the import manifest to the same cluster. Set to 0 to disable.")
+
+	fs.StringVar(&additionalManifestFile, "additional-manifest-file", "",
+		"Path to a manifest applied to every imported cluster after the Rancher registration manifest.")
+
+	fs.StringVar(&additionalManifestCM, "additional-manifest-configmap", "",
+		"Config map, in the namespace/name format, whose data is applied to every imported cluster after the Rancher registration manifest.")
+
+	fs.DurationVar(&namespaceEnqueueSpread, "namespace-enqueue-spread", 0,
+		"Window over which the clusters of a namespace are enqueued when its import label changes, to smooth the load on Rancher. Set to 0 to enqueue them immediately.") //nolint:lll
+
+	fs.StringToStringVar(&agentNodeSelector, "agent-node-selector", map[string]string{},
+		"Node selector merged into the cattle-cluster-agent deployment of the import manifest.")
+
+	fs.StringSliceVar(&agentTolerations, "agent-tolerations", []string{},
+		"Tolerations added to the cattle-cluster-agent deployment of the import manifest, in the `key[=value][:effect]` format.")
+
+	fs.BoolVar(&cacheRemoteClients, "cache-remote-clients", false,
+		"Keep the downstream cluster clients between reconciles. Cached clients are evicted when the cluster is deleted.")
+
+	fs.StringVar(&kubeconfigSecretSuffix, "kubeconfig-secret-suffix", "",
+		"Suffix appended to the CAPI cluster name to form the name of its kubeconfig secret. Empty uses the CAPI \"-kubeconfig\" convention.") //nolint:lll
+
+	fs.StringVar(&kubeconfigSecretKey, "kubeconfig-secret-key", "",
+		"Data key of the kubeconfig secret holding the kubeconfig of the CAPI clusters. Empty uses the CAPI \"value\" key.")
+
+	fs.StringVar(&provisioningAPIVersion, "rancher-provisioning-api-version", provisioningv1.GroupVersion.String(),
+		"Group version of the Rancher provisioning Cluster API, for Rancher releases or forks serving it elsewhere.")
+
+	fs.BoolVar(&rancherDeletionProtection, "rancher-deletion-protection", false,
+		fmt.Sprintf("Protect the imported Rancher clusters against deletion until their CAPI cluster is deleted or the %s annotation is set to false.",
+			turtlesannotations.DeletionProtectionAnnotation))
+
+	fs.IntVar(&maxImportAttempts, "max-import-attempts", 0,
+		"Number of consecutive failed import attempts after which the import is retried at the import backoff interval. Zero disables the limit.")
+
+	fs.DurationVar(&importBackoffInterval, "import-backoff-interval", time.Hour,
+		"Interval failed imports are retried at once the maximum import attempts are reached.")
+
+	fs.IntVar(&maxTokenRefreshes, "max-token-refreshes", 0,
+		"Number of times the registration token of a cluster whose agent is rejected by Rancher is refreshed and its manifest re-applied. Zero disables the refresh.") //nolint:lll
+
+	fs.DurationVar(&importSLOThreshold, "import-slo-threshold", 0,
+		"Import duration, from the cluster becoming eligible to its Rancher cluster being ready, above which the import is counted as an SLO breach. Set to 0 to disable.") //nolint:lll
+
+	fs.StringVar(&supportedK8sVersions, "supported-kubernetes-versions", "",
+		"Range of Kubernetes versions supported by Rancher for imported clusters, e.g. \">=1.26.0 <1.31.0\". Clusters out of the range are not imported. Empty disables the check.") //nolint:lll
+
+	fs.BoolVar(&allowUnsupportedK8sVersions, "allow-unsupported-kubernetes-versions", false,
+		"Import clusters out of the supported Kubernetes versions range with a warning instead of skipping them.")
+
+	fs.IntVar(&maxRancherClusters, "max-rancher-clusters", 0,
+		"Maximum number of clusters Rancher manages. Imports are held off while Rancher is at capacity. Zero disables the limit.")
+
+	fs.IntVar(&importTimelineEntries, "import-timeline-entries", 0,
+		"Number of import lifecycle entries kept in the timeline config map of each Rancher cluster. Zero disables the timeline.")
+
+	fs.StringVar(&manifestCacheCM, "manifest-cache-configmap", "",
+		"Config map, in the namespace/name format, the registration manifest applied to each cluster is persisted to. Unchanged manifests are not downloaded again, including after a leader failover.") //nolint:lll
+
+	fs.StringVar(&debugAddress, "debug-address", "",
+		"Bind address to expose the import reconciler state dump at "+debugPath+" and its effective configuration at "+
+			configPath+" (e.g. localhost:6061). Requests are authenticated and authorized against the management cluster. "+
+			"Disabled when empty.")
+
+	fs.StringVar(&selfCheckAddress, "self-check-address", "",
+		"Bind address to expose the result of the import pipeline self-check at "+selfCheckPath+" (e.g. :6062), answering "+
+			"200 when healthy and 503 otherwise. Disabled when empty.")
+
+	fs.StringVar(&statusAddress, "status-address", "",
+		"Bind address to expose the read-only import status API at "+statusPath+" and "+statusPath+"/<namespace>/<name> "+
+			"(e.g. :6063), for external dashboards. Requests are authenticated and authorized against the management "+
+			"cluster. Disabled when empty.")
+
+	fs.DurationVar(&selfCheckInterval, "self-check-interval", time.Minute,
+		"Interval the import pipeline self-check checking Rancher is reachable and serves the provisioning API is run at.")
+
 	feature.MutableGates.AddFlag(fs)
 }
 
@@ -133,6 +483,20 @@ func main() {
 
 	ctrl.SetLogger(klogr.New())
 
+	provisioningGroupVersion := provisioningv1.GroupVersion
+
+	if provisioningAPIVersion != "" {
+		gv, err := schema.ParseGroupVersion(provisioningAPIVersion)
+		if err != nil || gv.Group == "" || gv.Version == "" {
+			setupLog.Error(err, "invalid Rancher provisioning API version", "version", provisioningAPIVersion)
+			os.Exit(1)
+		}
+
+		provisioningGroupVersion = gv
+	}
+
+	utilruntime.Must(provisioningv1.AddToSchemeWithGroupVersion(provisioningGroupVersion)(scheme))
+
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme: scheme,
 		Metrics: server.Options{
@@ -152,10 +516,9 @@ func main() {
 				},
 			},
 		},
-		Cache: cache.Options{
-			SyncPeriod: &syncPeriod,
-		},
-		HealthProbeBindAddress: healthAddr,
+		Cache:                   managerCacheOptions(syncPeriod),
+		HealthProbeBindAddress:  healthAddr,
+		GracefulShutdownTimeout: &gracefulShutdownTimeout,
 	})
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
@@ -189,21 +552,94 @@ func setupChecks(mgr ctrl.Manager) {
 	}
 }
 
+// setupHTTPServer serves the handlers at their path of the address. Servers which don't need the leader election run
+// on every replica.
+func setupHTTPServer(mgr ctrl.Manager, name, address string, handlers map[string]http.Handler, needLeaderElection bool) error {
+	mux := http.NewServeMux()
+	for path, handler := range handlers {
+		mux.Handle(path, handler)
+	}
+
+	srv := &http.Server{
+		Addr:              address,
+		Handler:           mux,
+		ReadHeaderTimeout: httpServerTimeout,
+	}
+
+	return mgr.Add(&httpServerRunnable{needLeaderElection: needLeaderElection, start: func(ctx context.Context) error {
+		errs := make(chan error, 1)
+
+		go func() {
+			setupLog.Info("starting "+name+" server", "address", address)
+			errs <- srv.ListenAndServe()
+		}()
+
+		select {
+		case err := <-errs:
+			return err
+		case <-ctx.Done():
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), httpServerTimeout)
+			defer cancel()
+
+			return srv.Shutdown(shutdownCtx)
+		}
+	}})
+}
+
+// httpServerRunnable runs an HTTP server with the manager.
+type httpServerRunnable struct {
+	needLeaderElection bool
+	start              func(ctx context.Context) error
+}
+
+func (h *httpServerRunnable) Start(ctx context.Context) error {
+	return h.start(ctx)
+}
+
+func (h *httpServerRunnable) NeedLeaderElection() bool {
+	return h.needLeaderElection
+}
+
 func setupReconcilers(ctx context.Context, mgr ctrl.Manager) {
-	rancherClient, err := setupRancherClient(mgr)
+	rancherClient, err := setupRancherClient(ctx, mgr)
 	if err != nil {
 		setupLog.Error(err, "failed to create client")
 		os.Exit(1)
 	}
 
+	if err := turtlesnaming.SetSuffix(nameSuffix); err != nil {
+		setupLog.Error(err, "invalid Rancher cluster name suffix")
+		os.Exit(1)
+	}
+
+	caBundle, err := readRancherCABundle(rancherCACertPath)
+	if err != nil {
+		setupLog.Error(err, "invalid Rancher CA bundle")
+		os.Exit(1)
+	}
+
+	controlPlaneReadiness := util.ControlPlaneReadiness{ProvisionedPhase: controlPlaneReadyPhase}
+	for _, conditionType := range controlPlaneReadyConditions {
+		controlPlaneReadiness.Conditions = append(controlPlaneReadiness.Conditions, clusterv1.ConditionType(conditionType))
+	}
+
 	if feature.Gates.Enabled(feature.ManagementV3Cluster) {
 		setupLog.Info("enabling CAPI cluster import controller for `management.cattle.io/v3` resources")
 
 		if err := (&controllers.CAPIImportManagementV3Reconciler{
-			Client:             mgr.GetClient(),
-			RancherClient:      rancherClient,
-			WatchFilterValue:   watchFilterValue,
-			InsecureSkipVerify: insecureSkipVerify,
+			Client:                     mgr.GetClient(),
+			RancherClient:              rancherClient,
+			WatchFilterValue:           watchFilterValue,
+			InsecureSkipVerify:         insecureSkipVerify,
+			CABundle:                   caBundle,
+			ManifestURLHost:            manifestURLHost,
+			ManifestURLHostAllowlist:   manifestURLHostAllowlist,
+			ManifestDownloadAttempts:   manifestDownloadAttempts,
+			ManifestDownloadInterval:   manifestDownloadInterval,
+			ManifestDownloadTimeout:    manifestDownloadTimeout,
+			RancherClusterPollInterval: rancherClusterPollInterval,
+			NamespaceEnqueueSpread:     namespaceEnqueueSpread,
+			ControlPlaneReadiness:      controlPlaneReadiness,
 		}).SetupWithManager(ctx, mgr, controller.Options{
 			MaxConcurrentReconciles: concurrencyNumber,
 			CacheSyncTimeout:        maxDuration,
@@ -214,18 +650,235 @@ func setupReconcilers(ctx context.Context, mgr ctrl.Manager) {
 	} else {
 		setupLog.Info("enabling CAPI cluster import controller for `provisioning.cattle.io/v1` resources")
 
-		if err := (&controllers.CAPIImportReconciler{
-			Client:             mgr.GetClient(),
-			RancherClient:      rancherClient,
-			WatchFilterValue:   watchFilterValue,
-			InsecureSkipVerify: insecureSkipVerify,
-		}).SetupWithManager(ctx, mgr, controller.Options{
+		var (
+			rancherNameTemplate *turtlesnaming.Template
+			rancherNamePolicy   turtlesnaming.NamePolicy
+			importSchedule      *schedule.Schedule
+			capiClusterSelector *metav1.LabelSelector
+		)
+
+		if nameTemplate != "" {
+			rancherNameTemplate, err = turtlesnaming.NewTemplate(nameTemplate)
+			if err != nil {
+				setupLog.Error(err, "invalid Rancher cluster name template")
+				os.Exit(1)
+			}
+		}
+
+		if namePolicy != "" {
+			rancherNamePolicy, err = turtlesnaming.NewRegexPolicy(namePolicy)
+			if err != nil {
+				setupLog.Error(err, "invalid Rancher cluster name policy")
+				os.Exit(1)
+			}
+		}
+
+		if len(importWindows) > 0 {
+			importSchedule, err = schedule.Parse(importWindows, importWindowsTimezone)
+			if err != nil {
+				setupLog.Error(err, "invalid import maintenance windows")
+				os.Exit(1)
+			}
+		}
+
+		if clusterSelector != "" {
+			capiClusterSelector, err = metav1.ParseToLabelSelector(clusterSelector)
+			if err != nil {
+				setupLog.Error(err, "invalid cluster selector")
+				os.Exit(1)
+			}
+		}
+
+		var additionalManifest []byte
+
+		if additionalManifestFile != "" {
+			additionalManifest, err = os.ReadFile(additionalManifestFile)
+			if err != nil {
+				setupLog.Error(err, "unable to read additional manifest")
+				os.Exit(1)
+			}
+		}
+
+		var supportedVersions semver.Range
+
+		if supportedK8sVersions != "" {
+			var err error
+
+			supportedVersions, err = semver.ParseRange(supportedK8sVersions)
+			if err != nil {
+				setupLog.Error(err, "invalid supported Kubernetes versions range")
+				os.Exit(1)
+			}
+		}
+
+		agentPolicy, err := controllers.ParseAgentPolicy(existingAgentPolicy)
+		if err != nil {
+			setupLog.Error(err, "invalid existing agent policy")
+			os.Exit(1)
+		}
+
+		rancherClusterFactory, err := controllers.NewRancherClusterFactory(rancherClusterShape)
+		if err != nil {
+			setupLog.Error(err, "invalid rancher cluster shape")
+			os.Exit(1)
+		}
+
+		tolerations := make([]corev1.Toleration, 0, len(agentTolerations))
+
+		for _, spec := range agentTolerations {
+			toleration, err := scheduling.ParseToleration(spec)
+			if err != nil {
+				setupLog.Error(err, "invalid agent toleration")
+				os.Exit(1)
+			}
+
+			tolerations = append(tolerations, toleration)
+		}
+
+		importReconciler := &controllers.CAPIImportReconciler{
+			Client:                mgr.GetClient(),
+			RancherClient:         rancherClient,
+			RemoteRancher:         rancherKubeconfigSecret != "",
+			RancherClusterFactory: rancherClusterFactory,
+			WatchFilterValue:      watchFilterValue,
+			Concurrency:           importConcurrency,
+			CheckAgentHealth:      checkAgentHealth,
+			ReconcileAgentHealth:  reconcileAgentHealth,
+			EndpointDiagnostics:   endpointDiagnostics,
+			Version:               version.Get().GitVersion,
+			CacheRemoteClients:    cacheRemoteClients,
+			KubeconfigSecret:      controllers.KubeconfigSecretRef{NameSuffix: kubeconfigSecretSuffix, Key: kubeconfigSecretKey},
+			DownloadOptions: controllers.DownloadOptions{
+				InsecureSkipVerify:       insecureSkipVerify,
+				CABundle:                 caBundle,
+				ManifestURLHost:          manifestURLHost,
+				ManifestURLHostAllowlist: manifestURLHostAllowlist,
+				ManifestDownloadAttempts: manifestDownloadAttempts,
+				ManifestDownloadInterval: manifestDownloadInterval,
+				ManifestDownloadTimeout:  manifestDownloadTimeout,
+				ManifestCacheConfigMap:   objectKeyFlag(manifestCacheCM, "manifest cache config map"),
+			},
+			ApplyOptions: controllers.ApplyOptions{
+				RecordManifestStats:         recordManifestStats,
+				IncrementalApply:            incrementalApply,
+				UseServerSideApply:          serverSideApply,
+				DryRun:                      dryRun,
+				ApplyConcurrency:            applyConcurrency,
+				CRDEstablishedTimeout:       crdEstablishedTimeout,
+				ObjectApplyTimeout:          gracefulShutdownTimeout,
+				WaitForMachinePools:         waitForMachinePools,
+				ExistingAgentPolicy:         agentPolicy,
+				LabelAdoptedAgent:           labelAdoptedAgent,
+				ContinueOnForbidden:         continueOnForbidden,
+				ReapplyOnDisconnect:         reapplyOnDisconnect,
+				AdditionalManifest:          string(additionalManifest),
+				AdditionalManifestConfigMap: objectKeyFlag(additionalManifestCM, "additional manifest config map"),
+				AgentNodeSelector:           agentNodeSelector,
+				AgentTolerations:            tolerations,
+			},
+			SelectionOptions: controllers.SelectionOptions{
+				ClusterSelector:                    capiClusterSelector,
+				WatchNamespaces:                    watchNamespaces,
+				ImportLabel:                        importLabel,
+				ImportSchedule:                     importSchedule,
+				EagerCreate:                        eagerCreate,
+				ControlPlaneReadiness:              controlPlaneReadiness,
+				SupportedKubernetesVersions:        supportedVersions,
+				AllowUnsupportedKubernetesVersions: allowUnsupportedK8sVersions,
+				MaxRancherClusters:                 maxRancherClusters,
+			},
+			TimingOptions: controllers.TimingOptions{
+				RegistrationCheckWindow:          registrationCheckWindow,
+				AgentHealthInterval:              agentHealthInterval,
+				RancherClusterPollInterval:       rancherClusterPollInterval,
+				RegistrationTokenGracePeriod:     tokenGracePeriod,
+				RegistrationTokenReissueInterval: tokenReissueInterval,
+				NamespaceEventInterval:           namespaceEventInterval,
+				DisconnectedThreshold:            disconnectedThreshold,
+				MinReapplyInterval:               minReapplyInterval,
+				SelfCheckInterval:                selfCheckInterval,
+				NamespaceEnqueueSpread:           namespaceEnqueueSpread,
+				MaxImportAttempts:                maxImportAttempts,
+				ImportBackoffInterval:            importBackoffInterval,
+				MaxTokenRefreshes:                maxTokenRefreshes,
+				ImportSLOThreshold:               importSLOThreshold,
+			},
+			RancherClusterOptions: controllers.RancherClusterOptions{
+				LabelsToRancher:         labelsToRancher,
+				AnnotationsToRancher:    annotationsToRancher,
+				AnnotationsFromRancher:  annotationsFromRancher,
+				NameTemplate:            rancherNameTemplate,
+				NamePolicy:              rancherNamePolicy,
+				OwnedLabel:              ownedLabel,
+				MirrorRancherReadiness:  mirrorRancherReadiness,
+				TopologyLabels:          topologyLabels,
+				RegionFields:            regionFields,
+				TopologyVariableMapping: objectKeyFlag(variableMappingCM, "topology variable mapping config map"),
+				CreateRancherNamespace:  createRancherNamespace,
+				RancherClusterNamespace: rancherClusterNamespace,
+				TimelineEntries:         importTimelineEntries,
+				AccessLabels:            accessLabels,
+				DeletionProtection:      rancherDeletionProtection,
+			},
+		}
+
+		if err := importReconciler.SetupWithManager(ctx, mgr, controller.Options{
 			MaxConcurrentReconciles: concurrencyNumber,
 			CacheSyncTimeout:        maxDuration,
 		}); err != nil {
 			setupLog.Error(err, "unable to create capi controller")
 			os.Exit(1)
 		}
+
+		setupLog.Info("import controller configuration", "config", importReconciler.Config())
+
+		if rancherClusterWebhook {
+			if err := (&controllers.RancherClusterValidator{OwnedLabel: ownedLabel}).SetupWebhookWithManager(mgr); err != nil {
+				setupLog.Error(err, "unable to create Rancher cluster webhook")
+				os.Exit(1)
+			}
+		}
+
+		if debugAddress != "" {
+			if err := setupHTTPServer(mgr, "debug", debugAddress, map[string]http.Handler{
+				debugPath:  importReconciler.DebugHandler(),
+				configPath: importReconciler.ConfigHandler(),
+			}, true); err != nil {
+				setupLog.Error(err, "unable to create debug server")
+				os.Exit(1)
+			}
+		}
+
+		if statusAddress != "" {
+			statusHandler := importReconciler.StatusHandler(statusPath)
+
+			if err := setupHTTPServer(mgr, "status", statusAddress, map[string]http.Handler{
+				statusPath:       statusHandler,
+				statusPath + "/": statusHandler,
+			}, true); err != nil {
+				setupLog.Error(err, "unable to create status server")
+				os.Exit(1)
+			}
+		}
+
+		if selfCheckAddress != "" {
+			if selfCheckInterval <= 0 {
+				setupLog.Error(nil, "self-check interval must be positive", "interval", selfCheckInterval)
+				os.Exit(1)
+			}
+
+			if err := mgr.Add(importReconciler.SelfCheckRunnable()); err != nil {
+				setupLog.Error(err, "unable to create import self-check")
+				os.Exit(1)
+			}
+
+			if err := setupHTTPServer(mgr, "self-check", selfCheckAddress, map[string]http.Handler{
+				selfCheckPath: importReconciler.SelfCheckHandler(),
+			}, false); err != nil {
+				setupLog.Error(err, "unable to create self-check server")
+				os.Exit(1)
+			}
+		}
 	}
 
 	if feature.Gates.Enabled(feature.RancherKubeSecretPatch) {
@@ -251,10 +904,38 @@ func setupReconcilers(ctx context.Context, mgr ctrl.Manager) {
 	}
 }
 
+// objectKeyFlag parses a flag value in the namespace/name format, exiting when it is malformed. An empty value returns
+// an empty key.
+func objectKeyFlag(value, description string) client.ObjectKey {
+	if value == "" {
+		return client.ObjectKey{}
+	}
+
+	namespace, name, ok := strings.Cut(value, "/")
+	if !ok || namespace == "" || name == "" {
+		setupLog.Error(nil, description+" must be in the namespace/name format")
+		os.Exit(1)
+	}
+
+	return client.ObjectKey{Namespace: namespace, Name: name}
+}
+
 // setupRancherClient can either create a client for an in-cluster installation (rancher and rancher-turtles in the same cluster)
 // or create a client for an out-of-cluster installation (rancher and rancher-turtles in different clusters) based on the
-// existence of Rancher kubeconfig file.
-func setupRancherClient(mgr ctrl.Manager) (client.Client, error) {
+// existence of Rancher kubeconfig file or secret.
+func setupRancherClient(ctx context.Context, mgr ctrl.Manager) (client.Client, error) {
+	if rancherKubeconfigSecret != "" {
+		if len(rancherKubeconfig) > 0 {
+			return nil, errors.New("--rancher-kubeconfig and --rancher-kubeconfig-secret are mutually exclusive")
+		}
+
+		secretKey := objectKeyFlag(rancherKubeconfigSecret, "Rancher kubeconfig secret")
+		setupLog.Info("remote Rancher installation", "using kubeconfig from secret", secretKey.String())
+
+		// the manager cache is not started yet, the secret is read from the API server
+		return controllers.NewRemoteRancherClient(ctx, mgr.GetAPIReader(), secretKey, mgr.GetClient().Scheme())
+	}
+
 	if len(rancherKubeconfig) > 0 {
 		setupLog.Info("out-of-cluster installation of rancher-turtles", "using kubeconfig from path", rancherKubeconfig)
 
@@ -287,3 +968,26 @@ func loadConfigWithContext(apiServerURL string, loader clientcmd.ClientConfigLoa
 			CurrentContext: context,
 		}).ClientConfig()
 }
+
+// readRancherCABundle reads the PEM encoded CA bundle of the Rancher server, if any. As the bundle takes precedence,
+// a warning is logged when the TLS verification is also disabled.
+func readRancherCABundle(path string) ([]byte, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	bundle, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading CA bundle: %w", err)
+	}
+
+	if !x509.NewCertPool().AppendCertsFromPEM(bundle) {
+		return nil, fmt.Errorf("no valid PEM certificate found in %s", path)
+	}
+
+	if insecureSkipVerify {
+		setupLog.Info("WARNING: --insecure-skip-verify is ignored as a Rancher CA bundle is set", "path", path)
+	}
+
+	return bundle, nil
+}
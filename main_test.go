@@ -0,0 +1,65 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"github.com/spf13/pflag"
+)
+
+func TestManagerCacheOptions(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		syncPeriod *time.Duration
+	}{
+		{
+			name:       "default sync period",
+			args:       []string{},
+			syncPeriod: nil,
+		},
+		{
+			name:       "configured sync period",
+			args:       []string{"--sync-period=15m"},
+			syncPeriod: ptr(15 * time.Minute),
+		},
+		{
+			name:       "explicit controller-runtime default",
+			args:       []string{"--sync-period=0"},
+			syncPeriod: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+			initFlags(fs)
+			g.Expect(fs.Parse(tt.args)).To(Succeed())
+
+			g.Expect(managerCacheOptions(syncPeriod).SyncPeriod).To(Equal(tt.syncPeriod))
+		})
+	}
+}
+
+func ptr(d time.Duration) *time.Duration {
+	return &d
+}
@@ -30,6 +30,8 @@ type RunCommandInput struct {
 	Command              string
 	Args                 []string
 	EnvironmentVariables map[string]string
+	// Stdin is the optional standard input of the command, e.g. to pass secrets which must not be in the arguments.
+	Stdin []byte
 }
 
 // RunCommandResult is the result of RunCommand.
@@ -51,6 +53,10 @@ func RunCommand(ctx context.Context, input RunCommandInput, result *RunCommandRe
 		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", name, val))
 	}
 
+	if input.Stdin != nil {
+		cmd.Stdin = bytes.NewReader(input.Stdin)
+	}
+
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
@@ -0,0 +1,174 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package testenv
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/util/version"
+
+	"sigs.k8s.io/cluster-api/test/framework/bootstrap"
+
+	turtlesframework "github.com/rancher/turtles/test/framework"
+)
+
+func NewAKSClusterProvider(name, version, subscriptionID, resourceGroup, region string, numWorkers int) bootstrap.ClusterProvider {
+	Expect(name).ToNot(BeEmpty(), "name is required for NewAKSClusterProvider")
+	Expect(version).ToNot(BeEmpty(), "version is required for NewAKSClusterProvider")
+	Expect(numWorkers).To(BeNumerically(">", 0), "numWorkers must be greater than 0 for NewAKSClusterProvider")
+	Expect(subscriptionID).ToNot(BeEmpty(), "subscriptionID is required for NewAKSClusterProvider")
+	Expect(resourceGroup).ToNot(BeEmpty(), "resourceGroup is required for NewAKSClusterProvider")
+	Expect(region).ToNot(BeEmpty(), "region is required for NewAKSClusterProvider")
+
+	return &AKSClusterProvider{
+		name:           name,
+		version:        version,
+		subscriptionID: subscriptionID,
+		resourceGroup:  resourceGroup,
+		region:         region,
+		numWorkers:     numWorkers,
+	}
+}
+
+type AKSClusterProvider struct {
+	name           string
+	version        string
+	subscriptionID string
+	resourceGroup  string
+	region         string
+	numWorkers     int
+	kubeconfigPath string
+}
+
+// Create an AKS cluster, and its resource group when missing.
+func (k *AKSClusterProvider) Create(ctx context.Context) {
+	tempFile, err := os.CreateTemp("", "kubeconfig")
+	Expect(err).NotTo(HaveOccurred(), "Failed to create temp file for kubeconfig")
+	turtlesframework.Byf("AKS kubeconfig will be written to temp file %s", tempFile.Name())
+
+	turtlesframework.Byf("Creating resource group %s in %s", k.resourceGroup, k.region)
+
+	createGroupRes := &turtlesframework.RunCommandResult{}
+	turtlesframework.RunCommand(ctx, turtlesframework.RunCommandInput{
+		Command: "az",
+		Args: []string{
+			"group",
+			"create",
+			"--subscription",
+			k.subscriptionID,
+			"--name",
+			k.resourceGroup,
+			"--location",
+			k.region,
+		},
+	}, createGroupRes)
+	Expect(createGroupRes.Error).NotTo(HaveOccurred(), "Failed to create resource group using az: %s", createGroupRes.Stderr)
+	Expect(createGroupRes.ExitCode).To(Equal(0), "Creating resource group returned non-zero exit code")
+
+	aksVersion := versionToAKS(parseEKSVersion(k.version))
+
+	turtlesframework.Byf("Creating cluster using az (version %s)", aksVersion)
+
+	createClusterRes := &turtlesframework.RunCommandResult{}
+	turtlesframework.RunCommand(ctx, turtlesframework.RunCommandInput{
+		Command: "az",
+		Args: []string{
+			"aks",
+			"create",
+			"--subscription",
+			k.subscriptionID,
+			"--resource-group",
+			k.resourceGroup,
+			"--name",
+			k.name,
+			"--location",
+			k.region,
+			"--kubernetes-version",
+			aksVersion,
+			"--node-count",
+			strconv.Itoa(k.numWorkers),
+			"--generate-ssh-keys",
+		},
+	}, createClusterRes)
+	Expect(createClusterRes.Error).NotTo(HaveOccurred(), "Failed to create cluster using az: %s", createClusterRes.Stderr)
+	Expect(createClusterRes.ExitCode).To(Equal(0), "Creating cluster returned non-zero exit code")
+
+	credentialsRes := &turtlesframework.RunCommandResult{}
+	turtlesframework.RunCommand(ctx, turtlesframework.RunCommandInput{
+		Command: "az",
+		Args: []string{
+			"aks",
+			"get-credentials",
+			"--subscription",
+			k.subscriptionID,
+			"--resource-group",
+			k.resourceGroup,
+			"--name",
+			k.name,
+			"--file",
+			tempFile.Name(),
+			"--overwrite-existing",
+		},
+	}, credentialsRes)
+	Expect(credentialsRes.Error).NotTo(HaveOccurred(), "Failed to get cluster credentials using az: %s", credentialsRes.Stderr)
+	Expect(credentialsRes.ExitCode).To(Equal(0), "Getting cluster credentials returned non-zero exit code")
+
+	k.kubeconfigPath = tempFile.Name()
+}
+
+// GetKubeconfigPath returns the path to the kubeconfig file for the cluster.
+func (k *AKSClusterProvider) GetKubeconfigPath() string {
+	return k.kubeconfigPath
+}
+
+// Dispose the AKS cluster and its kubeconfig file. The resource group is kept, as it may be shared.
+func (k *AKSClusterProvider) Dispose(ctx context.Context) {
+	Expect(ctx).NotTo(BeNil(), "ctx is required for Dispose")
+
+	By("Deleting cluster using az")
+
+	deleteClusterRes := &turtlesframework.RunCommandResult{}
+	turtlesframework.RunCommand(ctx, turtlesframework.RunCommandInput{
+		Command: "az",
+		Args: []string{
+			"aks",
+			"delete",
+			"--subscription",
+			k.subscriptionID,
+			"--resource-group",
+			k.resourceGroup,
+			"--name",
+			k.name,
+			"--yes",
+		},
+	}, deleteClusterRes)
+	Expect(deleteClusterRes.Error).NotTo(HaveOccurred(), "Failed to delete cluster using az")
+	Expect(deleteClusterRes.ExitCode).To(Equal(0), "Deleting cluster returned non-zero exit code")
+
+	if err := os.Remove(k.kubeconfigPath); err != nil {
+		turtlesframework.Byf("Error deleting the kubeconfig file %q file. You may need to remove this by hand.", k.kubeconfigPath)
+	}
+}
+
+func versionToAKS(v *version.Version) string {
+	return fmt.Sprintf("%d.%d", v.Major(), v.Minor())
+}
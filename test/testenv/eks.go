@@ -48,8 +48,25 @@ func CreateEKSBootstrapClusterAndValidateImages(ctx context.Context, input Creat
 	Expect(input.Version).ToNot(BeEmpty(), "Version is required for CreateEKSBootstrapClusterAndValidateImages")
 	Expect(res).ToNot(BeNil(), "Result should not be nil")
 
+	validateImages(ctx, input.Images)
+
+	if input.NumWorkers == 0 {
+		By("Defaulting the bootstrap cluster to 1 worker node")
+		input.NumWorkers = 1
+	}
+
+	By("Creating EKS bootstrap cluster")
+
+	clusterProvider := NewEKSClusterProvider(input.Name, input.Version, input.Region, input.NumWorkers)
+	clusterProvider.Create(ctx)
+
+	res.BootstrapClusterProvider = clusterProvider
+}
+
+// validateImages checks the images are present in the local docker registry.
+func validateImages(ctx context.Context, images []clusterctl.ContainerImage) {
 	By("Checking images are present in registry")
-	for _, image := range input.Images {
+	for _, image := range images {
 		turtlesframework.Byf("Checking image: %s", image.Name)
 		cmdImgRes := &turtlesframework.RunCommandResult{}
 		turtlesframework.RunCommand(ctx, turtlesframework.RunCommandInput{
@@ -63,16 +80,4 @@ func CreateEKSBootstrapClusterAndValidateImages(ctx context.Context, input Creat
 		Expect(cmdImgRes.Error).NotTo(HaveOccurred(), "Failed checking if image is available %s error", image.Name)
 		Expect(cmdImgRes.ExitCode).To(Equal(0), "Image not found %s", image.Name)
 	}
-
-	if input.NumWorkers == 0 {
-		By("Defaulting the bootstrap cluster to 1 worker node")
-		input.NumWorkers = 1
-	}
-
-	By("Creating EKS bootstrap cluster")
-
-	clusterProvider := NewEKSClusterProvider(input.Name, input.Version, input.Region, input.NumWorkers)
-	clusterProvider.Create(ctx)
-
-	res.BootstrapClusterProvider = clusterProvider
 }
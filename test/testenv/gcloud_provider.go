@@ -0,0 +1,165 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package testenv
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/util/version"
+
+	"sigs.k8s.io/cluster-api/test/framework/bootstrap"
+
+	turtlesframework "github.com/rancher/turtles/test/framework"
+)
+
+func NewGKEClusterProvider(name, version, project, region string, numWorkers int) bootstrap.ClusterProvider {
+	Expect(name).ToNot(BeEmpty(), "name is required for NewGKEClusterProvider")
+	Expect(version).ToNot(BeEmpty(), "version is required for NewGKEClusterProvider")
+	Expect(numWorkers).To(BeNumerically(">", 0), "numWorkers must be greater than 0 for NewGKEClusterProvider")
+	Expect(project).ToNot(BeEmpty(), "project is required for NewGKEClusterProvider")
+	Expect(region).ToNot(BeEmpty(), "region is required for NewGKEClusterProvider")
+
+	return &GKEClusterProvider{
+		name:       name,
+		version:    version,
+		project:    project,
+		region:     region,
+		numWorkers: numWorkers,
+	}
+}
+
+type GKEClusterProvider struct {
+	name           string
+	version        string
+	project        string
+	region         string
+	numWorkers     int
+	kubeconfigPath string
+}
+
+// Create a regional GKE cluster. The number of workers is the number of nodes in each zone of the region.
+func (k *GKEClusterProvider) Create(ctx context.Context) {
+	tempFile, err := os.CreateTemp("", "kubeconfig")
+	Expect(err).NotTo(HaveOccurred(), "Failed to create temp file for kubeconfig")
+	turtlesframework.Byf("GKE kubeconfig will be written to temp file %s", tempFile.Name())
+
+	gkeVersion := versionToGKE(parseEKSVersion(k.version))
+
+	turtlesframework.Byf("Creating cluster using gcloud (version %s)", gkeVersion)
+
+	createClusterRes := &turtlesframework.RunCommandResult{}
+	turtlesframework.RunCommand(ctx, turtlesframework.RunCommandInput{
+		Command: "gcloud",
+		Args: []string{
+			"container",
+			"clusters",
+			"create",
+			k.name,
+			"--project",
+			k.project,
+			"--region",
+			k.region,
+			"--cluster-version",
+			gkeVersion,
+			"--num-nodes",
+			strconv.Itoa(k.numWorkers),
+			"--quiet",
+		},
+	}, createClusterRes)
+	Expect(createClusterRes.Error).NotTo(HaveOccurred(), "Failed to create cluster using gcloud: %s", createClusterRes.Stderr)
+	Expect(createClusterRes.ExitCode).To(Equal(0), "Creating cluster returned non-zero exit code")
+
+	// gcloud only writes the credentials to the kubeconfig file set in the environment.
+	credentialsRes := &turtlesframework.RunCommandResult{}
+	turtlesframework.RunCommand(ctx, turtlesframework.RunCommandInput{
+		Command: "gcloud",
+		Args: []string{
+			"container",
+			"clusters",
+			"get-credentials",
+			k.name,
+			"--project",
+			k.project,
+			"--region",
+			k.region,
+		},
+		EnvironmentVariables: gcloudEnvironment(tempFile.Name()),
+	}, credentialsRes)
+	Expect(credentialsRes.Error).NotTo(HaveOccurred(), "Failed to get cluster credentials using gcloud: %s", credentialsRes.Stderr)
+	Expect(credentialsRes.ExitCode).To(Equal(0), "Getting cluster credentials returned non-zero exit code")
+
+	k.kubeconfigPath = tempFile.Name()
+}
+
+// GetKubeconfigPath returns the path to the kubeconfig file for the cluster.
+func (k *GKEClusterProvider) GetKubeconfigPath() string {
+	return k.kubeconfigPath
+}
+
+// Dispose the GKE cluster and its kubeconfig file.
+func (k *GKEClusterProvider) Dispose(ctx context.Context) {
+	Expect(ctx).NotTo(BeNil(), "ctx is required for Dispose")
+
+	By("Deleting cluster using gcloud")
+
+	deleteClusterRes := &turtlesframework.RunCommandResult{}
+	turtlesframework.RunCommand(ctx, turtlesframework.RunCommandInput{
+		Command: "gcloud",
+		Args: []string{
+			"container",
+			"clusters",
+			"delete",
+			k.name,
+			"--project",
+			k.project,
+			"--region",
+			k.region,
+			"--quiet",
+		},
+	}, deleteClusterRes)
+	Expect(deleteClusterRes.Error).NotTo(HaveOccurred(), "Failed to delete cluster using gcloud: %s", deleteClusterRes.Stderr)
+	Expect(deleteClusterRes.ExitCode).To(Equal(0), "Deleting cluster returned non-zero exit code")
+
+	if err := os.Remove(k.kubeconfigPath); err != nil {
+		turtlesframework.Byf("Error deleting the kubeconfig file %q file. You may need to remove this by hand.", k.kubeconfigPath)
+	}
+}
+
+// gcloudEnvironment returns the environment of the test process with KUBECONFIG set to the kubeconfig path, as the
+// environment of a command replaces the one of the test process.
+func gcloudEnvironment(kubeconfigPath string) map[string]string {
+	env := map[string]string{}
+
+	for _, variable := range os.Environ() {
+		name, value, _ := strings.Cut(variable, "=")
+		env[name] = value
+	}
+
+	env["KUBECONFIG"] = kubeconfigPath
+
+	return env
+}
+
+func versionToGKE(v *version.Version) string {
+	return fmt.Sprintf("%d.%d", v.Major(), v.Minor())
+}
@@ -0,0 +1,65 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package testenv
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"sigs.k8s.io/cluster-api/test/framework/bootstrap"
+	"sigs.k8s.io/cluster-api/test/framework/clusterctl"
+)
+
+type CreateGKEBootstrapClusterAndValidateImagesInput struct {
+	Name       string
+	Version    string
+	Project    string
+	Region     string
+	NumWorkers int
+	Images     []clusterctl.ContainerImage
+}
+
+type CreateGKEBootstrapClusterAndValidateImagesInputResult struct {
+	// BootstrapClusterProvider manages provisioning of the the bootstrap cluster to be used for the e2e tests.
+	// Please note that provisioning will be skipped if e2e.use-existing-cluster is provided.
+	BootstrapClusterProvider bootstrap.ClusterProvider
+}
+
+func CreateGKEBootstrapClusterAndValidateImages(ctx context.Context, input CreateGKEBootstrapClusterAndValidateImagesInput, res *CreateGKEBootstrapClusterAndValidateImagesInputResult) {
+	Expect(ctx).ToNot(BeNil(), "Context is required for CreateGKEBootstrapClusterAndValidateImages")
+	Expect(input.Name).ToNot(BeEmpty(), "Name is required for CreateGKEBootstrapClusterAndValidateImages")
+	Expect(input.Version).ToNot(BeEmpty(), "Version is required for CreateGKEBootstrapClusterAndValidateImages")
+	Expect(input.Project).ToNot(BeEmpty(), "Project is required for CreateGKEBootstrapClusterAndValidateImages")
+	Expect(input.Region).ToNot(BeEmpty(), "Region is required for CreateGKEBootstrapClusterAndValidateImages")
+	Expect(res).ToNot(BeNil(), "Result should not be nil")
+
+	validateImages(ctx, input.Images)
+
+	if input.NumWorkers == 0 {
+		By("Defaulting the bootstrap cluster to 1 worker node")
+		input.NumWorkers = 1
+	}
+
+	By("Creating GKE bootstrap cluster")
+
+	clusterProvider := NewGKEClusterProvider(input.Name, input.Version, input.Project, input.Region, input.NumWorkers)
+	clusterProvider.Create(ctx)
+
+	res.BootstrapClusterProvider = clusterProvider
+}
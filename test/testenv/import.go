@@ -0,0 +1,99 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package testenv
+
+import (
+	"context"
+	"fmt"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"k8s.io/apimachinery/pkg/types"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/test/framework"
+	"sigs.k8s.io/yaml"
+
+	provisioningv1 "github.com/rancher/turtles/internal/rancher/provisioning/v1"
+	turtlesframework "github.com/rancher/turtles/test/framework"
+	turtlesannotations "github.com/rancher/turtles/util/annotations"
+	turtlesnaming "github.com/rancher/turtles/util/naming"
+)
+
+type WaitForClusterImportedInput struct {
+	BootstrapClusterProxy framework.ClusterProxy
+	ClusterName           string
+	ClusterNamespace      string
+	// RancherClusterNamespace is the namespace of the Rancher cluster, defaults to the namespace of the CAPI cluster.
+	RancherClusterNamespace string
+	ImportWaitInterval      []interface{}
+}
+
+// WaitForClusterImported waits for the CAPI cluster to be imported in Rancher: its Rancher cluster has a deployed
+// agent and is ready, and the CAPI cluster is not marked as imported, which only happens when its Rancher cluster is
+// removed. The status of the Rancher cluster is dumped when the import doesn't complete in time.
+func WaitForClusterImported(ctx context.Context, input WaitForClusterImportedInput) {
+	Expect(ctx).NotTo(BeNil(), "ctx is required for WaitForClusterImported")
+	Expect(input.BootstrapClusterProxy).ToNot(BeNil(), "BootstrapClusterProxy is required for WaitForClusterImported")
+	Expect(input.ClusterName).ToNot(BeEmpty(), "ClusterName is required for WaitForClusterImported")
+	Expect(input.ClusterNamespace).ToNot(BeEmpty(), "ClusterNamespace is required for WaitForClusterImported")
+	Expect(input.ImportWaitInterval).ToNot(BeNil(), "ImportWaitInterval is required for WaitForClusterImported")
+
+	if input.RancherClusterNamespace == "" {
+		input.RancherClusterNamespace = input.ClusterNamespace
+	}
+
+	cl := input.BootstrapClusterProxy.GetClient()
+	capiClusterKey := types.NamespacedName{Namespace: input.ClusterNamespace, Name: input.ClusterName}
+	rancherClusterKey := types.NamespacedName{
+		Namespace: input.RancherClusterNamespace,
+		Name:      turtlesnaming.Name(input.ClusterName).ToRancherName(),
+	}
+
+	turtlesframework.Byf("Waiting for cluster %s to be imported as Rancher cluster %s", capiClusterKey, rancherClusterKey)
+
+	Eventually(func() error {
+		capiCluster := &clusterv1.Cluster{}
+		if err := cl.Get(ctx, capiClusterKey, capiCluster); err != nil {
+			return fmt.Errorf("failed to get cluster %s: %w", capiClusterKey, err)
+		}
+
+		if turtlesannotations.HasClusterImportAnnotation(capiCluster) {
+			return fmt.Errorf("cluster %s has the %q annotation, its Rancher cluster was removed",
+				capiClusterKey, turtlesannotations.ClusterImportedAnnotation)
+		}
+
+		rancherCluster := &provisioningv1.Cluster{}
+		if err := cl.Get(ctx, rancherClusterKey, rancherCluster); err != nil {
+			return fmt.Errorf("failed to get rancher cluster %s: %w", rancherClusterKey, err)
+		}
+
+		if !rancherCluster.Status.AgentDeployed || !rancherCluster.Status.Ready {
+			status, err := yaml.Marshal(rancherCluster.Status)
+			if err != nil {
+				return fmt.Errorf("failed to marshal rancher cluster %s status: %w", rancherClusterKey, err)
+			}
+
+			return fmt.Errorf("rancher cluster %s is not imported yet, agent deployed: %t, ready: %t, status:\n%s",
+				rancherClusterKey, rancherCluster.Status.AgentDeployed, rancherCluster.Status.Ready, status)
+		}
+
+		return nil
+	}, input.ImportWaitInterval...).Should(Succeed(), "Failed to wait for cluster %s to be imported", capiClusterKey)
+
+	By("Cluster was imported in Rancher")
+}
@@ -0,0 +1,109 @@
+//go:build e2e
+// +build e2e
+
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package testenv
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/test/framework"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	provisioningv1 "github.com/rancher/turtles/internal/rancher/provisioning/v1"
+	turtlesannotations "github.com/rancher/turtles/util/annotations"
+)
+
+// fakeClusterProxy is a cluster proxy only providing a client.
+type fakeClusterProxy struct {
+	framework.ClusterProxy
+	client client.Client
+}
+
+func (p *fakeClusterProxy) GetClient() client.Client {
+	return p.client
+}
+
+var _ = Describe("WaitForClusterImported", func() {
+	var (
+		capiCluster    *clusterv1.Cluster
+		rancherCluster *provisioningv1.Cluster
+	)
+
+	BeforeEach(func() {
+		capiCluster = &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "cluster1", Namespace: "default"}}
+		rancherCluster = &provisioningv1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "cluster1-capi", Namespace: "default"},
+			Status:     provisioningv1.ClusterStatus{ClusterName: "c-m-cluster1", AgentDeployed: true, Ready: true},
+		}
+	})
+
+	waitForClusterImported := func() []string {
+		scheme := runtime.NewScheme()
+		Expect(clusterv1.AddToScheme(scheme)).To(Succeed())
+		Expect(provisioningv1.AddToScheme(scheme)).To(Succeed())
+
+		proxy := &fakeClusterProxy{client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(capiCluster, rancherCluster).Build()}
+
+		return InterceptGomegaFailures(func() {
+			WaitForClusterImported(context.Background(), WaitForClusterImportedInput{
+				BootstrapClusterProxy: proxy,
+				ClusterName:           capiCluster.Name,
+				ClusterNamespace:      capiCluster.Namespace,
+				ImportWaitInterval:    []interface{}{100 * time.Millisecond, 10 * time.Millisecond},
+			})
+		})
+	}
+
+	It("should succeed when the Rancher cluster is ready", func() {
+		Expect(waitForClusterImported()).To(BeEmpty())
+	})
+
+	It("should dump the Rancher cluster status when the agent is not deployed", func() {
+		rancherCluster.Status.AgentDeployed = false
+		rancherCluster.Status.Ready = false
+
+		failures := waitForClusterImported()
+		Expect(failures).To(HaveLen(1))
+		Expect(failures[0]).To(ContainSubstring("agent deployed: false, ready: false"))
+		Expect(failures[0]).To(ContainSubstring("clusterName: c-m-cluster1"))
+	})
+
+	It("should fail when the Rancher cluster is missing", func() {
+		rancherCluster.Name = "other"
+
+		failures := waitForClusterImported()
+		Expect(failures).To(HaveLen(1))
+		Expect(failures[0]).To(ContainSubstring("failed to get rancher cluster default/cluster1-capi"))
+	})
+
+	It("should fail when the Rancher cluster of the CAPI cluster was removed", func() {
+		capiCluster.Annotations = map[string]string{turtlesannotations.ClusterImportedAnnotation: "true"}
+
+		failures := waitForClusterImported()
+		Expect(failures).To(HaveLen(1))
+		Expect(failures[0]).To(ContainSubstring("its Rancher cluster was removed"))
+	})
+})
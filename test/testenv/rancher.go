@@ -20,6 +20,7 @@ import (
 	"context"
 	"io/ioutil"
 	"os"
+	"strings"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -38,30 +39,34 @@ import (
 )
 
 type DeployRancherInput struct {
-	BootstrapClusterProxy   framework.ClusterProxy
-	HelmBinaryPath          string
-	HelmExtraValuesPath     string
-	InstallCertManager      bool
-	CertManagerChartPath    string
-	CertManagerUrl          string
-	CertManagerRepoName     string
-	RancherChartRepoName    string
-	RancherChartURL         string
-	RancherChartPath        string
-	RancherVersion          string
-	RancherImageTag         string
-	RancherNamespace        string
-	RancherHost             string
-	RancherPassword         string
-	RancherFeatures         string
-	RancherPatches          [][]byte
-	RancherWaitInterval     []interface{}
-	ControllerWaitInterval  []interface{}
-	RancherIngressConfig    []byte
-	RancherServicePatch     []byte
-	RancherIngressClassName string
-	Development             bool
-	Variables               turtlesframework.VariableCollection
+	BootstrapClusterProxy framework.ClusterProxy
+	HelmBinaryPath        string
+	HelmExtraValuesPath   string
+	InstallCertManager    bool
+	CertManagerChartPath  string
+	CertManagerUrl        string
+	CertManagerRepoName   string
+	RancherChartRepoName  string
+	RancherChartURL       string
+	RancherChartPath      string
+	// RancherChartRegistryUsername and RancherChartRegistryPassword are the optional credentials to log in the
+	// registry of an OCI RancherChartURL.
+	RancherChartRegistryUsername string
+	RancherChartRegistryPassword string
+	RancherVersion               string
+	RancherImageTag              string
+	RancherNamespace             string
+	RancherHost                  string
+	RancherPassword              string
+	RancherFeatures              string
+	RancherPatches               [][]byte
+	RancherWaitInterval          []interface{}
+	ControllerWaitInterval       []interface{}
+	RancherIngressConfig         []byte
+	RancherServicePatch          []byte
+	RancherIngressClassName      string
+	Development                  bool
+	Variables                    turtlesframework.VariableCollection
 }
 
 type deployRancherValuesFile struct {
@@ -82,9 +87,15 @@ func DeployRancher(ctx context.Context, input DeployRancherInput) {
 	Expect(input.BootstrapClusterProxy).ToNot(BeNil(), "BootstrapClusterProxy is required for DeployRancher")
 	Expect(input.HelmBinaryPath).ToNot(BeEmpty(), "HelmBinaryPath is required for DeployRancher")
 	Expect(input.HelmExtraValuesPath).ToNot(BeEmpty(), "HelmExtraValuesPath is required for DeployRancher")
-	Expect(input.RancherChartRepoName).ToNot(BeEmpty(), "RancherChartRepoName is required for DeployRancher")
 	Expect(input.RancherChartURL).ToNot(BeEmpty(), "RancherChartURL is required for DeployRancher")
-	Expect(input.RancherChartPath).ToNot(BeEmpty(), "RancherChartPath is required for DeployRancher")
+	if isOCIChart(input.RancherChartURL) {
+		Expect(input.RancherChartRegistryUsername == "").To(Equal(input.RancherChartRegistryPassword == ""),
+			"RancherChartRegistryUsername and RancherChartRegistryPassword must be set together for DeployRancher")
+	} else {
+		Expect(input.RancherChartRepoName).ToNot(BeEmpty(), "RancherChartRepoName is required for DeployRancher")
+		Expect(input.RancherChartPath).ToNot(BeEmpty(), "RancherChartPath is required for DeployRancher")
+		Expect(input.RancherChartRegistryUsername).To(BeEmpty(), "RancherChartRegistryUsername requires an OCI RancherChartURL for DeployRancher")
+	}
 	Expect(input.RancherNamespace).ToNot(BeEmpty(), "RancherNamespace is required for DeployRancher")
 	Expect(input.RancherHost).ToNot(BeEmpty(), "RancherHost is required for DeployRancher")
 	Expect(input.RancherPassword).ToNot(BeEmpty(), "RancherPassword is required for DeployRancher")
@@ -116,25 +127,41 @@ func DeployRancher(ctx context.Context, input DeployRancherInput) {
 		Expect(certErr).ToNot(HaveOccurred())
 	}
 
-	By("Adding Rancher chart repo")
-	addChart := &opframework.HelmChart{
-		BinaryPath:      input.HelmBinaryPath,
-		Name:            input.RancherChartRepoName,
-		Path:            input.RancherChartURL,
-		Commands:        opframework.Commands(opframework.Repo, opframework.Add),
-		AdditionalFlags: opframework.Flags("--force-update"),
-		Kubeconfig:      input.BootstrapClusterProxy.GetKubeconfigPath(),
+	if isOCIChart(input.RancherChartURL) {
+		if input.RancherChartRegistryUsername != "" {
+			turtlesframework.Byf("Logging in Rancher chart registry %s", ociRegistryHost(input.RancherChartURL))
+			result := &turtlesframework.RunCommandResult{}
+			turtlesframework.RunCommand(ctx, turtlesframework.RunCommandInput{
+				Command: input.HelmBinaryPath,
+				Args:    rancherChartRegistryLoginArgs(input),
+				Stdin:   []byte(input.RancherChartRegistryPassword),
+			}, result)
+			Expect(result.Error).ToNot(HaveOccurred(), "Failed to log in Rancher chart registry: %s", result.Stderr)
+		}
+	} else {
+		By("Adding Rancher chart repo")
+		addChart := &opframework.HelmChart{
+			BinaryPath:      input.HelmBinaryPath,
+			Name:            input.RancherChartRepoName,
+			Path:            input.RancherChartURL,
+			Commands:        opframework.Commands(opframework.Repo, opframework.Add),
+			AdditionalFlags: opframework.Flags("--force-update"),
+			Kubeconfig:      input.BootstrapClusterProxy.GetKubeconfigPath(),
+		}
+		_, err := addChart.Run(nil)
+		Expect(err).ToNot(HaveOccurred())
 	}
-	_, err := addChart.Run(nil)
-	Expect(err).ToNot(HaveOccurred())
 
-	updateChart := &opframework.HelmChart{
-		BinaryPath: input.HelmBinaryPath,
-		Commands:   opframework.Commands(opframework.Repo, opframework.Update),
-		Kubeconfig: input.BootstrapClusterProxy.GetKubeconfigPath(),
+	// an OCI chart is installed from its reference, the repositories are only updated for cert-manager
+	if !isOCIChart(input.RancherChartURL) || input.InstallCertManager {
+		updateChart := &opframework.HelmChart{
+			BinaryPath: input.HelmBinaryPath,
+			Commands:   opframework.Commands(opframework.Repo, opframework.Update),
+			Kubeconfig: input.BootstrapClusterProxy.GetKubeconfigPath(),
+		}
+		_, err := updateChart.Run(nil)
+		Expect(err).ToNot(HaveOccurred())
 	}
-	_, err = updateChart.Run(nil)
-	Expect(err).ToNot(HaveOccurred())
 
 	if input.InstallCertManager {
 		By("Installing cert-manager")
@@ -150,7 +177,7 @@ func DeployRancher(ctx context.Context, input DeployRancherInput) {
 			),
 			Wait: true,
 		}
-		_, err = certManagerChart.Run(map[string]string{
+		_, err := certManagerChart.Run(map[string]string{
 			"installCRDs": "true",
 		})
 		Expect(err).ToNot(HaveOccurred())
@@ -165,26 +192,8 @@ func DeployRancher(ctx context.Context, input DeployRancherInput) {
 	Expect(err).ToNot(HaveOccurred())
 
 	By("Installing Rancher")
-	installFlags := opframework.Flags(
-		"--namespace", input.RancherNamespace,
-		"--create-namespace",
-		"--values", input.HelmExtraValuesPath,
-	)
-	if input.RancherVersion != "" {
-		installFlags = append(installFlags, "--version", input.RancherVersion)
-	}
-	if input.Development {
-		installFlags = append(installFlags, "--devel")
-	}
-
-	chart := &opframework.HelmChart{
-		BinaryPath:      input.HelmBinaryPath,
-		Path:            input.RancherChartPath,
-		Name:            "rancher",
-		Kubeconfig:      input.BootstrapClusterProxy.GetKubeconfigPath(),
-		AdditionalFlags: installFlags,
-		Wait:            true,
-	}
+	chart := rancherChart(input)
+	chart.Kubeconfig = input.BootstrapClusterProxy.GetKubeconfigPath()
 	values := map[string]string{
 		"global.cattle.psp.enabled": "false",
 		"replicas":                  "1",
@@ -244,6 +253,56 @@ func DeployRancher(ctx context.Context, input DeployRancherInput) {
 	}, input.ControllerWaitInterval...).ShouldNot(HaveOccurred())
 }
 
+// isOCIChart returns true when the chart URL is an OCI reference, e.g. oci://registry.example.com/charts/rancher.
+func isOCIChart(chartURL string) bool {
+	return strings.HasPrefix(chartURL, "oci://")
+}
+
+// ociRegistryHost returns the registry host of an OCI chart reference.
+func ociRegistryHost(chartURL string) string {
+	host, _, _ := strings.Cut(strings.TrimPrefix(chartURL, "oci://"), "/")
+	return host
+}
+
+// rancherChartRegistryLoginArgs returns the helm arguments to log in the registry of an OCI chart, the password is read
+// from the standard input so it doesn't show in the process list.
+func rancherChartRegistryLoginArgs(input DeployRancherInput) []string {
+	return []string{
+		"registry", "login", ociRegistryHost(input.RancherChartURL),
+		"--username", input.RancherChartRegistryUsername,
+		"--password-stdin",
+	}
+}
+
+// rancherChart returns the helm chart installing Rancher, from the chart repository added for RancherChartRepoName or
+// directly from an OCI RancherChartURL.
+func rancherChart(input DeployRancherInput) *opframework.HelmChart {
+	chartPath := input.RancherChartPath
+	if isOCIChart(input.RancherChartURL) {
+		chartPath = input.RancherChartURL
+	}
+
+	installFlags := opframework.Flags(
+		"--namespace", input.RancherNamespace,
+		"--create-namespace",
+		"--values", input.HelmExtraValuesPath,
+	)
+	if input.RancherVersion != "" {
+		installFlags = append(installFlags, "--version", input.RancherVersion)
+	}
+	if input.Development {
+		installFlags = append(installFlags, "--devel")
+	}
+
+	return &opframework.HelmChart{
+		BinaryPath:      input.HelmBinaryPath,
+		Path:            chartPath,
+		Name:            "rancher",
+		AdditionalFlags: installFlags,
+		Wait:            true,
+	}
+}
+
 type RestartRancherInput struct {
 	BootstrapClusterProxy framework.ClusterProxy
 	RancherNamespace      string
@@ -0,0 +1,76 @@
+//go:build e2e
+// +build e2e
+
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package testenv
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	opframework "sigs.k8s.io/cluster-api-operator/test/framework"
+)
+
+var _ = Describe("Rancher chart", func() {
+	var input DeployRancherInput
+
+	BeforeEach(func() {
+		input = DeployRancherInput{
+			HelmBinaryPath:       "helm",
+			HelmExtraValuesPath:  "/tmp/values.yaml",
+			RancherChartRepoName: "rancher-latest",
+			RancherChartURL:      "https://releases.rancher.com/server-charts/latest",
+			RancherChartPath:     "rancher-latest/rancher",
+			RancherNamespace:     "cattle-system",
+			RancherVersion:       "2.8.1",
+		}
+	})
+
+	It("should install the chart from the repository", func() {
+		chart := rancherChart(input)
+		Expect(chart.Path).To(Equal("rancher-latest/rancher"))
+		Expect(chart.Name).To(Equal("rancher"))
+		Expect(chart.AdditionalFlags).To(Equal(opframework.Flags(
+			"--namespace", "cattle-system", "--create-namespace", "--values", "/tmp/values.yaml", "--version", "2.8.1",
+		)))
+	})
+
+	It("should install an OCI chart from its reference", func() {
+		input.RancherChartURL = "oci://registry.example.com:5000/charts/rancher"
+		input.Development = true
+
+		Expect(isOCIChart(input.RancherChartURL)).To(BeTrue())
+
+		chart := rancherChart(input)
+		Expect(chart.Path).To(Equal("oci://registry.example.com:5000/charts/rancher"))
+		Expect(chart.AdditionalFlags).To(Equal(opframework.Flags(
+			"--namespace", "cattle-system", "--create-namespace", "--values", "/tmp/values.yaml", "--version", "2.8.1", "--devel",
+		)))
+	})
+
+	It("should log in the registry of an OCI chart without passing the password as an argument", func() {
+		input.RancherChartURL = "oci://registry.example.com:5000/charts/rancher"
+		input.RancherChartRegistryUsername = "user"
+		input.RancherChartRegistryPassword = "secret"
+
+		args := rancherChartRegistryLoginArgs(input)
+		Expect(args).To(Equal([]string{
+			"registry", "login", "registry.example.com:5000", "--username", "user", "--password-stdin",
+		}))
+		Expect(args).ToNot(ContainElement("secret"))
+	})
+})
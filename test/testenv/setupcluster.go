@@ -21,6 +21,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -38,6 +39,8 @@ import (
 type SetupTestClusterInput struct {
 	UseExistingCluster   bool
 	UseEKS               bool
+	UseAKS               bool
+	UseGKE               bool
 	E2EConfig            *clusterctl.E2EConfig
 	ClusterctlConfigPath string
 	Scheme               *runtime.Scheme
@@ -76,7 +79,7 @@ func SetupTestCluster(ctx context.Context, input SetupTestClusterInput) *SetupTe
 
 	By("Setting up the bootstrap cluster")
 	result.BootstrapClusterProvider, result.BootstrapClusterProxy = setupCluster(
-		ctx, input.E2EConfig, input.Scheme, clusterName, input.UseExistingCluster, input.UseEKS, input.KubernetesVersion)
+		ctx, input.E2EConfig, input.Scheme, clusterName, input.UseExistingCluster, input.UseEKS, input.UseAKS, input.UseGKE, input.KubernetesVersion)
 
 	if input.UseExistingCluster {
 		return result
@@ -94,11 +97,23 @@ func SetupTestCluster(ctx context.Context, input SetupTestClusterInput) *SetupTe
 	return result
 }
 
-func setupCluster(ctx context.Context, config *clusterctl.E2EConfig, scheme *runtime.Scheme, clusterName string, useExistingCluster, useEKS bool, kubernetesVersion string) (bootstrap.ClusterProvider, framework.ClusterProxy) {
+func setupCluster(ctx context.Context, config *clusterctl.E2EConfig, scheme *runtime.Scheme, clusterName string, useExistingCluster, useEKS, useAKS, useGKE bool, kubernetesVersion string) (bootstrap.ClusterProvider, framework.ClusterProxy) {
+	cloudProviders := 0
+	for _, useCloud := range []bool{useEKS, useAKS, useGKE} {
+		if useCloud {
+			cloudProviders++
+		}
+	}
+
+	if cloudProviders > 1 {
+		Fail(fmt.Sprintf("Only one of EKS, AKS and GKE can be used for the bootstrap cluster, got EKS=%t AKS=%t GKE=%t", useEKS, useAKS, useGKE))
+	}
+
 	var clusterProvider bootstrap.ClusterProvider
 	kubeconfigPath := ""
 	if !useExistingCluster {
-		if useEKS {
+		switch {
+		case useEKS:
 			region := config.Variables["KUBERNETES_MANAGEMENT_AWS_REGION"]
 			Expect(region).ToNot(BeEmpty(), "KUBERNETES_MANAGEMENT_AWS_REGION must be set in the e2e config")
 
@@ -111,8 +126,36 @@ func setupCluster(ctx context.Context, config *clusterctl.E2EConfig, scheme *run
 				Images:     config.Images,
 			}, eksCreateResult)
 			clusterProvider = eksCreateResult.BootstrapClusterProvider
-
-		} else {
+		case useAKS:
+			numWorkers, err := strconv.Atoi(requiredVariable(config, "KUBERNETES_MANAGEMENT_AZURE_NODE_COUNT"))
+			Expect(err).ToNot(HaveOccurred(), "KUBERNETES_MANAGEMENT_AZURE_NODE_COUNT must be a number")
+
+			aksCreateResult := &CreateAKSBootstrapClusterAndValidateImagesInputResult{}
+			CreateAKSBootstrapClusterAndValidateImages(ctx, CreateAKSBootstrapClusterAndValidateImagesInput{
+				Name:           clusterName,
+				Version:        kubernetesVersion,
+				SubscriptionID: requiredVariable(config, "AZURE_SUBSCRIPTION_ID"),
+				ResourceGroup:  requiredVariable(config, "KUBERNETES_MANAGEMENT_AZURE_RESOURCE_GROUP"),
+				Region:         requiredVariable(config, "KUBERNETES_MANAGEMENT_AZURE_REGION"),
+				NumWorkers:     numWorkers,
+				Images:         config.Images,
+			}, aksCreateResult)
+			clusterProvider = aksCreateResult.BootstrapClusterProvider
+		case useGKE:
+			numWorkers, err := strconv.Atoi(requiredVariable(config, "KUBERNETES_MANAGEMENT_GCP_NODE_COUNT"))
+			Expect(err).ToNot(HaveOccurred(), "KUBERNETES_MANAGEMENT_GCP_NODE_COUNT must be a number")
+
+			gkeCreateResult := &CreateGKEBootstrapClusterAndValidateImagesInputResult{}
+			CreateGKEBootstrapClusterAndValidateImages(ctx, CreateGKEBootstrapClusterAndValidateImagesInput{
+				Name:       clusterName,
+				Version:    kubernetesVersion,
+				Project:    requiredVariable(config, "KUBERNETES_MANAGEMENT_GCP_PROJECT"),
+				Region:     requiredVariable(config, "KUBERNETES_MANAGEMENT_GCP_REGION"),
+				NumWorkers: numWorkers,
+				Images:     config.Images,
+			}, gkeCreateResult)
+			clusterProvider = gkeCreateResult.BootstrapClusterProvider
+		default:
 			clusterProvider = bootstrap.CreateKindBootstrapClusterAndLoadImages(ctx, bootstrap.CreateKindBootstrapClusterAndLoadImagesInput{
 				Name:               clusterName,
 				KubernetesVersion:  kubernetesVersion,
@@ -132,6 +175,16 @@ func setupCluster(ctx context.Context, config *clusterctl.E2EConfig, scheme *run
 	return clusterProvider, proxy
 }
 
+// requiredVariable returns the variable from the environment or the e2e config, failing when it is unset or empty.
+func requiredVariable(config *clusterctl.E2EConfig, name string) string {
+	Expect(config.HasVariable(name)).To(BeTrue(), "%s must be set in the environment or in the e2e config", name)
+
+	value := config.GetVariable(name)
+	Expect(value).ToNot(BeEmpty(), "%s must not be empty", name)
+
+	return value
+}
+
 // configureIsolatedEnvironment gets the isolatedHostName by setting it to the IP of the first and only node in the boostrap cluster. Labels the node with
 // "ingress-ready" so that the nginx ingress controller can pick it up, required by kind. See: https://kind.sigs.k8s.io/docs/user/ingress/#create-cluster
 func configureIsolatedEnvironment(ctx context.Context, clusterProxy framework.ClusterProxy) string {
@@ -0,0 +1,179 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package testutil contains builders for fake Rancher clients and manifest servers, to test the import reconciler
+// and the packages building on it.
+package testutil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	managementv3 "github.com/rancher/turtles/internal/rancher/management/v3"
+	provisioningv1 "github.com/rancher/turtles/internal/rancher/provisioning/v1"
+)
+
+// ClusterState is the import progress of a Rancher provisioning cluster.
+type ClusterState int
+
+const (
+	// ClusterStateNoName is a Rancher cluster which has not been assigned a management cluster name yet.
+	ClusterStateNoName ClusterState = iota
+	// ClusterStateNameSet is a Rancher cluster with a management cluster name, waiting for the agent.
+	ClusterStateNameSet
+	// ClusterStateAgentDeployed is a Rancher cluster with the agent deployed on the downstream cluster.
+	ClusterStateAgentDeployed
+	// ClusterStateReady is a Rancher cluster with the agent deployed which is ready.
+	ClusterStateReady
+)
+
+// NewScheme returns a scheme with the kubernetes, CAPI and Rancher types registered.
+func NewScheme() *runtime.Scheme {
+	scheme := runtime.NewScheme()
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(clusterv1.AddToScheme(scheme))
+	utilruntime.Must(provisioningv1.AddToScheme(scheme))
+	utilruntime.Must(managementv3.AddToScheme(scheme))
+
+	return scheme
+}
+
+// ManagementClusterName returns the management cluster name assigned to a Rancher cluster with the given name.
+func ManagementClusterName(name string) string {
+	return "c-m-" + name
+}
+
+// RancherCluster returns a provisioning cluster with the status set according to the state.
+func RancherCluster(name, namespace string, state ClusterState) *provisioningv1.Cluster {
+	cluster := &provisioningv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+	}
+
+	if state >= ClusterStateNameSet {
+		cluster.Status.ClusterName = ManagementClusterName(name)
+	}
+
+	cluster.Status.AgentDeployed = state >= ClusterStateAgentDeployed
+	cluster.Status.Ready = state >= ClusterStateReady
+
+	return cluster
+}
+
+// RegistrationToken returns a cluster registration token for the management cluster name, pointing to the manifest URL.
+func RegistrationToken(clusterName, namespace, manifestURL string) *managementv3.ClusterRegistrationToken {
+	return &managementv3.ClusterRegistrationToken{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      clusterName,
+			Namespace: namespace,
+		},
+		Spec: managementv3.ClusterRegistrationTokenSpec{
+			ClusterName: clusterName,
+		},
+		Status: managementv3.ClusterRegistrationTokenStatus{
+			ManifestURL: manifestURL,
+		},
+	}
+}
+
+// RancherClientBuilder builds a fake Rancher client seeded with provisioning clusters and registration tokens.
+type RancherClientBuilder struct {
+	scheme       *runtime.Scheme
+	objects      []client.Object
+	interceptors *interceptor.Funcs
+}
+
+// NewRancherClientBuilder returns a new RancherClientBuilder.
+func NewRancherClientBuilder() *RancherClientBuilder {
+	return &RancherClientBuilder{scheme: NewScheme()}
+}
+
+// WithCluster adds a provisioning cluster in the given state. When the state has a management cluster name and
+// manifestURL is not empty, a matching registration token is added as well.
+func (b *RancherClientBuilder) WithCluster(name, namespace string, state ClusterState, manifestURL string) *RancherClientBuilder {
+	cluster := RancherCluster(name, namespace, state)
+	b.objects = append(b.objects, cluster)
+
+	if cluster.Status.ClusterName != "" && manifestURL != "" {
+		b.objects = append(b.objects, RegistrationToken(cluster.Status.ClusterName, namespace, manifestURL))
+	}
+
+	return b
+}
+
+// WithObjects adds arbitrary objects to the client.
+func (b *RancherClientBuilder) WithObjects(objs ...client.Object) *RancherClientBuilder {
+	b.objects = append(b.objects, objs...)
+
+	return b
+}
+
+// WithInterceptorFuncs intercepts the requests to the client, to simulate Rancher failures.
+func (b *RancherClientBuilder) WithInterceptorFuncs(funcs interceptor.Funcs) *RancherClientBuilder {
+	b.interceptors = &funcs
+
+	return b
+}
+
+// Build returns the fake client.
+func (b *RancherClientBuilder) Build() client.Client {
+	builder := fake.NewClientBuilder().WithScheme(b.scheme).WithObjects(b.objects...).WithStatusSubresource(
+		&provisioningv1.Cluster{},
+		&managementv3.ClusterRegistrationToken{},
+	)
+
+	if b.interceptors != nil {
+		builder = builder.WithInterceptorFuncs(*b.interceptors)
+	}
+
+	return builder.Build()
+}
+
+// ManifestServer is a fake HTTP server serving an import manifest.
+type ManifestServer struct {
+	*httptest.Server
+
+	requests atomic.Int32
+}
+
+// NewManifestServer starts a server returning the manifest on every request. The caller must close it.
+func NewManifestServer(manifest string) *ManifestServer {
+	s := &ManifestServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		s.requests.Add(1)
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(manifest))
+	}))
+
+	return s
+}
+
+// Requests returns the number of requests served.
+func (s *ManifestServer) Requests() int {
+	return int(s.requests.Load())
+}
@@ -0,0 +1,65 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package testutil_test
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	managementv3 "github.com/rancher/turtles/internal/rancher/management/v3"
+	provisioningv1 "github.com/rancher/turtles/internal/rancher/provisioning/v1"
+	"github.com/rancher/turtles/testutil"
+)
+
+func TestTestutil(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Testutil Suite")
+}
+
+var _ = Describe("fake rancher client builder", func() {
+	DescribeTable("should seed the cluster in the requested state",
+		func(state testutil.ClusterState, clusterName string, agentDeployed, ready bool) {
+			cl := testutil.NewRancherClientBuilder().WithCluster("cluster", "ns", state, "https://rancher/manifest").Build()
+
+			cluster := &provisioningv1.Cluster{}
+			Expect(cl.Get(context.Background(), client.ObjectKey{Name: "cluster", Namespace: "ns"}, cluster)).To(Succeed())
+			Expect(cluster.Status.ClusterName).To(Equal(clusterName))
+			Expect(cluster.Status.AgentDeployed).To(Equal(agentDeployed))
+			Expect(cluster.Status.Ready).To(Equal(ready))
+
+			tokens := &managementv3.ClusterRegistrationTokenList{}
+			Expect(cl.List(context.Background(), tokens)).To(Succeed())
+
+			if clusterName == "" {
+				Expect(tokens.Items).To(BeEmpty())
+				return
+			}
+
+			Expect(tokens.Items).To(HaveLen(1))
+			Expect(tokens.Items[0].Name).To(Equal(clusterName))
+			Expect(tokens.Items[0].Status.ManifestURL).To(Equal("https://rancher/manifest"))
+		},
+		Entry("no name", testutil.ClusterStateNoName, "", false, false),
+		Entry("name set", testutil.ClusterStateNameSet, "c-m-cluster", false, false),
+		Entry("agent deployed", testutil.ClusterStateAgentDeployed, "c-m-cluster", true, false),
+		Entry("ready", testutil.ClusterStateReady, "c-m-cluster", true, true),
+	)
+})
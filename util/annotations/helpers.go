@@ -23,6 +23,70 @@ import (
 const (
 	// ClusterImportedAnnotation represents cluster imported annotation.
 	ClusterImportedAnnotation = "imported"
+
+	// CAPIClusterNameAnnotation stores the name of the CAPI cluster on the Rancher cluster created for it.
+	CAPIClusterNameAnnotation = "cluster-api.cattle.io/capi-cluster-name"
+
+	// RancherClusterAnnotation references the Rancher cluster linked to the CAPI cluster, as namespace/name.
+	RancherClusterAnnotation = "cluster-api.cattle.io/rancher-cluster"
+
+	// ManagedAccessLabelsAnnotation records on the Rancher cluster the comma separated keys of the access labels set by
+	// turtles, so that they are removed once their source is gone.
+	ManagedAccessLabelsAnnotation = "cluster-api.cattle.io/managed-access-labels"
+
+	// ImportSourceAnnotation records what marked the CAPI cluster for import.
+	ImportSourceAnnotation = "cluster-api.cattle.io/import-source"
+
+	// ManifestSizeAnnotation records the size in bytes of the last applied registration manifest.
+	ManifestSizeAnnotation = "cluster-api.cattle.io/import-manifest-size"
+
+	// ManifestObjectsAnnotation records the number of objects of the last applied registration manifest.
+	ManifestObjectsAnnotation = "cluster-api.cattle.io/import-manifest-objects"
+
+	// ManifestHashAnnotation records the sha256 hash of the last applied registration manifest.
+	ManifestHashAnnotation = "cluster-api.cattle.io/import-manifest-hash"
+
+	// ImportAttemptsAnnotation records the number of consecutive failed import attempts of the CAPI cluster.
+	ImportAttemptsAnnotation = "cluster-api.cattle.io/import-attempts"
+
+	// ImportedByVersionAnnotation records the turtles version which last imported the cluster.
+	ImportedByVersionAnnotation = "cluster-api.cattle.io/imported-by-version"
+
+	// DeletionProtectionAnnotation marks the Rancher cluster as protected against deletion. Setting it to "false" lifts
+	// the protection.
+	DeletionProtectionAnnotation = "cluster-api.cattle.io/deletion-protection"
+
+	// ManifestURLHostAnnotation overrides the host the registration manifest is downloaded from, e.g. a mirror
+	// reachable from air-gapped clusters. Only the hosts allowed by the operator are accepted.
+	ManifestURLHostAnnotation = "cluster-api.cattle.io/manifest-url-host"
+
+	// ManifestChecksumAnnotation is the expected checksum of the registration manifest of the CAPI cluster, as
+	// sha256:<hex>. The manifest is not applied when it doesn't match.
+	ManifestChecksumAnnotation = "cluster-api.cattle.io/manifest-checksum"
+
+	// ImportSuspendedAnnotation suspends the management of the CAPI cluster by Rancher: the cattle-cluster-agent is
+	// scaled down on the downstream cluster while it is present, and scaled back up once it is removed.
+	ImportSuspendedAnnotation = "cluster-api.cattle.io/import-suspended"
+
+	// SuspendedReplicasAnnotation records on the cattle-cluster-agent deployment its replicas before it was scaled down
+	// by the import suspension.
+	SuspendedReplicasAnnotation = "cluster-api.cattle.io/suspended-replicas"
+
+	// AllowRancherTakeoverAnnotation allows applying the import manifest to a downstream cluster whose
+	// cattle-cluster-agent is registered with another Rancher server.
+	AllowRancherTakeoverAnnotation = "cluster-api.cattle.io/allow-rancher-takeover"
+
+	// TokenRefreshesAnnotation records the number of times the registration token of the CAPI cluster was refreshed
+	// since it last registered with Rancher.
+	TokenRefreshesAnnotation = "cluster-api.cattle.io/registration-token-refreshes"
+
+	// ImportSkippedReasonAnnotation records the reasons the CAPI cluster is not eligible for import, as listed by the
+	// ImportEligible condition. It is removed once the cluster is eligible.
+	ImportSkippedReasonAnnotation = "cluster-api.cattle.io/import-skipped-reason"
+
+	// RancherClusterNamespaceAnnotation overrides the namespace the Rancher cluster and its registration token of the
+	// CAPI cluster are created in.
+	RancherClusterNamespaceAnnotation = "cluster-api.cattle.io/rancher-cluster-namespace"
 )
 
 // HasClusterImportAnnotation returns true if the object has the `imported` annotation.
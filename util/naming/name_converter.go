@@ -17,21 +17,89 @@ limitations under the License.
 package naming
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"regexp"
 	"strings"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// DefaultSuffix is the suffix added to CAPI cluster names to name their Rancher cluster.
+	DefaultSuffix = "-capi"
+
+	// MaxNameLength is the maximum length of Rancher cluster names, the length of a DNS label.
+	MaxNameLength = 63
+
+	// truncatedHashLength is the length of the hash replacing the end of the names too long to be suffixed.
+	truncatedHashLength = 8
+
+	// maxSuffixLength leaves room for at least one character of the name before the hash and the suffix.
+	maxSuffixLength = MaxNameLength - truncatedHashLength - 2
 )
 
-var rancherCAPISuffix = "-capi"
+var (
+	rancherCAPISuffix = DefaultSuffix
+
+	suffixPattern = regexp.MustCompile(`^[a-z0-9-]*$`)
+)
+
+// SetSuffix sets the suffix added to CAPI cluster names to name their Rancher cluster. It is meant to be called once
+// at startup, before any name is converted. The suffix can only contain lowercase alphanumeric characters and '-', so
+// that it keeps Rancher cluster names valid.
+func SetSuffix(suffix string) error {
+	if !suffixPattern.MatchString(suffix) {
+		return fmt.Errorf("invalid Rancher cluster name suffix %q: only lowercase alphanumeric characters and '-' are allowed", suffix)
+	}
+
+	if len(suffix) > maxSuffixLength {
+		return fmt.Errorf("invalid Rancher cluster name suffix %q: must be no more than %d characters", suffix, maxSuffixLength)
+	}
+
+	rancherCAPISuffix = suffix
+
+	return nil
+}
+
+// Suffix returns the suffix added to CAPI cluster names to name their Rancher cluster.
+func Suffix() string {
+	return rancherCAPISuffix
+}
 
 // Name is a wrapper around CAPI/Rancher cluster names to simplify convertation between the two.
 type Name string
 
-// ToRancherName converts a CAPI cluster name to Rancher cluster name.
+// ToRancherName converts a CAPI cluster name to Rancher cluster name. When the suffixed name would exceed
+// MaxNameLength, the CAPI cluster name is truncated and a hash of the full name is inserted before the suffix, so that
+// the name stays valid and the same CAPI cluster always gets the same Rancher cluster name.
 func (n Name) ToRancherName() string {
-	return fmt.Sprintf("%s%s", n.ToCapiName(), rancherCAPISuffix)
+	name := n.ToCapiName()
+	if len(name)+len(rancherCAPISuffix) <= MaxNameLength {
+		return fmt.Sprintf("%s%s", name, rancherCAPISuffix)
+	}
+
+	hash := sha256.Sum256([]byte(name))
+	base := name[:MaxNameLength-len(rancherCAPISuffix)-truncatedHashLength-1]
+
+	return fmt.Sprintf("%s-%s%s", base, hex.EncodeToString(hash[:])[:truncatedHashLength], rancherCAPISuffix)
 }
 
-// ToCapiName converts a Rancher cluster name to CAPI cluster name.
+// ToCapiName converts a Rancher cluster name to CAPI cluster name. Names truncated by ToRancherName can't be
+// converted back, the CAPI cluster name must be stored alongside them instead.
 func (n Name) ToCapiName() string {
 	return strings.TrimSuffix(string(n), rancherCAPISuffix)
 }
+
+// RancherClusterKey returns the key of the Rancher cluster of the CAPI cluster with the given key. The Rancher cluster
+// is in the namespace of the CAPI cluster.
+func RancherClusterKey(capi client.ObjectKey) client.ObjectKey {
+	return client.ObjectKey{Namespace: capi.Namespace, Name: Name(capi.Name).ToRancherName()}
+}
+
+// CapiClusterKey returns the key of the CAPI cluster of the Rancher cluster with the given key. The CAPI cluster is in
+// the namespace of the Rancher cluster.
+func CapiClusterKey(rancher client.ObjectKey) client.ObjectKey {
+	return client.ObjectKey{Namespace: rancher.Namespace, Name: Name(rancher.Name).ToCapiName()}
+}
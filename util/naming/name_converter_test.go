@@ -17,10 +17,12 @@ limitations under the License.
 package naming
 
 import (
+	"strings"
 	"testing"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 var _ = Describe("Cluster name mapping", func() {
@@ -46,6 +48,123 @@ var _ = Describe("Cluster name mapping", func() {
 		name := Name("some-cluster").ToCapiName()
 		Expect(string(name)).To(Equal("some-cluster"))
 	})
+
+	Context("with a custom suffix", func() {
+		BeforeEach(func() {
+			Expect(SetSuffix("-rancher")).To(Succeed())
+			DeferCleanup(func() {
+				Expect(SetSuffix(DefaultSuffix)).To(Succeed())
+			})
+		})
+
+		It("should round-trip names with the custom suffix", func() {
+			name := Name("some-cluster").ToRancherName()
+			Expect(name).To(Equal("some-cluster-rancher"))
+			Expect(Name(name).ToRancherName()).To(Equal("some-cluster-rancher"))
+			Expect(Name(name).ToCapiName()).To(Equal("some-cluster"))
+			Expect(Suffix()).To(Equal("-rancher"))
+		})
+
+		It("should keep the default suffix on names", func() {
+			name := Name("some-cluster-capi").ToRancherName()
+			Expect(name).To(Equal("some-cluster-capi-rancher"))
+			Expect(Name(name).ToCapiName()).To(Equal("some-cluster-capi"))
+		})
+
+		It("should keep the suffix in the middle of names", func() {
+			name := Name("some-rancher-cluster").ToRancherName()
+			Expect(name).To(Equal("some-rancher-cluster-rancher"))
+			Expect(Name(name).ToCapiName()).To(Equal("some-rancher-cluster"))
+			Expect(Name("some-rancher-cluster").ToCapiName()).To(Equal("some-rancher-cluster"))
+		})
+	})
+
+	It("should keep the suffix in the middle of names", func() {
+		name := Name("my-capi-cluster").ToRancherName()
+		Expect(name).To(Equal("my-capi-cluster-capi"))
+		Expect(Name(name).ToCapiName()).To(Equal("my-capi-cluster"))
+	})
+
+	It("should convert the CAPI cluster key to the Rancher cluster key", func() {
+		key := RancherClusterKey(client.ObjectKey{Namespace: "some-ns", Name: "some-cluster"})
+		Expect(key).To(Equal(client.ObjectKey{Namespace: "some-ns", Name: "some-cluster-capi"}))
+		Expect(RancherClusterKey(key)).To(Equal(key))
+	})
+
+	It("should convert the Rancher cluster key to the CAPI cluster key", func() {
+		key := CapiClusterKey(client.ObjectKey{Namespace: "some-ns", Name: "some-cluster-capi"})
+		Expect(key).To(Equal(client.ObjectKey{Namespace: "some-ns", Name: "some-cluster"}))
+		Expect(CapiClusterKey(key)).To(Equal(key))
+	})
+
+	It("should round-trip cluster keys", func() {
+		key := client.ObjectKey{Namespace: "other-ns", Name: "my-capi-cluster"}
+		Expect(CapiClusterKey(RancherClusterKey(key))).To(Equal(key))
+		Expect(RancherClusterKey(client.ObjectKey{Name: "some-cluster"})).To(Equal(client.ObjectKey{Name: "some-cluster-capi"}))
+	})
+
+	Context("with names too long to be suffixed", func() {
+		It("should keep names of exactly 63 characters with the suffix", func() {
+			capiName := strings.Repeat("a", MaxNameLength-len(DefaultSuffix))
+
+			name := Name(capiName).ToRancherName()
+			Expect(name).To(Equal(capiName + DefaultSuffix))
+			Expect(name).To(HaveLen(MaxNameLength))
+			Expect(Name(name).ToCapiName()).To(Equal(capiName))
+		})
+
+		It("should truncate names of 64 characters with the suffix", func() {
+			capiName := strings.Repeat("a", MaxNameLength-len(DefaultSuffix)+1)
+
+			name := Name(capiName).ToRancherName()
+			Expect(name).To(HaveLen(MaxNameLength))
+			Expect(name).To(MatchRegexp(`^a{49}-[0-9a-f]{8}-capi$`))
+			Expect(Name(name).ToRancherName()).To(Equal(name))
+			Expect(Name(name).ToCapiName()).ToNot(Equal(capiName))
+		})
+
+		It("should truncate names deterministically", func() {
+			capiName := strings.Repeat("a", 100)
+			Expect(Name(capiName).ToRancherName()).To(Equal(Name(capiName).ToRancherName()))
+			Expect(Name(capiName).ToRancherName()).To(HaveLen(MaxNameLength))
+		})
+
+		It("should keep truncated names of different clusters apart", func() {
+			prefix := strings.Repeat("a", MaxNameLength)
+			Expect(Name(prefix + "-one").ToRancherName()).ToNot(Equal(Name(prefix + "-two").ToRancherName()))
+		})
+
+		It("should truncate names with a custom suffix", func() {
+			Expect(SetSuffix("-rancher")).To(Succeed())
+			DeferCleanup(func() {
+				Expect(SetSuffix(DefaultSuffix)).To(Succeed())
+			})
+
+			name := Name(strings.Repeat("a", 60)).ToRancherName()
+			Expect(name).To(HaveLen(MaxNameLength))
+			Expect(name).To(HaveSuffix("-rancher"))
+		})
+	})
+
+	It("should reject suffixes too long to keep names valid", func() {
+		Expect(SetSuffix("-" + strings.Repeat("a", MaxNameLength))).To(MatchError(ContainSubstring("must be no more than")))
+		Expect(Suffix()).To(Equal(DefaultSuffix))
+	})
+
+	It("should reject suffixes making invalid names", func() {
+		Expect(SetSuffix("_CAPI")).To(MatchError(ContainSubstring("invalid Rancher cluster name suffix")))
+		Expect(Suffix()).To(Equal(DefaultSuffix))
+	})
+
+	It("should allow an empty suffix", func() {
+		Expect(SetSuffix("")).To(Succeed())
+		DeferCleanup(func() {
+			Expect(SetSuffix(DefaultSuffix)).To(Succeed())
+		})
+
+		Expect(Name("some-cluster").ToRancherName()).To(Equal("some-cluster"))
+		Expect(Name("some-cluster").ToCapiName()).To(Equal("some-cluster"))
+	})
 })
 
 func TestNameConverter(t *testing.T) {
@@ -0,0 +1,59 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package naming
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// NamePolicy validates Rancher cluster names against an organization naming policy, e.g. a required business unit
+// prefix, before the Rancher cluster is created.
+type NamePolicy interface {
+	// Validate returns an error describing the violation when the name doesn't comply with the policy.
+	Validate(name string) error
+}
+
+// RegexPolicy is a NamePolicy only accepting the names entirely matching a regular expression.
+type RegexPolicy struct {
+	expr string
+	re   *regexp.Regexp
+}
+
+// NewRegexPolicy returns a naming policy from a regular expression, which must match the whole name.
+func NewRegexPolicy(expr string) (*RegexPolicy, error) {
+	re, err := regexp.Compile("^(?:" + expr + ")$")
+	if err != nil {
+		return nil, fmt.Errorf("parsing name policy: %w", err)
+	}
+
+	return &RegexPolicy{expr: expr, re: re}, nil
+}
+
+// Validate returns an error when the name doesn't match the policy expression.
+func (p *RegexPolicy) Validate(name string) error {
+	if !p.re.MatchString(name) {
+		return fmt.Errorf("name %q does not match the naming policy %q", name, p.expr)
+	}
+
+	return nil
+}
+
+// String returns the policy expression.
+func (p *RegexPolicy) String() string {
+	return p.expr
+}
@@ -0,0 +1,52 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package naming
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Cluster name policy", func() {
+	It("should accept names matching the expression", func() {
+		policy, err := NewRegexPolicy(`(bu1|bu2)-[a-z0-9-]+`)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(policy.Validate("bu1-prod-capi")).To(Succeed())
+	})
+
+	It("should reject names not matching the expression", func() {
+		policy, err := NewRegexPolicy(`(bu1|bu2)-[a-z0-9-]+`)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(policy.Validate("prod-capi")).To(MatchError(ContainSubstring(`name "prod-capi" does not match`)))
+	})
+
+	It("should match the whole name", func() {
+		policy, err := NewRegexPolicy(`bu1-[a-z]+|prod`)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(policy.Validate("bu1-prod-1")).ToNot(Succeed())
+		Expect(policy.Validate("my-prod-capi")).ToNot(Succeed())
+		Expect(policy.Validate("prod")).To(Succeed())
+	})
+
+	It("should fail on invalid expressions", func() {
+		_, err := NewRegexPolicy(`bu1-(`)
+		Expect(err).To(MatchError(ContainSubstring("parsing name policy")))
+	})
+})
@@ -0,0 +1,77 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package naming
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// Template renders Rancher cluster names from a Go template evaluated against the CAPI cluster metadata.
+// The template can reference .Name, .Namespace, .Labels and .Annotations, e.g. `{{ index .Annotations "team" }}-{{ .Name }}`.
+type Template struct {
+	text string
+	tmpl *template.Template
+}
+
+// templateData is the data the name template is evaluated against.
+type templateData struct {
+	Name        string
+	Namespace   string
+	Labels      map[string]string
+	Annotations map[string]string
+}
+
+// NewTemplate parses a name template.
+func NewTemplate(text string) (*Template, error) {
+	tmpl, err := template.New("name").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parsing name template: %w", err)
+	}
+
+	return &Template{text: text, tmpl: tmpl}, nil
+}
+
+// String returns the text the template was parsed from.
+func (t *Template) String() string {
+	return t.text
+}
+
+// Render renders the Rancher cluster name for the object and validates it is a DNS-1123 label.
+func (t *Template) Render(obj metav1.Object) (string, error) {
+	buf := &bytes.Buffer{}
+	if err := t.tmpl.Execute(buf, templateData{
+		Name:        obj.GetName(),
+		Namespace:   obj.GetNamespace(),
+		Labels:      obj.GetLabels(),
+		Annotations: obj.GetAnnotations(),
+	}); err != nil {
+		return "", fmt.Errorf("rendering name template: %w", err)
+	}
+
+	name := strings.TrimSpace(buf.String())
+	if errs := validation.IsDNS1123Label(name); len(errs) > 0 {
+		return "", fmt.Errorf("rendered name %q is not a valid DNS-1123 label: %s", name, strings.Join(errs, ", "))
+	}
+
+	return name, nil
+}
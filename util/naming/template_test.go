@@ -0,0 +1,65 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package naming
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("Cluster name template", func() {
+	var obj *metav1.ObjectMeta
+
+	BeforeEach(func() {
+		obj = &metav1.ObjectMeta{
+			Name:        "some-cluster",
+			Namespace:   "team-ns",
+			Annotations: map[string]string{"example.com/team": "platform"},
+		}
+	})
+
+	It("should render the name from the cluster metadata", func() {
+		tmpl, err := NewTemplate(`{{ index .Annotations "example.com/team" }}-{{ .Namespace }}-{{ .Name }}`)
+		Expect(err).ToNot(HaveOccurred())
+
+		name, err := tmpl.Render(obj)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(name).To(Equal("platform-team-ns-some-cluster"))
+	})
+
+	It("should reject a template that fails to parse", func() {
+		_, err := NewTemplate(`{{ .Name `)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should reject a rendered name that is not DNS compliant", func() {
+		tmpl, err := NewTemplate(`{{ index .Annotations "example.com/team" }}_{{ .Name }}`)
+		Expect(err).ToNot(HaveOccurred())
+
+		_, err = tmpl.Render(obj)
+		Expect(err).To(MatchError(ContainSubstring("not a valid DNS-1123 label")))
+	})
+
+	It("should reject an empty rendered name", func() {
+		tmpl, err := NewTemplate(`{{ index .Annotations "missing" }}`)
+		Expect(err).ToNot(HaveOccurred())
+
+		_, err = tmpl.Render(obj)
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -18,15 +18,17 @@ package predicates
 
 import (
 	"context"
+	"slices"
 	"strings"
 
 	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/labels"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
 
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
-	"sigs.k8s.io/cluster-api/util/conditions"
 
 	"github.com/rancher/turtles/util"
 	"github.com/rancher/turtles/util/annotations"
@@ -67,26 +69,30 @@ func processIfClusterNotImported(logger logr.Logger, obj client.Object) bool {
 }
 
 // ClusterWithReadyControlPlane returns a predicate that returns true only if the provided resource is a cluster with a
-// ready control plane.
-func ClusterWithReadyControlPlane(logger logr.Logger) predicate.Funcs {
+// ready control plane, as reported by the readiness signals.
+func ClusterWithReadyControlPlane(logger logr.Logger, readiness util.ControlPlaneReadiness) predicate.Funcs {
 	return predicate.Funcs{
 		UpdateFunc: func(e event.UpdateEvent) bool {
-			return processIfClusterReadyControlPlane(logger.WithValues("predicate", "ClusterWithReadyControlPlane", "eventType", "update"), e.ObjectNew)
+			return processIfClusterReadyControlPlane(
+				logger.WithValues("predicate", "ClusterWithReadyControlPlane", "eventType", "update"), e.ObjectNew, readiness)
 		},
 		CreateFunc: func(e event.CreateEvent) bool {
-			return processIfClusterReadyControlPlane(logger.WithValues("predicate", "ClusterWithReadyControlPlane", "eventType", "create"), e.Object)
+			return processIfClusterReadyControlPlane(
+				logger.WithValues("predicate", "ClusterWithReadyControlPlane", "eventType", "create"), e.Object, readiness)
 		},
 		DeleteFunc: func(e event.DeleteEvent) bool {
-			return processIfClusterReadyControlPlane(logger.WithValues("predicate", "ClusterWithReadyControlPlane", "eventType", "delete"), e.Object)
+			return processIfClusterReadyControlPlane(
+				logger.WithValues("predicate", "ClusterWithReadyControlPlane", "eventType", "delete"), e.Object, readiness)
 		},
 		GenericFunc: func(e event.GenericEvent) bool {
-			return processIfClusterReadyControlPlane(logger.WithValues("predicate", "ClusterWithReadyControlPlane", "eventType", "generic"), e.Object)
+			return processIfClusterReadyControlPlane(
+				logger.WithValues("predicate", "ClusterWithReadyControlPlane", "eventType", "generic"), e.Object, readiness)
 		},
 	}
 }
 
 // processIfClusterReadyControlPlane returns true if the provided object is a cluster and has a ready control plane.
-func processIfClusterReadyControlPlane(logger logr.Logger, obj client.Object) bool {
+func processIfClusterReadyControlPlane(logger logr.Logger, obj client.Object, readiness util.ControlPlaneReadiness) bool {
 	kind := strings.ToLower(obj.GetObjectKind().GroupVersionKind().Kind)
 	log := logger.WithValues("namespace", obj.GetNamespace(), kind, obj.GetName())
 
@@ -96,13 +102,8 @@ func processIfClusterReadyControlPlane(logger logr.Logger, obj client.Object) bo
 		return false
 	}
 
-	if cluster.Status.ControlPlaneReady {
-		log.V(6).Info("Cluster has a ready control plane, will attempt to map resource")
-		return true
-	}
-
-	if conditions.IsTrue(cluster, clusterv1.ControlPlaneReadyCondition) {
-		log.V(6).Info("Cluster has a ready control plane condition, will attempt to map resource")
+	if ready, signal := readiness.Ready(cluster); ready {
+		log.V(6).Info("Cluster has a ready control plane, will attempt to map resource", "signal", signal)
 		return true
 	}
 
@@ -154,3 +155,135 @@ func processIfClusterOrNamespaceWithImportLabel(ctx context.Context, logger logr
 
 	return shouldImport
 }
+
+// ClusterMatchingSelector returns a predicate that returns true only if the provided resource is a cluster whose labels
+// match the selector. A nil selector matches every cluster.
+func ClusterMatchingSelector(logger logr.Logger, selector labels.Selector) predicate.Funcs {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			return processIfClusterMatchingSelector(
+				logger.WithValues("predicate", "ClusterMatchingSelector", "eventType", "update"), e.ObjectNew, selector)
+		},
+		CreateFunc: func(e event.CreateEvent) bool {
+			return processIfClusterMatchingSelector(
+				logger.WithValues("predicate", "ClusterMatchingSelector", "eventType", "create"), e.Object, selector)
+		},
+		DeleteFunc: func(e event.DeleteEvent) bool {
+			return processIfClusterMatchingSelector(
+				logger.WithValues("predicate", "ClusterMatchingSelector", "eventType", "delete"), e.Object, selector)
+		},
+		GenericFunc: func(e event.GenericEvent) bool {
+			return processIfClusterMatchingSelector(
+				logger.WithValues("predicate", "ClusterMatchingSelector", "eventType", "generic"), e.Object, selector)
+		},
+	}
+}
+
+// processIfClusterMatchingSelector returns true if the provided object is a cluster and its labels match the selector.
+func processIfClusterMatchingSelector(logger logr.Logger, obj client.Object, selector labels.Selector) bool {
+	kind := strings.ToLower(obj.GetObjectKind().GroupVersionKind().Kind)
+	log := logger.WithValues("namespace", obj.GetNamespace(), kind, obj.GetName())
+
+	if _, ok := obj.(*clusterv1.Cluster); !ok {
+		log.V(4).Info("Expected a Cluster but got a different object, will not attempt to map resource", "object", obj)
+		return false
+	}
+
+	if selector == nil || selector.Matches(labels.Set(obj.GetLabels())) {
+		log.V(6).Info("Cluster matches the cluster selector, will attempt to map resource")
+		return true
+	}
+
+	log.V(4).Info("Cluster does not match the cluster selector, will not attempt to map resource", "selector", selector.String())
+
+	return false
+}
+
+// ClusterInNamespaces returns a predicate that returns true only if the provided resource is a cluster in one of the
+// namespaces. An empty list of namespaces matches every cluster.
+func ClusterInNamespaces(logger logr.Logger, namespaces []string) predicate.Funcs {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			return processIfClusterInNamespaces(
+				logger.WithValues("predicate", "ClusterInNamespaces", "eventType", "update"), e.ObjectNew, namespaces)
+		},
+		CreateFunc: func(e event.CreateEvent) bool {
+			return processIfClusterInNamespaces(
+				logger.WithValues("predicate", "ClusterInNamespaces", "eventType", "create"), e.Object, namespaces)
+		},
+		DeleteFunc: func(e event.DeleteEvent) bool {
+			return processIfClusterInNamespaces(
+				logger.WithValues("predicate", "ClusterInNamespaces", "eventType", "delete"), e.Object, namespaces)
+		},
+		GenericFunc: func(e event.GenericEvent) bool {
+			return processIfClusterInNamespaces(
+				logger.WithValues("predicate", "ClusterInNamespaces", "eventType", "generic"), e.Object, namespaces)
+		},
+	}
+}
+
+// processIfClusterInNamespaces returns true if the provided object is a cluster in one of the namespaces.
+func processIfClusterInNamespaces(logger logr.Logger, obj client.Object, namespaces []string) bool {
+	kind := strings.ToLower(obj.GetObjectKind().GroupVersionKind().Kind)
+	log := logger.WithValues("namespace", obj.GetNamespace(), kind, obj.GetName())
+
+	if _, ok := obj.(*clusterv1.Cluster); !ok {
+		log.V(4).Info("Expected a Cluster but got a different object, will not attempt to map resource", "object", obj)
+		return false
+	}
+
+	if len(namespaces) == 0 || slices.Contains(namespaces, obj.GetNamespace()) {
+		log.V(6).Info("Cluster is in a watched namespace, will attempt to map resource")
+		return true
+	}
+
+	log.V(4).Info("Cluster is not in a watched namespace, will not attempt to map resource", "namespaces", namespaces)
+
+	return false
+}
+
+// AllowClusterDeletingWithFinalizer returns a predicate that behaves as the provided one, except that a cluster being
+// deleted which still holds the finalizer always passes. This lets the cleanup behind the finalizer run even when the
+// cluster no longer matches the provided predicate, e.g. when its import label was removed before it was deleted.
+func AllowClusterDeletingWithFinalizer(logger logr.Logger, finalizer string, p predicate.Funcs) predicate.Funcs {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			return processIfClusterDeletingWithFinalizer(
+				logger.WithValues("predicate", "AllowClusterDeletingWithFinalizer", "eventType", "update"), e.ObjectNew, finalizer) ||
+				p.Update(e)
+		},
+		CreateFunc: func(e event.CreateEvent) bool {
+			return processIfClusterDeletingWithFinalizer(
+				logger.WithValues("predicate", "AllowClusterDeletingWithFinalizer", "eventType", "create"), e.Object, finalizer) ||
+				p.Create(e)
+		},
+		DeleteFunc: func(e event.DeleteEvent) bool {
+			return processIfClusterDeletingWithFinalizer(
+				logger.WithValues("predicate", "AllowClusterDeletingWithFinalizer", "eventType", "delete"), e.Object, finalizer) ||
+				p.Delete(e)
+		},
+		GenericFunc: func(e event.GenericEvent) bool {
+			return processIfClusterDeletingWithFinalizer(
+				logger.WithValues("predicate", "AllowClusterDeletingWithFinalizer", "eventType", "generic"), e.Object, finalizer) ||
+				p.Generic(e)
+		},
+	}
+}
+
+// processIfClusterDeletingWithFinalizer returns true if the provided object is a cluster being deleted which still holds
+// the finalizer.
+func processIfClusterDeletingWithFinalizer(logger logr.Logger, obj client.Object, finalizer string) bool {
+	kind := strings.ToLower(obj.GetObjectKind().GroupVersionKind().Kind)
+	log := logger.WithValues("namespace", obj.GetNamespace(), kind, obj.GetName())
+
+	if _, ok := obj.(*clusterv1.Cluster); !ok {
+		return false
+	}
+
+	if !obj.GetDeletionTimestamp().IsZero() && controllerutil.ContainsFinalizer(obj, finalizer) {
+		log.V(6).Info("Cluster is being deleted and holds the finalizer, will attempt to map resource", "finalizer", finalizer)
+		return true
+	}
+
+	return false
+}
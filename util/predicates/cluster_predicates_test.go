@@ -17,14 +17,20 @@ limitations under the License.
 package predicates
 
 import (
+	"time"
+
 	"github.com/go-logr/logr"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"github.com/rancher/turtles/util"
 	"github.com/rancher/turtles/util/annotations"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
 	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 )
 
 var _ = Describe("ClusterWithoutImportedAnnotation", func() {
@@ -91,13 +97,43 @@ var _ = Describe("ClusterWithReadyControlPlane", func() {
 
 	It("should return true when cluster has ready control plane", func() {
 		capiCluster.Status.ControlPlaneReady = true
-		result := ClusterWithReadyControlPlane(logger).UpdateFunc(event.UpdateEvent{ObjectNew: capiCluster})
+		result := ClusterWithReadyControlPlane(logger, util.ControlPlaneReadiness{}).UpdateFunc(event.UpdateEvent{ObjectNew: capiCluster})
 		Expect(result).To(BeTrue())
 	})
 
 	It("should return false when cluster does not have ready control plane", func() {
-		result := ClusterWithReadyControlPlane(logger).UpdateFunc(event.UpdateEvent{ObjectNew: capiCluster})
+		result := ClusterWithReadyControlPlane(logger, util.ControlPlaneReadiness{}).UpdateFunc(event.UpdateEvent{ObjectNew: capiCluster})
+		Expect(result).To(BeFalse())
+	})
+
+	Context("when the control plane readiness is reported by a provider-specific condition", func() {
+		const providerReady clusterv1.ConditionType = "EKSControlPlaneReady"
+
+		BeforeEach(func() {
+			conditions.MarkTrue(capiCluster, providerReady)
+		})
+
+		It("should return false when the condition is not configured", func() {
+			result := ClusterWithReadyControlPlane(logger, util.ControlPlaneReadiness{}).UpdateFunc(event.UpdateEvent{ObjectNew: capiCluster})
+			Expect(result).To(BeFalse())
+		})
+
+		It("should return true when the condition is configured", func() {
+			readiness := util.ControlPlaneReadiness{Conditions: []clusterv1.ConditionType{"GKEControlPlaneReady", providerReady}}
+			result := ClusterWithReadyControlPlane(logger, readiness).UpdateFunc(event.UpdateEvent{ObjectNew: capiCluster})
+			Expect(result).To(BeTrue())
+		})
+	})
+
+	It("should return true when cluster is provisioned and the phase is accepted", func() {
+		capiCluster.Status.SetTypedPhase(clusterv1.ClusterPhaseProvisioned)
+
+		result := ClusterWithReadyControlPlane(logger, util.ControlPlaneReadiness{}).UpdateFunc(event.UpdateEvent{ObjectNew: capiCluster})
 		Expect(result).To(BeFalse())
+
+		readiness := util.ControlPlaneReadiness{ProvisionedPhase: true}
+		result = ClusterWithReadyControlPlane(logger, readiness).UpdateFunc(event.UpdateEvent{ObjectNew: capiCluster})
+		Expect(result).To(BeTrue())
 	})
 })
 
@@ -162,3 +198,143 @@ var _ = Describe("ClusterOrNamespaceWithImportLabel", func() {
 		Expect(result).To(BeFalse())
 	})
 })
+
+var _ = Describe("ClusterMatchingSelector", func() {
+	var (
+		logger      logr.Logger
+		capiCluster *clusterv1.Cluster
+		selector    labels.Selector
+	)
+
+	BeforeEach(func() {
+		logger = logr.Discard()
+
+		capiCluster = &clusterv1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-cluster",
+				Namespace: "test-ns",
+				Labels: map[string]string{
+					importLabel: "true",
+				},
+			},
+		}
+
+		var err error
+		selector, err = metav1.LabelSelectorAsSelector(&metav1.LabelSelector{
+			MatchLabels: map[string]string{"env": "dev"},
+		})
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("should return true when the cluster matches the selector", func() {
+		capiCluster.Labels["env"] = "dev"
+		result := ClusterMatchingSelector(logger, selector).UpdateFunc(event.UpdateEvent{ObjectNew: capiCluster})
+		Expect(result).To(BeTrue())
+	})
+
+	It("should return false when the cluster has the import label but doesn't match the selector", func() {
+		capiCluster.Labels["env"] = "prod"
+		result := ClusterMatchingSelector(logger, selector).CreateFunc(event.CreateEvent{Object: capiCluster})
+		Expect(result).To(BeFalse())
+	})
+
+	It("should return true for every cluster when the selector is nil", func() {
+		result := ClusterMatchingSelector(logger, nil).UpdateFunc(event.UpdateEvent{ObjectNew: capiCluster})
+		Expect(result).To(BeTrue())
+	})
+
+	It("should return false when the object is not a cluster", func() {
+		result := ClusterMatchingSelector(logger, nil).UpdateFunc(event.UpdateEvent{ObjectNew: &corev1.Namespace{}})
+		Expect(result).To(BeFalse())
+	})
+})
+
+var _ = Describe("ClusterInNamespaces", func() {
+	var (
+		logger      logr.Logger
+		capiCluster *clusterv1.Cluster
+	)
+
+	BeforeEach(func() {
+		logger = logr.Discard()
+
+		capiCluster = &clusterv1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-cluster",
+				Namespace: "test-ns",
+				Labels: map[string]string{
+					importLabel: "true",
+				},
+			},
+		}
+	})
+
+	It("should return true when the cluster is in a watched namespace", func() {
+		result := ClusterInNamespaces(logger, []string{"other-ns", "test-ns"}).UpdateFunc(event.UpdateEvent{ObjectNew: capiCluster})
+		Expect(result).To(BeTrue())
+	})
+
+	It("should return false when the cluster has the import label but is not in a watched namespace", func() {
+		result := ClusterInNamespaces(logger, []string{"other-ns"}).CreateFunc(event.CreateEvent{Object: capiCluster})
+		Expect(result).To(BeFalse())
+	})
+
+	It("should return true for every cluster when no namespace is set", func() {
+		result := ClusterInNamespaces(logger, nil).GenericFunc(event.GenericEvent{Object: capiCluster})
+		Expect(result).To(BeTrue())
+	})
+
+	It("should return false when the object is not a cluster", func() {
+		result := ClusterInNamespaces(logger, nil).UpdateFunc(event.UpdateEvent{ObjectNew: &corev1.Namespace{}})
+		Expect(result).To(BeFalse())
+	})
+})
+
+var _ = Describe("AllowClusterDeletingWithFinalizer", func() {
+	const finalizer = "test.cattle.io"
+
+	var (
+		logger      logr.Logger
+		capiCluster *clusterv1.Cluster
+		inWatchedNs predicate.Funcs
+	)
+
+	BeforeEach(func() {
+		logger = logr.Discard()
+
+		capiCluster = &clusterv1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-cluster",
+				Namespace: "test-ns",
+			},
+		}
+		inWatchedNs = ClusterInNamespaces(logger, []string{"other-ns"})
+	})
+
+	It("should return false when the wrapped predicate does and the cluster is not being deleted", func() {
+		capiCluster.Finalizers = []string{finalizer}
+		result := AllowClusterDeletingWithFinalizer(logger, finalizer, inWatchedNs).UpdateFunc(event.UpdateEvent{ObjectNew: capiCluster})
+		Expect(result).To(BeFalse())
+	})
+
+	It("should return true when the cluster is being deleted and holds the finalizer", func() {
+		capiCluster.Finalizers = []string{finalizer}
+		capiCluster.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+		p := AllowClusterDeletingWithFinalizer(logger, finalizer, inWatchedNs)
+		Expect(p.UpdateFunc(event.UpdateEvent{ObjectNew: capiCluster})).To(BeTrue())
+		Expect(p.DeleteFunc(event.DeleteEvent{Object: capiCluster})).To(BeTrue())
+	})
+
+	It("should return false when the cluster is being deleted without the finalizer", func() {
+		capiCluster.Finalizers = []string{"other.cattle.io"}
+		capiCluster.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+		result := AllowClusterDeletingWithFinalizer(logger, finalizer, inWatchedNs).DeleteFunc(event.DeleteEvent{Object: capiCluster})
+		Expect(result).To(BeFalse())
+	})
+
+	It("should return true when the wrapped predicate does", func() {
+		capiCluster.Namespace = "other-ns"
+		result := AllowClusterDeletingWithFinalizer(logger, finalizer, inWatchedNs).CreateFunc(event.CreateEvent{Object: capiCluster})
+		Expect(result).To(BeTrue())
+	})
+})
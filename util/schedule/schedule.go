@@ -0,0 +1,255 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package schedule implements maintenance windows restricting when an action is allowed to happen.
+package schedule
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const day = 24 * time.Hour
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// Window is a daily time range, optionally restricted to some days of the week. A window whose end is before
+// its start spans midnight, and belongs to the day it starts on.
+type Window struct {
+	// Days the window starts on. Every day when empty.
+	Days []time.Weekday
+	// Start is the offset from midnight the window opens at.
+	Start time.Duration
+	// End is the offset from midnight the window closes at.
+	End time.Duration
+}
+
+// Schedule is a set of maintenance windows evaluated in a given location.
+type Schedule struct {
+	Windows  []Window
+	Location *time.Location
+}
+
+// Parse parses windows in the `[Mon-Fri|Sat,Sun] HH:MM-HH:MM` format, evaluated in the given IANA time zone.
+func Parse(specs []string, timezone string) (*Schedule, error) {
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, fmt.Errorf("loading time zone %q: %w", timezone, err)
+	}
+
+	schedule := &Schedule{Location: location}
+
+	for _, spec := range specs {
+		window, err := parseWindow(spec)
+		if err != nil {
+			return nil, fmt.Errorf("parsing window %q: %w", spec, err)
+		}
+
+		schedule.Windows = append(schedule.Windows, window)
+	}
+
+	return schedule, nil
+}
+
+func parseWindow(spec string) (Window, error) {
+	window := Window{}
+	fields := strings.Fields(spec)
+
+	switch len(fields) {
+	case 1:
+	case 2:
+		days, err := parseDays(fields[0])
+		if err != nil {
+			return window, err
+		}
+
+		window.Days = days
+		fields = fields[1:]
+	default:
+		return window, fmt.Errorf("expected `[days] HH:MM-HH:MM`")
+	}
+
+	start, end, ok := strings.Cut(fields[0], "-")
+	if !ok {
+		return window, fmt.Errorf("expected a HH:MM-HH:MM time range")
+	}
+
+	var err error
+
+	if window.Start, err = parseClock(start); err != nil {
+		return window, err
+	}
+
+	if window.End, err = parseClock(end); err != nil {
+		return window, err
+	}
+
+	if window.Start == window.End {
+		return window, fmt.Errorf("window start and end must differ")
+	}
+
+	return window, nil
+}
+
+func parseDays(spec string) ([]time.Weekday, error) {
+	days := []time.Weekday{}
+
+	for _, part := range strings.Split(spec, ",") {
+		first, last, isRange := strings.Cut(part, "-")
+
+		from, ok := weekdays[strings.ToLower(first)]
+		if !ok {
+			return nil, fmt.Errorf("unknown day %q", first)
+		}
+
+		to := from
+
+		if isRange {
+			if to, ok = weekdays[strings.ToLower(last)]; !ok {
+				return nil, fmt.Errorf("unknown day %q", last)
+			}
+		}
+
+		for d := from; ; d = (d + 1) % 7 {
+			days = append(days, d)
+
+			if d == to {
+				break
+			}
+		}
+	}
+
+	return days, nil
+}
+
+func parseClock(spec string) (time.Duration, error) {
+	t, err := time.Parse("15:04", spec)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", spec)
+	}
+
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// String formats the window in the format it is parsed from, e.g. `Mon,Tue 22:00-06:00`.
+func (w Window) String() string {
+	clock := fmt.Sprintf("%s-%s", formatClock(w.Start), formatClock(w.End))
+	if len(w.Days) == 0 {
+		return clock
+	}
+
+	days := make([]string, 0, len(w.Days))
+	for _, day := range w.Days {
+		days = append(days, day.String()[:3])
+	}
+
+	return strings.Join(days, ",") + " " + clock
+}
+
+func formatClock(offset time.Duration) string {
+	return fmt.Sprintf("%02d:%02d", int(offset.Hours()), int(offset.Minutes())%60)
+}
+
+func (w Window) startsOn(weekday time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+
+	for _, d := range w.Days {
+		if d == weekday {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (w Window) length() time.Duration {
+	if w.End > w.Start {
+		return w.End - w.Start
+	}
+
+	return day - w.Start + w.End
+}
+
+// midnight returns the start of the day t is in.
+func midnight(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// Contains returns true when t falls in one of the windows. An empty schedule always contains t.
+func (s *Schedule) Contains(t time.Time) bool {
+	if s == nil || len(s.Windows) == 0 {
+		return true
+	}
+
+	t = t.In(s.Location)
+	today := midnight(t)
+
+	for _, w := range s.Windows {
+		// A window may have opened today or, when it spans midnight, yesterday.
+		for _, start := range []time.Time{today, today.AddDate(0, 0, -1)} {
+			if !w.startsOn(start.Weekday()) {
+				continue
+			}
+
+			opens := start.Add(w.Start)
+			if !t.Before(opens) && t.Before(opens.Add(w.length())) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Next returns the time the next window opens after t. The zero time is returned for an empty schedule.
+func (s *Schedule) Next(t time.Time) time.Time {
+	next := time.Time{}
+
+	if s == nil || len(s.Windows) == 0 {
+		return next
+	}
+
+	t = t.In(s.Location)
+	today := midnight(t)
+
+	for d := 0; d <= 7; d++ {
+		start := today.AddDate(0, 0, d)
+
+		for _, w := range s.Windows {
+			if !w.startsOn(start.Weekday()) {
+				continue
+			}
+
+			opens := start.Add(w.Start)
+			if opens.After(t) && (next.IsZero() || opens.Before(next)) {
+				next = opens
+			}
+		}
+	}
+
+	return next
+}
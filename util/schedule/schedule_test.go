@@ -0,0 +1,105 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schedule
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Maintenance window schedule", func() {
+	// 2024-01-03 is a Wednesday.
+	at := func(day int, clock string) time.Time {
+		t, err := time.ParseInLocation("2006-01-02 15:04", fmt.Sprintf("2024-01-%02d %s", day, clock), time.UTC)
+		Expect(err).ToNot(HaveOccurred())
+
+		return t
+	}
+
+	It("should always allow with an empty schedule", func() {
+		schedule, err := Parse(nil, "UTC")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(schedule.Contains(at(3, "12:00"))).To(BeTrue())
+		Expect(schedule.Next(at(3, "12:00"))).To(BeZero())
+	})
+
+	It("should match a same-day window", func() {
+		schedule, err := Parse([]string{"09:00-17:00"}, "UTC")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(schedule.Contains(at(3, "08:59"))).To(BeFalse())
+		Expect(schedule.Contains(at(3, "09:00"))).To(BeTrue())
+		Expect(schedule.Contains(at(3, "16:59"))).To(BeTrue())
+		Expect(schedule.Contains(at(3, "17:00"))).To(BeFalse())
+		Expect(schedule.Next(at(3, "17:00"))).To(Equal(at(4, "09:00")))
+	})
+
+	It("should match a window spanning midnight", func() {
+		schedule, err := Parse([]string{"22:00-06:00"}, "UTC")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(schedule.Contains(at(3, "23:00"))).To(BeTrue())
+		Expect(schedule.Contains(at(4, "05:59"))).To(BeTrue())
+		Expect(schedule.Contains(at(4, "06:00"))).To(BeFalse())
+		Expect(schedule.Next(at(4, "12:00"))).To(Equal(at(4, "22:00")))
+	})
+
+	It("should restrict windows to the given days", func() {
+		schedule, err := Parse([]string{"Sat,Sun 00:00-23:59", "Fri 22:00-02:00"}, "UTC")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(schedule.Contains(at(3, "12:00"))).To(BeFalse())
+		Expect(schedule.Contains(at(5, "23:00"))).To(BeTrue())
+		Expect(schedule.Contains(at(6, "01:00"))).To(BeTrue())
+		Expect(schedule.Contains(at(7, "12:00"))).To(BeTrue())
+		Expect(schedule.Next(at(3, "12:00"))).To(Equal(at(5, "22:00")))
+	})
+
+	It("should evaluate windows in the configured time zone", func() {
+		schedule, err := Parse([]string{"Mon-Fri 09:00-17:00"}, "America/New_York")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(schedule.Contains(at(3, "13:00"))).To(BeFalse())
+		Expect(schedule.Contains(at(3, "15:00"))).To(BeTrue())
+	})
+
+	It("should reject invalid windows", func() {
+		for _, spec := range []string{"9-17", "Mon 09:00", "Funday 09:00-10:00", "10:00-10:00", "Mon Tue 09:00-10:00"} {
+			_, err := Parse([]string{spec}, "UTC")
+			Expect(err).To(HaveOccurred(), spec)
+		}
+
+		_, err := Parse(nil, "Not/AZone")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should format the windows in the format they are parsed from", func() {
+		schedule, err := Parse([]string{"Sat,Sun 22:00-06:30", "09:05-17:00"}, "UTC")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(schedule.Windows[0].String()).To(Equal("Sat,Sun 22:00-06:30"))
+		Expect(schedule.Windows[1].String()).To(Equal("09:05-17:00"))
+
+		reparsed, err := Parse([]string{schedule.Windows[0].String()}, "UTC")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(reparsed.Windows[0]).To(Equal(schedule.Windows[0]))
+	})
+})
+
+func TestSchedule(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Maintenance window schedule Suite")
+}
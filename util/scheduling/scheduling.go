@@ -0,0 +1,109 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package scheduling parses and validates pod scheduling constraints, like node selectors and tolerations.
+package scheduling
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// ParseToleration parses a toleration in the `key[=value][:effect]` format. A toleration with a value uses the Equal
+// operator, otherwise Exists. Omitting the effect tolerates all effects.
+func ParseToleration(spec string) (corev1.Toleration, error) {
+	toleration := corev1.Toleration{Operator: corev1.TolerationOpExists}
+
+	keyValue, effect, _ := strings.Cut(spec, ":")
+	toleration.Effect = corev1.TaintEffect(effect)
+
+	key, value, hasValue := strings.Cut(keyValue, "=")
+	toleration.Key = key
+
+	if hasValue {
+		toleration.Operator = corev1.TolerationOpEqual
+		toleration.Value = value
+	}
+
+	if err := ValidateTolerations([]corev1.Toleration{toleration}); err != nil {
+		return corev1.Toleration{}, fmt.Errorf("invalid toleration %q: %w", spec, err)
+	}
+
+	return toleration, nil
+}
+
+// ValidateNodeSelector checks the node selector keys are qualified names and the values valid label values.
+func ValidateNodeSelector(nodeSelector map[string]string) error {
+	errs := []error{}
+
+	for key, value := range nodeSelector {
+		for _, msg := range validation.IsQualifiedName(key) {
+			errs = append(errs, fmt.Errorf("node selector key %q: %s", key, msg))
+		}
+
+		for _, msg := range validation.IsValidLabelValue(value) {
+			errs = append(errs, fmt.Errorf("node selector value %q: %s", value, msg))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// ValidateTolerations checks the tolerations are well-formed, following the rules of the Kubernetes API server.
+func ValidateTolerations(tolerations []corev1.Toleration) error {
+	errs := []error{}
+
+	for _, toleration := range tolerations {
+		if toleration.Key != "" {
+			for _, msg := range validation.IsQualifiedName(toleration.Key) {
+				errs = append(errs, fmt.Errorf("toleration key %q: %s", toleration.Key, msg))
+			}
+		}
+
+		switch toleration.Operator {
+		case corev1.TolerationOpEqual, "":
+			if toleration.Key == "" {
+				errs = append(errs, errors.New("toleration with an empty key must use the Exists operator"))
+			}
+
+			for _, msg := range validation.IsValidLabelValue(toleration.Value) {
+				errs = append(errs, fmt.Errorf("toleration value %q: %s", toleration.Value, msg))
+			}
+		case corev1.TolerationOpExists:
+			if toleration.Value != "" {
+				errs = append(errs, fmt.Errorf("toleration %q with the Exists operator must not have a value", toleration.Key))
+			}
+		default:
+			errs = append(errs, fmt.Errorf("toleration %q has an unsupported operator %q", toleration.Key, toleration.Operator))
+		}
+
+		switch toleration.Effect {
+		case "", corev1.TaintEffectNoSchedule, corev1.TaintEffectPreferNoSchedule, corev1.TaintEffectNoExecute:
+		default:
+			errs = append(errs, fmt.Errorf("toleration %q has an unsupported effect %q", toleration.Key, toleration.Effect))
+		}
+
+		if toleration.TolerationSeconds != nil && toleration.Effect != corev1.TaintEffectNoExecute {
+			errs = append(errs, fmt.Errorf("toleration %q can only set seconds with the NoExecute effect", toleration.Key))
+		}
+	}
+
+	return errors.Join(errs...)
+}
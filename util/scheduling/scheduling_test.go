@@ -0,0 +1,66 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduling
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+)
+
+var _ = Describe("Agent scheduling constraints", func() {
+	DescribeTable("should parse tolerations",
+		func(spec string, expected corev1.Toleration) {
+			toleration, err := ParseToleration(spec)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(toleration).To(Equal(expected))
+		},
+		Entry("key, value and effect", "node-role.kubernetes.io/infra=true:NoSchedule", corev1.Toleration{
+			Key: "node-role.kubernetes.io/infra", Operator: corev1.TolerationOpEqual, Value: "true", Effect: corev1.TaintEffectNoSchedule,
+		}),
+		Entry("key and effect", "dedicated:NoExecute", corev1.Toleration{
+			Key: "dedicated", Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoExecute,
+		}),
+		Entry("key only", "dedicated", corev1.Toleration{
+			Key: "dedicated", Operator: corev1.TolerationOpExists,
+		}),
+	)
+
+	DescribeTable("should reject malformed tolerations",
+		func(spec string) {
+			_, err := ParseToleration(spec)
+			Expect(err).To(HaveOccurred())
+		},
+		Entry("invalid key", "not a key:NoSchedule"),
+		Entry("invalid effect", "dedicated=true:Sometimes"),
+		Entry("invalid value", "dedicated=not a value"),
+		Entry("empty key with value", "=true"),
+	)
+
+	It("should validate node selectors", func() {
+		Expect(ValidateNodeSelector(map[string]string{"node-role.kubernetes.io/infra": "true"})).To(Succeed())
+		Expect(ValidateNodeSelector(map[string]string{"not a key": "true"})).ToNot(Succeed())
+		Expect(ValidateNodeSelector(map[string]string{"role": "not a value"})).ToNot(Succeed())
+	})
+})
+
+func TestScheduling(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Scheduling Suite")
+}
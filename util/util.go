@@ -19,6 +19,7 @@ package util
 import (
 	"context"
 	"strconv"
+	"strings"
 
 	"github.com/go-logr/logr"
 	corev1 "k8s.io/api/core/v1"
@@ -26,15 +27,30 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
 )
 
-// ShouldImport checks if the object has the label set to true.
+// ImportDisabledValue is the import label value explicitly opting an object out of auto-import, like "false".
+const ImportDisabledValue = "disabled"
+
+// ShouldImport checks if the object has the label set to true. The object can also be marked with an annotation of the
+// same key, e.g. a namespace managed with GitOps where labels are reserved for selection, and the label takes
+// precedence when both are set. A value of "false", "disabled" or any other value which is not true is reported as
+// present with a false value.
 func ShouldImport(obj metav1.Object, label string) (hasLabel bool, labelValue bool) {
 	labelVal, ok := obj.GetLabels()[label]
+	if !ok {
+		labelVal, ok = obj.GetAnnotations()[label]
+	}
+
 	if !ok {
 		return false, false
 	}
 
+	if strings.EqualFold(labelVal, ImportDisabledValue) {
+		return true, false
+	}
+
 	autoImport, err := strconv.ParseBool(labelVal)
 	if err != nil {
 		return true, false
@@ -43,8 +59,33 @@ func ShouldImport(obj metav1.Object, label string) (hasLabel bool, labelValue bo
 	return true, autoImport
 }
 
-// ShouldAutoImport checks if the namespace or cluster has the label set to true.
+// ImportSource describes what marked a cluster for import.
+type ImportSource string
+
+const (
+	// ImportSourceNone is used when the cluster is not marked for import.
+	ImportSourceNone ImportSource = ""
+	// ImportSourceClusterLabel is used when the cluster is marked for import by its own label, or annotation.
+	ImportSourceClusterLabel ImportSource = "cluster-label"
+	// ImportSourceNamespaceLabel is used when the cluster is marked for import by its namespace label, or annotation.
+	ImportSourceNamespaceLabel ImportSource = "namespace-label"
+)
+
+// ShouldAutoImport checks if the namespace or cluster has the label set to true, following the precedence rules of
+// AutoImportSource.
 func ShouldAutoImport(ctx context.Context, logger logr.Logger, cl client.Client, capiCluster *clusterv1.Cluster, label string) (bool, error) {
+	source, err := AutoImportSource(ctx, logger, cl, capiCluster, label)
+
+	return source != ImportSourceNone, err
+}
+
+// AutoImportSource returns what marked the cluster for import, or ImportSourceNone if the cluster should not be imported.
+// The label of the cluster takes precedence over the label of its namespace, an annotation standing for a missing label:
+//   - a cluster labeled true is imported regardless of its namespace;
+//   - a cluster labeled false or disabled is never imported, even when its namespace is labeled true;
+//   - a cluster without the label is imported when its namespace is labeled true.
+func AutoImportSource(ctx context.Context, logger logr.Logger, cl client.Client, capiCluster *clusterv1.Cluster, label string,
+) (ImportSource, error) {
 	logger.V(2).Info("should we auto import the capi cluster", "name", capiCluster.Name, "namespace", capiCluster.Namespace)
 
 	// Check CAPI cluster for label first
@@ -52,13 +93,13 @@ func ShouldAutoImport(ctx context.Context, logger logr.Logger, cl client.Client,
 	if hasLabel && autoImport {
 		logger.V(2).Info("Cluster contains import annotation")
 
-		return true, nil
+		return ImportSourceClusterLabel, nil
 	}
 
 	if hasLabel && !autoImport {
 		logger.V(2).Info("Cluster contains annotation to not import")
 
-		return false, nil
+		return ImportSourceNone, nil
 	}
 
 	// Check namespace wide
@@ -67,10 +108,51 @@ func ShouldAutoImport(ctx context.Context, logger logr.Logger, cl client.Client,
 
 	if err := cl.Get(ctx, key, ns); err != nil {
 		logger.Error(err, "getting namespace")
-		return false, err
+		return ImportSourceNone, err
 	}
 
-	_, autoImport = ShouldImport(ns, label)
+	if _, autoImport = ShouldImport(ns, label); autoImport {
+		return ImportSourceNamespaceLabel, nil
+	}
+
+	return ImportSourceNone, nil
+}
+
+// ControlPlaneReadiness configures the additional signals the control plane of a cluster is considered ready on, for
+// providers which don't promptly report the standard ones, e.g. some managed control planes.
+type ControlPlaneReadiness struct {
+	// Conditions are the condition types marking the control plane ready when true, e.g. provider-specific conditions.
+	Conditions []clusterv1.ConditionType
+	// ProvisionedPhase marks the control plane ready once the cluster is in the Provisioned phase.
+	ProvisionedPhase bool
+}
+
+// Ready returns whether the control plane of the cluster is ready, and the signal it was found ready on. The signals
+// are checked in order of precedence, and the first one set marks the control plane ready:
+//   - the ControlPlaneReady status field;
+//   - the standard ControlPlaneReady condition;
+//   - the additional conditions, in the configured order;
+//   - the Provisioned phase, when enabled.
+//
+// The zero value only accepts the standard signals.
+func (r ControlPlaneReadiness) Ready(cluster *clusterv1.Cluster) (bool, string) {
+	if cluster.Status.ControlPlaneReady {
+		return true, "status"
+	}
+
+	if conditions.IsTrue(cluster, clusterv1.ControlPlaneReadyCondition) {
+		return true, "condition " + string(clusterv1.ControlPlaneReadyCondition)
+	}
+
+	for _, conditionType := range r.Conditions {
+		if conditions.IsTrue(cluster, conditionType) {
+			return true, "condition " + string(conditionType)
+		}
+	}
+
+	if r.ProvisionedPhase && cluster.Status.GetTypedPhase() == clusterv1.ClusterPhaseProvisioned {
+		return true, "phase " + string(clusterv1.ClusterPhaseProvisioned)
+	}
 
-	return autoImport, nil
+	return false, ""
 }
@@ -0,0 +1,144 @@
+/*
+Copyright © 2023 - 2024 SUSE LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-logr/logr"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const importLabel = "cluster-api.cattle.io/rancher-auto-import"
+
+var _ = Describe("Auto import label precedence", func() {
+	labels := func(value string) map[string]string {
+		if value == "" {
+			return nil
+		}
+
+		return map[string]string{importLabel: value}
+	}
+
+	DescribeTable("should resolve the import source from the cluster and namespace labels",
+		func(namespaceLabel, clusterLabel string, expected ImportSource) {
+			ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test-ns", Labels: labels(namespaceLabel)}}
+			capiCluster := &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-cluster",
+				Namespace: "test-ns",
+				Labels:    labels(clusterLabel),
+			}}
+			cl := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(ns).Build()
+
+			source, err := AutoImportSource(context.Background(), logr.Discard(), cl, capiCluster, importLabel)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(source).To(Equal(expected))
+
+			autoImport, err := ShouldAutoImport(context.Background(), logr.Discard(), cl, capiCluster, importLabel)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(autoImport).To(Equal(expected != ImportSourceNone))
+		},
+		Entry("no labels", "", "", ImportSourceNone),
+		Entry("namespace true, cluster missing", "true", "", ImportSourceNamespaceLabel),
+		Entry("namespace false, cluster missing", "false", "", ImportSourceNone),
+		Entry("namespace disabled, cluster missing", "disabled", "", ImportSourceNone),
+		Entry("namespace missing, cluster true", "", "true", ImportSourceClusterLabel),
+		Entry("namespace missing, cluster false", "", "false", ImportSourceNone),
+		Entry("namespace true, cluster true", "true", "true", ImportSourceClusterLabel),
+		Entry("namespace true, cluster false", "true", "false", ImportSourceNone),
+		Entry("namespace true, cluster disabled", "true", "disabled", ImportSourceNone),
+		Entry("namespace false, cluster true", "false", "true", ImportSourceClusterLabel),
+		Entry("namespace false, cluster false", "false", "false", ImportSourceNone),
+	)
+
+	DescribeTable("should parse the import label of an object",
+		func(value string, hasLabel, labelValue bool) {
+			ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test-ns", Labels: labels(value)}}
+
+			has, autoImport := ShouldImport(ns, importLabel)
+			Expect(has).To(Equal(hasLabel))
+			Expect(autoImport).To(Equal(labelValue))
+		},
+		Entry("missing", "", false, false),
+		Entry("true", "true", true, true),
+		Entry("false", "false", true, false),
+		Entry("disabled", "disabled", true, false),
+		Entry("disabled in upper case", "Disabled", true, false),
+		Entry("invalid", "maybe", true, false),
+	)
+
+	DescribeTable("should parse the import annotation of an object",
+		func(labelValue, annotationValue string, hasMarker, autoImport bool) {
+			ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+				Name:        "test-ns",
+				Labels:      labels(labelValue),
+				Annotations: labels(annotationValue),
+			}}
+
+			has, value := ShouldImport(ns, importLabel)
+			Expect(has).To(Equal(hasMarker))
+			Expect(value).To(Equal(autoImport))
+		},
+		Entry("label only", "true", "", true, true),
+		Entry("annotation only", "", "true", true, true),
+		Entry("annotation only, false", "", "false", true, false),
+		Entry("annotation only, disabled", "", "disabled", true, false),
+		Entry("both agree, true", "true", "true", true, true),
+		Entry("both agree, false", "false", "false", true, false),
+		Entry("both conflict, label true", "true", "false", true, true),
+		Entry("both conflict, label false", "false", "true", true, false),
+	)
+
+	DescribeTable("should auto import with the import annotation",
+		func(nsAnnotation, clusterLabel, clusterAnnotation string, expected ImportSource) {
+			ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test-ns", Annotations: labels(nsAnnotation)}}
+			capiCluster := &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{
+				Name:        "test-cluster",
+				Namespace:   ns.Name,
+				Labels:      labels(clusterLabel),
+				Annotations: labels(clusterAnnotation),
+			}}
+			cl := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(ns).Build()
+
+			source, err := AutoImportSource(context.Background(), logr.Discard(), cl, capiCluster, importLabel)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(source).To(Equal(expected))
+
+			autoImport, err := ShouldAutoImport(context.Background(), logr.Discard(), cl, capiCluster, importLabel)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(autoImport).To(Equal(expected != ImportSourceNone))
+		},
+		Entry("namespace annotated true", "true", "", "", ImportSourceNamespaceLabel),
+		Entry("namespace annotated false", "false", "", "", ImportSourceNone),
+		Entry("cluster annotated true", "", "", "true", ImportSourceClusterLabel),
+		Entry("namespace annotated true, cluster annotated false", "true", "", "false", ImportSourceNone),
+		Entry("cluster labeled true, annotated false", "", "true", "false", ImportSourceClusterLabel),
+		Entry("cluster labeled false, annotated true", "true", "false", "true", ImportSourceNone),
+	)
+})
+
+func TestUtil(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Util Suite")
+}